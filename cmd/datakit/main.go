@@ -36,6 +36,7 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs"
 	_ "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/all"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit/budget"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/service"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/usagetrace"
 )
@@ -219,12 +220,14 @@ func tryLoadConfig() {
 func startIO() {
 	c := config.Cfg.IO
 	opts := []dkio.IOOption{
-		dkio.WithFeederOutputer(dkio.NewDatawayOutput(c.FeedChanSize)),
+		dkio.WithFeederOutputer(dkio.NewDatawayOutput(c.FeedChanSize, dkio.WithFeedPolicy(c.FeedPolicy))),
 		dkio.WithDataway(config.Cfg.Dataway),
 		dkio.WithCompactAt(c.MaxCacheCount),
 		dkio.WithFilters(c.Filters),
 		dkio.WithCompactWorkers(c.CompactWorkers),
 		dkio.WithRecorder(config.Cfg.Recorder),
+		dkio.WithCardinality(c.Cardinality),
+		dkio.WithAggregate(c.Aggregate),
 		dkio.WithRemoteJob(config.Cfg.RemoteJob, config.Cfg.Dataway),
 		dkio.WithAvailableCPUs(datakit.AvailableCPUs),
 	}
@@ -384,6 +387,8 @@ func doRun() error {
 		}()),
 	)
 
+	budget.Run(config.Cfg.ResourceLimitOptions)
+
 	if config.ConfdEnabled() {
 		if err := confd.Run(config.Cfg.Confds); err != nil {
 			return err
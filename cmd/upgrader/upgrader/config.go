@@ -39,6 +39,9 @@ type MainConfig struct {
 	Username         string     `toml:"username"`
 	DCAWebsocketURL  string     `toml:"dca_websocket_url"`
 
+	Verify   *VerifyConfig   `toml:"verify"`
+	Rollback *RollbackConfig `toml:"rollback"`
+
 	upgradeUpgraderService,
 	dkUpgrade,
 	installOnly bool
@@ -184,6 +187,12 @@ func defaultMainConfig() *MainConfig {
 		},
 
 		InstallDir: InstallDir,
+
+		// the health check blocks the upgrade request for up to grace period, disabled by default.
+		Rollback: &RollbackConfig{
+			Enable:      false,
+			GracePeriod: defaultGracePeriod,
+		},
 	}
 
 	if runtime.GOOS == datakit.OSWindows {
@@ -77,6 +77,46 @@ func (c *DCAClient) UpdateDatakit(datakit *ws.DataKit, updateRemote bool) {
 	}
 }
 
+// ReportUpgradeHistory send Datakit upgrade record to DCA.
+func (c *DCAClient) ReportUpgradeHistory(r *UpgradeRecord) {
+	if c.Client == nil || r == nil {
+		return
+	}
+
+	j, err := json.Marshal(r)
+	if err != nil {
+		l.Warnf("json.Marshal: %s", err.Error())
+		return
+	}
+
+	if err := c.SendMessage(&ws.WebsocketMessage{
+		Action: ws.UpgradeHistory,
+		Data:   ws.ActionData{Body: string(j)},
+	}); err != nil {
+		l.Warnf("send message failed: %s", err.Error())
+	}
+}
+
+// ReportRolloutProgress send progress of the rollout on current host to DCA.
+func (c *DCAClient) ReportRolloutProgress(s *RolloutStatus) {
+	if c.Client == nil || s == nil {
+		return
+	}
+
+	j, err := json.Marshal(s)
+	if err != nil {
+		l.Warnf("json.Marshal: %s", err.Error())
+		return
+	}
+
+	if err := c.SendMessage(&ws.WebsocketMessage{
+		Action: ws.RolloutProgress,
+		Data:   ws.ActionData{Body: string(j)},
+	}); err != nil {
+		l.Warnf("send message failed: %s", err.Error())
+	}
+}
+
 func (c *DCAClient) isInitialized() bool {
 	return c.Client != nil
 }
@@ -348,6 +388,25 @@ func upgradeDatakitAction(client *ws.Client, response *ws.DCAResponse, data *ws.
 	response.SetSuccess("ok")
 }
 
+// rolloutDatakitAction apply the rollout policy, the upgrade is scheduled on
+// current host according to the policy.
+func rolloutDatakitAction(client *ws.Client, response *ws.DCAResponse, data *ws.ActionData, datakit *ws.DataKit) {
+	var p RolloutPolicy
+	if err := json.Unmarshal([]byte(data.Body), &p); err != nil {
+		response.SetError(&ws.ResponseError{Code: 400, ErrorCode: "param.invalid", ErrorMsg: "invalid rollout policy"})
+		return
+	}
+
+	s, err := rollouts.start(&p)
+	if err != nil {
+		l.Errorf("start rollout failed: %s", upgradeErrorMessage(err))
+		response.SetError(&ws.ResponseError{Code: 500, ErrorCode: "rollout.failed", ErrorMsg: upgradeErrorMessage(err)})
+		return
+	}
+
+	response.SetSuccess(s)
+}
+
 func stopDatakitAction(client *ws.Client, response *ws.DCAResponse, data *ws.ActionData, datakit *ws.DataKit) {
 	if err := ui.forceStopService(); err != nil {
 		l.Errorf("force stop datakit failed: %s", err.Error())
@@ -378,6 +437,7 @@ func init() {
 	ActionHandlerMap[ws.GetDatakitStatsAction] = ws.GetActionHandler(ws.GetDatakitStatsAction, getDatakitStatsAction)
 	ActionHandlerMap[ws.ReloadDatakitAction] = ws.GetActionHandler(ws.ReloadDatakitAction, reloadDatakitAction)
 	ActionHandlerMap[ws.UpgradeDatakitAction] = ws.GetActionHandler(ws.UpgradeDatakitAction, upgradeDatakitAction)
+	ActionHandlerMap[ws.RolloutDatakitAction] = ws.GetActionHandler(ws.RolloutDatakitAction, rolloutDatakitAction)
 	ActionHandlerMap[ws.StopDatakitAction] = ws.GetActionHandler(ws.StopDatakitAction, stopDatakitAction)
 	ActionHandlerMap[ws.RestartDatakitAction] = ws.GetActionHandler(ws.RestartDatakitAction, restartDatakitAction)
 	ActionHandlerMap[ws.SaveDatakitConfigAction] = ws.GetActionHandler(ws.SaveDatakitConfigAction, saveDatakitConfigAction)
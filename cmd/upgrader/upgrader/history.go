@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+)
+
+const (
+	historyFile = "upgrade_history.json"
+	maxHistory  = 100
+
+	UpgradeSucceeded      = "succeeded"
+	UpgradeFailed         = "failed"
+	UpgradeRolledBack     = "rolled_back"
+	UpgradeRollbackFailed = "rollback_failed"
+)
+
+// UpgradeRecord is a history entry of one Datakit upgrade.
+type UpgradeRecord struct {
+	From   string    `json:"from"`
+	To     string    `json:"to"`
+	Status string    `json:"status"`
+	Error  string    `json:"error,omitempty"`
+	Start  time.Time `json:"start"`
+	End    time.Time `json:"end"`
+}
+
+var historyMtx sync.Mutex
+
+func (u *upgraderImpl) historyPath() string {
+	if u.c.InstallDir == "" {
+		return ""
+	}
+	return filepath.Join(u.c.InstallDir, historyFile)
+}
+
+// loadHistory load upgrade history, the latest at the end.
+func (u *upgraderImpl) loadHistory() ([]*UpgradeRecord, error) {
+	historyMtx.Lock()
+	defer historyMtx.Unlock()
+
+	return u.doLoadHistory()
+}
+
+func (u *upgraderImpl) doLoadHistory() ([]*UpgradeRecord, error) {
+	f := u.historyPath()
+	if f == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(f))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	var records []*UpgradeRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+
+	return records, nil
+}
+
+// addHistory save the upgrade record and report it to DCA.
+func (u *upgraderImpl) addHistory(r *UpgradeRecord) {
+	r.End = time.Now()
+
+	dcaClient.ReportUpgradeHistory(r)
+
+	f := u.historyPath()
+	if f == "" {
+		return
+	}
+
+	historyMtx.Lock()
+	defer historyMtx.Unlock()
+
+	records, err := u.doLoadHistory()
+	if err != nil {
+		l.Warnf("load upgrade history failed: %s, history reset", err.Error())
+	}
+
+	records = append(records, r)
+	if len(records) > maxHistory {
+		records = records[len(records)-maxHistory:]
+	}
+
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		l.Warnf("json.Marshal: %s", err.Error())
+		return
+	}
+
+	if err := os.WriteFile(f, data, datakit.ConfPerm); err != nil {
+		l.Warnf("save upgrade history to %q failed: %s", f, err.Error())
+	}
+}
@@ -6,6 +6,7 @@
 package upgrader
 
 import (
+	"encoding/json"
 	"net/http"
 
 	"github.com/GuanceCloud/cliutils/logger"
@@ -81,6 +82,69 @@ func apiUpgrade(w http.ResponseWriter, r *http.Request, args ...interface{}) (in
 	return uhttp.RawJSONBody(`{"msg": "success"}`), nil
 }
 
+func apiUpgradeHistory(w http.ResponseWriter, r *http.Request, args ...interface{}) (interface{}, error) {
+	if args == nil || len(args) != 1 {
+		l.Error("invalid handler")
+		return nil, httpapi.ErrInvalidAPIHandler
+	}
+
+	var u *upgraderImpl
+
+	for _, arg := range args {
+		switch x := arg.(type) {
+		case *upgraderImpl:
+			u = x
+		default:
+			return nil, httpapi.ErrInvalidAPIHandler
+		}
+	}
+
+	records, err := u.loadHistory()
+	if err != nil {
+		return nil, uhttp.Errorf(httpapi.ErrUpgradeFailed, "load upgrade history: %s", err.Error())
+	}
+
+	if records == nil {
+		records = []*UpgradeRecord{}
+	}
+
+	return records, nil
+}
+
+func apiRollout(w http.ResponseWriter, r *http.Request, args ...interface{}) (interface{}, error) {
+	if args == nil || len(args) != 1 {
+		l.Error("invalid handler")
+		return nil, httpapi.ErrInvalidAPIHandler
+	}
+
+	var m *rolloutManager
+
+	for _, arg := range args {
+		switch x := arg.(type) {
+		case *rolloutManager:
+			m = x
+		default:
+			return nil, httpapi.ErrInvalidAPIHandler
+		}
+	}
+
+	switch r.Method {
+	case http.MethodPost:
+		var p RolloutPolicy
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			return nil, uhttp.Errorf(httpapi.ErrInvalidRequest, "decode rollout policy: %s", err.Error())
+		}
+
+		return m.start(&p)
+
+	case http.MethodDelete:
+		return m.cancel(), nil
+
+	default:
+		return m.current(), nil
+	}
+}
+
 func DebugRun() {
 	if err := Cfg.LoadMainTOML(MainConfigFile); err != nil {
 		l.Warnf("unable to load main config file: %s", err)
@@ -117,6 +181,14 @@ func startHTTPServer() *http.Server {
 	router.GET("/v1/datakit/version",
 		httpapi.RawHTTPWrapper(nil, apiDKVersion, ui))
 
+	router.GET("/v1/datakit/upgrade/history",
+		httpapi.RawHTTPWrapper(nil, apiUpgradeHistory, ui))
+
+	router.Any("/v1/datakit/rollout",
+		httpapi.RawHTTPWrapper(nil, apiRollout, rollouts))
+
+	rollouts.resume()
+
 	serv := &http.Server{
 		Addr:    Cfg.Listen,
 		Handler: router,
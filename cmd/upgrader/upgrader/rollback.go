@@ -0,0 +1,229 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/httpapi"
+
+	ws "gitlab.jiagouyun.com/cloudcare-tools/datakit/dca/websocket"
+)
+
+const (
+	defaultGracePeriod  = 3 * time.Minute
+	healthCheckInterval = 5 * time.Second
+
+	backupDirName     = "backup"
+	backupVersionFile = "version"
+)
+
+// RollbackConfig configure health check on upgraded Datakit, if the new
+// Datakit not healthy within GracePeriod, the previous version restored.
+type RollbackConfig struct {
+	Enable      bool          `toml:"enable"`
+	GracePeriod time.Duration `toml:"grace_period"`
+}
+
+func (rc *RollbackConfig) gracePeriod() time.Duration {
+	if rc.GracePeriod <= 0 {
+		return defaultGracePeriod
+	}
+	return rc.GracePeriod
+}
+
+func (u *upgraderImpl) rollbackEnabled() bool {
+	return u.c.Rollback != nil && u.c.Rollback.Enable
+}
+
+func datakitBin() string {
+	if runtime.GOOS == datakit.OSWindows {
+		return filepath.Join(datakit.InstallDir, "datakit.exe")
+	}
+	return filepath.Join(datakit.InstallDir, "datakit")
+}
+
+// files to keep for rollback: Datakit binary and it's main configure(the
+// installer may upgrade the configure during upgrading).
+func backupFiles() []string {
+	return []string{datakitBin(), datakit.MainConfPath}
+}
+
+func (u *upgraderImpl) backupDir() string {
+	return filepath.Join(u.c.InstallDir, backupDirName)
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(filepath.Clean(src))
+	if err != nil {
+		return err
+	}
+	defer in.Close() //nolint:errcheck,gosec
+
+	fi, err := in.Stat()
+	if err != nil {
+		return err
+	}
+
+	// write to temp file and rename, so a half copied file never overwrite dst.
+	tmp := dst + ".tmp"
+	out, err := os.OpenFile(filepath.Clean(tmp), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, fi.Mode())
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close() //nolint:errcheck,gosec
+		return err
+	}
+
+	if err := out.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, dst)
+}
+
+// backupDatakit keep current Datakit(version ver) under backup dir, previous backup overwritten.
+func (u *upgraderImpl) backupDatakit(ver string) error {
+	dir := u.backupDir()
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("create backup dir %q: %w", dir, err)
+	}
+
+	for _, f := range backupFiles() {
+		if err := copyFile(f, filepath.Join(dir, filepath.Base(f))); err != nil {
+			return fmt.Errorf("backup %q: %w", f, err)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, backupVersionFile), []byte(ver), datakit.ConfPerm); err != nil {
+		return fmt.Errorf("write backup version: %w", err)
+	}
+
+	l.Infof("Datakit %s backup to %s", ver, dir)
+	return nil
+}
+
+// restoreDatakit copy backup files back to Datakit install dir.
+func (u *upgraderImpl) restoreDatakit() (string, error) {
+	dir := u.backupDir()
+
+	ver, err := os.ReadFile(filepath.Join(dir, backupVersionFile))
+	if err != nil {
+		return "", fmt.Errorf("no Datakit backup available: %w", err)
+	}
+
+	for _, f := range backupFiles() {
+		if err := copyFile(filepath.Join(dir, filepath.Base(f)), f); err != nil {
+			return "", fmt.Errorf("restore %q: %w", f, err)
+		}
+	}
+
+	return string(ver), nil
+}
+
+// rollback stop the upgraded Datakit and start the previous one.
+func (u *upgraderImpl) rollback() (string, error) {
+	if err := u.forceStopService(); err != nil {
+		l.Warnf("stop Datakit before rollback failed: %s, ignored", err.Error())
+	}
+
+	ver, err := u.restoreDatakit()
+	if err != nil {
+		return "", err
+	}
+
+	u.tryStartService()
+
+	l.Infof("Datakit rollback to %s", ver)
+	return ver, nil
+}
+
+// enabledInputs get number of enabled inputs of running Datakit.
+func (u *upgraderImpl) enabledInputs() (int, error) {
+	schema := "http"
+	if u.c.DatakitAPIHTTPS {
+		schema = "https"
+	}
+
+	var (
+		stats httpapi.DCAstats
+		res   = ws.DCAResponse{Content: &stats}
+		cli   = &baseDatakitClient{}
+	)
+
+	if err := cli.Request(http.MethodGet, "/stat", &res,
+		fmt.Sprintf("%s://%s", schema, u.c.DatakitAPIListen), nil); err != nil {
+		return 0, err
+	}
+
+	if !res.Success || stats.DatakitStats == nil {
+		return 0, fmt.Errorf("get Datakit stats failed: %s", res.Message)
+	}
+
+	return len(stats.EnabledInputs), nil
+}
+
+// checkHealth check if upgraded Datakit is version ver, and inputs enabled
+// as before upgrade.
+func (u *upgraderImpl) checkHealth(ver string, inputs int) error {
+	pi, err := u.fetchCurrentDKVersion()
+	if err != nil {
+		return err
+	}
+
+	if ver != "" && pi.Content.Version != ver {
+		return fmt.Errorf("Datakit version is %q, expect %q", pi.Content.Version, ver) //nolint:stylecheck
+	}
+
+	n, err := u.enabledInputs()
+	if err != nil {
+		return err
+	}
+
+	if n < inputs {
+		return fmt.Errorf("%d inputs enabled, expect at least %d", n, inputs)
+	}
+
+	return nil
+}
+
+// waitHealthy wait upgraded Datakit become healthy within grace period.
+func (u *upgraderImpl) waitHealthy(ver string, inputs int) error {
+	var (
+		deadline = time.Now().Add(u.c.Rollback.gracePeriod())
+		interval = healthCheckInterval
+		err      error
+	)
+
+	if interval > u.c.Rollback.gracePeriod() {
+		interval = u.c.Rollback.gracePeriod()
+	}
+
+	for {
+		if err = u.checkHealth(ver, inputs); err == nil {
+			l.Infof("upgraded Datakit %s healthy", ver)
+			return nil
+		}
+
+		l.Infof("upgraded Datakit not healthy: %s", err.Error())
+
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("Datakit not healthy within %s: %w", //nolint:stylecheck
+				u.c.Rollback.gracePeriod(), err)
+		}
+
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,151 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	T "testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	ws "gitlab.jiagouyun.com/cloudcare-tools/datakit/dca/websocket"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/httpapi"
+)
+
+func TestBackupRestore(t *T.T) {
+	dkDir := t.TempDir()
+
+	oldInstallDir, oldMainConf := datakit.InstallDir, datakit.MainConfPath
+	datakit.InstallDir = dkDir
+	datakit.MainConfPath = filepath.Join(dkDir, "datakit.conf")
+	defer func() {
+		datakit.InstallDir, datakit.MainConfPath = oldInstallDir, oldMainConf
+	}()
+
+	require.NoError(t, os.WriteFile(datakitBin(), []byte("old-binary"), 0o700))
+	require.NoError(t, os.WriteFile(datakit.MainConfPath, []byte("old-conf"), 0o600))
+
+	u := &upgraderImpl{c: &MainConfig{InstallDir: t.TempDir()}}
+
+	t.Run("no-backup", func(t *T.T) {
+		_, err := u.restoreDatakit()
+		assert.Error(t, err)
+	})
+
+	t.Run("backup-and-restore", func(t *T.T) {
+		require.NoError(t, u.backupDatakit("1.2.3"))
+
+		// upgraded
+		require.NoError(t, os.WriteFile(datakitBin(), []byte("new-binary"), 0o700))
+		require.NoError(t, os.WriteFile(datakit.MainConfPath, []byte("new-conf"), 0o600))
+
+		ver, err := u.restoreDatakit()
+		require.NoError(t, err)
+		assert.Equal(t, "1.2.3", ver)
+
+		data, err := os.ReadFile(datakitBin())
+		require.NoError(t, err)
+		assert.Equal(t, "old-binary", string(data))
+
+		data, err = os.ReadFile(datakit.MainConfPath)
+		require.NoError(t, err)
+		assert.Equal(t, "old-conf", string(data))
+	})
+}
+
+func TestWaitHealthy(t *T.T) {
+	var (
+		dkVersion = atomic.NewString("1.2.3")
+		inputs    = atomic.NewInt32(2)
+	)
+
+	router := gin.New()
+	router.GET("/v1/ping", func(c *gin.Context) {
+		j, err := json.Marshal(pingInfo{Content: httpapi.Ping{Version: dkVersion.Load()}})
+		assert.NoError(t, err)
+		c.Data(200, "application/json", j)
+	})
+
+	router.GET("/v1/dca/stat", func(c *gin.Context) {
+		enabled := map[string]any{}
+		for i := 0; i < int(inputs.Load()); i++ {
+			enabled[string(rune('a'+i))] = map[string]any{"instances": 1}
+		}
+
+		j, err := json.Marshal(ws.DCAResponse{
+			Success: true,
+			Content: map[string]any{"enabled_input_list": enabled},
+		})
+		assert.NoError(t, err)
+		c.Data(200, "application/json", j)
+	})
+
+	ts := httptest.NewServer(router)
+	defer ts.Close()
+
+	u := &upgraderImpl{
+		c: &MainConfig{
+			DatakitAPIListen: ts.Listener.Addr().String(),
+			Rollback:         &RollbackConfig{Enable: true, GracePeriod: 100 * time.Millisecond},
+		},
+	}
+
+	t.Run("healthy", func(t *T.T) {
+		assert.NoError(t, u.waitHealthy("1.2.3", 2))
+	})
+
+	t.Run("version-mismatch", func(t *T.T) {
+		err := u.waitHealthy("4.5.6", 2)
+		assert.ErrorContains(t, err, "expect \"4.5.6\"")
+	})
+
+	t.Run("inputs-missing", func(t *T.T) {
+		inputs.Store(1)
+		defer inputs.Store(2)
+
+		err := u.waitHealthy("1.2.3", 2)
+		assert.ErrorContains(t, err, "1 inputs enabled")
+	})
+
+	t.Run("datakit-down", func(t *T.T) {
+		x := &upgraderImpl{
+			c: &MainConfig{
+				DatakitAPIListen: "127.0.0.1:1", // nothing listening
+				Rollback:         &RollbackConfig{Enable: true, GracePeriod: 100 * time.Millisecond},
+			},
+		}
+
+		assert.Error(t, x.waitHealthy("1.2.3", 0))
+	})
+}
+
+func TestUpgradeHistory(t *T.T) {
+	u := &upgraderImpl{c: &MainConfig{InstallDir: t.TempDir()}}
+
+	records, err := u.loadHistory()
+	assert.NoError(t, err)
+	assert.Len(t, records, 0)
+
+	for i := 0; i < maxHistory+10; i++ {
+		u.addHistory(&UpgradeRecord{From: "1.2.3", To: "4.5.6", Status: UpgradeSucceeded, Start: time.Now()})
+	}
+
+	u.addHistory(&UpgradeRecord{From: "1.2.3", To: "1.2.3", Status: UpgradeRolledBack, Error: "not healthy"})
+
+	records, err = u.loadHistory()
+	assert.NoError(t, err)
+	assert.Len(t, records, maxHistory)
+	assert.Equal(t, UpgradeRolledBack, records[len(records)-1].Status)
+	assert.False(t, records[len(records)-1].End.IsZero())
+}
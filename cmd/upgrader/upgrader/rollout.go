@@ -0,0 +1,710 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math/rand"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	uhttp "github.com/GuanceCloud/cliutils/network/http"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/httpapi"
+
+	ws "gitlab.jiagouyun.com/cloudcare-tools/datakit/dca/websocket"
+)
+
+const (
+	rolloutFile         = "rollout.json"
+	defaultWaveInterval = 10 * time.Minute
+
+	RolloutPending   = "pending"   // waiting for scheduled time
+	RolloutSkipped   = "skipped"   // host not selected by the canary percent
+	RolloutPaused    = "paused"    // too many failures within the fleet
+	RolloutUpgrading = "upgrading" // upgrade on going
+	RolloutSucceeded = "succeeded"
+	RolloutFailed    = "failed"
+	RolloutCancelled = "cancelled"
+
+	groupByNamespace = "namespace"
+)
+
+// MaxParallel limit the number of hosts upgrading at the same time within a
+// group(election namespace or tag value). Hosts of a group are split into waves
+// of at most Limit hosts, and waves start WaveInterval one after another.
+type MaxParallel struct {
+	GroupBy string `json:"group_by"` // namespace or a election/global-host tag key
+	Limit   int    `json:"limit"`
+
+	// Members list hosts of each group, so each host get an exact wave. If
+	// not set, GroupSizes used and host's wave is chosen by hash, then the
+	// number of hosts within a wave is approximate to Limit.
+	Members    map[string][]string `json:"members,omitempty"`
+	GroupSizes map[string]int      `json:"group_sizes,omitempty"`
+}
+
+// RolloutPolicy is constraints of a fleet-wide upgrade. The same policy is sent
+// to all hosts, and each host evaluate it locally.
+type RolloutPolicy struct {
+	ID      string `json:"id"`
+	Version string `json:"version"`
+	Force   bool   `json:"force,omitempty"`
+
+	// Only hosts whose bucket(0~99, hashed from rollout ID and host) less
+	// than Percent upgraded, 0 means all hosts.
+	Percent int `json:"percent,omitempty"`
+
+	// Maintenance windows like "02:00-04:00", "Mon-Fri 22:00-02:00" or "Sat,Sun 00:00-06:00".
+	Windows  []string `json:"windows,omitempty"`
+	Timezone string   `json:"timezone,omitempty"`
+
+	Jitter       string       `json:"jitter,omitempty"`
+	MaxParallel  *MaxParallel `json:"max_parallel,omitempty"`
+	WaveInterval string       `json:"wave_interval,omitempty"`
+
+	// The rollout paused on this host if FleetFailures reach PauseOnFailure.
+	// FleetFailures updated by the controller(DCA) by posting the policy
+	// with the same ID.
+	PauseOnFailure int `json:"pause_on_failure,omitempty"`
+	FleetFailures  int `json:"fleet_failures,omitempty"`
+
+	windows      []*window
+	loc          *time.Location
+	jitter       time.Duration
+	waveInterval time.Duration
+}
+
+// RolloutStatus is the progress of a rollout on current host.
+type RolloutStatus struct {
+	Policy      *RolloutPolicy `json:"policy"`
+	Host        string         `json:"host"`
+	Group       string         `json:"group,omitempty"`
+	Bucket      int            `json:"bucket"`
+	Wave        int            `json:"wave"`
+	Offset      time.Duration  `json:"offset,omitempty"` // wave interval and jitter since window opened
+	State       string         `json:"state"`
+	Reason      string         `json:"reason,omitempty"`
+	ScheduledAt time.Time      `json:"scheduled_at,omitempty"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+}
+
+func (s *RolloutStatus) finished() bool {
+	switch s.State {
+	case RolloutSkipped, RolloutSucceeded, RolloutFailed, RolloutCancelled:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *RolloutPolicy) setup() error {
+	if p.ID == "" {
+		return fmt.Errorf("rollout ID required")
+	}
+
+	if p.Percent < 0 || p.Percent > 100 {
+		return fmt.Errorf("invalid percent %d", p.Percent)
+	}
+
+	p.loc = time.Local
+	if p.Timezone != "" {
+		loc, err := time.LoadLocation(p.Timezone)
+		if err != nil {
+			return fmt.Errorf("invalid timezone %q: %w", p.Timezone, err)
+		}
+		p.loc = loc
+	}
+
+	p.windows = p.windows[:0]
+	for _, s := range p.Windows {
+		w, err := parseWindow(s)
+		if err != nil {
+			return err
+		}
+		p.windows = append(p.windows, w)
+	}
+
+	var err error
+	if p.jitter, err = parsePolicyDuration(p.Jitter, 0); err != nil {
+		return fmt.Errorf("invalid jitter: %w", err)
+	}
+
+	if p.waveInterval, err = parsePolicyDuration(p.WaveInterval, defaultWaveInterval); err != nil {
+		return fmt.Errorf("invalid wave_interval: %w", err)
+	}
+
+	if mp := p.MaxParallel; mp != nil {
+		if mp.Limit <= 0 {
+			return fmt.Errorf("max_parallel.limit should be positive")
+		}
+
+		// without members or group sizes, all hosts fall into the first wave.
+		if len(mp.Members) == 0 && len(mp.GroupSizes) == 0 {
+			return fmt.Errorf("max_parallel requires members or group_sizes")
+		}
+
+		if mp.GroupBy == "" {
+			mp.GroupBy = groupByNamespace
+		}
+	}
+
+	return nil
+}
+
+func parsePolicyDuration(s string, def time.Duration) (time.Duration, error) {
+	if s == "" {
+		return def, nil
+	}
+
+	du, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, err
+	}
+
+	if du < 0 {
+		return 0, fmt.Errorf("negative duration %q", s)
+	}
+
+	return du, nil
+}
+
+// window is a daily maintenance window in minutes of day. If end <= start,
+// the window cross midnight and the weekday applied on the start day.
+type window struct {
+	days       [7]bool
+	start, end int
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+func parseWindow(s string) (*window, error) {
+	w := &window{}
+
+	fields := strings.Fields(s)
+	switch len(fields) {
+	case 1:
+		for i := range w.days {
+			w.days[i] = true
+		}
+
+	case 2:
+		for _, part := range strings.Split(fields[0], ",") {
+			from, to, isRange := strings.Cut(part, "-")
+			fd, ok := weekdays[strings.ToLower(from)]
+			if !ok {
+				return nil, fmt.Errorf("invalid weekday %q in window %q", from, s)
+			}
+
+			td := fd
+			if isRange {
+				if td, ok = weekdays[strings.ToLower(to)]; !ok {
+					return nil, fmt.Errorf("invalid weekday %q in window %q", to, s)
+				}
+			}
+
+			for d := fd; ; d = (d + 1) % 7 {
+				w.days[d] = true
+				if d == td {
+					break
+				}
+			}
+		}
+
+	default:
+		return nil, fmt.Errorf("invalid window %q", s)
+	}
+
+	from, to, ok := strings.Cut(fields[len(fields)-1], "-")
+	if !ok {
+		return nil, fmt.Errorf("invalid window %q", s)
+	}
+
+	var err error
+	if w.start, err = parseClock(from); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+
+	if w.end, err = parseClock(to); err != nil {
+		return nil, fmt.Errorf("invalid window %q: %w", s, err)
+	}
+
+	return w, nil
+}
+
+// parseClock parse HH:MM into minutes of day.
+func parseClock(s string) (int, error) {
+	h, m, ok := strings.Cut(s, ":")
+	if !ok {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+
+	hour, err := strconv.Atoi(h)
+	if err != nil || hour < 0 || hour > 24 {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+
+	minute, err := strconv.Atoi(m)
+	if err != nil || minute < 0 || minute > 59 || (hour == 24 && minute != 0) {
+		return 0, fmt.Errorf("invalid clock %q", s)
+	}
+
+	return hour*60 + minute, nil
+}
+
+// span get the window instance start at day of t.
+func (w *window) span(t time.Time) (start, end time.Time, ok bool) {
+	if !w.days[t.Weekday()] {
+		return start, end, false
+	}
+
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	start = day.Add(time.Duration(w.start) * time.Minute)
+	end = day.Add(time.Duration(w.end) * time.Minute)
+
+	if w.end <= w.start {
+		end = end.Add(24 * time.Hour)
+	}
+
+	return start, end, true
+}
+
+// windowAt get the window instance containing t, or the earliest one after t.
+func windowAt(t time.Time, windows []*window) (start, end time.Time, ok bool) {
+	// start from yesterday for windows crossing midnight.
+	for d := -1; d <= 7; d++ {
+		day := t.AddDate(0, 0, d)
+
+		for _, w := range windows {
+			from, to, ok := w.span(day)
+			if !ok || !to.After(t) {
+				continue
+			}
+
+			if start.IsZero() || from.Before(start) {
+				start, end = from, to
+			}
+		}
+
+		if !start.IsZero() {
+			return start, end, true
+		}
+	}
+
+	return start, end, false
+}
+
+// nextInWindows get the earliest time not before t within any of the windows.
+func nextInWindows(t time.Time, windows []*window) time.Time {
+	if len(windows) == 0 {
+		return t
+	}
+
+	start, _, ok := windowAt(t, windows)
+	if ok && start.Before(t) { // within the window
+		return t
+	}
+
+	return start
+}
+
+// scheduleIn get the time offset after the window opened(or t if within the
+// window). Offset not fit into the window is carried over into later windows,
+// so waves are still spread when windows are short.
+func scheduleIn(t time.Time, offset time.Duration, windows []*window) time.Time {
+	if len(windows) == 0 {
+		return t.Add(offset)
+	}
+
+	for {
+		start, end, ok := windowAt(t, windows)
+		if !ok {
+			return t.Add(offset)
+		}
+
+		if start.Before(t) {
+			start = t
+		}
+
+		if at := start.Add(offset); at.Before(end) {
+			return at
+		}
+
+		offset -= end.Sub(start)
+		t = end
+	}
+}
+
+func hashN(n int, parts ...string) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join(parts, "/")))
+	return int(h.Sum32() % uint32(n))
+}
+
+// hostInfo get host name and group of current host within the policy.
+type hostInfo func(p *RolloutPolicy) (host, group string, err error)
+
+func datakitHostInfo(u *upgraderImpl) hostInfo {
+	return func(p *RolloutPolicy) (string, string, error) {
+		schema := "http"
+		if u.c.DatakitAPIHTTPS {
+			schema = "https"
+		}
+
+		var (
+			info httpapi.DCAInfo
+			res  = ws.DCAResponse{Content: &info}
+			cli  = &baseDatakitClient{}
+		)
+
+		if err := cli.Request(http.MethodGet, "/info", &res,
+			fmt.Sprintf("%s://%s", schema, u.c.DatakitAPIListen), nil); err != nil || !res.Success || info.DataKit == nil {
+			if p.MaxParallel != nil {
+				return "", "", fmt.Errorf("unable to get Datakit info for group %q: %w", p.MaxParallel.GroupBy, err)
+			}
+
+			// percent and windows still work on OS host name
+			host, herr := os.Hostname()
+			return host, "", herr
+		}
+
+		host := info.DataKit.HostName
+		if p.MaxParallel == nil {
+			return host, "", nil
+		}
+
+		cfg := info.Config
+		if p.MaxParallel.GroupBy == groupByNamespace {
+			if cfg.Election != nil {
+				return host, cfg.Election.Namespace, nil
+			}
+			return host, "", nil
+		}
+
+		if cfg.Election != nil {
+			if v, ok := cfg.Election.Tags[p.MaxParallel.GroupBy]; ok {
+				return host, v, nil
+			}
+		}
+
+		return host, cfg.GlobalHostTags[p.MaxParallel.GroupBy], nil
+	}
+}
+
+// rolloutManager run at most one rollout on current host.
+type rolloutManager struct {
+	mtx    sync.Mutex
+	status *RolloutStatus
+	timer  *time.Timer
+
+	u        *upgraderImpl
+	hostInfo hostInfo
+	now      func() time.Time
+	jitter   func(time.Duration) time.Duration
+}
+
+var rollouts = newRolloutManager(ui)
+
+func newRolloutManager(u *upgraderImpl) *rolloutManager {
+	var (
+		mtx sync.Mutex
+		// seeded per process, or all hosts get the same jitter.
+		rnd = rand.New(rand.NewSource(time.Now().UnixNano())) //nolint:gosec
+	)
+
+	return &rolloutManager{
+		u:        u,
+		hostInfo: datakitHostInfo(u),
+		now:      time.Now,
+		jitter: func(max time.Duration) time.Duration {
+			if max <= 0 {
+				return 0
+			}
+
+			mtx.Lock()
+			defer mtx.Unlock()
+			return time.Duration(rnd.Int63n(int64(max)))
+		},
+	}
+}
+
+// plan evaluate the policy on current host, get the state and when to upgrade.
+func (m *rolloutManager) plan(p *RolloutPolicy) (*RolloutStatus, error) {
+	host, group, err := m.hostInfo(p)
+	if err != nil {
+		return nil, err
+	}
+
+	now := m.now()
+	s := &RolloutStatus{
+		Policy:    p,
+		Host:      host,
+		Group:     group,
+		Bucket:    hashN(100, p.ID, host),
+		State:     RolloutPending,
+		UpdatedAt: now,
+	}
+
+	if p.Percent > 0 && s.Bucket >= p.Percent {
+		s.State = RolloutSkipped
+		s.Reason = fmt.Sprintf("bucket %d not within %d%% canary", s.Bucket, p.Percent)
+		return s, nil
+	}
+
+	if mp := p.MaxParallel; mp != nil {
+		if members, ok := mp.Members[group]; ok {
+			s.Wave = -1
+			for i, x := range members {
+				if x == host {
+					s.Wave = i / mp.Limit
+					break
+				}
+			}
+
+			if s.Wave < 0 {
+				s.State = RolloutSkipped
+				s.Reason = fmt.Sprintf("host not listed in members of group %q", group)
+				return s, nil
+			}
+		} else if size := mp.GroupSizes[group]; size > mp.Limit {
+			s.Wave = hashN((size+mp.Limit-1)/mp.Limit, p.ID, group, host)
+		}
+	}
+
+	s.Offset = time.Duration(s.Wave)*p.waveInterval + m.jitter(p.jitter)
+	s.ScheduledAt = scheduleIn(now.In(p.loc), s.Offset, p.windows)
+
+	return s, nil
+}
+
+// start apply a rollout policy. Posting the policy with same ID update the
+// fleet failures of the running rollout.
+func (m *rolloutManager) start(p *RolloutPolicy) (*RolloutStatus, error) {
+	if err := p.setup(); err != nil {
+		return nil, uhttp.Errorf(httpapi.ErrInvalidRequest, "invalid rollout policy: %s", err)
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	if cur := m.status; cur != nil && cur.Policy.ID == p.ID {
+		cur.Policy.FleetFailures = p.FleetFailures
+		cur.Policy.PauseOnFailure = p.PauseOnFailure
+		m.checkPause(cur)
+		m.update(cur)
+		return cur, nil
+	}
+
+	if m.status != nil && m.status.State == RolloutUpgrading {
+		return nil, httpapi.ErrIsUpgrading
+	}
+
+	s, err := m.plan(p)
+	if err != nil {
+		return nil, uhttp.Errorf(httpapi.ErrUpgradeFailed, "plan rollout failed: %s", err)
+	}
+
+	m.stopTimer()
+	m.status = s
+	m.checkPause(s)
+	m.update(s)
+	m.schedule()
+
+	return s, nil
+}
+
+func (m *rolloutManager) checkPause(s *RolloutStatus) {
+	p := s.Policy
+	if s.State != RolloutPending && s.State != RolloutPaused {
+		return
+	}
+
+	if p.PauseOnFailure > 0 && p.FleetFailures >= p.PauseOnFailure {
+		s.State = RolloutPaused
+		s.Reason = fmt.Sprintf("%d failures within the fleet, pause threshold %d", p.FleetFailures, p.PauseOnFailure)
+	} else if s.State == RolloutPaused {
+		s.State = RolloutPending
+		s.Reason = ""
+		m.schedule()
+	}
+}
+
+func (m *rolloutManager) cancel() *RolloutStatus {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	s := m.status
+	if s == nil || s.finished() || s.State == RolloutUpgrading {
+		return s
+	}
+
+	m.stopTimer()
+	s.State = RolloutCancelled
+	m.update(s)
+
+	return s
+}
+
+func (m *rolloutManager) current() *RolloutStatus {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	return m.status
+}
+
+func (m *rolloutManager) stopTimer() {
+	if m.timer != nil {
+		m.timer.Stop()
+		m.timer = nil
+	}
+}
+
+// schedule fire the upgrade at scheduled time, should be called with lock held.
+func (m *rolloutManager) schedule() {
+	s := m.status
+	if s == nil || s.State != RolloutPending {
+		return
+	}
+
+	m.stopTimer()
+
+	delay := s.ScheduledAt.Sub(m.now())
+	if delay < 0 {
+		delay = 0
+	}
+
+	l.Infof("rollout %s: upgrade to %q scheduled at %s", s.Policy.ID, s.Policy.Version, s.ScheduledAt)
+
+	m.timer = time.AfterFunc(delay, func() { m.fire(s) })
+}
+
+func (m *rolloutManager) fire(s *RolloutStatus) {
+	m.mtx.Lock()
+	if m.status != s || s.State != RolloutPending {
+		m.mtx.Unlock()
+		return
+	}
+
+	// timer may fire late(e.g., system suspended), do not upgrade out of the
+	// windows, and keep the wave and jitter within the next window.
+	now := m.now()
+	if next := nextInWindows(now.In(s.Policy.loc), s.Policy.windows); next.After(now) {
+		s.ScheduledAt = scheduleIn(next, s.Offset, s.Policy.windows)
+		m.update(s)
+		m.schedule()
+		m.mtx.Unlock()
+		return
+	}
+
+	s.State = RolloutUpgrading
+	m.update(s)
+	m.mtx.Unlock()
+
+	err := m.u.upgrade(withVersion(s.Policy.Version), withForce(s.Policy.Force))
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	switch {
+	case err == nil:
+		s.State = RolloutSucceeded
+	case errors.Is(err, httpapi.ErrDKVersionUptoDate):
+		s.State = RolloutSucceeded
+		s.Reason = "up-to-date"
+	default:
+		s.State = RolloutFailed
+		s.Reason = upgradeErrorMessage(err)
+	}
+
+	m.update(s)
+}
+
+// update touch the status, save and report it to DCA. Should be called with lock held.
+func (m *rolloutManager) update(s *RolloutStatus) {
+	s.UpdatedAt = m.now()
+
+	l.Infof("rollout %s on %s: %s %s", s.Policy.ID, s.Host, s.State, s.Reason)
+
+	dcaClient.ReportRolloutProgress(s)
+
+	if f := m.statusPath(); f != "" {
+		if j, err := json.Marshal(s); err != nil {
+			l.Warnf("json.Marshal: %s", err.Error())
+		} else if err := os.WriteFile(f, j, datakit.ConfPerm); err != nil {
+			l.Warnf("save rollout status to %q failed: %s", f, err.Error())
+		}
+	}
+}
+
+func (m *rolloutManager) statusPath() string {
+	if m.u.c == nil || m.u.c.InstallDir == "" {
+		return ""
+	}
+	return filepath.Join(m.u.c.InstallDir, rolloutFile)
+}
+
+// resume load saved rollout, and re-schedule it if still pending. A rollout
+// interrupted during upgrading marked failed.
+func (m *rolloutManager) resume() {
+	f := m.statusPath()
+	if f == "" {
+		return
+	}
+
+	j, err := os.ReadFile(filepath.Clean(f))
+	if err != nil {
+		return
+	}
+
+	var s RolloutStatus
+	if err := json.Unmarshal(j, &s); err != nil || s.Policy == nil {
+		l.Warnf("invalid rollout status in %q, ignored", f)
+		return
+	}
+
+	if err := s.Policy.setup(); err != nil {
+		l.Warnf("invalid rollout policy in %q: %s, ignored", f, err.Error())
+		return
+	}
+
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	m.status = &s
+	if s.State == RolloutUpgrading {
+		s.State = RolloutFailed
+		s.Reason = "upgrade interrupted"
+		m.update(&s)
+		return
+	}
+
+	m.schedule()
+}
+
+func upgradeErrorMessage(err error) string {
+	var me *uhttp.MsgError
+	if errors.As(err, &me) && me.Fmt != "" {
+		return fmt.Sprintf(me.Fmt, me.Args...)
+	}
+	return err.Error()
+}
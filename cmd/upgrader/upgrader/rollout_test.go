@@ -0,0 +1,299 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"fmt"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestParseWindow(t *T.T) {
+	t.Run("daily", func(t *T.T) {
+		w, err := parseWindow("02:00-04:30")
+		require.NoError(t, err)
+		assert.Equal(t, 120, w.start)
+		assert.Equal(t, 270, w.end)
+		for _, on := range w.days {
+			assert.True(t, on)
+		}
+	})
+
+	t.Run("weekdays", func(t *T.T) {
+		w, err := parseWindow("Fri-Mon,Wed 22:00-02:00")
+		require.NoError(t, err)
+		assert.Equal(t, [7]bool{true, true, false, true, false, true, true}, w.days)
+	})
+
+	for _, s := range []string{"", "02:00", "Mon 02:00-25:00", "Xyz 02:00-04:00", "a b c", "02:60-03:00"} {
+		t.Run(fmt.Sprintf("invalid-%q", s), func(t *T.T) {
+			_, err := parseWindow(s)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestNextInWindows(t *T.T) {
+	mustWindows := func(ss ...string) (arr []*window) {
+		for _, s := range ss {
+			w, err := parseWindow(s)
+			require.NoError(t, err)
+			arr = append(arr, w)
+		}
+		return
+	}
+
+	// 2024-01-03 is Wednesday
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2024, 1, day, hour, minute, 0, 0, time.UTC)
+	}
+
+	cases := []struct {
+		name    string
+		windows []string
+		t       time.Time
+		expect  time.Time
+	}{
+		{"no-window", nil, at(3, 10, 0), at(3, 10, 0)},
+		{"within", []string{"09:00-11:00"}, at(3, 10, 0), at(3, 10, 0)},
+		{"later-today", []string{"12:00-13:00"}, at(3, 10, 0), at(3, 12, 0)},
+		{"tomorrow", []string{"02:00-04:00"}, at(3, 10, 0), at(4, 2, 0)},
+		{"window-end-excluded", []string{"09:00-10:00"}, at(3, 10, 0), at(4, 9, 0)},
+		{"cross-midnight-after", []string{"22:00-02:00"}, at(3, 1, 0), at(3, 1, 0)},
+		{"cross-midnight-before", []string{"22:00-02:00"}, at(3, 21, 0), at(3, 22, 0)},
+		{"weekend", []string{"Sat,Sun 00:00-06:00"}, at(3, 10, 0), at(6, 0, 0)},
+		{"friday-night-to-saturday", []string{"Fri 22:00-02:00"}, at(6, 1, 0), at(6, 1, 0)},
+		{"earliest-of-windows", []string{"20:00-21:00", "Wed 15:00-16:00"}, at(3, 10, 0), at(3, 15, 0)},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *T.T) {
+			assert.Equal(t, tc.expect, nextInWindows(tc.t, mustWindows(tc.windows...)))
+		})
+	}
+}
+
+func newTestRolloutManager(host, group string, now time.Time) *rolloutManager {
+	u := &upgraderImpl{upgradeStatus: atomic.NewInt32(0), c: &MainConfig{}}
+	m := newRolloutManager(u)
+	m.hostInfo = func(*RolloutPolicy) (string, string, error) { return host, group, nil }
+	m.now = func() time.Time { return now }
+	m.jitter = func(max time.Duration) time.Duration { return max / 2 }
+	return m
+}
+
+func TestScheduleIn(t *T.T) {
+	w, err := parseWindow("Mon-Fri 22:00-24:00")
+	require.NoError(t, err)
+	windows := []*window{w}
+	mon := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	// within the window: offset from now
+	assert.Equal(t, mon.Add(23*time.Hour), scheduleIn(mon.Add(22*time.Hour+30*time.Minute), 30*time.Minute, windows))
+
+	// before the window: offset from window opened
+	assert.Equal(t, mon.Add(22*time.Hour+30*time.Minute), scheduleIn(mon.Add(10*time.Hour), 30*time.Minute, windows))
+
+	// carried over into the next window
+	assert.Equal(t, mon.Add(24*time.Hour+22*time.Hour+30*time.Minute), scheduleIn(mon.Add(10*time.Hour), 150*time.Minute, windows))
+
+	// Friday window full: carried over to next Monday
+	fri := mon.AddDate(0, 0, 4)
+	assert.Equal(t, mon.AddDate(0, 0, 7).Add(22*time.Hour+time.Minute), scheduleIn(fri.Add(23*time.Hour), 61*time.Minute, windows))
+
+	// no window
+	assert.Equal(t, mon.Add(time.Hour), scheduleIn(mon, time.Hour, nil))
+}
+
+func TestRolloutPlan(t *T.T) {
+	now := time.Date(2024, 1, 3, 10, 0, 0, 0, time.UTC)
+
+	t.Run("canary", func(t *T.T) {
+		selected := 0
+		for i := 0; i < 1000; i++ {
+			m := newTestRolloutManager(fmt.Sprintf("host-%d", i), "", now)
+			p := &RolloutPolicy{ID: "r1", Version: "1.2.3", Percent: 5}
+			require.NoError(t, p.setup())
+
+			s, err := m.plan(p)
+			require.NoError(t, err)
+			if s.State == RolloutPending {
+				selected++
+			} else {
+				assert.Equal(t, RolloutSkipped, s.State)
+			}
+		}
+
+		assert.InDelta(t, 50, selected, 25)
+	})
+
+	t.Run("waves-by-members", func(t *T.T) {
+		members := []string{"h0", "h1", "h2", "h3", "h4"}
+
+		for i, h := range members {
+			m := newTestRolloutManager(h, "ns-a", now)
+			p := &RolloutPolicy{
+				ID:           "r1",
+				Version:      "1.2.3",
+				Jitter:       "2m",
+				WaveInterval: "30m",
+				Timezone:     "UTC",
+				MaxParallel: &MaxParallel{
+					Limit:   2,
+					Members: map[string][]string{"ns-a": members},
+				},
+			}
+			require.NoError(t, p.setup())
+
+			s, err := m.plan(p)
+			require.NoError(t, err)
+			assert.Equal(t, RolloutPending, s.State)
+			assert.Equal(t, i/2, s.Wave)
+			assert.Equal(t, now.Add(time.Duration(i/2)*30*time.Minute+time.Minute), s.ScheduledAt)
+		}
+
+		m := newTestRolloutManager("not-listed", "ns-a", now)
+		p := &RolloutPolicy{ID: "r1", MaxParallel: &MaxParallel{Limit: 2, Members: map[string][]string{"ns-a": members}}}
+		require.NoError(t, p.setup())
+		s, err := m.plan(p)
+		require.NoError(t, err)
+		assert.Equal(t, RolloutSkipped, s.State)
+	})
+
+	t.Run("waves-by-group-size", func(t *T.T) {
+		waves := map[int]int{}
+		for i := 0; i < 100; i++ {
+			m := newTestRolloutManager(fmt.Sprintf("host-%d", i), "ns-a", now)
+			p := &RolloutPolicy{ID: "r1", MaxParallel: &MaxParallel{Limit: 10, GroupSizes: map[string]int{"ns-a": 100}}}
+			require.NoError(t, p.setup())
+
+			s, err := m.plan(p)
+			require.NoError(t, err)
+			waves[s.Wave]++
+		}
+
+		assert.Len(t, waves, 10)
+	})
+
+	t.Run("in-window", func(t *T.T) {
+		m := newTestRolloutManager("h0", "", now)
+		p := &RolloutPolicy{ID: "r1", Windows: []string{"02:00-04:00"}, Timezone: "UTC"}
+		require.NoError(t, p.setup())
+
+		s, err := m.plan(p)
+		require.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 4, 2, 0, 0, 0, time.UTC), s.ScheduledAt.UTC())
+	})
+
+	t.Run("window-waves-jitter", func(t *T.T) {
+		members := []string{"h0", "h1", "h2", "h3", "h4", "h5", "h6", "h7"}
+		opened := time.Date(2024, 1, 4, 2, 0, 0, 0, time.UTC)
+
+		expect := []time.Time{
+			opened.Add(5 * time.Minute),              // wave 0
+			opened.Add(time.Hour + 5*time.Minute),    // wave 1
+			opened.Add(24*time.Hour + 5*time.Minute), // wave 2 carried into next window
+			opened.Add(25*time.Hour + 5*time.Minute), // wave 3
+		}
+
+		for i, h := range members {
+			m := newTestRolloutManager(h, "ns-a", now)
+			p := &RolloutPolicy{
+				ID:           "r1",
+				Version:      "1.2.3",
+				Jitter:       "10m",
+				WaveInterval: "1h",
+				Windows:      []string{"02:00-04:00"},
+				Timezone:     "UTC",
+				MaxParallel: &MaxParallel{
+					Limit:   2,
+					Members: map[string][]string{"ns-a": members},
+				},
+			}
+			require.NoError(t, p.setup())
+
+			s, err := m.plan(p)
+			require.NoError(t, err)
+			assert.Equal(t, expect[i/2], s.ScheduledAt.UTC(), "host %s", h)
+		}
+	})
+
+	t.Run("jitter-spread-in-window", func(t *T.T) {
+		at := map[time.Time]bool{}
+		for i := 0; i < 10; i++ {
+			m := newTestRolloutManager("h0", "", now)
+			x := time.Duration(i) * time.Minute
+			m.jitter = func(time.Duration) time.Duration { return x }
+
+			p := &RolloutPolicy{ID: "r1", Jitter: "10m", Windows: []string{"02:00-04:00"}, Timezone: "UTC"}
+			require.NoError(t, p.setup())
+
+			s, err := m.plan(p)
+			require.NoError(t, err)
+			at[s.ScheduledAt] = true
+		}
+
+		assert.Len(t, at, 10)
+	})
+
+	t.Run("invalid-policy", func(t *T.T) {
+		for _, p := range []*RolloutPolicy{
+			{},
+			{ID: "r1", Percent: 101},
+			{ID: "r1", Jitter: "abc"},
+			{ID: "r1", Timezone: "No/Where"},
+			{ID: "r1", Windows: []string{"bad"}},
+			{ID: "r1", MaxParallel: &MaxParallel{}},
+			{ID: "r1", MaxParallel: &MaxParallel{Limit: 2}}, // unbounded
+		} {
+			assert.Error(t, p.setup())
+		}
+	})
+}
+
+func TestRolloutStart(t *T.T) {
+	now := time.Now()
+
+	t.Run("pause-on-failure", func(t *T.T) {
+		m := newTestRolloutManager("h0", "", now)
+
+		s, err := m.start(&RolloutPolicy{ID: "r1", Version: "1.2.3", Jitter: "1h", PauseOnFailure: 2})
+		require.NoError(t, err)
+		assert.Equal(t, RolloutPending, s.State)
+
+		s, err = m.start(&RolloutPolicy{ID: "r1", Version: "1.2.3", Jitter: "1h", PauseOnFailure: 2, FleetFailures: 2})
+		require.NoError(t, err)
+		assert.Equal(t, RolloutPaused, s.State)
+
+		s, err = m.start(&RolloutPolicy{ID: "r1", Version: "1.2.3", Jitter: "1h", PauseOnFailure: 3, FleetFailures: 2})
+		require.NoError(t, err)
+		assert.Equal(t, RolloutPending, s.State)
+
+		s = m.cancel()
+		assert.Equal(t, RolloutCancelled, s.State)
+	})
+
+	t.Run("fire", func(t *T.T) {
+		m := newTestRolloutManager("h0", "", now)
+		m.u.upgradeStatus.Store(statusUpgrading) // make the upgrade fail
+
+		_, err := m.start(&RolloutPolicy{ID: "r2", Version: "1.2.3"})
+		require.NoError(t, err)
+
+		assert.Eventually(t, func() bool {
+			s := m.current()
+			m.mtx.Lock()
+			defer m.mtx.Unlock()
+			return s.State == RolloutFailed
+		}, 5*time.Second, 10*time.Millisecond)
+
+		assert.Contains(t, m.current().Reason, "upgrade is on going")
+	})
+}
@@ -84,6 +84,7 @@ func (u *upgraderImpl) upgrade(opts ...upgradeOpt) error {
 		baseURL     = cmds.OnlineBaseURL
 		upToDate    = false
 		downloadURL = ""
+		toVersion   = uo.version
 	)
 
 	if u.c.InstallerBaseURL != "" {
@@ -109,6 +110,8 @@ func (u *upgraderImpl) upgrade(opts ...upgradeOpt) error {
 			return uhttp.Errorf(httpapi.ErrUpgradeFailed, "unable to get online version: %s", err)
 		}
 
+		toVersion = onlineVer.VersionString
+
 		if uo.force {
 			downloadURL = onlineVer.DownloadURL
 		} else {
@@ -144,14 +147,74 @@ func (u *upgraderImpl) upgrade(opts ...upgradeOpt) error {
 		}
 	}
 
-	scriptFile, err := u.saveUpgradeScript(downloadURL, uo.version)
+	return u.apply(downloadURL, uo.version, dkv.Content.Version, toVersion)
+}
+
+// installCommand prepare the command to upgrade Datakit. If verification
+// enabled, the installer and packages are downloaded and verified, and
+// Datakit installed from them directly. Otherwise the upgrade script used.
+// The returned cleanup should be called after upgrading.
+func (u *upgraderImpl) installCommand(downloadURL, scriptVersion, to string) (string, []string, func(), error) {
+	if u.verifyEnabled() {
+		pkg, err := u.downloadVerified(downloadURL, to)
+		if err != nil {
+			return "", nil, nil, err
+		}
+
+		bin, args := pkg.installCommand(u.c)
+		return bin, args, pkg.cleanup, nil
+	}
+
+	scriptFile, err := u.saveUpgradeScript(downloadURL, scriptVersion)
+	if err != nil {
+		return "", nil, nil, err
+	}
+
+	bin, args, err := scriptCommand(scriptFile)
+	return bin, args, func() {}, err
+}
+
+// apply download(and verify) the upgrade files and run the upgrade. If
+// rollback enabled, current Datakit kept before upgrading and restored if the
+// upgraded one not healthy.
+func (u *upgraderImpl) apply(downloadURL, scriptVersion, from, to string) error {
+	rec := &UpgradeRecord{From: from, To: to, Start: time.Now(), Status: UpgradeFailed}
+	defer u.addHistory(rec)
+
+	bin, args, cleanup, err := u.installCommand(downloadURL, scriptVersion, to)
 	if err != nil {
-		l.Errorf("unable to download upgrade script: %s", err.Error())
+		l.Errorf("installCommand: %s", err.Error())
+		rec.Error = err.Error()
 		return err
 	}
+	defer cleanup()
 
-	if err := u.doUpgrade(scriptFile); err != nil {
+	inputs := 0
+	if u.rollbackEnabled() {
+		if from == "" { // force upgrade do not fetched current version
+			if dkv, err := u.fetchCurrentDKVersion(); err == nil {
+				from = dkv.Content.Version
+				rec.From = from
+			}
+		}
+
+		if n, err := u.enabledInputs(); err != nil {
+			l.Warnf("get enabled inputs failed: %s, ignored", err.Error())
+		} else {
+			inputs = n
+		}
+
+		if err := u.backupDatakit(from); err != nil {
+			l.Errorf("backupDatakit: %s", err.Error())
+			rec.Error = err.Error()
+			return uhttp.Errorf(httpapi.ErrUpgradeFailed, "backup current Datakit failed: %s", err)
+		}
+	}
+
+	if err := u.doUpgrade(bin, args); err != nil {
 		l.Errorf("doUpgrade: %s", err.Error())
+		rec.Error = err.Error()
+		u.tryRollback(rec)
 		return uhttp.Errorf(httpapi.ErrUpgradeFailed, "doUpgrade: %s", err)
 	}
 
@@ -159,9 +222,34 @@ func (u *upgraderImpl) upgrade(opts ...upgradeOpt) error {
 	// we tried here to start it.
 	u.tryStartService()
 
+	if u.rollbackEnabled() {
+		if err := u.waitHealthy(to, inputs); err != nil {
+			l.Errorf("waitHealthy: %s", err.Error())
+			rec.Error = err.Error()
+			u.tryRollback(rec)
+			return uhttp.Errorf(httpapi.ErrUpgradeFailed, "upgraded Datakit not healthy: %s", err)
+		}
+	}
+
+	rec.Status = UpgradeSucceeded
 	return nil
 }
 
+func (u *upgraderImpl) tryRollback(rec *UpgradeRecord) {
+	if !u.rollbackEnabled() {
+		return
+	}
+
+	if ver, err := u.rollback(); err != nil {
+		l.Errorf("rollback: %s", err.Error())
+		rec.Status = UpgradeRollbackFailed
+		rec.Error += "; rollback: " + err.Error()
+	} else {
+		rec.Status = UpgradeRolledBack
+		rec.To = ver
+	}
+}
+
 func (u *upgraderImpl) saveUpgradeScript(downloadURL, version string) (string, error) {
 	downloadURL = strings.TrimRight(downloadURL, "/ ")
 	scriptExt := ".sh"
@@ -268,18 +356,8 @@ func (u *upgraderImpl) restartService() error {
 	return nil
 }
 
-func (u *upgraderImpl) doUpgrade(scriptFile string) error {
-	// Force stop current running datakit service.
-	// dk-install may failed to stop(why?) the datakit service, and during
-	// download new version datakit binary, we'll get `text file busy' error.
-	//
-	// I don't know why backed-started upgrade procedure failed to operate on datakit service,
-	// such as get current service status/stop service/start service. So it's better to start/stop
-	// datakit service within dk_upgrader, not within the installer.
-	if err := u.forceStopService(); err != nil {
-		return err
-	}
-
+// scriptCommand returns the shell command to run the upgrade script.
+func scriptCommand(scriptFile string) (string, []string, error) {
 	shell := "bash"
 	args := []string{scriptFile}
 	if runtime.GOOS == datakit.OSWindows {
@@ -294,13 +372,28 @@ func (u *upgraderImpl) doUpgrade(scriptFile string) error {
 
 	shellBin, err := exec.LookPath(shell)
 	if err != nil {
-		return fmt.Errorf("%s command not found: %w", shell, err)
+		return "", nil, fmt.Errorf("%s command not found: %w", shell, err)
+	}
+
+	return shellBin, args, nil
+}
+
+func (u *upgraderImpl) doUpgrade(bin string, args []string) error {
+	// Force stop current running datakit service.
+	// dk-install may failed to stop(why?) the datakit service, and during
+	// download new version datakit binary, we'll get `text file busy' error.
+	//
+	// I don't know why backed-started upgrade procedure failed to operate on datakit service,
+	// such as get current service status/stop service/start service. So it's better to start/stop
+	// datakit service within dk_upgrader, not within the installer.
+	if err := u.forceStopService(); err != nil {
+		return err
 	}
 
 	stderr := &bytes.Buffer{}
 	stdout := &bytes.Buffer{}
 
-	cmd := exec.Command(shellBin, args...) // nolint:gosec
+	cmd := exec.Command(bin, args...) // nolint:gosec
 	cmd.Stderr = stderr
 	cmd.Stdout = stdout
 
@@ -325,7 +418,7 @@ func (u *upgraderImpl) doUpgrade(scriptFile string) error {
 		return fmt.Errorf("unable to execute upgrade cmd[%s]: %w", cmd.String(), err)
 	}
 
-	err = cmd.Wait()
+	err := cmd.Wait()
 	if x := stdout.String(); x != "" {
 		l.Infof("upgrade process stdout:\n%s\n", x)
 	}
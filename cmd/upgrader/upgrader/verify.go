@@ -0,0 +1,326 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	uhttp "github.com/GuanceCloud/cliutils/network/http"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/cmds"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/httpapi"
+)
+
+const defaultManifestFile = "sha256sums.txt"
+
+var (
+	errSignatureMismatch = errors.New("manifest signature mismatch")
+	errChecksumMismatch  = errors.New("checksum mismatch")
+)
+
+// VerifyConfig configure how to verify upgrade packages before applying them.
+//
+// The manifest is a sha256sum(1) style file(<hex-sha256>  <file-name> per line)
+// published along with install packages, and <manifest>.sig is the detached
+// signature of the manifest signed by the private key of PublicKey.
+type VerifyConfig struct {
+	Enable    bool   `toml:"enable"`
+	PublicKey string `toml:"public_key"` // PEM encoded public key file, ed25519/ECDSA/RSA accepted
+	Manifest  string `toml:"manifest"`   // manifest file name under download URL, default sha256sums.txt
+}
+
+func (vc *VerifyConfig) manifestFile() string {
+	if vc.Manifest == "" {
+		return defaultManifestFile
+	}
+	return vc.Manifest
+}
+
+// manifest is file name -> hex SHA-256 sum.
+type manifest map[string]string
+
+func parseManifest(data []byte) (manifest, error) {
+	m := manifest{}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("invalid manifest line %q", line)
+		}
+
+		sum := strings.ToLower(fields[0])
+		if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+			return nil, fmt.Errorf("invalid SHA-256 sum in manifest line %q", line)
+		}
+
+		// sha256sum(1) prefix '*' for binary mode.
+		m[strings.TrimPrefix(fields[1], "*")] = sum
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// match check if sum is the SHA-256 of name in manifest.
+func (m manifest) match(name string, sum []byte) error {
+	expect, ok := m[name]
+	if !ok {
+		return fmt.Errorf("%s not found in manifest", name)
+	}
+
+	if got := hex.EncodeToString(sum); got != expect {
+		return fmt.Errorf("%w on %s: expect %s, got %s", errChecksumMismatch, name, expect, got)
+	}
+
+	return nil
+}
+
+func loadPublicKey(f string) (crypto.PublicKey, error) {
+	data, err := os.ReadFile(filepath.Clean(f))
+	if err != nil {
+		return nil, fmt.Errorf("read public key %q: %w", f, err)
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found in %q", f)
+	}
+
+	pub, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse public key %q: %w", f, err)
+	}
+
+	return pub, nil
+}
+
+// verifySignature check the detached signature sig on data. The signature
+// may be raw bytes or base64 encoded.
+func verifySignature(pub crypto.PublicKey, data, sig []byte) error {
+	if raw, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = raw
+	}
+
+	digest := sha256.Sum256(data)
+
+	switch k := pub.(type) {
+	case ed25519.PublicKey:
+		if ed25519.Verify(k, data, sig) {
+			return nil
+		}
+	case *ecdsa.PublicKey:
+		if ecdsa.VerifyASN1(k, digest[:], sig) {
+			return nil
+		}
+	case *rsa.PublicKey:
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", pub)
+	}
+
+	return errSignatureMismatch
+}
+
+func fetch(u, proxy string) (io.ReadCloser, error) {
+	resp, err := cmds.GetHTTPClient(proxy).Get(u) //nolint:noctx
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode/100 != 2 {
+		resp.Body.Close() //nolint:errcheck,gosec
+		return nil, fmt.Errorf("download %s: response status: %s", u, resp.Status)
+	}
+
+	return resp.Body, nil
+}
+
+func download(u, proxy string) ([]byte, error) {
+	body, err := fetch(u, proxy)
+	if err != nil {
+		return nil, err
+	}
+	defer body.Close() //nolint:errcheck
+
+	return io.ReadAll(body)
+}
+
+// fetchManifest download manifest and it's signature under downloadURL, and
+// verify the signature against configured public key.
+func (u *upgraderImpl) fetchManifest(downloadURL string) (manifest, error) {
+	vc := u.c.Verify
+
+	pub, err := loadPublicKey(vc.PublicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimRight(downloadURL, "/ ")
+	manifestURL := base + "/" + vc.manifestFile()
+
+	l.Infof("download upgrade manifest from %s", manifestURL)
+
+	data, err := download(manifestURL, u.c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := download(manifestURL+".sig", u.c.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verifySignature(pub, data, sig); err != nil {
+		return nil, err
+	}
+
+	return parseManifest(data)
+}
+
+// verifiedPackage is the upgrade files downloaded and verified against the
+// signed manifest. Datakit is installed from these files, they are not
+// downloaded again by the installer.
+type verifiedPackage struct {
+	dir       string
+	installer string
+	srcs      []string // Datakit package and data package
+}
+
+func (p *verifiedPackage) cleanup() {
+	if err := os.RemoveAll(p.dir); err != nil {
+		l.Warnf("remove %s failed: %s, ignored", p.dir, err.Error())
+	}
+}
+
+// installCommand returns the command to install Datakit from the verified files.
+func (p *verifiedPackage) installCommand(c *MainConfig) (string, []string) {
+	args := []string{"--upgrade", "--offline", "--srcs=" + strings.Join(p.srcs, ",")}
+
+	if c.Proxy != "" {
+		args = append(args, "--proxy="+c.Proxy)
+	}
+
+	if c.InstallerBaseURL != "" {
+		args = append(args, "--installer_base_url="+
+			strings.TrimRight(cmds.CanonicalInstallBaseURL(c.InstallerBaseURL), "/"))
+	}
+
+	return p.installer, args
+}
+
+func (u *upgraderImpl) verifyEnabled() bool {
+	return u.c.Verify != nil && u.c.Verify.Enable
+}
+
+func installerName(version string) string {
+	if runtime.GOOS == datakit.OSWindows {
+		return fmt.Sprintf("installer-%s-%s-%s.exe", runtime.GOOS, runtime.GOARCH, version)
+	}
+	return fmt.Sprintf("installer-%s-%s-%s", runtime.GOOS, runtime.GOARCH, version)
+}
+
+// saveVerified download name under baseURL into dir, the SHA-256 is
+// calculated during saving and checked against manifest.
+func (u *upgraderImpl) saveVerified(m manifest, baseURL, dir, name string) (string, error) {
+	if _, ok := m[name]; !ok {
+		return "", fmt.Errorf("%s not found in manifest", name)
+	}
+
+	body, err := fetch(baseURL+"/"+name, u.c.Proxy)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close() //nolint:errcheck
+
+	f := filepath.Join(dir, name)
+	fd, err := os.OpenFile(filepath.Clean(f), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o700) //nolint:gosec
+	if err != nil {
+		return "", err
+	}
+	defer fd.Close() //nolint:errcheck,gosec
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(fd, h), body); err != nil {
+		return "", fmt.Errorf("download %s: %w", name, err)
+	}
+
+	if err := m.match(name, h.Sum(nil)); err != nil {
+		return "", err
+	}
+
+	return f, nil
+}
+
+// downloadVerified download the installer, Datakit package and data package of
+// version on current platform, and verify them against the signed manifest.
+func (u *upgraderImpl) downloadVerified(downloadURL, version string) (*verifiedPackage, error) {
+	if version == "" {
+		return nil, uhttp.Errorf(httpapi.ErrUpgradeFailed, "upgrade version unknown, unable to verify Datakit package")
+	}
+
+	m, err := u.fetchManifest(downloadURL)
+	if err != nil {
+		return nil, uhttp.Errorf(httpapi.ErrUpgradeFailed, "verify upgrade manifest failed: %s", err)
+	}
+
+	dir, err := os.MkdirTemp(u.c.InstallDir, "tmp-dk-upgrade-*")
+	if err != nil {
+		return nil, uhttp.Errorf(httpapi.ErrUpgradeFailed, "create download dir failed: %s", err)
+	}
+
+	p := &verifiedPackage{dir: dir}
+	baseURL := strings.TrimRight(downloadURL, "/ ")
+
+	for _, name := range []string{
+		installerName(version),
+		fmt.Sprintf("datakit-%s-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH, version),
+		"data.tar.gz",
+	} {
+		f, err := u.saveVerified(m, baseURL, dir, name)
+		if err != nil {
+			p.cleanup()
+			return nil, uhttp.Errorf(httpapi.ErrUpgradeFailed, "verify %s failed: %s", name, err)
+		}
+
+		if p.installer == "" {
+			p.installer = f
+		} else {
+			p.srcs = append(p.srcs, f)
+		}
+	}
+
+	l.Infof("upgrade files of %s verified", version)
+	return p, nil
+}
@@ -0,0 +1,200 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package upgrader
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	T "testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func sha256Hex(data []byte) string {
+	x := sha256.Sum256(data)
+	return hex.EncodeToString(x[:])
+}
+
+func TestDownloadVerified(t *T.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	pubFile := filepath.Join(dir, "upgrade.pub")
+	require.NoError(t, os.WriteFile(pubFile, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600))
+
+	var (
+		version = "1.2.3"
+		files   = map[string][]byte{
+			installerName(version): []byte("fake installer"),
+			fmt.Sprintf("datakit-%s-%s-%s.tar.gz", runtime.GOOS, runtime.GOARCH, version): []byte("fake datakit package"),
+			"data.tar.gz": []byte("fake data package"),
+		}
+	)
+
+	newServer := func(manifest, sig []byte) *httptest.Server {
+		router := gin.New()
+		router.GET("/datakit/:file", func(c *gin.Context) {
+			switch f := c.Param("file"); f {
+			case "sha256sums.txt":
+				c.Data(200, "", manifest)
+			case "sha256sums.txt.sig":
+				c.Data(200, "", sig)
+			default:
+				if data, ok := files[f]; ok {
+					c.Data(200, "", data)
+				} else {
+					c.Status(404)
+				}
+			}
+		})
+
+		return httptest.NewServer(router)
+	}
+
+	newUpgrader := func() *upgraderImpl {
+		return &upgraderImpl{
+			upgradeStatus: atomic.NewInt32(0),
+			c: &MainConfig{
+				InstallDir: dir,
+				Verify:     &VerifyConfig{Enable: true, PublicKey: pubFile},
+			},
+		}
+	}
+
+	buildManifest := func(sums map[string][]byte) []byte {
+		var m string
+		for name, data := range sums {
+			m += fmt.Sprintf("%s *%s\n", sha256Hex(data), name)
+		}
+		return []byte(m)
+	}
+
+	manifest := buildManifest(files)
+
+	// no temporary files left on failure
+	assertCleaned := func(t *T.T) {
+		t.Helper()
+		matches, err := filepath.Glob(filepath.Join(dir, "tmp-dk-upgrade-*"))
+		require.NoError(t, err)
+		assert.Empty(t, matches)
+	}
+
+	t.Run("ok", func(t *T.T) {
+		ts := newServer(manifest, []byte(base64.StdEncoding.EncodeToString(ed25519.Sign(priv, manifest))))
+		defer ts.Close()
+
+		pkg, err := newUpgrader().downloadVerified(ts.URL+"/datakit", version)
+		require.NoError(t, err)
+
+		// the installed files are exactly the verified ones
+		data, err := os.ReadFile(pkg.installer)
+		require.NoError(t, err)
+		assert.Equal(t, files[installerName(version)], data)
+		require.Len(t, pkg.srcs, 2)
+		for _, f := range pkg.srcs {
+			data, err := os.ReadFile(f)
+			require.NoError(t, err)
+			assert.Equal(t, files[filepath.Base(f)], data)
+		}
+
+		bin, args := pkg.installCommand(&MainConfig{Proxy: "http://1.2.3.4:9530"})
+		assert.Equal(t, pkg.installer, bin)
+		assert.Equal(t, []string{
+			"--upgrade",
+			"--offline",
+			"--srcs=" + pkg.srcs[0] + "," + pkg.srcs[1],
+			"--proxy=http://1.2.3.4:9530",
+		}, args)
+
+		pkg.cleanup()
+		assertCleaned(t)
+	})
+
+	t.Run("raw-signature", func(t *T.T) {
+		ts := newServer(manifest, ed25519.Sign(priv, manifest))
+		defer ts.Close()
+
+		pkg, err := newUpgrader().downloadVerified(ts.URL+"/datakit", version)
+		require.NoError(t, err)
+		pkg.cleanup()
+	})
+
+	t.Run("bad-signature", func(t *T.T) {
+		_, other, err := ed25519.GenerateKey(rand.Reader)
+		require.NoError(t, err)
+
+		ts := newServer(manifest, ed25519.Sign(other, manifest))
+		defer ts.Close()
+
+		_, err = newUpgrader().downloadVerified(ts.URL+"/datakit", version)
+		assert.Error(t, err)
+		assert.Contains(t, upgradeErrorMessage(err), "signature mismatch")
+		assertCleaned(t)
+	})
+
+	t.Run("bad-checksum", func(t *T.T) {
+		sums := map[string][]byte{}
+		for k, v := range files {
+			sums[k] = v
+		}
+		sums["data.tar.gz"] = []byte("other")
+
+		m := buildManifest(sums)
+		ts := newServer(m, ed25519.Sign(priv, m))
+		defer ts.Close()
+
+		_, err := newUpgrader().downloadVerified(ts.URL+"/datakit", version)
+		assert.Error(t, err)
+		assert.Contains(t, upgradeErrorMessage(err), "checksum mismatch")
+		assertCleaned(t)
+	})
+
+	t.Run("not-in-manifest", func(t *T.T) {
+		ts := newServer(manifest, ed25519.Sign(priv, manifest))
+		defer ts.Close()
+
+		_, err := newUpgrader().downloadVerified(ts.URL+"/datakit", "4.5.6")
+		assert.Error(t, err)
+		assert.Contains(t, upgradeErrorMessage(err), "not found in manifest")
+		assertCleaned(t)
+	})
+
+	t.Run("version-unknown", func(t *T.T) {
+		_, err := newUpgrader().downloadVerified("http://invalid.url", "")
+		assert.Error(t, err)
+	})
+}
+
+func TestParseManifest(t *T.T) {
+	t.Run("invalid-sum", func(t *T.T) {
+		_, err := parseManifest([]byte("abc  install.sh"))
+		assert.Error(t, err)
+	})
+
+	t.Run("comments-and-blank", func(t *T.T) {
+		m, err := parseManifest([]byte("# sums\n\n" + sha256Hex([]byte("x")) + "  a.tar.gz\n"))
+		assert.NoError(t, err)
+		assert.Len(t, m, 1)
+		assert.Equal(t, sha256Hex([]byte("x")), m["a.tar.gz"])
+	})
+}
@@ -88,11 +88,14 @@ const (
 	GetDatakitConfigAction         = "get_datakit_config_action"
 	ReloadDatakitAction            = "reload_datakit_action"
 	UpgradeDatakitAction           = "upgrade_datakit_action"
+	RolloutDatakitAction           = "rollout_datakit_action"
 	StopDatakitAction              = "stop_datakit_action"
 	RestartDatakitAction           = "restart_datakit_action"
 	UpdateDatakitStatus            = "update_datakit_status"
 	UpdateDatakit                  = "update_datakit"
 	DeleteDatakit                  = "delete_datakit"
+	UpgradeHistory                 = "upgrade_history"
+	RolloutProgress                = "rollout_progress"
 	SaveDatakitConfigAction        = "save_datakit_config_action"
 	DeleteDatakitConfigAction      = "delete_datakit_config_action"
 	GetDatakitPipelineAction       = "get_datakit_pipeline_action"
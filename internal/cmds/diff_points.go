@@ -0,0 +1,42 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package cmds
+
+import (
+	"fmt"
+
+	cp "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/colorprint"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/recorder"
+)
+
+// diffPoints compares recorded points of a and args[0] by measurement.
+func diffPoints(a string, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: datakit tool --diff-points <a> <b>")
+	}
+
+	ptsA, err := recorder.LoadPoints(a)
+	if err != nil {
+		return err
+	}
+
+	ptsB, err := recorder.LoadPoints(args[0])
+	if err != nil {
+		return err
+	}
+
+	diff := recorder.DiffPoints(ptsA, ptsB,
+		recorder.WithIgnoreKeys(*flagToolDiffIgnoreKeys...),
+		recorder.WithCompareValues(*flagToolDiffValues))
+
+	if len(diff) == 0 {
+		cp.Infof("no difference(%d points in a, %d points in b)\n", len(ptsA), len(ptsB))
+		return nil
+	}
+
+	cp.Output("%s", diff)
+	return fmt.Errorf("%d measurements differ", len(diff))
+}
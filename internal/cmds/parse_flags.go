@@ -91,10 +91,25 @@ var (
 	flagPLDate     = fsPL.Bool("date", false, "append date display(according to local timezone) on timestamp")
 	fsPLUsage      = func() {
 		fmt.Printf("usage: datakit pipeline -P [pipeline-script-name.p] -T [text] [other-options...]\n\n")
+		fmt.Printf("usage: datakit pipeline test [options] [pipeline-dir...]\n\n")
 		fmt.Printf("Pipeline used to debug exists pipeline script.\n\n")
 		fmt.Println(fsPL.FlagUsagesWrapped(0))
 	}
 
+	//
+	// pipeline test related flags.
+	//
+	fsPLTestName      = "test"
+	fsPLTest          = pflag.NewFlagSet(fsPLName+" "+fsPLTestName, pflag.ContinueOnError)
+	flagPLTestLogPath = fsPLTest.String("log", commonLogFlag(), "log path")
+	flagPLTestVerbose = fsPLTest.BoolP("verbose", "V", false, "show passed cases")
+	fsPLTestUsage     = func() {
+		fmt.Printf("usage: datakit pipeline test [options] [pipeline-dir...]\n\n")
+		fmt.Printf("Test used to run test cases(xxx_test.toml) of pipeline scripts(xxx.p) under pipeline directories,\n")
+		fmt.Printf("default to pipeline directories of DataKit and all git repos. Available options:\n\n")
+		fmt.Println(fsPLTest.FlagUsagesWrapped(0))
+	}
+
 	//
 	// version related flags.
 	//
@@ -235,6 +250,10 @@ var (
 	flagToolJSON              = fsTool.Bool("json", false, "output in JSON format(partially supported)")
 	flagToolUpdateIPDB        = fsTool.Bool("update-ipdb", false, "update local IPDB")
 
+	flagToolDiffPoints     = fsTool.String("diff-points", "", "diff recorded points(.pbjson/.lp file or directory): --diff-points <a> <b>")
+	flagToolDiffIgnoreKeys = fsTool.StringSlice("ignore-keys", nil, "tag/field keys ignored by --diff-points, host is always ignored")
+	flagToolDiffValues     = fsTool.Bool("diff-values", false, "compare tag/field values by --diff-points")
+
 	flagToolParseKVFile         = fsTool.String("parse-kv-file", "", "parse input conf file with kv replaced")
 	flagToolKVFile              = fsTool.String("kv-file", "", "specify the kv file path")
 	flagToolRemoveApmAutoInject = fsTool.Bool("remove-apm-auto-inject", false, "remove apm-auto-inject")
@@ -457,6 +476,24 @@ func doParseAndRunFlags() {
 
 		case fsPLName:
 
+			if len(os.Args) > 2 && os.Args[2] == fsPLTestName {
+				if err := fsPLTest.Parse(os.Args[3:]); err != nil {
+					cp.Errorf("[E] Parse: %s\n", err)
+					fsPLTestUsage()
+					os.Exit(-1)
+				}
+
+				setCmdRootLog(*flagPLTestLogPath)
+				tryLoadMainCfg()
+
+				if err := runPLTestFlags(fsPLTest.Args()); err != nil {
+					cp.Errorf("[E] %s\n", err)
+					os.Exit(-1)
+				}
+
+				os.Exit(0)
+			}
+
 			if len(os.Args) < 6 {
 				fsPLUsage()
 				os.Exit(-1)
@@ -0,0 +1,102 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package cmds
+
+import (
+	"fmt"
+	"path/filepath"
+
+	cp "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/colorprint"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/config"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/path"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/pltest"
+)
+
+func runPLTestFlags(dirs []string) error {
+	if err := pipeline.InitPipeline(config.Cfg.Pipeline, nil, datakit.GlobalHostTags(),
+		datakit.InstallDir); err != nil {
+		return err
+	}
+
+	if len(dirs) == 0 {
+		dirs = defaultPLTestDirs()
+	}
+
+	if len(dirs) == 0 {
+		return fmt.Errorf("no pipeline directory found")
+	}
+
+	var scripts, failed int
+	for _, dir := range dirs {
+		res, err := pltest.RunDir(dir)
+		if err != nil {
+			return err
+		}
+
+		for _, r := range res {
+			scripts++
+			if !r.Passed() {
+				failed++
+			}
+			printPLTestResult(r, *flagPLTestVerbose)
+		}
+	}
+
+	cp.Infof("---------------\n")
+	if failed > 0 {
+		return fmt.Errorf("%d of %d scripts failed", failed, scripts)
+	}
+
+	cp.Infof("%d scripts passed\n", scripts)
+	return nil
+}
+
+// defaultPLTestDirs returns pipeline directories of DataKit and all git repos.
+func defaultPLTestDirs() []string {
+	var dirs []string
+
+	if dir := filepath.Join(datakit.InstallDir, "pipeline"); path.IsDir(dir) {
+		dirs = append(dirs, dir)
+	}
+
+	repos, _ := filepath.Glob(filepath.Join(datakit.GitReposDir, "*", "pipeline"))
+	for _, dir := range repos {
+		if path.IsDir(dir) {
+			dirs = append(dirs, dir)
+		}
+	}
+
+	return dirs
+}
+
+func printPLTestResult(r *pltest.ScriptResult, verbose bool) {
+	if r.Err != nil {
+		cp.Errorf("FAIL %s/%s: %s\n", r.Category, r.Script, r.Err)
+		return
+	}
+
+	if r.Passed() {
+		cp.Infof("PASS %s/%s (%d cases)\n", r.Category, r.Script, len(r.Cases))
+	} else {
+		cp.Errorf("FAIL %s/%s (%s)\n", r.Category, r.Script, r.TestFile)
+	}
+
+	for _, c := range r.Cases {
+		switch {
+		case c.Err != nil:
+			cp.Errorf("    --- FAIL %s: %s\n", c.Name, c.Err)
+		case len(c.Diffs) > 0:
+			cp.Errorf("    --- FAIL %s\n", c.Name)
+			for _, d := range c.Diffs {
+				cp.Output("        %s\n", d)
+			}
+		case verbose:
+			cp.Infof("    --- PASS %s\n", c.Name)
+		}
+	}
+}
@@ -36,6 +36,14 @@ func runToolFlags() error {
 			os.Exit(0)
 		}
 
+	case *flagToolDiffPoints != "":
+		if err := diffPoints(*flagToolDiffPoints, fsTool.Args()); err != nil {
+			cp.Errorf("%s\n", err)
+			os.Exit(1)
+		} else {
+			os.Exit(0)
+		}
+
 	case *flagToolSetupCompleterScripts:
 		setupCompleterScripts()
 		os.Exit(0)
@@ -501,10 +501,7 @@ func getPipelineData() {
 	// Update pipeline script.
 	l.Debug("before set pipelines from confd ")
 
-	if m, ok := plval.GetManager(); ok && m != nil {
-		m.LoadScriptsFromWorkspace(
-			manager.NSConfd, datakit.ConfdPipelineDir, nil)
-	}
+	plval.LoadScriptsFromWorkspace(manager.NSConfd, datakit.ConfdPipelineDir, nil)
 }
 
 func storeDataToDisk(key, data string, dirCategory map[string]string) error {
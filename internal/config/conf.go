@@ -51,11 +51,14 @@ type DKUpgraderCfg struct {
 }
 
 type GitRepository struct {
-	Enable                bool   `toml:"enable"`
-	URL                   string `toml:"url"`
-	SSHPrivateKeyPath     string `toml:"ssh_private_key_path"`
-	SSHPrivateKeyPassword string `toml:"ssh_private_key_password"`
-	Branch                string `toml:"branch"`
+	Enable                bool     `toml:"enable"`
+	URL                   string   `toml:"url"`
+	SSHPrivateKeyPath     string   `toml:"ssh_private_key_path"`
+	SSHPrivateKeyPassword string   `toml:"ssh_private_key_password"`
+	Branch                string   `toml:"branch"`
+	Tag                   string   `toml:"tag"`
+	TrustedKeys           []string `toml:"trusted_keys"`
+	PipelineTest          bool     `toml:"pipeline_test"`
 }
 
 type GitRepost struct {
@@ -94,6 +94,11 @@ func maskPassword(s string) string {
 	return s[:5] + stars
 }
 
+// DecodeENC decode ENC[...] within s with configured crypto settings.
+func DecodeENC(s string) string {
+	return string(decodeEncs([]byte(s)))
+}
+
 func initCrypto(c *Config) {
 	if c.Crypto == nil {
 		c.Crypto = &configCrpto{}
@@ -111,6 +111,17 @@ func (c *Config) loadConfdEnvs() {
 		keyPath := datakit.GetEnv("ENV_GIT_KEY_PATH")
 		keyPasswd := datakit.GetEnv("ENV_GIT_KEY_PW")
 		branch := datakit.GetEnv("ENV_GIT_BRANCH")
+		plTest := datakit.GetEnv("ENV_GIT_PIPELINE_TEST") != ""
+		tag := datakit.GetEnv("ENV_GIT_TAG")
+
+		var trustedKeys []string
+		if x := datakit.GetEnv("ENV_GIT_TRUSTED_KEYS"); x != "" {
+			for _, k := range strings.Split(x, ",") {
+				if k = strings.TrimSpace(k); k != "" {
+					trustedKeys = append(trustedKeys, k)
+				}
+			}
+		}
 
 		c.GitRepos = &GitRepost{
 			PullInterval: interval,
@@ -121,6 +132,9 @@ func (c *Config) loadConfdEnvs() {
 					SSHPrivateKeyPath:     keyPath,
 					SSHPrivateKeyPassword: keyPasswd,
 					Branch:                branch,
+					Tag:                   tag,
+					TrustedKeys:           trustedKeys,
+					PipelineTest:          plTest,
 				},
 			},
 		}
@@ -503,6 +517,22 @@ func (c *Config) loadRemoteJobEnvs() {
 	if v := datakit.GetEnv("ENV_REMOTE_JOB_INTERVAL"); v != "" {
 		c.RemoteJob.Interval = v
 	}
+
+	if v := datakit.GetEnv("ENV_REMOTE_JOB_MAX_REPORT_SIZE"); v != "" {
+		if x, err := strconv.ParseInt(v, 10, 64); err != nil {
+			l.Warnf("invalid ENV_REMOTE_JOB_MAX_REPORT_SIZE %q: %s, ignored", v, err)
+		} else {
+			c.RemoteJob.MaxReportSize = x
+		}
+	}
+
+	if v := datakit.GetEnv("ENV_REMOTE_JOB_LOG_SNAPSHOT_PATHS"); v != "" {
+		c.RemoteJob.LogSnapshotPaths = strings.Split(v, ",")
+	}
+
+	if v := datakit.GetEnv("ENV_REMOTE_JOB_PY_SPY_PATH"); v != "" {
+		c.RemoteJob.PySpyPath = v
+	}
 }
 
 func (c *Config) loadElectionEnvs() {
@@ -27,6 +27,39 @@ type APIConfig struct {
 	CloseIdleConnection bool       `toml:"close_idle_connection"`
 	TLSConf             *TLSConfig `toml:"tls"`
 	AllowedCORSOrigins  []string   `toml:"allowed_cors_origins"`
+
+	Auth *APIAuthConfig `toml:"auth"`
+}
+
+// APIAuthConfig used to configure client authentication of HTTP APIs.
+type APIAuthConfig struct {
+	Enable bool `toml:"enable"`
+
+	// Loopback clients are trusted by default, enable this to authenticate them too.
+	LoopbackAuth bool `toml:"loopback_auth"`
+
+	// CA file to verify client certificates(mTLS), only works with HTTPS enabled.
+	ClientCA string `toml:"client_ca"`
+
+	Keys    []*APIKey        `toml:"keys"`
+	Clients []*APIClientCert `toml:"clients"`
+}
+
+// APIKey is a static API key. Key can be ENC[...] encoded, or loaded from
+// KeyFile, the file re-read once modified, so the key can be rotated without
+// restarting datakit.
+type APIKey struct {
+	Name     string   `toml:"name"`
+	Key      string   `toml:"key"`
+	KeyFile  string   `toml:"key_file"`
+	Scopes   []string `toml:"scopes"`
+	ExpireAt string   `toml:"expire_at"` // RFC3339
+}
+
+// APIClientCert grant scopes to mTLS clients by their certificate common name.
+type APIClientCert struct {
+	CommonName string   `toml:"common_name"`
+	Scopes     []string `toml:"scopes"`
 }
 
 func (conf *APIConfig) HTTPSEnabled() bool {
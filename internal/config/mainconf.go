@@ -260,6 +260,8 @@ func DefaultConfig() *Config {
 			},
 			Interval: "30s",
 			JavaHome: "",
+
+			MaxReportSize: 4 * 1024 * 1024,
 		},
 	}
 
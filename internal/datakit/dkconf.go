@@ -108,6 +108,17 @@ ulimit = 64000
       # "http://<ip>:<port>"
     ]
 
+  # Evaluate updated scripts on sampled data before they take effect.
+  #[pipeline.shadow]
+  #  enable = true
+  #  sample_rate = 0.1
+  #  min_samples = 100
+  #  timeout = "30m"
+  #  min_timeout_samples = 1
+  #  max_fail_rate_increase = 0.01
+  #  max_drop_rate_increase = 0.01
+  #  max_field_diff_rate = 0.05
+
 ################################################
 # HTTP server(9529)
 ################################################
@@ -146,6 +157,22 @@ ulimit = 64000
     # cert = "path/to/certificate/file"
     # privkey = "path/to/private_key/file"
 
+  # Authenticate HTTP API clients with API keys or client certificates(mTLS)
+  #[http_api.auth]
+  #  enable = false
+  #  loopback_auth = false # also authenticate loopback(localhost) clients
+  #  client_ca = "path/to/client/ca/file" # verify client certificates, HTTPS required
+  #
+  #  [[http_api.auth.keys]]
+  #    name = "app-logging"
+  #    key = "ENC[aes://...]" # or set key_file, the file re-read once modified
+  #    scopes = [ "write:logging", "write:metric" ]
+  #    expire_at = "2026-12-31T00:00:00Z"
+  #
+  #  [[http_api.auth.clients]]
+  #    common_name = "collector.example.com"
+  #    scopes = [ "write:*" ]
+
 ################################################
 # io configures
 ################################################
@@ -164,6 +191,35 @@ ulimit = 64000
   # NOTE: Global blocking mode may consume more memory on large metric points.
   global_blocking = false
 
+  # Per-category and per-input back-pressure policies on feed queue.
+  # policy: one of block/drop_oldest/drop_newest/spill.
+  #[io.feed_policy]
+  #  enable = false
+  #  [io.feed_policy.categories.logging]
+  #    policy     = "drop_oldest"
+  #    queue_size = 128
+  #  [io.feed_policy.inputs.cpu]
+  #    weight = 10
+
+  # Aggregate metric points before upload
+  #[io.aggregate]
+  #  enable = false
+  #  [[io.aggregate.rules]]
+  #    measurements = ["cpu"]
+  #    group_by     = ["host"]
+  #    window       = "1m"
+  #    funcs        = ["avg", "max", "p99"]
+  #    drop_raw     = false
+
+  # Limit unique series of each metric measurement
+  #[io.cardinality]
+  #  enable     = false
+  #  max_series = 10000
+  #  action     = "drop" # drop/strip_tag/overflow
+  #  protected_tags = ["host"]
+  #  [io.cardinality.measurements.some_measurement]
+  #    max_series = 1000
+
   # Data point filter configures.
   # NOTE: Most of the time, you should use web-side filter, it's a debug helper for developers.
   #[io.filters]
@@ -342,6 +398,16 @@ ulimit = 64000
   # set max memory usage(MB)
   mem_max_mb = 4096
 
+  # account CPU/memory usage of each input even no budget configured
+  #account_inputs = false
+
+  # per-input CPU/memory budgets, enforced within Datakit(not require enable = true)
+  # action on exceeding the budget: throttle(delay feeds of the input) or pause(block feeds of the input)
+  #[resource_limit.inputs.prom]
+  #  cpu_max = 5.0
+  #  mem_max_mb = 256
+  #  action = "throttle"
+
 ################################################
 # git_repos configures
 ################################################
@@ -359,6 +425,9 @@ ulimit = 64000
     # the branch name to pull
     branch = "master"
 
+    # pin to the tag instead of the branch HEAD
+    tag = ""
+
     # git repository URL. There are 3 formats here:
     #   - HTTP(s): such as "https://github.datakit.com/path/to/datakit-conf.git"
     #   - Git: such as "git@github.com:path/to/datakit.git"
@@ -369,6 +438,14 @@ ulimit = 64000
     ssh_private_key_path = ""
     ssh_private_key_password = ""
 
+    # run test cases of pipeline scripts before applying the update, the update
+    # is rejected if any test failed.
+    pipeline_test = false
+
+    # public key files(armored GPG public key or SSH authorized_keys format),
+    # if set, the update is applied only if the commit is signed by one of them.
+    # trusted_keys = ["/path/to/gpg.pub", "/path/to/ssh.pub"]
+
 ################################################
 # crypto key or key filePath.
 ################################################
@@ -381,4 +458,13 @@ ulimit = 64000
   envs = ["OSS_BUCKET_HOST=host","OSS_ACCESS_KEY_ID=key","OSS_ACCESS_KEY_SECRET=secret","OSS_BUCKET_NAME=bucket"]
   interval = "30s"
   java_home=""
+
+  # max bytes of a job report, default 4MiB
+  max_report_size = 4194304
+
+  # log files allowed to snapshot, log snapshot disabled if not set
+  # log_snapshot_paths = ["/var/log/*.log"]
+
+  # py-spy binary path, searched within $PATH if empty
+  py_spy_path = ""
 `
@@ -10,14 +10,19 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/GuanceCloud/cliutils/logger"
 	git "github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/filemode"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/go-git/go-git/v5/plumbing/transport/ssh"
@@ -27,7 +32,10 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/config"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/httpapi"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/path"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/pltest"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs"
 )
 
@@ -103,6 +111,7 @@ func pullMain(cg *config.GitRepost) error {
 
 				tip := fmt.Sprintf("[gitrepo] failed: %v", err)
 				l.Error(tip)
+				dkio.DefaultFeeder().FeedLastError(tip, metrics.WithLastErrorInput("gitrepo"))
 			}
 		}
 
@@ -142,23 +151,25 @@ func doRun(c *config.GitRepository) error {
 		return err
 	}
 
+	keys, err := loadTrustedKeys(c.TrustedKeys)
+	if err != nil {
+		l.Errorf("loadTrustedKeys failed: %v", err)
+		return err
+	}
+
 	isUpdate := true
 
 	// check git repo exist
 	if path.IsDir(clonePath) {
-		// clone in the exist dir
 		l.Debug("Pull start")
 
-		isUpdate, err = gitPull(clonePath, c.Branch, authMethod)
-		if err != nil {
+		if isUpdate, err = gitPull(clonePath, c, authMethod, keys); err != nil {
 			return err
 		}
 	} else {
-		// clone a new one
 		l.Debug("PlainClone start")
 
-		if err := gitPlainClone(clonePath, c.URL, c.Branch, authMethod); err != nil {
-			l.Errorf("gitPlainClone failed: %v", err)
+		if err := gitClone(clonePath, c, authMethod, keys); err != nil {
 			return err
 		}
 	}
@@ -181,79 +192,306 @@ func doRun(c *config.GitRepository) error {
 	return nil
 }
 
-func gitPull(clonePath, branch string, authMethod transport.AuthMethod) (isUpdate bool, err error) {
-	isUpdate = true // default is true
-
-	// We instantiate a new repository targeting the given path (the .git folder)
+// gitPull fetches the branch or tag, and moves the worktree to the fetched
+// commit only if it passed checkUpdate, so reloads never see a rejected
+// commit.
+func gitPull(clonePath string, c *config.GitRepository, authMethod transport.AuthMethod, keys *trustedKeys) (bool, error) {
 	r, err := git.PlainOpen(clonePath)
 	if err != nil {
 		l.Errorf("PlainOpen failed: %v", err)
-		return
+		return false, err
 	}
 
-	// Get the working directory for the repository
-	w, err := r.Worktree()
+	hash, err := gitFetch(r, c.Branch, c.Tag, authMethod)
 	if err != nil {
-		l.Errorf("Worktree failed: %v", err)
-		return
+		if strings.Contains(err.Error(), errTextSameNameNotReally) {
+			if err := os.RemoveAll(clonePath); err != nil {
+				l.Warnf("failed remove clone path %s with error %v", clonePath, err)
+			} else {
+				l.Infof("succeeded remove clone path %s", clonePath)
+			}
+		}
+		return false, err
+	}
+
+	hrf, err := r.Head()
+	if err != nil {
+		l.Errorf("Head failed: %v", err)
+		return false, err
+	}
+
+	if hrf.Hash() == hash && (c.Tag != "" || hrf.Name() == plumbing.NewBranchReferenceName(c.Branch)) {
+		return false, nil
+	}
+
+	if err := checkUpdate(r, hash, c, keys); err != nil {
+		l.Warnf("commit %s rejected, stay on %s", hash, hrf.Hash())
+		return false, err
+	}
+
+	if err := gitCheckout(r, c.Branch, c.Tag, hash); err != nil {
+		l.Errorf("Checkout failed: %v", err)
+		return false, err
+	}
+
+	return true, nil
+}
+
+// gitFetch fetches the branch or tag from origin and returns its commit,
+// the worktree is not changed.
+func gitFetch(r *git.Repository, branch, tag string, authMethod transport.AuthMethod) (plumbing.Hash, error) {
+	refSpec := gitconfig.RefSpec(fmt.Sprintf("+%s%s:refs/remotes/origin/%s", prefixGitBranchName, branch, branch))
+	ref := plumbing.NewRemoteReferenceName("origin", branch)
+	if tag != "" {
+		refSpec = "+refs/tags/*:refs/tags/*"
+		ref = plumbing.NewTagReferenceName(tag)
 	}
 
 	ctxNew, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
-	// Pull the latest changes from the origin remote and merge into the current branch
-	err = w.PullContext(ctxNew, &git.PullOptions{
+	err := r.FetchContext(ctxNew, &git.FetchOptions{
 		RemoteName:      "origin",
-		ReferenceName:   plumbing.NewBranchReferenceName(branch),
+		RefSpecs:        []gitconfig.RefSpec{refSpec},
 		Auth:            authMethod,
 		Force:           true,
 		InsecureSkipTLS: true,
 	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		l.Errorf("Fetch failed: %v", err)
+		return plumbing.ZeroHash, err
+	}
+
+	hash, err := r.ResolveRevision(plumbing.Revision(ref))
 	if err != nil {
-		// ignore specific errors
-		if errors.Is(err, git.NoErrAlreadyUpToDate) {
-			isUpdate = false // NOTE: not continue here
-		} else {
-			l.Errorf("Pull failed: %v", err)
+		return plumbing.ZeroHash, fmt.Errorf("resolve %s: %w", ref, err)
+	}
 
-			if strings.Contains(err.Error(), errTextSameNameNotReally) {
-				if err := os.RemoveAll(clonePath); err != nil {
-					l.Warnf("failed remove clone path %s with error %v", clonePath, err)
-				} else {
-					l.Infof("succeeded remove clone path %s", clonePath)
-				}
-			}
-			return
+	return *hash, nil
+}
+
+// gitCheckout moves the worktree to the commit: the branch is reset to it,
+// or HEAD is detached at it for tag.
+func gitCheckout(r *git.Repository, branch, tag string, hash plumbing.Hash) error {
+	w, err := r.Worktree()
+	if err != nil {
+		return err
+	}
+
+	if tag != "" {
+		return w.Checkout(&git.CheckoutOptions{Hash: hash, Force: true})
+	}
+
+	ref := plumbing.NewBranchReferenceName(branch)
+	if err := r.Storer.SetReference(plumbing.NewHashReference(ref, hash)); err != nil {
+		return err
+	}
+
+	return w.Checkout(&git.CheckoutOptions{Branch: ref, Force: true})
+}
+
+// gitClone clones the repo into a staging directory, and moves it to
+// clonePath only if HEAD passed checkUpdate.
+func gitClone(clonePath string, c *config.GitRepository, authMethod transport.AuthMethod, keys *trustedKeys) error {
+	if err := os.MkdirAll(filepath.Dir(clonePath), os.ModePerm); err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(clonePath), "."+filepath.Base(clonePath)+"-*")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err := os.RemoveAll(staging); err != nil {
+			l.Warnf("failed remove staging path %s with error %v", staging, err)
 		}
+	}()
+
+	if err := gitPlainClone(staging, c.URL, c.Branch, c.Tag, authMethod); err != nil {
+		l.Errorf("gitPlainClone failed: %v", err)
+		return err
+	}
+
+	r, err := git.PlainOpen(staging)
+	if err != nil {
+		return err
 	}
 
-	// get branch name, if branch exists, then create flag is false
-	flagCreate := true
 	hrf, err := r.Head()
 	if err != nil {
-		l.Errorf("Head failed: %v", err)
-		return
+		return err
+	}
+
+	if err := checkUpdate(r, hrf.Hash(), c, keys); err != nil {
+		return err
 	}
-	if hrf.Name().IsBranch() {
-		branchName := string(hrf.Name())
-		branchName = strings.TrimPrefix(branchName, prefixGitBranchName)
-		if branchName == branch {
-			flagCreate = false
+
+	return os.Rename(staging, clonePath)
+}
+
+// checkUpdate checks the commit before applying it: the commit should be
+// signed by trusted keys if configured, input configures should load, and
+// pipeline tests should pass if enabled. Files are checked on a temporary
+// copy of the commit, the worktree is not changed.
+func checkUpdate(r *git.Repository, hash plumbing.Hash, c *config.GitRepository, keys *trustedKeys) error {
+	commit, err := r.CommitObject(hash)
+	if err != nil {
+		return err
+	}
+
+	if keys != nil {
+		if err := keys.verifyCommit(commit); err != nil {
+			return err
 		}
 	}
 
-	err = w.Checkout(&git.CheckoutOptions{
-		Branch: plumbing.NewBranchReferenceName(branch),
-		Force:  true,
-		Create: flagCreate,
+	dir, err := os.MkdirTemp("", "gitrepo-check-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	if err := writeTree(commit, dir); err != nil {
+		return fmt.Errorf("write commit %s: %w", hash, err)
+	}
+
+	if err := checkConfig(dir); err != nil {
+		return err
+	}
+
+	if c.PipelineTest {
+		return testPipeline(dir)
+	}
+
+	return nil
+}
+
+// writeTree writes files of the commit into dir.
+func writeTree(commit *object.Commit, dir string) error {
+	files, err := commit.Files()
+	if err != nil {
+		return err
+	}
+
+	return files.ForEach(func(f *object.File) error {
+		fp := filepath.Join(dir, filepath.FromSlash(f.Name))
+		if rel, err := filepath.Rel(dir, fp); err != nil || strings.HasPrefix(rel, "..") {
+			return fmt.Errorf("invalid file path %q", f.Name)
+		}
+
+		if err := os.MkdirAll(filepath.Dir(fp), os.ModePerm); err != nil {
+			return err
+		}
+
+		if f.Mode == filemode.Symlink {
+			target, err := f.Contents()
+			if err != nil {
+				return err
+			}
+			return os.Symlink(target, fp)
+		}
+
+		mode, err := f.Mode.ToOSFileMode()
+		if err != nil {
+			return err
+		}
+
+		rd, err := f.Reader()
+		if err != nil {
+			return err
+		}
+		defer rd.Close() //nolint:errcheck
+
+		out, err := os.OpenFile(filepath.Clean(fp), os.O_CREATE|os.O_WRONLY|os.O_TRUNC, mode.Perm())
+		if err != nil {
+			return err
+		}
+
+		if _, err := io.Copy(out, rd); err != nil {
+			out.Close() //nolint:errcheck,gosec
+			return err
+		}
+
+		return out.Close()
 	})
+}
+
+// checkConfig dry-run loads input configures within the repo, the same as
+// datakit check --config.
+func checkConfig(clonePath string) error {
+	dir := filepath.Join(clonePath, datakit.GitRepoSubDirNameConfd)
+	if !path.IsDir(dir) {
+		return nil
+	}
+
+	var failed []string
+	for _, fp := range config.SearchDir(dir, ".conf", ".git") {
+		if strings.HasPrefix(filepath.Base(fp), ".") {
+			continue
+		}
+
+		// skip checksum, or the conf is ignored on reload as duplicated
+		if _, err := config.LoadSingleConfFile(fp, inputs.Inputs, true); err != nil {
+			rel, _ := filepath.Rel(clonePath, fp)
+			failed = append(failed, fmt.Sprintf("%s: %s", rel, err))
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("load conf failed: %s", strings.Join(failed, "; "))
+	}
+
+	return nil
+}
+
+// testPipeline runs test cases of pipeline scripts within the repo, and
+// returns error if any script failed.
+func testPipeline(clonePath string) error {
+	dir := filepath.Join(clonePath, "pipeline")
+	if !path.IsDir(dir) {
+		return nil
+	}
+
+	res, err := pltest.RunDir(dir)
 	if err != nil {
-		l.Errorf("Checkout failed: %v", err)
-		return
+		return err
+	}
+
+	var failed []string
+	for _, r := range res {
+		if r.Passed() {
+			continue
+		}
+
+		failed = append(failed, r.Category.String()+"/"+r.Script)
+		if r.Err != nil {
+			l.Warnf("pipeline %s/%s: %s", r.Category, r.Script, r.Err)
+		}
+
+		for _, c := range r.Cases {
+			if c.Err != nil {
+				l.Warnf("pipeline %s/%s case %s: %s", r.Category, r.Script, c.Name, c.Err)
+			}
+			for _, d := range c.Diffs {
+				l.Warnf("pipeline %s/%s case %s: %s", r.Category, r.Script, c.Name, d)
+			}
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("pipeline test failed: %s", strings.Join(failed, ", "))
 	}
-	return isUpdate, nil
+
+	l.Infof("pipeline test passed, %d scripts", len(res))
+	return nil
 }
 
-func gitPlainClone(clonePath, gitURL, branch string, authMethod transport.AuthMethod) error {
+func gitPlainClone(clonePath, gitURL, branch, tag string, authMethod transport.AuthMethod) error {
+	ref := plumbing.NewBranchReferenceName(branch)
+	if tag != "" {
+		ref = plumbing.NewTagReferenceName(tag)
+	}
+
 	ctxNew, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if _, err := git.PlainCloneContext(ctxNew, clonePath, false, &git.CloneOptions{
@@ -264,7 +502,7 @@ func gitPlainClone(clonePath, gitURL, branch string, authMethod transport.AuthMe
 		URL:             gitURL,
 		InsecureSkipTLS: true,
 		RemoteName:      "origin",
-		ReferenceName:   plumbing.NewBranchReferenceName(branch),
+		ReferenceName:   ref,
 	}); err != nil {
 		return err
 	}
@@ -6,15 +6,28 @@
 package gitrepo
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
 	"fmt"
 	"net/url"
 	"os"
 	"path/filepath"
 	T "testing"
+	"time"
 
+	"github.com/ProtonMail/go-crypto/openpgp"
+	"github.com/ProtonMail/go-crypto/openpgp/armor"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/go-git/go-git/v5/plumbing/transport"
 	"github.com/go-git/go-git/v5/plumbing/transport/http"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	ssh2 "golang.org/x/crypto/ssh"
 
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/config"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
@@ -301,6 +314,297 @@ func TestGitPull(t *T.T) {
 	const clonePath = "/usr/local/datakit/gitrepos/repository"
 	const branch = "empty"
 
-	_, err = gitPull(clonePath, branch, authM)
+	_, err = gitPull(clonePath, &config.GitRepository{Branch: branch}, authM, nil)
 	assert.NoError(t, err)
 }
+
+func TestTestPipeline(t *T.T) {
+	clonePath := t.TempDir()
+	r, err := git.PlainInit(clonePath, false)
+	assert.NoError(t, err)
+	w, err := r.Worktree()
+	assert.NoError(t, err)
+
+	commit := func(name, content string) plumbing.Hash {
+		t.Helper()
+		fp := filepath.Join(clonePath, name)
+		assert.NoError(t, os.MkdirAll(filepath.Dir(fp), os.ModePerm))
+		assert.NoError(t, os.WriteFile(fp, []byte(content), 0o600))
+		_, err := w.Add(name)
+		assert.NoError(t, err)
+		h, err := w.Commit("update "+name, &git.CommitOptions{
+			Author: &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		})
+		assert.NoError(t, err)
+		return h
+	}
+
+	// no pipeline directory
+	assert.NoError(t, testPipeline(clonePath))
+
+	commit("pipeline/a.p", `add_key(a, 1)`)
+	first := commit("pipeline/a_test.toml", "[[case]]\ntext = \"x\"\n[case.expect.fields]\na = 1\n")
+	assert.NoError(t, testPipeline(clonePath))
+
+	commit("pipeline/a.p", `add_key(a, 2)`)
+	err = testPipeline(clonePath)
+	assert.ErrorContains(t, err, "pipeline test failed: logging/a.p")
+
+	// check on the commit without changing the worktree
+	c := &config.GitRepository{PipelineTest: true}
+	assert.NoError(t, checkUpdate(r, first, c, nil))
+	assert.ErrorContains(t, testPipeline(clonePath), "pipeline test failed: logging/a.p")
+}
+
+// newTestRepo inits a repo and returns function to commit a file.
+func newTestRepo(t *T.T, dir string) (*git.Repository, func(name, content string, opt *git.CommitOptions) plumbing.Hash) {
+	t.Helper()
+
+	r, err := git.PlainInit(dir, false)
+	require.NoError(t, err)
+	w, err := r.Worktree()
+	require.NoError(t, err)
+
+	return r, func(name, content string, opt *git.CommitOptions) plumbing.Hash {
+		t.Helper()
+		fp := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(fp), os.ModePerm))
+		require.NoError(t, os.WriteFile(fp, []byte(content), 0o600))
+		_, err := w.Add(name)
+		require.NoError(t, err)
+
+		if opt == nil {
+			opt = &git.CommitOptions{}
+		}
+		opt.Author = &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()}
+		h, err := w.Commit("update "+name, opt)
+		require.NoError(t, err)
+		return h
+	}
+}
+
+func TestCheckConfig(t *T.T) {
+	clonePath := t.TempDir()
+	_, commit := newTestRepo(t, clonePath)
+
+	// no conf.d directory
+	assert.NoError(t, checkConfig(clonePath))
+
+	commit("conf.d/cpu.conf", "[[inputs.cpu]]\ninterval = \"10s\"\n", nil)
+	assert.NoError(t, checkConfig(clonePath))
+
+	commit("conf.d/host/mem.conf", "[[inputs.mem]\n", nil)
+	assert.ErrorContains(t, checkConfig(clonePath), "conf.d/host/mem.conf")
+}
+
+func writeTrustedKey(t *T.T, data []byte) string {
+	t.Helper()
+	fp := filepath.Join(t.TempDir(), "key.pub")
+	require.NoError(t, os.WriteFile(fp, data, 0o600))
+	return fp
+}
+
+func armoredPGPKey(t *T.T, e *openpgp.Entity) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := armor.Encode(&buf, openpgp.PublicKeyType, nil)
+	require.NoError(t, err)
+	require.NoError(t, e.Serialize(w))
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func TestVerifyPGPCommit(t *T.T) {
+	signer, err := openpgp.NewEntity("test", "", "test@example.com", nil)
+	require.NoError(t, err)
+	other, err := openpgp.NewEntity("other", "", "other@example.com", nil)
+	require.NoError(t, err)
+
+	r, commit := newTestRepo(t, t.TempDir())
+
+	keys, err := loadTrustedKeys([]string{writeTrustedKey(t, armoredPGPKey(t, signer))})
+	require.NoError(t, err)
+	untrusted, err := loadTrustedKeys([]string{writeTrustedKey(t, armoredPGPKey(t, other))})
+	require.NoError(t, err)
+
+	c := &config.GitRepository{}
+
+	h := commit("a.conf", "", nil)
+	assert.ErrorContains(t, checkUpdate(r, h, c, keys), "is not signed")
+
+	h = commit("b.conf", "", &git.CommitOptions{SignKey: signer})
+	assert.NoError(t, checkUpdate(r, h, c, keys))
+	assert.ErrorContains(t, checkUpdate(r, h, c, untrusted), "not signed by trusted keys")
+}
+
+// sshSign signs msg the same as `ssh-keygen -Y sign -n git`.
+func sshSign(t *T.T, signer ssh2.Signer, msg []byte) string {
+	t.Helper()
+
+	h := sha512.Sum512(msg)
+	signed := sshSignedData{Namespace: sshSigNamespace, HashAlgorithm: "sha512", Hash: h[:]}
+	copy(signed.Magic[:], sshSigMagic)
+
+	s, err := signer.Sign(rand.Reader, ssh2.Marshal(&signed))
+	require.NoError(t, err)
+
+	sig := sshSig{
+		Version:       sshSigVersion,
+		PublicKey:     signer.PublicKey().Marshal(),
+		Namespace:     sshSigNamespace,
+		HashAlgorithm: "sha512",
+		Signature:     ssh2.Marshal(s),
+	}
+	copy(sig.Magic[:], sshSigMagic)
+
+	return sshSigArmorStart + "\n" + base64.StdEncoding.EncodeToString(ssh2.Marshal(&sig)) + "\n" + sshSigArmorEnd + "\n"
+}
+
+func newSSHSigner(t *T.T) ssh2.Signer {
+	t.Helper()
+	_, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	signer, err := ssh2.NewSignerFromKey(priv)
+	require.NoError(t, err)
+	return signer
+}
+
+func TestVerifySSHCommit(t *T.T) {
+	signer := newSSHSigner(t)
+	other := newSSHSigner(t)
+
+	keys, err := loadTrustedKeys([]string{
+		writeTrustedKey(t, append(ssh2.MarshalAuthorizedKey(other.PublicKey()), ssh2.MarshalAuthorizedKey(signer.PublicKey())...)),
+	})
+	require.NoError(t, err)
+	assert.Len(t, keys.ssh, 2)
+
+	c := &object.Commit{
+		Author:    object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Committer: object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+		Message:   "update",
+		TreeHash:  plumbing.ZeroHash,
+	}
+
+	msg, err := commitPayload(c)
+	require.NoError(t, err)
+
+	c.PGPSignature = sshSign(t, signer, msg)
+	assert.NoError(t, keys.verifyCommit(c))
+
+	// content changed after signed
+	c.Message = "changed"
+	assert.Error(t, keys.verifyCommit(c))
+
+	// signed by untrusted key
+	c.PGPSignature = sshSign(t, newSSHSigner(t), msg)
+	c.Message = "update"
+	assert.ErrorContains(t, keys.verifyCommit(c), "untrusted key")
+
+	_, err = loadTrustedKeys([]string{writeTrustedKey(t, []byte("not a key"))})
+	assert.Error(t, err)
+}
+
+// headOf returns HEAD commit of the repo.
+func headOf(t *T.T, dir string) plumbing.Hash {
+	t.Helper()
+
+	r, err := git.PlainOpen(dir)
+	require.NoError(t, err)
+	hrf, err := r.Head()
+	require.NoError(t, err)
+	return hrf.Hash()
+}
+
+func TestGitPullTag(t *T.T) {
+	origin := t.TempDir()
+	r, commit := newTestRepo(t, origin)
+
+	tag := func(name string, h plumbing.Hash) {
+		t.Helper()
+		_, err := r.CreateTag(name, h, &git.CreateTagOptions{
+			Tagger:  &object.Signature{Name: "test", Email: "test@example.com", When: time.Now()},
+			Message: name,
+		})
+		require.NoError(t, err)
+	}
+
+	v1 := commit("conf.d/a.conf", "", nil)
+	tag("v1", v1)
+	commit("conf.d/b.conf", "", nil) // not tagged
+
+	c := &config.GitRepository{URL: origin, Tag: "v1"}
+	clonePath := filepath.Join(t.TempDir(), "repo")
+	require.NoError(t, gitClone(clonePath, c, nil, nil))
+	assert.Equal(t, v1, headOf(t, clonePath))
+
+	updated, err := gitPull(clonePath, c, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	v2 := commit("conf.d/c.conf", "", nil)
+	tag("v2", v2)
+
+	c.Tag = "v2"
+	updated, err = gitPull(clonePath, c, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, v2, headOf(t, clonePath))
+	assert.FileExists(t, filepath.Join(clonePath, "conf.d/c.conf"))
+
+	c.Tag = "v3"
+	_, err = gitPull(clonePath, c, nil, nil)
+	assert.Error(t, err)
+	assert.Equal(t, v2, headOf(t, clonePath))
+}
+
+func TestGitPullBranch(t *T.T) {
+	origin := t.TempDir()
+	_, commit := newTestRepo(t, origin)
+
+	good := "[[inputs.cpu]]\ninterval = \"10s\"\n"
+	v1 := commit("conf.d/cpu.conf", good, nil)
+
+	c := &config.GitRepository{URL: origin, Branch: "master"}
+	clonePath := filepath.Join(t.TempDir(), "repo")
+	require.NoError(t, gitClone(clonePath, c, nil, nil))
+	assert.Equal(t, v1, headOf(t, clonePath))
+
+	updated, err := gitPull(clonePath, c, nil, nil)
+	require.NoError(t, err)
+	assert.False(t, updated)
+
+	// rejected commit never reaches the worktree
+	commit("conf.d/cpu.conf", "[[inputs.cpu]\n", nil)
+	_, err = gitPull(clonePath, c, nil, nil)
+	assert.ErrorContains(t, err, "conf.d/cpu.conf")
+	assert.Equal(t, v1, headOf(t, clonePath))
+	data, err := os.ReadFile(filepath.Join(clonePath, "conf.d/cpu.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, good, string(data))
+
+	v3 := commit("conf.d/cpu.conf", good+"percpu = true\n", nil)
+	updated, err = gitPull(clonePath, c, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, v3, headOf(t, clonePath))
+	data, err = os.ReadFile(filepath.Join(clonePath, "conf.d/cpu.conf"))
+	require.NoError(t, err)
+	assert.Equal(t, good+"percpu = true\n", string(data))
+}
+
+func TestGitCloneRejected(t *T.T) {
+	origin := t.TempDir()
+	_, commit := newTestRepo(t, origin)
+	commit("conf.d/cpu.conf", "[[inputs.cpu]\n", nil)
+
+	dir := t.TempDir()
+	clonePath := filepath.Join(dir, "repo")
+	assert.Error(t, gitClone(clonePath, &config.GitRepository{URL: origin, Branch: "master"}, nil, nil))
+	assert.NoDirExists(t, clonePath)
+
+	// staging directory removed
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
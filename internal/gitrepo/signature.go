@@ -0,0 +1,222 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package gitrepo
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	ssh2 "golang.org/x/crypto/ssh"
+)
+
+const (
+	pgpPublicKeyArmor = "-----BEGIN PGP PUBLIC KEY BLOCK-----"
+
+	sshSigArmorStart = "-----BEGIN SSH SIGNATURE-----"
+	sshSigArmorEnd   = "-----END SSH SIGNATURE-----"
+	sshSigMagic      = "SSHSIG"
+	sshSigVersion    = 1
+	sshSigNamespace  = "git" // namespace used by git for commit signing
+)
+
+// trustedKeys are public keys commits should be signed with.
+type trustedKeys struct {
+	pgp []string // armored key rings
+	ssh []ssh2.PublicKey
+}
+
+// loadTrustedKeys loads public keys from files. A file contains either an
+// armored OpenPGP public key block, or SSH public keys in authorized_keys
+// format. Returns nil if no file configured.
+func loadTrustedKeys(files []string) (*trustedKeys, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+
+	keys := &trustedKeys{}
+	for _, f := range files {
+		data, err := os.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, fmt.Errorf("read trusted key: %w", err)
+		}
+
+		if bytes.Contains(data, []byte(pgpPublicKeyArmor)) {
+			keys.pgp = append(keys.pgp, string(data))
+			continue
+		}
+
+		n := 0
+		for rest := data; len(bytes.TrimSpace(rest)) > 0; {
+			pub, _, _, next, err := ssh2.ParseAuthorizedKey(rest)
+			if err != nil {
+				break
+			}
+			keys.ssh = append(keys.ssh, pub)
+			rest = next
+			n++
+		}
+
+		if n == 0 {
+			return nil, fmt.Errorf("no public key found in %s", f)
+		}
+	}
+
+	return keys, nil
+}
+
+func (k *trustedKeys) verifyCommit(c *object.Commit) error {
+	sig := strings.TrimSpace(c.PGPSignature)
+	if sig == "" {
+		return fmt.Errorf("commit %s is not signed", c.Hash)
+	}
+
+	if strings.HasPrefix(sig, sshSigArmorStart) {
+		msg, err := commitPayload(c)
+		if err != nil {
+			return err
+		}
+
+		if err := k.verifySSH(sig, msg); err != nil {
+			return fmt.Errorf("verify commit %s: %w", c.Hash, err)
+		}
+		return nil
+	}
+
+	for _, ring := range k.pgp {
+		if _, err := c.Verify(ring); err == nil {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("commit %s is not signed by trusted keys", c.Hash)
+}
+
+// commitPayload returns the signed content of the commit.
+func commitPayload(c *object.Commit) ([]byte, error) {
+	encoded := &plumbing.MemoryObject{}
+	if err := c.EncodeWithoutSignature(encoded); err != nil {
+		return nil, err
+	}
+
+	r, err := encoded.Reader()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close() //nolint:errcheck
+
+	return io.ReadAll(r)
+}
+
+// sshSig is the SSHSIG blob, see
+// https://github.com/openssh/openssh-portable/blob/master/PROTOCOL.sshsig
+type sshSig struct {
+	Magic         [6]byte
+	Version       uint32
+	PublicKey     []byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Signature     []byte
+}
+
+// sshSignedData is the data actually signed by the SSH key.
+type sshSignedData struct {
+	Magic         [6]byte
+	Namespace     string
+	Reserved      string
+	HashAlgorithm string
+	Hash          []byte
+}
+
+func parseSSHSig(armored string) (*sshSig, error) {
+	body := strings.TrimSpace(armored)
+	body = strings.TrimPrefix(body, sshSigArmorStart)
+	body = strings.TrimSuffix(body, sshSigArmorEnd)
+
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(body), ""))
+	if err != nil {
+		return nil, fmt.Errorf("decode ssh signature: %w", err)
+	}
+
+	var sig sshSig
+	if err := ssh2.Unmarshal(data, &sig); err != nil {
+		return nil, fmt.Errorf("unmarshal ssh signature: %w", err)
+	}
+
+	if string(sig.Magic[:]) != sshSigMagic {
+		return nil, fmt.Errorf("invalid ssh signature magic")
+	}
+
+	if sig.Version != sshSigVersion {
+		return nil, fmt.Errorf("unsupported ssh signature version %d", sig.Version)
+	}
+
+	return &sig, nil
+}
+
+func (k *trustedKeys) verifySSH(armored string, msg []byte) error {
+	sig, err := parseSSHSig(armored)
+	if err != nil {
+		return err
+	}
+
+	if sig.Namespace != sshSigNamespace {
+		return fmt.Errorf("unexpected ssh signature namespace %q", sig.Namespace)
+	}
+
+	pub, err := ssh2.ParsePublicKey(sig.PublicKey)
+	if err != nil {
+		return fmt.Errorf("parse ssh signature public key: %w", err)
+	}
+
+	trusted := false
+	for _, key := range k.ssh {
+		if bytes.Equal(key.Marshal(), pub.Marshal()) {
+			trusted = true
+			break
+		}
+	}
+
+	if !trusted {
+		return fmt.Errorf("signed by untrusted key %s", ssh2.FingerprintSHA256(pub))
+	}
+
+	var h hash.Hash
+	switch sig.HashAlgorithm {
+	case "sha256":
+		h = sha256.New()
+	case "sha512":
+		h = sha512.New()
+	default:
+		return fmt.Errorf("unsupported ssh signature hash algorithm %q", sig.HashAlgorithm)
+	}
+	h.Write(msg) //nolint:errcheck,gosec
+
+	var s ssh2.Signature
+	if err := ssh2.Unmarshal(sig.Signature, &s); err != nil {
+		return fmt.Errorf("unmarshal ssh signature: %w", err)
+	}
+
+	signed := sshSignedData{
+		Namespace:     sig.Namespace,
+		Reserved:      sig.Reserved,
+		HashAlgorithm: sig.HashAlgorithm,
+		Hash:          h.Sum(nil),
+	}
+	copy(signed.Magic[:], sshSigMagic)
+
+	return pub.Verify(ssh2.Marshal(&signed), &s)
+}
@@ -10,6 +10,8 @@ import (
 	"context"
 	"fmt"
 	"runtime"
+	"runtime/pprof"
+	"strings"
 	"sync"
 	"time"
 
@@ -24,6 +26,10 @@ var (
 	log = logger.DefaultSLogger("goroutine")
 )
 
+// LabelInput is the pprof label set on goroutines of input groups, the
+// label inherited by goroutines they started, used to account CPU usage of each input.
+const LabelInput = "datakit_input"
+
 // A Group is a collection of goroutines working on subtasks that are part of
 // the same overall task.
 type Group struct {
@@ -172,7 +178,13 @@ func (g *Group) do(f func(ctx context.Context) error) {
 			g.wg.Done()
 		}()
 
-		err = f(ctx)
+		if input := strings.TrimPrefix(g.name, inputGroupPrefix); input != g.name {
+			pprof.Do(ctx, pprof.Labels(LabelInput, input), func(ctx context.Context) {
+				err = f(ctx)
+			})
+		} else {
+			err = f(ctx)
+		}
 	}
 
 	run()
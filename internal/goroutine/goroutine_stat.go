@@ -83,7 +83,9 @@ func GetStat() *Summary {
 	return summary
 }
 
+const inputGroupPrefix = "inputs_"
+
 // GetInputName return the group name for each inputs.
 func GetInputName(name string) string {
-	return "inputs_" + name
+	return inputGroupPrefix + name
 }
@@ -0,0 +1,79 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+
+	uhttp "github.com/GuanceCloud/cliutils/network/http"
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/plval"
+)
+
+type IAPIPipelinePromote interface {
+	checkToken(*http.Request) error
+	promote(cat point.Category, ns, name string) error
+}
+
+type apiPipelinePromoteImpl struct {
+	conf *httpServerConf
+}
+
+func (x *apiPipelinePromoteImpl) checkToken(req *http.Request) error {
+	if x.conf.dw == nil {
+		return ErrInvalidToken
+	}
+
+	return checkTokens(x.conf.dw, req)
+}
+
+func (x *apiPipelinePromoteImpl) promote(cat point.Category, ns, name string) error {
+	s, ok := plval.GetShadow()
+	if !ok {
+		return errors.New("pipeline shadow evaluation not enabled")
+	}
+
+	return s.Promote(cat, ns, name)
+}
+
+// apiPipelinePromote forces the updated pipeline script in shadow evaluation
+// (or rejected by it) to take effect.
+func apiPipelinePromote(_ http.ResponseWriter, req *http.Request, args ...any) (interface{}, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("invalid API handle")
+	}
+
+	p, ok := args[0].(IAPIPipelinePromote)
+	if !ok {
+		return nil, fmt.Errorf("invalid API promoter, got type %s", reflect.TypeOf(args[0]))
+	}
+
+	if err := p.checkToken(req); err != nil {
+		return nil, err
+	}
+
+	q := req.URL.Query()
+
+	cat := point.CatString(q.Get("category"))
+	if cat == point.UnknownCategory {
+		return nil, uhttp.Error(ErrInvalidCategory, "invalid category")
+	}
+
+	ns, name := q.Get("ns"), q.Get("name")
+	if ns == "" || name == "" {
+		return nil, uhttp.Error(ErrInvalidRequest, "ns or name missing")
+	}
+
+	if err := p.promote(cat, ns, name); err != nil {
+		return nil, uhttp.Error(ErrInvalidPipeline, err.Error())
+	}
+
+	return nil, nil
+}
@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	T "testing"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mockAPIPipelinePromote struct {
+	tokenErr error
+	promoted []string
+}
+
+func (m *mockAPIPipelinePromote) checkToken(*http.Request) error {
+	return m.tokenErr
+}
+
+func (m *mockAPIPipelinePromote) promote(cat point.Category, ns, name string) error {
+	if name == "not-updated.p" {
+		return errors.New("not updated")
+	}
+
+	m.promoted = append(m.promoted, cat.String()+"/"+ns+"/"+name)
+	return nil
+}
+
+func TestAPIPipelinePromote(t *T.T) {
+	cases := []struct {
+		name     string
+		query    string
+		tokenErr error
+		status   int
+		promoted []string
+	}{
+		{
+			name:     "ok",
+			query:    "?category=logging&ns=remote&name=nginx.p",
+			status:   http.StatusOK,
+			promoted: []string{"logging/remote/nginx.p"},
+		},
+		{
+			name:     "invalid-token",
+			query:    "?category=logging&ns=remote&name=nginx.p",
+			tokenErr: ErrInvalidToken,
+			status:   http.StatusForbidden,
+		},
+		{
+			name:   "invalid-category",
+			query:  "?category=xxx&ns=remote&name=nginx.p",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "missing-name",
+			query:  "?category=logging&ns=remote",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "not-updated",
+			query:  "?category=logging&ns=remote&name=not-updated.p",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *T.T) {
+			m := &mockAPIPipelinePromote{tokenErr: tc.tokenErr}
+
+			router := gin.New()
+			router.POST("/promote", RawHTTPWrapper(nil, apiPipelinePromote, m))
+
+			ts := httptest.NewServer(router)
+			defer ts.Close()
+
+			resp, err := http.Post(ts.URL+"/promote"+tc.query, "", nil)
+			require.NoError(t, err)
+			defer resp.Body.Close() //nolint:errcheck
+
+			assert.Equal(t, tc.status, resp.StatusCode)
+			assert.Equal(t, tc.promoted, m.promoted)
+		})
+	}
+}
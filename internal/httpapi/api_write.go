@@ -332,7 +332,7 @@ func (wr *APIWriteResult) APIV1Write(req *http.Request) (err error) {
 		inputName = x
 	}
 
-	wr.FeedOptions = append(wr.FeedOptions, dkio.WithInputName(inputName))
+	wr.FeedOptions = append(wr.FeedOptions, dkio.WithInputName(inputName), dkio.WithRequestHandler(true))
 
 	wr.input = inputName
 
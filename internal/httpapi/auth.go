@@ -0,0 +1,332 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package httpapi
+
+import (
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	uhttp "github.com/GuanceCloud/cliutils/network/http"
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/gin-gonic/gin"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/config"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+// Scopes of API route groups.
+const (
+	ScopeAll           = "*"
+	ScopeAdmin         = "admin"
+	ScopePipelineDebug = "pipeline-debug"
+	ScopeWriteInput    = "write:input" // APIs registered by inputs, such as ddtrace/opentelemetry
+	scopeWritePrefix   = "write:"
+
+	auditSource = "datakit_api_audit"
+
+	keyFileCheckInterval = 10 * time.Second
+)
+
+type apiKey struct {
+	name     string
+	key      string
+	file     string
+	mtime    time.Time
+	scopes   []string
+	expireAt time.Time
+}
+
+// load (re)read the key from key file if it's modified.
+func (k *apiKey) load() error {
+	if k.file == "" {
+		return nil
+	}
+
+	fi, err := os.Stat(k.file)
+	if err != nil {
+		return err
+	}
+
+	if fi.ModTime().Equal(k.mtime) {
+		return nil
+	}
+
+	data, err := os.ReadFile(k.file)
+	if err != nil {
+		return err
+	}
+
+	k.key = strings.TrimSpace(config.DecodeENC(strings.TrimSpace(string(data))))
+	k.mtime = fi.ModTime()
+	return nil
+}
+
+type apiAuth struct {
+	mtx         sync.Mutex
+	keys        []*apiKey
+	lastChecked time.Time
+
+	clients      map[string][]string
+	loopbackAuth bool
+	inputRoutes  map[string]bool
+	feeder       dkio.Feeder
+}
+
+func newAPIAuth(c *config.APIAuthConfig, inputRoutes []string) (*apiAuth, error) {
+	a := &apiAuth{
+		clients:      map[string][]string{},
+		loopbackAuth: c.LoopbackAuth,
+		inputRoutes:  map[string]bool{},
+		feeder:       dkio.DefaultFeeder(),
+		lastChecked:  time.Now(),
+	}
+
+	for _, r := range inputRoutes {
+		a.inputRoutes[r] = true
+	}
+
+	for _, k := range c.Keys {
+		if k == nil {
+			continue
+		}
+
+		if len(k.Scopes) == 0 {
+			return nil, fmt.Errorf("API key %q: no scopes configured", k.Name)
+		}
+
+		key := &apiKey{
+			name:   k.Name,
+			key:    strings.TrimSpace(config.DecodeENC(k.Key)),
+			file:   k.KeyFile,
+			scopes: k.Scopes,
+		}
+
+		if k.ExpireAt != "" {
+			t, err := time.Parse(time.RFC3339, k.ExpireAt)
+			if err != nil {
+				return nil, fmt.Errorf("API key %q: invalid expire_at: %w", k.Name, err)
+			}
+			key.expireAt = t
+		}
+
+		if err := key.load(); err != nil {
+			return nil, fmt.Errorf("API key %q: %w", k.Name, err)
+		}
+
+		if key.key == "" {
+			return nil, fmt.Errorf("API key %q: empty key", k.Name)
+		}
+
+		a.keys = append(a.keys, key)
+	}
+
+	for _, cli := range c.Clients {
+		if cli == nil || cli.CommonName == "" {
+			continue
+		}
+		a.clients[cli.CommonName] = append(a.clients[cli.CommonName], cli.Scopes...)
+	}
+
+	return a, nil
+}
+
+// lookupKey returns the API key matched with key.
+func (a *apiAuth) lookupKey(key string) *apiKey {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	if time.Since(a.lastChecked) > keyFileCheckInterval {
+		for _, k := range a.keys {
+			if err := k.load(); err != nil {
+				l.Warnf("reload API key %q: %s, keep the old one", k.name, err)
+			}
+		}
+		a.lastChecked = time.Now()
+	}
+
+	var found *apiKey
+	for _, k := range a.keys {
+		// compare all keys in constant time
+		if subtle.ConstantTimeCompare([]byte(k.key), []byte(key)) == 1 && found == nil {
+			found = k
+		}
+	}
+
+	return found
+}
+
+// routeScope returns the scope required by the request, empty scope means no
+// authentication required.
+func (a *apiAuth) routeScope(c *gin.Context) string {
+	path := c.FullPath()
+
+	switch {
+	case path == "", path == "/v1/ping": // 404 or ping
+		return ""
+	case path == "/v1/write/:category":
+		return scopeWritePrefix + c.Param("category")
+	case path == "/v1/pipeline/debug":
+		return ScopePipelineDebug
+	case a.inputRoutes[path]:
+		if strings.HasPrefix(path, "/v1/write/") {
+			return scopeWritePrefix + strings.TrimPrefix(path, "/v1/write/")
+		}
+		return ScopeWriteInput
+	default:
+		return ScopeAdmin
+	}
+}
+
+func requestKey(c *gin.Context) string {
+	if v := c.GetHeader("X-API-Key"); v != "" {
+		return v
+	}
+
+	if v := c.GetHeader("Authorization"); strings.HasPrefix(v, "Bearer ") {
+		return strings.TrimSpace(strings.TrimPrefix(v, "Bearer "))
+	}
+
+	return ""
+}
+
+func scopeAllowed(scopes []string, want string) bool {
+	for _, s := range scopes {
+		switch {
+		case s == ScopeAll, s == want:
+			return true
+		case strings.HasSuffix(s, ":*") && strings.HasPrefix(want, strings.TrimSuffix(s, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate returns identity and scopes of the client, mTLS client
+// certificate are preferred to API key.
+func (a *apiAuth) authenticate(c *gin.Context) (string, []string, error) {
+	if tlsState := c.Request.TLS; tlsState != nil && len(tlsState.VerifiedChains) > 0 {
+		cn := tlsState.VerifiedChains[0][0].Subject.CommonName
+		if scopes, ok := a.clients[cn]; ok {
+			return "cert:" + cn, scopes, nil
+		}
+
+		if requestKey(c) == "" {
+			return "cert:" + cn, nil, uhttp.Errorf(ErrUnauthorized, "client certificate %q not allowed", cn)
+		}
+	}
+
+	key := requestKey(c)
+	if key == "" {
+		return "", nil, uhttp.Errorf(ErrUnauthorized, "API key or client certificate required")
+	}
+
+	k := a.lookupKey(key)
+	if k == nil {
+		return "", nil, uhttp.Errorf(ErrUnauthorized, "invalid API key")
+	}
+
+	if !k.expireAt.IsZero() && time.Now().After(k.expireAt) {
+		return "key:" + k.name, nil, uhttp.Errorf(ErrUnauthorized, "API key %q expired", k.name)
+	}
+
+	return "key:" + k.name, k.scopes, nil
+}
+
+func (a *apiAuth) middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		scope := a.routeScope(c)
+		if scope == "" || (!a.loopbackAuth && isLoopbackClient(c)) {
+			c.Next()
+			return
+		}
+
+		identity, scopes, err := a.authenticate(c)
+		if err == nil && !scopeAllowed(scopes, scope) {
+			err = uhttp.Errorf(ErrScopeDenied, "%s not allowed to access %s, scope %q required",
+				identity, c.Request.URL.Path, scope)
+		}
+
+		if err != nil {
+			a.audit(c, identity, scope, err)
+			uhttp.HttpErr(c, err)
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// denyAllMiddleware denies all APIs that require authentication, used when
+// the auth config is invalid, so that we never fail open.
+func denyAllMiddleware(inputRoutes []string, cause error) gin.HandlerFunc {
+	a := &apiAuth{inputRoutes: map[string]bool{}}
+	for _, r := range inputRoutes {
+		a.inputRoutes[r] = true
+	}
+
+	return func(c *gin.Context) {
+		scope := a.routeScope(c)
+		if scope == "" {
+			c.Next()
+			return
+		}
+
+		apiAuthDeniedVec.WithLabelValues(c.FullPath(), scope).Inc()
+		uhttp.HttpErr(c, uhttp.Errorf(ErrUnauthorized, "invalid HTTP API auth config: %s", cause))
+		c.Abort()
+	}
+}
+
+// audit feed denied request as logging point.
+func (a *apiAuth) audit(c *gin.Context, identity, scope string, err error) {
+	api := c.FullPath()
+	apiAuthDeniedVec.WithLabelValues(api, scope).Inc()
+
+	l.Warnf("API %s %s from %s(%s) denied: %s", c.Request.Method, c.Request.URL.Path, c.ClientIP(), identity, err)
+
+	var kvs point.KVs
+	kvs = kvs.AddTag("api", api).
+		AddTag("method", c.Request.Method).
+		AddTag("client_ip", c.ClientIP()).
+		AddTag("identity", identity).
+		AddTag("scope", scope).
+		AddTag("status", "warning").
+		Add("path", c.Request.URL.Path, false, false).
+		Add("user_agent", c.Request.UserAgent(), false, false).
+		Add("message", err.Error(), false, false)
+
+	pt := point.NewPointV2(auditSource, kvs, point.DefaultLoggingOptions()...)
+	if err := a.feeder.FeedV2(point.Logging, []*point.Point{pt},
+		dkio.WithInputName(auditSource),
+		dkio.WithNonBlocking(true)); err != nil {
+		l.Warnf("feed API audit log: %s", err)
+	}
+}
+
+// clientTLSConfig returns TLS config verifying client certificates with CA file.
+func clientTLSConfig(caFile string) (*tls.Config, error) {
+	data, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no valid certificate found in %s", caFile)
+	}
+
+	return &tls.Config{
+		ClientCAs:  pool,
+		ClientAuth: tls.VerifyClientCertIfGiven, // clients may use API keys instead
+		MinVersion: tls.VersionTLS12,
+	}, nil
+}
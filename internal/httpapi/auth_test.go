@@ -0,0 +1,234 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package httpapi
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	T "testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/config"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+func authRouter(t *T.T, conf *config.APIAuthConfig) (*gin.Engine, *dkio.MockedFeeder) {
+	t.Helper()
+
+	a, err := newAPIAuth(conf, []string{"/v1/write/rum", "/v0.4/traces"})
+	require.NoError(t, err)
+
+	feeder := dkio.NewMockedFeeder()
+	a.feeder = feeder
+
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+
+	router := gin.New()
+	router.Use(a.middleware())
+	router.GET("/v1/ping", ok)
+	router.POST("/v1/write/:category", ok)
+	router.POST("/v1/write/rum", ok)
+	router.POST("/v0.4/traces", ok)
+	router.POST("/v1/pipeline/debug", ok)
+	router.GET("/restart", ok)
+
+	return router, feeder
+}
+
+func TestAPIAuth(t *T.T) {
+	dir := t.TempDir()
+	keyFile := filepath.Join(dir, "key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("file-key-1\n"), 0o600))
+
+	router, feeder := authRouter(t, &config.APIAuthConfig{
+		Enable: true,
+		Keys: []*config.APIKey{
+			{Name: "logging", Key: "logging-key", Scopes: []string{"write:logging"}},
+			{Name: "writer", Key: "writer-key", Scopes: []string{"write:*"}},
+			{Name: "admin", Key: "admin-key", Scopes: []string{ScopeAll}},
+			{Name: "expired", Key: "expired-key", Scopes: []string{ScopeAll}, ExpireAt: "2020-01-01T00:00:00Z"},
+			{Name: "file", KeyFile: keyFile, Scopes: []string{ScopePipelineDebug}},
+		},
+		Clients: []*config.APIClientCert{
+			{CommonName: "collector", Scopes: []string{"write:metric"}},
+		},
+	})
+
+	do := func(method, path string, hdr map[string]string, cn string) int {
+		req := httptest.NewRequest(method, path, nil)
+		for k, v := range hdr {
+			req.Header.Set(k, v)
+		}
+
+		if cn != "" {
+			cert := &x509.Certificate{Subject: pkix.Name{CommonName: cn}}
+			req.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{cert}}}
+		}
+
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	bearer := func(k string) map[string]string { return map[string]string{"Authorization": "Bearer " + k} }
+
+	cases := []struct {
+		name   string
+		method string
+		path   string
+		hdr    map[string]string
+		cn     string
+		expect int
+	}{
+		{"ping-public", http.MethodGet, "/v1/ping", nil, "", http.StatusOK},
+		{"no-credential", http.MethodPost, "/v1/write/logging", nil, "", http.StatusUnauthorized},
+		{"invalid-key", http.MethodPost, "/v1/write/logging", bearer("bad"), "", http.StatusUnauthorized},
+		{"scoped-key", http.MethodPost, "/v1/write/logging", bearer("logging-key"), "", http.StatusOK},
+		{"x-api-key", http.MethodPost, "/v1/write/logging", map[string]string{"X-API-Key": "logging-key"}, "", http.StatusOK},
+		{"scope-denied", http.MethodPost, "/v1/write/metric", bearer("logging-key"), "", http.StatusForbidden},
+		{"wildcard-scope", http.MethodPost, "/v1/write/metric", bearer("writer-key"), "", http.StatusOK},
+		{"input-write-route", http.MethodPost, "/v1/write/rum", bearer("writer-key"), "", http.StatusOK},
+		{"input-route", http.MethodPost, "/v0.4/traces", bearer("writer-key"), "", http.StatusOK},
+		{"admin-denied", http.MethodGet, "/restart", bearer("writer-key"), "", http.StatusForbidden},
+		{"admin", http.MethodGet, "/restart", bearer("admin-key"), "", http.StatusOK},
+		{"expired", http.MethodGet, "/restart", bearer("expired-key"), "", http.StatusUnauthorized},
+		{"key-file", http.MethodPost, "/v1/pipeline/debug", bearer("file-key-1"), "", http.StatusOK},
+		{"client-cert", http.MethodPost, "/v1/write/metric", nil, "collector", http.StatusOK},
+		{"client-cert-denied", http.MethodPost, "/v1/write/logging", nil, "collector", http.StatusForbidden},
+		{"unknown-cert", http.MethodPost, "/v1/write/metric", nil, "other", http.StatusUnauthorized},
+		{"unknown-cert-with-key", http.MethodPost, "/v1/write/logging", bearer("logging-key"), "other", http.StatusOK},
+	}
+
+	denied := 0
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *T.T) {
+			assert.Equal(t, tc.expect, do(tc.method, tc.path, tc.hdr, tc.cn))
+		})
+
+		if tc.expect != http.StatusOK {
+			denied++
+		}
+	}
+
+	t.Run("audit", func(t *T.T) {
+		pts, err := feeder.NPoints(denied, time.Second)
+		require.NoError(t, err)
+
+		pt := pts[0]
+		assert.Equal(t, auditSource, pt.Name())
+		assert.Equal(t, "/v1/write/:category", pt.Get("api"))
+		assert.Equal(t, "write:logging", pt.Get("scope"))
+	})
+
+	t.Run("rotate-key-file", func(t *T.T) {
+		a, err := newAPIAuth(&config.APIAuthConfig{
+			Keys: []*config.APIKey{{Name: "file", KeyFile: keyFile, Scopes: []string{ScopeAll}}},
+		}, nil)
+		require.NoError(t, err)
+		assert.NotNil(t, a.lookupKey("file-key-1"))
+
+		require.NoError(t, os.WriteFile(keyFile, []byte("file-key-2"), 0o600))
+		mtime := time.Now().Add(time.Hour)
+		require.NoError(t, os.Chtimes(keyFile, mtime, mtime))
+
+		assert.NotNil(t, a.lookupKey("file-key-1"), "key file not re-checked yet")
+
+		a.lastChecked = time.Time{}
+		assert.Nil(t, a.lookupKey("file-key-1"))
+		assert.NotNil(t, a.lookupKey("file-key-2"))
+	})
+}
+
+func TestAPIAuthLoopback(t *T.T) {
+	conf := &config.APIAuthConfig{
+		Enable: true,
+		Keys:   []*config.APIKey{{Name: "k", Key: "key", Scopes: []string{ScopeAll}}},
+	}
+
+	req := func() *http.Request {
+		r := httptest.NewRequest(http.MethodGet, "/restart", nil)
+		r.RemoteAddr = "127.0.0.1:12345"
+		return r
+	}
+
+	router, _ := authRouter(t, conf)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req())
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	conf.LoopbackAuth = true
+	router, _ = authRouter(t, conf)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req())
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+func TestNewAPIAuth(t *T.T) {
+	_, err := newAPIAuth(&config.APIAuthConfig{
+		Keys: []*config.APIKey{{Name: "k", Key: "key"}},
+	}, nil)
+	assert.Error(t, err, "no scopes")
+
+	_, err = newAPIAuth(&config.APIAuthConfig{
+		Keys: []*config.APIKey{{Name: "k", Scopes: []string{ScopeAdmin}}},
+	}, nil)
+	assert.Error(t, err, "empty key")
+
+	_, err = newAPIAuth(&config.APIAuthConfig{
+		Keys: []*config.APIKey{{Name: "k", Key: "key", Scopes: []string{ScopeAdmin}, ExpireAt: "tomorrow"}},
+	}, nil)
+	assert.Error(t, err, "invalid expire_at")
+}
+
+func TestAPIAuthInvalidConfig(t *T.T) {
+	dir := t.TempDir()
+	badCA := filepath.Join(dir, "ca.pem")
+	require.NoError(t, os.WriteFile(badCA, []byte("not a cert"), 0o600))
+
+	cases := map[string]*config.APIAuthConfig{
+		"invalid-key": {
+			Enable: true,
+			Keys:   []*config.APIKey{{Name: "k", Key: "key"}}, // no scopes
+		},
+		"client-ca-without-https": {
+			Enable:   true,
+			Keys:     []*config.APIKey{{Name: "k", Key: "key", Scopes: []string{ScopeAll}}},
+			ClientCA: badCA,
+		},
+	}
+
+	for name, auth := range cases {
+		t.Run(name, func(t *T.T) {
+			hs := defaultHTTPServerConf()
+			hs.apiConfig.Auth = auth
+			r := setupRouter(hs)
+			assert.Nil(t, hs.clientTLS)
+
+			for _, req := range []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/restart", nil),
+				httptest.NewRequest(http.MethodPost, "/v1/write/logging", nil),
+			} {
+				req.RemoteAddr = "127.0.0.1:12345" // loopback clients denied too
+				req.Header.Set("X-API-Key", "key")
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, req)
+				assert.Equal(t, http.StatusUnauthorized, w.Code, req.URL.Path)
+			}
+
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/v1/ping", nil))
+			assert.Equal(t, http.StatusOK, w.Code)
+		})
+	}
+}
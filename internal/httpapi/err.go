@@ -42,6 +42,8 @@ var (
 
 	ErrPublicAccessDisabled = newErr(errors.New("public access disabled"), http.StatusForbidden)
 	ErrReachLimit           = newErr(errors.New("reach max API limit"), http.StatusTooManyRequests)
+	ErrUnauthorized         = newErr(errors.New("unauthorized"), http.StatusUnauthorized)
+	ErrScopeDenied          = newErr(errors.New("scope denied"), http.StatusForbidden)
 
 	ErrInvalidJSON = newErr(errors.New("invalid JSON"), http.StatusBadRequest)
 
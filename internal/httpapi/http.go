@@ -8,6 +8,7 @@ package httpapi
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
 	"io"
@@ -68,6 +69,8 @@ type httpServerConf struct {
 
 	pprof       bool
 	pprofListen string
+
+	clientTLS *tls.Config // set by setupRouter if client CA configured
 }
 
 func defaultHTTPServerConf() *httpServerConf {
@@ -203,9 +206,27 @@ func setupRouter(hs *httpServerConf) *gin.Engine {
 		router.NoRoute(page404)
 	}
 
-	addNewRegistedAPIs(hs)
-	// use whitelist config
-	if len(hs.apiConfig.PublicAPIs) != 0 {
+	inputRoutes := addNewRegistedAPIs(hs)
+
+	if auth := hs.apiConfig.Auth; auth != nil && auth.Enable {
+		// authenticated clients are granted by scopes instead of the whitelist
+		a, err := newAPIAuth(auth, inputRoutes)
+		if err == nil && auth.ClientCA != "" {
+			if !hs.apiConfig.HTTPSEnabled() {
+				err = fmt.Errorf("client_ca %s configured but HTTPS not enabled", auth.ClientCA)
+			} else if hs.clientTLS, err = clientTLSConfig(auth.ClientCA); err != nil {
+				err = fmt.Errorf("load client_ca: %w", err)
+			}
+		}
+
+		if err != nil {
+			l.Errorf("invalid HTTP API auth config: %s, all protected APIs denied", err)
+			router.Use(denyAllMiddleware(inputRoutes, err))
+		} else {
+			l.Infof("HTTP API auth enabled with %d keys and %d clients", len(a.keys), len(a.clients))
+			router.Use(a.middleware())
+		}
+	} else if len(hs.apiConfig.PublicAPIs) != 0 { // use whitelist config
 		router.Use(apiWhiteListMiddleware(hs.apiConfig.PublicAPIs))
 	}
 
@@ -222,6 +243,7 @@ func setupRouter(hs *httpServerConf) *gin.Engine {
 	router.DELETE("/v1/object/labels", RawHTTPWrapper(reqLimiter, apiDeleteObjectLabel, hs.dw))
 
 	router.POST("/v1/pipeline/debug", RawHTTPWrapper(reqLimiter, apiPipelineDebugHandler))
+	router.POST("/v1/pipeline/shadow/promote", RawHTTPWrapper(reqLimiter, apiPipelinePromote, &apiPipelinePromoteImpl{conf: hs}))
 
 	router.POST("/v1/lasterror", RawHTTPWrapper(reqLimiter, apiPutLastError, dkio.DefaultFeeder()))
 	router.GET("/restart", RawHTTPWrapper(reqLimiter, apiRestart, apiRestartImpl{conf: hs}))
@@ -306,6 +328,10 @@ func HTTPStart(hs *httpServerConf) {
 		srv.ReadTimeout = hs.timeout
 	}
 
+	if hs.clientTLS != nil {
+		srv.TLSConfig = hs.clientTLS
+	}
+
 	g.Go(func(ctx context.Context) error {
 		tryStartServer(hs, srv, true, semReload, semReloadCompleted)
 		l.Info("http server exit")
@@ -645,16 +671,14 @@ func ReloadDataKit(ctx context.Context) error {
 
 			case 3:
 				l.Info("before set pipelines")
-				if m, ok := plval.GetManager(); ok && m != nil {
-					// git
-					if config.GitHasEnabled() {
-						m.LoadScriptsFromWorkspace(manager.NSGitRepo,
-							filepath.Join(datakit.GitReposRepoFullPath, "pipeline"), nil)
-					}
-					// local
-					plPath := filepath.Join(datakit.InstallDir, "pipeline")
-					m.LoadScriptsFromWorkspace(manager.NSDefault, plPath, nil)
+				// git
+				if config.GitHasEnabled() {
+					plval.LoadScriptsFromWorkspace(manager.NSGitRepo,
+						filepath.Join(datakit.GitReposRepoFullPath, "pipeline"), nil)
 				}
+				// local
+				plPath := filepath.Join(datakit.InstallDir, "pipeline")
+				plval.LoadScriptsFromWorkspace(manager.NSDefault, plPath, nil)
 
 			case 4:
 				l.Info("before RunInputs")
@@ -17,6 +17,8 @@ var (
 	apiReqSizeVec *p8s.SummaryVec
 
 	apiGlobalTagsUpdatedVec *p8s.GaugeVec
+
+	apiAuthDeniedVec *p8s.CounterVec
 )
 
 func metricsSetup() {
@@ -91,11 +93,25 @@ func metricsSetup() {
 		},
 	)
 
+	apiAuthDeniedVec = p8s.NewCounterVec(
+		p8s.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "http",
+			Name:      "api_auth_denied_total",
+			Help:      "API requests denied by authentication",
+		},
+		[]string{
+			"api",
+			"scope",
+		},
+	)
+
 	metrics.MustRegister(
 		apiElapsedVec,
 		apiReqSizeVec,
 		apiCountVec,
 		apiGlobalTagsUpdatedVec,
+		apiAuthDeniedVec,
 	)
 }
 
@@ -63,6 +63,7 @@ func WithAPIConfig(c *config.APIConfig) option {
 			s.apiConfig.CloseIdleConnection = c.CloseIdleConnection
 			s.apiConfig.TLSConf = c.TLSConf
 			s.apiConfig.AllowedCORSOrigins = append(s.apiConfig.AllowedCORSOrigins, c.AllowedCORSOrigins...)
+			s.apiConfig.Auth = c.Auth
 		}
 	}
 }
@@ -71,7 +71,8 @@ func CleanHTTPHandler() {
 	httpRouteList = make(map[string]*httpRouteInfo)
 }
 
-func addNewRegistedAPIs(hs *httpServerConf) {
+// addNewRegistedAPIs add APIs registered by inputs to white list, and returns their paths.
+func addNewRegistedAPIs(hs *httpServerConf) (paths []string) {
 	httpConfMtx.Lock()
 	defer httpConfMtx.Unlock()
 
@@ -79,7 +80,10 @@ func addNewRegistedAPIs(hs *httpServerConf) {
 		l.Infof("add %q(method %q) to API white list", x.Path, x.Method)
 		// Because API whitelist defauled enabled, we should add new registered APIs to white list.
 		hs.apiConfig.PublicAPIs = append(hs.apiConfig.PublicAPIs, x.Path)
+		paths = append(paths, x.Path)
 	}
+
+	return paths
 }
 
 func applyRegistedAPIs(router *gin.Engine) {
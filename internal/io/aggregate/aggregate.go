@@ -0,0 +1,603 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package aggregate roll up metric points before they are uploaded.
+package aggregate
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/logger"
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+const (
+	FuncSum   = "sum"
+	FuncCount = "count"
+	FuncMin   = "min"
+	FuncMax   = "max"
+	FuncAvg   = "avg"
+	FuncLast  = "last"
+
+	rateSuffix = "_rate"
+
+	defaultWindow     = time.Minute
+	defaultMaxGroups  = 100000
+	defaultMaxSamples = 1024
+	defaultMaxSeries  = 100000
+
+	defaultAllowedLateness = time.Second * 10
+	defaultMaxFuture       = time.Minute
+
+	// rate state not updated within rateStateTTL windows will be removed.
+	rateStateTTL = 10
+)
+
+var (
+	l = logger.DefaultSLogger("aggregate")
+
+	defaultFuncs = []string{FuncSum, FuncCount, FuncMin, FuncMax, FuncAvg, FuncLast}
+)
+
+// Rule defines how to aggregate points of some measurements.
+type Rule struct {
+	// Measurements to aggregate.
+	Measurements []string `toml:"measurements"`
+
+	// Tag keys used to group points, tags not listed here are removed from aggregated points.
+	GroupBy []string `toml:"group_by"`
+
+	// Aggregate window, default 1m.
+	Window time.Duration `toml:"window"`
+
+	// Fields to aggregate, if empty, all numeric fields are aggregated.
+	Fields []string `toml:"fields"`
+
+	// Aggregate functions: sum/count/min/max/avg/last and percentiles like p50/p99.
+	Funcs []string `toml:"funcs"`
+
+	// Counter fields that converted to per-second rate(named as <field>_rate) before aggregation.
+	RateFields []string `toml:"rate_fields"`
+
+	// Drop raw points after aggregated.
+	DropRaw bool `toml:"drop_raw"`
+
+	// Measurement name of aggregated points, default the same as raw point.
+	Output string `toml:"output"`
+
+	id         int // index within rules, groups of different rules never merged
+	fields     map[string]bool
+	rateFields map[string]bool
+	funcs      []string
+	quantiles  map[string]float64
+}
+
+// Config configures aggregate stage in IO.
+type Config struct {
+	Enable bool `toml:"enable"`
+
+	// Max groups(in all windows) kept in memory, points exceed the limit are not aggregated.
+	MaxGroups int `toml:"max_groups"`
+
+	// Max samples kept for each field within a group to calculate percentiles.
+	MaxSamples int `toml:"max_samples"`
+
+	// Max counter series kept to calculate rate.
+	MaxSeries int `toml:"max_series"`
+
+	// Windows are flushed after allowed lateness since window end, points
+	// of flushed windows are not aggregated.
+	AllowedLateness time.Duration `toml:"allowed_lateness"`
+
+	// Points later than now + max future are not aggregated.
+	MaxFuture time.Duration `toml:"max_future"`
+
+	Rules []*Rule `toml:"rules"`
+}
+
+func (r *Rule) setup() error {
+	if len(r.Measurements) == 0 {
+		return fmt.Errorf("measurements required")
+	}
+
+	if r.Window <= 0 {
+		r.Window = defaultWindow
+	}
+
+	r.fields = map[string]bool{}
+	for _, f := range r.Fields {
+		r.fields[f] = true
+	}
+
+	r.rateFields = map[string]bool{}
+	for _, f := range r.RateFields {
+		r.rateFields[f] = true
+	}
+
+	funcs := r.Funcs
+	if len(funcs) == 0 {
+		funcs = defaultFuncs
+	}
+
+	r.funcs = r.funcs[:0]
+	r.quantiles = map[string]float64{}
+	for _, fn := range funcs {
+		switch fn {
+		case FuncSum, FuncCount, FuncMin, FuncMax, FuncAvg, FuncLast:
+		default:
+			q, err := parseQuantile(fn)
+			if err != nil {
+				return err
+			}
+			r.quantiles[fn] = q
+		}
+
+		r.funcs = append(r.funcs, fn)
+	}
+
+	return nil
+}
+
+// parseQuantile parse function like p99 or p99.9.
+func parseQuantile(fn string) (float64, error) {
+	if !strings.HasPrefix(fn, "p") {
+		return 0, fmt.Errorf("invalid aggregate function %q", fn)
+	}
+
+	x, err := strconv.ParseFloat(fn[1:], 64)
+	if err != nil || x <= 0 || x > 100 {
+		return 0, fmt.Errorf("invalid percentile function %q", fn)
+	}
+
+	return x / 100.0, nil
+}
+
+type fieldStat struct {
+	count int64
+	sum,
+	min,
+	max,
+	last float64
+	samples []float64
+}
+
+type group struct {
+	rule   *Rule
+	name   string
+	tags   point.KVs
+	start  time.Time
+	fields map[string]*fieldStat
+}
+
+type rateState struct {
+	val      float64
+	ts       time.Time
+	lastSeen time.Time
+}
+
+// Aggregator aggregate metric points within time windows.
+type Aggregator struct {
+	mtx sync.Mutex
+
+	conf   *Config
+	groups map[string]*group
+	series map[string]*rateState
+	rules  map[string][]*Rule // measurement -> rules
+
+	clock func() time.Time
+	rnd   *rand.Rand
+}
+
+// Option used to setup aggregator.
+type Option func(*Aggregator)
+
+// WithClock set clock of aggregator, used in testing.
+func WithClock(fn func() time.Time) Option {
+	return func(a *Aggregator) {
+		a.clock = fn
+	}
+}
+
+// New create an aggregator on conf.
+func New(conf *Config, opts ...Option) (*Aggregator, error) {
+	l = logger.SLogger("aggregate")
+
+	if conf.MaxGroups <= 0 {
+		conf.MaxGroups = defaultMaxGroups
+	}
+
+	if conf.MaxSamples <= 0 {
+		conf.MaxSamples = defaultMaxSamples
+	}
+
+	if conf.MaxSeries <= 0 {
+		conf.MaxSeries = defaultMaxSeries
+	}
+
+	if conf.AllowedLateness <= 0 {
+		conf.AllowedLateness = defaultAllowedLateness
+	}
+
+	if conf.MaxFuture <= 0 {
+		conf.MaxFuture = defaultMaxFuture
+	}
+
+	a := &Aggregator{
+		conf:   conf,
+		groups: map[string]*group{},
+		series: map[string]*rateState{},
+		rules:  map[string][]*Rule{},
+		clock:  time.Now,
+		rnd:    rand.New(rand.NewSource(1)), //nolint:gosec
+	}
+
+	for i, r := range conf.Rules {
+		r.id = i
+		if err := r.setup(); err != nil {
+			return nil, fmt.Errorf("invalid aggregate rule %d: %w", i, err)
+		}
+
+		for _, m := range r.Measurements {
+			a.rules[m] = append(a.rules[m], r)
+		}
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(a)
+		}
+	}
+
+	return a, nil
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int64:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
+
+func seriesKey(pt *point.Point, field string) string {
+	var sb strings.Builder
+	sb.WriteString(pt.Name())
+
+	tags := pt.Tags()
+	sort.Sort(tags)
+	for _, t := range tags {
+		sb.WriteByte(0)
+		sb.WriteString(t.Key)
+		sb.WriteByte('=')
+		sb.WriteString(t.GetS())
+	}
+
+	sb.WriteByte(0)
+	sb.WriteString(field)
+
+	return sb.String()
+}
+
+// rate calculate per-second rate of counter field. false returned if rate not available.
+func (a *Aggregator) rate(pt *point.Point, field string, val float64, now time.Time) (float64, bool) {
+	key := seriesKey(pt, field)
+	ts := pt.Time()
+
+	st, ok := a.series[key]
+	if !ok {
+		if len(a.series) >= a.conf.MaxSeries {
+			overflowVec.WithLabelValues(pt.Name(), "series").Inc()
+			return 0, false
+		}
+
+		a.series[key] = &rateState{val: val, ts: ts, lastSeen: now}
+		return 0, false
+	}
+
+	prevVal, prevTS := st.val, st.ts
+	st.val, st.ts, st.lastSeen = val, ts, now
+
+	if !ts.After(prevTS) || val < prevVal { // out-of-order or counter reset
+		return 0, false
+	}
+
+	return (val - prevVal) / ts.Sub(prevTS).Seconds(), true
+}
+
+func (fs *fieldStat) add(v float64, maxSamples int, rnd *rand.Rand) {
+	if fs.count == 0 {
+		fs.min, fs.max = v, v
+	} else {
+		fs.min = math.Min(fs.min, v)
+		fs.max = math.Max(fs.max, v)
+	}
+
+	fs.count++
+	fs.sum += v
+	fs.last = v
+
+	// reservoir sampling for percentiles
+	if len(fs.samples) < maxSamples {
+		fs.samples = append(fs.samples, v)
+	} else if j := rnd.Int63n(fs.count); j < int64(maxSamples) {
+		fs.samples[j] = v
+	}
+}
+
+// groupOf get the group of pt within rule r. Points are bucketed by their own
+// time, so late points land in the window they belong to if the window not
+// flushed yet. Points of flushed windows, or too far in the future, are not
+// aggregated.
+func (a *Aggregator) groupOf(r *Rule, pt *point.Point, now time.Time) *group {
+	ts := pt.Time()
+	if ts.IsZero() {
+		ts = now
+	}
+	start := ts.Truncate(r.Window)
+
+	if a.flushed(start.Add(r.Window), now) {
+		overflowVec.WithLabelValues(pt.Name(), "late").Inc()
+		return nil
+	}
+
+	if ts.After(now.Add(a.conf.MaxFuture)) {
+		overflowVec.WithLabelValues(pt.Name(), "future").Inc()
+		return nil
+	}
+
+	var (
+		sb   strings.Builder
+		tags point.KVs
+	)
+
+	sb.WriteString(pt.Name())
+	sb.WriteByte(0)
+	sb.WriteString(strconv.Itoa(r.id))
+	sb.WriteByte(0)
+	sb.WriteString(strconv.FormatInt(start.UnixNano(), 10))
+	sb.WriteByte(0)
+	sb.WriteString(strconv.FormatInt(int64(r.Window), 10))
+
+	for _, k := range r.GroupBy {
+		v := pt.GetTag(k)
+		if v == "" {
+			continue
+		}
+
+		tags = tags.AddTag(k, v)
+		sb.WriteByte(0)
+		sb.WriteString(k)
+		sb.WriteByte('=')
+		sb.WriteString(v)
+	}
+
+	key := sb.String()
+	if g, ok := a.groups[key]; ok {
+		return g
+	}
+
+	if len(a.groups) >= a.conf.MaxGroups {
+		overflowVec.WithLabelValues(pt.Name(), "group").Inc()
+		return nil
+	}
+
+	name := r.Output
+	if name == "" {
+		name = pt.Name()
+	}
+
+	g := &group{
+		rule:   r,
+		name:   name,
+		tags:   tags,
+		start:  start,
+		fields: map[string]*fieldStat{},
+	}
+
+	a.groups[key] = g
+	groupsGauge.Set(float64(len(a.groups)))
+
+	return g
+}
+
+// Process aggregate pts, points not dropped returned in kept, dropped points
+// are aggregated and can be released.
+func (a *Aggregator) Process(pts []*point.Point) (kept, dropped []*point.Point) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	now := a.clock()
+
+	for _, pt := range pts {
+		rules := a.rules[pt.Name()]
+		if len(rules) == 0 {
+			kept = append(kept, pt)
+			continue
+		}
+
+		// rate of counter field is calculated once for each point, and
+		// shared by all rules: updating rate state again within another
+		// rule gets no rate.
+		rates := map[string]*float64{}
+		rateOf := func(key string, v float64) (float64, bool) {
+			if x, ok := rates[key]; ok {
+				if x == nil {
+					return 0, false
+				}
+				return *x, true
+			}
+
+			x, ok := a.rate(pt, key, v, now)
+			if !ok {
+				rates[key] = nil
+				return 0, false
+			}
+			rates[key] = &x
+			return x, true
+		}
+
+		drop := false
+		for _, r := range rules {
+			g := a.groupOf(r, pt, now)
+			if g == nil { // overflow or out of time range: keep the raw point
+				continue
+			}
+
+			for _, kv := range pt.Fields() {
+				v, ok := toFloat(kv.Raw())
+				if !ok {
+					continue
+				}
+
+				key := kv.Key
+				switch {
+				case r.rateFields[key]:
+					if v, ok = rateOf(key, v); !ok {
+						continue
+					}
+					key += rateSuffix
+
+				case len(r.fields) > 0 && !r.fields[key]:
+					continue
+				}
+
+				fs, ok := g.fields[key]
+				if !ok {
+					fs = &fieldStat{}
+					g.fields[key] = fs
+				}
+
+				fs.add(v, a.conf.MaxSamples, a.rnd)
+			}
+
+			drop = drop || r.DropRaw
+		}
+
+		aggPtsVec.WithLabelValues(pt.Name()).Inc()
+
+		if drop {
+			dropped = append(dropped, pt)
+		} else {
+			kept = append(kept, pt)
+		}
+	}
+
+	return kept, dropped
+}
+
+func quantile(samples []float64, q float64) float64 {
+	arr := make([]float64, len(samples))
+	copy(arr, samples)
+	sort.Float64s(arr)
+
+	idx := int(math.Ceil(q*float64(len(arr)))) - 1
+	if idx < 0 {
+		idx = 0
+	}
+
+	return arr[idx]
+}
+
+func (g *group) point() *point.Point {
+	kvs := append(point.KVs{}, g.tags...)
+
+	for name, fs := range g.fields {
+		for _, fn := range g.rule.funcs {
+			key := name + "_" + fn
+
+			switch fn {
+			case FuncSum:
+				kvs = kvs.Add(key, fs.sum, false, true)
+			case FuncCount:
+				kvs = kvs.Add(key, fs.count, false, true)
+			case FuncMin:
+				kvs = kvs.Add(key, fs.min, false, true)
+			case FuncMax:
+				kvs = kvs.Add(key, fs.max, false, true)
+			case FuncAvg:
+				kvs = kvs.Add(key, fs.sum/float64(fs.count), false, true)
+			case FuncLast:
+				kvs = kvs.Add(key, fs.last, false, true)
+			default:
+				if q, ok := g.rule.quantiles[fn]; ok && len(fs.samples) > 0 {
+					kvs = kvs.Add(key, quantile(fs.samples, q), false, true)
+				}
+			}
+		}
+	}
+
+	return point.NewPointV2(g.name, kvs, append(point.DefaultMetricOptions(), point.WithTime(g.start))...)
+}
+
+// Flush build aggregated points for all windows ended before now.
+func (a *Aggregator) Flush() (pts []*point.Point) {
+	return a.flush(false)
+}
+
+// FlushAll build aggregated points for all windows, including windows not
+// ended yet. Used on exit, or raw points dropped within partial windows lost.
+func (a *Aggregator) FlushAll() (pts []*point.Point) {
+	return a.flush(true)
+}
+
+// flushed check if window ended at end should be (or has been) flushed.
+func (a *Aggregator) flushed(end, now time.Time) bool {
+	return !end.Add(a.conf.AllowedLateness).After(now)
+}
+
+func (a *Aggregator) flush(all bool) (pts []*point.Point) {
+	a.mtx.Lock()
+	defer a.mtx.Unlock()
+
+	now := a.clock()
+
+	var maxWindow time.Duration
+	for key, g := range a.groups {
+		if w := g.rule.Window; w > maxWindow {
+			maxWindow = w
+		}
+
+		if !all && !a.flushed(g.start.Add(g.rule.Window), now) {
+			continue
+		}
+
+		delete(a.groups, key)
+
+		if len(g.fields) == 0 {
+			continue
+		}
+
+		pts = append(pts, g.point())
+	}
+
+	groupsGauge.Set(float64(len(a.groups)))
+
+	// remove staled rate states.
+	if maxWindow > 0 {
+		for key, st := range a.series {
+			if now.Sub(st.lastSeen) > maxWindow*rateStateTTL {
+				delete(a.series, key)
+			}
+		}
+	}
+
+	if len(pts) > 0 {
+		l.Debugf("flush %d aggregated points", len(pts))
+	}
+
+	return pts
+}
@@ -0,0 +1,270 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package aggregate
+
+import (
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newPoint(name string, tags map[string]string, fields map[string]any, ts time.Time) *point.Point {
+	var kvs point.KVs
+	for k, v := range tags {
+		kvs = kvs.AddTag(k, v)
+	}
+
+	for k, v := range fields {
+		kvs = kvs.Add(k, v, false, true)
+	}
+
+	return point.NewPointV2(name, kvs, append(point.DefaultMetricOptions(), point.WithTime(ts))...)
+}
+
+func TestAggregate(t *T.T) {
+	t.Run("basic", func(t *T.T) {
+		clk := &fakeClock{now: time.Unix(1700000000, 0)}
+		a, err := New(&Config{
+			Rules: []*Rule{
+				{
+					Measurements: []string{"cpu"},
+					GroupBy:      []string{"host"},
+					Window:       10 * time.Second,
+					Funcs:        []string{"sum", "count", "min", "max", "avg", "last", "p50", "p99"},
+					DropRaw:      true,
+				},
+			},
+		}, WithClock(clk.Now))
+		require.NoError(t, err)
+
+		var pts []*point.Point
+		for i := 1; i <= 4; i++ {
+			pts = append(pts, newPoint("cpu",
+				map[string]string{"host": "h1", "cpu": "cpu0"},
+				map[string]any{"usage": float64(i), "name": "str-value"}, clk.now))
+		}
+
+		pts = append(pts, newPoint("mem", map[string]string{"host": "h1"}, map[string]any{"used": 1.0}, clk.now))
+
+		kept, dropped := a.Process(pts)
+		assert.Len(t, kept, 1)
+		assert.Equal(t, "mem", kept[0].Name())
+		assert.Len(t, dropped, 4)
+
+		// window not end
+		assert.Len(t, a.Flush(), 0)
+
+		// window ended, but within allowed lateness
+		clk.now = clk.now.Add(10 * time.Second)
+		assert.Len(t, a.Flush(), 0)
+
+		clk.now = clk.now.Add(defaultAllowedLateness)
+		res := a.Flush()
+		require.Len(t, res, 1)
+
+		pt := res[0]
+		assert.Equal(t, "cpu", pt.Name())
+		assert.Equal(t, "h1", pt.GetTag("host"))
+		assert.Equal(t, "", pt.GetTag("cpu")) // tags not in group_by removed
+		assert.Equal(t, 10.0, pt.Get("usage_sum"))
+		assert.Equal(t, int64(4), pt.Get("usage_count"))
+		assert.Equal(t, 1.0, pt.Get("usage_min"))
+		assert.Equal(t, 4.0, pt.Get("usage_max"))
+		assert.Equal(t, 2.5, pt.Get("usage_avg"))
+		assert.Equal(t, 4.0, pt.Get("usage_last"))
+		assert.Equal(t, 2.0, pt.Get("usage_p50"))
+		assert.Equal(t, 4.0, pt.Get("usage_p99"))
+		assert.Nil(t, pt.Get("name_sum"))
+		assert.Equal(t, time.Unix(1700000000, 0).Truncate(10*time.Second).UnixNano(), pt.Time().UnixNano())
+
+		assert.Len(t, a.Flush(), 0)
+	})
+
+	t.Run("rate", func(t *T.T) {
+		clk := &fakeClock{now: time.Unix(1700000000, 0)}
+		a, err := New(&Config{
+			Rules: []*Rule{
+				{
+					Measurements: []string{"net"},
+					GroupBy:      []string{"host"},
+					Window:       time.Minute,
+					Fields:       []string{"no-such-field"},
+					RateFields:   []string{"bytes_sent"},
+					Funcs:        []string{"sum", "max"},
+					Output:       "net_agg",
+				},
+			},
+		}, WithClock(clk.Now))
+		require.NoError(t, err)
+
+		ts := clk.now
+		pts := []*point.Point{
+			newPoint("net", map[string]string{"host": "h1", "iface": "eth0"}, map[string]any{"bytes_sent": int64(100)}, ts),
+			newPoint("net", map[string]string{"host": "h1", "iface": "eth1"}, map[string]any{"bytes_sent": int64(100)}, ts),
+			newPoint("net", map[string]string{"host": "h1", "iface": "eth0"}, map[string]any{"bytes_sent": int64(200)}, ts.Add(10*time.Second)),
+			newPoint("net", map[string]string{"host": "h1", "iface": "eth1"}, map[string]any{"bytes_sent": int64(400)}, ts.Add(10*time.Second)),
+			// counter reset, no rate
+			newPoint("net", map[string]string{"host": "h1", "iface": "eth1"}, map[string]any{"bytes_sent": int64(10)}, ts.Add(20*time.Second)),
+		}
+
+		kept, dropped := a.Process(pts)
+		assert.Len(t, kept, 5)
+		assert.Len(t, dropped, 0)
+
+		clk.now = clk.now.Add(time.Minute)
+		res := a.Flush()
+		require.Len(t, res, 1)
+
+		pt := res[0]
+		assert.Equal(t, "net_agg", pt.Name())
+		assert.Equal(t, 40.0, pt.Get("bytes_sent_rate_sum"))
+		assert.Equal(t, 30.0, pt.Get("bytes_sent_rate_max"))
+		assert.Nil(t, pt.Get("bytes_sent_sum"))
+	})
+
+	t.Run("max-groups", func(t *T.T) {
+		clk := &fakeClock{now: time.Unix(1700000000, 0)}
+		a, err := New(&Config{
+			MaxGroups: 1,
+			Rules: []*Rule{
+				{Measurements: []string{"cpu"}, GroupBy: []string{"host"}, DropRaw: true},
+			},
+		}, WithClock(clk.Now))
+		require.NoError(t, err)
+
+		kept, dropped := a.Process([]*point.Point{
+			newPoint("cpu", map[string]string{"host": "h1"}, map[string]any{"usage": 1.0}, clk.now),
+			newPoint("cpu", map[string]string{"host": "h2"}, map[string]any{"usage": 1.0}, clk.now),
+		})
+
+		// point of h2 exceed max groups and kept as raw point
+		require.Len(t, kept, 1)
+		assert.Equal(t, "h2", kept[0].GetTag("host"))
+		assert.Len(t, dropped, 1)
+	})
+
+	t.Run("max-samples", func(t *T.T) {
+		clk := &fakeClock{now: time.Unix(1700000000, 0)}
+		a, err := New(&Config{
+			MaxSamples: 8,
+			Rules: []*Rule{
+				{Measurements: []string{"cpu"}, Funcs: []string{"count", "p90"}},
+			},
+		}, WithClock(clk.Now))
+		require.NoError(t, err)
+
+		var pts []*point.Point
+		for i := 0; i < 100; i++ {
+			pts = append(pts, newPoint("cpu", nil, map[string]any{"usage": float64(i)}, clk.now))
+		}
+
+		a.Process(pts)
+
+		for _, g := range a.groups {
+			assert.Len(t, g.fields["usage"].samples, 8)
+		}
+
+		clk.now = clk.now.Add(time.Minute)
+		res := a.Flush()
+		require.Len(t, res, 1)
+		assert.Equal(t, int64(100), res[0].Get("usage_count"))
+		assert.NotNil(t, res[0].Get("usage_p90"))
+	})
+
+	t.Run("point-time", func(t *T.T) {
+		win := time.Unix(1700000000, 0).Truncate(time.Minute)
+		clk := &fakeClock{now: win.Add(10 * time.Second)}
+		a, err := New(&Config{
+			AllowedLateness: 30 * time.Second,
+			Rules:           []*Rule{{Measurements: []string{"cpu"}, Funcs: []string{"sum"}, DropRaw: true}},
+		}, WithClock(clk.Now))
+		require.NoError(t, err)
+
+		kept, dropped := a.Process([]*point.Point{
+			// late point within allowed lateness
+			newPoint("cpu", nil, map[string]any{"usage": 1.0}, win.Add(-time.Second)),
+			newPoint("cpu", nil, map[string]any{"usage": 2.0}, clk.now),
+			// too late
+			newPoint("cpu", nil, map[string]any{"usage": 3.0}, win.Add(-time.Minute-time.Second)),
+			// too far in the future
+			newPoint("cpu", nil, map[string]any{"usage": 4.0}, clk.now.Add(2*time.Minute)),
+		})
+		assert.Len(t, dropped, 2)
+		require.Len(t, kept, 2, "points out of time range not aggregated")
+		assert.Equal(t, 3.0, kept[0].Get("usage"))
+		assert.Equal(t, 4.0, kept[1].Get("usage"))
+
+		assert.Len(t, a.Flush(), 0)
+
+		clk.now = win.Add(30 * time.Second)
+		res := a.Flush()
+		require.Len(t, res, 1)
+		assert.Equal(t, 1.0, res[0].Get("usage_sum"))
+		assert.Equal(t, win.Add(-time.Minute).UnixNano(), res[0].Time().UnixNano())
+
+		// point of flushed window not aggregated again
+		kept, _ = a.Process([]*point.Point{
+			newPoint("cpu", nil, map[string]any{"usage": 5.0}, win.Add(-time.Second)),
+		})
+		assert.Len(t, kept, 1)
+		assert.Len(t, a.Flush(), 0)
+
+		// partial window flushed on exit
+		res = a.FlushAll()
+		require.Len(t, res, 1)
+		assert.Equal(t, 2.0, res[0].Get("usage_sum"))
+		assert.Empty(t, a.groups)
+	})
+
+	t.Run("rate-multi-rules", func(t *T.T) {
+		clk := &fakeClock{now: time.Unix(1700000000, 0).Truncate(time.Minute)}
+		a, err := New(&Config{
+			Rules: []*Rule{
+				{Measurements: []string{"net"}, RateFields: []string{"bytes"}, Funcs: []string{"sum"}, Output: "net_1"},
+				{Measurements: []string{"net"}, RateFields: []string{"bytes"}, Funcs: []string{"max"}, Output: "net_2"},
+			},
+		}, WithClock(clk.Now))
+		require.NoError(t, err)
+
+		a.Process([]*point.Point{
+			newPoint("net", nil, map[string]any{"bytes": int64(100)}, clk.now),
+			newPoint("net", nil, map[string]any{"bytes": int64(200)}, clk.now.Add(10*time.Second)),
+		})
+
+		res := a.FlushAll()
+		require.Len(t, res, 2)
+		for _, pt := range res {
+			switch pt.Name() {
+			case "net_1":
+				assert.Equal(t, 10.0, pt.Get("bytes_rate_sum"))
+			case "net_2":
+				assert.Equal(t, 10.0, pt.Get("bytes_rate_max"))
+			default:
+				t.Errorf("unexpected point %s", pt.Name())
+			}
+		}
+	})
+
+	t.Run("invalid-rule", func(t *T.T) {
+		_, err := New(&Config{Rules: []*Rule{{Measurements: []string{"cpu"}, Funcs: []string{"p101"}}}})
+		assert.Error(t, err)
+
+		_, err = New(&Config{Rules: []*Rule{{Measurements: []string{"cpu"}, Funcs: []string{"median"}}}})
+		assert.Error(t, err)
+
+		_, err = New(&Config{Rules: []*Rule{{}}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,63 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package aggregate
+
+import (
+	"github.com/GuanceCloud/cliutils/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	aggPtsVec,
+	overflowVec *prometheus.CounterVec
+
+	groupsGauge prometheus.Gauge
+)
+
+func setupMetrics() {
+	aggPtsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "aggregate_point_total",
+			Help:      "Raw points aggregated",
+		},
+		[]string{"measurement"},
+	)
+
+	overflowVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "aggregate_overflow_total",
+			Help:      "Points not aggregated(or rate not calculated) due to memory limits, or point time out of allowed range",
+		},
+		[]string{
+			"measurement",
+			"type",
+		},
+	)
+
+	groupsGauge = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "aggregate_groups",
+			Help:      "Aggregate groups kept in memory",
+		},
+	)
+
+	metrics.MustRegister(
+		aggPtsVec,
+		overflowVec,
+		groupsGauge,
+	)
+}
+
+//nolint:gochecknoinits
+func init() {
+	setupMetrics()
+}
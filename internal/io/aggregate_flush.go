@@ -0,0 +1,54 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package io
+
+import (
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+)
+
+const aggregateFlushInterval = time.Second
+
+// runAggregator periodically upload aggregated metric points.
+func (x *dkIO) runAggregator() {
+	tick := time.NewTicker(aggregateFlushInterval)
+	defer tick.Stop()
+
+	flush := func(all bool) {
+		var pts []*point.Point
+		if all {
+			pts = x.aggregator.FlushAll()
+		} else {
+			pts = x.aggregator.Flush()
+		}
+
+		if len(pts) == 0 {
+			return
+		}
+
+		if err := x.doCompact(pts, point.Metric); err != nil {
+			log.Warnf("post %d aggregated points failed: %s, ignored", len(pts), err)
+		}
+
+		datakit.PutbackPoints(pts...)
+	}
+
+	log.Infof("run aggregator on %s", point.Metric)
+	for {
+		select {
+		case <-tick.C:
+			flush(false)
+
+		case <-datakit.Exit.Wait():
+			// flush partial windows, or points dropped(drop_raw) within them lost.
+			flush(true)
+			return
+		}
+	}
+}
@@ -0,0 +1,371 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package cardinality limit unique metric series of each measurement.
+package cardinality
+
+import (
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/logger"
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
+)
+
+// Actions applied on new series when the limit exceeded.
+const (
+	ActionDrop     = "drop"      // drop points of new series
+	ActionStripTag = "strip_tag" // remove the offending tag
+	ActionOverflow = "overflow"  // replace value of the offending tag with overflow marker
+
+	defaultMaxSeries     = 10000
+	defaultResetInterval = time.Hour
+	defaultOverflowValue = "__overflow__"
+
+	source = "cardinality"
+)
+
+var defaultProtectedTags = []string{"host"}
+
+var l = logger.DefaultSLogger("cardinality")
+
+// Limit configure series limit of a measurement.
+type Limit struct {
+	MaxSeries int    `toml:"max_series"`
+	Action    string `toml:"action"`
+}
+
+// Config configures cardinality guard in IO.
+type Config struct {
+	Enable bool `toml:"enable"`
+
+	// Default limit for all measurements.
+	MaxSeries int    `toml:"max_series"`
+	Action    string `toml:"action"`
+
+	// Value used to replace the offending tag value under action overflow.
+	OverflowValue string `toml:"overflow_value"`
+
+	// Tags never stripped or replaced, points are dropped if no other tag to
+	// strip or replace. Default host.
+	ProtectedTags []string `toml:"protected_tags"`
+
+	// All tracked series are cleared on each interval.
+	ResetInterval time.Duration `toml:"reset_interval"`
+
+	// Per-measurement limits, overwrite the default limit.
+	Measurements map[string]*Limit `toml:"measurements"`
+
+	protected map[string]bool
+}
+
+func checkAction(a string) error {
+	switch a {
+	case "", ActionDrop, ActionStripTag, ActionOverflow:
+		return nil
+	default:
+		return fmt.Errorf("invalid cardinality action %q", a)
+	}
+}
+
+func (c *Config) setup() error {
+	if c.MaxSeries <= 0 {
+		c.MaxSeries = defaultMaxSeries
+	}
+
+	if c.Action == "" {
+		c.Action = ActionDrop
+	}
+
+	if c.OverflowValue == "" {
+		c.OverflowValue = defaultOverflowValue
+	}
+
+	if c.ResetInterval <= 0 {
+		c.ResetInterval = defaultResetInterval
+	}
+
+	if c.ProtectedTags == nil {
+		c.ProtectedTags = defaultProtectedTags
+	}
+
+	c.protected = map[string]bool{}
+	for _, k := range c.ProtectedTags {
+		c.protected[k] = true
+	}
+
+	if err := checkAction(c.Action); err != nil {
+		return err
+	}
+
+	for m, x := range c.Measurements {
+		if x == nil {
+			return fmt.Errorf("empty limit on measurement %q", m)
+		}
+
+		if err := checkAction(x.Action); err != nil {
+			return fmt.Errorf("%w on measurement %q", err, m)
+		}
+	}
+
+	return nil
+}
+
+func (c *Config) limitOf(measurement string) *Limit {
+	lmt := &Limit{MaxSeries: c.MaxSeries, Action: c.Action}
+
+	if x, ok := c.Measurements[measurement]; ok {
+		if x.MaxSeries > 0 {
+			lmt.MaxSeries = x.MaxSeries
+		}
+
+		if x.Action != "" {
+			lmt.Action = x.Action
+		}
+	}
+
+	return lmt
+}
+
+type measurementState struct {
+	limit  *Limit
+	series map[uint64]struct{}
+
+	// series with offending tag stripped or replaced, bounded by limit separately.
+	overflow map[uint64]struct{}
+
+	// estimated distinct values of each tag key, used to find out the offending tag.
+	tagValues map[string]*hll
+
+	// tags already reported within current reset interval.
+	reported map[string]bool
+}
+
+// Guard track unique series of each measurement within a bounded set.
+type Guard struct {
+	mtx sync.Mutex
+
+	conf      *Config
+	states    map[string]*measurementState
+	lastReset time.Time
+
+	clock func() time.Time
+}
+
+// Option used to setup guard.
+type Option func(*Guard)
+
+// WithClock set clock of guard, used in testing.
+func WithClock(fn func() time.Time) Option {
+	return func(g *Guard) {
+		g.clock = fn
+	}
+}
+
+// New create a cardinality guard on conf.
+func New(conf *Config, opts ...Option) (*Guard, error) {
+	l = logger.SLogger("cardinality")
+
+	if err := conf.setup(); err != nil {
+		return nil, err
+	}
+
+	g := &Guard{
+		conf:   conf,
+		states: map[string]*measurementState{},
+		clock:  time.Now,
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(g)
+		}
+	}
+
+	g.lastReset = g.clock()
+
+	return g, nil
+}
+
+func hashOf(s ...string) uint64 {
+	h := fnv.New64a()
+	for _, x := range s {
+		h.Write([]byte(x)) //nolint:errcheck,gosec
+		h.Write([]byte{0}) //nolint:errcheck,gosec
+	}
+	return h.Sum64()
+}
+
+// seriesHash calculate series hash of pt. If replace set, value of tag key
+// replaced with *replace, and if *replace is empty the tag is removed.
+func seriesHash(pt *point.Point, key string, replace *string) uint64 {
+	tags := pt.Tags()
+	sort.Sort(tags)
+
+	arr := []string{pt.Name()}
+	for _, t := range tags {
+		v := t.GetS()
+		if replace != nil && t.Key == key {
+			if *replace == "" {
+				continue
+			}
+			v = *replace
+		}
+
+		arr = append(arr, t.Key, v)
+	}
+
+	return hashOf(arr...)
+}
+
+func (g *Guard) stateOf(measurement string) *measurementState {
+	if st, ok := g.states[measurement]; ok {
+		return st
+	}
+
+	st := &measurementState{
+		limit:     g.conf.limitOf(measurement),
+		series:    map[uint64]struct{}{},
+		overflow:  map[uint64]struct{}{},
+		tagValues: map[string]*hll{},
+		reported:  map[string]bool{},
+	}
+
+	g.states[measurement] = st
+	return st
+}
+
+// observe add tag values of pt into distinct value sketches.
+func (st *measurementState) observe(pt *point.Point) {
+	for _, t := range pt.Tags() {
+		vals, ok := st.tagValues[t.Key]
+		if !ok {
+			vals = &hll{}
+			st.tagValues[t.Key] = vals
+		}
+
+		vals.add(hashOf(t.GetS()))
+	}
+}
+
+// offendingTag find out the tag key of pt with most distinct values, protected
+// tags are never selected. Empty returned if no tag selected.
+func (st *measurementState) offendingTag(pt *point.Point, protected map[string]bool) string {
+	var (
+		key string
+		max uint64
+	)
+
+	for _, t := range pt.Tags() {
+		vals, ok := st.tagValues[t.Key]
+		if !ok || protected[t.Key] {
+			continue
+		}
+
+		if n := vals.count(); key == "" || n > max || (n == max && t.Key < key) {
+			key, max = t.Key, n
+		}
+	}
+
+	return key
+}
+
+// admit add series h into tracked set, false returned if the limit exceeded.
+func (st *measurementState) admit(set map[uint64]struct{}, h uint64) bool {
+	if _, ok := set[h]; ok {
+		return true
+	}
+
+	if len(set) >= st.limit.MaxSeries {
+		return false
+	}
+
+	set[h] = struct{}{}
+	return true
+}
+
+func (g *Guard) report(input string, st *measurementState, measurement, key string, now time.Time) {
+	if st.reported[key] {
+		return
+	}
+
+	st.reported[key] = true
+
+	msg := fmt.Sprintf("measurement %q exceed max series %d, offending tag %q, action %s",
+		measurement, st.limit.MaxSeries, key, st.limit.Action)
+	l.Warnf("%s on input %q", msg, input)
+
+	cat := point.Metric.String()
+	metrics.ErrCountVec.WithLabelValues(source, cat).Inc()
+	metrics.LastErrVec.WithLabelValues(input, source, cat, msg).Set(float64(now.Unix()))
+}
+
+func (g *Guard) resetIfExpired(now time.Time) {
+	if now.Sub(g.lastReset) < g.conf.ResetInterval {
+		return
+	}
+
+	g.states = map[string]*measurementState{}
+	g.lastReset = now
+	seriesVec.Reset()
+}
+
+// Process check pts from input, points exceed the limit are dropped or modified
+// according to the limit action.
+func (g *Guard) Process(input string, pts []*point.Point) (kept, dropped []*point.Point) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+
+	now := g.clock()
+	g.resetIfExpired(now)
+
+	for _, pt := range pts {
+		name := pt.Name()
+		st := g.stateOf(name)
+
+		st.observe(pt)
+		if st.admit(st.series, seriesHash(pt, "", nil)) {
+			kept = append(kept, pt)
+			continue
+		}
+
+		key := st.offendingTag(pt, g.conf.protected)
+
+		exceedVec.WithLabelValues(name, key, st.limit.Action).Inc()
+		g.report(input, st, name, key, now)
+
+		var replace string
+		switch st.limit.Action {
+		case ActionStripTag:
+			if key != "" && st.admit(st.overflow, seriesHash(pt, key, &replace)) {
+				pt.Del(key)
+				kept = append(kept, pt)
+				continue
+			}
+
+		case ActionOverflow:
+			replace = g.conf.OverflowValue
+			if key != "" && st.admit(st.overflow, seriesHash(pt, key, &replace)) {
+				pt.MustAddTag(key, replace)
+				kept = append(kept, pt)
+				continue
+			}
+		}
+
+		droppedVec.WithLabelValues(name).Inc()
+		dropped = append(dropped, pt)
+	}
+
+	for name, st := range g.states {
+		seriesVec.WithLabelValues(name).Set(float64(len(st.series)))
+	}
+
+	return kept, dropped
+}
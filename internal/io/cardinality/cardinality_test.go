@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package cardinality
+
+import (
+	"fmt"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func reqPoints(name string, n int) (pts []*point.Point) {
+	return hostReqPoints(name, n, false)
+}
+
+func hostReqPoints(name string, n int, randHost bool) (pts []*point.Point) {
+	for i := 0; i < n; i++ {
+		host := "h1"
+		if randHost {
+			host = fmt.Sprintf("h%d", i)
+		}
+
+		var kvs point.KVs
+		kvs = kvs.AddTag("host", host).
+			AddTag("request_id", fmt.Sprintf("req-%d", i)).
+			Add("cost", int64(i), false, true)
+
+		pts = append(pts, point.NewPointV2(name, kvs, point.DefaultMetricOptions()...))
+	}
+
+	return pts
+}
+
+func TestHLL(t *T.T) {
+	for _, n := range []int{10, 1000, 100000} {
+		x := &hll{}
+		for i := 0; i < n; i++ {
+			x.add(hashOf(fmt.Sprintf("value-%d", i)))
+		}
+
+		assert.InEpsilon(t, n, x.count(), 0.1, "n=%d", n)
+	}
+}
+
+func TestGuard(t *T.T) {
+	t.Run("drop", func(t *T.T) {
+		g, err := New(&Config{MaxSeries: 3})
+		require.NoError(t, err)
+
+		kept, dropped := g.Process("some-input", reqPoints("http", 10))
+		assert.Len(t, kept, 3)
+		assert.Len(t, dropped, 7)
+
+		// known series still accepted
+		kept, dropped = g.Process("some-input", reqPoints("http", 2))
+		assert.Len(t, kept, 2)
+		assert.Len(t, dropped, 0)
+
+		// other measurement not affected
+		kept, _ = g.Process("some-input", reqPoints("rpc", 3))
+		assert.Len(t, kept, 3)
+
+		assert.True(t, g.states["http"].reported["request_id"])
+	})
+
+	t.Run("strip-tag", func(t *T.T) {
+		g, err := New(&Config{
+			MaxSeries: 3,
+			Measurements: map[string]*Limit{
+				"http": {Action: ActionStripTag},
+			},
+		})
+		require.NoError(t, err)
+
+		kept, dropped := g.Process("some-input", reqPoints("http", 10))
+		assert.Len(t, kept, 10)
+		assert.Len(t, dropped, 0)
+
+		for _, pt := range kept[3:] {
+			assert.Equal(t, "", pt.GetTag("request_id"))
+			assert.Equal(t, "h1", pt.GetTag("host"))
+		}
+	})
+
+	t.Run("overflow", func(t *T.T) {
+		g, err := New(&Config{MaxSeries: 3, Action: ActionOverflow, OverflowValue: "other"})
+		require.NoError(t, err)
+
+		kept, dropped := g.Process("some-input", reqPoints("http", 10))
+		assert.Len(t, kept, 10)
+		assert.Len(t, dropped, 0)
+
+		for _, pt := range kept[3:] {
+			assert.Equal(t, "other", pt.GetTag("request_id"))
+		}
+
+		// the overflow series are limited too
+		g, err = New(&Config{MaxSeries: 1, Action: ActionOverflow})
+		require.NoError(t, err)
+
+		kept, dropped = g.Process("some-input", hostReqPoints("http", 10, true))
+		assert.Len(t, kept, 2)
+		assert.Len(t, dropped, 8)
+	})
+
+	t.Run("distinct-count-not-capped", func(t *T.T) {
+		g, err := New(&Config{MaxSeries: 3, Action: ActionStripTag})
+		require.NoError(t, err)
+
+		var pts []*point.Point
+		for i := 0; i < 100; i++ {
+			var kvs point.KVs
+			kvs = kvs.AddTag("aaa", fmt.Sprintf("a-%d", i%10)).
+				AddTag("zzz", fmt.Sprintf("z-%d", i)).
+				Add("cost", int64(i), false, true)
+			pts = append(pts, point.NewPointV2("http", kvs, point.DefaultMetricOptions()...))
+		}
+
+		g.Process("some-input", pts)
+
+		// both tags exceed max series, but zzz has more distinct values
+		assert.Equal(t, "zzz", g.states["http"].offendingTag(pts[99], g.conf.protected))
+	})
+
+	t.Run("protected-tags", func(t *T.T) {
+		g, err := New(&Config{MaxSeries: 3, Action: ActionStripTag})
+		require.NoError(t, err)
+
+		// host is protected by default, request_id stripped
+		pts := hostReqPoints("http", 10, true)
+		kept, _ := g.Process("some-input", pts)
+		for _, pt := range kept {
+			assert.NotEqual(t, "", pt.GetTag("host"))
+		}
+		assert.Equal(t, "request_id", g.states["http"].offendingTag(pts[9], g.conf.protected))
+
+		// only protected tag left, points dropped
+		pts = pts[:0]
+		for i := 0; i < 10; i++ {
+			pts = append(pts, point.NewPointV2("cpu",
+				point.KVs{}.AddTag("host", fmt.Sprintf("h%d", i)).Add("usage", 1.0, false, true),
+				point.DefaultMetricOptions()...))
+		}
+
+		kept, dropped := g.Process("some-input", pts)
+		assert.Len(t, kept, 3)
+		assert.Len(t, dropped, 7)
+	})
+
+	t.Run("reset", func(t *T.T) {
+		now := time.Unix(1700000000, 0)
+		g, err := New(&Config{MaxSeries: 3, ResetInterval: time.Minute}, WithClock(func() time.Time { return now }))
+		require.NoError(t, err)
+
+		_, dropped := g.Process("some-input", reqPoints("http", 5))
+		assert.Len(t, dropped, 2)
+
+		now = now.Add(time.Minute)
+		_, dropped = g.Process("some-input", reqPoints("http", 3))
+		assert.Len(t, dropped, 0)
+	})
+
+	t.Run("invalid", func(t *T.T) {
+		_, err := New(&Config{Action: "no-such-action"})
+		assert.Error(t, err)
+
+		_, err = New(&Config{Measurements: map[string]*Limit{"http": {Action: "no-such-action"}}})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,64 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package cardinality
+
+import (
+	"math"
+	"math/bits"
+)
+
+// hllPrecision is the number of register index bits, 2^10 registers(1KB) give
+// about 3% standard error.
+const hllPrecision = 10
+
+// hll is a HyperLogLog sketch estimating distinct values of a tag within fixed
+// memory, no matter how many values there are.
+type hll struct {
+	registers [1 << hllPrecision]uint8
+}
+
+// mix is the splitmix64 finalizer, spread bits of FNV hash over all 64 bits.
+func mix(h uint64) uint64 {
+	h ^= h >> 30
+	h *= 0xbf58476d1ce4e5b9
+	h ^= h >> 27
+	h *= 0x94d049bb133111eb
+	h ^= h >> 31
+	return h
+}
+
+func (x *hll) add(h uint64) {
+	h = mix(h)
+	idx := h >> (64 - hllPrecision)
+	rank := uint8(bits.LeadingZeros64(h<<hllPrecision|1<<(hllPrecision-1)) + 1)
+
+	if rank > x.registers[idx] {
+		x.registers[idx] = rank
+	}
+}
+
+func (x *hll) count() uint64 {
+	const m = float64(1 << hllPrecision)
+
+	var (
+		sum   float64
+		zeros int
+	)
+
+	for _, r := range x.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	est := 0.7213 / (1 + 1.079/m) * m * m / sum
+	if est <= 2.5*m && zeros > 0 { // small range correction: linear counting
+		est = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(est + 0.5)
+}
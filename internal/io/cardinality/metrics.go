@@ -0,0 +1,65 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package cardinality
+
+import (
+	"github.com/GuanceCloud/cliutils/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	exceedVec,
+	droppedVec *prometheus.CounterVec
+
+	seriesVec *prometheus.GaugeVec
+)
+
+func setupMetrics() {
+	seriesVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "cardinality_series",
+			Help:      "Tracked unique series of measurement",
+		},
+		[]string{"measurement"},
+	)
+
+	exceedVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "cardinality_exceeded_total",
+			Help:      "Points that exceed max series of measurement",
+		},
+		[]string{
+			"measurement",
+			"tag",
+			"action",
+		},
+	)
+
+	droppedVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "cardinality_dropped_point_total",
+			Help:      "Points dropped by cardinality guard",
+		},
+		[]string{"measurement"},
+	)
+
+	metrics.MustRegister(
+		seriesVec,
+		exceedVec,
+		droppedVec,
+	)
+}
+
+//nolint:gochecknoinits
+func init() {
+	setupMetrics()
+}
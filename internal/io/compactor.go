@@ -80,6 +80,22 @@ func (x *dkIO) cacheData(c *compactor, d *feedOption, tryClean bool) {
 	log.Debugf("get iodata(%d points) from %s|%s", len(d.pts), d.cat, d.input)
 
 	x.recordPoints(d)
+
+	if x.cardinalityGuard != nil && d.cat == point.Metric {
+		kept, dropped := x.cardinalityGuard.Process(d.input, d.pts)
+		datakit.PutbackPoints(dropped...)
+		if d.pts = kept; len(d.pts) == 0 {
+			return
+		}
+	}
+
+	if x.aggregator != nil && d.cat == point.Metric {
+		kept, dropped := x.aggregator.Process(d.pts)
+		datakit.PutbackPoints(dropped...)
+		if d.pts = kept; len(d.pts) == 0 {
+			return
+		}
+	}
 	c.points = append(c.points, d.pts...)
 
 	queuePtsVec.WithLabelValues(d.cat.String()).Add(float64(len(d.pts)))
@@ -63,6 +63,10 @@ func PutFeedOption(fo *feedOption) {
 	fo.postTimeout = 0
 	fo.plOption = nil
 	fo.election = false
+	fo.nonBlocking = false
+	fo.requestHandler = false
+	fo.filtered = nil
+	fo.filteredPts = nil
 	fo.pts = nil
 
 	feedOptionPool.Put(fo)
@@ -105,8 +109,13 @@ type feedOption struct {
 
 	noGlobalTags,
 	syncSend,
+	nonBlocking,
+	requestHandler,
 	election bool
 
+	filtered    *int
+	filteredPts *[]*point.Point
+
 	pts []*point.Point
 }
 
@@ -135,6 +144,22 @@ func WithSyncSend(on bool) FeedOption      { return func(fo *feedOption) { fo.sy
 func WithElection(on bool) FeedOption      { return func(fo *feedOption) { fo.election = on } }
 func WithInputName(name string) FeedOption { return func(fo *feedOption) { fo.input = name } }
 
+// WithNonBlocking makes the feed return ErrIOBusy instead of blocking when the feed queue is full.
+func WithNonBlocking(on bool) FeedOption { return func(fo *feedOption) { fo.nonBlocking = on } }
+
+// WithRequestHandler marks the feed called within a request handler. Such feed never waits
+// for throttled or paused input(see ThrottleInput and PauseInput) but returns ErrIOBusy,
+// so do non-blocking feeds.
+func WithRequestHandler(on bool) FeedOption { return func(fo *feedOption) { fo.requestHandler = on } }
+
+// WithFilteredCount set n to the number of points dropped by filters within the feed.
+func WithFilteredCount(n *int) FeedOption { return func(fo *feedOption) { fo.filtered = n } }
+
+// WithFilteredPoints append points dropped by filters(or offloaded by pipeline) within the feed to pts.
+func WithFilteredPoints(pts *[]*point.Point) FeedOption {
+	return func(fo *feedOption) { fo.filteredPts = pts }
+}
+
 type Feeder interface {
 	Feed(name string, category point.Category, pts []*point.Point, opt ...*Option) error
 	FeedV2(category point.Category, pts []*point.Point, opts ...FeedOption) error
@@ -161,6 +186,10 @@ func (f *ioFeeder) Feed(name string, category point.Category, pts []*point.Point
 	inputsFeedPtsVec.WithLabelValues(name, category.String()).Observe(float64(len(pts)))
 	inputsLastFeedVec.WithLabelValues(name, category.String()).Set(float64(time.Now().Unix()))
 
+	if err := throttle(name, true); err != nil {
+		return err
+	}
+
 	fo := GetFeedOption()
 	fo.input = name
 	fo.cat = category
@@ -227,6 +256,11 @@ func (f *ioFeeder) FeedV2(cat point.Category, pts []*point.Point, opts ...FeedOp
 		}
 	}
 
+	if err := throttle(fo.input, !fo.nonBlocking && !fo.requestHandler); err != nil {
+		PutFeedOption(fo)
+		return err
+	}
+
 	inputsFeedVec.WithLabelValues(fo.input, cat.String()).Inc()
 	inputsFeedPtsVec.WithLabelValues(fo.input, cat.String()).Observe(float64(len(pts)))
 	inputsLastFeedVec.WithLabelValues(fo.input, cat.String()).Set(float64(time.Now().Unix()))
@@ -359,9 +393,26 @@ func (x *dkIO) doFeed(opt *feedOption) error {
 
 	filtered := len(opt.pts) - len(after) - offl
 
+	if opt.filteredPts != nil && len(after) < len(opt.pts) {
+		kept := make(map[*point.Point]struct{}, len(after))
+		for _, pt := range after {
+			kept[pt] = struct{}{}
+		}
+
+		for _, pt := range opt.pts {
+			if _, ok := kept[pt]; !ok {
+				*opt.filteredPts = append(*opt.filteredPts, pt)
+			}
+		}
+	}
+
 	opt.pts = after
 
 	if filtered >= 0 {
+		if opt.filtered != nil {
+			*opt.filtered = filtered
+		}
+
 		inputsFilteredPtsVec.WithLabelValues(
 			opt.input,
 			opt.cat.String(),
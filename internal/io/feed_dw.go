@@ -18,9 +18,13 @@ var _ FeederOutputer = new(datawayOutput)
 
 // feederOutput send feeder data to dataway.
 type datawayOutput struct {
-	chans map[point.Category]chan *feedOption
+	chans  map[point.Category]chan *feedOption
+	queues map[point.Category]*fairQueue
 }
 
+// DatawayOutputOption used to setup dataway output.
+type DatawayOutputOption func(*datawayOutput)
+
 func (fo *datawayOutput) Reader(cat point.Category) <-chan *feedOption {
 	return fo.chans[cat]
 }
@@ -76,6 +80,26 @@ func (fo *datawayOutput) Write(data *feedOption) error {
 
 	ioChanLen.WithLabelValues(data.cat.String()).Set(float64(len(ch)))
 
+	if q, ok := fo.queues[data.cat]; ok {
+		err := q.put(data)
+		feedCost.WithLabelValues(
+			category, inputName,
+		).Observe(float64(time.Since(start)) / float64(time.Second))
+		return err
+	}
+
+	if data.nonBlocking {
+		select {
+		case ch <- data:
+			feedCost.WithLabelValues(
+				category, inputName,
+			).Observe(float64(time.Since(start)) / float64(time.Second))
+			return nil
+		default:
+			return ErrIOBusy
+		}
+	}
+
 	select {
 	case ch <- data:
 		feedCost.WithLabelValues(
@@ -89,7 +113,7 @@ func (fo *datawayOutput) Write(data *feedOption) error {
 }
 
 // NewDatawayOutput new a Dataway output for feeder, its the default output of feeder.
-func NewDatawayOutput(chanCap int) FeederOutputer {
+func NewDatawayOutput(chanCap int, opts ...DatawayOutputOption) FeederOutputer {
 	dw := datawayOutput{
 		chans: make(map[point.Category]chan *feedOption),
 	}
@@ -108,5 +132,11 @@ func NewDatawayOutput(chanCap int) FeederOutputer {
 		dw.chans[c] = make(chan *feedOption, chanCap)
 	}
 
+	for _, opt := range opts {
+		if opt != nil {
+			opt(&dw)
+		}
+	}
+
 	return &dw
 }
@@ -28,6 +28,8 @@ type MockedFeeder struct {
 	ch chan []*point.Point
 
 	lastErrors [][2]string
+
+	filter func(*point.Point) bool
 }
 
 func NewMockedFeeder() *MockedFeeder {
@@ -46,7 +48,36 @@ func (f *MockedFeeder) Feed(name string, category point.Category, pts []*point.P
 	return nil
 }
 
+// SetFilter drop points that fn return false within FeedV2, like IO filters.
+func (f *MockedFeeder) SetFilter(fn func(*point.Point) bool) {
+	f.filter = fn
+}
+
 func (f *MockedFeeder) FeedV2(category point.Category, pts []*point.Point, opts ...FeedOption) error {
+	if f.filter != nil {
+		fo := &feedOption{}
+		for _, opt := range opts {
+			if opt != nil {
+				opt(fo)
+			}
+		}
+
+		var after []*point.Point
+		for _, pt := range pts {
+			if f.filter(pt) {
+				after = append(after, pt)
+			} else if fo.filteredPts != nil {
+				*fo.filteredPts = append(*fo.filteredPts, pt)
+			}
+		}
+
+		if fo.filtered != nil {
+			*fo.filtered = len(pts) - len(after)
+		}
+
+		pts = after
+	}
+
 	select {
 	case f.ch <- pts:
 	default:
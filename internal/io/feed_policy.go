@@ -0,0 +1,597 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package io
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/diskcache"
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+)
+
+// Back-pressure policies used when input's feed queue is full.
+const (
+	PolicyBlock      = "block"       // wait until queue available
+	PolicyDropOldest = "drop_oldest" // drop the oldest feed in queue and accept the new one
+	PolicyDropNewest = "drop_newest" // drop the new feed
+	PolicySpill      = "spill"       // spill the new feed to disk, and re-feed them when queue available
+
+	defaultFeedQueueSize   = 128
+	defaultFeedWeight      = 1
+	defaultSpillCapacityMB = 1024
+	defaultSpillWakeup     = time.Second * 3
+	defaultFeedQueueIdle   = time.Minute * 5        // remove input queue not fed for a while
+	defaultFeedRetry       = time.Millisecond * 100 // retry sending to full category channel
+)
+
+// FeedPolicy configure back-pressure policy of a feed queue.
+type FeedPolicy struct {
+	Policy    string `toml:"policy"`
+	QueueSize int    `toml:"queue_size"`
+	Weight    int    `toml:"weight"`
+}
+
+// merge set non-zero values of x into p.
+func (p *FeedPolicy) merge(x *FeedPolicy) {
+	if x == nil {
+		return
+	}
+
+	if x.Policy != "" {
+		p.Policy = x.Policy
+	}
+
+	if x.QueueSize > 0 {
+		p.QueueSize = x.QueueSize
+	}
+
+	if x.Weight > 0 {
+		p.Weight = x.Weight
+	}
+}
+
+// FeedPolicyConf configure per-category and per-input back-pressure policies of IO feed.
+// Input policy overwrite it's category policy.
+type FeedPolicyConf struct {
+	Enable          bool                   `toml:"enable"`
+	SpillDir        string                 `toml:"spill_dir"`
+	SpillCapacityMB int                    `toml:"spill_capacity_mb"`
+	Categories      map[string]*FeedPolicy `toml:"categories"`
+	Inputs          map[string]*FeedPolicy `toml:"inputs"`
+}
+
+// Check validate all policies.
+func (c *FeedPolicyConf) Check() error {
+	check := func(name string, p *FeedPolicy) error {
+		if p == nil {
+			return nil
+		}
+
+		switch p.Policy {
+		case "", PolicyBlock, PolicyDropOldest, PolicyDropNewest, PolicySpill:
+			return nil
+		default:
+			return fmt.Errorf("invalid feed policy %q on %q", p.Policy, name)
+		}
+	}
+
+	for k, p := range c.Categories {
+		if point.CatString(k) == point.UnknownCategory {
+			return fmt.Errorf("invalid feed policy category %q", k)
+		}
+
+		if err := check(k, p); err != nil {
+			return err
+		}
+	}
+
+	for k, p := range c.Inputs {
+		if err := check(k, p); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *FeedPolicyConf) policyOf(cat point.Category, input string) *FeedPolicy {
+	p := &FeedPolicy{
+		Policy:    PolicyBlock,
+		QueueSize: defaultFeedQueueSize,
+		Weight:    defaultFeedWeight,
+	}
+
+	p.merge(c.Categories[cat.String()])
+	p.merge(c.Inputs[input])
+
+	return p
+}
+
+// inputQueue is the feed queue of a single input within a category.
+type inputQueue struct {
+	name    string
+	policy  *FeedPolicy
+	items   []*feedOption
+	deficit int
+	space   chan struct{} // notify blocked feeder there is space in queue
+	active  time.Time     // last time the input fed
+}
+
+func (iq *inputQueue) pop() *feedOption {
+	fo := iq.items[0]
+	iq.items[0] = nil
+	iq.items = iq.items[1:]
+
+	select {
+	case iq.space <- struct{}{}:
+	default:
+	}
+
+	return fo
+}
+
+// fairQueue dispatch feeds of a category from all inputs with weighted fair queuing,
+// so that a noisy input can not starve other inputs within the same category.
+type fairQueue struct {
+	cat  point.Category
+	conf *FeedPolicyConf
+	out  chan *feedOption
+
+	mtx       sync.Mutex
+	queues    map[string]*inputQueue
+	order     []*inputQueue
+	cursor    int
+	spillTurn bool // take spilled feed on next dequeue
+
+	notify chan struct{}
+	spill  *diskcache.DiskCache
+
+	// fields below only used by feedScheduler
+	weight  int
+	deficit int
+	pending *feedOption // feed not sent for out channel full
+}
+
+func newFairQueue(cat point.Category, conf *FeedPolicyConf, out chan *feedOption) *fairQueue {
+	weight := defaultFeedWeight
+	if p := conf.Categories[cat.String()]; p != nil && p.Weight > 0 {
+		weight = p.Weight
+	}
+
+	return &fairQueue{
+		cat:    cat,
+		conf:   conf,
+		out:    out,
+		queues: map[string]*inputQueue{},
+		notify: make(chan struct{}, 1),
+		weight: weight,
+	}
+}
+
+func (q *fairQueue) setupSpill() error {
+	dir := q.conf.SpillDir
+	if dir == "" {
+		dir = datakit.JoinToCacheDir("feed_spill")
+	}
+
+	capacity := q.conf.SpillCapacityMB
+	if capacity <= 0 {
+		capacity = defaultSpillCapacityMB
+	}
+
+	dc, err := diskcache.Open(
+		diskcache.WithPath(filepath.Join(dir, q.cat.String())),
+		diskcache.WithNoLock(true),
+		diskcache.WithFILODrop(true),
+		diskcache.WithNoFallbackOnError(true), // skip bad spilled data
+		diskcache.WithWakeup(defaultSpillWakeup),
+		diskcache.WithCapacity(int64(capacity)<<20),
+	)
+	if err != nil {
+		return err
+	}
+
+	q.spill = dc
+	return nil
+}
+
+func (q *fairQueue) getQueue(input string) *inputQueue {
+	if iq, ok := q.queues[input]; ok {
+		return iq
+	}
+
+	iq := &inputQueue{
+		name:   input,
+		policy: q.conf.policyOf(q.cat, input),
+		space:  make(chan struct{}, 1),
+	}
+
+	q.queues[input] = iq
+	q.order = append(q.order, iq)
+	sort.Slice(q.order, func(i, j int) bool { return q.order[i].name < q.order[j].name })
+	q.cursor = 0
+
+	return iq
+}
+
+// expire remove empty input queues not fed since idle ago.
+func (q *fairQueue) expire(now time.Time, idle time.Duration) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	order := q.order[:0]
+	for _, iq := range q.order {
+		if len(iq.items) == 0 && now.Sub(iq.active) > idle {
+			delete(q.queues, iq.name)
+			feedQueueLenVec.DeleteLabelValues(q.cat.String(), iq.name)
+			continue
+		}
+		order = append(order, iq)
+	}
+
+	for i := len(order); i < len(q.order); i++ {
+		q.order[i] = nil
+	}
+
+	if len(order) != len(q.order) {
+		q.order = order
+		q.cursor = 0
+	}
+}
+
+func (q *fairQueue) wakeup() {
+	select {
+	case q.notify <- struct{}{}:
+	default:
+	}
+}
+
+func (q *fairQueue) dropped(fo *feedOption, policy string) {
+	feedDropPtsVec.WithLabelValues(q.cat.String(), fo.input, policy).Add(float64(len(fo.pts)))
+	datakit.PutbackPoints(fo.pts...)
+	PutFeedOption(fo)
+}
+
+// put add feed into input's queue, the back-pressure policy applied if the queue is full.
+func (q *fairQueue) put(fo *feedOption) error {
+	for {
+		q.mtx.Lock()
+		iq := q.getQueue(fo.input)
+		iq.active = time.Now()
+
+		if len(iq.items) < iq.policy.QueueSize {
+			iq.items = append(iq.items, fo)
+			feedQueueLenVec.WithLabelValues(q.cat.String(), iq.name).Set(float64(len(iq.items)))
+			q.mtx.Unlock()
+			q.wakeup()
+			return nil
+		}
+
+		switch iq.policy.Policy {
+		case PolicyDropOldest:
+			old := iq.items[0]
+			iq.items[0] = nil
+			iq.items = append(iq.items[1:], fo)
+			q.mtx.Unlock()
+			q.dropped(old, PolicyDropOldest)
+			q.wakeup()
+			return nil
+
+		case PolicyDropNewest:
+			q.mtx.Unlock()
+			q.dropped(fo, PolicyDropNewest)
+			return ErrIOBusy
+
+		case PolicySpill:
+			q.mtx.Unlock()
+			if err := q.spillFeed(fo); err != nil {
+				log.Warnf("spill %d points from %q failed: %s, dropped", len(fo.pts), fo.input, err)
+				q.dropped(fo, PolicySpill)
+				return ErrIOBusy
+			}
+
+			feedSpillPtsVec.WithLabelValues(q.cat.String(), fo.input).Add(float64(len(fo.pts)))
+			datakit.PutbackPoints(fo.pts...)
+			PutFeedOption(fo)
+			return nil
+
+		default: // PolicyBlock
+			q.mtx.Unlock()
+			if fo.nonBlocking {
+				return ErrIOBusy
+			}
+
+			select {
+			case <-iq.space:
+			case <-datakit.Exit.Wait():
+				log.Warnf("%s/%s feed skipped on global exit", fo.cat, fo.input)
+				return fmt.Errorf("feed on global exit")
+			}
+		}
+	}
+}
+
+// next select next feed of the category. Spilled feeds are taken in turn with
+// queued feeds, so they are re-fed while inputs keep feeding.
+func (q *fairQueue) next() *feedOption {
+	if q.spillTurn {
+		q.spillTurn = false
+		if fo := q.unspill(); fo != nil {
+			return fo
+		}
+	}
+
+	if fo := q.dequeue(); fo != nil {
+		q.spillTurn = q.spill != nil
+		return fo
+	}
+
+	return q.unspill()
+}
+
+// dequeue select next feed with deficit round robin among all input queues.
+func (q *fairQueue) dequeue() *feedOption {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	for i := 0; i < len(q.order); i++ {
+		iq := q.order[q.cursor]
+		if len(iq.items) == 0 {
+			iq.deficit = 0
+			q.cursor = (q.cursor + 1) % len(q.order)
+			continue
+		}
+
+		if iq.deficit <= 0 {
+			iq.deficit = iq.policy.Weight
+		}
+
+		fo := iq.pop()
+		iq.deficit--
+		feedQueueLenVec.WithLabelValues(q.cat.String(), iq.name).Set(float64(len(iq.items)))
+
+		if iq.deficit <= 0 || len(iq.items) == 0 {
+			q.cursor = (q.cursor + 1) % len(q.order)
+		}
+
+		return fo
+	}
+
+	return nil
+}
+
+var errInvalidSpillData = errors.New("invalid spill data")
+
+// spillFeed encode feed as: <input-name>\n<protobuf-points>.
+func (q *fairQueue) spillFeed(fo *feedOption) error {
+	if q.spill == nil {
+		return fmt.Errorf("spill not available")
+	}
+
+	enc := point.GetEncoder(point.WithEncEncoding(point.Protobuf))
+	defer point.PutEncoder(enc)
+
+	arr, err := enc.Encode(fo.pts)
+	if err != nil {
+		return err
+	}
+
+	for _, x := range arr {
+		buf := make([]byte, 0, len(fo.input)+1+len(x))
+		buf = append(buf, fo.input...)
+		buf = append(buf, '\n')
+		buf = append(buf, x...)
+
+		if err := q.spill.Put(buf); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unspill load one spilled feed from disk.
+func (q *fairQueue) unspill() *feedOption {
+	if q.spill == nil {
+		return nil
+	}
+
+	var fo *feedOption
+	if err := q.spill.Get(func(x []byte) error {
+		idx := bytes.IndexByte(x, '\n')
+		if idx < 0 {
+			return errInvalidSpillData
+		}
+
+		dec := point.GetDecoder(point.WithDecEncoding(point.Protobuf))
+		defer point.PutDecoder(dec)
+
+		pts, err := dec.Decode(x[idx+1:])
+		if err != nil {
+			return err
+		}
+
+		fo = GetFeedOption()
+		fo.input = string(x[:idx])
+		fo.cat = q.cat
+		fo.pts = pts
+		return nil
+	}); err != nil {
+		if !errors.Is(err, diskcache.ErrNoData) {
+			log.Warnf("load spilled feed on %s failed: %s, ignored", q.cat, err)
+		}
+		return nil
+	}
+
+	return fo
+}
+
+// feedScheduler dispatch feeds of all categories with deficit round robin
+// among categories, so that a noisy category can not starve other categories.
+type feedScheduler struct {
+	queues []*fairQueue
+	cursor int
+	notify chan struct{}
+}
+
+func newFeedScheduler(queues map[point.Category]*fairQueue) *feedScheduler {
+	s := &feedScheduler{notify: make(chan struct{}, 1)}
+
+	for _, q := range queues {
+		q.notify = s.notify
+		s.queues = append(s.queues, q)
+	}
+
+	sort.Slice(s.queues, func(i, j int) bool { return s.queues[i].cat < s.queues[j].cat })
+	return s
+}
+
+func (s *feedScheduler) advance() {
+	s.cursor = (s.cursor + 1) % len(s.queues)
+}
+
+// dispatch send one feed to its category channel, categories with full channel
+// are skipped. It returns false if no feed sent.
+func (s *feedScheduler) dispatch() bool {
+	for i := 0; i < len(s.queues); i++ {
+		q := s.queues[s.cursor]
+
+		fo := q.pending
+		if fo == nil {
+			fo = q.next()
+		}
+
+		if fo == nil {
+			q.deficit = 0
+			s.advance()
+			continue
+		}
+
+		select {
+		case q.out <- fo:
+			q.pending = nil
+		default: // category channel full
+			q.pending = fo
+			q.deficit = 0
+			s.advance()
+			continue
+		}
+
+		if q.deficit <= 0 {
+			q.deficit = q.weight
+		}
+
+		q.deficit--
+		if q.deficit <= 0 {
+			s.advance()
+		}
+
+		return true
+	}
+
+	return false
+}
+
+func (s *feedScheduler) pending() bool {
+	for _, q := range s.queues {
+		if q.pending != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *feedScheduler) close() {
+	for _, q := range s.queues {
+		if q.spill != nil {
+			if err := q.spill.Close(); err != nil {
+				log.Warnf("close spill cache on %s: %s, ignored", q.cat, err)
+			}
+		}
+	}
+}
+
+func (s *feedScheduler) run() {
+	tick := time.NewTicker(defaultSpillWakeup)
+	defer tick.Stop()
+
+	for {
+		if len(s.queues) > 0 && s.dispatch() {
+			continue
+		}
+
+		var retry <-chan time.Time
+		if s.pending() {
+			retry = time.After(defaultFeedRetry)
+		}
+
+		select {
+		case <-s.notify:
+		case <-retry:
+		case now := <-tick.C: // check spilled data and idle queues
+			for _, q := range s.queues {
+				q.expire(now, defaultFeedQueueIdle)
+			}
+		case <-datakit.Exit.Wait():
+			s.close()
+			return
+		}
+	}
+}
+
+func (s *feedScheduler) start() {
+	g := datakit.G("io/feed_policy")
+	g.Go(func(_ context.Context) error {
+		s.run()
+		return nil
+	})
+}
+
+// WithFeedPolicy enable per-category and per-input back-pressure policies on dataway output.
+func WithFeedPolicy(conf *FeedPolicyConf) DatawayOutputOption {
+	return func(fo *datawayOutput) {
+		if conf == nil || !conf.Enable {
+			return
+		}
+
+		if err := conf.Check(); err != nil {
+			log.Warnf("invalid feed policy: %s, feed policy disabled", err)
+			return
+		}
+
+		fo.queues = map[point.Category]*fairQueue{}
+		for cat, ch := range fo.chans {
+			q := newFairQueue(cat, conf, ch)
+
+			var spillOn bool
+			for _, p := range conf.Categories {
+				spillOn = spillOn || (p != nil && p.Policy == PolicySpill)
+			}
+			for _, p := range conf.Inputs {
+				spillOn = spillOn || (p != nil && p.Policy == PolicySpill)
+			}
+
+			if spillOn {
+				if err := q.setupSpill(); err != nil {
+					log.Warnf("setup spill on %s failed: %s, spilled feed will be dropped", cat, err)
+				}
+			}
+
+			fo.queues[cat] = q
+		}
+
+		newFeedScheduler(fo.queues).start()
+	}
+}
@@ -0,0 +1,256 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package io
+
+import (
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func feedOf(input string, n int) *feedOption {
+	fo := GetFeedOption()
+	fo.input = input
+	fo.cat = point.Metric
+	fo.pts = point.RandPoints(n)
+	return fo
+}
+
+func TestFeedPolicyOf(t *T.T) {
+	conf := &FeedPolicyConf{
+		Categories: map[string]*FeedPolicy{
+			"logging": {Policy: PolicyDropNewest, QueueSize: 16},
+		},
+		Inputs: map[string]*FeedPolicy{
+			"logging/nginx": {Policy: PolicyDropOldest},
+			"cpu":           {Weight: 10},
+		},
+	}
+
+	require.NoError(t, conf.Check())
+
+	p := conf.policyOf(point.Logging, "logging/nginx")
+	assert.Equal(t, &FeedPolicy{Policy: PolicyDropOldest, QueueSize: 16, Weight: defaultFeedWeight}, p)
+
+	p = conf.policyOf(point.Metric, "cpu")
+	assert.Equal(t, &FeedPolicy{Policy: PolicyBlock, QueueSize: defaultFeedQueueSize, Weight: 10}, p)
+
+	conf.Inputs["cpu"].Policy = "no-such-policy"
+	assert.Error(t, conf.Check())
+
+	conf.Inputs["cpu"].Policy = ""
+	conf.Categories["no-such-category"] = &FeedPolicy{}
+	assert.Error(t, conf.Check())
+}
+
+func TestFairQueue(t *T.T) {
+	t.Run("weighted", func(t *T.T) {
+		conf := &FeedPolicyConf{
+			Inputs: map[string]*FeedPolicy{
+				"cpu":     {Weight: 3},
+				"logging": {QueueSize: 100},
+			},
+		}
+
+		q := newFairQueue(point.Metric, conf, nil)
+		for i := 0; i < 20; i++ {
+			require.NoError(t, q.put(feedOf("logging", 1)))
+		}
+
+		for i := 0; i < 6; i++ {
+			require.NoError(t, q.put(feedOf("cpu", 1)))
+		}
+
+		var got []string
+		for fo := q.next(); fo != nil; fo = q.next() {
+			got = append(got, fo.input)
+		}
+
+		require.Len(t, got, 26)
+		// cpu get 3 slots per round, logging get 1.
+		assert.Equal(t, []string{"cpu", "cpu", "cpu", "logging", "cpu", "cpu", "cpu", "logging", "logging"}, got[:9])
+	})
+
+	t.Run("drop-newest", func(t *T.T) {
+		conf := &FeedPolicyConf{
+			Inputs: map[string]*FeedPolicy{"logging": {QueueSize: 2, Policy: PolicyDropNewest}},
+		}
+
+		q := newFairQueue(point.Metric, conf, nil)
+		require.NoError(t, q.put(feedOf("logging", 1)))
+		require.NoError(t, q.put(feedOf("logging", 2)))
+		assert.ErrorIs(t, q.put(feedOf("logging", 3)), ErrIOBusy)
+
+		assert.Len(t, q.next().pts, 1)
+		assert.Len(t, q.next().pts, 2)
+		assert.Nil(t, q.next())
+	})
+
+	t.Run("drop-oldest", func(t *T.T) {
+		conf := &FeedPolicyConf{
+			Inputs: map[string]*FeedPolicy{"logging": {QueueSize: 2, Policy: PolicyDropOldest}},
+		}
+
+		q := newFairQueue(point.Metric, conf, nil)
+		require.NoError(t, q.put(feedOf("logging", 1)))
+		require.NoError(t, q.put(feedOf("logging", 2)))
+		require.NoError(t, q.put(feedOf("logging", 3)))
+
+		assert.Len(t, q.next().pts, 2)
+		assert.Len(t, q.next().pts, 3)
+		assert.Nil(t, q.next())
+	})
+
+	t.Run("spill", func(t *T.T) {
+		conf := &FeedPolicyConf{
+			SpillDir: t.TempDir(),
+			Inputs:   map[string]*FeedPolicy{"logging": {QueueSize: 1, Policy: PolicySpill}},
+		}
+
+		q := newFairQueue(point.Metric, conf, nil)
+		require.NoError(t, q.setupSpill())
+		defer q.spill.Close() //nolint:errcheck
+
+		require.NoError(t, q.put(feedOf("logging", 1)))
+		require.NoError(t, q.put(feedOf("logging", 5)))
+
+		assert.Len(t, q.next().pts, 1)
+		assert.Nil(t, q.next())
+
+		require.NoError(t, q.spill.Rotate())
+
+		fo := q.unspill()
+		require.NotNil(t, fo)
+		assert.Equal(t, "logging", fo.input)
+		assert.Equal(t, point.Metric, fo.cat)
+		assert.Len(t, fo.pts, 5)
+
+		assert.Nil(t, q.unspill())
+	})
+
+	t.Run("spill-interleaved", func(t *T.T) {
+		conf := &FeedPolicyConf{
+			SpillDir: t.TempDir(),
+			Inputs:   map[string]*FeedPolicy{"logging": {QueueSize: 1, Policy: PolicySpill}},
+		}
+
+		q := newFairQueue(point.Metric, conf, nil)
+		require.NoError(t, q.setupSpill())
+		defer q.spill.Close() //nolint:errcheck
+
+		require.NoError(t, q.put(feedOf("logging", 1)))
+		require.NoError(t, q.put(feedOf("logging", 5)))
+		require.NoError(t, q.spill.Rotate())
+
+		require.NoError(t, q.put(feedOf("cpu", 2)))
+		require.NoError(t, q.put(feedOf("cpu", 3)))
+
+		// spilled feed re-fed while queues not empty
+		var got []int
+		for fo := q.next(); fo != nil; fo = q.next() {
+			got = append(got, len(fo.pts))
+		}
+
+		assert.Equal(t, []int{2, 5, 1, 3}, got)
+	})
+
+	t.Run("expire", func(t *T.T) {
+		q := newFairQueue(point.Metric, &FeedPolicyConf{}, nil)
+		require.NoError(t, q.put(feedOf("cpu", 1)))
+		require.NoError(t, q.put(feedOf("mem", 1)))
+		require.NotNil(t, q.next()) // cpu
+
+		q.expire(time.Now().Add(time.Hour), time.Minute)
+		require.Len(t, q.queues, 1, "non-empty queue kept")
+		assert.NotNil(t, q.queues["mem"])
+
+		require.NotNil(t, q.next())
+		assert.Nil(t, q.next())
+
+		q.expire(time.Now(), time.Minute)
+		assert.Len(t, q.queues, 1, "active queue kept")
+
+		q.expire(time.Now().Add(time.Hour), time.Minute)
+		assert.Empty(t, q.queues)
+		assert.Empty(t, q.order)
+
+		require.NoError(t, q.put(feedOf("cpu", 1)))
+		assert.Len(t, q.next().pts, 1)
+	})
+}
+
+func TestFeedScheduler(t *T.T) {
+	conf := &FeedPolicyConf{
+		Categories: map[string]*FeedPolicy{
+			"metric":  {Weight: 2},
+			"logging": {QueueSize: 100},
+		},
+	}
+
+	metric := make(chan *feedOption, 100)
+	logging := make(chan *feedOption, 1)
+
+	queues := map[point.Category]*fairQueue{
+		point.Metric:  newFairQueue(point.Metric, conf, metric),
+		point.Logging: newFairQueue(point.Logging, conf, logging),
+	}
+	s := newFeedScheduler(queues)
+
+	for i := 0; i < 10; i++ {
+		fo := feedOf("logging", 1)
+		fo.cat = point.Logging
+		require.NoError(t, queues[point.Logging].put(fo))
+	}
+
+	for i := 0; i < 4; i++ {
+		require.NoError(t, queues[point.Metric].put(feedOf("cpu", 1)))
+	}
+
+	for s.dispatch() {
+	}
+
+	// logging channel full, metric still dispatched
+	assert.Len(t, metric, 4)
+	assert.Len(t, logging, 1)
+	assert.True(t, s.pending())
+
+	<-logging
+	assert.True(t, s.dispatch())
+	assert.Len(t, logging, 1)
+	assert.False(t, s.pending())
+}
+
+func TestFeedSchedulerWeighted(t *T.T) {
+	conf := &FeedPolicyConf{
+		Categories: map[string]*FeedPolicy{"metric": {Weight: 3}},
+	}
+
+	out := make(chan *feedOption, 100)
+	queues := map[point.Category]*fairQueue{
+		point.Metric:  newFairQueue(point.Metric, conf, out),
+		point.Logging: newFairQueue(point.Logging, conf, out),
+	}
+	s := newFeedScheduler(queues)
+
+	for i := 0; i < 6; i++ {
+		fo := feedOf("logging", 1)
+		fo.cat = point.Logging
+		require.NoError(t, queues[point.Logging].put(fo))
+		require.NoError(t, queues[point.Metric].put(feedOf("cpu", 1)))
+	}
+
+	var got []string
+	for s.dispatch() {
+		got = append(got, (<-out).input)
+	}
+
+	require.Len(t, got, 12)
+	// metric get 3 slots per round, logging get 1.
+	assert.Equal(t, []string{"cpu", "cpu", "cpu", "logging", "cpu", "cpu", "cpu", "logging", "logging"}, got[:9])
+}
@@ -14,6 +14,8 @@ import (
 	"github.com/GuanceCloud/cliutils/logger"
 	"github.com/GuanceCloud/cliutils/point"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/aggregate"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/cardinality"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/dataway"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/filter"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/recorder"
@@ -39,6 +41,9 @@ type dkIO struct {
 
 	recorder *recorder.Recorder
 
+	aggregator       *aggregate.Aggregator
+	cardinalityGuard *cardinality.Guard
+
 	flushInterval time.Duration
 	availableCPUs,
 	flushWorkers int
@@ -132,6 +137,14 @@ func (x *dkIO) start() {
 			}
 		}
 	}
+	if x.aggregator != nil {
+		g := datakit.G("io/aggregate")
+		g.Go(func(_ context.Context) error {
+			x.runAggregator()
+			return nil
+		})
+	}
+
 	log.Infof("remote_job x.remotemanager %v", x.remoteManager == nil)
 	if x.remoteManager != nil {
 		g := datakit.G("io/remote_job")
@@ -16,6 +16,8 @@ import (
 var (
 	inputsFeedVec,
 	flushVec,
+	feedDropPtsVec,
+	feedSpillPtsVec,
 	inputsFilteredPtsVec *prometheus.CounterVec
 
 	feedCost,
@@ -23,6 +25,7 @@ var (
 	inputsCollectLatencyVec *prometheus.SummaryVec
 
 	queuePtsVec,
+	feedQueueLenVec,
 	flushWorkersVec,
 	inputsLastFeedVec,
 	ioChanCap,
@@ -197,6 +200,46 @@ func metricsSetup() {
 		},
 	)
 
+	feedQueueLenVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "feed_queue_length",
+			Help:      "Input feed queue length(in feed batches) under feed policy",
+		},
+		[]string{
+			"category",
+			"name",
+		},
+	)
+
+	feedDropPtsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "feed_drop_point_total",
+			Help:      "Input feed dropped points on full feed queue under feed policy",
+		},
+		[]string{
+			"category",
+			"name",
+			"policy",
+		},
+	)
+
+	feedSpillPtsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "io",
+			Name:      "feed_spill_point_total",
+			Help:      "Input feed points spilled to disk on full feed queue under feed policy",
+		},
+		[]string{
+			"category",
+			"name",
+		},
+	)
+
 	// add more...
 }
 
@@ -219,6 +262,9 @@ func Metrics() []prometheus.Collector {
 		flushVec,
 		flushWorkersVec,
 		feedCost,
+		feedQueueLenVec,
+		feedDropPtsVec,
+		feedSpillPtsVec,
 	}
 }
 
@@ -235,6 +281,9 @@ func MetricsReset() {
 	ioChanLen.Reset()
 	flushVec.Reset()
 	flushWorkersVec.Reset()
+	feedQueueLenVec.Reset()
+	feedDropPtsVec.Reset()
+	feedSpillPtsVec.Reset()
 }
 
 // A CollectorStatus used to describe a input's status.
@@ -8,6 +8,8 @@ package io
 import (
 	"time"
 
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/aggregate"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/cardinality"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/dataway"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/filter"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/recorder"
@@ -52,6 +54,10 @@ func WithRemoteJob(rj *RemoteJob, dw *dataway.Dataway) IOOption {
 				Internal: d,
 				PullFunc: x.dw.Pull,
 				JavaHome: rj.JavaHome,
+
+				MaxReportSize:    rj.MaxReportSize,
+				LogSnapshotPaths: rj.LogSnapshotPaths,
+				PySpyPath:        rj.PySpyPath,
 			}
 		}
 	}
@@ -124,3 +130,33 @@ func WithAvailableCPUs(cpus int) IOOption {
 		}
 	}
 }
+
+// WithAggregate enable aggregate stage on metric points.
+func WithAggregate(conf *aggregate.Config) IOOption {
+	return func(x *dkIO) {
+		if conf == nil || !conf.Enable {
+			return
+		}
+
+		if agg, err := aggregate.New(conf); err != nil {
+			log.Warnf("invalid aggregate config: %s, aggregate disabled", err)
+		} else {
+			x.aggregator = agg
+		}
+	}
+}
+
+// WithCardinality enable cardinality guard on metric points.
+func WithCardinality(conf *cardinality.Config) IOOption {
+	return func(x *dkIO) {
+		if conf == nil || !conf.Enable {
+			return
+		}
+
+		if g, err := cardinality.New(conf); err != nil {
+			log.Warnf("invalid cardinality config: %s, cardinality guard disabled", err)
+		} else {
+			x.cardinalityGuard = g
+		}
+	}
+}
@@ -10,4 +10,12 @@ type RemoteJob struct {
 	ENVs     []string `toml:"envs"`
 	Interval string   `toml:"interval"`
 	JavaHome string   `toml:"java_home"`
+
+	// MaxReportSize limit the bytes of a job report, 0 means default(4MiB).
+	MaxReportSize int64 `toml:"max_report_size"`
+	// LogSnapshotPaths are glob patterns of log files that allowed to snapshot,
+	// log snapshot job disabled if empty.
+	LogSnapshotPaths []string `toml:"log_snapshot_paths"`
+	// PySpyPath is path of py-spy binary, searched within $PATH if empty.
+	PySpyPath string `toml:"py_spy_path"`
 }
@@ -0,0 +1,157 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package io
+
+import (
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+)
+
+// inputThrottle slow down or block feeds of an input, the input goroutine
+// calling Feed will wait here, so its collecting slowed down too. Feeds within
+// request handlers and non-blocking feeds never wait, they are rejected with
+// ErrIOBusy instead.
+type inputThrottle struct {
+	delay  time.Duration
+	paused chan struct{} // not nil and not closed if paused
+
+	lastFeed int64 // unix nano of last feed accepted without waiting
+}
+
+var (
+	throttleMtx sync.RWMutex
+	throttles   = map[string]*inputThrottle{}
+	nthrottles  int32 // fast path for no throttled inputs
+)
+
+func getThrottle(name string) *inputThrottle {
+	t, ok := throttles[name]
+	if !ok {
+		t = &inputThrottle{}
+		throttles[name] = t
+	}
+	return t
+}
+
+func cleanThrottle(name string) {
+	if t := throttles[name]; t != nil && t.delay == 0 && t.paused == nil {
+		delete(throttles, name)
+	}
+	atomic.StoreInt32(&nthrottles, int32(len(throttles)))
+}
+
+// ThrottleInput delay every feed of input name, 0 to remove the delay.
+// Feeds with input name like "name/xxx" are throttled too.
+func ThrottleInput(name string, delay time.Duration) {
+	throttleMtx.Lock()
+	defer throttleMtx.Unlock()
+
+	getThrottle(name).delay = delay
+	cleanThrottle(name)
+}
+
+// PauseInput block feeds of input name until resumed.
+func PauseInput(name string, pause bool) {
+	throttleMtx.Lock()
+	defer throttleMtx.Unlock()
+
+	t := getThrottle(name)
+	switch {
+	case pause && t.paused == nil:
+		t.paused = make(chan struct{})
+	case !pause && t.paused != nil:
+		close(t.paused)
+		t.paused = nil
+	}
+
+	cleanThrottle(name)
+}
+
+// InputThrottled returns the feed delay and pause state of input name.
+func InputThrottled(name string) (time.Duration, bool) {
+	throttleMtx.RLock()
+	defer throttleMtx.RUnlock()
+
+	if t := throttles[name]; t != nil {
+		return t.delay, t.paused != nil
+	}
+	return 0, false
+}
+
+func lookupThrottle(input string) *inputThrottle {
+	if atomic.LoadInt32(&nthrottles) == 0 {
+		return nil
+	}
+
+	throttleMtx.RLock()
+	defer throttleMtx.RUnlock()
+
+	t := throttles[input]
+	if t == nil {
+		if idx := strings.Index(input, "/"); idx > 0 {
+			t = throttles[input[:idx]]
+		}
+	}
+
+	return t
+}
+
+func (t *inputThrottle) state() (time.Duration, chan struct{}) {
+	throttleMtx.RLock()
+	defer throttleMtx.RUnlock()
+
+	return t.delay, t.paused
+}
+
+// allow accept at most one feed within delay.
+func (t *inputThrottle) allow(delay time.Duration) bool {
+	now := time.Now().UnixNano()
+	last := atomic.LoadInt64(&t.lastFeed)
+	if now-last < int64(delay) {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt64(&t.lastFeed, last, now)
+}
+
+// throttle wait for the delay and pause of the input before feeding. If wait
+// is false, it returns ErrIOBusy rather than waiting.
+func throttle(input string, wait bool) error {
+	t := lookupThrottle(input)
+	if t == nil {
+		return nil
+	}
+
+	delay, paused := t.state()
+
+	if !wait {
+		if paused != nil || (delay > 0 && !t.allow(delay)) {
+			return ErrIOBusy
+		}
+		return nil
+	}
+
+	if paused != nil {
+		select {
+		case <-paused:
+		case <-datakit.Exit.Wait():
+			return nil
+		}
+	}
+
+	if delay > 0 {
+		select {
+		case <-time.After(delay):
+		case <-datakit.Exit.Wait():
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,97 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package io
+
+import (
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrottleInput(t *T.T) {
+	t.Run("delay", func(t *T.T) {
+		ThrottleInput("prom", 100*time.Millisecond)
+		defer ThrottleInput("prom", 0)
+
+		for _, input := range []string{"prom", "prom/some-source"} {
+			start := time.Now()
+			assert.NoError(t, throttle(input, true))
+			assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, input)
+		}
+
+		start := time.Now()
+		assert.NoError(t, throttle("promx", true))
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+		delay, paused := InputThrottled("prom")
+		assert.Equal(t, 100*time.Millisecond, delay)
+		assert.False(t, paused)
+	})
+
+	t.Run("pause", func(t *T.T) {
+		PauseInput("logging", true)
+
+		done := make(chan struct{})
+		go func() {
+			assert.NoError(t, throttle("logging/nginx", true))
+			close(done)
+		}()
+
+		select {
+		case <-done:
+			t.Fatal("feed should be blocked")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		PauseInput("logging", false)
+
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("feed should be resumed")
+		}
+	})
+
+	t.Run("no-wait", func(t *T.T) {
+		ThrottleInput("rum", 100*time.Millisecond)
+		defer ThrottleInput("rum", 0)
+
+		// at most one feed accepted within the delay
+		start := time.Now()
+		assert.NoError(t, throttle("rum", false))
+		assert.ErrorIs(t, throttle("rum", false), ErrIOBusy)
+		assert.Less(t, time.Since(start), 50*time.Millisecond)
+
+		time.Sleep(100 * time.Millisecond)
+		assert.NoError(t, throttle("rum", false))
+
+		PauseInput("rum", true)
+		defer PauseInput("rum", false)
+		assert.ErrorIs(t, throttle("rum/app", false), ErrIOBusy)
+	})
+
+	t.Run("feed-within-request", func(t *T.T) {
+		PauseInput("ddtrace", true)
+		defer PauseInput("ddtrace", false)
+
+		f := &ioFeeder{}
+		assert.ErrorIs(t, f.FeedV2(point.Tracing, nil, WithInputName("ddtrace"), WithRequestHandler(true)), ErrIOBusy)
+		assert.ErrorIs(t, f.FeedV2(point.Tracing, nil, WithInputName("ddtrace"), WithNonBlocking(true)), ErrIOBusy)
+	})
+
+	t.Run("clean", func(t *T.T) {
+		ThrottleInput("a", time.Second)
+		PauseInput("a", true)
+		ThrottleInput("a", 0)
+		PauseInput("a", false)
+
+		throttleMtx.RLock()
+		defer throttleMtx.RUnlock()
+		assert.Empty(t, throttles)
+	})
+}
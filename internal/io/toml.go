@@ -8,6 +8,8 @@ package io
 import (
 	"time"
 
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/aggregate"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/cardinality"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/filter"
 )
 
@@ -23,4 +25,9 @@ type IOConf struct {
 	CompactWorkers  int           `toml:"flush_workers"`
 
 	Filters map[string]filter.FilterConditions `toml:"filters"`
+
+	FeedPolicy *FeedPolicyConf `toml:"feed_policy"`
+
+	Aggregate   *aggregate.Config   `toml:"aggregate"`
+	Cardinality *cardinality.Config `toml:"cardinality"`
 }
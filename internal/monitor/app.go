@@ -23,10 +23,10 @@ import (
 var (
 	l = logger.DefaultSLogger("monitor")
 
-	inputsFeedCols   = strings.Split(`Input|Cat|Feeds|P90Lat|P90Pts|Filtered|LastFeed|AvgCost|Errors`, "|")
-	plStatsCols      = strings.Split("Script|Cat|Namespace|TotalPts|DropPts|ErrPts|PLUpdate|AvgCost", "|")
+	inputsFeedCols   = strings.Split(`Input|Cat|Feeds|P90Lat|P90Pts|Filtered|Queue|Dropped|LastFeed|AvgCost|Errors`, "|")
+	plStatsCols      = strings.Split("Script|Cat|Namespace|TotalPts|DropPts|ErrPts|PLUpdate|AvgCost|Shadow", "|")
 	walStatsCols     = strings.Split("Cat|Points(mem/disk/drop/total)", "|")
-	enabledInputCols = strings.Split(`Input|Count|Crashed`, "|")
+	enabledInputCols = strings.Split(`Input|Count|Crashed|CPU|Heap`, "|")
 	goroutineCols    = strings.Split(`Name|Running|Done|TotalCost`, "|")
 	httpAPIStatCols  = strings.Split(`API|Status|Total|Latency|BodySize(P90/Total)`, "|")
 	filterRuleCols   = strings.Split("Cat|Total|Filtered(%)|Cost", "|")
@@ -44,6 +44,8 @@ func (app *monitorAPP) renderInputsFeedTable(mfs map[string]*dto.MetricFamily, c
 	ptsFilter := mfs["datakit_io_input_filter_point_total"]
 	errCount := mfs["datakit_error_total"]
 	feedCost := mfs["datakit_io_feed_cost_seconds"]
+	queueLen := mfs["datakit_io_feed_queue_length"]
+	ptsDrop := mfs["datakit_io_feed_drop_point_total"]
 
 	if feedTotal == nil {
 		app.inputsStatTable.SetTitle("[red]In[white]puts Info(no data collected)")
@@ -153,6 +155,37 @@ func (app *monitorAPP) renderInputsFeedTable(mfs map[string]*dto.MetricFamily, c
 		}
 		col++
 
+		// Queue: only available under feed policy
+		table.SetCell(row, col, tview.NewTableCell("-").
+			SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignCenter))
+		if queueLen != nil {
+			x := metricWithLabel(queueLen, cat, inputName)
+			if x != nil {
+				table.SetCell(row, col, tview.NewTableCell(number(x.GetGauge().GetValue())).
+					SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignRight))
+			}
+		}
+		col++
+
+		// Dropped
+		table.SetCell(row, col, tview.NewTableCell("-").
+			SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignCenter))
+		if ptsDrop != nil {
+			dropped := 0.0
+			for _, dm := range ptsDrop.Metric {
+				lps := dm.GetLabel() // labels: category/name/policy
+				if len(lps) == 3 && lps[0].GetValue() == cat && lps[1].GetValue() == inputName {
+					dropped += dm.GetCounter().GetValue()
+				}
+			}
+
+			if dropped > 0 {
+				table.SetCell(row, col, tview.NewTableCell(number(dropped)).
+					SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignRight))
+			}
+		}
+		col++
+
 		// LastFeed
 		if lastFeed != nil {
 			x := metricWithLabel(lastFeed, cat, inputName)
@@ -21,6 +21,7 @@ func (app *monitorAPP) renderEnabledInputTable(mfs map[string]*dto.MetricFamily,
 	}
 
 	instance, panics := mfs["datakit_inputs_instance"], mfs["datakit_inputs_crash_total"]
+	cpuUsage, heapBytes := mfs["datakit_input_resource_cpu_usage"], mfs["datakit_input_resource_heap_bytes"]
 	if instance == nil {
 		app.enabledInputTable.SetTitle("Enabled [red]In[white]puts(no inputs enabled)")
 		return
@@ -61,6 +62,20 @@ func (app *monitorAPP) renderEnabledInputTable(mfs map[string]*dto.MetricFamily,
 				SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignRight))
 		}
 
+		// resource usage, only available if per-input accounting enabled
+		cpu, heap := "-", "-"
+		if x := metricWithLabel(cpuUsage, name); x != nil {
+			cpu = fmt.Sprintf("%.2f%%", x.GetGauge().GetValue())
+		}
+		if x := metricWithLabel(heapBytes, name); x != nil {
+			heap = number(x.GetGauge().GetValue())
+		}
+
+		table.SetCell(row, 3, tview.NewTableCell(cpu).
+			SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignRight))
+		table.SetCell(row, 4, tview.NewTableCell(heap).
+			SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignRight))
+
 		row++
 	}
 }
@@ -27,6 +27,8 @@ func (app *monitorAPP) renderPLStatTable(mfs map[string]*dto.MetricFamily, colAr
 	totalDropPts := mfs["datakit_pipeline_drop_point_total"]
 	lastUpdate := mfs["datakit_pipeline_last_update_timestamp_seconds"]
 	cost := mfs["datakit_pipeline_cost_seconds"]
+	shadowState := mfs["datakit_pipeline_shadow_state"]
+	shadowSamples := mfs["datakit_pipeline_shadow_sample_total"]
 
 	if totalPts == nil {
 		table.SetTitle("[red]P[white]ipeline Info(no data collected)")
@@ -133,7 +135,45 @@ func (app *monitorAPP) renderPLStatTable(mfs map[string]*dto.MetricFamily, colAr
 			table.SetCell(row, col, tview.NewTableCell("-").
 				SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignCenter))
 		}
+		col++
+
+		if x := shadowStateText(shadowState, shadowSamples, cat, name, ns); x == "" {
+			table.SetCell(row, col, tview.NewTableCell("-").
+				SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignCenter))
+		} else {
+			table.SetCell(row, col, tview.NewTableCell(x).
+				SetMaxWidth(app.maxTableWidth).SetAlign(tview.AlignRight))
+		}
 
 		row++
 	}
 }
+
+// shadowStateText shows shadow evaluation state of the updated script.
+func shadowStateText(state, samples *dto.MetricFamily, cat, name, ns string) string {
+	if state == nil {
+		return ""
+	}
+
+	x := metricWithLabel(state, cat, name, ns)
+	if x == nil {
+		return ""
+	}
+
+	switch x.GetGauge().GetValue() {
+	case 1:
+		n := 0.0
+		if samples != nil {
+			if y := metricWithLabel(samples, cat, name, ns); y != nil {
+				n = y.GetCounter().GetValue()
+			}
+		}
+		return fmt.Sprintf("evaluating(%s)", number(n))
+	case 2:
+		return "promoted"
+	case 3:
+		return "rejected"
+	default:
+		return ""
+	}
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/GuanceCloud/cliutils/point"
 	"github.com/GuanceCloud/platypus/pkg/ast"
 	plval "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/plval"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/shadow"
 )
 
 const (
@@ -96,6 +97,14 @@ func RunPl(category point.Category, pts []*point.Point,
 			pt.AddTag(plTagNS, script.NS())
 		}
 
+		var candidate *shadow.Candidate
+		var candidatePt *point.Point
+		if sh, ok := plval.GetShadow(); ok {
+			if c, ok := sh.Sample(script); ok {
+				candidate, candidatePt = c, shadow.CopyPoint(pt)
+			}
+		}
+
 		inputData := ptinput.PtWrap(category, pt)
 
 		if v, ok := plval.GetRefTb(); ok {
@@ -107,6 +116,11 @@ func RunPl(category point.Category, pts []*point.Point,
 
 		// run pl srcipt
 		err := script.Run(inputData, nil, plOpt)
+
+		if candidate != nil {
+			evaluateCandidate(category, candidate, candidatePt, inputData, err, plOpt)
+		}
+
 		if err != nil {
 			l.Warn(err)
 			if plval.EnableAppendRunInfo() {
@@ -174,3 +188,24 @@ func searchScript(cat point.Category,
 		return nil, false
 	}
 }
+
+// evaluateCandidate runs the candidate script on the copied point, the result
+// is only used for comparison and never uploaded.
+func evaluateCandidate(category point.Category, c *shadow.Candidate, pt *point.Point,
+	active ptinput.PlInputPt, activeErr error, plOpt *plmanager.Option,
+) {
+	sh, ok := plval.GetShadow()
+	if !ok {
+		return
+	}
+
+	in := ptinput.PtWrap(category, pt)
+	if v, ok := plval.GetRefTb(); ok {
+		in.SetPlReferTables(v.Tables())
+	}
+	if v, ok := plval.GetIPDB(); ok {
+		in.SetIPDB(v)
+	}
+
+	sh.Evaluate(c, in, active, activeErr, plOpt)
+}
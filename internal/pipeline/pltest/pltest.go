@@ -0,0 +1,333 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package pltest runs unit tests of pipeline scripts.
+//
+// Test cases of script xxx.p are placed beside the script in file
+// xxx_test.toml, each case feeds one text or point to the script and checks
+// the result:
+//
+//	[[case]]
+//	  name = "access log"
+//	  text = '127.0.0.1 - - [21/Jul/2021:14:14:38 +0800] "GET / HTTP/1.1" 200 612'
+//	  [case.expect]
+//	    drop = false
+//	    [case.expect.tags]
+//	      http_method = "GET"
+//	    [case.expect.fields]
+//	      status_code = 200
+package pltest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	plmanager "github.com/GuanceCloud/cliutils/pipeline/manager"
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline"
+)
+
+// TestFileSuffix is the suffix of the test file, test file of script xxx.p
+// is xxx_test.toml.
+const TestFileSuffix = "_test.toml"
+
+// Case is a test case of the pipeline script.
+type Case struct {
+	Name string `toml:"name"`
+
+	// Text is the message of logging point, its measurement is Source, or the
+	// script name if Source not set.
+	Text   string `toml:"text"`
+	Source string `toml:"source"`
+
+	// Point is line-protocol of the point, used for all categories.
+	Point string `toml:"point"`
+
+	Expect Expect `toml:"expect"`
+}
+
+// Expect is the expected result of the case. Only tags and fields listed are
+// checked, keys in Absent should not exist in the result.
+type Expect struct {
+	Drop        bool              `toml:"drop"`
+	Measurement string            `toml:"measurement"`
+	Tags        map[string]string `toml:"tags"`
+	Fields      map[string]any    `toml:"fields"`
+	Absent      []string          `toml:"absent"`
+}
+
+type testFile struct {
+	Cases []*Case `toml:"case"`
+}
+
+// CaseResult is the result of one test case, the case passed if no error and
+// no diff.
+type CaseResult struct {
+	Name  string
+	Err   error
+	Diffs []string
+}
+
+func (r *CaseResult) Passed() bool {
+	return r.Err == nil && len(r.Diffs) == 0
+}
+
+// ScriptResult is the result of all test cases of the script.
+type ScriptResult struct {
+	Category point.Category
+	Script   string
+	TestFile string
+
+	// Err is set when the script failed to compile or the test file is
+	// invalid.
+	Err   error
+	Cases []*CaseResult
+}
+
+func (r *ScriptResult) Passed() bool {
+	if r.Err != nil {
+		return false
+	}
+
+	for _, c := range r.Cases {
+		if !c.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// TestFilePath returns the test file path of the script.
+func TestFilePath(scriptPath string) string {
+	return strings.TrimSuffix(scriptPath, filepath.Ext(scriptPath)) + TestFileSuffix
+}
+
+// RunDir runs test files of scripts under pipeline directory dir, which is
+// organized the same as <datakit-install-dir>/pipeline. Scripts failed to
+// compile are always reported, scripts without test file are skipped.
+func RunDir(dir string) ([]*ScriptResult, error) {
+	if fi, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !fi.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", dir)
+	}
+
+	scripts, scriptsPath := plmanager.ReadWorkspaceScripts(dir)
+
+	var res []*ScriptResult
+	for _, cat := range point.AllCategories() {
+		if len(scripts[cat]) == 0 {
+			continue
+		}
+
+		compiled, errs := pipeline.NewPipelineMulti(cat, scripts[cat], nil)
+
+		names := make([]string, 0, len(scripts[cat]))
+		for name := range scripts[cat] {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			r := &ScriptResult{
+				Category: cat,
+				Script:   name,
+				TestFile: TestFilePath(scriptsPath[cat][name]),
+			}
+
+			if err, ok := errs[name]; ok {
+				r.Err = err
+				res = append(res, r)
+				continue
+			}
+
+			cases, err := loadCases(r.TestFile)
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				r.Err = err
+				res = append(res, r)
+				continue
+			}
+
+			pl := &pipeline.Pipeline{Script: compiled[name]}
+			for i, c := range cases {
+				r.Cases = append(r.Cases, c.run(cat, name, i, pl))
+			}
+
+			res = append(res, r)
+		}
+	}
+
+	return res, nil
+}
+
+func loadCases(path string) ([]*Case, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var f testFile
+	if _, err := toml.Decode(string(data), &f); err != nil {
+		return nil, fmt.Errorf("decode %s: %w", path, err)
+	}
+
+	return f.Cases, nil
+}
+
+func (c *Case) point(cat point.Category, script string) (*point.Point, error) {
+	switch {
+	case c.Point != "":
+		opts := point.CommonLoggingOptions()
+		if cat == point.Metric {
+			opts = point.DefaultMetricOptions()
+		}
+
+		dec := point.GetDecoder(point.WithDecEncoding(point.LineProtocol))
+		defer point.PutDecoder(dec)
+
+		pts, err := dec.Decode([]byte(c.Point), opts...)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(pts) != 1 {
+			return nil, fmt.Errorf("expect 1 point, got %d", len(pts))
+		}
+		return pts[0], nil
+
+	case c.Text != "":
+		if cat != point.Logging {
+			return nil, fmt.Errorf("text only available for logging, use point instead")
+		}
+
+		name := c.Source
+		if name == "" {
+			name = strings.TrimSuffix(script, filepath.Ext(script))
+		}
+
+		kvs := point.NewKVs(map[string]any{pipeline.FieldMessage: c.Text})
+		return point.NewPointV2(name, kvs, append(point.DefaultLoggingOptions(), point.WithTime(time.Now()))...), nil
+
+	default:
+		return nil, fmt.Errorf("neither text nor point set")
+	}
+}
+
+func (c *Case) run(cat point.Category, script string, idx int, pl *pipeline.Pipeline) *CaseResult {
+	r := &CaseResult{Name: c.Name}
+	if r.Name == "" {
+		r.Name = fmt.Sprintf("case-%d", idx)
+	}
+
+	pt, err := c.point(cat, script)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	plpt, err := pl.Run(cat, pt, nil, nil)
+	if err != nil {
+		r.Err = err
+		return r
+	}
+
+	res := plpt.Point()
+	r.Diffs = c.Expect.diff(plpt.Dropped(), res.Name(), res.MapTags(), res.InfluxFields())
+	return r
+}
+
+func (e *Expect) diff(dropped bool, name string, tags map[string]string, fields map[string]any) []string {
+	var diffs []string
+
+	if dropped != e.Drop {
+		diffs = append(diffs, fmt.Sprintf("drop is %v, expected %v", dropped, e.Drop))
+	}
+
+	if e.Measurement != "" && name != e.Measurement {
+		diffs = append(diffs, fmt.Sprintf("measurement is %q, expected %q", name, e.Measurement))
+	}
+
+	for _, k := range sortedKeys(e.Tags) {
+		if v, ok := tags[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("tag %s not found, expected %q", k, e.Tags[k]))
+		} else if v != e.Tags[k] {
+			diffs = append(diffs, fmt.Sprintf("tag %s is %q, expected %q", k, v, e.Tags[k]))
+		}
+	}
+
+	for _, k := range sortedKeys(e.Fields) {
+		if v, ok := fields[k]; !ok {
+			diffs = append(diffs, fmt.Sprintf("field %s not found, expected %#v", k, e.Fields[k]))
+		} else if !equalValue(e.Fields[k], v) {
+			diffs = append(diffs, fmt.Sprintf("field %s is %#v, expected %#v", k, v, e.Fields[k]))
+		}
+	}
+
+	for _, k := range e.Absent {
+		if v, ok := tags[k]; ok {
+			diffs = append(diffs, fmt.Sprintf("tag %s is %q, expected absent", k, v))
+		}
+		if v, ok := fields[k]; ok {
+			diffs = append(diffs, fmt.Sprintf("field %s is %#v, expected absent", k, v))
+		}
+	}
+
+	return diffs
+}
+
+// equalValue compares numbers by value, so integer in test file equals to
+// float field.
+func equalValue(expect, actual any) bool {
+	if x, ok := actual.([]byte); ok {
+		actual = string(x)
+	}
+
+	if ef, ok := toFloat(expect); ok {
+		af, ok := toFloat(actual)
+		return ok && ef == af
+	}
+
+	return reflect.DeepEqual(expect, actual)
+}
+
+func toFloat(v any) (float64, bool) {
+	switch x := v.(type) {
+	case int:
+		return float64(x), true
+	case int32:
+		return float64(x), true
+	case int64:
+		return float64(x), true
+	case uint32:
+		return float64(x), true
+	case uint64:
+		return float64(x), true
+	case float32:
+		return float64(x), true
+	case float64:
+		return x, true
+	default:
+		return 0, false
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
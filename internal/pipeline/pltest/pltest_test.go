@@ -0,0 +1,130 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package pltest
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	require.NoError(t, os.MkdirAll(filepath.Dir(path), os.ModePerm))
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+}
+
+func TestRunDir(t *testing.T) {
+	dir := t.TempDir()
+
+	writeFile(t, filepath.Join(dir, "nginx.p"), `
+grok(_, "%{IPORHOST:client_ip} %{WORD:http_method} %{INT:status_code}")
+cast(status_code, "int")
+if status_code >= 500 {
+	add_key(status, "error")
+}
+if http_method == "HEAD" {
+	drop()
+}
+drop_key(message)
+`)
+
+	writeFile(t, filepath.Join(dir, "nginx"+TestFileSuffix), `
+[[case]]
+  name = "ok"
+  text = "127.0.0.1 GET 200"
+  [case.expect]
+    measurement = "nginx"
+    absent = ["message"]
+    [case.expect.fields]
+      client_ip = "127.0.0.1"
+      http_method = "GET"
+      status_code = 200
+      status = "unknown"
+
+[[case]]
+  name = "drop"
+  text = "127.0.0.1 HEAD 200"
+  [case.expect]
+    drop = true
+
+[[case]]
+  name = "mismatch"
+  text = "127.0.0.1 GET 502"
+  source = "web"
+  [case.expect]
+    measurement = "nginx"
+    [case.expect.fields]
+      status_code = 200
+      status = "warning"
+      upstream = "-"
+
+[[case]]
+  point = "nginx message=\"127.0.0.1 GET 200\""
+  [case.expect.fields]
+    status_code = 200.0
+`)
+
+	// script without test file is skipped
+	writeFile(t, filepath.Join(dir, "redis.p"), `add_key(a, 1)`)
+
+	// script failed to compile is reported
+	writeFile(t, filepath.Join(dir, "logging", "bad.p"), `add_key(`)
+
+	writeFile(t, filepath.Join(dir, "metric", "cpu.p"), `set_tag(host, "h1")`)
+	writeFile(t, filepath.Join(dir, "metric", "cpu"+TestFileSuffix), `
+[[case]]
+  point = "cpu,host=h0 usage=1.5"
+  [case.expect.tags]
+    host = "h1"
+  [case.expect.fields]
+    usage = 1.5
+
+[[case]]
+  text = "not supported"
+`)
+
+	res, err := RunDir(dir)
+	require.NoError(t, err)
+	require.Len(t, res, 3)
+
+	cpu := res[0]
+	assert.Equal(t, point.Metric, cpu.Category)
+	require.Len(t, cpu.Cases, 2)
+	assert.True(t, cpu.Cases[0].Passed(), "%v", cpu.Cases[0].Diffs)
+	assert.Error(t, cpu.Cases[1].Err)
+
+	bad := res[1]
+	assert.Equal(t, "bad.p", bad.Script)
+	assert.Error(t, bad.Err)
+	assert.False(t, bad.Passed())
+
+	nginx := res[2]
+	assert.Equal(t, point.Logging, nginx.Category)
+	assert.Equal(t, filepath.Join(dir, "nginx"+TestFileSuffix), nginx.TestFile)
+	require.NoError(t, nginx.Err)
+	require.Len(t, nginx.Cases, 4)
+	assert.True(t, nginx.Cases[0].Passed(), "%v", nginx.Cases[0].Diffs)
+	assert.True(t, nginx.Cases[1].Passed(), "%v", nginx.Cases[1].Diffs)
+	assert.Equal(t, []string{
+		`measurement is "web", expected "nginx"`,
+		`field status is "error", expected "warning"`,
+		`field status_code is 502, expected 200`,
+		`field upstream not found, expected "-"`,
+	}, nginx.Cases[2].Diffs)
+	assert.Equal(t, "case-3", nginx.Cases[3].Name)
+	assert.True(t, nginx.Cases[3].Passed(), "%v", nginx.Cases[3].Diffs)
+	assert.False(t, nginx.Passed())
+}
+
+func TestRunDirNotExist(t *testing.T) {
+	_, err := RunDir(filepath.Join(t.TempDir(), "none"))
+	assert.Error(t, err)
+}
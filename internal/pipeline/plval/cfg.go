@@ -15,6 +15,7 @@ import (
 	"github.com/GuanceCloud/cliutils/pipeline/ptinput/ipdb/iploc"
 	"github.com/GuanceCloud/grok"
 	"github.com/GuanceCloud/platypus/pkg/engine/runtime"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/shadow"
 )
 
 var pipelineDefaultCfg = &PipelineCfg{
@@ -41,6 +42,7 @@ type PipelineCfg struct {
 	Offload                *offload.OffloadConfig `toml:"offload"`
 	EnableDebugFields      bool                   `toml:"-"`
 	DefaultPipeline        map[string]string      `toml:"default_pipeline"`
+	Shadow                 *shadow.Config         `toml:"shadow"`
 
 	DisableHTTPRequestFunc        bool     `toml:"disable_http_request_func"`
 	HTTPRequestHostWhitelist      []string `toml:"http_request_host_whitelist"`
@@ -27,6 +27,7 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/goroutine"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/offload"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/shadow"
 )
 
 var (
@@ -47,6 +48,9 @@ var (
 	// offload.
 	_offloadWkr *offload.OffloadWorker
 
+	// shadow evaluation of updated scripts.
+	_shadow *shadow.Shadow
+
 	_enableAppendRunInfo bool = false
 )
 
@@ -91,7 +95,47 @@ func GetOffload() (*offload.OffloadWorker, bool) {
 	return _offloadWkr, true
 }
 
-const maxCustomer = 16
+func SetShadow(s *shadow.Shadow) {
+	_shadow = s
+}
+
+func GetShadow() (*shadow.Shadow, bool) {
+	if _shadow == nil {
+		return nil, false
+	}
+	return _shadow, true
+}
+
+// LoadScripts loads scripts of the namespace, updated scripts are evaluated
+// in shadow mode before taking effect if shadow enabled.
+func LoadScripts(ns string, scripts map[point.Category]map[string]string, tags map[string]string) {
+	if s, ok := GetShadow(); ok {
+		s.Load(ns, scripts, tags)
+		return
+	}
+
+	if m, ok := GetManager(); ok {
+		m.LoadScripts(ns, scripts, tags)
+	}
+}
+
+// LoadScriptsFromWorkspace loads scripts under pipeline directory plPath, see
+// LoadScripts.
+func LoadScriptsFromWorkspace(ns, plPath string, tags map[string]string) {
+	if plPath == "" {
+		return
+	}
+
+	scripts, _ := plmanager.ReadWorkspaceScripts(plPath)
+	LoadScripts(ns, scripts, tags)
+}
+
+const (
+	maxCustomer = 16
+
+	shadowCheckInterval = 10 * time.Second
+	shadowStateFile     = "pipeline_shadow.json"
+)
 
 var localDefaultPipeline map[point.Category]string
 
@@ -157,6 +201,32 @@ func InitPlVal(cfg *PipelineCfg, upFn plmap.UploadFunc, gTags map[string]string,
 	plmanager.InitStore(managerIns, installDir, nil)
 	SetManager(managerIns)
 
+	// init shadow evaluation
+	if cfg != nil && cfg.Shadow != nil && cfg.Shadow.Enable {
+		s := shadow.New(cfg.Shadow, managerIns.LoadScriptWithCat, filepath.Join(datakit.DataDir, shadowStateFile))
+		SetShadow(s)
+		// scripts loaded on startup take effect directly, unless they are
+		// updated since last run
+		LoadScriptsFromWorkspace(plmanager.NSDefault, filepath.Join(installDir, "pipeline"), nil)
+		l.Infof("pipeline shadow evaluation enabled, sample rate %f", cfg.Shadow.SampleRate)
+
+		g.Go(func(ctx context.Context) error {
+			tick := time.NewTicker(shadowCheckInterval)
+			defer tick.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return nil
+				case <-datakit.Exit.Wait():
+					return nil
+				case <-tick.C:
+					s.CheckTimeout()
+				}
+			}
+		})
+	}
+
 	// init ipdb
 	if ipdb, err := InitIPdb(datakit.DataDir, cfg); err != nil {
 		l.Warnf("init ipdb error: %s", err.Error())
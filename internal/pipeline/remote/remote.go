@@ -245,10 +245,8 @@ func doPull(pathConfig, pathRelation, siteURL string, ipr IPipelineRemote) error
 
 			// cleanup default pipeline
 			managerWkr.UpdateDefaultScript(nil)
-			if m, ok := plval.GetManager(); ok && m != nil {
-				// cleanup all remote scripts
-				m.LoadScripts(plmanager.NSRemote, nil, nil)
-			}
+			// cleanup all remote scripts
+			plval.LoadScripts(plmanager.NSRemote, nil, nil)
 
 			// remove lcoal files
 			if err := removeLocalRemote(ipr); err != nil {
@@ -445,15 +443,10 @@ func convertThreeMapToContentMap(in map[point.Category]map[string]string,
 }
 
 func loadContentPipeline(in map[point.Category]map[string]string) {
-	managerWkr, ok := plval.GetManager()
-	if !ok || managerWkr == nil {
-		return
-	}
-
 	inS := map[point.Category]map[string]string{}
 
 	for cat, val := range in {
 		inS[cat] = val
 	}
-	managerWkr.LoadScripts(plmanager.NSRemote, inS, nil)
+	plval.LoadScripts(plmanager.NSRemote, inS, nil)
 }
@@ -0,0 +1,86 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package shadow
+
+import (
+	"github.com/GuanceCloud/cliutils/metrics"
+	p8s "github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	versionActive    = "active"
+	versionCandidate = "candidate"
+
+	stateEvaluating = 1
+	statePromoted   = 2
+	stateRejected   = 3
+)
+
+var (
+	scriptLabels  = []string{"category", "name", "namespace"}
+	versionLabels = []string{"category", "name", "namespace", "version"}
+
+	sampleVec = p8s.NewCounterVec(
+		p8s.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "pipeline_shadow",
+			Name:      "sample_total",
+			Help:      "Pipeline shadow sampled points",
+		},
+		scriptLabels,
+	)
+
+	failedVec = p8s.NewCounterVec(
+		p8s.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "pipeline_shadow",
+			Name:      "failed_point_total",
+			Help:      "Pipeline shadow sampled points failed by active or candidate script",
+		},
+		versionLabels,
+	)
+
+	dropVec = p8s.NewCounterVec(
+		p8s.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "pipeline_shadow",
+			Name:      "drop_point_total",
+			Help:      "Pipeline shadow sampled points dropped by active or candidate script",
+		},
+		versionLabels,
+	)
+
+	fieldDiffVec = p8s.NewCounterVec(
+		p8s.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "pipeline_shadow",
+			Name:      "field_diff_point_total",
+			Help:      "Pipeline shadow sampled points missing keys extracted by active script",
+		},
+		scriptLabels,
+	)
+
+	stateVec = p8s.NewGaugeVec(
+		p8s.GaugeOpts{
+			Namespace: "datakit",
+			Subsystem: "pipeline_shadow",
+			Name:      "state",
+			Help:      "Pipeline shadow candidate state, 1: evaluating, 2: promoted, 3: rejected",
+		},
+		scriptLabels,
+	)
+)
+
+// nolint:gochecknoinits
+func init() {
+	metrics.MustRegister(
+		sampleVec,
+		failedVec,
+		dropVec,
+		fieldDiffVec,
+		stateVec,
+	)
+}
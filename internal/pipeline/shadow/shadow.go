@@ -0,0 +1,586 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package shadow evaluates updated pipeline scripts before they take effect.
+//
+// When a script of some namespace is updated, the previous version keeps
+// active and the new version becomes a candidate. The candidate runs on a
+// sampled copy of the data alongside the active script, and the results are
+// compared. The candidate is promoted to active if thresholds pass, otherwise
+// it's rejected and the active script keeps running.
+package shadow
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/logger"
+	plmanager "github.com/GuanceCloud/cliutils/pipeline/manager"
+	"github.com/GuanceCloud/cliutils/pipeline/ptinput"
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+var l = logger.DefaultSLogger("pl-shadow")
+
+const (
+	defaultSampleRate          = 0.1
+	defaultMinSamples          = 100
+	defaultMinTimeoutSamples   = 1
+	defaultTimeout             = 30 * time.Minute
+	defaultMaxFailRateIncrease = 0.01
+	defaultMaxDropRateIncrease = 0.01
+	defaultMaxFieldDiffRate    = 0.05
+
+	maxDiffKeys = 16
+
+	// suffix of candidate's namespace, so stats of the candidate are not
+	// mixed with the active one.
+	nsSuffix = "-shadow"
+)
+
+// Config configures shadow evaluation of pipeline scripts.
+type Config struct {
+	Enable bool `toml:"enable"`
+
+	// SampleRate is the ratio of points copied to the candidate.
+	SampleRate float64 `toml:"sample_rate"`
+
+	// Candidate is judged after MinSamples points sampled, or after Timeout
+	// if MinTimeoutSamples points sampled at least.
+	MinSamples        int    `toml:"min_samples"`
+	Timeout           string `toml:"timeout"`
+	MinTimeoutSamples int    `toml:"min_timeout_samples"`
+
+	// Thresholds of the candidate compared with the active script.
+	MaxFailRateIncrease float64 `toml:"max_fail_rate_increase"`
+	MaxDropRateIncrease float64 `toml:"max_drop_rate_increase"`
+	MaxFieldDiffRate    float64 `toml:"max_field_diff_rate"`
+
+	timeout time.Duration
+}
+
+func (c *Config) init() {
+	if c.SampleRate <= 0 || c.SampleRate > 1 {
+		c.SampleRate = defaultSampleRate
+	}
+
+	if c.MinSamples <= 0 {
+		c.MinSamples = defaultMinSamples
+	}
+
+	if c.MinTimeoutSamples <= 0 {
+		c.MinTimeoutSamples = defaultMinTimeoutSamples
+	}
+
+	c.timeout = defaultTimeout
+	if c.Timeout != "" {
+		if du, err := time.ParseDuration(c.Timeout); err != nil {
+			l.Warnf("invalid shadow timeout %q: %s, use default %s", c.Timeout, err, defaultTimeout)
+		} else {
+			c.timeout = du
+		}
+	}
+
+	if c.MaxFailRateIncrease <= 0 {
+		c.MaxFailRateIncrease = defaultMaxFailRateIncrease
+	}
+
+	if c.MaxDropRateIncrease <= 0 {
+		c.MaxDropRateIncrease = defaultMaxDropRateIncrease
+	}
+
+	if c.MaxFieldDiffRate <= 0 {
+		c.MaxFieldDiffRate = defaultMaxFieldDiffRate
+	}
+}
+
+// LoadFunc loads scripts of the category and namespace into the script
+// manager.
+type LoadFunc func(cat point.Category, ns string, scripts, tags map[string]string)
+
+type setKey struct {
+	cat point.Category
+	ns  string
+}
+
+type scriptKey struct {
+	cat  point.Category
+	ns   string
+	name string
+}
+
+type scriptSet struct {
+	scripts map[string]string // active scripts
+	loaded  map[string]string // latest scripts loaded, may be candidates or rejected
+	tags    map[string]string
+}
+
+// Shadow holds candidates of all updated scripts.
+type Shadow struct {
+	cfg       *Config
+	load      LoadFunc
+	stateFile string
+
+	mtx        sync.Mutex
+	sets       map[setKey]*scriptSet
+	restored   map[setKey]map[string]string // active scripts of last run, not loaded yet
+	candidates map[scriptKey]*Candidate
+	rejected   map[scriptKey]string // content hash of rejected candidates
+}
+
+// New creates Shadow, active and rejected scripts are kept in stateFile
+// across restarts, empty stateFile disables it.
+func New(cfg *Config, load LoadFunc, stateFile string) *Shadow {
+	l = logger.SLogger("pl-shadow")
+
+	cfg.init()
+
+	s := &Shadow{
+		cfg:        cfg,
+		load:       load,
+		stateFile:  stateFile,
+		sets:       map[setKey]*scriptSet{},
+		restored:   map[setKey]map[string]string{},
+		candidates: map[scriptKey]*Candidate{},
+		rejected:   map[scriptKey]string{},
+	}
+
+	s.restore()
+
+	return s
+}
+
+// Load loads scripts of the namespace. New and deleted scripts take effect
+// immediately, while updated ones become candidates and the previous version
+// keeps active.
+func (s *Shadow) Load(ns string, scripts map[point.Category]map[string]string, tags map[string]string) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for _, cat := range point.AllCategories() {
+		s.loadWithCat(cat, ns, scripts[cat], tags)
+	}
+
+	s.save()
+}
+
+func (s *Shadow) loadWithCat(cat point.Category, ns string, scripts, tags map[string]string) {
+	sk := setKey{cat: cat, ns: ns}
+	prev := s.sets[sk]
+	if prev == nil {
+		if restored, ok := s.restored[sk]; ok {
+			prev = &scriptSet{scripts: restored}
+			delete(s.restored, sk)
+		}
+	}
+
+	active := map[string]string{}
+
+	for name, content := range scripts {
+		key := scriptKey{cat: cat, ns: ns, name: name}
+
+		var prevContent string
+		var ok bool
+		if prev != nil {
+			prevContent, ok = prev.scripts[name]
+		}
+
+		if !ok || prevContent == content { // new or not changed
+			active[name] = content
+			s.removeCandidate(key)
+			s.removeRejected(key)
+			continue
+		}
+
+		active[name] = prevContent
+
+		if c, ok := s.candidates[key]; ok && c.content == content {
+			continue // already in evaluation
+		}
+
+		if s.rejected[key] == contentHash(content) {
+			continue // already rejected
+		}
+
+		s.removeCandidate(key)
+
+		c, err := newCandidate(key, content, scripts, tags)
+		if err != nil {
+			l.Warnf("reject pipeline %s/%s/%s: %s", cat, ns, name, err)
+			s.rejected[key] = contentHash(content)
+			stateVec.WithLabelValues(cat.String(), name, ns).Set(stateRejected)
+			continue
+		}
+
+		l.Infof("pipeline %s/%s/%s updated, evaluating in shadow mode", cat, ns, name)
+		s.candidates[key] = c
+		stateVec.WithLabelValues(cat.String(), name, ns).Set(stateEvaluating)
+	}
+
+	// drop candidates of deleted scripts
+	if prev != nil {
+		for name := range prev.scripts {
+			if _, ok := scripts[name]; !ok {
+				key := scriptKey{cat: cat, ns: ns, name: name}
+				s.removeCandidate(key)
+				s.removeRejected(key)
+			}
+		}
+	}
+
+	s.sets[sk] = &scriptSet{scripts: active, loaded: scripts, tags: tags}
+	s.load(cat, ns, active, tags)
+}
+
+func (s *Shadow) removeCandidate(key scriptKey) {
+	if c, ok := s.candidates[key]; ok {
+		c.release()
+		delete(s.candidates, key)
+		stateVec.DeleteLabelValues(key.cat.String(), key.name, key.ns)
+	}
+}
+
+func (s *Shadow) removeRejected(key scriptKey) {
+	if _, ok := s.rejected[key]; ok {
+		delete(s.rejected, key)
+		stateVec.DeleteLabelValues(key.cat.String(), key.name, key.ns)
+	}
+}
+
+// Sample returns candidate of the active script if the point should be
+// copied to it.
+func (s *Shadow) Sample(active *plmanager.PlScript) (*Candidate, bool) {
+	if active == nil {
+		return nil, false
+	}
+
+	s.mtx.Lock()
+	c, ok := s.candidates[scriptKey{cat: active.Category(), ns: active.NS(), name: active.Name()}]
+	s.mtx.Unlock()
+
+	if !ok || rand.Float64() >= s.cfg.SampleRate { //nolint:gosec
+		return nil, false
+	}
+
+	return c, true
+}
+
+// CopyPoint deep-copies the point before the active script runs on it.
+func CopyPoint(pt *point.Point) *point.Point {
+	kvs := append(point.NewTags(pt.MapTags()), point.NewKVs(pt.InfluxFields())...)
+	return point.NewPointV2(pt.Name(), kvs, point.WithTime(pt.Time()))
+}
+
+// Evaluate runs the candidate on the copied point in, and compares the result
+// with output of the active script.
+func (s *Shadow) Evaluate(c *Candidate, in, active ptinput.PlInputPt, activeErr error, opt *plmanager.Option) {
+	inKeys := keysOf(in)
+
+	candErr := c.script.Run(in, nil, opt)
+
+	ar := newResult(active, activeErr, inKeys)
+	cr := newResult(in, candErr, inKeys)
+
+	c.observe(ar, cr)
+
+	s.judge(c, false)
+}
+
+// CheckTimeout judges candidates evaluated longer than timeout, so candidates
+// without traffic are judged too.
+func (s *Shadow) CheckTimeout() {
+	s.mtx.Lock()
+	arr := make([]*Candidate, 0, len(s.candidates))
+	for _, c := range s.candidates {
+		arr = append(arr, c)
+	}
+	s.mtx.Unlock()
+
+	for _, c := range arr {
+		s.judge(c, true)
+	}
+}
+
+func (s *Shadow) judge(c *Candidate, checkTimeout bool) {
+	st := c.stats()
+	if st.samples < s.cfg.MinSamples {
+		// Candidates without enough samples keep evaluating after timeout,
+		// they are never promoted blindly.
+		if !checkTimeout || time.Since(c.start) < s.cfg.timeout || st.samples < s.cfg.MinTimeoutSamples {
+			return
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.candidates[c.key] != c { // judged or replaced
+		return
+	}
+
+	delete(s.candidates, c.key)
+	c.release()
+
+	defer s.save()
+
+	lbs := []string{c.key.cat.String(), c.key.name, c.key.ns}
+	reasons := st.check(s.cfg)
+	if len(reasons) > 0 {
+		l.Warnf("reject pipeline %s/%s/%s after %d samples: %s",
+			c.key.cat, c.key.ns, c.key.name, st.samples, strings.Join(reasons, "; "))
+		s.rejected[c.key] = contentHash(c.content)
+		stateVec.WithLabelValues(lbs...).Set(stateRejected)
+		return
+	}
+
+	l.Infof("promote pipeline %s/%s/%s after %d samples", c.key.cat, c.key.ns, c.key.name, st.samples)
+	stateVec.WithLabelValues(lbs...).Set(statePromoted)
+
+	set, ok := s.sets[setKey{cat: c.key.cat, ns: c.key.ns}]
+	if !ok {
+		return
+	}
+
+	set.scripts[c.key.name] = c.content
+	s.load(c.key.cat, c.key.ns, set.scripts, set.tags)
+}
+
+// Promote forces the latest loaded version of the script to take effect,
+// whether it's in evaluation or rejected.
+func (s *Shadow) Promote(cat point.Category, ns, name string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	set, ok := s.sets[setKey{cat: cat, ns: ns}]
+	if !ok {
+		return fmt.Errorf("pipeline %s/%s/%s not found", cat, ns, name)
+	}
+
+	content, ok := set.loaded[name]
+	if !ok {
+		return fmt.Errorf("pipeline %s/%s/%s not found", cat, ns, name)
+	}
+
+	if set.scripts[name] == content {
+		return fmt.Errorf("pipeline %s/%s/%s not updated", cat, ns, name)
+	}
+
+	key := scriptKey{cat: cat, ns: ns, name: name}
+	s.removeCandidate(key)
+	s.removeRejected(key)
+
+	l.Infof("force promote pipeline %s/%s/%s", cat, ns, name)
+	stateVec.WithLabelValues(cat.String(), name, ns).Set(statePromoted)
+
+	set.scripts[name] = content
+	s.load(cat, ns, set.scripts, set.tags)
+	s.save()
+
+	return nil
+}
+
+// Candidate is the updated script in evaluation.
+type Candidate struct {
+	key     scriptKey
+	content string
+	store   *plmanager.ScriptStore
+	script  *plmanager.PlScript
+	start   time.Time
+
+	mtx sync.Mutex
+	st  stats
+
+	diffKeys map[string]struct{}
+}
+
+type stats struct {
+	samples,
+	activeFailed,
+	candidateFailed,
+	activeDropped,
+	candidateDropped,
+	fieldDiffs int
+
+	diffKeys []string
+}
+
+func rate(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(n) / float64(total)
+}
+
+// check returns reasons why the candidate failed.
+func (st *stats) check(cfg *Config) []string {
+	var reasons []string
+
+	if x := rate(st.candidateFailed, st.samples) - rate(st.activeFailed, st.samples); x > cfg.MaxFailRateIncrease {
+		reasons = append(reasons, fmt.Sprintf("failure rate increased %.2f%%", x*100))
+	}
+
+	if x := rate(st.candidateDropped, st.samples) - rate(st.activeDropped, st.samples); x > cfg.MaxDropRateIncrease {
+		reasons = append(reasons, fmt.Sprintf("drop rate increased %.2f%%", x*100))
+	}
+
+	if x := rate(st.fieldDiffs, st.samples); x > cfg.MaxFieldDiffRate {
+		reasons = append(reasons, fmt.Sprintf("%.2f%% points lost keys(%s)", x*100, strings.Join(st.diffKeys, ",")))
+	}
+
+	return reasons
+}
+
+// newCandidate compiles the updated script together with other scripts of
+// the namespace, which may be imported by it.
+func newCandidate(key scriptKey, content string, scripts, tags map[string]string) (*Candidate, error) {
+	ns := key.ns + nsSuffix
+	store := plmanager.NewScriptStore(key.cat, plmanager.NewManagerCfg(nil, nil))
+
+	if err, ok := store.UpdateScriptsWithNS(ns, scripts, tags)[key.name]; ok {
+		store.UpdateScriptsWithNS(ns, nil, nil)
+		return nil, fmt.Errorf("compile failed: %w", err)
+	}
+
+	script, ok := store.GetWithNs(key.name, ns)
+	if !ok {
+		store.UpdateScriptsWithNS(ns, nil, nil)
+		return nil, fmt.Errorf("script not found")
+	}
+
+	return &Candidate{
+		key:      key,
+		content:  content,
+		store:    store,
+		script:   script,
+		start:    time.Now(),
+		diffKeys: map[string]struct{}{},
+	}, nil
+}
+
+// release stops background workers of compiled scripts.
+func (c *Candidate) release() {
+	c.store.UpdateScriptsWithNS(c.key.ns+nsSuffix, nil, nil)
+}
+
+func (c *Candidate) observe(active, cand *result) {
+	lbs := []string{c.key.cat.String(), c.key.name, c.key.ns}
+
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.st.samples++
+	sampleVec.WithLabelValues(lbs...).Inc()
+
+	if active.failed {
+		c.st.activeFailed++
+		failedVec.WithLabelValues(append(lbs, versionActive)...).Inc()
+	}
+
+	if cand.failed {
+		c.st.candidateFailed++
+		failedVec.WithLabelValues(append(lbs, versionCandidate)...).Inc()
+	}
+
+	if active.dropped {
+		c.st.activeDropped++
+		dropVec.WithLabelValues(append(lbs, versionActive)...).Inc()
+	}
+
+	if cand.dropped {
+		c.st.candidateDropped++
+		dropVec.WithLabelValues(append(lbs, versionCandidate)...).Inc()
+	}
+
+	if active.err != nil || cand.err != nil || active.dropped || cand.dropped {
+		return
+	}
+
+	// Keys added by the candidate are expected on script updates, only keys
+	// lost compared with the active script are regressions.
+	if lost := lostKeys(active.keys, cand.keys); len(lost) > 0 {
+		c.st.fieldDiffs++
+		fieldDiffVec.WithLabelValues(lbs...).Inc()
+
+		for _, k := range lost {
+			if len(c.diffKeys) >= maxDiffKeys {
+				break
+			}
+			c.diffKeys[k] = struct{}{}
+		}
+	}
+}
+
+func (c *Candidate) stats() stats {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	st := c.st
+	for k := range c.diffKeys {
+		st.diffKeys = append(st.diffKeys, k)
+	}
+	sort.Strings(st.diffKeys)
+
+	return st
+}
+
+type result struct {
+	err     error
+	failed  bool
+	dropped bool
+	keys    map[string]struct{}
+}
+
+// newResult checks output of the script. The script failed if it returned
+// error, or nothing extracted, such as a broken grok leaves the raw message
+// only.
+func newResult(pt ptinput.PlInputPt, err error, inKeys map[string]struct{}) *result {
+	r := &result{err: err, dropped: err == nil && pt.Dropped()}
+	if err != nil {
+		r.failed = true
+		return r
+	}
+
+	r.keys = keysOf(pt)
+	if r.dropped {
+		return r
+	}
+
+	r.failed = true
+	for k := range r.keys {
+		if _, ok := inKeys[k]; !ok && k != plmanager.FieldStatus {
+			r.failed = false
+			break
+		}
+	}
+
+	return r
+}
+
+func keysOf(pt ptinput.PlInputPt) map[string]struct{} {
+	keys := map[string]struct{}{}
+	for k := range pt.Tags() {
+		keys[k] = struct{}{}
+	}
+	for k := range pt.Fields() {
+		keys[k] = struct{}{}
+	}
+	return keys
+}
+
+// lostKeys returns keys of active missing in cand.
+func lostKeys(active, cand map[string]struct{}) []string {
+	var lost []string
+	for k := range active {
+		if _, ok := cand[k]; !ok {
+			lost = append(lost, k)
+		}
+	}
+	sort.Strings(lost)
+	return lost
+}
@@ -0,0 +1,286 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package shadow
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	plmanager "github.com/GuanceCloud/cliutils/pipeline/manager"
+	"github.com/GuanceCloud/cliutils/pipeline/ptinput"
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	goodScript   = `grok(_, "%{INT:code} %{WORD:method}")`
+	goodScriptV2 = `grok(_, "%{INT:code} %{WORD:method}")
+cast(code, "int")`
+	brokenScript = `grok(_, "%{WORD:method} %{INT:code}")`
+)
+
+// fakeManager records scripts loaded and runs them as the active version.
+type fakeManager struct {
+	store *plmanager.ScriptStore
+	loads []map[string]string
+}
+
+func newFakeManager() *fakeManager {
+	return &fakeManager{
+		store: plmanager.NewScriptStore(point.Logging, plmanager.NewManagerCfg(nil, nil)),
+	}
+}
+
+func (m *fakeManager) load(cat point.Category, ns string, scripts, tags map[string]string) {
+	if cat != point.Logging {
+		return
+	}
+
+	cp := map[string]string{}
+	for k, v := range scripts {
+		cp[k] = v
+	}
+	m.loads = append(m.loads, cp)
+	m.store.UpdateScriptsWithNS(ns, scripts, tags)
+}
+
+func (m *fakeManager) active() map[string]string {
+	if len(m.loads) == 0 {
+		return nil
+	}
+	return m.loads[len(m.loads)-1]
+}
+
+// feed runs n points through the active script and the sampled candidate.
+func (m *fakeManager) feed(t *testing.T, s *Shadow, name string, n int) {
+	t.Helper()
+
+	for i := 0; i < n; i++ {
+		script, ok := m.store.GetWithNs(name, plmanager.NSRemote)
+		require.True(t, ok)
+
+		pt := point.NewPointV2("nginx",
+			point.NewKVs(map[string]any{"message": "200 GET"}),
+			append(point.DefaultLoggingOptions(), point.WithTime(time.Now()))...)
+
+		c, ok := s.Sample(script)
+		if !ok {
+			return
+		}
+
+		cp := CopyPoint(pt)
+		active := ptinput.PtWrap(point.Logging, pt)
+		err := script.Run(active, nil, nil)
+
+		s.Evaluate(c, ptinput.PtWrap(point.Logging, cp), active, err, nil)
+	}
+}
+
+func newTestShadow(m *fakeManager) *Shadow {
+	return New(&Config{Enable: true, SampleRate: 1, MinSamples: 10}, m.load, "")
+}
+
+func logging(scripts map[string]string) map[point.Category]map[string]string {
+	return map[point.Category]map[string]string{point.Logging: scripts}
+}
+
+func TestPromote(t *testing.T) {
+	m := newFakeManager()
+	s := newTestShadow(m)
+
+	// new script takes effect directly
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript}), nil)
+	assert.Equal(t, goodScript, m.active()["nginx.p"])
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2}), nil)
+	assert.Equal(t, goodScript, m.active()["nginx.p"])
+	require.Len(t, s.candidates, 1)
+
+	// reload the same candidate is ignored
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2}), nil)
+	require.Len(t, s.candidates, 1)
+
+	m.feed(t, s, "nginx.p", 9)
+	assert.Equal(t, goodScript, m.active()["nginx.p"])
+
+	m.feed(t, s, "nginx.p", 1)
+	assert.Equal(t, goodScriptV2, m.active()["nginx.p"])
+	assert.Empty(t, s.candidates)
+}
+
+func TestReject(t *testing.T) {
+	m := newFakeManager()
+	s := newTestShadow(m)
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript}), nil)
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": brokenScript}), nil)
+
+	c := s.candidates[scriptKey{cat: point.Logging, ns: plmanager.NSRemote, name: "nginx.p"}]
+	require.NotNil(t, c)
+
+	m.feed(t, s, "nginx.p", 10)
+	assert.Equal(t, goodScript, m.active()["nginx.p"])
+	assert.Empty(t, s.candidates)
+
+	st := c.stats()
+	assert.Equal(t, 10, st.samples)
+	assert.Equal(t, 0, st.activeFailed)
+	assert.Equal(t, 10, st.candidateFailed)
+	assert.NotEmpty(t, st.check(s.cfg))
+
+	// rejected version is not evaluated again
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": brokenScript}), nil)
+	assert.Empty(t, s.candidates)
+
+	// deleted script takes effect directly
+	s.Load(plmanager.NSRemote, nil, nil)
+	assert.Empty(t, m.active())
+	assert.Empty(t, s.rejected)
+}
+
+func TestPromoteAddedKeys(t *testing.T) {
+	m := newFakeManager()
+	s := newTestShadow(m)
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript}), nil)
+
+	// extract one more field
+	v2 := goodScript + "\nadd_key(version, \"v2\")"
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": v2}), nil)
+	require.Len(t, s.candidates, 1)
+
+	m.feed(t, s, "nginx.p", 10)
+	assert.Equal(t, v2, m.active()["nginx.p"])
+	assert.Empty(t, s.rejected)
+}
+
+func TestForcePromote(t *testing.T) {
+	m := newFakeManager()
+	s := newTestShadow(m)
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript, "redis.p": goodScript}), nil)
+
+	assert.Error(t, s.Promote(point.Logging, plmanager.NSRemote, "nginx.p"), "not updated")
+	assert.Error(t, s.Promote(point.Logging, plmanager.NSRemote, "mysql.p"), "not found")
+	assert.Error(t, s.Promote(point.Metric, plmanager.NSRemote, "nginx.p"), "not found")
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2, "redis.p": brokenScript}), nil)
+	m.feed(t, s, "redis.p", 10)
+	require.Len(t, s.candidates, 1)
+	require.Len(t, s.rejected, 1)
+
+	// candidate in evaluation
+	require.NoError(t, s.Promote(point.Logging, plmanager.NSRemote, "nginx.p"))
+	assert.Equal(t, goodScriptV2, m.active()["nginx.p"])
+	assert.Empty(t, s.candidates)
+
+	// rejected version
+	require.NoError(t, s.Promote(point.Logging, plmanager.NSRemote, "redis.p"))
+	assert.Equal(t, brokenScript, m.active()["redis.p"])
+	assert.Empty(t, s.rejected)
+
+	// reload keeps promoted versions
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2, "redis.p": brokenScript}), nil)
+	assert.Equal(t, map[string]string{"nginx.p": goodScriptV2, "redis.p": brokenScript}, m.active())
+	assert.Empty(t, s.candidates)
+}
+
+func TestCompileError(t *testing.T) {
+	m := newFakeManager()
+	s := newTestShadow(m)
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript}), nil)
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": `add_key(`}), nil)
+
+	assert.Empty(t, s.candidates)
+	assert.Len(t, s.rejected, 1)
+	assert.Equal(t, goodScript, m.active()["nginx.p"])
+}
+
+func TestCheckTimeout(t *testing.T) {
+	m := newFakeManager()
+	s := New(&Config{Enable: true, SampleRate: 1, Timeout: "1ms", MinTimeoutSamples: 2}, m.load, "")
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript}), nil)
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2}), nil)
+	require.Len(t, s.candidates, 1)
+
+	time.Sleep(time.Millisecond * 10)
+	s.CheckTimeout()
+
+	// not promoted without enough samples
+	require.Len(t, s.candidates, 1)
+	assert.Equal(t, goodScript, m.active()["nginx.p"])
+
+	m.feed(t, s, "nginx.p", 1)
+	s.CheckTimeout()
+	require.Len(t, s.candidates, 1)
+
+	m.feed(t, s, "nginx.p", 1)
+	s.CheckTimeout()
+	assert.Empty(t, s.candidates)
+	assert.Equal(t, goodScriptV2, m.active()["nginx.p"])
+}
+
+func TestRestore(t *testing.T) {
+	stateFile := filepath.Join(t.TempDir(), "data", "pipeline_shadow.json")
+
+	m := newFakeManager()
+	s := New(&Config{Enable: true, SampleRate: 1, MinSamples: 10}, m.load, stateFile)
+
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScript, "redis.p": goodScript}), nil)
+	s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2, "redis.p": brokenScript}), nil)
+	m.feed(t, s, "nginx.p", 10)
+	m.feed(t, s, "redis.p", 10)
+	assert.Equal(t, map[string]string{"nginx.p": goodScriptV2, "redis.p": goodScript}, m.active())
+	require.FileExists(t, stateFile)
+
+	t.Run("unchanged", func(t *testing.T) {
+		m := newFakeManager()
+		s := New(&Config{Enable: true, SampleRate: 1, MinSamples: 10}, m.load, stateFile)
+
+		// rejected script not promoted after restart
+		s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": goodScriptV2, "redis.p": brokenScript}), nil)
+		assert.Equal(t, map[string]string{"nginx.p": goodScriptV2, "redis.p": goodScript}, m.active())
+		assert.Empty(t, s.candidates)
+	})
+
+	t.Run("updated-during-restart", func(t *testing.T) {
+		m := newFakeManager()
+		s := New(&Config{Enable: true, SampleRate: 1, MinSamples: 10}, m.load, stateFile)
+
+		s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": brokenScript, "redis.p": goodScript}), nil)
+		assert.Equal(t, map[string]string{"nginx.p": goodScriptV2, "redis.p": goodScript}, m.active())
+		require.Len(t, s.candidates, 1, "updated script evaluated")
+		assert.Empty(t, s.rejected, "rejected script reverted")
+	})
+
+	t.Run("invalid-state", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(stateFile, []byte("{"), 0o600))
+
+		m := newFakeManager()
+		s := New(&Config{Enable: true, SampleRate: 1, MinSamples: 10}, m.load, stateFile)
+		s.Load(plmanager.NSRemote, logging(map[string]string{"nginx.p": brokenScript}), nil)
+		assert.Equal(t, brokenScript, m.active()["nginx.p"])
+	})
+}
+
+func TestLostKeys(t *testing.T) {
+	set := func(keys ...string) map[string]struct{} {
+		m := map[string]struct{}{}
+		for _, k := range keys {
+			m[k] = struct{}{}
+		}
+		return m
+	}
+
+	assert.Empty(t, lostKeys(set("a", "b"), set("b", "a")))
+	assert.Empty(t, lostKeys(set("a", "b"), set("a", "b", "c")), "added keys")
+	assert.Equal(t, []string{"a"}, lostKeys(set("a", "b"), set("b", "c")))
+}
@@ -0,0 +1,152 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package shadow
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+// savedScript is the state of a script kept across restarts: the active
+// content, and the hash of the rejected content if any.
+type savedScript struct {
+	Category string `json:"category"`
+	NS       string `json:"ns"`
+	Name     string `json:"name"`
+	Active   string `json:"active,omitempty"`
+	Rejected string `json:"rejected,omitempty"`
+}
+
+func contentHash(content string) string {
+	h := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(h[:])
+}
+
+// restore loads state saved on last run. Active scripts of a namespace are
+// used as the previous version on the first load of the namespace, so
+// scripts updated during restart are still evaluated, and rejected ones are
+// not promoted.
+func (s *Shadow) restore() {
+	if s.stateFile == "" {
+		return
+	}
+
+	data, err := os.ReadFile(s.stateFile)
+	if err != nil {
+		if !errors.Is(err, os.ErrNotExist) {
+			l.Warnf("read shadow state %s: %s, ignored", s.stateFile, err)
+		}
+		return
+	}
+
+	var arr []*savedScript
+	if err := json.Unmarshal(data, &arr); err != nil {
+		l.Warnf("invalid shadow state %s: %s, ignored", s.stateFile, err)
+		return
+	}
+
+	for _, x := range arr {
+		cat := point.CatString(x.Category)
+		if cat == point.UnknownCategory {
+			continue
+		}
+
+		if x.Active != "" {
+			sk := setKey{cat: cat, ns: x.NS}
+			if s.restored[sk] == nil {
+				s.restored[sk] = map[string]string{}
+			}
+			s.restored[sk][x.Name] = x.Active
+		}
+
+		if x.Rejected != "" {
+			s.rejected[scriptKey{cat: cat, ns: x.NS, name: x.Name}] = x.Rejected
+			stateVec.WithLabelValues(cat.String(), x.Name, x.NS).Set(stateRejected)
+		}
+	}
+
+	l.Infof("%d pipeline shadow states restored from %s", len(arr), s.stateFile)
+}
+
+// save writes active and rejected scripts into state file, s.mtx should be
+// held.
+func (s *Shadow) save() {
+	if s.stateFile == "" {
+		return
+	}
+
+	scripts := map[scriptKey]*savedScript{}
+	get := func(key scriptKey) *savedScript {
+		x, ok := scripts[key]
+		if !ok {
+			x = &savedScript{Category: key.cat.String(), NS: key.ns, Name: key.name}
+			scripts[key] = x
+		}
+		return x
+	}
+
+	// namespaces not loaded yet since restart
+	for sk, set := range s.restored {
+		for name, content := range set {
+			get(scriptKey{cat: sk.cat, ns: sk.ns, name: name}).Active = content
+		}
+	}
+
+	for sk, set := range s.sets {
+		for name, content := range set.scripts {
+			get(scriptKey{cat: sk.cat, ns: sk.ns, name: name}).Active = content
+		}
+	}
+
+	for key, hash := range s.rejected {
+		get(key).Rejected = hash
+	}
+
+	arr := make([]*savedScript, 0, len(scripts))
+	for _, x := range scripts {
+		arr = append(arr, x)
+	}
+
+	sort.Slice(arr, func(i, j int) bool {
+		a, b := arr[i], arr[j]
+		if a.Category != b.Category {
+			return a.Category < b.Category
+		}
+		if a.NS != b.NS {
+			return a.NS < b.NS
+		}
+		return a.Name < b.Name
+	})
+
+	data, err := json.Marshal(arr)
+	if err != nil {
+		l.Warnf("marshal shadow state: %s", err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(s.stateFile), 0o750); err != nil {
+		l.Warnf("save shadow state: %s", err)
+		return
+	}
+
+	// write then rename, so the state file is never partially written
+	tmp := s.stateFile + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		l.Warnf("save shadow state: %s", err)
+		return
+	}
+
+	if err := os.Rename(tmp, s.stateFile); err != nil {
+		l.Warnf("save shadow state: %s", err)
+	}
+}
@@ -12,8 +12,11 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/GuanceCloud/cliutils/point"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/recorder"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/testutils"
 )
 
@@ -83,6 +86,29 @@ func TestGetMetric(t *testing.T) {
 	t.Logf(p.String())
 }
 
+func TestGolden(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, testdata)
+	}))
+	defer ts.Close()
+
+	n := Input{
+		URL:    ts.URL + "/server_status",
+		Tagger: testutils.NewTaggerHost(),
+	}
+
+	client, err := n.createHTTPClient()
+	require.NoError(t, err)
+	n.client = client
+
+	pt, err := n.getMetric()
+	require.NoError(t, err)
+
+	// url changes with port of the test server
+	testutils.AssertGolden(t, "testdata/apache.pbjson", []*point.Point{pt},
+		recorder.WithIgnoreKeys("url"), recorder.WithCompareValues(true))
+}
+
 func TestInput_setHost(t *testing.T) {
 	type fields struct {
 		URL string
@@ -49,10 +49,8 @@ func handleInput(confdInputs map[string][]*ConfdInfo, handleList []handle, ctx c
 			case 3:
 				l.Info("before set pipelines")
 
-				if m, ok := plval.GetManager(); ok && m != nil {
-					m.LoadScriptsFromWorkspace(plmanager.NSConfd,
-						datakit.ConfdPipelineDir, nil)
-				}
+				plval.LoadScriptsFromWorkspace(plmanager.NSConfd,
+					datakit.ConfdPipelineDir, nil)
 			}
 		}
 
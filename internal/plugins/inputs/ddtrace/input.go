@@ -88,6 +88,19 @@ const (
   # [inputs.ddtrace.sampler]
   #   sampling_rate = 1.0
 
+  ## Topology config uses to compute service-to-service call edges from spans.
+  ## interval is the period of reporting edges as metric and object.
+  ## peer_tags are span tags used to find the callee on client spans when callee is not instrumented.
+  ## max_edges limits the edges kept in memory within one interval.
+  ## span_ttl is how long spans are kept to match parent and child spans sent in different requests.
+  ## max_spans limits the spans kept for matching.
+  # [inputs.ddtrace.topology]
+  #   interval = "1m"
+  #   peer_tags = ["peer_service", "db_system", "http_host", "net_peer_name"]
+  #   max_edges = 10000
+  #   span_ttl = "30s"
+  #   max_spans = 100000
+
   # [inputs.ddtrace.tags]
   #   key1 = "value1"
   #   key2 = "value2"
@@ -140,6 +153,7 @@ type Input struct {
 	OmitErrStatus    []string                     `toml:"omit_err_status"`
 	CloseResource    map[string][]string          `toml:"close_resource"`
 	Sampler          *itrace.Sampler              `toml:"sampler"`
+	Topology         *itrace.Topology             `toml:"topology"`
 	Tags             map[string]string            `toml:"tags"`
 	WPConfig         *workerpool.WorkerPoolConfig `toml:"threads"`
 	LocalCacheConfig *storage.StorageConfig       `toml:"storage"`
@@ -162,7 +176,7 @@ func (*Input) AvailableArchs() []string { return datakit.AllOS }
 func (*Input) SampleConfig() string { return sampleConfig }
 
 func (*Input) SampleMeasurement() []inputs.Measurement {
-	return []inputs.Measurement{&itrace.TraceMeasurement{Name: inputName}, &Telemetry{}}
+	return []inputs.Measurement{&itrace.TraceMeasurement{Name: inputName}, &Telemetry{}, &itrace.TopologyMeasurement{}}
 }
 
 func (ipt *Input) RegHTTPHandler() {
@@ -257,6 +271,12 @@ func (ipt *Input) RegHTTPHandler() {
 	}
 	afterGatherRun = afterGather
 
+	// add service topology
+	if ipt.Topology != nil {
+		afterGather.SetTopology(ipt.Topology.Init(inputName, ipt.feeder, log))
+		ipt.Topology.Start()
+	}
+
 	// add filters: the order of appending filters into AfterGather is important!!!
 	// the order of appending represents the order of that filter executes.
 	// add close resource filter
@@ -349,6 +369,9 @@ func (ipt *Input) exit() {
 		}
 		log.Debug("### local storage closed")
 	}
+	if ipt.Topology != nil {
+		ipt.Topology.Stop()
+	}
 }
 
 func (ipt *Input) Terminate() {
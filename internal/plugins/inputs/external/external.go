@@ -21,7 +21,9 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/config"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/goroutine"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/plugin"
 )
 
 const (
@@ -49,6 +51,20 @@ const (
     election = false
     args = []
 
+    # Run the external program as supervised plugin: Datakit pass plugin_config
+    # to it, restart it if it exit or no heartbeat received within heartbeat_timeout,
+    # and points sent via the plugin protocol. Daemon and interval are ignored.
+    #plugin = false
+    #heartbeat_timeout = "30s"
+    #plugin_config = '''
+    #  key = "value"
+    #'''
+
+    # CPU(percent, max is 100) and memory(MB) limit of the program, plugin mode only.
+    #[inputs.external.resource_limit]
+    #  cpu_max = 10.0
+    #  mem_max_mb = 512
+
     [[inputs.external.tags]]
         # tag1 = "val1"
         # tag2 = "val2"
@@ -71,6 +87,11 @@ type Input struct {
 	Args     []string          `toml:"args"`
 	Tags     map[string]string `toml:"tags"`
 
+	Plugin           bool                  `toml:"plugin"`
+	PluginConfig     string                `toml:"plugin_config"`
+	HeartbeatTimeout time.Duration         `toml:"heartbeat_timeout"`
+	ResourceLimit    *plugin.ResourceLimit `toml:"resource_limit"`
+
 	cmd      *exec.Cmd      `toml:"-"`
 	duration time.Duration  `toml:"-"`
 	Query    []*customQuery `toml:"custom_queries"`
@@ -78,6 +99,7 @@ type Input struct {
 	semStop        *cliutils.Sem // start stop signal
 	semStopProcess *cliutils.Sem
 	Tagger         datakit.GlobalTagger
+	feeder         dkio.Feeder
 	procExitReply  chan struct{}
 
 	daemonStarted bool
@@ -101,6 +123,7 @@ func NewInput() *Input {
 		semStop:        cliutils.NewSem(),
 		semStopProcess: cliutils.NewSem(),
 		Tagger:         datakit.DefaultGlobalTagger(),
+		feeder:         dkio.DefaultFeeder(),
 		Election:       true,
 		pauseCh:        make(chan bool, inputs.ElectionPauseChannelLength),
 	}
@@ -183,6 +206,11 @@ func (ipt *Input) Run() {
 
 	l.Infof("starting external input %s...", ipt.Name)
 
+	if ipt.Plugin {
+		ipt.runPlugin()
+		return
+	}
+
 	tagsStr := ""
 	arr := []string{}
 	for tagKey, tagVal := range ipt.Tags {
@@ -253,6 +281,73 @@ func (ipt *Input) Run() {
 	}
 }
 
+// runPlugin run the program under plugin supervisor, the supervisor stopped
+// on election pause and started again on resume.
+func (ipt *Input) runPlugin() {
+	var (
+		cancel context.CancelFunc
+		exited chan struct{}
+	)
+
+	start := func() {
+		var ctx context.Context
+		ctx, cancel = context.WithCancel(context.Background())
+		exited = make(chan struct{})
+
+		s := plugin.NewSupervisor(ipt.Name, ipt.Cmd,
+			plugin.WithArgs(ipt.Args...),
+			plugin.WithEnvs(ipt.Envs...),
+			plugin.WithConfig(ipt.PluginConfig),
+			plugin.WithTags(ipt.Tags),
+			plugin.WithHeartbeatTimeout(ipt.HeartbeatTimeout),
+			plugin.WithResourceLimit(ipt.ResourceLimit),
+			plugin.WithFeeder(ipt.feeder),
+			plugin.WithElection(ipt.Election),
+		)
+
+		go func() {
+			defer close(exited)
+			if err := s.Run(ctx); err != nil {
+				l.Errorf("external plugin %s: %s", ipt.Name, err)
+			}
+		}()
+	}
+
+	stop := func() {
+		if cancel != nil {
+			cancel()
+			<-exited
+			cancel = nil
+		}
+	}
+
+	defer stop()
+
+	if !ipt.pause {
+		start()
+	}
+
+	for {
+		select {
+		case <-datakit.Exit.Wait():
+			l.Infof("external input %s exiting", ipt.Name)
+			return
+
+		case <-ipt.semStop.Wait():
+			l.Infof("external input %s stopped", ipt.Name)
+			return
+
+		case ipt.pause = <-ipt.pauseCh:
+			if ipt.pause {
+				l.Infof("%s paused", ipt.Name)
+				stop()
+			} else if cancel == nil {
+				start()
+			}
+		}
+	}
+}
+
 func (ipt *Input) queryToBytes() []byte {
 	var buffer bytes.Buffer
 	enc := gob.NewEncoder(&buffer)
@@ -627,7 +627,8 @@ func (ipt *Input) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 
 	if err := ipt.feeder.FeedV2(point.Logging, pts,
 		dkio.WithElection(ipt.Election),
-		dkio.WithInputName("gitlab_ci")); err != nil {
+		dkio.WithInputName("gitlab_ci"),
+		dkio.WithRequestHandler(true)); err != nil {
 		ipt.feeder.FeedLastError(err.Error(),
 			metrics.WithLastErrorInput(inputName),
 			metrics.WithLastErrorCategory(point.Logging),
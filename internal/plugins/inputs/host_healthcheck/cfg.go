@@ -66,6 +66,75 @@ const (
       # [inputs.host_healthcheck.http.headers]
         # Header1 = "header-value-1"
         # Hedaer2 = "header-value-2"
+
+  ## Each check support its own interval and tags, the interval is rounded up to
+  ## multiple of the input's interval, e.g.
+  # [[inputs.host_healthcheck.tcp]]
+    # host_ports = ["127.0.0.1:6379"]
+    # interval = "5m"
+    # [inputs.host_healthcheck.tcp.tags]
+      # service = "redis"
+
+  ## Check multi-step HTTP, steps share cookies, so the login step's session is reused
+  # [[inputs.host_healthcheck.http_flow]]
+    # name = "login-and-query"
+    # timeout = "30s"
+    # ignore_insecure_tls = false
+
+    # [[inputs.host_healthcheck.http_flow.steps]]
+      # name = "login"
+      # method = "POST"
+      # url = "http://127.0.0.1:8000/login"
+      # body = '{"user": "monitor", "password": "xxx"}'
+      # expect_status = 200
+      ## Save JSON field(gjson path) of response body as variable, referenced as {{token}} in following steps
+      # [inputs.host_healthcheck.http_flow.steps.extract]
+        # token = "data.token"
+      # [inputs.host_healthcheck.http_flow.steps.headers]
+        # Content-Type = "application/json"
+
+    # [[inputs.host_healthcheck.http_flow.steps]]
+      # name = "query"
+      # url = "http://127.0.0.1:8000/api/orders?limit=1"
+      # [inputs.host_healthcheck.http_flow.steps.headers]
+        # Authorization = "Bearer {{token}}"
+      ## Assert on response body, json_path select field of JSON body, whole body if not set
+      # [[inputs.host_healthcheck.http_flow.steps.assert]]
+        # json_path = "status"
+        # is = "ok"
+      # [[inputs.host_healthcheck.http_flow.steps.assert]]
+        # match_regex = "order_id"
+
+  ## Check TLS certificate expiry
+  # [[inputs.host_healthcheck.tls]]
+    # host_ports = ["example.com:443"]
+    ## Exception if the certificate expires in min_valid_days
+    # min_valid_days = 14
+    # server_name = ""
+    # timeout = "10s"
+    # ignore_insecure_tls = false
+
+  ## Check DNS resolution
+  # [[inputs.host_healthcheck.dns]]
+    # domains = ["example.com"]
+    ## A/AAAA/CNAME/MX/NS/TXT
+    # record_type = "A"
+    ## DNS server, system resolver if not set
+    # server = "8.8.8.8:53"
+    ## Values must be in the answers
+    # expect_values = ["93.184.216.34"]
+    # timeout = "5s"
+
+  ## Check local command
+  # [[inputs.host_healthcheck.command]]
+    # name = "nginx-active"
+    # command = "systemctl"
+    # args = ["is-active", "nginx"]
+    # timeout = "10s"
+    # expect_exit_code = 0
+    ## Assert on stdout(trailing whitespaces trimmed), same as http_flow steps
+    # [[inputs.host_healthcheck.command.assert]]
+      # is = "active"
   
   ## Extra tags
   [inputs.host_healthcheck.tags]
@@ -132,6 +201,89 @@ func (m *HTTPMetric) Info() *inputs.MeasurementInfo {
 	}
 }
 
+type HTTPFlowMetric struct{}
+
+//nolint:lll
+func (m *HTTPFlowMetric) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: httpFlowMetricName,
+		Type: "metric",
+		Fields: map[string]interface{}{
+			"exception":     newOtherFieldInfo(inputs.Int, inputs.Bool, inputs.UnknownUnit, "Exception value, 1 or 0"),
+			"error_message": newOtherFieldInfo(inputs.String, inputs.UnknownType, inputs.UnknownUnit, "The error detail, truncated to 256 bytes"),
+			"response_time": newOtherFieldInfo(inputs.Int, inputs.Gauge, inputs.DurationUS, "Total time of all steps"),
+		},
+		Tags: map[string]interface{}{
+			"name":  inputs.NewTagInfo("The name of the check"),
+			"step":  inputs.NewTagInfo("The name of the failed step, `none` if all steps passed"),
+			"error": inputs.NewTagInfo("The reason of failure: `status_code`/`assert`/`extract`/`timeout`/`cert_expiring`/`exit_code`/`no_record`/`answer`/`failed`, `none` if no error"),
+			"host":  inputs.NewTagInfo("System hostname"),
+		},
+	}
+}
+
+type TLSMetric struct{}
+
+//nolint:lll
+func (m *TLSMetric) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: tlsMetricName,
+		Type: "metric",
+		Fields: map[string]interface{}{
+			"exception":     newOtherFieldInfo(inputs.Int, inputs.Bool, inputs.UnknownUnit, "Exception value, 1 or 0"),
+			"error_message": newOtherFieldInfo(inputs.String, inputs.UnknownType, inputs.UnknownUnit, "The error detail, truncated to 256 bytes"),
+			"expire_days":   newOtherFieldInfo(inputs.Float, inputs.Gauge, inputs.UnknownUnit, "Days before the certificate expired"),
+		},
+		Tags: map[string]interface{}{
+			"host_port": inputs.NewTagInfo("The host and port"),
+			"error":     inputs.NewTagInfo("The reason of failure: `status_code`/`assert`/`extract`/`timeout`/`cert_expiring`/`exit_code`/`no_record`/`answer`/`failed`, `none` if no error"),
+			"host":      inputs.NewTagInfo("System hostname"),
+		},
+	}
+}
+
+type DNSMetric struct{}
+
+//nolint:lll
+func (m *DNSMetric) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: dnsMetricName,
+		Type: "metric",
+		Fields: map[string]interface{}{
+			"exception":     newOtherFieldInfo(inputs.Int, inputs.Bool, inputs.UnknownUnit, "Exception value, 1 or 0"),
+			"error_message": newOtherFieldInfo(inputs.String, inputs.UnknownType, inputs.UnknownUnit, "The error detail, truncated to 256 bytes"),
+			"response_time": newOtherFieldInfo(inputs.Int, inputs.Gauge, inputs.DurationUS, "Time of the resolution"),
+		},
+		Tags: map[string]interface{}{
+			"domain":      inputs.NewTagInfo("The domain"),
+			"record_type": inputs.NewTagInfo("The DNS record type"),
+			"error":       inputs.NewTagInfo("The reason of failure: `status_code`/`assert`/`extract`/`timeout`/`cert_expiring`/`exit_code`/`no_record`/`answer`/`failed`, `none` if no error"),
+			"host":        inputs.NewTagInfo("System hostname"),
+		},
+	}
+}
+
+type CommandMetric struct{}
+
+//nolint:lll
+func (m *CommandMetric) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: commandMetricName,
+		Type: "metric",
+		Fields: map[string]interface{}{
+			"exception":     newOtherFieldInfo(inputs.Int, inputs.Bool, inputs.UnknownUnit, "Exception value, 1 or 0"),
+			"error_message": newOtherFieldInfo(inputs.String, inputs.UnknownType, inputs.UnknownUnit, "The error detail, truncated to 256 bytes"),
+			"exit_code":     newOtherFieldInfo(inputs.Int, inputs.Gauge, inputs.UnknownUnit, "Exit code of the command, -1 if not exited"),
+			"duration":      newOtherFieldInfo(inputs.Int, inputs.Gauge, inputs.DurationUS, "Run time of the command"),
+		},
+		Tags: map[string]interface{}{
+			"name":  inputs.NewTagInfo("The name of the check"),
+			"error": inputs.NewTagInfo("The reason of failure: `status_code`/`assert`/`extract`/`timeout`/`cert_expiring`/`exit_code`/`no_record`/`answer`/`failed`, `none` if no error"),
+			"host":  inputs.NewTagInfo("System hostname"),
+		},
+	}
+}
+
 func newOtherFieldInfo(datatype, ftype, unit, desc string) *inputs.FieldInfo {
 	return &inputs.FieldInfo{
 		DataType: datatype,
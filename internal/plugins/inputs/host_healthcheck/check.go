@@ -0,0 +1,211 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package healthcheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/tidwall/gjson"
+)
+
+// Reasons of failed checks, used as value of tag error. Details of the
+// failure are in field error_message.
+const (
+	reasonStatusCode   = "status_code"
+	reasonAssert       = "assert"
+	reasonExtract      = "extract"
+	reasonTimeout      = "timeout"
+	reasonCertExpiring = "cert_expiring"
+	reasonExitCode     = "exit_code"
+	reasonNoRecord     = "no_record"
+	reasonAnswer       = "answer"
+	reasonFailed       = "failed" // other errors, such as connection refused
+
+	maxErrorMessage = 256
+	maxAssertValue  = 64
+)
+
+// checkError is error of a check with a fixed reason.
+type checkError struct {
+	reason string
+	msg    string
+}
+
+func (e *checkError) Error() string { return e.msg }
+
+func newCheckError(reason, format string, args ...any) error {
+	return &checkError{reason: reason, msg: fmt.Sprintf(format, args...)}
+}
+
+// checkOption is common options of each check.
+type checkOption struct {
+	// Interval of the check, it's rounded up to multiple of the input's interval.
+	Interval string            `toml:"interval" json:"interval"`
+	Tags     map[string]string `toml:"tags" json:"tags"`
+
+	interval time.Duration
+	lastRun  int64
+}
+
+func (c *checkOption) initOption() {
+	if c.Interval == "" {
+		return
+	}
+
+	if du, err := time.ParseDuration(c.Interval); err != nil {
+		l.Warnf("parse check interval %q: %s, using input's interval", c.Interval, err.Error())
+	} else {
+		c.interval = du
+	}
+}
+
+// due returns whether the check should run at ptTS, ptTS is aligned to the
+// input's interval.
+func (c *checkOption) due(ptTS int64) bool {
+	if c.lastRun != 0 && time.Duration(ptTS-c.lastRun) < c.interval {
+		return false
+	}
+
+	c.lastRun = ptTS
+	return true
+}
+
+// addTags add tags of the input and the check, tags of the check take
+// precedence.
+func (c *checkOption) addTags(kvs point.KVs, inputTags map[string]string) point.KVs {
+	for k, v := range inputTags {
+		if _, ok := c.Tags[k]; !ok {
+			kvs = kvs.AddTag(k, v)
+		}
+	}
+
+	for k, v := range c.Tags {
+		kvs = kvs.AddTag(k, v)
+	}
+
+	return kvs
+}
+
+// assertion checks a value, such as HTTP response body or command output. If
+// JSONPath set, the value is the JSON field selected by the path(gjson syntax).
+type assertion struct {
+	JSONPath    string `toml:"json_path" json:"json_path"`
+	Is          string `toml:"is" json:"is"`
+	IsNot       string `toml:"is_not" json:"is_not"`
+	Contains    string `toml:"contains" json:"contains"`
+	NotContains string `toml:"not_contains" json:"not_contains"`
+	MatchRegex  string `toml:"match_regex" json:"match_regex"`
+
+	re *regexp.Regexp
+}
+
+func (a *assertion) init() error {
+	if a.MatchRegex != "" {
+		re, err := regexp.Compile(a.MatchRegex)
+		if err != nil {
+			return fmt.Errorf("compile regex %q: %w", a.MatchRegex, err)
+		}
+		a.re = re
+	}
+
+	return nil
+}
+
+func (a *assertion) check(data []byte) error {
+	val, name := string(data), "value"
+	if a.JSONPath != "" {
+		res := gjson.GetBytes(data, a.JSONPath)
+		if !res.Exists() {
+			return newCheckError(reasonAssert, "json path %s not found", a.JSONPath)
+		}
+		val, name = res.String(), a.JSONPath
+	}
+
+	switch {
+	case a.Is != "" && val != a.Is:
+		return newCheckError(reasonAssert, "%s is %q, expected %q", name, truncate(val, maxAssertValue), a.Is)
+	case a.IsNot != "" && val == a.IsNot:
+		return newCheckError(reasonAssert, "%s is %q", name, truncate(val, maxAssertValue))
+	case a.Contains != "" && !strings.Contains(val, a.Contains):
+		return newCheckError(reasonAssert, "%s does not contain %q", name, a.Contains)
+	case a.NotContains != "" && strings.Contains(val, a.NotContains):
+		return newCheckError(reasonAssert, "%s contains %q", name, a.NotContains)
+	case a.re != nil && !a.re.MatchString(val):
+		return newCheckError(reasonAssert, "%s does not match %q", name, a.MatchRegex)
+	}
+
+	return nil
+}
+
+func initAssertions(arr []*assertion) error {
+	for _, a := range arr {
+		if err := a.init(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func checkAssertions(arr []*assertion, data []byte) error {
+	for _, a := range arr {
+		if err := a.check(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return s[:n] + "..."
+}
+
+// errorTag returns reason of err as value of tag error, none if no error.
+func errorTag(err error) string {
+	if err == nil {
+		return noneType
+	}
+
+	var ce *checkError
+	if errors.As(err, &ce) {
+		return ce.reason
+	}
+
+	var ne net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &ne) && ne.Timeout()) {
+		return reasonTimeout
+	}
+
+	return reasonFailed
+}
+
+// addError add tag error and field error_message of err.
+func addError(kvs point.KVs, err error) point.KVs {
+	kvs = kvs.Add("error", errorTag(err), true, true)
+	if err != nil {
+		kvs = kvs.Add("error_message", truncate(err.Error(), maxErrorMessage), false, true)
+	}
+	return kvs
+}
+
+func (ipt *Input) appendPoint(name string, kvs point.KVs, opt *checkOption, ptTS int64) {
+	kvs = opt.addTags(kvs, ipt.mergedTags)
+
+	opts := point.DefaultMetricOptions()
+	// keep string field error_message
+	opts = append(opts, point.WithTimestamp(ptTS), point.WithStrField(true))
+
+	ipt.collectCache = append(ipt.collectCache, point.NewPointV2(name, kvs, opts...))
+}
@@ -0,0 +1,115 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+const commandMetricName = "host_command_exception"
+
+// command runs local command, and checks its exit code and output.
+type command struct {
+	checkOption
+
+	Name           string       `toml:"name" json:"name"`
+	Command        string       `toml:"command" json:"command"`
+	Args           []string     `toml:"args" json:"args"`
+	Timeout        string       `toml:"timeout" json:"timeout"`
+	ExpectExitCode int          `toml:"expect_exit_code" json:"expect_exit_code"`
+	Assert         []*assertion `toml:"assert" json:"assert"`
+
+	timeout time.Duration
+}
+
+func (c *command) init() error {
+	c.initOption()
+
+	if c.Command == "" {
+		return fmt.Errorf("command not set")
+	}
+
+	if c.Name == "" {
+		c.Name = strings.Join(append([]string{c.Command}, c.Args...), " ")
+	}
+
+	c.timeout = 10 * time.Second
+	if c.Timeout != "" {
+		du, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("parse timeout: %w", err)
+		}
+		c.timeout = du
+	}
+
+	return initAssertions(c.Assert)
+}
+
+// run returns exit code of the command, -1 if not exited.
+func (c *command) run() (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, c.Command, c.Args...) //nolint:gosec
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	exitCode := 0
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if !errors.As(err, &exitErr) || ctx.Err() != nil {
+			if ctx.Err() != nil {
+				err = newCheckError(reasonTimeout, "timeout after %s", c.timeout)
+			}
+			return -1, err
+		}
+		exitCode = exitErr.ExitCode()
+	}
+
+	if exitCode != c.ExpectExitCode {
+		return exitCode, newCheckError(reasonExitCode, "exit code is %d, expected %d: %s",
+			exitCode, c.ExpectExitCode, truncate(strings.TrimSpace(stderr.String()), maxErrorMessage))
+	}
+
+	// command output usually ends with newline, such as `systemctl is-active`
+	return exitCode, checkAssertions(c.Assert, bytes.TrimRight(stdout.Bytes(), " \t\r\n"))
+}
+
+func (ipt *Input) collectCommand(ptTS int64) error {
+	for _, c := range ipt.command {
+		if !c.due(ptTS) {
+			continue
+		}
+
+		start := time.Now()
+		exitCode, err := c.run()
+		cost := time.Since(start)
+
+		if err != nil {
+			l.Infof("command check %s failed: %s", c.Name, err)
+		}
+
+		var kvs point.KVs
+		kvs = kvs.Add("name", c.Name, true, true)
+		kvs = addError(kvs, err)
+		kvs = kvs.Add("exception", err != nil, false, true)
+		kvs = kvs.Add("exit_code", exitCode, false, true)
+		kvs = kvs.Add("duration", cost.Microseconds(), false, true)
+
+		ipt.appendPoint(commandMetricName, kvs, &c.checkOption, ptTS)
+	}
+
+	return nil
+}
@@ -0,0 +1,187 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package healthcheck
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+const dnsMetricName = "host_dns_exception"
+
+// dns checks domains are resolved, and the answers contain expected values.
+type dns struct {
+	checkOption
+
+	Domains      []string `toml:"domains" json:"domains"`
+	RecordType   string   `toml:"record_type" json:"record_type"`
+	Server       string   `toml:"server" json:"server"`
+	ExpectValues []string `toml:"expect_values" json:"expect_values"`
+	Timeout      string   `toml:"timeout" json:"timeout"`
+
+	timeout  time.Duration
+	resolver *net.Resolver
+}
+
+func (d *dns) init() error {
+	d.initOption()
+
+	d.Domains = filterEmptyValues(d.Domains)
+	if len(d.Domains) == 0 {
+		return fmt.Errorf("domains not set")
+	}
+
+	d.RecordType = strings.ToUpper(d.RecordType)
+	switch d.RecordType {
+	case "":
+		d.RecordType = "A"
+	case "A", "AAAA", "CNAME", "MX", "NS", "TXT":
+	default:
+		return fmt.Errorf("unsupported record type %s", d.RecordType)
+	}
+
+	d.timeout = 5 * time.Second
+	if d.Timeout != "" {
+		du, err := time.ParseDuration(d.Timeout)
+		if err != nil {
+			return fmt.Errorf("parse timeout: %w", err)
+		}
+		d.timeout = du
+	}
+
+	d.resolver = net.DefaultResolver
+	if d.Server != "" {
+		server := d.Server
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(server, "53")
+		}
+
+		d.resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	return nil
+}
+
+func (d *dns) lookup(ctx context.Context, domain string) ([]string, error) {
+	var res []string
+
+	switch d.RecordType {
+	case "A", "AAAA":
+		addrs, err := d.resolver.LookupIPAddr(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, addr := range addrs {
+			if (addr.IP.To4() != nil) == (d.RecordType == "A") {
+				res = append(res, addr.IP.String())
+			}
+		}
+
+	case "CNAME":
+		cname, err := d.resolver.LookupCNAME(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, strings.TrimSuffix(cname, "."))
+
+	case "MX":
+		mxs, err := d.resolver.LookupMX(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			res = append(res, strings.TrimSuffix(mx.Host, "."))
+		}
+
+	case "NS":
+		nss, err := d.resolver.LookupNS(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range nss {
+			res = append(res, strings.TrimSuffix(ns.Host, "."))
+		}
+
+	case "TXT":
+		txts, err := d.resolver.LookupTXT(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, txts...)
+	}
+
+	if len(res) == 0 {
+		return nil, newCheckError(reasonNoRecord, "no %s record", d.RecordType)
+	}
+
+	return res, nil
+}
+
+func (d *dns) check(domain string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+
+	answers, err := d.lookup(ctx, domain)
+	if err != nil {
+		return err
+	}
+
+	for _, v := range d.ExpectValues {
+		found := false
+		for _, ans := range answers {
+			if ans == v {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return newCheckError(reasonAnswer, "%s not in answers %s", v, strings.Join(answers, ","))
+		}
+	}
+
+	return nil
+}
+
+func (ipt *Input) collectDNS(ptTS int64) error {
+	for _, d := range ipt.dns {
+		if !d.due(ptTS) {
+			continue
+		}
+
+		for _, domain := range d.Domains {
+			start := time.Now()
+			err := d.check(domain)
+			cost := time.Since(start)
+
+			if err != nil {
+				l.Infof("dns check on %s failed: %s", domain, err)
+			}
+
+			var kvs point.KVs
+			kvs = kvs.Add("domain", domain, true, true)
+			kvs = kvs.Add("record_type", d.RecordType, true, true)
+			kvs = addError(kvs, err)
+			kvs = kvs.Add("exception", err != nil, false, true)
+			kvs = kvs.Add("response_time", cost.Microseconds(), false, true)
+
+			ipt.appendPoint(dnsMetricName, kvs, &d.checkOption, ptTS)
+		}
+	}
+
+	return nil
+}
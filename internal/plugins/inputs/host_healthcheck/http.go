@@ -16,6 +16,10 @@ const httpMetricName = "host_http_exception"
 
 func (ipt *Input) collectHTTP(ptTS int64) error {
 	for _, http := range ipt.http {
+		if !http.due(ptTS) {
+			continue
+		}
+
 		statusCode := fmt.Sprintf("%d", http.ExpectStatus)
 		for _, url := range http.HTTPURLs {
 			task := dt.HTTPTask{
@@ -63,9 +67,7 @@ func (ipt *Input) collectHTTP(ptTS int64) error {
 				}
 			}
 
-			for k, v := range ipt.mergedTags {
-				kvs = kvs.AddTag(k, v)
-			}
+			kvs = http.addTags(kvs, ipt.mergedTags)
 
 			opts := point.DefaultMetricOptions()
 			opts = append(opts, point.WithTimestamp(ptTS))
@@ -0,0 +1,205 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package healthcheck
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	nhttp "net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/tidwall/gjson"
+)
+
+const (
+	httpFlowMetricName = "host_http_flow_exception"
+	maxHTTPBodySize    = 10 * 1024 * 1024
+)
+
+type httpStep struct {
+	Name         string            `toml:"name" json:"name"`
+	Method       string            `toml:"method" json:"method"`
+	URL          string            `toml:"url" json:"url"`
+	Headers      map[string]string `toml:"headers" json:"headers"`
+	Body         string            `toml:"body" json:"body"`
+	ExpectStatus int               `toml:"expect_status" json:"expect_status"`
+	Assert       []*assertion      `toml:"assert" json:"assert"`
+
+	// Extract save JSON fields of response body as variables, they are
+	// referenced as {{name}} in URL, headers and body of the following steps.
+	Extract map[string]string `toml:"extract" json:"extract"`
+}
+
+// httpFlow requests steps in order with the same cookie jar, so cookies set by
+// login step are sent by the following steps.
+type httpFlow struct {
+	checkOption
+
+	Name              string      `toml:"name" json:"name"`
+	Timeout           string      `toml:"timeout" json:"timeout"`
+	IgnoreInsecureTLS bool        `toml:"ignore_insecure_tls" json:"ignore_insecure_tls"`
+	Steps             []*httpStep `toml:"steps" json:"steps"`
+
+	timeout time.Duration
+}
+
+func (f *httpFlow) init() error {
+	f.initOption()
+
+	f.timeout = 30 * time.Second
+	if f.Timeout != "" {
+		du, err := time.ParseDuration(f.Timeout)
+		if err != nil {
+			return fmt.Errorf("parse timeout: %w", err)
+		}
+		f.timeout = du
+	}
+
+	for i, s := range f.Steps {
+		if s.URL == "" {
+			return fmt.Errorf("url of step %d not set", i)
+		}
+
+		if s.Name == "" {
+			s.Name = fmt.Sprintf("step-%d", i)
+		}
+
+		if s.Method == "" {
+			s.Method = nhttp.MethodGet
+		}
+
+		if s.ExpectStatus == 0 {
+			s.ExpectStatus = nhttp.StatusOK
+		}
+
+		if err := initAssertions(s.Assert); err != nil {
+			return fmt.Errorf("step %s: %w", s.Name, err)
+		}
+	}
+
+	if f.Name == "" && len(f.Steps) > 0 {
+		f.Name = f.Steps[0].URL
+	}
+
+	return nil
+}
+
+// run returns the failed step and its error.
+func (f *httpFlow) run() (string, error) {
+	jar, err := cookiejar.New(nil)
+	if err != nil {
+		return "", err
+	}
+
+	cli := &nhttp.Client{
+		Jar:     jar,
+		Timeout: f.timeout,
+		Transport: &nhttp.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: f.IgnoreInsecureTLS}, //nolint:gosec
+		},
+	}
+	defer cli.CloseIdleConnections()
+
+	vars := map[string]string{}
+	for _, s := range f.Steps {
+		if err := s.run(cli, vars); err != nil {
+			return s.Name, err
+		}
+	}
+
+	return "", nil
+}
+
+func expandVars(s string, vars map[string]string) string {
+	if len(vars) == 0 || !strings.Contains(s, "{{") {
+		return s
+	}
+
+	for k, v := range vars {
+		s = strings.ReplaceAll(s, "{{"+k+"}}", v)
+	}
+	return s
+}
+
+func (s *httpStep) run(cli *nhttp.Client, vars map[string]string) error {
+	var body io.Reader
+	if s.Body != "" {
+		body = strings.NewReader(expandVars(s.Body, vars))
+	}
+
+	req, err := nhttp.NewRequest(s.Method, expandVars(s.URL, vars), body)
+	if err != nil {
+		return err
+	}
+
+	for k, v := range s.Headers {
+		req.Header.Set(k, expandVars(v, vars))
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBodySize))
+	if err != nil {
+		return fmt.Errorf("read body: %w", err)
+	}
+
+	if resp.StatusCode != s.ExpectStatus {
+		return newCheckError(reasonStatusCode, "status code is %d, expected %d", resp.StatusCode, s.ExpectStatus)
+	}
+
+	if err := checkAssertions(s.Assert, data); err != nil {
+		return err
+	}
+
+	for k, path := range s.Extract {
+		res := gjson.GetBytes(data, path)
+		if !res.Exists() {
+			return newCheckError(reasonExtract, "extract %s: json path %s not found", k, path)
+		}
+		vars[k] = res.String()
+	}
+
+	return nil
+}
+
+func (ipt *Input) collectHTTPFlow(ptTS int64) error {
+	for _, f := range ipt.httpFlow {
+		if !f.due(ptTS) {
+			continue
+		}
+
+		start := time.Now()
+		step, err := f.run()
+		cost := time.Since(start)
+
+		if err != nil {
+			l.Infof("http flow %s failed at step %s: %s", f.Name, step, err)
+		}
+
+		if step == "" {
+			step = noneType
+		}
+
+		var kvs point.KVs
+		kvs = kvs.Add("name", f.Name, true, true)
+		kvs = kvs.Add("step", step, true, true)
+		kvs = addError(kvs, err)
+		kvs = kvs.Add("exception", err != nil, false, true)
+		kvs = kvs.Add("response_time", cost.Microseconds(), false, true)
+
+		ipt.appendPoint(httpFlowMetricName, kvs, &f.checkOption, ptTS)
+	}
+
+	return nil
+}
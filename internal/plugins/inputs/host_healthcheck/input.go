@@ -32,6 +32,8 @@ var (
 )
 
 type process struct {
+	checkOption
+
 	Names         []string `toml:"names" json:"names"`             // process names
 	NamesRegex    []string `toml:"names_regex" json:"names_regex"` // process names regex
 	MinRunTime    string   `toml:"min_run_time" json:"min_run_time"`
@@ -45,6 +47,8 @@ type process struct {
 }
 
 type tcp struct {
+	checkOption
+
 	HostPorts         []string `toml:"host_ports" json:"host_ports"`
 	ConnectionTimeOut string   `toml:"connection_timeout" json:"connection_timeout"`
 
@@ -52,6 +56,8 @@ type tcp struct {
 }
 
 type http struct {
+	checkOption
+
 	HTTPURLs          []string          `toml:"http_urls" json:"http_urls"`
 	Method            string            `toml:"method" json:"method"`
 	ExpectStatus      int               `toml:"expect_status" json:"expect_status"`
@@ -65,6 +71,10 @@ type Input struct {
 	Process  []*process        `toml:"process" json:"process"`
 	TCP      []*tcp            `toml:"tcp" json:"tcp"`
 	HTTP     []*http           `toml:"http" json:"http"`
+	HTTPFlow []*httpFlow       `toml:"http_flow" json:"http_flow"`
+	TLS      []*tlsCert        `toml:"tls" json:"tls"`
+	DNS      []*dns            `toml:"dns" json:"dns"`
+	Command  []*command        `toml:"command" json:"command"`
 	Tags     map[string]string `toml:"tags" json:"tags"`
 
 	semStop      *cliutils.Sem // start stop signal
@@ -76,6 +86,10 @@ type Input struct {
 	tcp          []*tcp
 	http         []*http
 	process      []*process
+	httpFlow     []*httpFlow
+	tls          []*tlsCert
+	dns          []*dns
+	command      []*command
 }
 
 func (*Input) Catalog() string { return category }
@@ -85,7 +99,15 @@ func (*Input) SampleConfig() string { return sampleConfig }
 func (*Input) AvailableArchs() []string { return datakit.AllOS }
 
 func (*Input) SampleMeasurement() []inputs.Measurement {
-	return []inputs.Measurement{&ProcessMetric{}, &TCPMetric{}, &HTTPMetric{}}
+	return []inputs.Measurement{
+		&ProcessMetric{},
+		&TCPMetric{},
+		&HTTPMetric{},
+		&HTTPFlowMetric{},
+		&TLSMetric{},
+		&DNSMetric{},
+		&CommandMetric{},
+	}
 }
 
 func (ipt *Input) Collect(ptTS int64) error {
@@ -103,6 +125,7 @@ func (ipt *Input) Collect(ptTS int64) error {
 func (ipt *Input) initConfig() {
 	ipt.mergedTags = inputs.MergeTags(ipt.tagger.HostTags(), ipt.Tags, "")
 	for _, process := range ipt.Process {
+		process.initOption()
 		for _, v := range process.NamesRegex {
 			if r, err := regexp.Compile(v); err != nil {
 				l.Warnf("regexp compile(%s) error: %s, ignored", v, err.Error())
@@ -142,6 +165,7 @@ func (ipt *Input) initConfig() {
 	}
 
 	for _, tcp := range ipt.TCP {
+		tcp.initOption()
 		// parse connection timeout and set default
 		if tcp.ConnectionTimeOut != "" {
 			if du, err := time.ParseDuration(tcp.ConnectionTimeOut); err != nil {
@@ -164,6 +188,7 @@ func (ipt *Input) initConfig() {
 	}
 
 	for _, http := range ipt.HTTP {
+		http.initOption()
 		// set default method
 		if http.Method == "" {
 			http.Method = "GET"
@@ -184,6 +209,46 @@ func (ipt *Input) initConfig() {
 		ipt.http = append(ipt.http, http)
 	}
 
+	for _, f := range ipt.HTTPFlow {
+		if len(f.Steps) == 0 {
+			continue
+		}
+
+		if err := f.init(); err != nil {
+			l.Warnf("invalid http_flow %s: %s, ignored", f.Name, err.Error())
+			continue
+		}
+
+		ipt.httpFlow = append(ipt.httpFlow, f)
+	}
+
+	for _, c := range ipt.TLS {
+		if err := c.init(); err != nil {
+			l.Warnf("invalid tls check: %s, ignored", err.Error())
+			continue
+		}
+
+		ipt.tls = append(ipt.tls, c)
+	}
+
+	for _, d := range ipt.DNS {
+		if err := d.init(); err != nil {
+			l.Warnf("invalid dns check: %s, ignored", err.Error())
+			continue
+		}
+
+		ipt.dns = append(ipt.dns, d)
+	}
+
+	for _, c := range ipt.Command {
+		if err := c.init(); err != nil {
+			l.Warnf("invalid command check %s: %s, ignored", c.Name, err.Error())
+			continue
+		}
+
+		ipt.command = append(ipt.command, c)
+	}
+
 	ipt.collectFuncs = make(map[string]func(t int64) error, 0)
 	if len(ipt.process) > 0 {
 		ipt.collectFuncs["process"] = func(t int64) error {
@@ -202,6 +267,30 @@ func (ipt *Input) initConfig() {
 			return ipt.collectHTTP(t)
 		}
 	}
+
+	if len(ipt.httpFlow) > 0 {
+		ipt.collectFuncs["http_flow"] = func(t int64) error {
+			return ipt.collectHTTPFlow(t)
+		}
+	}
+
+	if len(ipt.tls) > 0 {
+		ipt.collectFuncs["tls"] = func(t int64) error {
+			return ipt.collectTLS(t)
+		}
+	}
+
+	if len(ipt.dns) > 0 {
+		ipt.collectFuncs["dns"] = func(t int64) error {
+			return ipt.collectDNS(t)
+		}
+	}
+
+	if len(ipt.command) > 0 {
+		ipt.collectFuncs["command"] = func(t int64) error {
+			return ipt.collectCommand(t)
+		}
+	}
 }
 
 func (ipt *Input) Run() {
@@ -267,6 +356,10 @@ func (ipt *Input) GetENVDoc() []*inputs.ENVInfo {
 		{FieldName: "Process", Type: doc.JSON, Example: `[{"names":["nginx","mysql"],"min_run_time":"10m"}]`, Desc: "Check process", DescZh: "检查处理器"},
 		{FieldName: "TCP", Type: doc.JSON, Example: `[{"host_ports":["10.100.1.2:3369","192.168.1.2:6379"],"connection_timeout":"3s"}]`, Desc: "Check TCP", DescZh: "检查 TCP"},
 		{FieldName: "HTTP", Type: doc.JSON, Example: `[{"http_urls":["http://local-ip:port/path/to/api?arg1=x&arg2=y"],"method":"GET","expect_status":200,"timeout":"30s","ignore_insecure_tls":false,"headers":{"Header1":"header-value-1","Hedaer2":"header-value-2"}}]`, Desc: "Check HTTP", DescZh: "检查 HTTP"},
+		{FieldName: "HTTPFlow", ENVName: "HTTP_FLOW", ConfField: "http_flow", Type: doc.JSON, Example: `[{"name":"login","steps":[{"url":"http://127.0.0.1:8000/login","method":"POST","body":"{\"user\":\"u\"}","extract":{"token":"data.token"}},{"url":"http://127.0.0.1:8000/api/me","headers":{"Authorization":"Bearer {{token}}"},"assert":[{"json_path":"status","is":"ok"}]}]}]`, Desc: "Check multi-step HTTP", DescZh: "检查多步骤 HTTP"},
+		{FieldName: "TLS", Type: doc.JSON, Example: `[{"host_ports":["example.com:443"],"min_valid_days":14}]`, Desc: "Check TLS certificate expiry", DescZh: "检查 TLS 证书有效期"},
+		{FieldName: "DNS", Type: doc.JSON, Example: `[{"domains":["example.com"],"record_type":"A","expect_values":["93.184.216.34"]}]`, Desc: "Check DNS resolution", DescZh: "检查 DNS 解析"},
+		{FieldName: "Command", Type: doc.JSON, Example: `[{"command":"systemctl","args":["is-active","nginx"],"expect_exit_code":0}]`, Desc: "Check local command", DescZh: "检查本地命令"},
 		{FieldName: "Tags", Type: doc.JSON, Example: `{"some_tag":"some_value","more_tag":"some_other_value"}`},
 	}
 
@@ -279,6 +372,10 @@ func (ipt *Input) GetENVDoc() []*inputs.ENVInfo {
 //		ENV_INPUT_HEALTHCHECK_PROCESS : JSON string
 //		ENV_INPUT_HEALTHCHECK_TCP : JSON string
 //		ENV_INPUT_HEALTHCHECK_HTTP : JSON string
+//		ENV_INPUT_HEALTHCHECK_HTTP_FLOW : JSON string
+//		ENV_INPUT_HEALTHCHECK_TLS : JSON string
+//		ENV_INPUT_HEALTHCHECK_DNS : JSON string
+//		ENV_INPUT_HEALTHCHECK_COMMAND : JSON string
 //		ENV_INPUT_HEALTHCHECK_TAGS : JSON string
 
 func (ipt *Input) ReadEnv(envs map[string]string) {
@@ -313,6 +410,42 @@ func (ipt *Input) ReadEnv(envs map[string]string) {
 		}
 	}
 
+	if value, ok := envs["ENV_INPUT_HEALTHCHECK_HTTP_FLOW"]; ok {
+		conf := []*httpFlow{}
+		if err := json.Unmarshal([]byte(value), &conf); err != nil {
+			l.Warnf("parse ENV_INPUT_HEALTHCHECK_HTTP_FLOW=%s failed, %s", value, err.Error())
+		} else {
+			ipt.HTTPFlow = conf
+		}
+	}
+
+	if value, ok := envs["ENV_INPUT_HEALTHCHECK_TLS"]; ok {
+		conf := []*tlsCert{}
+		if err := json.Unmarshal([]byte(value), &conf); err != nil {
+			l.Warnf("parse ENV_INPUT_HEALTHCHECK_TLS=%s failed, %s", value, err.Error())
+		} else {
+			ipt.TLS = conf
+		}
+	}
+
+	if value, ok := envs["ENV_INPUT_HEALTHCHECK_DNS"]; ok {
+		conf := []*dns{}
+		if err := json.Unmarshal([]byte(value), &conf); err != nil {
+			l.Warnf("parse ENV_INPUT_HEALTHCHECK_DNS=%s failed, %s", value, err.Error())
+		} else {
+			ipt.DNS = conf
+		}
+	}
+
+	if value, ok := envs["ENV_INPUT_HEALTHCHECK_COMMAND"]; ok {
+		conf := []*command{}
+		if err := json.Unmarshal([]byte(value), &conf); err != nil {
+			l.Warnf("parse ENV_INPUT_HEALTHCHECK_COMMAND=%s failed, %s", value, err.Error())
+		} else {
+			ipt.Command = conf
+		}
+	}
+
 	if value, ok := envs["ENV_INPUT_HEALTHCHECK_TAGS"]; ok {
 		var tags map[string]string
 		if err := json.Unmarshal([]byte(value), &tags); err != nil {
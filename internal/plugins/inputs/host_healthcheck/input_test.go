@@ -9,10 +9,13 @@ import (
 	"net"
 	h "net/http"
 	"net/http/httptest"
+	"runtime"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestProcess(t *testing.T) {
@@ -232,3 +235,227 @@ func tcpServer() (server net.Listener, err error) {
 
 	return
 }
+
+func TestHTTPFlow(t *testing.T) {
+	mux := h.NewServeMux()
+	mux.HandleFunc("/login", func(w h.ResponseWriter, r *h.Request) {
+		h.SetCookie(w, &h.Cookie{Name: "session", Value: "s1"})
+		_, _ = w.Write([]byte(`{"data":{"token":"t1"}}`))
+	})
+	mux.HandleFunc("/api", func(w h.ResponseWriter, r *h.Request) {
+		if c, err := r.Cookie("session"); err != nil || c.Value != "s1" || r.Header.Get("Authorization") != "Bearer t1" {
+			w.WriteHeader(h.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok","items":[{"id":"order-1"}]}`))
+	})
+
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	cases := []struct {
+		Title   string
+		Assert  []*assertion
+		Step    string
+		Error   string
+		Message string
+	}{
+		{
+			Title:  "ok",
+			Assert: []*assertion{{JSONPath: "status", Is: "ok"}, {MatchRegex: `order-\d+`}},
+			Step:   noneType,
+			Error:  noneType,
+		},
+		{
+			Title:   "json path mismatch",
+			Assert:  []*assertion{{JSONPath: "items.0.id", Is: "order-2"}},
+			Step:    "query",
+			Error:   reasonAssert,
+			Message: `items.0.id is "order-1", expected "order-2"`,
+		},
+		{
+			Title:   "json path not found",
+			Assert:  []*assertion{{JSONPath: "total"}},
+			Step:    "query",
+			Error:   reasonAssert,
+			Message: "json path total not found",
+		},
+		{
+			Title:   "plain body",
+			Assert:  []*assertion{{Is: "ok"}},
+			Step:    "query",
+			Error:   reasonAssert,
+			Message: `value is "{\"status\":\"ok\",\"items\":[{\"id\":\"order-1\"}]}", expected "ok"`,
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.Title, func(t *testing.T) {
+			input := defaultInput()
+			input.HTTPFlow = []*httpFlow{{
+				Name: "flow",
+				Steps: []*httpStep{
+					{Name: "login", Method: "POST", URL: server.URL + "/login", Extract: map[string]string{"token": "data.token"}},
+					{Name: "query", URL: server.URL + "/api", Headers: map[string]string{"Authorization": "Bearer {{token}}"}, Assert: cs.Assert},
+				},
+			}}
+			input.initConfig()
+
+			assert.NoError(t, input.Collect(time.Now().UnixNano()))
+			require.Len(t, input.collectCache, 1)
+
+			pt := input.collectCache[0]
+			assert.Equal(t, httpFlowMetricName, pt.Name())
+			assert.Equal(t, cs.Step, pt.GetTag("step"))
+			assert.Equal(t, cs.Error, pt.GetTag("error"))
+			assert.Equal(t, cs.Error != noneType, pt.Get("exception"))
+			if cs.Message != "" {
+				assert.Equal(t, cs.Message, pt.Get("error_message"))
+			} else {
+				assert.Nil(t, pt.Get("error_message"))
+			}
+		})
+	}
+}
+
+func TestTLS(t *testing.T) {
+	server := httptest.NewTLSServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {}))
+	defer server.Close()
+
+	hostPort := strings.TrimPrefix(server.URL, "https://")
+
+	input := defaultInput()
+	input.TLS = []*tlsCert{
+		{HostPorts: []string{hostPort}, IgnoreInsecureTLS: true},
+		{HostPorts: []string{hostPort}, IgnoreInsecureTLS: true, MinValidDays: 1000000},
+		{HostPorts: []string{hostPort}}, // self-signed
+	}
+	input.initConfig()
+
+	assert.NoError(t, input.Collect(time.Now().UnixNano()))
+	require.Len(t, input.collectCache, 3)
+
+	assert.Equal(t, false, input.collectCache[0].Get("exception"))
+	assert.Greater(t, input.collectCache[0].Get("expire_days"), float64(0))
+	assert.Equal(t, reasonCertExpiring, input.collectCache[1].GetTag("error"))
+	assert.Contains(t, input.collectCache[1].Get("error_message"), "certificate expires in")
+	assert.Equal(t, reasonFailed, input.collectCache[2].GetTag("error"))
+	assert.Contains(t, input.collectCache[2].Get("error_message"), "certificate")
+}
+
+func TestDNS(t *testing.T) {
+	input := defaultInput()
+	input.DNS = []*dns{
+		{Domains: []string{"localhost"}, ExpectValues: []string{"127.0.0.1"}},
+		{Domains: []string{"localhost"}, ExpectValues: []string{"10.0.0.1"}},
+		{Domains: []string{"localhost"}, RecordType: "SRV"}, // unsupported
+	}
+	input.initConfig()
+
+	assert.NoError(t, input.Collect(time.Now().UnixNano()))
+	require.Len(t, input.collectCache, 2)
+
+	assert.Equal(t, false, input.collectCache[0].Get("exception"))
+	assert.Equal(t, "A", input.collectCache[0].GetTag("record_type"))
+	assert.Equal(t, reasonAnswer, input.collectCache[1].GetTag("error"))
+	assert.Contains(t, input.collectCache[1].Get("error_message"), "10.0.0.1 not in answers")
+}
+
+func TestCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("sh not available")
+	}
+
+	cases := []struct {
+		Title    string
+		Command  *command
+		ExitCode int64
+		Error    string
+		Message  string
+	}{
+		{
+			Title:   "ok",
+			Command: &command{Command: "sh", Args: []string{"-c", `echo '{"state":"active"}'`}, Assert: []*assertion{{JSONPath: "state", Is: "active"}}},
+			Error:   noneType,
+		},
+		{
+			Title:   "plain text",
+			Command: &command{Command: "sh", Args: []string{"-c", "echo active"}, Assert: []*assertion{{Is: "active"}}},
+			Error:   noneType,
+		},
+		{
+			Title:   "long output truncated",
+			Command: &command{Command: "sh", Args: []string{"-c", "printf 'x%.0s' $(seq 100)"}, Assert: []*assertion{{Is: "ok"}}},
+			Error:   reasonAssert,
+			Message: `value is "` + strings.Repeat("x", maxAssertValue) + `...", expected "ok"`,
+		},
+		{
+			Title:    "exit code",
+			Command:  &command{Command: "sh", Args: []string{"-c", "echo oops >&2; exit 3"}},
+			ExitCode: 3,
+			Error:    reasonExitCode,
+			Message:  "exit code is 3, expected 0: oops",
+		},
+		{
+			Title:   "stdout",
+			Command: &command{Command: "sh", Args: []string{"-c", "echo inactive"}, Assert: []*assertion{{NotContains: "inactive"}}},
+			Error:   reasonAssert,
+			Message: `value contains "inactive"`,
+		},
+		{
+			Title:    "timeout",
+			Command:  &command{Command: "sleep", Args: []string{"5"}, Timeout: "100ms"},
+			ExitCode: -1,
+			Error:    reasonTimeout,
+			Message:  "timeout after 100ms",
+		},
+	}
+
+	for _, cs := range cases {
+		t.Run(cs.Title, func(t *testing.T) {
+			input := defaultInput()
+			input.Command = []*command{cs.Command}
+			input.initConfig()
+
+			assert.NoError(t, input.Collect(time.Now().UnixNano()))
+			require.Len(t, input.collectCache, 1)
+
+			pt := input.collectCache[0]
+			assert.Equal(t, commandMetricName, pt.Name())
+			assert.Equal(t, cs.Error, pt.GetTag("error"))
+			assert.Equal(t, cs.ExitCode, pt.Get("exit_code"))
+			if cs.Message != "" {
+				assert.Equal(t, cs.Message, pt.Get("error_message"))
+			}
+		})
+	}
+}
+
+func TestCheckOption(t *testing.T) {
+	server := httptest.NewServer(h.HandlerFunc(func(w h.ResponseWriter, r *h.Request) {}))
+	defer server.Close()
+
+	input := defaultInput()
+	input.mergedTags = map[string]string{"host": "h1", "service": "input"}
+	input.HTTP = []*http{{
+		HTTPURLs:    []string{server.URL},
+		checkOption: checkOption{Interval: "2m", Tags: map[string]string{"service": "api"}},
+	}}
+	input.initConfig()
+	input.mergedTags = map[string]string{"host": "h1", "service": "input"}
+
+	start := time.Now()
+	var points []int
+	for i := 0; i < 5; i++ {
+		input.collectCache = nil
+		assert.NoError(t, input.Collect(start.Add(time.Duration(i)*time.Minute).UnixNano()))
+		points = append(points, len(input.collectCache))
+
+		for _, pt := range input.collectCache {
+			assert.Equal(t, "api", pt.GetTag("service"))
+			assert.Equal(t, "h1", pt.GetTag("host"))
+		}
+	}
+
+	assert.Equal(t, []int{1, 0, 1, 0, 1}, points)
+}
@@ -33,6 +33,10 @@ func (ipt *Input) collectProcess(ptTS int64) error {
 	}
 
 	for _, process := range ipt.process {
+		if !process.due(ptTS) {
+			continue
+		}
+
 		runningProcesses := getMatchedProcess(pses, process)
 
 		for oldPid, oldInfo := range process.processes {
@@ -49,9 +53,7 @@ func (ipt *Input) collectProcess(ptTS int64) error {
 				kvs = kvs.Add("type", "missing", true, true)
 				kvs = kvs.Add("exception", true, false, true)
 			}
-			for k, v := range ipt.mergedTags {
-				kvs = kvs.AddTag(k, v)
-			}
+			kvs = process.addTags(kvs, ipt.mergedTags)
 
 			opts := point.DefaultMetricOptions()
 			opts = append(opts, point.WithTimestamp(ptTS))
@@ -17,6 +17,10 @@ const tcpMetricName = "host_tcp_exception"
 
 func (ipt *Input) collectTCP(ptTS int64) error {
 	for _, tcp := range ipt.tcp {
+		if !tcp.due(ptTS) {
+			continue
+		}
+
 		for _, ip := range tcp.HostPorts {
 			host, port, err := net.SplitHostPort(ip)
 			if err != nil {
@@ -67,9 +71,7 @@ func (ipt *Input) collectTCP(ptTS int64) error {
 				}
 				kvs = kvs.Add("type", failType, true, true)
 			}
-			for k, v := range ipt.mergedTags {
-				kvs = kvs.AddTag(k, v)
-			}
+			kvs = tcp.addTags(kvs, ipt.mergedTags)
 
 			opts := point.DefaultMetricOptions()
 			opts = append(opts, point.WithTimestamp(ptTS))
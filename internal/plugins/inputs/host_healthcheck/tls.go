@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package healthcheck
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+const tlsMetricName = "host_tls_exception"
+
+// tlsCert checks certificates served on host ports are valid and not going to
+// expire.
+type tlsCert struct {
+	checkOption
+
+	HostPorts         []string `toml:"host_ports" json:"host_ports"`
+	ServerName        string   `toml:"server_name" json:"server_name"`
+	MinValidDays      int      `toml:"min_valid_days" json:"min_valid_days"`
+	Timeout           string   `toml:"timeout" json:"timeout"`
+	IgnoreInsecureTLS bool     `toml:"ignore_insecure_tls" json:"ignore_insecure_tls"`
+
+	timeout time.Duration
+}
+
+func (c *tlsCert) init() error {
+	c.initOption()
+
+	c.HostPorts = filterEmptyValues(c.HostPorts)
+	if len(c.HostPorts) == 0 {
+		return fmt.Errorf("host_ports not set")
+	}
+
+	if c.MinValidDays == 0 {
+		c.MinValidDays = 14
+	}
+
+	c.timeout = 10 * time.Second
+	if c.Timeout != "" {
+		du, err := time.ParseDuration(c.Timeout)
+		if err != nil {
+			return fmt.Errorf("parse timeout: %w", err)
+		}
+		c.timeout = du
+	}
+
+	return nil
+}
+
+// check returns days before the leaf certificate expired.
+func (c *tlsCert) check(hostPort string) (float64, error) {
+	host, _, err := net.SplitHostPort(hostPort)
+	if err != nil {
+		return 0, err
+	}
+
+	serverName := c.ServerName
+	if serverName == "" {
+		serverName = host
+	}
+
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: c.timeout}, "tcp", hostPort, &tls.Config{
+		ServerName:         serverName,
+		InsecureSkipVerify: c.IgnoreInsecureTLS, //nolint:gosec
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close() //nolint:errcheck
+
+	certs := conn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return 0, fmt.Errorf("no certificate")
+	}
+
+	days := time.Until(certs[0].NotAfter).Hours() / 24
+	if days < float64(c.MinValidDays) {
+		return days, newCheckError(reasonCertExpiring, "certificate expires in %.1f days(%s)", days, certs[0].NotAfter.Format(time.RFC3339))
+	}
+
+	return days, nil
+}
+
+func (ipt *Input) collectTLS(ptTS int64) error {
+	for _, c := range ipt.tls {
+		if !c.due(ptTS) {
+			continue
+		}
+
+		for _, hostPort := range c.HostPorts {
+			days, err := c.check(hostPort)
+			if err != nil {
+				l.Infof("tls check on %s failed: %s", hostPort, err)
+			}
+
+			var kvs point.KVs
+			kvs = kvs.Add("host_port", hostPort, true, true)
+			kvs = addError(kvs, err)
+			kvs = kvs.Add("exception", err != nil, false, true)
+			kvs = kvs.Add("expire_days", days, false, true)
+
+			ipt.appendPoint(tlsMetricName, kvs, &c.checkOption, ptTS)
+		}
+	}
+
+	return nil
+}
@@ -21,6 +21,9 @@ const (
 	Azure       = "azure"
 	Hwcloud     = "hwcloud"
 	VolcEngine  = "volcengine"
+	GCP         = "gcp"
+	OCI         = "oci"
+	OpenStack   = "openstack"
 )
 
 var cloudCli = &http.Client{Timeout: 3 * time.Second}
@@ -95,6 +98,30 @@ func (ipt *Input) SyncCloudInfo(provider string) (map[string]interface{}, error)
 			p = &volcEcs{baseURL: volcMetaRootURL}
 		}
 		return p.Sync()
+	case GCP:
+		var p *gcp
+		if url, ok := ipt.CloudMetaURL[GCP]; ok {
+			p = &gcp{baseURL: url}
+		} else {
+			p = &gcp{baseURL: gcpMetaRootURL}
+		}
+		return p.Sync()
+	case OCI:
+		var p *oci
+		if url, ok := ipt.CloudMetaURL[OCI]; ok {
+			p = &oci{baseURL: url}
+		} else {
+			p = &oci{baseURL: ociMetaRootURL}
+		}
+		return p.Sync()
+	case OpenStack:
+		var p *openstack
+		if url, ok := ipt.CloudMetaURL[OpenStack]; ok {
+			p = &openstack{baseURL: url}
+		} else {
+			p = &openstack{baseURL: openstackDefaultURL()}
+		}
+		return p.Sync()
 	default:
 		return nil, fmt.Errorf("unknown cloud_provider: %s", provider)
 	}
@@ -121,7 +148,8 @@ func (ipt *Input) matchCloudProvider(cloudProvider string) bool {
 }
 
 func (ipt *Input) SetCloudProvider() error {
-	cloudProviders := []string{Aliyun, AWS, Tencent, Azure, Hwcloud, VolcEngine}
+	// OpenStack should be the last, other clouds may also be compatible with it.
+	cloudProviders := []string{Aliyun, AWS, Tencent, Azure, Hwcloud, VolcEngine, GCP, OCI, OpenStack}
 	for _, cp := range cloudProviders {
 		if ipt.matchCloudProvider(cp) {
 			ipt.Tags["cloud_provider"] = cp
@@ -172,12 +200,23 @@ func metadataGet(metaURL string) []byte {
 }
 
 func metadataGetByHeader(metaURL string) []byte {
+	return metadataGetWithHeader(metaURL, "Metadata", "true")
+}
+
+func metadataGetWithHeader(metaURL, key, value string) []byte {
 	req, err := http.NewRequest("GET", metaURL, nil)
 	if err != nil {
 		l.Warn(err)
 		return nil
 	}
-	req.Header.Set("Metadata", "true")
+	req.Header.Set(key, value)
 
 	return clientDo(req, metaURL)
 }
+
+func orUnavailable(s string) string {
+	if s == "" {
+		return Unavailable
+	}
+	return s
+}
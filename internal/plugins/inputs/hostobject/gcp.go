@@ -0,0 +1,146 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"encoding/json"
+	"path"
+	"strings"
+)
+
+const gcpMetaRootURL = "http://metadata.google.internal/computeMetadata/v1"
+
+type gcp struct {
+	baseURL string
+
+	meta    *gcpMetaData
+	fetched bool
+}
+
+type gcpMetaData struct {
+	ID                json.Number `json:"id"`
+	Name              string      `json:"name"`
+	Description       string      `json:"description"`
+	MachineType       string      `json:"machineType"` // projects/<project-num>/machineTypes/<type>
+	Zone              string      `json:"zone"`        // projects/<project-num>/zones/<zone>
+	NetworkInterfaces []struct {
+		IP string `json:"ip"`
+	} `json:"networkInterfaces"`
+	Scheduling struct {
+		ProvisioningModel string `json:"provisioningModel"` // STANDARD or SPOT
+	} `json:"scheduling"`
+}
+
+func (x *gcp) Sync() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"cloud_provider":        GCP,
+		"description":           x.Description(),
+		"instance_id":           x.InstanceID(),
+		"instance_name":         x.InstanceName(),
+		"instance_type":         x.InstanceType(),
+		"instance_charge_type":  x.InstanceChargeType(),
+		"instance_network_type": x.InstanceNetworkType(),
+		"instance_status":       x.InstanceStatus(),
+		"security_group_id":     x.SecurityGroupID(),
+		"private_ip":            x.PrivateIP(),
+		"zone_id":               x.ZoneID(),
+		"region":                x.Region(),
+	}, nil
+}
+
+// getGCPMetaData fetch all instance metadata in one request, the result is
+// reused during one sync.
+func (x *gcp) getGCPMetaData() *gcpMetaData {
+	if x.fetched {
+		return x.meta
+	}
+	x.fetched = true
+
+	resp := metadataGetWithHeader(x.baseURL+"/instance/?recursive=true", "Metadata-Flavor", "Google")
+	if resp == nil {
+		return nil
+	}
+
+	model := &gcpMetaData{}
+	if err := json.Unmarshal(resp, model); err != nil {
+		l.Warnf("marshal json failed: %s", err)
+		return nil
+	}
+
+	x.meta = model
+	return x.meta
+}
+
+func (x *gcp) Description() string {
+	if m := x.getGCPMetaData(); m != nil {
+		return orUnavailable(m.Description)
+	}
+	return Unavailable
+}
+
+func (x *gcp) InstanceID() string {
+	if m := x.getGCPMetaData(); m != nil {
+		return orUnavailable(m.ID.String())
+	}
+	return Unavailable
+}
+
+func (x *gcp) InstanceName() string {
+	if m := x.getGCPMetaData(); m != nil {
+		return orUnavailable(m.Name)
+	}
+	return Unavailable
+}
+
+func (x *gcp) InstanceType() string {
+	if m := x.getGCPMetaData(); m != nil && m.MachineType != "" {
+		return path.Base(m.MachineType)
+	}
+	return Unavailable
+}
+
+func (x *gcp) InstanceChargeType() string {
+	if m := x.getGCPMetaData(); m != nil {
+		return orUnavailable(m.Scheduling.ProvisioningModel)
+	}
+	return Unavailable
+}
+
+func (x *gcp) InstanceNetworkType() string {
+	return Unavailable
+}
+
+func (x *gcp) InstanceStatus() string {
+	return Unavailable
+}
+
+func (x *gcp) SecurityGroupID() string {
+	return Unavailable
+}
+
+func (x *gcp) PrivateIP() string {
+	if m := x.getGCPMetaData(); m != nil && len(m.NetworkInterfaces) > 0 {
+		return orUnavailable(m.NetworkInterfaces[0].IP)
+	}
+	return Unavailable
+}
+
+func (x *gcp) ZoneID() string {
+	if m := x.getGCPMetaData(); m != nil && m.Zone != "" {
+		return path.Base(m.Zone)
+	}
+	return Unavailable
+}
+
+func (x *gcp) Region() string {
+	// region is the zone without suffix, such as us-central1 of us-central1-a
+	if zone := x.ZoneID(); zone != Unavailable {
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			return zone[:i]
+		}
+	}
+	return Unavailable
+}
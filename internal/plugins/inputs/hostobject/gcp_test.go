@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const gcpInstanceData = `{
+  "cpuPlatform": "Intel Broadwell",
+  "description": "web server",
+  "hostname": "gcp-test.us-central1-a.c.my-project.internal",
+  "id": 4520031799277581759,
+  "machineType": "projects/123456789012/machineTypes/e2-medium",
+  "name": "gcp-test",
+  "networkInterfaces": [
+    {
+      "ip": "10.128.0.2",
+      "network": "projects/123456789012/networks/default"
+    }
+  ],
+  "scheduling": {
+    "automaticRestart": "TRUE",
+    "onHostMaintenance": "MIGRATE",
+    "preemptible": "FALSE",
+    "provisioningModel": "STANDARD"
+  },
+  "tags": ["http-server"],
+  "zone": "projects/123456789012/zones/us-central1-a"
+}`
+
+func testGCPServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Metadata-Flavor") != "Google" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/computeMetadata/v1/instance/":
+			if r.URL.Query().Get("recursive") == "true" {
+				w.Header().Set("Metadata-Flavor", "Google")
+				fmt.Fprint(w, gcpInstanceData)
+				return
+			}
+			fallthrough
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+}
+
+func TestGCP(t *testing.T) {
+	ts := testGCPServer()
+	defer ts.Close()
+
+	info, err := (&gcp{baseURL: ts.URL + "/computeMetadata/v1"}).Sync()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"cloud_provider":        "gcp",
+		"description":           "web server",
+		"instance_id":           "4520031799277581759",
+		"instance_name":         "gcp-test",
+		"instance_type":         "e2-medium",
+		"instance_charge_type":  "STANDARD",
+		"instance_network_type": Unavailable,
+		"instance_status":       Unavailable,
+		"security_group_id":     Unavailable,
+		"private_ip":            "10.128.0.2",
+		"zone_id":               "us-central1-a",
+		"region":                "us-central1",
+	}, info)
+
+	t.Run("not-gcp", func(t *testing.T) {
+		info, err := (&gcp{baseURL: ts.URL + "/not-found"}).Sync()
+		require.NoError(t, err)
+		assert.Equal(t, Unavailable, info["instance_id"])
+		assert.Equal(t, Unavailable, info["region"])
+	})
+
+	t.Run("detect", func(t *testing.T) {
+		ipt := defaultInput()
+		ipt.CloudMetaURL = map[string]string{GCP: ts.URL + "/computeMetadata/v1"}
+		assert.True(t, ipt.matchCloudProvider(GCP))
+	})
+}
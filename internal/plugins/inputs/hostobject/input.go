@@ -83,6 +83,11 @@ type Input struct {
 	CloudInfo                map[string]string `toml:"cloud_info,omitempty"`
 	lastSync                 time.Time
 
+	EnableInventory   bool          `toml:"enable_inventory"`
+	InventoryInterval time.Duration `toml:"inventory_interval"`
+	lastInventory     *inventory
+	lastInventoryTime time.Time
+
 	netIOCounters  NetIOCounters
 	diskIOCounters DiskIOCounters
 	lastDiskIOInfo diskIOInfo
@@ -126,6 +131,8 @@ func (ipt *Input) Run() {
 			}
 		}
 
+		ipt.collectInventory()
+
 		select {
 		case <-datakit.Exit.Wait():
 			l.Infof("%s exit on sem", inputName)
@@ -147,6 +154,7 @@ func (ipt *Input) setup() {
 
 	l.Infof("%s input started", inputName)
 	ipt.Interval = config.ProtectedInterval(minInterval, maxInterval, ipt.Interval)
+	ipt.InventoryInterval = config.ProtectedInterval(minInventoryInterval, maxInventoryInterval, ipt.InventoryInterval)
 	ipt.mergedTags = inputs.MergeTags(ipt.tagger.HostTags(), ipt.Tags, "")
 	l.Debugf("merged tags: %+#v", ipt.mergedTags)
 }
@@ -270,6 +278,9 @@ func (*Input) AvailableArchs() []string { return datakit.AllOS }
 func (*Input) SampleMeasurement() []inputs.Measurement {
 	return []inputs.Measurement{
 		&docMeasurement{},
+		&inventoryMeasurement{},
+		&inventoryItemMeasurement{},
+		&inventoryChangeMeasurement{},
 	}
 }
 
@@ -283,8 +294,10 @@ func (ipt *Input) GetENVDoc() []*inputs.ENVInfo {
 		{FieldName: "ExtraDevice", ENVName: "INPUT_HOSTOBJECT_EXTRA_DEVICE", ConfField: "extra_device", Type: doc.List, Example: "`/nfsdata,other`", Desc: "Additional device", DescZh: "额外增加的 device"},
 		{FieldName: "EnableCloudHostTagsGlobalElection", ENVName: "INPUT_HOSTOBJECT_CLOUD_META_AS_ELECTION_TAGS", ConfField: "enable_cloud_host_tags_global_election_tags", Type: doc.Boolean, Default: "true", Desc: "Enable put cloud provider region/zone_id information into global election tags", DescZh: "将云服务商 region/zone_id 信息放入全局选举标签"},
 		{FieldName: "EnableCloudHostTagsGlobalHost", ENVName: "INPUT_HOSTOBJECT_CLOUD_META_AS_HOST_TAGS", ConfField: "enable_cloud_host_tags_global_host_tags", Type: doc.Boolean, Default: "true", Desc: "Enable put cloud provider region/zone_id information into global host tags", DescZh: "将云服务商 region/zone_id 信息放入全局主机标签"},
+		{FieldName: "EnableInventory", ENVName: "INPUT_HOSTOBJECT_ENABLE_INVENTORY", ConfField: "enable_inventory", Type: doc.Boolean, Default: `false`, Desc: "Enable collect installed packages, kernel modules, systemd units and listening sockets", DescZh: "开启采集已安装软件包、内核模块、systemd 单元及监听端口"},
+		{FieldName: "InventoryInterval", ENVName: "INPUT_HOSTOBJECT_INVENTORY_INTERVAL", ConfField: "inventory_interval", Type: doc.TimeDuration, Default: `1h`, Desc: "Interval of refreshing host inventory", DescZh: "主机软件清单刷新间隔"},
 		{FieldName: "Tags", ENVName: "INPUT_HOSTOBJECT_TAGS", ConfField: "tags"},
-		{FieldName: "ENVCloud", ENVName: "CLOUD_PROVIDER", ConfField: "none", Type: doc.String, Example: "`aliyun/aws/tencent/hwcloud/azure`", Desc: "Designate cloud service provider", DescZh: "指定云服务商"},
+		{FieldName: "ENVCloud", ENVName: "CLOUD_PROVIDER", ConfField: "none", Type: doc.String, Example: "`aliyun/aws/tencent/hwcloud/azure/gcp/oci/openstack`", Desc: "Designate cloud service provider", DescZh: "指定云服务商"},
 		{FieldName: "CloudMetaURL", ENVName: "CLOUD_META_URL", ConfField: "cloud_meta_url", Type: doc.Map, Example: "`{\"tencent\":\"xxx\", \"aliyun\":\"yyy\"}`", Desc: "Cloud metadata URL mapping", DescZh: "云服务商元数据 URL 映射"},
 	}
 
@@ -343,6 +356,22 @@ func (ipt *Input) ReadEnv(envs map[string]string) {
 			ipt.EnableCloudHostTagsGlobalHost = b
 		}
 	}
+	if enable, ok := envs["ENV_INPUT_HOSTOBJECT_ENABLE_INVENTORY"]; ok {
+		b, err := strconv.ParseBool(enable)
+		if err != nil {
+			l.Warnf("parse ENV_INPUT_HOSTOBJECT_ENABLE_INVENTORY to bool: %s, ignore", err)
+		} else {
+			ipt.EnableInventory = b
+		}
+	}
+	if v, ok := envs["ENV_INPUT_HOSTOBJECT_INVENTORY_INTERVAL"]; ok {
+		du, err := time.ParseDuration(v)
+		if err != nil {
+			l.Warnf("parse ENV_INPUT_HOSTOBJECT_INVENTORY_INTERVAL to duration: %s, ignore", err)
+		} else {
+			ipt.InventoryInterval = du
+		}
+	}
 	if tagsStr, ok := envs["ENV_INPUT_HOSTOBJECT_TAGS"]; ok {
 		tags := config.ParseGlobalTags(tagsStr)
 		for k, v := range tags {
@@ -355,7 +384,7 @@ func (ipt *Input) ReadEnv(envs map[string]string) {
 		cloudProvider := dkstring.TrimString(tagsStr)
 		cloudProvider = strings.ToLower(cloudProvider)
 		switch cloudProvider {
-		case "aliyun", "tencent", "aws", "hwcloud", "azure":
+		case "aliyun", "tencent", "aws", "hwcloud", "azure", GCP, OCI, OpenStack:
 			ipt.Tags["cloud_provider"] = cloudProvider
 		}
 	} // ENV_CLOUD_PROVIDER
@@ -406,6 +435,7 @@ func defaultInput() *Input {
 		diskIOCounters:                              diskutil.IOCounters,
 		netIOCounters:                               netutil.IOCounters,
 		MergeOnDevice:                               false,
+		InventoryInterval:                           defaultInventoryInterval,
 
 		semStop:       cliutils.NewSem(),
 		feeder:        dkio.DefaultFeeder(),
@@ -0,0 +1,622 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	netutil "github.com/shirou/gopsutil/net"
+	processutil "github.com/shirou/gopsutil/process"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	dkmetrics "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
+)
+
+const (
+	inventoryMeasurementName     = "host_inventory"
+	inventoryItemMeasurementName = "host_inventory_item"
+	inventoryFeedName            = inputName + "/inventory"
+
+	defaultInventoryInterval = time.Hour
+	minInventoryInterval     = 5 * time.Minute
+	maxInventoryInterval     = 24 * time.Hour
+
+	// Inventory categories.
+	categoryPackage      = "package"
+	categoryKernelModule = "kernel_module"
+	categorySystemdUnit  = "systemd_unit"
+	categoryListenSocket = "listen_socket"
+
+	// Inventory changes.
+	changeAdded   = "added"
+	changeRemoved = "removed"
+	changeChanged = "changed"
+
+	// Package managers.
+	pkgManagerDpkg = "dpkg"
+	pkgManagerRPM  = "rpm"
+	pkgManagerApk  = "apk"
+)
+
+type (
+	invPackage struct {
+		Name    string
+		Version string
+		Arch    string
+		Manager string
+	}
+
+	invKernelModule struct {
+		Name  string
+		Size  int64
+		State string
+	}
+
+	invSystemdUnit struct {
+		Name          string
+		LoadState     string
+		ActiveState   string
+		SubState      string
+		UnitFileState string
+	}
+
+	invListenSocket struct {
+		Protocol string
+		Address  string
+		Port     int
+		PID      int
+		Process  string
+	}
+
+	// inventory is a snapshot of software installed and running on the host.
+	inventory struct {
+		Packages      []*invPackage
+		KernelModules []*invKernelModule
+		SystemdUnits  []*invSystemdUnit
+		ListenSockets []*invListenSocket
+
+		managers []string
+	}
+
+	inventoryChange struct {
+		Category string
+		Item     string
+		Change   string
+		Old      string
+		New      string
+	}
+)
+
+// items returns the state of each inventory item by category, items with
+// different state are reported as changed.
+func (inv *inventory) items() map[string]map[string]string {
+	res := map[string]map[string]string{
+		categoryPackage:      {},
+		categoryKernelModule: {},
+		categorySystemdUnit:  {},
+		categoryListenSocket: {},
+	}
+
+	for _, p := range inv.Packages {
+		res[categoryPackage][p.key()] = p.Version
+	}
+
+	for _, m := range inv.KernelModules {
+		res[categoryKernelModule][m.Name] = ""
+	}
+
+	for _, u := range inv.SystemdUnits {
+		state := u.ActiveState + "/" + u.SubState
+		if u.UnitFileState != "" {
+			state += " " + u.UnitFileState
+		}
+		res[categorySystemdUnit][u.Name] = state
+	}
+
+	// PID changes on every restart of the process, it's not a change of the
+	// inventory.
+	for _, s := range inv.ListenSockets {
+		res[categoryListenSocket][s.key()] = s.Process
+	}
+
+	return res
+}
+
+// key identify the package among packages of all package managers, such as
+// dpkg:openssl.amd64.
+func (p *invPackage) key() string {
+	key := p.Manager + ":" + p.Name
+	if p.Arch != "" {
+		key += "." + p.Arch
+	}
+	return key
+}
+
+// key identify the socket, such as tcp:0.0.0.0:22.
+func (s *invListenSocket) key() string {
+	return fmt.Sprintf("%s:%s:%d", s.Protocol, s.Address, s.Port)
+}
+
+func (inv *inventory) sort() {
+	sort.Slice(inv.Packages, func(i, j int) bool {
+		a, b := inv.Packages[i], inv.Packages[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Arch != b.Arch {
+			return a.Arch < b.Arch
+		}
+		return a.Manager < b.Manager
+	})
+
+	sort.Slice(inv.KernelModules, func(i, j int) bool {
+		return inv.KernelModules[i].Name < inv.KernelModules[j].Name
+	})
+
+	sort.Slice(inv.SystemdUnits, func(i, j int) bool {
+		return inv.SystemdUnits[i].Name < inv.SystemdUnits[j].Name
+	})
+
+	sort.Slice(inv.ListenSockets, func(i, j int) bool {
+		a, b := inv.ListenSockets[i], inv.ListenSockets[j]
+		if a.Protocol != b.Protocol {
+			return a.Protocol < b.Protocol
+		}
+		if a.Port != b.Port {
+			return a.Port < b.Port
+		}
+		return a.Address < b.Address
+	})
+}
+
+// diffInventory returns changes from prev to cur, sorted by category and item.
+func diffInventory(prev, cur *inventory) []*inventoryChange {
+	if prev == nil || cur == nil {
+		return nil
+	}
+
+	var res []*inventoryChange
+	prevItems, curItems := prev.items(), cur.items()
+	for category, items := range curItems {
+		old := prevItems[category]
+		for k, v := range items {
+			ov, ok := old[k]
+			switch {
+			case !ok:
+				res = append(res, &inventoryChange{Category: category, Item: k, Change: changeAdded, New: v})
+			case ov != v:
+				res = append(res, &inventoryChange{Category: category, Item: k, Change: changeChanged, Old: ov, New: v})
+			}
+		}
+
+		for k, v := range old {
+			if _, ok := items[k]; !ok {
+				res = append(res, &inventoryChange{Category: category, Item: k, Change: changeRemoved, Old: v})
+			}
+		}
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Category != res[j].Category {
+			return res[i].Category < res[j].Category
+		}
+		return res[i].Item < res[j].Item
+	})
+
+	return res
+}
+
+func (c *inventoryChange) message() string {
+	switch c.Change {
+	case changeChanged:
+		return fmt.Sprintf("%s %s changed: %s -> %s", c.Category, c.Item, c.Old, c.New)
+	case changeAdded:
+		if c.New != "" {
+			return fmt.Sprintf("%s %s added: %s", c.Category, c.Item, c.New)
+		}
+	case changeRemoved:
+		if c.Old != "" {
+			return fmt.Sprintf("%s %s removed: %s", c.Category, c.Item, c.Old)
+		}
+	}
+
+	return fmt.Sprintf("%s %s %s", c.Category, c.Item, c.Change)
+}
+
+var (
+	netConnections = netutil.Connections
+	processName    = func(pid int32) (string, error) {
+		p, err := processutil.NewProcess(pid)
+		if err != nil {
+			return "", err
+		}
+		return p.Name()
+	}
+)
+
+// listenSockets returns TCP sockets in LISTEN state and UDP sockets not
+// connected, with process listening on them.
+func listenSockets() ([]*invListenSocket, error) {
+	conns, err := netConnections("inet")
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		res   []*invListenSocket
+		names = map[int32]string{}
+		seen  = map[string]bool{}
+	)
+
+	for _, c := range conns {
+		var proto string
+		switch {
+		case c.Type == syscall.SOCK_STREAM && c.Status == "LISTEN":
+			proto = "tcp"
+		case c.Type == syscall.SOCK_DGRAM && c.Raddr.Port == 0:
+			proto = "udp"
+		default:
+			continue
+		}
+
+		if c.Family == syscall.AF_INET6 {
+			proto += "6"
+		}
+
+		s := &invListenSocket{Protocol: proto, Address: c.Laddr.IP, Port: int(c.Laddr.Port), PID: int(c.Pid)}
+
+		// Socket shared by multiple processes, such as nginx workers.
+		key := s.key()
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		if c.Pid > 0 {
+			name, ok := names[c.Pid]
+			if !ok {
+				if name, err = processName(c.Pid); err != nil {
+					l.Debugf("get name of process %d: %s, ignored", c.Pid, err)
+				}
+				names[c.Pid] = name
+			}
+			s.Process = name
+		}
+
+		res = append(res, s)
+	}
+
+	return res, nil
+}
+
+// readInventory collect inventory of the host. Parts not available on the host
+// are empty, parts failed are kept as last inventory to avoid fake changes.
+func (ipt *Input) readInventory() (*inventory, error) {
+	var (
+		inv  = &inventory{}
+		last = ipt.lastInventory
+		errs []string
+		err  error
+	)
+
+	if last == nil {
+		last = &inventory{}
+	}
+
+	if inv.Packages, inv.managers, err = ipt.readPackages(); err != nil {
+		errs = append(errs, fmt.Sprintf("packages: %s", err))
+		inv.Packages, inv.managers = last.Packages, last.managers
+	}
+
+	if inv.KernelModules, err = ipt.readKernelModules(); err != nil {
+		errs = append(errs, fmt.Sprintf("kernel modules: %s", err))
+		inv.KernelModules = last.KernelModules
+	}
+
+	if inv.SystemdUnits, err = ipt.readSystemdUnits(); err != nil {
+		errs = append(errs, fmt.Sprintf("systemd units: %s", err))
+		inv.SystemdUnits = last.SystemdUnits
+	}
+
+	if inv.ListenSockets, err = listenSockets(); err != nil {
+		errs = append(errs, fmt.Sprintf("listen sockets: %s", err))
+		inv.ListenSockets = last.ListenSockets
+	}
+
+	if len(errs) > 0 {
+		return inv, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	return inv, nil
+}
+
+// inventoryPoint returns the summary of inventory, items are reported by
+// inventoryItemPoints.
+func (ipt *Input) inventoryPoint(inv *inventory, ts time.Time) *point.Point {
+	var kvs point.KVs
+
+	kvs = kvs.AddTag("name", datakit.DatakitHostName).
+		AddTag("package_managers", strings.Join(inv.managers, ",")).
+		Add("package_count", len(inv.Packages), false, true).
+		Add("kernel_module_count", len(inv.KernelModules), false, true).
+		Add("systemd_unit_count", len(inv.SystemdUnits), false, true).
+		Add("listen_socket_count", len(inv.ListenSockets), false, true)
+
+	for k, v := range ipt.mergedTags {
+		kvs = kvs.AddTag(k, v)
+	}
+
+	opts := point.DefaultObjectOptions()
+	opts = append(opts, point.WithTime(ts))
+
+	return point.NewPointV2(inventoryMeasurementName, kvs, opts...)
+}
+
+// inventoryItemPoints returns one object for each item of inventory, so that
+// items can be queried and filtered one by one instead of a huge list.
+func (ipt *Input) inventoryItemPoints(inv *inventory, ts time.Time) []*point.Point {
+	opts := point.DefaultObjectOptions()
+	opts = append(opts, point.WithTime(ts))
+
+	pts := make([]*point.Point, 0,
+		len(inv.Packages)+len(inv.KernelModules)+len(inv.SystemdUnits)+len(inv.ListenSockets))
+
+	add := func(category, item string, kvs point.KVs) {
+		kvs = kvs.AddTag("name", fmt.Sprintf("%s:%s:%s", datakit.DatakitHostName, category, item)).
+			AddTag("category", category).
+			AddTag("item", item)
+
+		for k, v := range ipt.mergedTags {
+			kvs = kvs.AddTag(k, v)
+		}
+
+		pts = append(pts, point.NewPointV2(inventoryItemMeasurementName, kvs, opts...))
+	}
+
+	for _, p := range inv.Packages {
+		var kvs point.KVs
+		add(categoryPackage, p.key(), kvs.Add("package_name", p.Name, false, true).
+			Add("version", p.Version, false, true).
+			Add("arch", p.Arch, false, true).
+			Add("manager", p.Manager, false, true))
+	}
+
+	for _, m := range inv.KernelModules {
+		var kvs point.KVs
+		add(categoryKernelModule, m.Name, kvs.Add("size", m.Size, false, true).
+			Add("state", m.State, false, true))
+	}
+
+	for _, u := range inv.SystemdUnits {
+		var kvs point.KVs
+		add(categorySystemdUnit, u.Name, kvs.Add("load_state", u.LoadState, false, true).
+			Add("active_state", u.ActiveState, false, true).
+			Add("sub_state", u.SubState, false, true).
+			Add("unit_file_state", u.UnitFileState, false, true))
+	}
+
+	for _, s := range inv.ListenSockets {
+		var kvs point.KVs
+		add(categoryListenSocket, s.key(), kvs.Add("protocol", s.Protocol, false, true).
+			Add("address", s.Address, false, true).
+			Add("port", s.Port, false, true).
+			Add("pid", s.PID, false, true).
+			Add("process", s.Process, false, true))
+	}
+
+	return pts
+}
+
+func (ipt *Input) inventoryChangePoints(changes []*inventoryChange, ts time.Time) []*point.Point {
+	var pts []*point.Point
+
+	opts := point.DefaultLoggingOptions()
+	opts = append(opts, point.WithTime(ts))
+
+	for _, c := range changes {
+		var kvs point.KVs
+		kvs = kvs.AddTag("category", c.Category).
+			AddTag("change", c.Change).
+			AddTag("item", c.Item).
+			Add("message", c.message(), false, true).
+			Add("status", "info", false, true).
+			Add("old", c.Old, false, true).
+			Add("new", c.New, false, true)
+
+		for k, v := range ipt.mergedTags {
+			kvs = kvs.AddTag(k, v)
+		}
+
+		pts = append(pts, point.NewPointV2(inventoryMeasurementName, kvs, opts...))
+	}
+
+	return pts
+}
+
+// collectInventory refresh the inventory object if it's expired, changes since
+// last inventory are fed as logging.
+func (ipt *Input) collectInventory() {
+	if !ipt.EnableInventory || time.Since(ipt.lastInventoryTime) < ipt.InventoryInterval {
+		return
+	}
+
+	start := time.Now()
+	ipt.lastInventoryTime = start
+
+	inv, err := ipt.readInventory()
+	if err != nil {
+		l.Warnf("read inventory: %s", err)
+		ipt.feeder.FeedLastError(err.Error(),
+			dkmetrics.WithLastErrorInput(inputName),
+			dkmetrics.WithLastErrorSource(inventoryMeasurementName),
+		)
+	}
+
+	inv.sort()
+
+	// No changes reported on the first inventory after started.
+	changes := diffInventory(ipt.lastInventory, inv)
+	ipt.lastInventory = inv
+
+	pts := append([]*point.Point{ipt.inventoryPoint(inv, start)}, ipt.inventoryItemPoints(inv, start)...)
+	if err := ipt.feeder.FeedV2(point.CustomObject, pts,
+		dkio.WithCollectCost(time.Since(start)),
+		dkio.WithElection(false),
+		dkio.WithInputName(inventoryFeedName)); err != nil {
+		ipt.feeder.FeedLastError(err.Error(),
+			dkmetrics.WithLastErrorInput(inputName),
+			dkmetrics.WithLastErrorCategory(point.CustomObject),
+		)
+		l.Errorf("feed inventory: %s", err)
+	}
+
+	if len(changes) == 0 {
+		return
+	}
+
+	l.Infof("%d inventory changes found", len(changes))
+
+	if err := ipt.feeder.FeedV2(point.Logging, ipt.inventoryChangePoints(changes, start),
+		dkio.WithElection(false),
+		dkio.WithInputName(inventoryFeedName)); err != nil {
+		ipt.feeder.FeedLastError(err.Error(),
+			dkmetrics.WithLastErrorInput(inputName),
+			dkmetrics.WithLastErrorCategory(point.Logging),
+		)
+		l.Errorf("feed inventory changes: %s", err)
+	}
+}
+
+// parseDpkgStatus parse installed packages in dpkg status file
+// (/var/lib/dpkg/status).
+func parseDpkgStatus(r io.Reader) ([]*invPackage, error) {
+	var (
+		res       []*invPackage
+		pkg       = &invPackage{Manager: pkgManagerDpkg}
+		installed bool
+	)
+
+	flush := func() {
+		if pkg.Name != "" && installed {
+			res = append(res, pkg)
+		}
+		pkg, installed = &invPackage{Manager: pkgManagerDpkg}, false
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.TrimSpace(line) == "" {
+			flush()
+			continue
+		}
+
+		// Continuation line of multi-line field, such as Description.
+		if line[0] == ' ' || line[0] == '\t' {
+			continue
+		}
+
+		k, v, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+
+		v = strings.TrimSpace(v)
+		switch k {
+		case "Package":
+			pkg.Name = v
+		case "Version":
+			pkg.Version = v
+		case "Architecture":
+			pkg.Arch = v
+		case "Status":
+			// Such as "install ok installed", the last word is package status.
+			fields := strings.Fields(v)
+			installed = len(fields) == 3 && fields[2] == "installed"
+		}
+	}
+
+	flush()
+
+	return res, scanner.Err()
+}
+
+// parseApkInstalled parse installed packages in apk database
+// (/lib/apk/db/installed).
+func parseApkInstalled(r io.Reader) ([]*invPackage, error) {
+	var (
+		res []*invPackage
+		pkg = &invPackage{Manager: pkgManagerApk}
+	)
+
+	flush := func() {
+		if pkg.Name != "" {
+			res = append(res, pkg)
+		}
+		pkg = &invPackage{Manager: pkgManagerApk}
+	}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			flush()
+			continue
+		}
+
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+
+		switch line[0] {
+		case 'P':
+			pkg.Name = line[2:]
+		case 'V':
+			pkg.Version = line[2:]
+		case 'A':
+			pkg.Arch = line[2:]
+		}
+	}
+
+	flush()
+
+	return res, scanner.Err()
+}
+
+// parseProcModules parse loaded kernel modules in /proc/modules, such as
+//
+//	nf_conntrack 172032 2 nf_nat,xt_conntrack, Live 0x0000000000000000
+func parseProcModules(r io.Reader) ([]*invKernelModule, error) {
+	var res []*invKernelModule
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 5 {
+			continue
+		}
+
+		size, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+
+		res = append(res, &invKernelModule{Name: fields[0], Size: size, State: fields[4]})
+	}
+
+	return res, scanner.Err()
+}
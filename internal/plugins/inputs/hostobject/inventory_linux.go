@@ -0,0 +1,271 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build linux
+// +build linux
+
+package hostobject
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	sddbus "github.com/coreos/go-systemd/v22/dbus"
+	"github.com/godbus/dbus/v5"
+	_ "modernc.org/sqlite"
+)
+
+const systemdTimeout = 10 * time.Second
+
+// rpmdb locations, newer rpm(>= 4.16) use sqlite, SUSE use ndb, others use
+// Berkeley DB.
+var rpmDBFiles = []string{
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"/var/lib/rpm/rpmdb.sqlite",
+	"/usr/lib/sysimage/rpm/Packages.db",
+	"/var/lib/rpm/Packages.db",
+	"/var/lib/rpm/Packages",
+}
+
+// hostPath returns path p on host, it's under host root if running in
+// container.
+func (ipt *Input) hostPath(p string) string {
+	if ipt.hostRoot != "" {
+		if _, err := os.Stat(ipt.hostRoot); err == nil {
+			return filepath.Join(ipt.hostRoot, p)
+		}
+	}
+	return p
+}
+
+// readPackages read installed packages in dpkg, rpm and apk databases, a host
+// may have multiple package managers installed.
+func (ipt *Input) readPackages() ([]*invPackage, []string, error) {
+	var (
+		res      []*invPackage
+		managers []string
+	)
+
+	for _, x := range []struct {
+		manager string
+		path    string
+		parse   func(string) ([]*invPackage, error)
+	}{
+		{pkgManagerDpkg, "/var/lib/dpkg/status", readDpkgStatus},
+		{pkgManagerApk, "/lib/apk/db/installed", readApkInstalled},
+	} {
+		path := ipt.hostPath(x.path)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		pkgs, err := x.parse(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read %s database %s: %w", x.manager, path, err)
+		}
+
+		res = append(res, pkgs...)
+		managers = append(managers, x.manager)
+	}
+
+	for _, f := range rpmDBFiles {
+		path := ipt.hostPath(f)
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+
+		pkgs, err := readRPMDB(path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read rpm database %s: %w", path, err)
+		}
+
+		res = append(res, pkgs...)
+		managers = append(managers, pkgManagerRPM)
+		break
+	}
+
+	return res, managers, nil
+}
+
+func readDpkgStatus(path string) ([]*invPackage, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	return parseDpkgStatus(f)
+}
+
+func readApkInstalled(path string) ([]*invPackage, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	return parseApkInstalled(f)
+}
+
+func readRPMDB(path string) ([]*invPackage, error) {
+	var (
+		blobs [][]byte
+		err   error
+	)
+
+	switch {
+	case strings.HasSuffix(path, ".sqlite"):
+		blobs, err = readRPMSqlite(path)
+	case strings.HasSuffix(path, ".db"):
+		blobs, err = readRPMFile(path, readNDBValues)
+	default:
+		blobs, err = readRPMFile(path, readBerkeleyDBValues)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	var res []*invPackage
+	for _, blob := range blobs {
+		pkg, err := parseRPMHeader(blob)
+		if err != nil {
+			l.Debugf("parse rpm header: %s, ignored", err)
+			continue
+		}
+
+		// GPG keys imported are stored as packages.
+		if pkg.Name == "gpg-pubkey" {
+			continue
+		}
+
+		res = append(res, pkg)
+	}
+
+	return res, nil
+}
+
+func readRPMSqlite(path string) ([][]byte, error) {
+	// Open read only and immutable, no lock or journal files created on host.
+	db, err := sql.Open("sqlite", fmt.Sprintf("file:%s?mode=ro&immutable=1", path))
+	if err != nil {
+		return nil, err
+	}
+	defer db.Close() //nolint:errcheck
+
+	rows, err := db.Query("SELECT blob FROM Packages")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var res [][]byte
+	for rows.Next() {
+		var blob []byte
+		if err := rows.Scan(&blob); err != nil {
+			return nil, err
+		}
+		res = append(res, blob)
+	}
+
+	return res, rows.Err()
+}
+
+func readRPMFile(path string, read func(io.ReaderAt) ([][]byte, error)) ([][]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	return read(f)
+}
+
+func (ipt *Input) readKernelModules() ([]*invKernelModule, error) {
+	f, err := os.Open(ipt.hostPath("/proc/modules"))
+	if err != nil {
+		// Kernel without module support, or in container without host's /proc.
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	return parseProcModules(f)
+}
+
+// readSystemdUnits list units loaded by systemd through its private socket, so
+// it works without dbus daemon.
+func (ipt *Input) readSystemdUnits() ([]*invSystemdUnit, error) {
+	sock := ipt.hostPath("/run/systemd/private")
+	if _, err := os.Stat(sock); err != nil {
+		// Not a systemd host.
+		return nil, nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), systemdTimeout)
+	defer cancel()
+
+	conn, err := sddbus.NewConnection(func() (*dbus.Conn, error) {
+		c, err := dbus.Dial("unix:path="+sock, dbus.WithContext(ctx))
+		if err != nil {
+			return nil, err
+		}
+
+		if err := c.Auth([]dbus.Auth{dbus.AuthExternal(strconv.Itoa(os.Getuid()))}); err != nil {
+			c.Close() //nolint:errcheck,gosec
+			return nil, err
+		}
+
+		return c, nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("connect systemd: %w", err)
+	}
+	defer conn.Close()
+
+	units, err := conn.ListUnitsContext(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("list units: %w", err)
+	}
+
+	fileStates := map[string]string{}
+	if files, err := conn.ListUnitFilesContext(ctx); err != nil {
+		l.Debugf("list unit files: %s, ignored", err)
+	} else {
+		for _, f := range files {
+			fileStates[filepath.Base(f.Path)] = f.Type
+		}
+	}
+
+	var res []*invSystemdUnit
+	for _, u := range units {
+		// Devices and mounts are not software installed.
+		if !strings.HasSuffix(u.Name, ".service") &&
+			!strings.HasSuffix(u.Name, ".socket") &&
+			!strings.HasSuffix(u.Name, ".timer") {
+			continue
+		}
+
+		res = append(res, &invSystemdUnit{
+			Name:          u.Name,
+			LoadState:     u.LoadState,
+			ActiveState:   u.ActiveState,
+			SubState:      u.SubState,
+			UnitFileState: fileStates[u.Name],
+		})
+	}
+
+	return res, nil
+}
@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build linux
+// +build linux
+
+package hostobject
+
+import (
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadPackages(t *testing.T) {
+	root := t.TempDir()
+
+	write := func(path string, data []byte) {
+		path = filepath.Join(root, path)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, data, 0o600))
+	}
+
+	write("/var/lib/dpkg/status", []byte(dpkgStatus))
+	write("/lib/apk/db/installed", []byte(apkInstalled))
+	write("/proc/modules", []byte(procModules))
+
+	require.NoError(t, os.MkdirAll(filepath.Join(root, "/var/lib/rpm"), 0o755))
+	db, err := sql.Open("sqlite", filepath.Join(root, "/var/lib/rpm/rpmdb.sqlite"))
+	require.NoError(t, err)
+
+	_, err = db.Exec("CREATE TABLE Packages (hnum INTEGER PRIMARY KEY AUTOINCREMENT, blob BLOB NOT NULL)")
+	require.NoError(t, err)
+
+	for _, name := range []string{"bash", "gpg-pubkey"} {
+		_, err = db.Exec("INSERT INTO Packages (blob) VALUES (?)", rpmHeader(map[uint32]string{
+			rpmTagName:    name,
+			rpmTagVersion: "5.1.8",
+			rpmTagRelease: "6.el9",
+			rpmTagArch:    "x86_64",
+		}, nil))
+		require.NoError(t, err)
+	}
+	require.NoError(t, db.Close())
+
+	ipt := defaultInput()
+	ipt.hostRoot = root
+
+	pkgs, managers, err := ipt.readPackages()
+	require.NoError(t, err)
+	assert.Equal(t, []string{pkgManagerDpkg, pkgManagerApk, pkgManagerRPM}, managers)
+	require.Len(t, pkgs, 5)
+	assert.Equal(t, &invPackage{Name: "bash", Version: "5.1.8-6.el9", Arch: "x86_64", Manager: pkgManagerRPM}, pkgs[4])
+
+	mods, err := ipt.readKernelModules()
+	require.NoError(t, err)
+	assert.Len(t, mods, 2)
+
+	// No systemd on the host.
+	units, err := ipt.readSystemdUnits()
+	require.NoError(t, err)
+	assert.Empty(t, units)
+
+	t.Run("berkeley-db", func(t *testing.T) {
+		root := t.TempDir()
+		ipt.hostRoot = root
+
+		write := func(path string, data []byte) {
+			path = filepath.Join(root, path)
+			require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+			require.NoError(t, os.WriteFile(path, data, 0o600))
+		}
+
+		write("/var/lib/rpm/Packages", berkeleyDB(rpmHeader(map[uint32]string{
+			rpmTagName:    "bash",
+			rpmTagVersion: "4.2.46",
+			rpmTagRelease: "35.el7_9",
+			rpmTagArch:    "x86_64",
+		}, nil), []byte("not a header")))
+
+		pkgs, managers, err := ipt.readPackages()
+		require.NoError(t, err)
+		assert.Equal(t, []string{pkgManagerRPM}, managers)
+		assert.Equal(t, []*invPackage{{Name: "bash", Version: "4.2.46-35.el7_9", Arch: "x86_64", Manager: pkgManagerRPM}}, pkgs)
+	})
+
+	t.Run("ndb", func(t *testing.T) {
+		root := t.TempDir()
+		ipt.hostRoot = root
+
+		path := filepath.Join(root, "/usr/lib/sysimage/rpm/Packages.db")
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, ndb(rpmHeader(map[uint32]string{
+			rpmTagName:    "bash",
+			rpmTagVersion: "4.4",
+			rpmTagRelease: "150400.27.3.2",
+			rpmTagArch:    "x86_64",
+		}, nil)), 0o600))
+
+		pkgs, managers, err := ipt.readPackages()
+		require.NoError(t, err)
+		assert.Equal(t, []string{pkgManagerRPM}, managers)
+		assert.Equal(t, []*invPackage{{Name: "bash", Version: "4.4-150400.27.3.2", Arch: "x86_64", Manager: pkgManagerRPM}}, pkgs)
+	})
+}
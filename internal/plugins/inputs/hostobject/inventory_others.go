@@ -0,0 +1,17 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build !linux
+// +build !linux
+
+package hostobject
+
+// Packages, kernel modules and systemd units are only available on Linux.
+
+func (*Input) readPackages() ([]*invPackage, []string, error) { return nil, nil, nil }
+
+func (*Input) readKernelModules() ([]*invKernelModule, error) { return nil, nil }
+
+func (*Input) readSystemdUnits() ([]*invSystemdUnit, error) { return nil, nil }
@@ -0,0 +1,384 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	netutil "github.com/shirou/gopsutil/net"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const dpkgStatus = `Package: openssl
+Status: install ok installed
+Priority: optional
+Architecture: amd64
+Version: 3.0.11-1~deb12u2
+Description: Secure Sockets Layer toolkit
+ This package is part of the OpenSSL project's implementation.
+
+Package: removed-pkg
+Status: deinstall ok config-files
+Architecture: amd64
+Version: 1.0
+
+Package: tzdata
+Status: install ok installed
+Architecture: all
+Version: 2024a-0+deb12u1
+`
+
+const apkInstalled = `C:Q1abc=
+P:musl
+V:1.2.4-r2
+A:x86_64
+S:383152
+
+C:Q1def=
+P:busybox
+V:1.36.1-r5
+A:x86_64
+`
+
+const procModules = `nf_conntrack 172032 2 nf_nat,xt_conntrack, Live 0x0000000000000000
+overlay 151552 12 - Live 0x0000000000000000
+broken line
+`
+
+// rpmHeader build rpm header blob with string and int32 tags.
+func rpmHeader(strs map[uint32]string, ints map[uint32]uint32) []byte {
+	var index, data bytes.Buffer
+
+	entry := func(tag, typ, off uint32) {
+		for _, v := range []uint32{tag, typ, off, 1} {
+			_ = binary.Write(&index, binary.BigEndian, v)
+		}
+	}
+
+	for tag, v := range ints {
+		entry(tag, rpmTypeInt32, uint32(data.Len()))
+		_ = binary.Write(&data, binary.BigEndian, v)
+	}
+
+	for tag, s := range strs {
+		entry(tag, rpmTypeString, uint32(data.Len()))
+		data.WriteString(s)
+		data.WriteByte(0)
+	}
+
+	var blob bytes.Buffer
+	_ = binary.Write(&blob, binary.BigEndian, uint32(len(strs)+len(ints)))
+	_ = binary.Write(&blob, binary.BigEndian, uint32(data.Len()))
+	blob.Write(index.Bytes())
+	blob.Write(data.Bytes())
+	return blob.Bytes()
+}
+
+// berkeleyDB build a little endian Berkeley DB hash database, value of the
+// first pair is stored in overflow pages, the second one inline.
+func berkeleyDB(offPage, inline []byte) []byte {
+	const pageSize = 512
+	le := binary.LittleEndian
+
+	pages := 1 + 1 + (len(offPage)+pageSize-bdbPageHeaderSize-1)/(pageSize-bdbPageHeaderSize)
+	db := make([]byte, pages*pageSize)
+
+	meta := db[:pageSize]
+	le.PutUint32(meta[12:], bdbHashMagic)
+	le.PutUint32(meta[20:], pageSize)
+	le.PutUint32(meta[32:], uint32(pages-1))
+
+	// Hash page: key1, offpage data1, key2, inline data2, stored from the end.
+	hash := db[pageSize : 2*pageSize]
+	hash[25] = bdbPageTypeHash
+	le.PutUint16(hash[20:], 4)
+
+	off := pageSize
+	put := func(idx int, item []byte) {
+		off -= len(item)
+		copy(hash[off:], item)
+		le.PutUint16(hash[bdbPageHeaderSize+idx*2:], uint16(off))
+	}
+
+	hoff := make([]byte, 12)
+	hoff[0] = bdbItemOffPage
+	le.PutUint32(hoff[4:], 2)
+	le.PutUint32(hoff[8:], uint32(len(offPage)))
+
+	put(0, []byte{bdbItemKeyData, 1, 0, 0, 0})
+	put(1, hoff)
+	put(2, []byte{bdbItemKeyData, 2, 0, 0, 0})
+	put(3, append([]byte{bdbItemKeyData}, inline...))
+
+	// Overflow pages.
+	rest := offPage
+	for pgno := 2; pgno < pages; pgno++ {
+		page := db[pgno*pageSize : (pgno+1)*pageSize]
+		page[25] = bdbPageTypeOverflow
+		n := copy(page[bdbPageHeaderSize:], rest)
+		rest = rest[n:]
+		le.PutUint16(page[22:], uint16(n))
+		if pgno+1 < pages {
+			le.PutUint32(page[16:], uint32(pgno+1))
+		}
+	}
+
+	return db
+}
+
+// ndb build rpm ndb database with one slot page, blobs are stored after the
+// slot page, with a free slot between them.
+func ndb(blobs ...[]byte) []byte {
+	le := binary.LittleEndian
+
+	db := make([]byte, ndbPageSize)
+	le.PutUint32(db[0:], ndbHeaderMagic)
+	le.PutUint32(db[12:], 1)
+
+	for off := ndbSlotStart * ndbSlotSize; off < ndbPageSize; off += ndbSlotSize {
+		le.PutUint32(db[off:], ndbSlotMagic)
+	}
+
+	slot := ndbSlotStart + 1
+	for i, blob := range blobs {
+		blkCnt := (ndbBlobHeadSize + len(blob) + ndbBlkSize - 1) / ndbBlkSize
+		blkOff := len(db) / ndbBlkSize
+
+		s := db[slot*ndbSlotSize:]
+		le.PutUint32(s[4:], uint32(i+1))
+		le.PutUint32(s[8:], uint32(blkOff))
+		le.PutUint32(s[12:], uint32(blkCnt))
+		slot += 2
+
+		head := make([]byte, ndbBlobHeadSize, blkCnt*ndbBlkSize)
+		le.PutUint32(head[0:], ndbBlobMagic)
+		le.PutUint32(head[4:], uint32(i+1))
+		le.PutUint32(head[12:], uint32(len(blob)))
+		head = append(head, blob...)
+		db = append(db, head[:cap(head)]...)
+	}
+
+	return db
+}
+
+func TestParsePackages(t *testing.T) {
+	t.Run("dpkg", func(t *testing.T) {
+		pkgs, err := parseDpkgStatus(strings.NewReader(dpkgStatus))
+		require.NoError(t, err)
+		require.Len(t, pkgs, 2)
+
+		assert.Equal(t, &invPackage{Name: "openssl", Version: "3.0.11-1~deb12u2", Arch: "amd64", Manager: pkgManagerDpkg}, pkgs[0])
+		assert.Equal(t, "tzdata", pkgs[1].Name)
+	})
+
+	t.Run("apk", func(t *testing.T) {
+		pkgs, err := parseApkInstalled(strings.NewReader(apkInstalled))
+		require.NoError(t, err)
+		require.Len(t, pkgs, 2)
+
+		assert.Equal(t, &invPackage{Name: "musl", Version: "1.2.4-r2", Arch: "x86_64", Manager: pkgManagerApk}, pkgs[0])
+		assert.Equal(t, "busybox", pkgs[1].Name)
+	})
+
+	t.Run("rpm-header", func(t *testing.T) {
+		pkg, err := parseRPMHeader(rpmHeader(map[uint32]string{
+			rpmTagName:    "openssl-libs",
+			rpmTagVersion: "3.0.7",
+			rpmTagRelease: "25.el9_3",
+			rpmTagArch:    "x86_64",
+		}, map[uint32]uint32{rpmTagEpoch: 1}))
+		require.NoError(t, err)
+		assert.Equal(t, &invPackage{Name: "openssl-libs", Version: "1:3.0.7-25.el9_3", Arch: "x86_64", Manager: pkgManagerRPM}, pkg)
+
+		_, err = parseRPMHeader([]byte{0, 0, 0, 9, 0, 0, 0, 0})
+		assert.Error(t, err)
+	})
+
+	t.Run("berkeley-db", func(t *testing.T) {
+		blob := rpmHeader(map[uint32]string{
+			rpmTagName:    "bash",
+			rpmTagVersion: "4.2.46",
+			rpmTagRelease: strings.Repeat("x", 1000), // spans multiple overflow pages
+		}, nil)
+
+		values, err := readBerkeleyDBValues(bytes.NewReader(berkeleyDB(blob, []byte("inline"))))
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, blob, values[0])
+		assert.Equal(t, []byte("inline"), values[1])
+
+		pkg, err := parseRPMHeader(values[0])
+		require.NoError(t, err)
+		assert.Equal(t, "bash", pkg.Name)
+
+		_, err = readBerkeleyDBValues(bytes.NewReader(make([]byte, 1024)))
+		assert.Error(t, err)
+	})
+
+	t.Run("ndb", func(t *testing.T) {
+		blob := rpmHeader(map[uint32]string{
+			rpmTagName:    "bash",
+			rpmTagVersion: "4.4",
+			rpmTagRelease: "150400.27.3.2",
+			rpmTagArch:    "x86_64",
+		}, nil)
+
+		values, err := readNDBValues(bytes.NewReader(ndb(blob, []byte("second"))))
+		require.NoError(t, err)
+		require.Len(t, values, 2)
+		assert.Equal(t, blob, values[0])
+		assert.Equal(t, []byte("second"), values[1])
+
+		_, err = readNDBValues(bytes.NewReader(make([]byte, ndbPageSize)))
+		assert.Error(t, err, "not ndb")
+
+		db := ndb(blob)
+		binary.LittleEndian.PutUint32(db[ndbPageSize+4:], 2) // pkgidx of blob mismatch
+		_, err = readNDBValues(bytes.NewReader(db))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseProcModules(t *testing.T) {
+	mods, err := parseProcModules(strings.NewReader(procModules))
+	require.NoError(t, err)
+	assert.Equal(t, []*invKernelModule{
+		{Name: "nf_conntrack", Size: 172032, State: "Live"},
+		{Name: "overlay", Size: 151552, State: "Live"},
+	}, mods)
+}
+
+func TestListenSockets(t *testing.T) {
+	defer func(f func(string) ([]netutil.ConnectionStat, error), g func(int32) (string, error)) {
+		netConnections, processName = f, g
+	}(netConnections, processName)
+
+	netConnections = func(string) ([]netutil.ConnectionStat, error) {
+		return []netutil.ConnectionStat{
+			{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Laddr: netutil.Addr{IP: "0.0.0.0", Port: 22}, Status: "LISTEN", Pid: 100},
+			{Family: syscall.AF_INET6, Type: syscall.SOCK_STREAM, Laddr: netutil.Addr{IP: "::", Port: 80}, Status: "LISTEN", Pid: 200},
+			{Family: syscall.AF_INET6, Type: syscall.SOCK_STREAM, Laddr: netutil.Addr{IP: "::", Port: 80}, Status: "LISTEN", Pid: 201},
+			{Family: syscall.AF_INET, Type: syscall.SOCK_STREAM, Laddr: netutil.Addr{IP: "10.0.0.1", Port: 22}, Raddr: netutil.Addr{IP: "10.0.0.2", Port: 50000}, Status: "ESTABLISHED", Pid: 100},
+			{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Laddr: netutil.Addr{IP: "127.0.0.1", Port: 323}, Pid: 300},
+			{Family: syscall.AF_INET, Type: syscall.SOCK_DGRAM, Laddr: netutil.Addr{IP: "10.0.0.1", Port: 40000}, Raddr: netutil.Addr{IP: "8.8.8.8", Port: 53}, Pid: 300},
+		}, nil
+	}
+
+	processName = func(pid int32) (string, error) {
+		return map[int32]string{100: "sshd", 200: "nginx", 300: "chronyd"}[pid], nil
+	}
+
+	socks, err := listenSockets()
+	require.NoError(t, err)
+	assert.Equal(t, []*invListenSocket{
+		{Protocol: "tcp", Address: "0.0.0.0", Port: 22, PID: 100, Process: "sshd"},
+		{Protocol: "tcp6", Address: "::", Port: 80, PID: 200, Process: "nginx"},
+		{Protocol: "udp", Address: "127.0.0.1", Port: 323, PID: 300, Process: "chronyd"},
+	}, socks)
+}
+
+func TestDiffInventory(t *testing.T) {
+	prev := &inventory{
+		Packages: []*invPackage{
+			{Name: "openssl", Version: "3.0.11", Arch: "amd64", Manager: pkgManagerDpkg},
+			{Name: "telnet", Version: "0.17", Arch: "amd64", Manager: pkgManagerDpkg},
+		},
+		KernelModules: []*invKernelModule{{Name: "overlay", Size: 1, State: "Live"}},
+		SystemdUnits:  []*invSystemdUnit{{Name: "ssh.service", ActiveState: "active", SubState: "running", UnitFileState: "enabled"}},
+		ListenSockets: []*invListenSocket{{Protocol: "tcp", Address: "0.0.0.0", Port: 22, PID: 100, Process: "sshd"}},
+	}
+
+	cur := &inventory{
+		Packages: []*invPackage{
+			{Name: "openssl", Version: "3.0.13", Arch: "amd64", Manager: pkgManagerDpkg},
+		},
+		KernelModules: []*invKernelModule{{Name: "overlay", Size: 2, State: "Live"}},
+		SystemdUnits:  []*invSystemdUnit{{Name: "ssh.service", ActiveState: "failed", SubState: "failed", UnitFileState: "enabled"}},
+		ListenSockets: []*invListenSocket{
+			{Protocol: "tcp", Address: "0.0.0.0", Port: 22, PID: 101, Process: "sshd"},
+			{Protocol: "tcp", Address: "0.0.0.0", Port: 4444, PID: 200, Process: "nc"},
+		},
+	}
+
+	t.Run("first", func(t *testing.T) {
+		assert.Empty(t, diffInventory(nil, cur))
+	})
+
+	t.Run("unchanged", func(t *testing.T) {
+		assert.Empty(t, diffInventory(prev, prev))
+	})
+
+	t.Run("changed", func(t *testing.T) {
+		changes := diffInventory(prev, cur)
+		assert.Equal(t, []*inventoryChange{
+			{Category: categoryListenSocket, Item: "tcp:0.0.0.0:4444", Change: changeAdded, New: "nc"},
+			{Category: categoryPackage, Item: "dpkg:openssl.amd64", Change: changeChanged, Old: "3.0.11", New: "3.0.13"},
+			{Category: categoryPackage, Item: "dpkg:telnet.amd64", Change: changeRemoved, Old: "0.17"},
+			{Category: categorySystemdUnit, Item: "ssh.service", Change: changeChanged, Old: "active/running enabled", New: "failed/failed enabled"},
+		}, changes)
+
+		assert.Equal(t, "package dpkg:openssl.amd64 changed: 3.0.11 -> 3.0.13", changes[1].message())
+
+		ipt := defaultInput()
+		ipt.mergedTags = map[string]string{"host": "test"}
+		pts := ipt.inventoryChangePoints(changes, time.Now())
+		require.Len(t, pts, 4)
+		assert.Equal(t, inventoryMeasurementName, pts[0].Name())
+		assert.Equal(t, "added", pts[0].Get("change"))
+		assert.Equal(t, "test", pts[0].Get("host"))
+	})
+}
+
+func TestInventoryPoints(t *testing.T) {
+	inv := &inventory{
+		Packages: []*invPackage{
+			{Name: "openssl", Version: "3.0.13", Arch: "amd64", Manager: pkgManagerDpkg},
+			{Name: "bash", Version: "5.2", Manager: pkgManagerApk},
+		},
+		KernelModules: []*invKernelModule{{Name: "overlay", Size: 151552, State: "Live"}},
+		SystemdUnits:  []*invSystemdUnit{{Name: "ssh.service", LoadState: "loaded", ActiveState: "active", SubState: "running"}},
+		ListenSockets: []*invListenSocket{{Protocol: "tcp", Address: "0.0.0.0", Port: 22, PID: 100, Process: "sshd"}},
+		managers:      []string{pkgManagerDpkg, pkgManagerApk},
+	}
+
+	ipt := defaultInput()
+	ipt.mergedTags = map[string]string{"host": "test"}
+
+	pt := ipt.inventoryPoint(inv, time.Now())
+	assert.Equal(t, inventoryMeasurementName, pt.Name())
+	assert.Equal(t, "dpkg,apk", pt.Get("package_managers"))
+	assert.Equal(t, int64(2), pt.Get("package_count"))
+	assert.Nil(t, pt.Get("packages"), "items not reported in summary")
+
+	pts := ipt.inventoryItemPoints(inv, time.Now())
+	require.Len(t, pts, 5)
+
+	items := map[string]*point.Point{}
+	for _, pt := range pts {
+		assert.Equal(t, inventoryItemMeasurementName, pt.Name())
+		assert.Equal(t, "test", pt.Get("host"))
+		items[pt.Get("category").(string)+"/"+pt.Get("item").(string)] = pt
+	}
+
+	pkg := items["package/dpkg:openssl.amd64"]
+	require.NotNil(t, pkg)
+	assert.Equal(t, "3.0.13", pkg.Get("version"))
+	assert.Equal(t, "openssl", pkg.Get("package_name"))
+	assert.Contains(t, pkg.Get("name"), ":package:dpkg:openssl.amd64")
+	assert.NotNil(t, items["package/apk:bash"])
+
+	assert.Equal(t, int64(151552), items["kernel_module/overlay"].Get("size"))
+	assert.Equal(t, "running", items["systemd_unit/ssh.service"].Get("sub_state"))
+	assert.Equal(t, "sshd", items["listen_socket/tcp:0.0.0.0:22"].Get("process"))
+	assert.Equal(t, int64(22), items["listen_socket/tcp:0.0.0.0:22"].Get("port"))
+}
@@ -44,3 +44,82 @@ func (*docMeasurement) Info() *inputs.MeasurementInfo {
 		},
 	}
 }
+
+type inventoryMeasurement struct{}
+
+//nolint:lll
+func (*inventoryMeasurement) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: inventoryMeasurementName,
+		Type: "custom_object",
+		Desc: "Software inventory of the host, enabled by `enable_inventory`",
+		Tags: map[string]interface{}{
+			"host":             &inputs.TagInfo{Desc: "Hostname"},
+			"name":             &inputs.TagInfo{Desc: "Hostname"},
+			"package_managers": &inputs.TagInfo{Desc: "Package managers found on the host, such as `dpkg,rpm,apk`"},
+		},
+		Fields: map[string]interface{}{
+			"package_count":       &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Int, Unit: inputs.NCount, Desc: "Number of installed packages"},
+			"kernel_module_count": &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Int, Unit: inputs.NCount, Desc: "Number of loaded kernel modules"},
+			"systemd_unit_count":  &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Int, Unit: inputs.NCount, Desc: "Number of loaded systemd service/socket/timer units"},
+			"listen_socket_count": &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Int, Unit: inputs.NCount, Desc: "Number of listening sockets"},
+		},
+	}
+}
+
+type inventoryItemMeasurement struct{}
+
+//nolint:lll
+func (*inventoryItemMeasurement) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: inventoryItemMeasurementName,
+		Type: "custom_object",
+		Desc: "Items of host inventory, one object for each package, kernel module, systemd unit or listening socket. Fields not applicable to the category are empty",
+		Tags: map[string]interface{}{
+			"host":     &inputs.TagInfo{Desc: "Hostname"},
+			"name":     &inputs.TagInfo{Desc: "Hostname, category and item, such as `host-1:package:dpkg:openssl.amd64`"},
+			"category": &inputs.TagInfo{Desc: "Category of the item, `package/kernel_module/systemd_unit/listen_socket`"},
+			"item":     &inputs.TagInfo{Desc: "Item name, such as `dpkg:openssl.amd64` or `tcp:0.0.0.0:22`"},
+		},
+		Fields: map[string]interface{}{
+			"package_name":    &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Package name"},
+			"version":         &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Package version"},
+			"arch":            &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Package architecture"},
+			"manager":         &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Package manager, `dpkg/rpm/apk`"},
+			"size":            &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.SizeByte, Desc: "Memory size of kernel module"},
+			"state":           &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Kernel module state, such as `Live`"},
+			"load_state":      &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "systemd unit load state"},
+			"active_state":    &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "systemd unit active state"},
+			"sub_state":       &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "systemd unit sub state"},
+			"unit_file_state": &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "systemd unit file state, such as `enabled`"},
+			"protocol":        &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Socket protocol, `tcp/tcp6/udp/udp6`"},
+			"address":         &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Socket listening address"},
+			"port":            &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "Socket listening port"},
+			"pid":             &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "PID of the process listening on the socket"},
+			"process":         &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Name of the process listening on the socket"},
+		},
+	}
+}
+
+type inventoryChangeMeasurement struct{}
+
+//nolint:lll
+func (*inventoryChangeMeasurement) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: inventoryMeasurementName,
+		Type: "logging",
+		Desc: "Changes of host inventory, one log for each item added, removed or changed since last inventory",
+		Tags: map[string]interface{}{
+			"host":     &inputs.TagInfo{Desc: "Hostname"},
+			"category": &inputs.TagInfo{Desc: "Category of the item, `package/kernel_module/systemd_unit/listen_socket`"},
+			"change":   &inputs.TagInfo{Desc: "Change of the item, `added/removed/changed`"},
+			"item":     &inputs.TagInfo{Desc: "Item name, such as `dpkg:openssl.amd64` or `tcp:0.0.0.0:22`"},
+		},
+		Fields: map[string]interface{}{
+			"message": &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Description of the change"},
+			"status":  &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Log status, always `info`"},
+			"old":     &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "State before change, such as package version, unit state or process name"},
+			"new":     &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "State after change"},
+		},
+	}
+}
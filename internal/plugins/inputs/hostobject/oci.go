@@ -0,0 +1,156 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"encoding/json"
+)
+
+// IMDSv2 of Oracle Cloud Infrastructure, see
+// https://docs.oracle.com/en-us/iaas/Content/Compute/Tasks/gettingmetadata.htm
+const ociMetaRootURL = "http://169.254.169.254/opc/v2"
+
+type oci struct {
+	baseURL string
+
+	meta    *ociMetaData
+	fetched bool
+}
+
+type ociMetaData struct {
+	ID                  string `json:"id"`
+	DisplayName         string `json:"displayName"`
+	Shape               string `json:"shape"`
+	Region              string `json:"region"`              // short region name, such as iad
+	CanonicalRegionName string `json:"canonicalRegionName"` // such as us-ashburn-1
+	AvailabilityDomain  string `json:"availabilityDomain"`
+	State               string `json:"state"`
+}
+
+type ociVnic struct {
+	PrivateIP string `json:"privateIp"`
+}
+
+func (x *oci) Sync() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"cloud_provider":        OCI,
+		"description":           x.Description(),
+		"instance_id":           x.InstanceID(),
+		"instance_name":         x.InstanceName(),
+		"instance_type":         x.InstanceType(),
+		"instance_charge_type":  x.InstanceChargeType(),
+		"instance_network_type": x.InstanceNetworkType(),
+		"instance_status":       x.InstanceStatus(),
+		"security_group_id":     x.SecurityGroupID(),
+		"private_ip":            x.PrivateIP(),
+		"zone_id":               x.ZoneID(),
+		"region":                x.Region(),
+	}, nil
+}
+
+func ociGet(metaURL string) []byte {
+	return metadataGetWithHeader(metaURL, "Authorization", "Bearer Oracle") // required by IMDSv2
+}
+
+func (x *oci) getOCIMetaData() *ociMetaData {
+	if x.fetched {
+		return x.meta
+	}
+	x.fetched = true
+
+	resp := ociGet(x.baseURL + "/instance/")
+	if resp == nil {
+		return nil
+	}
+
+	model := &ociMetaData{}
+	if err := json.Unmarshal(resp, model); err != nil {
+		l.Warnf("marshal json failed: %s", err)
+		return nil
+	}
+
+	x.meta = model
+	return x.meta
+}
+
+func (x *oci) Description() string {
+	return Unavailable
+}
+
+func (x *oci) InstanceID() string {
+	if m := x.getOCIMetaData(); m != nil {
+		return orUnavailable(m.ID)
+	}
+	return Unavailable
+}
+
+func (x *oci) InstanceName() string {
+	if m := x.getOCIMetaData(); m != nil {
+		return orUnavailable(m.DisplayName)
+	}
+	return Unavailable
+}
+
+func (x *oci) InstanceType() string {
+	if m := x.getOCIMetaData(); m != nil {
+		return orUnavailable(m.Shape)
+	}
+	return Unavailable
+}
+
+func (x *oci) InstanceChargeType() string {
+	return Unavailable
+}
+
+func (x *oci) InstanceNetworkType() string {
+	return Unavailable
+}
+
+func (x *oci) InstanceStatus() string {
+	if m := x.getOCIMetaData(); m != nil {
+		return orUnavailable(m.State)
+	}
+	return Unavailable
+}
+
+func (x *oci) SecurityGroupID() string {
+	return Unavailable
+}
+
+func (x *oci) PrivateIP() string {
+	resp := ociGet(x.baseURL + "/vnics/")
+	if resp == nil {
+		return Unavailable
+	}
+
+	var vnics []*ociVnic
+	if err := json.Unmarshal(resp, &vnics); err != nil {
+		l.Warnf("marshal json failed: %s", err)
+		return Unavailable
+	}
+
+	if len(vnics) == 0 {
+		return Unavailable
+	}
+	return orUnavailable(vnics[0].PrivateIP)
+}
+
+func (x *oci) ZoneID() string {
+	if m := x.getOCIMetaData(); m != nil {
+		return orUnavailable(m.AvailabilityDomain)
+	}
+	return Unavailable
+}
+
+func (x *oci) Region() string {
+	if m := x.getOCIMetaData(); m != nil {
+		if m.CanonicalRegionName != "" {
+			return m.CanonicalRegionName
+		}
+		return orUnavailable(m.Region)
+	}
+	return Unavailable
+}
@@ -0,0 +1,94 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	ociInstanceData = `{
+  "availabilityDomain": "EMIr:US-ASHBURN-AD-1",
+  "faultDomain": "FAULT-DOMAIN-2",
+  "compartmentId": "ocid1.tenancy.oc1..aaaaaaaa",
+  "displayName": "oci-test",
+  "hostname": "oci-test",
+  "id": "ocid1.instance.oc1.iad.anuwcljt",
+  "image": "ocid1.image.oc1.iad.aaaaaaaa",
+  "region": "iad",
+  "canonicalRegionName": "us-ashburn-1",
+  "ociAdName": "iad-ad-1",
+  "shape": "VM.Standard.E4.Flex",
+  "state": "Running",
+  "timeCreated": 1600381928581
+}`
+
+	ociVnicsData = `[
+  {
+    "vnicId": "ocid1.vnic.oc1.iad.abuwcljt",
+    "privateIp": "10.0.3.6",
+    "vlanTag": 11,
+    "macAddr": "02:00:17:05:d1:db",
+    "virtualRouterIp": "10.0.3.1",
+    "subnetCidrBlock": "10.0.3.0/24"
+  }
+]`
+)
+
+func TestOCI(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer Oracle" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/opc/v2/instance/":
+			fmt.Fprint(w, ociInstanceData)
+		case "/opc/v2/vnics/":
+			fmt.Fprint(w, ociVnicsData)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	info, err := (&oci{baseURL: ts.URL + "/opc/v2"}).Sync()
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"cloud_provider":        "oci",
+		"description":           Unavailable,
+		"instance_id":           "ocid1.instance.oc1.iad.anuwcljt",
+		"instance_name":         "oci-test",
+		"instance_type":         "VM.Standard.E4.Flex",
+		"instance_charge_type":  Unavailable,
+		"instance_network_type": Unavailable,
+		"instance_status":       "Running",
+		"security_group_id":     Unavailable,
+		"private_ip":            "10.0.3.6",
+		"zone_id":               "EMIr:US-ASHBURN-AD-1",
+		"region":                "us-ashburn-1",
+	}, info)
+
+	t.Run("imds-v1-only", func(t *testing.T) {
+		info, err := (&oci{baseURL: ts.URL + "/opc/v1"}).Sync()
+		require.NoError(t, err)
+		assert.Equal(t, Unavailable, info["instance_id"])
+	})
+
+	t.Run("detect", func(t *testing.T) {
+		ipt := defaultInput()
+		ipt.CloudMetaURL = map[string]string{OCI: ts.URL + "/opc/v2"}
+		assert.True(t, ipt.matchCloudProvider(OCI))
+	})
+}
@@ -0,0 +1,208 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	openstackMetaRootURL = "http://169.254.169.254"
+	openstackMetaPath    = "openstack/latest"
+	fileURLPrefix        = "file://"
+)
+
+// openstackConfigDrive is where the config drive(labeled config-2) mounted.
+var openstackConfigDrive = "/mnt/config"
+
+// openstack read metadata from config drive(baseURL is file://<mount-dir>) or
+// metadata service(baseURL is http://169.254.169.254).
+type openstack struct {
+	baseURL string
+
+	meta    *openstackMetaData
+	fetched bool
+}
+
+type openstackMetaData struct {
+	UUID             string `json:"uuid"`
+	Name             string `json:"name"`
+	AvailabilityZone string `json:"availability_zone"`
+	InstanceType     string `json:"instance_type"` // not available on all deployments
+	Region           string `json:"region_id"`     // not available on all deployments
+}
+
+type openstackNetworkData struct {
+	Networks []struct {
+		Type      string `json:"type"`
+		IPAddress string `json:"ip_address"` // only for static networks
+	} `json:"networks"`
+	Links []struct {
+		PrivateIP string `json:"local_ipv4"`
+	} `json:"links"`
+}
+
+// openstackDefaultURL prefer config drive if it's mounted.
+func openstackDefaultURL() string {
+	if _, err := os.Stat(filepath.Join(openstackConfigDrive, openstackMetaPath, "meta_data.json")); err == nil {
+		return fileURLPrefix + openstackConfigDrive
+	}
+	return openstackMetaRootURL
+}
+
+func (x *openstack) Sync() (map[string]interface{}, error) {
+	return map[string]interface{}{
+		"cloud_provider":        OpenStack,
+		"description":           x.Description(),
+		"instance_id":           x.InstanceID(),
+		"instance_name":         x.InstanceName(),
+		"instance_type":         x.InstanceType(),
+		"instance_charge_type":  x.InstanceChargeType(),
+		"instance_network_type": x.InstanceNetworkType(),
+		"instance_status":       x.InstanceStatus(),
+		"security_group_id":     x.SecurityGroupID(),
+		"private_ip":            x.PrivateIP(),
+		"zone_id":               x.ZoneID(),
+		"region":                x.Region(),
+	}, nil
+}
+
+func (x *openstack) get(name string) []byte {
+	if strings.HasPrefix(x.baseURL, fileURLPrefix) {
+		data, err := os.ReadFile(filepath.Join(strings.TrimPrefix(x.baseURL, fileURLPrefix), openstackMetaPath, name))
+		if err != nil {
+			l.Warnf("read config drive: %s", err)
+			return nil
+		}
+		return data
+	}
+
+	return metadataGet(x.baseURL + "/" + openstackMetaPath + "/" + name)
+}
+
+func (x *openstack) getOpenStackMetaData() *openstackMetaData {
+	if x.fetched {
+		return x.meta
+	}
+	x.fetched = true
+
+	resp := x.get("meta_data.json")
+	if resp == nil {
+		return nil
+	}
+
+	model := &openstackMetaData{}
+	if err := json.Unmarshal(resp, model); err != nil {
+		l.Warnf("marshal json failed: %s", err)
+		return nil
+	}
+
+	x.meta = model
+	return x.meta
+}
+
+func (x *openstack) getNetworkData() *openstackNetworkData {
+	resp := x.get("network_data.json")
+	if resp == nil {
+		return nil
+	}
+
+	model := &openstackNetworkData{}
+	if err := json.Unmarshal(resp, model); err != nil {
+		l.Warnf("marshal json failed: %s", err)
+		return nil
+	}
+	return model
+}
+
+func (x *openstack) Description() string {
+	return Unavailable
+}
+
+func (x *openstack) InstanceID() string {
+	if m := x.getOpenStackMetaData(); m != nil {
+		return orUnavailable(m.UUID)
+	}
+	return Unavailable
+}
+
+func (x *openstack) InstanceName() string {
+	if m := x.getOpenStackMetaData(); m != nil {
+		return orUnavailable(m.Name)
+	}
+	return Unavailable
+}
+
+func (x *openstack) InstanceType() string {
+	if m := x.getOpenStackMetaData(); m != nil {
+		return orUnavailable(m.InstanceType)
+	}
+	return Unavailable
+}
+
+func (x *openstack) InstanceChargeType() string {
+	return Unavailable
+}
+
+func (x *openstack) InstanceNetworkType() string {
+	nd := x.getNetworkData()
+	if nd == nil {
+		return Unavailable
+	}
+
+	var types []string
+	for _, n := range nd.Networks {
+		types = append(types, n.Type)
+	}
+	return orUnavailable(strings.Join(types, " "))
+}
+
+func (x *openstack) InstanceStatus() string {
+	return Unavailable
+}
+
+func (x *openstack) SecurityGroupID() string {
+	return Unavailable
+}
+
+func (x *openstack) PrivateIP() string {
+	nd := x.getNetworkData()
+	if nd == nil {
+		return Unavailable
+	}
+
+	var ips []string
+	for _, n := range nd.Networks {
+		if n.IPAddress != "" {
+			ips = append(ips, n.IPAddress)
+		}
+	}
+
+	for _, link := range nd.Links {
+		if link.PrivateIP != "" {
+			ips = append(ips, link.PrivateIP)
+		}
+	}
+
+	return orUnavailable(strings.Join(ips, " "))
+}
+
+func (x *openstack) ZoneID() string {
+	if m := x.getOpenStackMetaData(); m != nil {
+		return orUnavailable(m.AvailabilityZone)
+	}
+	return Unavailable
+}
+
+func (x *openstack) Region() string {
+	if m := x.getOpenStackMetaData(); m != nil {
+		return orUnavailable(m.Region)
+	}
+	return Unavailable
+}
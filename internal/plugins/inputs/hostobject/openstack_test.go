@@ -0,0 +1,126 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	openstackMetaJSON = `{
+  "uuid": "d8e02d56-2648-49a3-bf97-6be8f1204f38",
+  "availability_zone": "nova",
+  "hostname": "openstack-test.novalocal",
+  "launch_index": 0,
+  "meta": {"role": "web"},
+  "name": "openstack-test",
+  "project_id": "f7ac731cc11f40efbc03a9f9e1d1d21f"
+}`
+
+	openstackNetworkJSON = `{
+  "links": [
+    {
+      "ethernet_mac_address": "fa:16:3e:9c:bf:3d",
+      "id": "tapcd9f6d46-4a",
+      "mtu": null,
+      "type": "bridge",
+      "vif_id": "cd9f6d46-4a3a-43ab-a466-994af9db96fc"
+    }
+  ],
+  "networks": [
+    {
+      "id": "network0",
+      "link": "tapcd9f6d46-4a",
+      "network_id": "99e88329-f20d-4741-9593-25bf07847b16",
+      "type": "ipv4",
+      "ip_address": "10.0.0.5",
+      "netmask": "255.255.255.0"
+    },
+    {
+      "id": "network1",
+      "link": "tapcd9f6d46-4a",
+      "network_id": "99e88329-f20d-4741-9593-25bf07847b16",
+      "type": "ipv6_dhcp"
+    }
+  ],
+  "services": []
+}`
+)
+
+func checkOpenStackInfo(t *testing.T, info map[string]interface{}) {
+	t.Helper()
+
+	assert.Equal(t, map[string]interface{}{
+		"cloud_provider":        "openstack",
+		"description":           Unavailable,
+		"instance_id":           "d8e02d56-2648-49a3-bf97-6be8f1204f38",
+		"instance_name":         "openstack-test",
+		"instance_type":         Unavailable,
+		"instance_charge_type":  Unavailable,
+		"instance_network_type": "ipv4 ipv6_dhcp",
+		"instance_status":       Unavailable,
+		"security_group_id":     Unavailable,
+		"private_ip":            "10.0.0.5",
+		"zone_id":               "nova",
+		"region":                Unavailable,
+	}, info)
+}
+
+func TestOpenStackMetadataService(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/openstack/latest/meta_data.json":
+			fmt.Fprint(w, openstackMetaJSON)
+		case "/openstack/latest/network_data.json":
+			fmt.Fprint(w, openstackNetworkJSON)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer ts.Close()
+
+	info, err := (&openstack{baseURL: ts.URL}).Sync()
+	require.NoError(t, err)
+	checkOpenStackInfo(t, info)
+
+	ipt := defaultInput()
+	ipt.CloudMetaURL = map[string]string{OpenStack: ts.URL}
+	assert.True(t, ipt.matchCloudProvider(OpenStack))
+}
+
+func TestOpenStackConfigDrive(t *testing.T) {
+	dir := t.TempDir()
+
+	old := openstackConfigDrive
+	openstackConfigDrive = dir
+	defer func() { openstackConfigDrive = old }()
+
+	assert.Equal(t, openstackMetaRootURL, openstackDefaultURL(), "config drive not mounted")
+
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, openstackMetaPath), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, openstackMetaPath, "meta_data.json"), []byte(openstackMetaJSON), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, openstackMetaPath, "network_data.json"), []byte(openstackNetworkJSON), 0o600))
+
+	assert.Equal(t, "file://"+dir, openstackDefaultURL())
+
+	info, err := (&openstack{baseURL: openstackDefaultURL()}).Sync()
+	require.NoError(t, err)
+	checkOpenStackInfo(t, info)
+
+	t.Run("not-openstack", func(t *testing.T) {
+		info, err := (&openstack{baseURL: "file://" + t.TempDir()}).Sync()
+		require.NoError(t, err)
+		assert.Equal(t, Unavailable, info["instance_id"])
+	})
+}
@@ -0,0 +1,321 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package hostobject
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// RPM header tags and types, see rpmtag.h.
+const (
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagArch    = 1022
+
+	rpmTypeInt32  = 4
+	rpmTypeString = 6
+
+	rpmHeaderMaxEntries = 0xffff
+	rpmHeaderMaxData    = 256 * 1024 * 1024
+)
+
+var errRPMHeader = errors.New("invalid rpm header")
+
+// parseRPMHeader parse package in rpm header blob stored in rpmdb, the blob is
+// index count(int32), data length(int32), index entries(16 bytes each) and data.
+func parseRPMHeader(blob []byte) (*invPackage, error) {
+	if len(blob) < 8 {
+		return nil, errRPMHeader
+	}
+
+	il := binary.BigEndian.Uint32(blob[0:4])
+	dl := binary.BigEndian.Uint32(blob[4:8])
+	if il > rpmHeaderMaxEntries || dl > rpmHeaderMaxData {
+		return nil, errRPMHeader
+	}
+
+	dataStart := 8 + int(il)*16
+	if len(blob) < dataStart+int(dl) {
+		return nil, errRPMHeader
+	}
+
+	data := blob[dataStart : dataStart+int(dl)]
+
+	str := func(off uint32) string {
+		if int(off) >= len(data) {
+			return ""
+		}
+		if i := bytes.IndexByte(data[off:], 0); i >= 0 {
+			return string(data[off : int(off)+i])
+		}
+		return ""
+	}
+
+	var (
+		pkg      = &invPackage{Manager: pkgManagerRPM}
+		release  string
+		epoch    uint32
+		hasEpoch bool
+	)
+
+	for i := 0; i < int(il); i++ {
+		entry := blob[8+i*16 : 8+(i+1)*16]
+		tag := binary.BigEndian.Uint32(entry[0:4])
+		typ := binary.BigEndian.Uint32(entry[4:8])
+		offset := binary.BigEndian.Uint32(entry[8:12])
+
+		switch {
+		case typ == rpmTypeString && tag == rpmTagName:
+			pkg.Name = str(offset)
+		case typ == rpmTypeString && tag == rpmTagVersion:
+			pkg.Version = str(offset)
+		case typ == rpmTypeString && tag == rpmTagRelease:
+			release = str(offset)
+		case typ == rpmTypeString && tag == rpmTagArch:
+			pkg.Arch = str(offset)
+		case typ == rpmTypeInt32 && tag == rpmTagEpoch:
+			if int(offset)+4 <= len(data) {
+				epoch, hasEpoch = binary.BigEndian.Uint32(data[offset:offset+4]), true
+			}
+		}
+	}
+
+	if pkg.Name == "" {
+		return nil, errRPMHeader
+	}
+
+	if release != "" {
+		pkg.Version += "-" + release
+	}
+
+	if hasEpoch && epoch > 0 {
+		pkg.Version = fmt.Sprintf("%d:%s", epoch, pkg.Version)
+	}
+
+	return pkg, nil
+}
+
+// Berkeley DB hash database layout, see dbinc/db_page.h.
+const (
+	bdbHashMagic = 0x061561
+
+	bdbPageHeaderSize = 26
+
+	bdbPageTypeHashUnsorted = 2
+	bdbPageTypeOverflow     = 7
+	bdbPageTypeHash         = 13
+
+	bdbItemKeyData = 1
+	bdbItemOffPage = 3
+)
+
+// readBerkeleyDBValues returns values of all key/data pairs in Berkeley DB
+// hash database, such as rpmdb Packages before rpm 4.16.
+func readBerkeleyDBValues(r io.ReaderAt) ([][]byte, error) {
+	meta := make([]byte, 512)
+	if _, err := r.ReadAt(meta, 0); err != nil {
+		return nil, fmt.Errorf("read metadata: %w", err)
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case binary.LittleEndian.Uint32(meta[12:16]) == bdbHashMagic:
+		order = binary.LittleEndian
+	case binary.BigEndian.Uint32(meta[12:16]) == bdbHashMagic:
+		order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("not a Berkeley DB hash database")
+	}
+
+	if meta[24] != 0 {
+		return nil, fmt.Errorf("encrypted Berkeley DB not supported")
+	}
+
+	pageSize := order.Uint32(meta[20:24])
+	lastPage := order.Uint32(meta[32:36])
+	if pageSize < 512 || pageSize > 64*1024 {
+		return nil, fmt.Errorf("invalid page size %d", pageSize)
+	}
+
+	readPage := func(pgno uint32) ([]byte, error) {
+		page := make([]byte, pageSize)
+		if _, err := r.ReadAt(page, int64(pgno)*int64(pageSize)); err != nil {
+			return nil, fmt.Errorf("read page %d: %w", pgno, err)
+		}
+		return page, nil
+	}
+
+	// readOverflow concat data of overflow pages chain.
+	readOverflow := func(pgno, length uint32) ([]byte, error) {
+		var res []byte
+		for n := uint32(0); pgno != 0 && n <= lastPage; n++ {
+			page, err := readPage(pgno)
+			if err != nil {
+				return nil, err
+			}
+
+			if page[25] != bdbPageTypeOverflow {
+				return nil, fmt.Errorf("page %d is not overflow page", pgno)
+			}
+
+			// hf_offset of overflow page is the length of data on the page.
+			end := bdbPageHeaderSize + uint32(order.Uint16(page[22:24]))
+			if end > pageSize {
+				return nil, fmt.Errorf("invalid overflow page %d", pgno)
+			}
+
+			res = append(res, page[bdbPageHeaderSize:end]...)
+			pgno = order.Uint32(page[16:20])
+		}
+
+		if uint32(len(res)) < length {
+			return nil, fmt.Errorf("overflow data truncated")
+		}
+		return res[:length], nil
+	}
+
+	var values [][]byte
+	for pgno := uint32(1); pgno <= lastPage; pgno++ {
+		page, err := readPage(pgno)
+		if err != nil {
+			return nil, err
+		}
+
+		if page[25] != bdbPageTypeHash && page[25] != bdbPageTypeHashUnsorted {
+			continue
+		}
+
+		entries := int(order.Uint16(page[20:22]))
+		if bdbPageHeaderSize+entries*2 > int(pageSize) {
+			continue
+		}
+
+		offsets := make([]uint32, entries)
+		for i := range offsets {
+			offsets[i] = uint32(order.Uint16(page[bdbPageHeaderSize+i*2:]))
+		}
+
+		// Items are key/data pairs, data items are at odd indexes.
+		for i := 1; i < entries; i += 2 {
+			off := offsets[i]
+			if off >= pageSize {
+				continue
+			}
+
+			switch page[off] {
+			case bdbItemOffPage:
+				if off+12 > pageSize {
+					continue
+				}
+
+				v, err := readOverflow(order.Uint32(page[off+4:off+8]), order.Uint32(page[off+8:off+12]))
+				if err != nil {
+					return nil, err
+				}
+				values = append(values, v)
+
+			case bdbItemKeyData:
+				// Items are stored from end of the page, the previous item ends
+				// this one.
+				end := offsets[i-1]
+				if end <= off || end > pageSize {
+					continue
+				}
+				values = append(values, append([]byte(nil), page[off+1:end]...))
+			}
+		}
+	}
+
+	return values, nil
+}
+
+// rpm ndb database layout, see lib/backend/ndb/rpmpkg.c.
+const (
+	ndbHeaderMagic = 'R' | 'p'<<8 | 'm'<<16 | 'P'<<24
+	ndbSlotMagic   = 'S' | 'l'<<8 | 'o'<<16 | 't'<<24
+	ndbBlobMagic   = 'B' | 'l'<<8 | 'b'<<16 | 'S'<<24
+
+	ndbVersion      = 0
+	ndbPageSize     = 4096
+	ndbSlotSize     = 16
+	ndbSlotStart    = 2 // the first 2 slots are the file header
+	ndbBlkSize      = 16
+	ndbBlobHeadSize = 16
+
+	ndbMaxSlotPages = 4096
+)
+
+// readNDBValues returns package blobs in rpm ndb database, such as
+// Packages.db on SUSE. The file starts with slot pages, each slot locates a
+// package blob by block offset and block count.
+func readNDBValues(r io.ReaderAt) ([][]byte, error) {
+	le := binary.LittleEndian
+
+	hdr := make([]byte, ndbSlotStart*ndbSlotSize)
+	if _, err := r.ReadAt(hdr, 0); err != nil {
+		return nil, fmt.Errorf("read header: %w", err)
+	}
+
+	if le.Uint32(hdr[0:4]) != ndbHeaderMagic {
+		return nil, fmt.Errorf("not a rpm ndb database")
+	}
+
+	if v := le.Uint32(hdr[4:8]); v != ndbVersion {
+		return nil, fmt.Errorf("unsupported ndb version %d", v)
+	}
+
+	slotPages := le.Uint32(hdr[12:16])
+	if slotPages == 0 || slotPages > ndbMaxSlotPages {
+		return nil, fmt.Errorf("invalid slot pages %d", slotPages)
+	}
+
+	slots := make([]byte, slotPages*ndbPageSize)
+	if _, err := r.ReadAt(slots, 0); err != nil {
+		return nil, fmt.Errorf("read slots: %w", err)
+	}
+
+	var values [][]byte
+	for off := ndbSlotStart * ndbSlotSize; off+ndbSlotSize <= len(slots); off += ndbSlotSize {
+		slot := slots[off : off+ndbSlotSize]
+		if le.Uint32(slot[0:4]) != ndbSlotMagic {
+			return nil, fmt.Errorf("invalid slot at %d", off)
+		}
+
+		pkgIdx, blkOff, blkCnt := le.Uint32(slot[4:8]), le.Uint32(slot[8:12]), le.Uint32(slot[12:16])
+		if pkgIdx == 0 || blkOff == 0 { // free slot
+			continue
+		}
+
+		head := make([]byte, ndbBlobHeadSize)
+		if _, err := r.ReadAt(head, int64(blkOff)*ndbBlkSize); err != nil {
+			return nil, fmt.Errorf("read blob of package %d: %w", pkgIdx, err)
+		}
+
+		if le.Uint32(head[0:4]) != ndbBlobMagic || le.Uint32(head[4:8]) != pkgIdx {
+			return nil, fmt.Errorf("invalid blob of package %d", pkgIdx)
+		}
+
+		blobLen := le.Uint32(head[12:16])
+		if uint64(blobLen)+ndbBlobHeadSize > uint64(blkCnt)*ndbBlkSize || blobLen > rpmHeaderMaxData {
+			return nil, fmt.Errorf("invalid blob length %d of package %d", blobLen, pkgIdx)
+		}
+
+		blob := make([]byte, blobLen)
+		if _, err := r.ReadAt(blob, int64(blkOff)*ndbBlkSize+ndbBlobHeadSize); err != nil {
+			return nil, fmt.Errorf("read blob of package %d: %w", pkgIdx, err)
+		}
+
+		values = append(values, blob)
+	}
+
+	return values, nil
+}
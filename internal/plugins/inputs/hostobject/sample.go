@@ -41,6 +41,11 @@ ignore_zero_bytes_disk = true
 ## Disable cloud provider information synchronization
 disable_cloud_provider_sync = false
 
+## Collect installed packages(dpkg/rpm/apk), kernel modules, systemd units and listening
+## sockets as host_inventory object, and report changes between inventories as logging.
+# enable_inventory = false
+# inventory_interval = "1h"
+
 ## Enable put cloud provider region/zone_id information into global election tags, (default to true).
 # enable_cloud_host_tags_as_global_election_tags = true
 
@@ -48,7 +53,7 @@ disable_cloud_provider_sync = false
 # enable_cloud_host_tags_as_global_host_tags = true
 
 ## [inputs.hostobject.tags] # (optional) custom tags
-  # cloud_provider = "aliyun" # aliyun/tencent/aws/hwcloud/azure/volcengine, probe automatically if not set
+  # cloud_provider = "aliyun" # aliyun/tencent/aws/hwcloud/azure/volcengine/gcp/oci/openstack, probe automatically if not set
   # some_tag = "some_value"
   # more_tag = "some_other_value"
   # ...
@@ -60,5 +65,8 @@ disable_cloud_provider_sync = false
   # azure = ""
   # Hwcloud = ""
   # volcengine = ""
+  # gcp = ""
+  # oci = ""
+  # openstack = "" # "file:///mnt/config" for config drive, default to the mounted config drive or metadata service
 `
 )
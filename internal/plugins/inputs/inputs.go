@@ -16,6 +16,7 @@ import (
 	"net/url"
 	"os"
 	"reflect"
+	"runtime/pprof"
 	"strings"
 	"sync"
 	"time"
@@ -23,6 +24,7 @@ import (
 	"github.com/GuanceCloud/cliutils/logger"
 	"github.com/GuanceCloud/cliutils/system/rtpanic"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/goroutine"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/tailer"
 )
@@ -487,7 +489,10 @@ func RunInput(name string, ii *InputInfo) {
 			case <-tick.C:
 				l.Infof("starting input %s ...", name)
 
-				protectRunningInput(name, ii)
+				// label goroutines of the input for resource accounting
+				pprof.Do(ctx, pprof.Labels(goroutine.LabelInput, name), func(context.Context) {
+					protectRunningInput(name, ii)
+				})
 
 				l.Infof("input %s exited, this maybe a input that only register a HTTP handle", name)
 				return nil
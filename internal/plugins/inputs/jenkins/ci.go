@@ -85,6 +85,7 @@ func (ipt *Input) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 	if err := ipt.feeder.FeedV2(point.Logging, pts,
 		dkio.WithElection(ipt.Election),
 		dkio.WithInputName("jenkins_ci"),
+		dkio.WithRequestHandler(true),
 	); err != nil {
 		ipt.feeder.FeedLastError(err.Error(),
 			metrics.WithLastErrorInput(inputName),
@@ -226,5 +226,6 @@ func (ipt *Input) processLogBody(param *parameters) error {
 
 	return ipt.feeder.FeedV2(point.Logging, pts,
 		dkio.WithInputName(inputName+"/"+source),
-		dkio.WithPipelineOption(plopt))
+		dkio.WithPipelineOption(plopt),
+		dkio.WithRequestHandler(true))
 }
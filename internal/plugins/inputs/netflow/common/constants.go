@@ -33,6 +33,12 @@ const (
 
 	// DefaultPrometheusListenerAddress is the default goflow prometheus listener address.
 	DefaultPrometheusListenerAddress = "localhost:9090"
+
+	// DefaultEnrichmentCacheSize is the default max IPs cached by GeoIP enrichment.
+	DefaultEnrichmentCacheSize = 10000
+
+	// DefaultEnrichmentCacheTTL is the default TTL in seconds of GeoIP enrichment cache.
+	DefaultEnrichmentCacheTTL = 3600 // 1h
 )
 
 ////////////////////////////////////////////////////////////////////////////////
@@ -9,6 +9,7 @@ package config
 
 import (
 	"fmt"
+	"time"
 
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/dkstring"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/common"
@@ -29,6 +30,18 @@ type NetflowConfig struct {
 
 	PrometheusListenerAddress string // Example `localhost:9090`
 	PrometheusListenerEnabled bool
+
+	Enrichment *EnrichmentConfig
+}
+
+// EnrichmentConfig contains configuration of GeoIP and SNMP interface enrichment.
+type EnrichmentConfig struct {
+	GeoIP         bool          `toml:"geoip"`
+	GeoIPFields   []string      `toml:"geoip_fields"` // country/province/city/isp/asn/asn_org
+	ASNFile       string        `toml:"asn_file"`     // MaxMind GeoLite2-ASN database
+	SNMPInterface bool          `toml:"snmp_interface"`
+	CacheSize     int           `toml:"cache_size"`
+	CacheTTL      time.Duration `toml:"cache_ttl"`
 }
 
 // ListenerConfig contains configuration for a single flow listener.
@@ -43,7 +56,7 @@ type ListenerConfig struct {
 // ReadConfig builds and returns configuration from Agent configuration.
 //
 //nolint:lll
-func ReadConfig(flows []*common.FlowOpt, namespace string) (*NetflowConfig, error) {
+func ReadConfig(flows []*common.FlowOpt, namespace string, enrichment *EnrichmentConfig) (*NetflowConfig, error) {
 	var mainConfig NetflowConfig
 
 	for _, flow := range flows {
@@ -109,6 +122,17 @@ func ReadConfig(flows []*common.FlowOpt, namespace string) (*NetflowConfig, erro
 		mainConfig.PrometheusListenerAddress = common.DefaultPrometheusListenerAddress
 	}
 
+	if enrichment != nil {
+		e := *enrichment
+		if e.CacheSize <= 0 {
+			e.CacheSize = common.DefaultEnrichmentCacheSize
+		}
+		if e.CacheTTL <= 0 {
+			e.CacheTTL = common.DefaultEnrichmentCacheTTL * time.Second
+		}
+		mainConfig.Enrichment = &e
+	}
+
 	return &mainConfig, nil
 }
 
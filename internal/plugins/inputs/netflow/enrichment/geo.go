@@ -0,0 +1,210 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package enrichment
+
+import (
+	"fmt"
+	"net"
+	"sync/atomic"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/pipeline/ptinput/ipdb"
+	"github.com/hashicorp/golang-lru/v2/expirable"
+	"github.com/oschwald/geoip2-golang"
+)
+
+// GeoIP fields could be enriched.
+const (
+	GeoFieldCountry  = "country"
+	GeoFieldProvince = "province"
+	GeoFieldCity     = "city"
+	GeoFieldISP      = "isp"
+	GeoFieldASN      = "asn"
+	GeoFieldASNOrg   = "asn_org"
+
+	geoUnknown = "unknown"
+)
+
+// DefaultGeoFields are enriched if no fields configured.
+var DefaultGeoFields = []string{GeoFieldCountry, GeoFieldProvince, GeoFieldCity, GeoFieldASN}
+
+var validGeoFields = map[string]bool{
+	GeoFieldCountry:  true,
+	GeoFieldProvince: true,
+	GeoFieldCity:     true,
+	GeoFieldISP:      true,
+	GeoFieldASN:      true,
+	GeoFieldASNOrg:   true,
+}
+
+// Geo contains location and autonomous system of an IP.
+type Geo struct {
+	Country  string `json:"country,omitempty"`
+	Province string `json:"province,omitempty"`
+	City     string `json:"city,omitempty"`
+	ISP      string `json:"isp,omitempty"`
+	ASN      uint   `json:"asn,omitempty"`
+	ASNOrg   string `json:"asn_org,omitempty"`
+}
+
+// Fields returns non-empty fields of geo with prefix, such as source_country.
+func (g *Geo) Fields(prefix string) map[string]interface{} {
+	res := map[string]interface{}{}
+	if g == nil {
+		return res
+	}
+
+	for k, v := range map[string]string{
+		GeoFieldCountry:  g.Country,
+		GeoFieldProvince: g.Province,
+		GeoFieldCity:     g.City,
+		GeoFieldISP:      g.ISP,
+		GeoFieldASNOrg:   g.ASNOrg,
+	} {
+		if v != "" {
+			res[prefix+k] = v
+		}
+	}
+
+	if g.ASN > 0 {
+		res[prefix+GeoFieldASN] = int64(g.ASN)
+	}
+
+	return res
+}
+
+// ASNDB resolves autonomous system number and organization of IP.
+type ASNDB interface {
+	LookupASN(ip net.IP) (uint, string, error)
+}
+
+type mmdbASN struct {
+	r *geoip2.Reader
+}
+
+func (x *mmdbASN) LookupASN(ip net.IP) (uint, string, error) {
+	asn, err := x.r.ASN(ip)
+	if err != nil {
+		return 0, "", err
+	}
+	return asn.AutonomousSystemNumber, asn.AutonomousSystemOrganization, nil
+}
+
+// OpenASNDB open MaxMind GeoLite2-ASN database file.
+func OpenASNDB(file string) (ASNDB, error) {
+	r, err := geoip2.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	return &mmdbASN{r: r}, nil
+}
+
+// IPDBGetter returns current IPDB, such as plval.GetIPDB. The IPDB may be
+// (re)loaded at any time, so it's looked up on each query.
+type IPDBGetter func() (ipdb.IPdb, bool)
+
+// GeoEnricher resolves location of IP from IPDB and ASN from ASNDB, results
+// are cached.
+type GeoEnricher struct {
+	ipdb    IPDBGetter
+	hasIPDB atomic.Bool
+	asn     ASNDB
+	fields  map[string]bool
+	cache   *expirable.LRU[string, *Geo]
+}
+
+// NewGeoEnricher create GeoEnricher with IPDB getter db and ASNDB asn(both
+// optional), only fields enriched.
+func NewGeoEnricher(db IPDBGetter, asn ASNDB, fields []string, cacheSize int, cacheTTL time.Duration) (*GeoEnricher, error) {
+	if len(fields) == 0 {
+		fields = DefaultGeoFields
+	}
+
+	e := &GeoEnricher{
+		ipdb:   db,
+		asn:    asn,
+		fields: map[string]bool{},
+		cache:  expirable.NewLRU[string, *Geo](cacheSize, nil, cacheTTL),
+	}
+
+	for _, f := range fields {
+		if !validGeoFields[f] {
+			return nil, fmt.Errorf("invalid geoip field %q", f)
+		}
+		e.fields[f] = true
+	}
+
+	return e, nil
+}
+
+// Lookup returns geo of IP, nil for IPs not public or not found.
+func (e *GeoEnricher) Lookup(ipAddr []byte) *Geo {
+	ip := net.IP(ipAddr)
+	if len(ip) == 0 || ip.IsPrivate() || ip.IsLoopback() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsMulticast() {
+		return nil
+	}
+
+	var db ipdb.IPdb
+	if e.ipdb != nil {
+		db, _ = e.ipdb()
+	}
+
+	// results cached before IPDB loaded(or after it's gone) are out of date
+	if has := db != nil; e.hasIPDB.Swap(has) != has {
+		e.cache.Purge()
+	}
+
+	key := ip.String()
+	if g, ok := e.cache.Get(key); ok {
+		return g
+	}
+
+	g := e.lookup(db, ip, key)
+	e.cache.Add(key, g) // not found cached too
+	return g
+}
+
+func (e *GeoEnricher) lookup(db ipdb.IPdb, ip net.IP, key string) *Geo {
+	g := &Geo{}
+
+	if db != nil {
+		if e.fields[GeoFieldCountry] || e.fields[GeoFieldProvince] || e.fields[GeoFieldCity] {
+			if rec, err := db.Geo(key); err == nil && rec != nil {
+				rec = rec.CheckData()
+				g.Country = e.field(GeoFieldCountry, rec.Country)
+				g.Province = e.field(GeoFieldProvince, rec.Region)
+				g.City = e.field(GeoFieldCity, rec.City)
+			}
+		}
+
+		if e.fields[GeoFieldISP] {
+			g.ISP = e.field(GeoFieldISP, db.SearchIsp(key))
+		}
+	}
+
+	if e.asn != nil && (e.fields[GeoFieldASN] || e.fields[GeoFieldASNOrg]) {
+		if asn, org, err := e.asn.LookupASN(ip); err == nil {
+			if e.fields[GeoFieldASN] {
+				g.ASN = asn
+			}
+			g.ASNOrg = e.field(GeoFieldASNOrg, org)
+		}
+	}
+
+	if *g == (Geo{}) {
+		return nil
+	}
+
+	return g
+}
+
+func (e *GeoEnricher) field(name, val string) string {
+	if !e.fields[name] || val == geoUnknown {
+		return ""
+	}
+	return val
+}
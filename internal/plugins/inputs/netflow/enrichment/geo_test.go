@@ -0,0 +1,170 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package enrichment
+
+import (
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/pipeline/ptinput/ipdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fixtureIPDB is an IPDB with fixed records.
+type fixtureIPDB struct {
+	records map[string]*ipdb.IPdbRecord
+	isp     map[string]string
+	queries int
+}
+
+func (*fixtureIPDB) Init(string, map[string]string) {}
+
+func (db *fixtureIPDB) Geo(ip string) (*ipdb.IPdbRecord, error) {
+	db.queries++
+	if r, ok := db.records[ip]; ok {
+		x := *r
+		return &x, nil
+	}
+	return nil, fmt.Errorf("%s not found", ip)
+}
+
+func (db *fixtureIPDB) SearchIsp(ip string) string {
+	if v, ok := db.isp[ip]; ok {
+		return v
+	}
+	return geoUnknown
+}
+
+type fixtureASN map[string][2]interface{}
+
+func (x fixtureASN) LookupASN(ip net.IP) (uint, string, error) {
+	if v, ok := x[ip.String()]; ok {
+		return v[0].(uint), v[1].(string), nil
+	}
+	return 0, "", fmt.Errorf("not found")
+}
+
+func getter(db ipdb.IPdb) IPDBGetter {
+	return func() (ipdb.IPdb, bool) { return db, db != nil }
+}
+
+func newFixtures() (*fixtureIPDB, fixtureASN) {
+	return &fixtureIPDB{
+		records: map[string]*ipdb.IPdbRecord{
+			"8.8.8.8":       {Country: "US", Region: "California", City: "Mountain View"},
+			"1.2.3.4":       {Country: "HK", City: geoUnknown},
+			"2001:4860::88": {Country: "US"},
+		},
+		isp: map[string]string{"8.8.8.8": "google"},
+	}, fixtureASN{
+		"8.8.8.8": {uint(15169), "GOOGLE"},
+	}
+}
+
+func TestGeoEnricher(t *testing.T) {
+	t.Run("default-fields", func(t *testing.T) {
+		db, asn := newFixtures()
+		e, err := NewGeoEnricher(getter(db), asn, nil, 100, time.Minute)
+		require.NoError(t, err)
+
+		assert.Equal(t, &Geo{Country: "US", Province: "California", City: "Mountain View", ASN: 15169},
+			e.Lookup(net.ParseIP("8.8.8.8").To4()))
+
+		// HK remapped, unknown city dropped
+		assert.Equal(t, &Geo{Country: "CN", Province: "Hong Kong"}, e.Lookup([]byte{1, 2, 3, 4}))
+
+		assert.Equal(t, &Geo{Country: "US"}, e.Lookup(net.ParseIP("2001:4860::88")))
+
+		assert.Nil(t, e.Lookup([]byte{9, 9, 9, 9}), "not found")
+		assert.Nil(t, e.Lookup([]byte{10, 0, 0, 1}), "private IP")
+		assert.Nil(t, e.Lookup([]byte{127, 0, 0, 1}), "loopback IP")
+		assert.Nil(t, e.Lookup(nil))
+	})
+
+	t.Run("fields", func(t *testing.T) {
+		db, asn := newFixtures()
+		e, err := NewGeoEnricher(getter(db), asn, []string{GeoFieldCity, GeoFieldISP, GeoFieldASNOrg}, 100, time.Minute)
+		require.NoError(t, err)
+
+		g := e.Lookup([]byte{8, 8, 8, 8})
+		assert.Equal(t, &Geo{City: "Mountain View", ISP: "google", ASNOrg: "GOOGLE"}, g)
+		assert.Equal(t, map[string]interface{}{
+			"source_city":    "Mountain View",
+			"source_isp":     "google",
+			"source_asn_org": "GOOGLE",
+		}, g.Fields("source_"))
+
+		_, err = NewGeoEnricher(getter(db), asn, []string{"zipcode"}, 100, time.Minute)
+		assert.Error(t, err)
+	})
+
+	t.Run("cache", func(t *testing.T) {
+		db, _ := newFixtures()
+		e, err := NewGeoEnricher(getter(db), nil, nil, 1, 50*time.Millisecond)
+		require.NoError(t, err)
+
+		for i := 0; i < 3; i++ {
+			e.Lookup([]byte{8, 8, 8, 8})
+			e.Lookup([]byte{9, 9, 9, 9}) // not found cached too
+		}
+		assert.Equal(t, 6, db.queries, "cache size 1: evicted each other")
+
+		e, err = NewGeoEnricher(getter(db), nil, nil, 10, 50*time.Millisecond)
+		require.NoError(t, err)
+		db.queries = 0
+
+		for i := 0; i < 3; i++ {
+			e.Lookup([]byte{8, 8, 8, 8})
+			e.Lookup([]byte{9, 9, 9, 9})
+		}
+		assert.Equal(t, 2, db.queries)
+
+		time.Sleep(100 * time.Millisecond) // expired
+		e.Lookup([]byte{8, 8, 8, 8})
+		assert.Equal(t, 3, db.queries)
+	})
+
+	t.Run("no-ipdb", func(t *testing.T) {
+		_, asn := newFixtures()
+		e, err := NewGeoEnricher(nil, asn, nil, 10, time.Minute)
+		require.NoError(t, err)
+
+		assert.Equal(t, &Geo{ASN: 15169}, e.Lookup([]byte{8, 8, 8, 8}))
+	})
+
+	t.Run("ipdb-reloaded", func(t *testing.T) {
+		db, asn := newFixtures()
+
+		var cur ipdb.IPdb
+		e, err := NewGeoEnricher(func() (ipdb.IPdb, bool) { return cur, cur != nil }, asn, nil, 10, time.Minute)
+		require.NoError(t, err)
+
+		assert.Equal(t, &Geo{ASN: 15169}, e.Lookup([]byte{8, 8, 8, 8}))
+		assert.Nil(t, e.Lookup([]byte{1, 2, 3, 4}))
+
+		cur = db // IPDB loaded later
+		assert.Equal(t, &Geo{Country: "US", Province: "California", City: "Mountain View", ASN: 15169},
+			e.Lookup([]byte{8, 8, 8, 8}))
+		assert.Equal(t, &Geo{Country: "CN", Province: "Hong Kong"}, e.Lookup([]byte{1, 2, 3, 4}))
+
+		reloaded, _ := newFixtures()
+		reloaded.records["9.9.9.9"] = &ipdb.IPdbRecord{Country: "CH"}
+		cur = reloaded
+		assert.Equal(t, &Geo{Country: "CH"}, e.Lookup([]byte{9, 9, 9, 9}), "lookup on reloaded IPDB")
+		assert.Equal(t, 1, reloaded.queries)
+	})
+}
+
+func TestGeoFields(t *testing.T) {
+	var g *Geo
+	assert.Empty(t, g.Fields("dest_"))
+
+	g = &Geo{Country: "CN", ASN: 4134}
+	assert.Equal(t, map[string]interface{}{"dest_country": "CN", "dest_asn": int64(4134)}, g.Fields("dest_"))
+}
@@ -0,0 +1,12 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package enrichment
+
+// InterfaceStore resolves name and speed(bits per second) of interface index
+// on exporter, such as interfaces collected by the snmp input.
+type InterfaceStore interface {
+	GetInterface(exporterIP string, index uint32) (name string, speed uint64, ok bool)
+}
@@ -18,6 +18,7 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/common"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/config"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/enrichment"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/metrics"
 	"go.uber.org/atomic"
 )
@@ -48,6 +49,9 @@ type FlowAggregator struct {
 	combinedTags            map[string]string
 	feeder                  dkio.Feeder
 	source                  string
+
+	geo        *enrichment.GeoEnricher
+	interfaces enrichment.InterfaceStore
 }
 
 type SequenceDeltaKey struct {
@@ -75,7 +79,7 @@ func NewFlowAggregator(config *config.NetflowConfig, combinedTags map[string]str
 		hostname = unknownHost
 	}
 
-	return &FlowAggregator{
+	agg := &FlowAggregator{
 		flowIn:                       make(chan *common.Flow, config.AggregatorBufferSize),
 		flowAcc:                      newFlowAccumulator(flushInterval, flowContextTTL, config.AggregatorPortRollupThreshold, config.AggregatorPortRollupDisabled),
 		flushFlowsToSendInterval:     flushFlowsToSendInterval,
@@ -94,6 +98,10 @@ func NewFlowAggregator(config *config.NetflowConfig, combinedTags map[string]str
 		feeder:       feeder,
 		source:       source,
 	}
+
+	agg.setupEnrichment(config.Enrichment)
+
+	return agg
 }
 
 // Start will start the FlowAggregator worker.
@@ -132,6 +140,7 @@ func (agg *FlowAggregator) run() {
 func (agg *FlowAggregator) sendFlows(flows []*common.Flow, flushTime time.Time) {
 	for _, flow := range flows {
 		flowPayload := buildPayload(flow, agg.hostname, flushTime)
+		agg.enrich(&flowPayload, flow)
 		payloadBytes, err := json.Marshal(flowPayload)
 		if err != nil {
 			l.Errorf("Error marshaling device metadata: %s", err)
@@ -158,6 +167,9 @@ func (agg *FlowAggregator) sendFlows(flows []*common.Flow, flushTime time.Time)
 		logging.Fields["source_ip"] = flowPayload.Source.IP
 		logging.Fields["source_port"] = flowPayload.Source.Port
 		logging.Fields["type"] = flowPayload.FlowType
+		for k, v := range enrichedFields(&flowPayload) {
+			logging.Fields[k] = v
+		}
 
 		if err := agg.feeder.FeedV2(point.Logging, []*point.Point{logging.Point()},
 			dkio.WithCollectCost(time.Since(flushTime)),
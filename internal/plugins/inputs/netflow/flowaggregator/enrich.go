@@ -0,0 +1,93 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package flowaggregator
+
+import (
+	"path/filepath"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/pipeline/plval"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/common"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/config"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/enrichment"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/payload"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/snmp/snmputil"
+)
+
+func (agg *FlowAggregator) setupEnrichment(conf *config.EnrichmentConfig) {
+	if conf == nil {
+		return
+	}
+
+	if conf.GeoIP {
+		if _, ok := plval.GetIPDB(); !ok {
+			l.Warnf("IPDB not available yet, only ASN enriched until it's loaded")
+		}
+
+		var asn enrichment.ASNDB
+		if conf.ASNFile != "" {
+			f := conf.ASNFile
+			if !filepath.IsAbs(f) {
+				f = filepath.Join(datakit.DataDir, "ipdb", f)
+			}
+
+			var err error
+			if asn, err = enrichment.OpenASNDB(f); err != nil {
+				l.Warnf("open ASN database %s: %s, ASN not enriched", f, err)
+			}
+		}
+
+		geo, err := enrichment.NewGeoEnricher(plval.GetIPDB, asn, conf.GeoIPFields, conf.CacheSize, conf.CacheTTL)
+		if err != nil {
+			l.Errorf("invalid GeoIP enrichment: %s, GeoIP not enriched", err)
+		} else {
+			agg.geo = geo
+		}
+	}
+
+	if conf.SNMPInterface {
+		agg.interfaces = snmputil.DefaultInterfaceStore
+	}
+}
+
+// enrich fill geo of endpoints and interfaces of exporter into flow payload.
+func (agg *FlowAggregator) enrich(p *payload.FlowPayload, flow *common.Flow) {
+	if agg.geo != nil {
+		p.Source.Geo = agg.geo.Lookup(flow.SrcAddr)
+		p.Destination.Geo = agg.geo.Lookup(flow.DstAddr)
+	}
+
+	if agg.interfaces != nil {
+		for _, x := range []*payload.Interface{&p.Ingress.Interface, &p.Egress.Interface} {
+			if name, speed, ok := agg.interfaces.GetInterface(p.Exporter.IP, x.Index); ok {
+				x.Name = name
+				x.Speed = speed
+			}
+		}
+	}
+}
+
+// enrichedFields returns logging fields of enriched geo and interfaces.
+func enrichedFields(p *payload.FlowPayload) map[string]interface{} {
+	res := p.Source.Geo.Fields("source_")
+	for k, v := range p.Destination.Geo.Fields("dest_") {
+		res[k] = v
+	}
+
+	for prefix, x := range map[string]payload.Interface{
+		"input_interface":  p.Ingress.Interface,
+		"output_interface": p.Egress.Interface,
+	} {
+		if x.Name != "" {
+			res[prefix] = x.Name
+		}
+		if x.Speed > 0 {
+			res[prefix+"_speed"] = int64(x.Speed)
+		}
+	}
+
+	return res
+}
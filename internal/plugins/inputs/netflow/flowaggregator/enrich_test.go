@@ -0,0 +1,104 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package flowaggregator
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/pipeline/ptinput/ipdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/common"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/config"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/enrichment"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/payload"
+)
+
+type fixtureIPDB map[string]*ipdb.IPdbRecord
+
+func (fixtureIPDB) Init(string, map[string]string) {}
+func (fixtureIPDB) SearchIsp(string) string        { return "unknown" }
+
+func (db fixtureIPDB) Geo(ip string) (*ipdb.IPdbRecord, error) {
+	if r, ok := db[ip]; ok {
+		return r, nil
+	}
+	return nil, fmt.Errorf("not found")
+}
+
+// mockedInterfaceStore mocks interfaces collected by the snmp input.
+type mockedInterfaceStore map[string]map[uint32]payload.Interface
+
+func (s mockedInterfaceStore) GetInterface(exporterIP string, index uint32) (string, uint64, bool) {
+	if x, ok := s[exporterIP][index]; ok {
+		return x.Name, x.Speed, true
+	}
+	return "", 0, false
+}
+
+func TestEnrich(t *testing.T) {
+	feeder := dkio.NewMockedFeeder()
+	agg := NewFlowAggregator(&config.NetflowConfig{AggregatorFlushInterval: 1}, map[string]string{}, feeder, "netflow")
+
+	db := fixtureIPDB{
+		"8.8.8.8": {Country: "US", Region: "California", City: "Mountain View"},
+	}
+	geo, err := enrichment.NewGeoEnricher(func() (ipdb.IPdb, bool) { return db, true }, nil, []string{enrichment.GeoFieldCountry, enrichment.GeoFieldCity}, 10, time.Minute)
+	require.NoError(t, err)
+
+	agg.geo = geo
+	agg.interfaces = mockedInterfaceStore{
+		"127.0.0.1": {
+			10: {Name: "ge-0/0/1", Speed: 1e9},
+			20: {Name: "ge-0/0/2"},
+		},
+	}
+
+	flow := &common.Flow{
+		FlowType:        common.TypeNetFlow9,
+		ExporterAddr:    []byte{127, 0, 0, 1},
+		SrcAddr:         []byte{10, 10, 10, 10},
+		DstAddr:         []byte{8, 8, 8, 8},
+		InputInterface:  10,
+		OutputInterface: 20,
+	}
+
+	p := buildPayload(flow, "my-hostname", time.Now())
+	agg.enrich(&p, flow)
+
+	assert.Nil(t, p.Source.Geo)
+	assert.Equal(t, &enrichment.Geo{Country: "US", City: "Mountain View"}, p.Destination.Geo)
+	assert.Equal(t, payload.Interface{Index: 10, Name: "ge-0/0/1", Speed: 1e9}, p.Ingress.Interface)
+	assert.Equal(t, payload.Interface{Index: 20, Name: "ge-0/0/2"}, p.Egress.Interface)
+
+	agg.sendFlows([]*common.Flow{flow}, time.Now())
+
+	pts, err := feeder.NPoints(1, time.Second)
+	require.NoError(t, err)
+
+	pt := pts[0]
+	assert.Equal(t, "US", pt.Get("dest_country"))
+	assert.Equal(t, "Mountain View", pt.Get("dest_city"))
+	assert.Nil(t, pt.Get("dest_province"), "field not configured")
+	assert.Nil(t, pt.Get("source_country"), "private IP")
+	assert.Equal(t, "ge-0/0/1", pt.Get("input_interface"))
+	assert.Equal(t, int64(1e9), pt.Get("input_interface_speed"))
+	assert.Equal(t, "ge-0/0/2", pt.Get("output_interface"))
+	assert.Nil(t, pt.Get("output_interface_speed"))
+
+	t.Run("unknown-exporter", func(t *testing.T) {
+		flow := *flow
+		flow.ExporterAddr = []byte{127, 0, 0, 2}
+
+		p := buildPayload(&flow, "my-hostname", time.Now())
+		agg.enrich(&p, &flow)
+		assert.Empty(t, p.Ingress.Interface.Name)
+		assert.NotContains(t, enrichedFields(&p), "input_interface")
+	})
+}
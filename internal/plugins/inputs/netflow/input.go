@@ -46,6 +46,18 @@ const (
     #    flow_type = "sflow5"
     #    port      = 6343
 
+    # Enrich flows with GeoIP of source/destination IPs and interface
+    # names resolved from data collected by the snmp input.
+    #[inputs.netflow.enrichment]
+    #    geoip = true
+    #    # Available: country/province/city/isp/asn/asn_org
+    #    geoip_fields = ["country", "province", "city", "asn"]
+    #    # MaxMind GeoLite2-ASN database for asn/asn_org, relative to <datakit>/data/ipdb
+    #    asn_file = "GeoLite2-ASN.mmdb"
+    #    snmp_interface = true
+    #    cache_size = 10000
+    #    cache_ttl = "1h"
+
     [inputs.netflow.tags]
     # some_tag = "some_value"
     # more_tag = "some_other_value"
@@ -65,6 +77,8 @@ type Input struct {
 	Listeners []common.FlowOpt  `toml:"listeners,omitempty"`
 	Tags      map[string]string `toml:"tags"`
 
+	Enrichment *config.EnrichmentConfig `toml:"enrichment"`
+
 	semStop *cliutils.Sem // start stop signal
 	feeder  dkio.Feeder
 	tagger  datakit.GlobalTagger
@@ -169,7 +183,7 @@ func NewNetflowServer(ipt *Input) (*Server, error) {
 
 	flows := getFlows(ipt)
 
-	mainConfig, err := config.ReadConfig(flows, ipt.Namespace)
+	mainConfig, err := config.ReadConfig(flows, ipt.Namespace, ipt.Enrichment)
 	if err != nil {
 		return nil, err
 	}
@@ -64,16 +64,32 @@ func (*NetflowMeasurement) Info() *inputs.MeasurementInfo {
 			"host": inputs.NewTagInfo("Hostname."),
 		},
 		Fields: map[string]interface{}{
-			"message":     &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "The text of the logging."},
-			"status":      &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "The status of the logging, only supported `info/emerg/alert/critical/error/warning/debug/OK/unknown`."},
-			"bytes":       &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.SizeByte, Desc: "Flow bytes."},
-			"dest_ip":     &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow destination IP."},
-			"dest_port":   &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow destination port."},
-			"device_ip":   &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "NetFlow exporter IP."},
-			"ip_protocol": &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow network protocol."},
-			"source_ip":   &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow source IP."},
-			"source_port": &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow source port."},
-			"type":        &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow type."},
+			"message":                &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "The text of the logging."},
+			"status":                 &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "The status of the logging, only supported `info/emerg/alert/critical/error/warning/debug/OK/unknown`."},
+			"bytes":                  &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.SizeByte, Desc: "Flow bytes."},
+			"dest_ip":                &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow destination IP."},
+			"dest_port":              &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow destination port."},
+			"device_ip":              &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "NetFlow exporter IP."},
+			"ip_protocol":            &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow network protocol."},
+			"source_ip":              &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow source IP."},
+			"source_port":            &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow source port."},
+			"type":                   &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Flow type."},
+			"source_country":         &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Country of flow source IP, enriched by GeoIP."},
+			"source_province":        &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Province of flow source IP, enriched by GeoIP."},
+			"source_city":            &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "City of flow source IP, enriched by GeoIP."},
+			"source_isp":             &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "ISP of flow source IP, enriched by GeoIP."},
+			"source_asn":             &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "Autonomous system number of flow source IP, enriched by GeoIP."},
+			"source_asn_org":         &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Autonomous system organization of flow source IP, enriched by GeoIP."},
+			"dest_country":           &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Country of flow destination IP, enriched by GeoIP."},
+			"dest_province":          &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Province of flow destination IP, enriched by GeoIP."},
+			"dest_city":              &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "City of flow destination IP, enriched by GeoIP."},
+			"dest_isp":               &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "ISP of flow destination IP, enriched by GeoIP."},
+			"dest_asn":               &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "Autonomous system number of flow destination IP, enriched by GeoIP."},
+			"dest_asn_org":           &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Autonomous system organization of flow destination IP, enriched by GeoIP."},
+			"input_interface":        &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Name of flow input interface, resolved from SNMP."},
+			"input_interface_speed":  &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "Speed(bits per second) of flow input interface, resolved from SNMP."},
+			"output_interface":       &inputs.FieldInfo{DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Name of flow output interface, resolved from SNMP."},
+			"output_interface_speed": &inputs.FieldInfo{DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "Speed(bits per second) of flow output interface, resolved from SNMP."},
 		},
 	}
 }
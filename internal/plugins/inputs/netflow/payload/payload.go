@@ -6,6 +6,8 @@
 // Package payload contains payload stuff.
 package payload
 
+import "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/netflow/enrichment"
+
 // Device contains device details (device sending NetFlow flows).
 type Device struct {
 	Namespace string `json:"namespace"`
@@ -22,6 +24,8 @@ type Endpoint struct {
 	Port string `json:"port"` // Port number can be zero/positive or `*` (ephemeral port)
 	Mac  string `json:"mac"`
 	Mask string `json:"mask"`
+
+	Geo *enrichment.Geo `json:"geo,omitempty"`
 }
 
 // NextHop contains next hop details.
@@ -32,6 +36,8 @@ type NextHop struct {
 // Interface contains interface details.
 type Interface struct {
 	Index uint32 `json:"index"`
+	Name  string `json:"name,omitempty"`
+	Speed uint64 `json:"speed,omitempty"` // in bits per second
 }
 
 // ObservationPoint contains ingress or egress observation point.
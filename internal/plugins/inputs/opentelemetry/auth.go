@@ -0,0 +1,204 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package opentelemetry
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const bearerPrefix = "Bearer "
+
+// tlsConfig configure TLS on OTLP gRPC server. If CA certs set, client
+// certificates are required and verified(mTLS).
+type tlsConfig struct {
+	Cert    string   `toml:"cert" json:"cert"`
+	CertKey string   `toml:"cert_key" json:"cert_key"`
+	CaCerts []string `toml:"ca_certs" json:"ca_certs"`
+}
+
+// loadCertPool load CA certificates from PEM files.
+func loadCertPool(files []string) (*x509.CertPool, error) {
+	pool := x509.NewCertPool()
+	for _, f := range files {
+		pem, err := os.ReadFile(filepath.Clean(f))
+		if err != nil {
+			return nil, fmt.Errorf("read CA certificate %q failed: %w", f, err)
+		}
+
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no valid CA certificate in %q", f)
+		}
+	}
+
+	return pool, nil
+}
+
+func (c *tlsConfig) serverTLSConfig() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(c.Cert, c.CertKey)
+	if err != nil {
+		return nil, fmt.Errorf("load server certificate failed: %w", err)
+	}
+
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if len(c.CaCerts) > 0 {
+		pool, err := loadCertPool(c.CaCerts)
+		if err != nil {
+			return nil, err
+		}
+
+		conf.ClientCAs = pool
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return conf, nil
+}
+
+// bearerAuth check bearer token in Authorization header.
+type bearerAuth []string
+
+func (ba bearerAuth) valid(authorization string) bool {
+	if !strings.HasPrefix(authorization, bearerPrefix) {
+		return false
+	}
+
+	token := []byte(strings.TrimSpace(authorization[len(bearerPrefix):]))
+	for _, x := range ba {
+		if subtle.ConstantTimeCompare(token, []byte(x)) == 1 {
+			return true
+		}
+	}
+
+	return false
+}
+
+// httpAuth accept HTTP requests with valid bearer token, or with client
+// certificate signed by the configured CAs.
+type httpAuth struct {
+	tokens bearerAuth
+	roots  *x509.CertPool
+}
+
+func newHTTPAuth(c *httpConfig) (*httpAuth, error) {
+	a := &httpAuth{tokens: bearerAuth(c.BearerTokens)}
+
+	if len(c.ClientCaCerts) > 0 {
+		pool, err := loadCertPool(c.ClientCaCerts)
+		if err != nil {
+			return nil, err
+		}
+		a.roots = pool
+	}
+
+	return a, nil
+}
+
+func (a *httpAuth) enabled() bool {
+	return len(a.tokens) > 0 || a.roots != nil
+}
+
+// validCert check client certificate of the TLS connection. The Datakit HTTP
+// server should be HTTPS and request client certificates, see client_ca of
+// Datakit HTTP API auth.
+func (a *httpAuth) validCert(state *tls.ConnectionState) bool {
+	if a.roots == nil || state == nil || len(state.PeerCertificates) == 0 {
+		return false
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         a.roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	for _, c := range state.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(c)
+	}
+
+	_, err := state.PeerCertificates[0].Verify(opts)
+	return err == nil
+}
+
+// httpWrapper reject requests without valid bearer token or client certificate with 401.
+func (a *httpAuth) httpWrapper(next http.HandlerFunc) http.HandlerFunc {
+	if !a.enabled() {
+		return next
+	}
+
+	return func(resp http.ResponseWriter, req *http.Request) {
+		if a.tokens.valid(req.Header.Get("Authorization")) || a.validCert(req.TLS) {
+			next(resp, req)
+			return
+		}
+
+		log.Warnf("unauthorized OTLP request from %s on %s", req.RemoteAddr, req.URL.Path)
+		if len(a.tokens) > 0 {
+			resp.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		resp.WriteHeader(http.StatusUnauthorized)
+	}
+}
+
+func (ba bearerAuth) checkGRPC(ctx context.Context) error {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if ok {
+		for _, v := range md.Get("authorization") {
+			if ba.valid(v) {
+				return nil
+			}
+		}
+	}
+
+	return status.Error(codes.Unauthenticated, "invalid or missing bearer token")
+}
+
+// grpcServerOptions build gRPC server options for authentication on OTLP gRPC endpoint.
+func (cfg *grpcConfig) grpcServerOptions() ([]grpc.ServerOption, error) {
+	var opts []grpc.ServerOption
+
+	if cfg.TLS != nil {
+		tlsConf, err := cfg.TLS.serverTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConf)))
+	}
+
+	if ba := bearerAuth(cfg.BearerTokens); len(ba) > 0 {
+		opts = append(opts, grpc.UnaryInterceptor(func(ctx context.Context,
+			req interface{},
+			info *grpc.UnaryServerInfo,
+			handler grpc.UnaryHandler,
+		) (interface{}, error) {
+			if err := ba.checkGRPC(ctx); err != nil {
+				log.Warnf("unauthorized OTLP gRPC request on %s", info.FullMethod)
+				return nil, err
+			}
+
+			return handler(ctx, req)
+		}))
+	}
+
+	return opts, nil
+}
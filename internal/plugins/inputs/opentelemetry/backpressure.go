@@ -0,0 +1,176 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package opentelemetry
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/klauspost/compress/zstd"
+	spb "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/encoding"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+const (
+	defaultRetryAfter = 5 * time.Second
+	retryInfoTypeURL  = "type.googleapis.com/google.rpc.RetryInfo"
+)
+
+// backPressureConfig configure whether to return busy response to OTEL SDKs
+// instead of blocking on the request when Datakit IO is saturated.
+type backPressureConfig struct {
+	Enable     bool          `toml:"enable" json:"enable"`
+	RetryAfter time.Duration `toml:"retry_after" json:"retry_after"`
+}
+
+var backPressure = &backPressureConfig{RetryAfter: defaultRetryAfter}
+
+func setupBackPressure(c *backPressureConfig) {
+	if c == nil {
+		backPressure = &backPressureConfig{RetryAfter: defaultRetryAfter}
+		return
+	}
+
+	if c.RetryAfter <= 0 {
+		c.RetryAfter = defaultRetryAfter
+	}
+
+	backPressure = c
+}
+
+func isBusy(err error) bool {
+	return errors.Is(err, dkio.ErrIOBusy) || errors.Is(err, dkio.ErrBusy)
+}
+
+func feedOptions() []dkio.FeedOption {
+	opts := []dkio.FeedOption{dkio.WithInputName(inputName)}
+	if backPressure.Enable {
+		opts = append(opts, dkio.WithNonBlocking(true))
+	}
+
+	return opts
+}
+
+// feedPoints feed pts to IO, and return the number of points rejected(feed
+// failed or dropped by filters).
+func feedPoints(feeder dkio.Feeder, cat point.Category, pts []*point.Point, opts ...dkio.FeedOption) (int, error) {
+	var filtered int
+	opts = append(append(feedOptions(), opts...), dkio.WithFilteredCount(&filtered))
+
+	if err := feeder.FeedV2(cat, pts, opts...); err != nil {
+		log.Warnf("feed %d %s points failed: %s", len(pts), cat, err)
+		return len(pts), err
+	}
+
+	return filtered, nil
+}
+
+// feedMetricPoints feed metric pts converted from OTLP request, and return the
+// number of OTLP data points rejected(not converted, feed failed or any of its
+// points dropped by filters). total is the number of data points within the
+// request, and pts[i] is converted from data point dataPoints[i].
+func feedMetricPoints(feeder dkio.Feeder,
+	pts []*point.Point,
+	dataPoints []int,
+	total int,
+	opts ...dkio.FeedOption,
+) (int, error) {
+	var filtered []*point.Point
+	opts = append(append(feedOptions(), opts...), dkio.WithFilteredPoints(&filtered))
+
+	if err := feeder.FeedV2(point.Metric, pts, opts...); err != nil {
+		log.Warnf("feed %d metric points failed: %s", len(pts), err)
+		return total, err
+	}
+
+	converted := 0
+	if len(dataPoints) > 0 {
+		converted = dataPoints[len(dataPoints)-1] + 1
+	}
+
+	rejected := map[int]bool{}
+	if len(filtered) > 0 {
+		dropped := make(map[*point.Point]bool, len(filtered))
+		for _, pt := range filtered {
+			dropped[pt] = true
+		}
+
+		for i, pt := range pts {
+			if dropped[pt] {
+				rejected[dataPoints[i]] = true
+			}
+		}
+	}
+
+	return total - converted + len(rejected), nil
+}
+
+func rejectedMessage(rejected int, err error) string {
+	if err != nil {
+		return err.Error()
+	}
+
+	return fmt.Sprintf("%d dropped by Datakit filters", rejected)
+}
+
+// grpcBusyError build RESOURCE_EXHAUSTED status with RetryInfo, so that SDKs retry later.
+func grpcBusyError() error {
+	delay, err := proto.Marshal(durationpb.New(backPressure.RetryAfter))
+	if err != nil {
+		return status.Error(codes.ResourceExhausted, "Datakit busy")
+	}
+
+	// google.rpc.RetryInfo{retry_delay = 1}
+	retryInfo := protowire.AppendTag(nil, 1, protowire.BytesType)
+	retryInfo = protowire.AppendBytes(retryInfo, delay)
+
+	return status.FromProto(&spb.Status{
+		Code:    int32(codes.ResourceExhausted),
+		Message: "Datakit busy",
+		Details: []*anypb.Any{{TypeUrl: retryInfoTypeURL, Value: retryInfo}},
+	}).Err()
+}
+
+func writeHTTPBusy(resp http.ResponseWriter) {
+	resp.Header().Set("Retry-After", strconv.Itoa(int(backPressure.RetryAfter.Seconds())))
+	resp.WriteHeader(http.StatusTooManyRequests)
+}
+
+// zstdCompressor enable zstd compressed OTLP gRPC requests.
+type zstdCompressor struct{}
+
+func (zstdCompressor) Name() string { return "zstd" }
+
+func (zstdCompressor) Compress(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+}
+
+func (zstdCompressor) Decompress(r io.Reader) (io.Reader, error) {
+	dec, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+
+	return dec.IOReadCloser(), nil
+}
+
+//nolint:gochecknoinits
+func init() {
+	encoding.RegisterCompressor(zstdCompressor{})
+}
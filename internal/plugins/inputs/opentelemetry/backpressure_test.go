@@ -0,0 +1,356 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package opentelemetry
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	clogs "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/collector/logs/v1"
+	cmetrics "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/collector/metrics/v1"
+	common "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/common/v1"
+	logspb "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/logs/v1"
+	metrics "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/metrics/v1"
+	resource "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/resource/v1"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+func gaugeRequest() *cmetrics.ExportMetricsServiceRequest {
+	return &cmetrics.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metrics.ResourceMetrics{
+			{
+				Resource: &resource.Resource{
+					Attributes: []*common.KeyValue{
+						{Key: "service.name", Value: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "svc"}}},
+					},
+				},
+				ScopeMetrics: []*metrics.ScopeMetrics{
+					{
+						Metrics: []*metrics.Metric{
+							{
+								Name: "cpu_usage",
+								Data: &metrics.Metric_Gauge{Gauge: &metrics.Gauge{
+									DataPoints: []*metrics.NumberDataPoint{
+										{
+											TimeUnixNano: uint64(time.Now().UnixNano()),
+											Value:        &metrics.NumberDataPoint_AsDouble{AsDouble: 1.5},
+										},
+									},
+								}},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestBearerAuth(t *testing.T) {
+	ba := bearerAuth{"token-1", "token-2"}
+
+	assert.True(t, ba.valid("Bearer token-1"))
+	assert.True(t, ba.valid("Bearer token-2"))
+	assert.False(t, ba.valid("Bearer token-3"))
+	assert.False(t, ba.valid("token-1"))
+	assert.False(t, ba.valid(""))
+
+	t.Run("http", func(t *testing.T) {
+		h := (&httpAuth{tokens: ba}).httpWrapper(func(resp http.ResponseWriter, req *http.Request) {
+			resp.WriteHeader(http.StatusOK)
+		})
+
+		req := httptest.NewRequest(http.MethodPost, "/otel/v1/metric", nil)
+		rec := httptest.NewRecorder()
+		h(rec, req)
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, "Bearer", rec.Header().Get("WWW-Authenticate"))
+
+		req.Header.Set("Authorization", "Bearer token-1")
+		rec = httptest.NewRecorder()
+		h(rec, req)
+		assert.Equal(t, http.StatusOK, rec.Code)
+	})
+
+	t.Run("grpc", func(t *testing.T) {
+		err := ba.checkGRPC(context.Background())
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer token-2"))
+		assert.NoError(t, ba.checkGRPC(ctx))
+	})
+}
+
+// newTestCert create a certificate signed by parent(self-signed if nil).
+func newTestCert(t *testing.T, cn string, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert, key
+}
+
+func TestHTTPClientCert(t *testing.T) {
+	ca, caKey := newTestCert(t, "ca", nil, nil)
+	client, _ := newTestCert(t, "client", ca, caKey)
+
+	otherCA, otherKey := newTestCert(t, "other-ca", nil, nil)
+	other, _ := newTestCert(t, "other", otherCA, otherKey)
+
+	caFile := filepath.Join(t.TempDir(), "ca.crt")
+	require.NoError(t, os.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: ca.Raw}), 0o600))
+
+	auth, err := newHTTPAuth(&httpConfig{BearerTokens: []string{"token-1"}, ClientCaCerts: []string{caFile}})
+	require.NoError(t, err)
+
+	h := auth.httpWrapper(func(resp http.ResponseWriter, req *http.Request) {
+		resp.WriteHeader(http.StatusOK)
+	})
+
+	cases := []struct {
+		name   string
+		token  string
+		certs  []*x509.Certificate
+		expect int
+	}{
+		{name: "no-auth", expect: http.StatusUnauthorized},
+		{name: "token", token: "Bearer token-1", expect: http.StatusOK},
+		{name: "cert", certs: []*x509.Certificate{client}, expect: http.StatusOK},
+		{name: "untrusted-cert", certs: []*x509.Certificate{other}, expect: http.StatusUnauthorized},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/otel/v1/metric", nil)
+			if tc.token != "" {
+				req.Header.Set("Authorization", tc.token)
+			}
+
+			if tc.certs != nil {
+				req.TLS = &tls.ConnectionState{PeerCertificates: tc.certs}
+			}
+
+			rec := httptest.NewRecorder()
+			h(rec, req)
+			assert.Equal(t, tc.expect, rec.Code)
+		})
+	}
+
+	_, err = newHTTPAuth(&httpConfig{ClientCaCerts: []string{filepath.Join(t.TempDir(), "none.crt")}})
+	assert.Error(t, err)
+}
+
+// failFeeder reject all points with non-busy error.
+type failFeeder struct {
+	dkio.Feeder
+}
+
+func (failFeeder) FeedV2(point.Category, []*point.Point, ...dkio.FeedOption) error {
+	return errors.New("mocked feed error")
+}
+
+func TestLogsPartialSuccess(t *testing.T) {
+	req := &clogs.ExportLogsServiceRequest{
+		ResourceLogs: []*logspb.ResourceLogs{
+			{
+				ScopeLogs: []*logspb.ScopeLogs{
+					{
+						LogRecords: []*logspb.LogRecord{
+							{Body: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "log-1"}}},
+							{Body: &common.AnyValue{Value: &common.AnyValue_StringValue{StringValue: "log-2"}}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	lss := &LogsServiceServer{Ipt: &Input{feeder: dkio.NewMockedFeeder()}}
+	resp, err := lss.Export(context.Background(), req)
+	require.NoError(t, err)
+	assert.Nil(t, resp.PartialSuccess)
+
+	lss = &LogsServiceServer{Ipt: &Input{feeder: failFeeder{}}}
+	resp, err = lss.Export(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, resp.PartialSuccess)
+	assert.Equal(t, int64(2), resp.PartialSuccess.RejectedLogRecords)
+	assert.Equal(t, "mocked feed error", resp.PartialSuccess.ErrorMessage)
+}
+
+func TestMetricsPartialSuccess(t *testing.T) {
+	req := gaugeRequest()
+	sm := req.ResourceMetrics[0].ScopeMetrics[0]
+	sm.Metrics = append(sm.Metrics, &metrics.Metric{
+		Name: "latency",
+		Data: &metrics.Metric_Histogram{Histogram: &metrics.Histogram{
+			DataPoints: []*metrics.HistogramDataPoint{
+				{Count: 3, Sum: proto.Float64(6), BucketCounts: []uint64{1, 2}, ExplicitBounds: []float64{10}},
+				{Count: 1, Sum: proto.Float64(1)},
+			},
+		}},
+	})
+
+	// 3 OTLP data points converted to 5 points
+	pts, dataPoints := parseResourceMetricsV2(req.ResourceMetrics)
+	require.Len(t, pts, 5)
+	assert.Equal(t, []int{0, 1, 1, 1, 2}, dataPoints)
+	assert.Equal(t, 3, countDataPoints(req.ResourceMetrics))
+
+	feeder := dkio.NewMockedFeeder()
+	mss := &MetricsServiceServer{Ipt: &Input{feeder: feeder}}
+
+	resp, err := mss.Export(context.Background(), req)
+	require.NoError(t, err)
+	assert.Nil(t, resp.PartialSuccess)
+
+	// drop all bucket points: only the first histogram data point rejected
+	feeder.SetFilter(func(pt *point.Point) bool { return pt.GetTag("le") == "" })
+	resp, err = mss.Export(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, resp.PartialSuccess)
+	assert.Equal(t, int64(1), resp.PartialSuccess.RejectedDataPoints)
+
+	mss = &MetricsServiceServer{Ipt: &Input{feeder: failFeeder{}}}
+	resp, err = mss.Export(context.Background(), req)
+	require.NoError(t, err)
+	require.NotNil(t, resp.PartialSuccess)
+	assert.Equal(t, int64(3), resp.PartialSuccess.RejectedDataPoints)
+}
+
+func TestExportBackPressure(t *testing.T) {
+	setupBackPressure(&backPressureConfig{Enable: true, RetryAfter: 3 * time.Second})
+	defer setupBackPressure(nil)
+
+	feeder := dkio.NewMockedFeeder()
+	mss := &MetricsServiceServer{Ipt: &Input{feeder: feeder}}
+
+	resp, err := mss.Export(context.Background(), gaugeRequest())
+	require.NoError(t, err)
+	assert.Nil(t, resp.PartialSuccess)
+
+	// make the feeder saturated
+	for {
+		if err := feeder.FeedV2(point.Metric, nil); err != nil {
+			break
+		}
+	}
+
+	_, err = mss.Export(context.Background(), gaugeRequest())
+	st, ok := status.FromError(err)
+	require.True(t, ok)
+	assert.Equal(t, codes.ResourceExhausted, st.Code())
+
+	details := st.Proto().GetDetails()
+	require.Len(t, details, 1)
+	assert.Equal(t, retryInfoTypeURL, details[0].GetTypeUrl())
+
+	t.Run("http-429", func(t *testing.T) {
+		iptGlobal = &Input{feeder: feeder}
+
+		body, err := proto.Marshal(gaugeRequest())
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodPost, "/otel/v1/metric", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		rec := httptest.NewRecorder()
+
+		handleOTElMetrics(rec, req)
+		assert.Equal(t, http.StatusTooManyRequests, rec.Code)
+		assert.Equal(t, "3", rec.Header().Get("Retry-After"))
+	})
+}
+
+func TestHTTPZstdBody(t *testing.T) {
+	feeder := dkio.NewMockedFeeder()
+	iptGlobal = &Input{feeder: feeder}
+
+	body, err := proto.Marshal(gaugeRequest())
+	require.NoError(t, err)
+
+	enc, err := zstd.NewWriter(nil)
+	require.NoError(t, err)
+	compressed := enc.EncodeAll(body, nil)
+	require.NoError(t, enc.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/otel/v1/metric", bytes.NewReader(compressed))
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "zstd")
+	rec := httptest.NewRecorder()
+
+	handleOTElMetrics(rec, req)
+	assert.Equal(t, statusOK, rec.Code)
+
+	out := &cmetrics.ExportMetricsServiceResponse{}
+	require.NoError(t, proto.Unmarshal(rec.Body.Bytes(), out))
+	assert.Nil(t, out.PartialSuccess)
+
+	pts, err := feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	assert.Len(t, pts, 1)
+
+	// gRPC zstd compressor
+	var buf bytes.Buffer
+	w, err := zstdCompressor{}.Compress(&buf)
+	require.NoError(t, err)
+	_, err = w.Write(body)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	r, err := zstdCompressor{}.Decompress(&buf)
+	require.NoError(t, err)
+
+	var got bytes.Buffer
+	_, err = got.ReadFrom(r)
+	require.NoError(t, err)
+	assert.Equal(t, body, got.Bytes())
+}
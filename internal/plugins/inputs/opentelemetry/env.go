@@ -35,6 +35,7 @@ func (ipt *Input) GetENVDoc() []*inputs.ENVInfo {
 		{FieldName: "LocalCacheConfig", ENVName: "STORAGE", Type: doc.JSON, Example: "`{\"storage\":\"./otel_storage\", \"capacity\": 5120}`", Desc: "Local cache file path and size (MB) ", DescZh: "本地缓存路径和大小（MB）"},
 		{FieldName: "HTTPConfig", ENVName: "HTTP", Type: doc.JSON, Example: "`{\"enable\":true, \"http_status_ok\": 200, \"trace_api\": \"/otel/v1/trace\", \"metric_api\": \"/otel/v1/metric\"}`", Desc: "HTTP agent config", DescZh: "代理 HTTP 配置"},
 		{FieldName: "GRPCConfig", ENVName: "GRPC", Type: doc.JSON, Example: `{"trace_enable": true, "metric_enable": true, "addr": "127.0.0.1:4317"}`, Desc: "GRPC agent config", DescZh: "代理 GRPC 配置"},
+		{FieldName: "BackPressure", Type: doc.Boolean, Default: `false`, Desc: "Return busy response(GRPC RESOURCE_EXHAUSTED or HTTP 429) to client when Datakit IO is busy", DescZh: "Datakit IO 繁忙时向客户端返回繁忙响应（GRPC RESOURCE_EXHAUSTED 或 HTTP 429）"},
 		{FieldName: "ExpectedHeaders", Type: doc.JSON, Example: `{"ex_version": "1.2.3", "ex_name": "env_resource_name"}`, Desc: "If 'expected_headers' is well config, then the obligation of sending certain wanted HTTP headers is on the client side", DescZh: "配置使用客户端的 HTTP 头"},
 		{FieldName: "Tags", Type: doc.JSON, Example: `{"k1":"v1", "k2":"v2", "k3":"v3"}`},
 	}
@@ -54,6 +55,7 @@ func (ipt *Input) GetENVDoc() []*inputs.ENVInfo {
 // ENV_INPUT_OTEL_HTTP : JSON string
 // ENV_INPUT_OTEL_GRPC : JSON string
 // ENV_INPUT_OTEL_EXPECTED_HEADERS : JSON string
+// ENV_INPUT_OTEL_BACK_PRESSURE : bool
 // below is a complete example for env in shell
 // export ENV_INPUT_OTEL_IGNORE_TAGS=`["block1", "block2"]`
 // export ENV_INPUT_OTEL_KEEP_RARE_RESOURCE=true
@@ -75,7 +77,7 @@ func (ipt *Input) ReadEnv(envs map[string]string) {
 		"ENV_INPUT_OTEL_THREADS", "ENV_INPUT_OTEL_STORAGE", "ENV_INPUT_OTEL_HTTP",
 		"ENV_INPUT_OTEL_GRPC", "ENV_INPUT_OTEL_EXPECTED_HEADERS", "ENV_INPUT_OTEL_DEL_MESSAGE",
 		"ENV_INPUT_OTEL_COMPATIBLE_DDTRACE",
-		"ENV_INPUT_OTEL_COMPATIBLE_DDTRACE", "ENV_INPUT_OTEL_SPILT_SERVICE_NAME",
+		"ENV_INPUT_OTEL_COMPATIBLE_DDTRACE", "ENV_INPUT_OTEL_SPILT_SERVICE_NAME", "ENV_INPUT_OTEL_BACK_PRESSURE",
 	} {
 		value, ok := envs[key]
 		if !ok {
@@ -178,6 +180,12 @@ func (ipt *Input) ReadEnv(envs map[string]string) {
 			} else {
 				ipt.DelMessage = ok
 			}
+		case "ENV_INPUT_OTEL_BACK_PRESSURE":
+			if ok, err := strconv.ParseBool(value); err != nil {
+				log.Warnf("parse %s=%s failed: %s", key, value, err.Error())
+			} else {
+				ipt.BackPressure = &backPressureConfig{Enable: ok}
+			}
 		}
 	}
 }
@@ -80,6 +80,19 @@ const (
   # [inputs.opentelemetry.sampler]
     # sampling_rate = 1.0
 
+  ## Topology config uses to compute service-to-service call edges from spans.
+  ## interval is the period of reporting edges as metric and object.
+  ## peer_tags are span tags used to find the callee on client spans when callee is not instrumented.
+  ## max_edges limits the edges kept in memory within one interval.
+  ## span_ttl is how long spans are kept to match parent and child spans sent in different requests.
+  ## max_spans limits the spans kept for matching.
+  # [inputs.opentelemetry.topology]
+    # interval = "1m"
+    # peer_tags = ["peer_service", "db_system", "http_host", "net_peer_name"]
+    # max_edges = 10000
+    # span_ttl = "30s"
+    # max_spans = 100000
+
   # [inputs.opentelemetry.tags]
     # key1 = "value1"
     # key2 = "value2"
@@ -113,6 +126,13 @@ const (
    trace_api = "/otel/v1/trace"
    metric_api = "/otel/v1/metric"
    logs_api = "/otel/v1/logs"
+   ## Bearer tokens accepted on HTTP APIs. If set, requests without a valid
+   ## "Authorization: Bearer <token>" header are rejected with 401.
+   # bearer_tokens = ["<your-token>"]
+   ## CA certificates to verify client certificates(mTLS) on HTTP APIs, requests with
+   ## valid bearer token or valid client certificate are accepted. Datakit HTTP server
+   ## should be HTTPS and request client certificates(client_ca of HTTP API auth).
+   # client_ca_certs = ["/path/to/ca.crt"]
 
   ## OTEL agent GRPC config for trace and metrics.
   ## GRPC services for trace and metrics can be enabled respectively as setting either to be true.
@@ -121,6 +141,23 @@ const (
    trace_enable = true
    metric_enable = true
    addr = "127.0.0.1:4317"
+   ## Bearer tokens accepted on GRPC server, requests without valid token got UNAUTHENTICATED.
+   # bearer_tokens = ["<your-token>"]
+
+  ## Enable TLS on GRPC server. If ca_certs set, client certificates are required
+  ## and verified against these CAs(mTLS).
+  # [inputs.opentelemetry.grpc.tls]
+    # cert = "/path/to/server.crt"
+    # cert_key = "/path/to/server.key"
+    # ca_certs = ["/path/to/ca.crt"]
+
+  ## Back-pressure config: if enabled, requests got RESOURCE_EXHAUSTED(GRPC) or 429 with
+  ## Retry-After(HTTP) instead of waiting when Datakit IO is busy, OTEL SDKs will retry them later.
+  ## Note: HTTP trace requests are handled asynchronously if threads or storage configured,
+  ## they are not affected by back-pressure.
+  # [inputs.opentelemetry.back_pressure]
+    # enable = false
+    # retry_after = "5s"
 
   ## If 'expected_headers' is well configed, then the obligation of sending certain wanted HTTP headers is on the client side,
   ## otherwise HTTP status code 400(bad request) will be provoked.
@@ -137,7 +174,7 @@ var (
 	statusOK         = 200
 	defaultTraceAPI  = "/otel/v1/trace"
 	defaultMetricAPI = "/otel/v1/metric"
-	afterGatherRun   itrace.AfterGatherHandler
+	afterGatherRun   *itrace.AfterGather
 	ignoreTags       []*regexp.Regexp
 	getAttribute     getAttributeFunc
 	tags             map[string]string
@@ -155,12 +192,20 @@ type httpConfig struct {
 	TraceAPI     string `toml:"trace_api" json:"trace_api"`
 	MetricAPI    string `toml:"metric_api" json:"metric_api"`
 	LogsAPI      string `toml:"logs_api" json:"logs_api"`
+
+	BearerTokens []string `toml:"bearer_tokens" json:"bearer_tokens"`
+
+	// CA certificates to verify client certificates on HTTP APIs.
+	ClientCaCerts []string `toml:"client_ca_certs" json:"client_ca_certs"`
 }
 
 type grpcConfig struct {
 	TraceEnabled  bool   `toml:"trace_enable" json:"trace_enable"`
 	MetricEnabled bool   `toml:"metric_enable" json:"metric_enable"`
 	Address       string `toml:"addr" json:"addr"`
+
+	BearerTokens []string   `toml:"bearer_tokens" json:"bearer_tokens"`
+	TLS          *tlsConfig `toml:"tls" json:"tls"`
 }
 
 type Input struct {
@@ -178,9 +223,11 @@ type Input struct {
 	CloseResource       map[string][]string          `toml:"close_resource"`
 	OmitErrStatus       []string                     `toml:"omit_err_status"`
 	Sampler             *itrace.Sampler              `toml:"sampler"`
+	Topology            *itrace.Topology             `toml:"topology"`
 	Tags                map[string]string            `toml:"tags"`
 	WPConfig            *workerpool.WorkerPoolConfig `toml:"threads"`
 	LocalCacheConfig    *storage.StorageConfig       `toml:"storage"`
+	BackPressure        *backPressureConfig          `toml:"back_pressure"`
 
 	feeder  dkio.Feeder
 	semStop *cliutils.Sem // start stop signal
@@ -194,7 +241,7 @@ func (*Input) AvailableArchs() []string { return datakit.AllOS }
 func (*Input) SampleConfig() string { return sampleConfig }
 
 func (*Input) SampleMeasurement() []inputs.Measurement {
-	return []inputs.Measurement{&Measurement{}, &itrace.TraceMeasurement{}}
+	return []inputs.Measurement{&Measurement{}, &itrace.TraceMeasurement{}, &itrace.TopologyMeasurement{}}
 }
 
 func (ipt *Input) RegHTTPHandler() {
@@ -216,6 +263,7 @@ func (ipt *Input) RegHTTPHandler() {
 	convertToDD = ipt.CompatibleDDTrace
 	convertToZhaoShang = ipt.CompatibleZhaoShang
 	getAttribute = getAttrWrapper(ignoreTags)
+	setupBackPressure(ipt.BackPressure)
 
 	var err error
 	if ipt.WPConfig != nil {
@@ -282,6 +330,12 @@ func (ipt *Input) RegHTTPHandler() {
 	}
 	afterGatherRun = afterGather
 
+	// add service topology
+	if ipt.Topology != nil {
+		afterGather.SetTopology(ipt.Topology.Init(inputName, ipt.feeder, log))
+		ipt.Topology.Start()
+	}
+
 	// add filters: the order of appending filters into AfterGather is important!!!
 	// the order of appending represents the order of that filter executes.
 	// add close resource filter
@@ -324,17 +378,35 @@ func (ipt *Input) RegHTTPHandler() {
 		ipt.HTTPConfig.MetricAPI = defaultMetricAPI
 	}
 
+	auth, err := newHTTPAuth(ipt.HTTPConfig)
+	if err != nil {
+		log.Errorf("### opentelemetry HTTP auth config invalid: %s, HTTP APIs not registered", err.Error())
+
+		return
+	}
+
 	statusOK = ipt.HTTPConfig.StatusCodeOK
+
+	traceHandler := handleOTELTrace
+	if (wkpool == nil) && (localCache == nil || !localCache.Enabled()) {
+		// trace request handled synchronously, so the busy response can be sent to client.
+		traceHandler = func(resp http.ResponseWriter, req *http.Request) {
+			doHandleOTELTrace(resp, req, feedOptions())
+		}
+	}
+
 	httpapi.RegHTTPHandler("POST", ipt.HTTPConfig.TraceAPI,
-		httpapi.CheckExpectedHeaders(
+		auth.httpWrapper(httpapi.CheckExpectedHeaders(
 			workerpool.HTTPWrapper(httpStatusRespFunc, wkpool,
-				httpapi.HTTPStorageWrapper(storage.HTTP_KEY, httpStatusRespFunc, localCache, handleOTELTrace)), log, expectedHeaders))
+				httpapi.HTTPStorageWrapper(storage.HTTP_KEY, httpStatusRespFunc, localCache, traceHandler)), log, expectedHeaders)))
 
 	log.Infof("### register handler for %s of agent %s", ipt.HTTPConfig.MetricAPI, inputName)
 
 	iptGlobal = ipt
-	httpapi.RegHTTPHandler("POST", ipt.HTTPConfig.MetricAPI, httpapi.CheckExpectedHeaders(handleOTElMetrics, log, expectedHeaders))
-	httpapi.RegHTTPHandler("POST", ipt.HTTPConfig.LogsAPI, httpapi.CheckExpectedHeaders(handleOTELLogging, log, expectedHeaders))
+	httpapi.RegHTTPHandler("POST", ipt.HTTPConfig.MetricAPI,
+		auth.httpWrapper(httpapi.CheckExpectedHeaders(handleOTElMetrics, log, expectedHeaders)))
+	httpapi.RegHTTPHandler("POST", ipt.HTTPConfig.LogsAPI,
+		auth.httpWrapper(httpapi.CheckExpectedHeaders(handleOTELLogging, log, expectedHeaders)))
 }
 
 func (ipt *Input) Run() {
@@ -373,6 +445,9 @@ func (ipt *Input) exit() {
 	if otelSvr != nil {
 		otelSvr.GracefulStop()
 	}
+	if ipt.Topology != nil {
+		ipt.Topology.Stop()
+	}
 }
 
 func (ipt *Input) Terminate() {
@@ -16,11 +16,18 @@ import (
 	metrics "github.com/GuanceCloud/tracing-protos/opentelemetry-gen-go/metrics/v1"
 )
 
-func parseResourceMetricsV2(resmcs []*metrics.ResourceMetrics) []*point.Point {
-	var pts []*point.Point
+// parseResourceMetricsV2 convert OTLP metrics to points, dataPoints[i] is
+// the index of OTLP data point that pts[i] converted from.
+func parseResourceMetricsV2(resmcs []*metrics.ResourceMetrics) (pts []*point.Point, dataPoints []int) {
+	dp := -1 // index of current OTLP data point
+	add := func(pt *point.Point) {
+		pts = append(pts, pt)
+		dataPoints = append(dataPoints, dp)
+	}
+
 	for _, resmc := range resmcs {
 		if resmc.GetResource() == nil {
-			return pts
+			return pts, dataPoints
 		}
 		resourceTags := attributesToTag(resmc.Resource.GetAttributes())
 		resourceTags["schema_url"] = resmc.GetSchemaUrl()
@@ -39,27 +46,28 @@ func parseResourceMetricsV2(resmcs []*metrics.ResourceMetrics) []*point.Point {
 						ptTags := attributesToTag(dataPoint.GetAttributes())
 						kvs := mergeTags(resourceTags, scopeTags, ptTags)
 						kvs = kvs.AddTag("unit", metric.GetUnit())
-						pt := numberDataToPoint(kvs, dataPoint, metric.GetName())
-						pts = append(pts, pt)
+						dp++
+						add(numberDataToPoint(kvs, dataPoint, metric.GetName()))
 					}
 				case *metrics.Metric_Sum:
 					for _, dataPoint := range t.Sum.GetDataPoints() {
 						ptTags := attributesToTag(dataPoint.GetAttributes())
 						kvs := mergeTags(resourceTags, scopeTags, ptTags)
 						kvs = kvs.AddTag("unit", metric.GetUnit())
-						pt := numberDataToPoint(kvs, dataPoint, metric.GetName())
-						pts = append(pts, pt)
+						dp++
+						add(numberDataToPoint(kvs, dataPoint, metric.GetName()))
 					}
 				case *metrics.Metric_Summary:
 					for _, dataPoint := range t.Summary.GetDataPoints() {
 						ptTags := attributesToTag(dataPoint.GetAttributes())
 						kvs := mergeTags(resourceTags, scopeTags, ptTags)
 						kvs = kvs.AddTag("unit", metric.GetUnit())
-						pt := summaryToPoint(kvs, dataPoint, metric.GetName())
-						pts = append(pts, pt)
+						dp++
+						add(summaryToPoint(kvs, dataPoint, metric.GetName()))
 					}
 				case *metrics.Metric_Histogram:
 					for _, his := range t.Histogram.GetDataPoints() {
+						dp++
 						hisTags := attributesToTag(his.GetAttributes())
 						kvs := mergeTags(resourceTags, scopeTags, hisTags)
 
@@ -76,7 +84,7 @@ func parseResourceMetricsV2(resmcs []*metrics.ResourceMetrics) []*point.Point {
 						ts := time.Unix(0, int64(his.GetTimeUnixNano()))
 						opts := point.DefaultMetricOptions()
 						opts = append(opts, point.WithTime(ts))
-						pts = append(pts, point.NewPointV2("otel-service", kvs, opts...))
+						add(point.NewPointV2("otel-service", kvs, opts...))
 						// bucket
 						if len(his.GetBucketCounts()) > 1 && len(his.GetExplicitBounds()) > 0 {
 							for i, bucket := range his.BucketCounts {
@@ -87,18 +95,19 @@ func parseResourceMetricsV2(resmcs []*metrics.ResourceMetrics) []*point.Point {
 									bKvs := mergeTags(resourceTags, scopeTags, hisTags)
 									bKvs = bKvs.Add(metric.Name+"_bucket", bucket, false, false).
 										AddTag("le", strconv.Itoa(int(his.ExplicitBounds[i])))
-									pts = append(pts, point.NewPointV2("otel-service", bKvs, opts...))
+									add(point.NewPointV2("otel-service", bKvs, opts...))
 								} else {
 									bKvs := mergeTags(resourceTags, scopeTags, hisTags)
 									bKvs = bKvs.Add(metric.Name+"_bucket", bucket, false, false).
 										AddTag("le", "inf")
-									pts = append(pts, point.NewPointV2("otel-service", bKvs, opts...))
+									add(point.NewPointV2("otel-service", bKvs, opts...))
 								}
 							}
 						}
 					}
 				case *metrics.Metric_ExponentialHistogram:
 					for _, his := range t.ExponentialHistogram.GetDataPoints() {
+						dp++
 						hisTags := attributesToTag(his.GetAttributes())
 						kvs := mergeTags(resourceTags, scopeTags, hisTags)
 
@@ -114,14 +123,38 @@ func parseResourceMetricsV2(resmcs []*metrics.ResourceMetrics) []*point.Point {
 						ts := time.Unix(0, int64(his.GetTimeUnixNano()))
 						opts := point.DefaultMetricOptions()
 						opts = append(opts, point.WithTime(ts))
-						pts = append(pts, point.NewPointV2("otel-service", kvs, opts...))
+						add(point.NewPointV2("otel-service", kvs, opts...))
 					}
 				}
 			}
 		}
 	}
 
-	return pts
+	return pts, dataPoints
+}
+
+// countDataPoints count OTLP data points within resmcs.
+func countDataPoints(resmcs []*metrics.ResourceMetrics) (n int) {
+	for _, resmc := range resmcs {
+		for _, scopeMetrics := range resmc.GetScopeMetrics() {
+			for _, metric := range scopeMetrics.GetMetrics() {
+				switch t := metric.Data.(type) {
+				case *metrics.Metric_Gauge:
+					n += len(t.Gauge.GetDataPoints())
+				case *metrics.Metric_Sum:
+					n += len(t.Sum.GetDataPoints())
+				case *metrics.Metric_Summary:
+					n += len(t.Summary.GetDataPoints())
+				case *metrics.Metric_Histogram:
+					n += len(t.Histogram.GetDataPoints())
+				case *metrics.Metric_ExponentialHistogram:
+					n += len(t.ExponentialHistogram.GetDataPoints())
+				}
+			}
+		}
+	}
+
+	return n
 }
 
 func attributesToTag(src []*common.KeyValue) map[string]string {
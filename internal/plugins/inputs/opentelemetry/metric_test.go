@@ -163,7 +163,7 @@ func Test_parseResourceMetricsV2(t *testing.T) {
 		},
 	}
 
-	pts := parseResourceMetricsV2(msource)
+	pts, _ := parseResourceMetricsV2(msource)
 	if len(pts) == 0 {
 		t.Errorf("parse otel metric to point.len==0")
 	} else {
@@ -21,6 +21,13 @@ import (
 )
 
 func runGRPCV1(addr string, ipt *Input) {
+	opts, err := ipt.GRPCConfig.grpcServerOptions()
+	if err != nil {
+		log.Errorf("### opentelemetry grpc server v1 auth config invalid: %s", err.Error())
+
+		return
+	}
+
 	listener, err := net.Listen("tcp", addr)
 	if err != nil {
 		log.Errorf("### opentelemetry grpc server v1 listening on %s failed: %v", addr, err.Error())
@@ -29,7 +36,7 @@ func runGRPCV1(addr string, ipt *Input) {
 	}
 	log.Debugf("### opentelemetry grpc v1 listening on: %s", addr)
 
-	otelSvr = grpc.NewServer(itrace.DefaultGRPCServerOpts...)
+	otelSvr = grpc.NewServer(append(opts, itrace.DefaultGRPCServerOpts...)...)
 	trace.RegisterTraceServiceServer(otelSvr, &TraceServiceServer{})
 	metrics.RegisterMetricsServiceServer(otelSvr, &MetricsServiceServer{Ipt: ipt})
 	logs.RegisterLogsServiceServer(otelSvr, &LogsServiceServer{Ipt: ipt})
@@ -48,13 +55,29 @@ type TraceServiceServer struct {
 func (tss *TraceServiceServer) Export(ctx context.Context, tsreq *trace.ExportTraceServiceRequest) (
 	*trace.ExportTraceServiceResponse, error,
 ) {
-	if afterGatherRun != nil {
-		if dktraces := parseResourceSpans(tsreq.ResourceSpans); len(dktraces) != 0 {
-			afterGatherRun.Run(inputName, dktraces)
+	resp := &trace.ExportTraceServiceResponse{}
+	if afterGatherRun == nil {
+		return resp, nil
+	}
+
+	dktraces := parseResourceSpans(tsreq.ResourceSpans)
+	if len(dktraces) == 0 {
+		return resp, nil
+	}
+
+	rejected, err := afterGatherRun.RunWithResult(inputName, dktraces, feedOptions()...)
+	if err != nil && isBusy(err) {
+		return nil, grpcBusyError()
+	}
+
+	if rejected > 0 {
+		resp.PartialSuccess = &trace.ExportTracePartialSuccess{
+			RejectedSpans: int64(rejected),
+			ErrorMessage:  rejectedMessage(rejected, err),
 		}
 	}
 
-	return &trace.ExportTraceServiceResponse{}, nil
+	return resp, nil
 }
 
 type MetricsServiceServer struct {
@@ -65,18 +88,28 @@ type MetricsServiceServer struct {
 func (mss *MetricsServiceServer) Export(ctx context.Context, msreq *metrics.ExportMetricsServiceRequest) (
 	*metrics.ExportMetricsServiceResponse, error,
 ) {
+	resp := &metrics.ExportMetricsServiceResponse{}
+
 	start := time.Now()
-	points := parseResourceMetricsV2(msreq.ResourceMetrics)
-	if len(points) != 0 {
-		if err := mss.Ipt.feeder.FeedV2(point.Metric, points,
-			dkio.WithInputName(inputName),
-			dkio.WithCollectCost(time.Since(start)),
-		); err != nil {
-			log.Error(err.Error())
+	points, dataPoints := parseResourceMetricsV2(msreq.ResourceMetrics)
+	if len(points) == 0 {
+		return resp, nil
+	}
+
+	rejected, err := feedMetricPoints(mss.Ipt.feeder, points, dataPoints, countDataPoints(msreq.ResourceMetrics),
+		dkio.WithCollectCost(time.Since(start)))
+	if err != nil && isBusy(err) {
+		return nil, grpcBusyError()
+	}
+
+	if rejected > 0 {
+		resp.PartialSuccess = &metrics.ExportMetricsPartialSuccess{
+			RejectedDataPoints: int64(rejected),
+			ErrorMessage:       rejectedMessage(rejected, err),
 		}
 	}
 
-	return &metrics.ExportMetricsServiceResponse{}, nil
+	return resp, nil
 }
 
 type LogsServiceServer struct {
@@ -85,20 +118,28 @@ type LogsServiceServer struct {
 }
 
 func (l *LogsServiceServer) Export(ctx context.Context, logsReq *logs.ExportLogsServiceRequest) (out *logs.ExportLogsServiceResponse, err error) {
+	out = &logs.ExportLogsServiceResponse{}
 	if logsReq == nil || len(logsReq.GetResourceLogs()) == 0 {
-		return
+		return out, nil
 	}
+
 	start := time.Now()
 	pts := ParseLogsRequest(logsReq.GetResourceLogs())
-	if len(pts) != 0 {
-		if err := l.Ipt.feeder.FeedV2(point.Logging, pts,
-			dkio.WithInputName(inputName),
-			dkio.WithCollectCost(time.Since(start)),
-		); err != nil {
-			log.Error(err.Error())
+	if len(pts) == 0 {
+		return out, nil
+	}
+
+	rejected, err := feedPoints(l.Ipt.feeder, point.Logging, pts, dkio.WithCollectCost(time.Since(start)))
+	if err != nil && isBusy(err) {
+		return nil, grpcBusyError()
+	}
+
+	if rejected > 0 {
+		out.PartialSuccess = &logs.ExportLogsPartialSuccess{
+			RejectedLogRecords: int64(rejected),
+			ErrorMessage:       rejectedMessage(rejected, err),
 		}
 	}
-	out = &logs.ExportLogsServiceResponse{PartialSuccess: &logs.ExportLogsPartialSuccess{}}
 
 	return out, nil
 }
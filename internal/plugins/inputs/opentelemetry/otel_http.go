@@ -38,7 +38,38 @@ func httpStatusRespFunc(resp http.ResponseWriter, req *http.Request, err error)
 	resp.Write(buf) //nolint:gosec,errcheck
 }
 
+// writeHTTPResponse write OTLP export response in the request's content type.
+func writeHTTPResponse(resp http.ResponseWriter, media string, msg proto.Message) {
+	var (
+		buf []byte
+		err error
+	)
+
+	if media == "application/json" {
+		buf, err = protojson.Marshal(msg)
+	} else {
+		buf, err = proto.Marshal(msg)
+	}
+
+	if err != nil {
+		log.Error(err.Error())
+		resp.WriteHeader(http.StatusInternalServerError)
+
+		return
+	}
+
+	resp.Header().Set("Content-Type", media)
+	resp.WriteHeader(statusOK)
+	resp.Write(buf) //nolint:gosec,errcheck
+}
+
 func handleOTELTrace(resp http.ResponseWriter, req *http.Request) {
+	doHandleOTELTrace(resp, req, nil)
+}
+
+// doHandleOTELTrace handle trace request, feed options used only if the request is
+// handled synchronously(no worker-pool or local cache).
+func doHandleOTELTrace(resp http.ResponseWriter, req *http.Request, opts []dkio.FeedOption) {
 	media, _, buf, err := itrace.ParseTracerRequest(req)
 	if err != nil {
 		log.Error(err.Error())
@@ -66,11 +97,26 @@ func handleOTELTrace(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	out := &trace.ExportTraceServiceResponse{}
 	if afterGatherRun != nil {
 		if dktraces := parseResourceSpans(tsreq.ResourceSpans); len(dktraces) != 0 {
-			afterGatherRun.Run(inputName, dktraces)
+			rejected, err := afterGatherRun.RunWithResult(inputName, dktraces, opts...)
+			if err != nil && isBusy(err) {
+				writeHTTPBusy(resp)
+
+				return
+			}
+
+			if rejected > 0 {
+				out.PartialSuccess = &trace.ExportTracePartialSuccess{
+					RejectedSpans: int64(rejected),
+					ErrorMessage:  rejectedMessage(rejected, err),
+				}
+			}
 		}
 	}
+
+	writeHTTPResponse(resp, media, out)
 }
 
 func handleOTElMetrics(resp http.ResponseWriter, req *http.Request) {
@@ -101,15 +147,24 @@ func handleOTElMetrics(resp http.ResponseWriter, req *http.Request) {
 		return
 	}
 
-	points := parseResourceMetricsV2(msreq.ResourceMetrics)
+	out := &metrics.ExportMetricsServiceResponse{}
+	if points, dataPoints := parseResourceMetricsV2(msreq.ResourceMetrics); len(points) != 0 {
+		rejected, err := feedMetricPoints(iptGlobal.feeder, points, dataPoints, countDataPoints(msreq.ResourceMetrics))
+		if err != nil && isBusy(err) {
+			writeHTTPBusy(resp)
+
+			return
+		}
 
-	if len(points) != 0 {
-		if err := iptGlobal.feeder.FeedV2(point.Metric, points,
-			dkio.WithInputName(inputName),
-		); err != nil {
-			log.Error(err.Error())
+		if rejected > 0 {
+			out.PartialSuccess = &metrics.ExportMetricsPartialSuccess{
+				RejectedDataPoints: int64(rejected),
+				ErrorMessage:       rejectedMessage(rejected, err),
+			}
 		}
 	}
+
+	writeHTTPResponse(resp, media, out)
 }
 
 func handleOTELLogging(resp http.ResponseWriter, req *http.Request) {
@@ -138,10 +193,23 @@ func handleOTELLogging(resp http.ResponseWriter, req *http.Request) {
 
 		return
 	}
-	pts := ParseLogsRequest(otelLogs.GetResourceLogs())
-	if len(pts) > 0 {
-		if err = iptGlobal.feeder.FeedV2(point.Logging, pts, dkio.WithInputName(inputName)); err != nil {
-			log.Errorf("feed logging to io err=%v", err)
+
+	out := &logs.ExportLogsServiceResponse{}
+	if pts := ParseLogsRequest(otelLogs.GetResourceLogs()); len(pts) > 0 {
+		rejected, err := feedPoints(iptGlobal.feeder, point.Logging, pts)
+		if err != nil && isBusy(err) {
+			writeHTTPBusy(resp)
+
+			return
+		}
+
+		if rejected > 0 {
+			out.PartialSuccess = &logs.ExportLogsPartialSuccess{
+				RejectedLogRecords: int64(rejected),
+				ErrorMessage:       rejectedMessage(rejected, err),
+			}
 		}
 	}
+
+	writeHTTPResponse(resp, media, out)
 }
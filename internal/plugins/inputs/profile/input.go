@@ -42,6 +42,7 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/profile/metrics"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/rum"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/trace"
+	"go.uber.org/atomic"
 )
 
 const (
@@ -86,6 +87,18 @@ const (
   #   send_timeout = "75s"
   #   send_retry_count = 4
 
+  ## function_metrics generates top N self/total CPU and allocation metrics of functions
+  ## from profiling data, and compares each profile with the previous one of the same
+  ## service to find regressions(logged as profiling_regression).
+  ## min_percent ignores functions whose self share(%) of the profile is below it.
+  ## regression_threshold is the increment(%) of self share to be reported as regression.
+  # [inputs.profile.function_metrics]
+  #   enable = false
+  #   top_n = 10
+  #   min_percent = 1.0
+  #   diff = true
+  #   regression_threshold = 40.0
+
   ## set custom tags for profiling data
   # [inputs.profile.tags]
   #   some_tag = "some_value"
@@ -116,6 +129,73 @@ const (
 #[inputs.profile.go.tags]
   # tag1 = "val1"
 
+## JVM profiling in pull mode, by attaching async-profiler(mode "async_profiler")
+## or recording JFR over JMX exposed by Jolokia(mode "jmx")
+#[[inputs.profile.jvm]]
+  #mode = "async_profiler"
+
+  ## async-profiler launcher, asprof(or profiler.sh before async-profiler 3.0)
+  #asprof_path = "/usr/local/async-profiler/bin/asprof"
+
+  ## target JVM, set pid or a regular expression to match the command line
+  #pid = 0
+  #process_name = 'java .*demo\.jar'
+
+  ## async-profiler event: cpu, alloc, lock, wall, itimer
+  #event = "cpu"
+
+  ## Jolokia agent url and JFR settings(default or profile) for mode jmx
+  #jolokia_url = "http://localhost:8778/jolokia"
+  #username = ""
+  #password = ""
+  #jfr_settings = "profile"
+
+  ## pull interval, and how long each profiling lasts
+  #interval = "60s"
+  #duration = "10s"
+
+  #service = "java-demo"
+  #env = "dev"
+  #version = "0.0.0"
+
+#[inputs.profile.jvm.tags]
+  # tag1 = "val1"
+
+## Node.js CPU profiling in pull mode through inspector protocol,
+## the process should be started with --inspect
+#[[inputs.profile.nodejs]]
+  #inspector_url = "http://127.0.0.1:9229"
+  #sampling_interval = "10ms"
+  #interval = "60s"
+  #duration = "10s"
+  #service = "nodejs-demo"
+  #env = "dev"
+  #version = "0.0.0"
+
+#[inputs.profile.nodejs.tags]
+  # tag1 = "val1"
+
+## Python CPU profiling in pull mode by py-spy
+#[[inputs.profile.python]]
+  #py_spy_path = "/usr/local/bin/py-spy"
+
+  ## target process, set pid or a regular expression to match the command line
+  #pid = 0
+  #process_name = 'python .*app\.py'
+
+  ## samples per second
+  #rate = 100
+  #native = false
+  #nonblocking = false
+  #interval = "60s"
+  #duration = "10s"
+  #service = "python-demo"
+  #env = "dev"
+  #version = "0.0.0"
+
+#[inputs.profile.python.tags]
+  # tag1 = "val1"
+
 ## pyroscope config
 #[[inputs.profile.pyroscope]]
   ## listen url
@@ -329,6 +409,7 @@ func DefaultInput() *Input {
 			SendRetryCount:    defaultHTTPRetryCount,
 		},
 		GenerateMetrics: true,
+		FunctionMetrics: metrics.DefaultFunctionMetricsConfig(),
 		pauseCh:         make(chan bool, inputs.ElectionPauseChannelLength),
 		Election:        true,
 		semStop:         cliutils.NewSem(),
@@ -345,18 +426,24 @@ func init() { //nolint:gochecknoinits
 }
 
 type Input struct {
-	Endpoints       []string          `toml:"endpoints"`
-	BodySizeLimitMB int               `toml:"body_size_limit_mb"`
-	IOConfig        ioConfig          `toml:"io_config"`
-	Tags            map[string]string `toml:"tags"`
-	Go              []*GoProfiler     `toml:"go"`
-	PyroscopeLists  []*pyroscopeOpts  `toml:"pyroscope"`
-	Election        bool              `toml:"election"`
-	GenerateMetrics bool              `toml:"generate_metrics"`
-
-	pause   bool
+	Endpoints       []string                       `toml:"endpoints"`
+	BodySizeLimitMB int                            `toml:"body_size_limit_mb"`
+	IOConfig        ioConfig                       `toml:"io_config"`
+	Tags            map[string]string              `toml:"tags"`
+	Go              []*GoProfiler                  `toml:"go"`
+	JVM             []*JVMProfiler                 `toml:"jvm"`
+	NodeJS          []*NodeJSProfiler              `toml:"nodejs"`
+	Python          []*PythonProfiler              `toml:"python"`
+	PyroscopeLists  []*pyroscopeOpts               `toml:"pyroscope"`
+	Election        bool                           `toml:"election"`
+	GenerateMetrics bool                           `toml:"generate_metrics"`
+	FunctionMetrics *metrics.FunctionMetricsConfig `toml:"function_metrics"`
+
+	pause   atomic.Bool
 	pauseCh chan bool
 
+	functionAnalyzer *metrics.FunctionAnalyzer
+
 	profileSendingAPI *url.URL
 	httpClient        *http.Client
 
@@ -589,6 +676,10 @@ func (ipt *Input) sendRequestToDW(ctx context.Context, pbBytes []byte) error {
 				log.Errorf("unable to export python ddtrace profiling metrics: %v", err)
 			}
 		}
+
+		if err = ipt.functionAnalyzer.Export(req.MultipartForm.File, metadata, allCustomTags, language); err != nil {
+			log.Errorf("unable to export profiling function metrics: %v", err)
+		}
 	}
 
 	customTagsDefined := false
@@ -820,6 +911,7 @@ func (ipt *Input) Run() {
 	log.Infof("the input %s is running...", inputName)
 
 	metrics.InitLog()
+	ipt.functionAnalyzer = metrics.NewFunctionAnalyzer(ipt.FunctionMetrics)
 
 	if err := ipt.InitDiskQueueIO(); err != nil {
 		log.Errorf("unable to start IO process for profiling: %s", err)
@@ -841,6 +933,46 @@ func (ipt *Input) Run() {
 		},
 	})
 
+	// all pull mode profilers share the election state
+	groupPull.Go(func(ctx context.Context) error {
+		for {
+			select {
+			case <-datakit.Exit.Wait():
+				return nil
+			case <-ipt.semStop.Wait():
+				return nil
+			case pause := <-ipt.pauseCh:
+				ipt.pause.Store(pause)
+			}
+		}
+	})
+
+	type puller interface {
+		run(*Input) error
+	}
+
+	var pullers []puller
+	for _, x := range ipt.JVM {
+		pullers = append(pullers, x)
+	}
+	for _, x := range ipt.NodeJS {
+		pullers = append(pullers, x)
+	}
+	for _, x := range ipt.Python {
+		pullers = append(pullers, x)
+	}
+
+	for _, p := range pullers {
+		func(p puller) {
+			groupPull.Go(func(ctx context.Context) error {
+				if err := p.run(ipt); err != nil {
+					log.Errorf("profile-pull-mode collect error: %s", err.Error())
+				}
+				return nil
+			})
+		}(p)
+	}
+
 	for _, g := range ipt.Go {
 		func(g *GoProfiler) {
 			groupPull.Go(func(ctx context.Context) error {
@@ -877,7 +1009,11 @@ func (ipt *Input) SampleConfig() string {
 }
 
 func (ipt *Input) SampleMeasurement() []inputs.Measurement {
-	return []inputs.Measurement{&trace.TraceMeasurement{Name: inputName}}
+	return []inputs.Measurement{
+		&trace.TraceMeasurement{Name: inputName},
+		&functionMeasurement{},
+		&regressionMeasurement{},
+	}
 }
 
 func (ipt *Input) AvailableArchs() []string {
@@ -0,0 +1,67 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"github.com/GuanceCloud/cliutils/point"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/profile/metrics"
+)
+
+var functionTags = map[string]interface{}{
+	"language": inputs.TagInfo{Desc: "Language of the profiled application"},
+	"host":     inputs.TagInfo{Desc: "Hostname of the profiled application"},
+	"service":  inputs.TagInfo{Desc: "Service name"},
+	"env":      inputs.TagInfo{Desc: "Service env"},
+	"version":  inputs.TagInfo{Desc: "Service version"},
+	"type":     inputs.TagInfo{Desc: "Resource type, `cpu`(in nanoseconds) or `alloc`(in bytes)"},
+	"function": inputs.TagInfo{Desc: "Function name"},
+	"file":     inputs.TagInfo{Desc: "Source file of the function, absent for JFR"},
+}
+
+type functionMeasurement struct{}
+
+//nolint:lll
+func (m *functionMeasurement) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: metrics.FunctionMetricsName,
+		Type: point.Metric.String(),
+		Desc: "Top N functions of each profile by self and total, enabled by `function_metrics`",
+		Tags: functionTags,
+		Fields: map[string]interface{}{
+			"self":          &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "CPU time(ns) or allocated bytes of the function itself"},
+			"total":         &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Int, Unit: inputs.UnknownUnit, Desc: "CPU time(ns) or allocated bytes of the function and its callees"},
+			"self_percent":  &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Float, Unit: inputs.Percent, Desc: "Share of `self` in the profile"},
+			"total_percent": &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Float, Unit: inputs.Percent, Desc: "Share of `total` in the profile"},
+		},
+	}
+}
+
+type regressionMeasurement struct{}
+
+//nolint:lll
+func (m *regressionMeasurement) Info() *inputs.MeasurementInfo {
+	tags := map[string]interface{}{
+		"previous_version": inputs.TagInfo{Desc: "Service version of the previous profile"},
+		"status":           inputs.TagInfo{Desc: "Log status, always `warning`"},
+	}
+	for k, v := range functionTags {
+		tags[k] = v
+	}
+
+	return &inputs.MeasurementInfo{
+		Name: metrics.RegressionLogName,
+		Type: point.Logging.String(),
+		Desc: "Functions whose self share increased over `regression_threshold` compared with the previous profile of the same service",
+		Tags: tags,
+		Fields: map[string]interface{}{
+			"message":               &inputs.FieldInfo{Type: inputs.UnknownType, DataType: inputs.String, Unit: inputs.UnknownUnit, Desc: "Regression description"},
+			"self_percent":          &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Float, Unit: inputs.Percent, Desc: "Self share of the function in current profile"},
+			"previous_self_percent": &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Float, Unit: inputs.Percent, Desc: "Self share of the function in previous profile"},
+			"change_percent":        &inputs.FieldInfo{Type: inputs.Gauge, DataType: inputs.Float, Unit: inputs.Percent, Desc: "Increment of the self share"},
+		},
+	}
+}
@@ -0,0 +1,625 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"mime/multipart"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/GuanceCloud/cliutils/pprofparser/domain/quantity"
+	"github.com/GuanceCloud/cliutils/pprofparser/service/parsing"
+	"github.com/google/pprof/profile"
+	"github.com/grafana/jfr-parser/common/attributes"
+	"github.com/grafana/jfr-parser/common/filters"
+	"github.com/grafana/jfr-parser/common/types"
+	"github.com/grafana/jfr-parser/common/units"
+	"github.com/grafana/jfr-parser/parser"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+const (
+	FunctionMetricsName = "profiling_function"
+	RegressionLogName   = "profiling_regression"
+
+	defaultFunctionTopN        = 10
+	defaultFunctionMinPercent  = 1.0
+	defaultRegressionThreshold = 40.0
+	maxDiffSeries              = 1024
+)
+
+// FunctionKind is the kind of resource consumed by functions.
+type FunctionKind string
+
+const (
+	FunctionCPU   FunctionKind = "cpu"   // nanoseconds
+	FunctionAlloc FunctionKind = "alloc" // bytes
+)
+
+// sample types which measure CPU time or allocated bytes, named by
+// different profilers.
+var (
+	cpuSampleTypes   = map[string]bool{"cpu": true, cpuTimeMetric: true}
+	allocSampleTypes = map[string]bool{"alloc_space": true, allocSpaceMetric: true}
+
+	// jfrAllocationSize is the allocation size of jdk.ObjectAllocationInNewTLAB
+	// and jdk.ObjectAllocationOutsideTLAB.
+	jfrAllocationSize = attributes.AttrSimple[units.IQuantity]("allocationSize", types.Long)
+)
+
+// FunctionMetricsConfig configure per-function metrics and regression detection.
+type FunctionMetricsConfig struct {
+	Enable              bool    `toml:"enable"`
+	TopN                int     `toml:"top_n"`
+	MinPercent          float64 `toml:"min_percent"`
+	Diff                bool    `toml:"diff"`
+	RegressionThreshold float64 `toml:"regression_threshold"`
+}
+
+// DefaultFunctionMetricsConfig returns the default config, which is disabled.
+func DefaultFunctionMetricsConfig() *FunctionMetricsConfig {
+	return &FunctionMetricsConfig{
+		TopN:                defaultFunctionTopN,
+		MinPercent:          defaultFunctionMinPercent,
+		Diff:                true,
+		RegressionThreshold: defaultRegressionThreshold,
+	}
+}
+
+type functionStat struct {
+	name  string
+	file  string
+	self  int64
+	total int64
+}
+
+func (s *functionStat) key() string {
+	return s.name + "|" + s.file
+}
+
+// functionStats is the self and total value of each function, of one kind.
+type functionStats struct {
+	funcs map[string]*functionStat
+	sum   int64 // sum of all samples
+}
+
+func newFunctionStats() *functionStats {
+	return &functionStats{funcs: map[string]*functionStat{}}
+}
+
+// add a sample, stack is leaf first.
+func (fs *functionStats) add(stack []*functionStat, value int64) {
+	if len(stack) == 0 || value <= 0 {
+		return
+	}
+
+	fs.sum += value
+	seen := make(map[string]bool, len(stack))
+
+	for i, frame := range stack {
+		k := frame.key()
+		st, ok := fs.funcs[k]
+		if !ok {
+			st = &functionStat{name: frame.name, file: frame.file}
+			fs.funcs[k] = st
+		}
+
+		if i == 0 {
+			st.self += value
+		}
+
+		// recursive function counted once
+		if !seen[k] {
+			seen[k] = true
+			st.total += value
+		}
+	}
+}
+
+func (fs *functionStats) selfPercent(st *functionStat) float64 {
+	if fs.sum == 0 {
+		return 0
+	}
+	return float64(st.self) * 100 / float64(fs.sum)
+}
+
+func (fs *functionStats) totalPercent(st *functionStat) float64 {
+	if fs.sum == 0 {
+		return 0
+	}
+	return float64(st.total) * 100 / float64(fs.sum)
+}
+
+// top returns the union of top n functions by self and by total.
+func (fs *functionStats) top(n int) []*functionStat {
+	all := make([]*functionStat, 0, len(fs.funcs))
+	for _, st := range fs.funcs {
+		all = append(all, st)
+	}
+
+	if n <= 0 || n >= len(all) {
+		sort.Slice(all, func(i, j int) bool { return lessStat(all[i], all[j], true) })
+		return all
+	}
+
+	picked := map[string]bool{}
+	var res []*functionStat
+
+	for _, bySelf := range []bool{true, false} {
+		sort.Slice(all, func(i, j int) bool { return lessStat(all[i], all[j], bySelf) })
+		for _, st := range all[:n] {
+			if !picked[st.key()] {
+				picked[st.key()] = true
+				res = append(res, st)
+			}
+		}
+	}
+
+	return res
+}
+
+func lessStat(a, b *functionStat, bySelf bool) bool {
+	x, y := a.total, b.total
+	if bySelf {
+		x, y = a.self, b.self
+	}
+	if x != y {
+		return x > y
+	}
+	return a.key() < b.key()
+}
+
+// pprofFunctionStats resolve CPU and allocation of each function from pprof.
+func pprofFunctionStats(r io.Reader) (map[FunctionKind]*functionStats, error) {
+	prof, err := profile.Parse(parsing.NewDecompressor(r))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse pprof: %w", err)
+	}
+
+	type column struct {
+		kind  FunctionKind
+		index int
+		unit  *quantity.Unit
+		to    *quantity.Unit
+	}
+
+	var columns []column
+	for idx, st := range prof.SampleType {
+		var (
+			kind FunctionKind
+			to   *quantity.Unit
+			unit *quantity.Unit
+		)
+
+		switch {
+		case cpuSampleTypes[st.Type]:
+			kind, to = FunctionCPU, quantity.NanoSecond
+			unit, err = quantity.ParseUnit(quantity.Duration, st.Unit)
+		case allocSampleTypes[st.Type]:
+			kind, to = FunctionAlloc, quantity.Byte
+			unit, err = quantity.ParseUnit(quantity.Memory, st.Unit)
+		default:
+			continue
+		}
+
+		if err != nil {
+			log.Warnf("unable to resolve unit of sample type %q: %v", st.Type, err)
+			continue
+		}
+		columns = append(columns, column{kind: kind, index: idx, unit: unit, to: to})
+	}
+
+	res := make(map[FunctionKind]*functionStats, len(columns))
+	if len(columns) == 0 {
+		return res, nil
+	}
+
+	for _, c := range columns {
+		res[c.kind] = newFunctionStats()
+	}
+
+	for _, sample := range prof.Sample {
+		var stack []*functionStat
+		for _, loc := range sample.Location {
+			for _, line := range loc.Line { // inlined functions first
+				if line.Function == nil {
+					continue
+				}
+				stack = append(stack, &functionStat{name: line.Function.Name, file: line.Function.Filename})
+			}
+		}
+
+		for _, c := range columns {
+			if c.index >= len(sample.Value) {
+				continue
+			}
+
+			v := sample.Value[c.index]
+			if c.unit != c.to {
+				if v, err = c.unit.Quantity(v).IntValueIn(c.to); err != nil {
+					continue
+				}
+			}
+			res[c.kind].add(stack, v)
+		}
+	}
+
+	return res, nil
+}
+
+// jfrFunctionStats resolve CPU and allocation of each Java method from JFR.
+func jfrFunctionStats(r io.Reader) (map[FunctionKind]*functionStats, error) {
+	chunks, err := parser.Parse(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse jfr: %w", err)
+	}
+
+	jc := jfrChunks(chunks)
+	cfg := jc.resolveDDProfilerSetting()
+
+	cpu, alloc := newFunctionStats(), newFunctionStats()
+
+	for _, chunk := range jc {
+		for _, event := range chunk.Apply(filters.FilterExecutionSample) {
+			cpu.add(jfrStack(event), cfg.cpuIntervalNanos)
+		}
+
+		for _, event := range chunk.Apply(filters.DatadogExecutionSample) {
+			weight, err := attributes.SampleWeight.GetValue(event)
+			if err != nil || weight <= 0 {
+				weight = 1
+			}
+			cpu.add(jfrStack(event), weight*cfg.cpuIntervalNanos)
+		}
+
+		for _, event := range chunk.Apply(filters.DatadogAllocationSample) {
+			size, err := attributes.AllocSize.GetValue(event)
+			if err != nil {
+				continue
+			}
+			weight, err := attributes.AllocWeight.GetValue(event)
+			if err != nil || weight <= 0 {
+				weight = 1
+			}
+			if b, err := size.In(units.Byte); err == nil {
+				alloc.add(jfrStack(event), int64(b.FloatValue()*weight))
+			}
+		}
+
+		for _, event := range chunk.Apply(filters.AllocAll) {
+			size, err := jfrAllocationSize.GetValue(event)
+			if err != nil {
+				continue
+			}
+			if b, err := size.In(units.Byte); err == nil {
+				alloc.add(jfrStack(event), b.IntValue())
+			}
+		}
+	}
+
+	res := map[FunctionKind]*functionStats{}
+	if cpu.sum > 0 {
+		res[FunctionCPU] = cpu
+	}
+	if alloc.sum > 0 {
+		res[FunctionAlloc] = alloc
+	}
+	return res, nil
+}
+
+// jfrStack returns stack of the event, leaf first.
+func jfrStack(event *parser.GenericEvent) []*functionStat {
+	st, err := attributes.EventStacktrace.GetValue(event)
+	if err != nil || st == nil {
+		return nil
+	}
+
+	stack := make([]*functionStat, 0, len(st.Frames))
+	for _, frame := range st.Frames {
+		if frame == nil || frame.Method == nil {
+			continue
+		}
+
+		var name, class string
+		if frame.Method.Name != nil {
+			name = frame.Method.Name.String
+		}
+		if frame.Method.Type != nil && frame.Method.Type.Name != nil {
+			class = strings.ReplaceAll(frame.Method.Type.Name.String, "/", ".")
+		}
+		if class != "" {
+			name = class + "." + name
+		}
+
+		stack = append(stack, &functionStat{name: name})
+	}
+	return stack
+}
+
+// profileFunctionStats merge function stats of all profiling files in the upload.
+func profileFunctionStats(files map[string][]*multipart.FileHeader, format Format) map[FunctionKind]*functionStats {
+	res := map[FunctionKind]*functionStats{}
+
+	for field, headers := range files {
+		switch field {
+		case EventFile, EventJSONFile, MetricFile, MetricJSONFile:
+			continue
+		}
+
+		for _, header := range headers {
+			if header.Size == 0 || path.Ext(header.Filename) == ".json" {
+				continue
+			}
+
+			isJFR := format == JFR || path.Ext(header.Filename) == ".jfr" || path.Ext(field) == ".jfr"
+
+			stats, err := func() (map[FunctionKind]*functionStats, error) {
+				f, err := header.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer f.Close() //nolint:errcheck
+
+				if isJFR {
+					return jfrFunctionStats(f)
+				}
+				return pprofFunctionStats(f)
+			}()
+			if err != nil {
+				log.Debugf("unable to resolve function stats from %s: %v", header.Filename, err)
+				continue
+			}
+
+			for kind, fs := range stats {
+				// the first file wins, e.g. cpu.pprof for CPU and delta-heap.pprof for allocation of go
+				if _, ok := res[kind]; !ok && fs.sum > 0 {
+					res[kind] = fs
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+// functionSnapshot is the self percent of each function in previous profile.
+type functionSnapshot struct {
+	version string
+	time    time.Time
+	percent map[string]float64
+	names   map[string]*functionStat
+}
+
+// FunctionAnalyzer generate per-function metrics from uploaded profiles,
+// and compare each profile with the previous one of the same host and service to
+// find regressions.
+type FunctionAnalyzer struct {
+	cfg    *FunctionMetricsConfig
+	feeder dkio.Feeder
+
+	mtx      sync.Mutex
+	previous map[string]*functionSnapshot
+}
+
+// NewFunctionAnalyzer returns nil if not enabled.
+func NewFunctionAnalyzer(cfg *FunctionMetricsConfig) *FunctionAnalyzer {
+	if cfg == nil || !cfg.Enable {
+		return nil
+	}
+
+	if cfg.TopN <= 0 {
+		cfg.TopN = defaultFunctionTopN
+	}
+	if cfg.MinPercent < 0 {
+		cfg.MinPercent = 0
+	}
+	if cfg.RegressionThreshold <= 0 {
+		cfg.RegressionThreshold = defaultRegressionThreshold
+	}
+
+	return &FunctionAnalyzer{
+		cfg:      cfg,
+		feeder:   metricsFeeder,
+		previous: map[string]*functionSnapshot{},
+	}
+}
+
+// Export resolve the profiling files and feed function metrics and regression logs.
+func (a *FunctionAnalyzer) Export(files map[string][]*multipart.FileHeader,
+	metadata map[string]string,
+	customTags map[string]string,
+	language Language,
+) error {
+	if a == nil {
+		return nil
+	}
+
+	end, err := ResolveEndTime(metadata)
+	if err != nil {
+		return fmt.Errorf("unable to resolve profiling end time: %w", err)
+	}
+
+	stats := profileFunctionStats(files, Format(metadata[FieldFormat]))
+	if len(stats) == 0 {
+		return nil
+	}
+
+	tags := map[string]string{
+		"language": language.String(),
+		"host":     metadata["host"],
+		"service":  metadata["service"],
+		"env":      metadata["env"],
+		"version":  metadata["version"],
+	}
+	for k, v := range customTags {
+		tags[k] = v
+	}
+
+	pts, regressions := a.points(stats, tags, end)
+
+	if len(pts) > 0 {
+		if err := a.feeder.FeedV2(point.Metric, pts, dkio.WithInputName(metricsName)); err != nil {
+			return fmt.Errorf("unable to feed function metrics: %w", err)
+		}
+	}
+
+	if len(regressions) > 0 {
+		if err := a.feeder.FeedV2(point.Logging, regressions, dkio.WithInputName(metricsName)); err != nil {
+			return fmt.Errorf("unable to feed profiling regressions: %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *FunctionAnalyzer) points(stats map[FunctionKind]*functionStats,
+	tags map[string]string,
+	end time.Time,
+) (pts, regressions []*point.Point) {
+	opts := []point.Option{point.WithPrecision(point.PrecNS), point.WithTime(end)}
+
+	kinds := make([]FunctionKind, 0, len(stats))
+	for kind := range stats {
+		kinds = append(kinds, kind)
+	}
+	sort.Slice(kinds, func(i, j int) bool { return kinds[i] < kinds[j] })
+
+	for _, kind := range kinds {
+		fs := stats[kind]
+
+		for _, st := range fs.top(a.cfg.TopN) {
+			kvs := point.NewTags(tags)
+			kvs = kvs.AddTag("type", string(kind))
+			kvs = kvs.AddTag("function", st.name)
+			if st.file != "" {
+				kvs = kvs.AddTag("file", st.file)
+			}
+			kvs = kvs.AddV2("self", st.self, false)
+			kvs = kvs.AddV2("total", st.total, false)
+			kvs = kvs.AddV2("self_percent", fs.selfPercent(st), false)
+			kvs = kvs.AddV2("total_percent", fs.totalPercent(st), false)
+
+			pts = append(pts, point.NewPointV2(FunctionMetricsName, kvs, opts...))
+		}
+
+		if a.cfg.Diff {
+			regressions = append(regressions, a.diff(kind, fs, tags, end)...)
+		}
+	}
+
+	return pts, regressions
+}
+
+// diff compare self percent of functions with the previous profile of the same
+// host, service and env, functions absent in the previous profile are ignored.
+func (a *FunctionAnalyzer) diff(kind FunctionKind,
+	fs *functionStats,
+	tags map[string]string,
+	end time.Time,
+) []*point.Point {
+	key := strings.Join([]string{tags["language"], tags["host"], tags["service"], tags["env"], string(kind)}, "|")
+
+	cur := &functionSnapshot{
+		version: tags["version"],
+		time:    end,
+		percent: map[string]float64{},
+		names:   map[string]*functionStat{},
+	}
+
+	for k, st := range fs.funcs {
+		// functions far below the threshold could not become regression
+		if p := fs.selfPercent(st); p > 0 && p >= a.cfg.MinPercent/10 {
+			cur.percent[k] = p
+			cur.names[k] = st
+		}
+	}
+
+	a.mtx.Lock()
+	prev := a.previous[key]
+	if prev == nil || !end.Before(prev.time) {
+		a.previous[key] = cur
+		a.evictLocked()
+	}
+	a.mtx.Unlock()
+
+	if prev == nil || end.Before(prev.time) {
+		return nil
+	}
+
+	var res []*point.Point
+	opts := []point.Option{point.WithPrecision(point.PrecNS), point.WithTime(end)}
+
+	keys := make([]string, 0, len(cur.percent))
+	for k := range cur.percent {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := cur.percent[k]
+		if p < a.cfg.MinPercent {
+			continue
+		}
+
+		old, ok := prev.percent[k]
+		if !ok || old <= 0 {
+			continue
+		}
+
+		change := (p - old) * 100 / old
+		if change < a.cfg.RegressionThreshold {
+			continue
+		}
+
+		st := cur.names[k]
+		msg := fmt.Sprintf("function %s %+.1f%% %s (self %.2f%% -> %.2f%%)",
+			st.name, change, strings.ToUpper(string(kind)), old, p)
+		if cur.version != prev.version {
+			msg = fmt.Sprintf("function %s %+.1f%% %s after version %s (self %.2f%% -> %.2f%%, previous version %s)",
+				st.name, change, strings.ToUpper(string(kind)), cur.version, old, p, prev.version)
+		}
+
+		kvs := point.NewTags(tags)
+		kvs = kvs.AddTag("type", string(kind))
+		kvs = kvs.AddTag("function", st.name)
+		if st.file != "" {
+			kvs = kvs.AddTag("file", st.file)
+		}
+		kvs = kvs.AddTag("previous_version", prev.version)
+		kvs = kvs.AddTag("status", "warning")
+		kvs = kvs.AddV2("message", msg, false)
+		kvs = kvs.AddV2("self_percent", p, false)
+		kvs = kvs.AddV2("previous_self_percent", old, false)
+		kvs = kvs.AddV2("change_percent", math.Round(change*100)/100, false)
+
+		res = append(res, point.NewPointV2(RegressionLogName, kvs, opts...))
+	}
+
+	return res
+}
+
+// evictLocked drop the oldest series if too many services tracked.
+func (a *FunctionAnalyzer) evictLocked() {
+	for len(a.previous) > maxDiffSeries {
+		var (
+			oldestKey string
+			oldest    time.Time
+		)
+		for k, s := range a.previous {
+			if oldestKey == "" || s.time.Before(oldest) {
+				oldestKey, oldest = k, s.time
+			}
+		}
+		delete(a.previous, oldestKey)
+	}
+}
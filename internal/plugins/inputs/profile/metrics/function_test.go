@@ -0,0 +1,306 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package metrics
+
+import (
+	"bytes"
+	"mime/multipart"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+func stack(names ...string) []*functionStat {
+	res := make([]*functionStat, 0, len(names))
+	for _, n := range names {
+		res = append(res, &functionStat{name: n})
+	}
+	return res
+}
+
+func TestFunctionStats(t *testing.T) {
+	fs := newFunctionStats()
+	fs.add(stack("fib", "fib", "fib", "main"), 60)
+	fs.add(stack("parse", "handle", "main"), 30)
+	fs.add(stack("main"), 10)
+	fs.add(stack("ignored"), 0)
+
+	assert.Equal(t, int64(100), fs.sum)
+
+	fib := fs.funcs["fib|"]
+	assert.Equal(t, int64(60), fib.self)
+	assert.Equal(t, int64(60), fib.total) // recursion counted once
+
+	main := fs.funcs["main|"]
+	assert.Equal(t, int64(10), main.self)
+	assert.Equal(t, int64(100), main.total)
+	assert.Equal(t, 10.0, fs.selfPercent(main))
+	assert.Equal(t, 100.0, fs.totalPercent(main))
+
+	var names []string
+	for _, st := range fs.top(1) {
+		names = append(names, st.name)
+	}
+	assert.Equal(t, []string{"fib", "main"}, names)
+	assert.Len(t, fs.top(0), 4)
+}
+
+func TestPprofFunctionStats(t *testing.T) {
+	cases := []struct {
+		file string
+		kind FunctionKind
+		sum  int64
+	}{
+		{"testdata/cpu.pprof", FunctionCPU, 6070000000},
+		{"testdata/delta-heap.pprof", FunctionAlloc, 16699978},
+		{"testdata/python.pprof", FunctionCPU, 7990188678},
+		{"testdata/python.pprof", FunctionAlloc, 151819986},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.file+"/"+string(tc.kind), func(t *testing.T) {
+			f, err := os.Open(tc.file)
+			require.NoError(t, err)
+			defer f.Close() //nolint:errcheck
+
+			stats, err := pprofFunctionStats(f)
+			require.NoError(t, err)
+
+			fs := stats[tc.kind]
+			require.NotNil(t, fs)
+			assert.Equal(t, tc.sum, fs.sum)
+
+			var self int64
+			for _, st := range fs.funcs {
+				self += st.self
+				assert.LessOrEqual(t, st.self, st.total)
+				assert.LessOrEqual(t, st.total, fs.sum)
+			}
+			assert.Equal(t, fs.sum, self)
+		})
+	}
+
+	f, err := os.Open("testdata/goroutines.pprof")
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	stats, err := pprofFunctionStats(f)
+	require.NoError(t, err)
+	assert.Empty(t, stats)
+}
+
+func TestJFRFunctionStats(t *testing.T) {
+	f, err := os.Open("testdata/main.jfr")
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	stats, err := jfrFunctionStats(f)
+	require.NoError(t, err)
+
+	fs := stats[FunctionCPU]
+	require.NotNil(t, fs)
+	assert.Greater(t, fs.sum, int64(0))
+
+	top := fs.top(5)
+	require.NotEmpty(t, top)
+	assert.NotEmpty(t, top[0].name)
+}
+
+// buildPProf build a CPU profile, each stack is leaf first.
+func buildPProf(t *testing.T, samples map[string]int64) []byte {
+	t.Helper()
+
+	prof := &profile.Profile{
+		SampleType: []*profile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType: &profile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:     10e6,
+	}
+
+	mainFn := &profile.Function{ID: 1, Name: "main.main", Filename: "main.go"}
+	mainLoc := &profile.Location{ID: 1, Line: []profile.Line{{Function: mainFn, Line: 1}}}
+	prof.Function = append(prof.Function, mainFn)
+	prof.Location = append(prof.Location, mainLoc)
+
+	for name, v := range samples {
+		fn := &profile.Function{ID: uint64(len(prof.Function) + 1), Name: name, Filename: "main.go"}
+		loc := &profile.Location{ID: uint64(len(prof.Location) + 1), Line: []profile.Line{{Function: fn, Line: 10}}}
+		prof.Function = append(prof.Function, fn)
+		prof.Location = append(prof.Location, loc)
+		prof.Sample = append(prof.Sample, &profile.Sample{
+			Location: []*profile.Location{loc, mainLoc},
+			Value:    []int64{v / 10e6, v},
+		})
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, prof.Write(buf))
+	return buf.Bytes()
+}
+
+func buildForm(t *testing.T, files map[string][]byte) *multipart.Form {
+	t.Helper()
+
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+	for name, data := range files {
+		fw, err := w.CreateFormFile(name, name)
+		require.NoError(t, err)
+		_, err = fw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	form, err := multipart.NewReader(body, w.Boundary()).ReadForm(1 << 20)
+	require.NoError(t, err)
+	return form
+}
+
+func TestFunctionAnalyzer(t *testing.T) {
+	assert.Nil(t, NewFunctionAnalyzer(nil))
+	assert.Nil(t, NewFunctionAnalyzer(DefaultFunctionMetricsConfig()))
+	assert.NoError(t, (*FunctionAnalyzer)(nil).Export(nil, nil, nil, Golang))
+
+	cfg := DefaultFunctionMetricsConfig()
+	cfg.Enable = true
+	cfg.TopN = 2
+
+	feeder := dkio.NewMockedFeeder()
+	a := NewFunctionAnalyzer(cfg)
+	a.feeder = feeder
+
+	now := time.Now()
+	metadataOn := func(host, version string, end time.Time) map[string]string {
+		return map[string]string{
+			"service":   "demo",
+			"env":       "test",
+			"version":   version,
+			"host":      host,
+			FieldFormat: string(PPROF),
+			FieldStart:  end.Add(-time.Minute).Format(time.RFC3339Nano),
+			FieldEnd:    end.Format(time.RFC3339Nano),
+		}
+	}
+	metadata := func(version string, end time.Time) map[string]string {
+		return metadataOn("host-1", version, end)
+	}
+
+	// first profile, no regression
+	form := buildForm(t, map[string][]byte{
+		"cpu.pprof":  buildPProf(t, map[string]int64{"main.parse": 50e6, "main.handle": 30e6, "main.idle": 20e6}),
+		"event.json": []byte(`{}`),
+	})
+	require.NoError(t, a.Export(form.File, metadata("1.2.2", now), map[string]string{"foo": "bar"}, Golang))
+
+	pts, err := feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	require.Len(t, pts, 3) // top 2 by self and main.main by total
+
+	for _, pt := range pts {
+		assert.Equal(t, FunctionMetricsName, pt.Name())
+		assert.Equal(t, "cpu", pt.Get("type"))
+		assert.Equal(t, "demo", pt.Get("service"))
+		assert.Equal(t, "bar", pt.Get("foo"))
+		assert.Equal(t, Golang.String(), pt.Get("language"))
+
+		if pt.Get("function") == "main.parse" {
+			assert.Equal(t, int64(50e6), pt.Get("self"))
+			assert.Equal(t, 50.0, pt.Get("self_percent"))
+		}
+		if pt.Get("function") == "main.main" {
+			assert.Equal(t, int64(0), pt.Get("self"))
+			assert.Equal(t, 100.0, pt.Get("total_percent"))
+		}
+	}
+	feeder.Clear()
+
+	// main.handle 30% -> 60%, main.parse 50% -> 35%
+	form = buildForm(t, map[string][]byte{
+		"cpu.pprof": buildPProf(t, map[string]int64{"main.parse": 35e6, "main.handle": 60e6, "main.idle": 5e6}),
+	})
+	require.NoError(t, a.Export(form.File, metadata("1.2.3", now.Add(time.Minute)), nil, Golang))
+
+	pts, err = feeder.NPoints(4, time.Second)
+	require.NoError(t, err)
+
+	var regressions []*point.Point
+	for _, pt := range pts {
+		if pt.Name() == RegressionLogName {
+			regressions = append(regressions, pt)
+		}
+	}
+
+	require.Len(t, regressions, 1)
+	r := regressions[0]
+	assert.Equal(t, "main.handle", r.Get("function"))
+	assert.Equal(t, "1.2.3", r.Get("version"))
+	assert.Equal(t, "1.2.2", r.Get("previous_version"))
+	assert.Equal(t, 100.0, r.Get("change_percent"))
+	assert.Equal(t, "function main.handle +100.0% CPU after version 1.2.3 (self 30.00% -> 60.00%, previous version 1.2.2)", r.Get("message"))
+	feeder.Clear()
+
+	// same version, no version in message
+	form = buildForm(t, map[string][]byte{
+		"cpu.pprof": buildPProf(t, map[string]int64{"main.parse": 10e6, "main.handle": 90e6}),
+	})
+	require.NoError(t, a.Export(form.File, metadata("1.2.3", now.Add(2*time.Minute)), nil, Golang))
+
+	pts, err = feeder.NPoints(4, time.Second)
+	require.NoError(t, err)
+	regressions = regressions[:0]
+	for _, pt := range pts {
+		if pt.Name() == RegressionLogName {
+			regressions = append(regressions, pt)
+		}
+	}
+	require.Len(t, regressions, 1)
+	assert.Equal(t, "function main.handle +50.0% CPU (self 60.00% -> 90.00%)", regressions[0].Get("message"))
+	feeder.Clear()
+
+	// profiles of other hosts are not compared with
+	form = buildForm(t, map[string][]byte{
+		"cpu.pprof": buildPProf(t, map[string]int64{"main.parse": 60e6, "main.handle": 40e6}),
+	})
+	require.NoError(t, a.Export(form.File, metadataOn("host-2", "1.2.4", now.Add(3*time.Minute)), nil, Golang))
+
+	pts, err = feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	for _, pt := range pts {
+		assert.Equal(t, FunctionMetricsName, pt.Name())
+	}
+	feeder.Clear()
+
+	// out of order profile is not compared
+	form = buildForm(t, map[string][]byte{
+		"cpu.pprof": buildPProf(t, map[string]int64{"main.idle": 90e6, "main.handle": 10e6}),
+	})
+	require.NoError(t, a.Export(form.File, metadata("1.2.1", now.Add(-time.Hour)), nil, Golang))
+
+	pts, err = feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	for _, pt := range pts {
+		assert.Equal(t, FunctionMetricsName, pt.Name())
+	}
+}
+
+func TestProfileFunctionStatsSkipInvalid(t *testing.T) {
+	form := buildForm(t, map[string][]byte{
+		"metrics.json": []byte(`[]`),
+		"main.jfr":     []byte(`not a jfr`),
+		"auto.pprof":   []byte(`not a pprof`),
+	})
+
+	stats := profileFunctionStats(form.File, "")
+	assert.Empty(t, stats)
+}
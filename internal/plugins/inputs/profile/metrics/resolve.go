@@ -49,6 +49,12 @@ const (
 	PySpy         Profiler = "py-spy"
 	Pyroscope     Profiler = "pyroscope"
 
+	// JDKFlightRecorder JFR dumped over JMX.
+	JDKFlightRecorder Profiler = "jdk-flight-recorder"
+
+	// NodeInspector Node.js inspector protocol.
+	NodeInspector Profiler = "node-inspector"
+
 	// GoPProf golang builtin pprof.
 	GoPProf Profiler = "pprof"
 )
@@ -0,0 +1,329 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/profile/metrics"
+)
+
+const (
+	jvmModeAsyncProfiler = "async_profiler"
+	jvmModeJMX           = "jmx"
+
+	defaultAsprofPath  = "asprof"
+	defaultJFRSettings = "profile"
+	jfrMBean           = "jdk.management.jfr:type=FlightRecorder"
+)
+
+// JVMProfiler pull JFR from JVM, by attaching async-profiler to the
+// process, or by recording JFR through JMX(exposed by Jolokia).
+type JVMProfiler struct {
+	pullTarget
+
+	Mode string `toml:"mode"` // async_profiler or jmx
+
+	// async-profiler
+	AsprofPath  string   `toml:"asprof_path"`
+	Event       string   `toml:"event"` // cpu,alloc,lock,wall,itimer...
+	PID         int32    `toml:"pid"`
+	ProcessName string   `toml:"process_name"`
+	ExtraArgs   []string `toml:"extra_args"`
+
+	// JMX
+	JolokiaURL  string `toml:"jolokia_url"`
+	Username    string `toml:"username"`
+	Password    string `toml:"password"`
+	JFRSettings string `toml:"jfr_settings"` // default or profile
+
+	processRe *regexp.Regexp
+	client    *http.Client
+}
+
+func (j *JVMProfiler) init(i *Input) error {
+	if err := j.setup(i); err != nil {
+		return err
+	}
+
+	switch j.Mode {
+	case "", jvmModeAsyncProfiler:
+		j.Mode = jvmModeAsyncProfiler
+		if j.AsprofPath == "" {
+			j.AsprofPath = defaultAsprofPath
+		}
+		if j.Event == "" {
+			j.Event = "cpu"
+		}
+
+		re, err := compileProcessName(j.ProcessName)
+		if err != nil {
+			return err
+		}
+		if re == nil && j.PID <= 0 {
+			return fmt.Errorf("pid or process_name required")
+		}
+		j.processRe = re
+
+	case jvmModeJMX:
+		if j.JolokiaURL == "" {
+			return fmt.Errorf("jolokia_url required")
+		}
+		if j.JFRSettings == "" {
+			j.JFRSettings = defaultJFRSettings
+		}
+		j.client = &http.Client{Timeout: 30 * time.Second}
+
+	default:
+		return fmt.Errorf("invalid mode %q, expect %s or %s", j.Mode, jvmModeAsyncProfiler, jvmModeJMX)
+	}
+
+	return nil
+}
+
+func (j *JVMProfiler) run(i *Input) error {
+	if err := j.init(i); err != nil {
+		return fmt.Errorf("init jvm profiler error: %w", err)
+	}
+
+	return j.loop("jvm", j.collect)
+}
+
+func (j *JVMProfiler) collect() error {
+	var (
+		pd       *profileData
+		endPoint string
+		profiler metrics.Profiler
+		err      error
+	)
+
+	if j.Mode == jvmModeJMX {
+		pd, err = j.recordJFR()
+		endPoint, profiler = j.JolokiaURL, metrics.JDKFlightRecorder
+	} else {
+		var pid int32
+		if pid, err = findProcess(j.PID, j.processRe); err != nil {
+			return err
+		}
+		pd, err = j.asyncProfile(pid)
+		endPoint, profiler = "pid:"+strconv.Itoa(int(pid)), metrics.AsyncProfiler
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return j.push(pd, endPoint, "/jvm", &metrics.Metadata{
+		Language: metrics.Java,
+		Format:   metrics.JFR,
+		Profiler: profiler,
+	})
+}
+
+// asyncProfile attach async-profiler to the JVM and record JFR for duration.
+func (j *JVMProfiler) asyncProfile(pid int32) (*profileData, error) {
+	// the JFR file is written by the JVM process, it may run as another user
+	dir, err := os.MkdirTemp("", "datakit-asprof-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temp dir: %w", err)
+	}
+	defer os.RemoveAll(dir) //nolint:errcheck
+
+	// the dir is created with 0700, hand it over to the JVM user
+	uid, err := prepareJFRDir(dir, pid)
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(dir, metrics.MainJFRFile)
+	secs := int(j.duration / time.Second)
+
+	args := []string{"-d", strconv.Itoa(secs), "-e", j.Event, "-f", file}
+	args = append(args, j.ExtraArgs...)
+	args = append(args, strconv.Itoa(int(pid)))
+
+	ctx, cancel := context.WithTimeout(context.Background(), j.duration+30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	out, err := exec.CommandContext(ctx, j.AsprofPath, args...).CombinedOutput() //nolint:gosec
+	if err != nil {
+		return nil, fmt.Errorf("run %s: %w, output: %s", j.AsprofPath, err, bytes.TrimSpace(out))
+	}
+	end := time.Now()
+
+	data, err := readJFRFile(file, uid)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read JFR: %w", err)
+	}
+
+	if int64(len(data)) > j.input.GetBodySizeLimit() {
+		return nil, fmt.Errorf("exceed body max size")
+	}
+
+	return &profileData{
+		fileName:  metrics.MainJFRFile,
+		buf:       bytes.NewBuffer(data),
+		startTime: start,
+		endTime:   end,
+	}, nil
+}
+
+type jolokiaRequest struct {
+	Type      string `json:"type"`
+	MBean     string `json:"mbean"`
+	Operation string `json:"operation"`
+	Arguments []any  `json:"arguments"`
+}
+
+type jolokiaResponse struct {
+	Status int             `json:"status"`
+	Value  json.RawMessage `json:"value"`
+	Error  string          `json:"error"`
+}
+
+// jmxExec execute operation of FlightRecorderMXBean through Jolokia.
+func (j *JVMProfiler) jmxExec(operation string, args ...any) (json.RawMessage, error) {
+	if args == nil {
+		args = []any{}
+	}
+
+	body, err := json.Marshal(&jolokiaRequest{
+		Type:      "exec",
+		MBean:     jfrMBean,
+		Operation: operation,
+		Arguments: args,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, j.JolokiaURL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if j.Username != "" {
+		req.SetBasicAuth(j.Username, j.Password)
+	}
+
+	resp, err := j.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("jolokia %s: invalid response status: %s", operation, resp.Status)
+	}
+
+	var res jolokiaResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return nil, fmt.Errorf("jolokia %s: unable to decode response: %w", operation, err)
+	}
+
+	if res.Status != http.StatusOK {
+		return nil, fmt.Errorf("jolokia %s: %s", operation, res.Error)
+	}
+
+	return res.Value, nil
+}
+
+// recordJFR start a JFR recording through JMX, wait for duration, then read it back.
+func (j *JVMProfiler) recordJFR() (*profileData, error) {
+	v, err := j.jmxExec("newRecording")
+	if err != nil {
+		return nil, err
+	}
+
+	var id int64
+	if err := json.Unmarshal(v, &id); err != nil {
+		return nil, fmt.Errorf("invalid recording id %s: %w", v, err)
+	}
+
+	defer func() {
+		if _, err := j.jmxExec("closeRecording", id); err != nil {
+			log.Warnf("unable to close JFR recording %d: %s", id, err)
+		}
+	}()
+
+	if _, err := j.jmxExec("setPredefinedConfiguration(long,java.lang.String)", id, j.JFRSettings); err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if _, err := j.jmxExec("startRecording", id); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-time.After(j.duration):
+	case <-j.input.semStop.Wait():
+		return nil, fmt.Errorf("input stopped")
+	}
+
+	if _, err := j.jmxExec("stopRecording", id); err != nil {
+		return nil, err
+	}
+	end := time.Now()
+
+	if v, err = j.jmxExec("openStream", id, nil); err != nil {
+		return nil, err
+	}
+
+	var stream int64
+	if err := json.Unmarshal(v, &stream); err != nil {
+		return nil, fmt.Errorf("invalid stream id %s: %w", v, err)
+	}
+
+	defer func() {
+		if _, err := j.jmxExec("closeStream", stream); err != nil {
+			log.Debugf("unable to close JFR stream %d: %s", stream, err)
+		}
+	}()
+
+	buf := &bytes.Buffer{}
+	for {
+		v, err := j.jmxExec("readStream", stream)
+		if err != nil {
+			return nil, err
+		}
+
+		// byte[] is returned as number array, null at the end of stream
+		var chunk []int8
+		if err := json.Unmarshal(v, &chunk); err != nil {
+			return nil, fmt.Errorf("invalid JFR chunk: %w", err)
+		}
+		if len(chunk) == 0 {
+			break
+		}
+
+		for _, b := range chunk {
+			buf.WriteByte(byte(b))
+		}
+
+		if int64(buf.Len()) > j.input.GetBodySizeLimit() {
+			return nil, fmt.Errorf("exceed body max size")
+		}
+	}
+
+	return &profileData{
+		fileName:  metrics.MainJFRFile,
+		buf:       buf,
+		startTime: start,
+		endTime:   end,
+	}, nil
+}
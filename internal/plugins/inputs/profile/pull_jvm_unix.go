@@ -0,0 +1,72 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build !windows
+// +build !windows
+
+package profile
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+// prepareJFRDir hand dir over to the user of the JVM process, so that the JVM
+// could write JFR file into it, while other users can't. The uid of the JVM
+// returned.
+func prepareJFRDir(dir string, pid int32) (int, error) {
+	proc, err := process.NewProcess(pid)
+	if err != nil {
+		return -1, fmt.Errorf("unable to find process %d: %w", pid, err)
+	}
+
+	uids, err := proc.Uids()
+	if err != nil || len(uids) < 2 {
+		return -1, fmt.Errorf("unable to get uid of process %d: %w", pid, err)
+	}
+
+	gids, err := proc.Gids()
+	if err != nil || len(gids) < 2 {
+		return -1, fmt.Errorf("unable to get gid of process %d: %w", pid, err)
+	}
+
+	// effective uid/gid
+	uid, gid := int(uids[1]), int(gids[1])
+	if err := os.Chown(dir, uid, gid); err != nil {
+		return -1, fmt.Errorf("unable to chown %s to %d:%d: %w", dir, uid, gid, err)
+	}
+
+	return uid, nil
+}
+
+// readJFRFile read the JFR file written by the JVM, the file should be a
+// regular file(symlinks not followed) owned by uid.
+func readJFRFile(path string, uid int) ([]byte, error) {
+	f, err := os.OpenFile(filepath.Clean(path), os.O_RDONLY|syscall.O_NOFOLLOW, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%s is not a regular file", path)
+	}
+
+	if st, ok := fi.Sys().(*syscall.Stat_t); ok && int(st.Uid) != uid {
+		return nil, fmt.Errorf("%s is owned by uid %d, expect %d", path, st.Uid, uid)
+	}
+
+	return io.ReadAll(f)
+}
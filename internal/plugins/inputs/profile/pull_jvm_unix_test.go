@@ -0,0 +1,51 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build !windows
+// +build !windows
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadJFRFile(t *testing.T) {
+	// created the same as asyncProfile
+	dir, err := os.MkdirTemp(t.TempDir(), "datakit-asprof-")
+	require.NoError(t, err)
+
+	uid, err := prepareJFRDir(dir, int32(os.Getpid()))
+	require.NoError(t, err)
+	assert.Equal(t, os.Geteuid(), uid)
+
+	fi, err := os.Stat(dir)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0), fi.Mode().Perm()&0o077, "dir should not be accessible by others")
+
+	file := filepath.Join(dir, "main.jfr")
+	require.NoError(t, os.WriteFile(file, []byte("jfr"), 0o600))
+
+	data, err := readJFRFile(file, uid)
+	require.NoError(t, err)
+	assert.Equal(t, "jfr", string(data))
+
+	_, err = readJFRFile(file, uid+1)
+	assert.Error(t, err)
+
+	// symlinks are not followed
+	secret := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secret, []byte("secret"), 0o600))
+	link := filepath.Join(dir, "link.jfr")
+	require.NoError(t, os.Symlink(secret, link))
+
+	_, err = readJFRFile(link, uid)
+	assert.Error(t, err)
+}
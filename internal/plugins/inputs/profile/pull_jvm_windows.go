@@ -0,0 +1,23 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build windows
+// +build windows
+
+package profile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// prepareJFRDir is a no-op on Windows, the temp dir of the user is used.
+func prepareJFRDir(dir string, pid int32) (int, error) {
+	return -1, nil
+}
+
+func readJFRFile(path string, uid int) ([]byte, error) {
+	return os.ReadFile(filepath.Clean(path))
+}
@@ -0,0 +1,382 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/gorilla/websocket"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/profile/metrics"
+)
+
+const (
+	defaultInspectorURL     = "http://127.0.0.1:9229"
+	defaultSamplingInterval = 10 * time.Millisecond
+)
+
+// NodeJSProfiler pull CPU profile from Node.js through the inspector protocol,
+// the process should be started with --inspect.
+type NodeJSProfiler struct {
+	pullTarget
+
+	InspectorURL     string `toml:"inspector_url"`
+	SamplingInterval string `toml:"sampling_interval"`
+
+	samplingInterval time.Duration
+	client           *http.Client
+}
+
+func (n *NodeJSProfiler) init(i *Input) error {
+	if err := n.setup(i); err != nil {
+		return err
+	}
+
+	if n.InspectorURL == "" {
+		n.InspectorURL = defaultInspectorURL
+	}
+	if _, err := url.Parse(n.InspectorURL); err != nil {
+		return fmt.Errorf("invalid inspector_url %q: %w", n.InspectorURL, err)
+	}
+
+	n.samplingInterval = defaultSamplingInterval
+	if n.SamplingInterval != "" {
+		du, err := time.ParseDuration(n.SamplingInterval)
+		if err != nil {
+			return fmt.Errorf("invalid sampling_interval %q: %w", n.SamplingInterval, err)
+		}
+		if du >= time.Microsecond {
+			n.samplingInterval = du
+		}
+	}
+
+	n.client = &http.Client{Timeout: 15 * time.Second}
+	return nil
+}
+
+func (n *NodeJSProfiler) run(i *Input) error {
+	if err := n.init(i); err != nil {
+		return fmt.Errorf("init nodejs profiler error: %w", err)
+	}
+
+	return n.loop("nodejs", n.collect)
+}
+
+func (n *NodeJSProfiler) collect() error {
+	wsURL, err := n.debuggerURL()
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	cp, err := n.cpuProfile(wsURL)
+	if err != nil {
+		return err
+	}
+	end := time.Now()
+
+	prof, err := cpuProfileToPProf(cp, n.samplingInterval)
+	if err != nil {
+		return err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := prof.Write(buf); err != nil {
+		return fmt.Errorf("write pprof failed: %w", err)
+	}
+
+	return n.push(&profileData{
+		fileName:  "cpu" + metrics.PprofExt,
+		buf:       buf,
+		startTime: start,
+		endTime:   end,
+	}, n.InspectorURL, "/nodejs", &metrics.Metadata{
+		Language: metrics.NodeJS,
+		Format:   metrics.PPROF,
+		Profiler: metrics.NodeInspector,
+	})
+}
+
+// debuggerURL get the websocket URL of the first inspector target.
+func (n *NodeJSProfiler) debuggerURL() (string, error) {
+	u, err := url.Parse(n.InspectorURL)
+	if err != nil {
+		return "", err
+	}
+	u.Path = "/json/list"
+
+	resp, err := n.client.Get(u.String())
+	if err != nil {
+		return "", fmt.Errorf("unable to list inspector targets: %w", err)
+	}
+	defer resp.Body.Close() //nolint:errcheck
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("invalid response status: %s(%s)", resp.Status, u.String())
+	}
+
+	var targets []struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&targets); err != nil {
+		return "", fmt.Errorf("unable to decode inspector targets: %w", err)
+	}
+
+	for _, t := range targets {
+		if t.WebSocketDebuggerURL != "" {
+			return t.WebSocketDebuggerURL, nil
+		}
+	}
+
+	return "", fmt.Errorf("no inspector target found on %s", n.InspectorURL)
+}
+
+type inspectorMessage struct {
+	ID     int64           `json:"id,omitempty"`
+	Method string          `json:"method,omitempty"`
+	Params any             `json:"params,omitempty"`
+	Result json.RawMessage `json:"result,omitempty"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error,omitempty"`
+}
+
+type inspectorSession struct {
+	conn *websocket.Conn
+	id   int64
+}
+
+// call send the command and wait its result, events are ignored.
+func (s *inspectorSession) call(method string, params any) (json.RawMessage, error) {
+	s.id++
+	if err := s.conn.WriteJSON(&inspectorMessage{ID: s.id, Method: method, Params: params}); err != nil {
+		return nil, fmt.Errorf("%s: %w", method, err)
+	}
+
+	for {
+		var msg inspectorMessage
+		if err := s.conn.ReadJSON(&msg); err != nil {
+			return nil, fmt.Errorf("%s: %w", method, err)
+		}
+
+		if msg.ID != s.id {
+			continue
+		}
+
+		if msg.Error != nil {
+			return nil, fmt.Errorf("%s: %s(%d)", method, msg.Error.Message, msg.Error.Code)
+		}
+		return msg.Result, nil
+	}
+}
+
+// cpuProfile record CPU profile for duration.
+func (n *NodeJSProfiler) cpuProfile(wsURL string) (*cpuProfile, error) {
+	dialer := &websocket.Dialer{HandshakeTimeout: 15 * time.Second}
+	conn, resp, err := dialer.Dial(wsURL, nil)
+	if resp != nil && resp.Body != nil {
+		_ = resp.Body.Close()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to connect inspector %s: %w", wsURL, err)
+	}
+	defer conn.Close() //nolint:errcheck
+
+	s := &inspectorSession{conn: conn}
+
+	if _, err := s.call("Profiler.enable", nil); err != nil {
+		return nil, err
+	}
+	defer s.call("Profiler.disable", nil) //nolint:errcheck
+
+	if _, err := s.call("Profiler.setSamplingInterval",
+		map[string]int64{"interval": n.samplingInterval.Microseconds()}); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.call("Profiler.start", nil); err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-time.After(n.duration):
+	case <-n.input.semStop.Wait():
+		return nil, fmt.Errorf("input stopped")
+	}
+
+	res, err := s.call("Profiler.stop", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var x struct {
+		Profile *cpuProfile `json:"profile"`
+	}
+	if err := json.Unmarshal(res, &x); err != nil {
+		return nil, fmt.Errorf("unable to decode cpu profile: %w", err)
+	}
+	if x.Profile == nil {
+		return nil, fmt.Errorf("empty cpu profile")
+	}
+
+	return x.Profile, nil
+}
+
+// cpuProfile is the Profiler.Profile of the inspector protocol.
+type cpuProfile struct {
+	Nodes []struct {
+		ID        int64 `json:"id"`
+		CallFrame struct {
+			FunctionName string `json:"functionName"`
+			URL          string `json:"url"`
+			LineNumber   int64  `json:"lineNumber"`   // 0-based
+			ColumnNumber int64  `json:"columnNumber"` // 0-based
+		} `json:"callFrame"`
+		Children []int64 `json:"children"`
+	} `json:"nodes"`
+	StartTime  int64   `json:"startTime"` // microseconds
+	EndTime    int64   `json:"endTime"`
+	Samples    []int64 `json:"samples"`
+	TimeDeltas []int64 `json:"timeDeltas"` // microseconds
+}
+
+// meta nodes of V8 CPU profile which are not JS functions.
+var cpuProfileSkipFunctions = map[string]bool{
+	"(root)": true,
+	"(idle)": true,
+}
+
+// cpuProfileToPProf convert V8 CPU profile to pprof, time of each sample is
+// the delta to next sample.
+func cpuProfileToPProf(cp *cpuProfile, interval time.Duration) (*pprofile.Profile, error) {
+	type nodeInfo struct {
+		parent   int64
+		location *pprofile.Location
+		skip     bool
+	}
+
+	prof := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{
+			{Type: "samples", Unit: "count"},
+			{Type: "cpu", Unit: "nanoseconds"},
+		},
+		PeriodType:    &pprofile.ValueType{Type: "cpu", Unit: "nanoseconds"},
+		Period:        interval.Nanoseconds(),
+		TimeNanos:     cp.StartTime * 1000,
+		DurationNanos: (cp.EndTime - cp.StartTime) * 1000,
+	}
+
+	var (
+		nodes     = make(map[int64]*nodeInfo, len(cp.Nodes))
+		functions = map[string]*pprofile.Function{}
+	)
+
+	for _, n := range cp.Nodes {
+		info, ok := nodes[n.ID]
+		if !ok {
+			info = &nodeInfo{}
+			nodes[n.ID] = info
+		}
+
+		for _, c := range n.Children {
+			if ci, ok := nodes[c]; ok {
+				ci.parent = n.ID
+			} else {
+				nodes[c] = &nodeInfo{parent: n.ID}
+			}
+		}
+
+		name := n.CallFrame.FunctionName
+		if name == "" {
+			name = "(anonymous)"
+		}
+
+		if cpuProfileSkipFunctions[name] {
+			info.skip = true
+			continue
+		}
+
+		key := fmt.Sprintf("%s|%s|%d", name, n.CallFrame.URL, n.CallFrame.LineNumber)
+		fn, ok := functions[key]
+		if !ok {
+			fn = &pprofile.Function{
+				ID:        uint64(len(prof.Function) + 1),
+				Name:      name,
+				Filename:  n.CallFrame.URL,
+				StartLine: n.CallFrame.LineNumber + 1,
+			}
+			functions[key] = fn
+			prof.Function = append(prof.Function, fn)
+		}
+
+		info.location = &pprofile.Location{
+			ID:   uint64(len(prof.Location) + 1),
+			Line: []pprofile.Line{{Function: fn, Line: n.CallFrame.LineNumber + 1}},
+		}
+		prof.Location = append(prof.Location, info.location)
+	}
+
+	type agg struct {
+		count, nanos int64
+	}
+	values := map[int64]*agg{}
+	var order []int64
+
+	for i, id := range cp.Samples {
+		var delta int64
+		if i+1 < len(cp.TimeDeltas) {
+			delta = cp.TimeDeltas[i+1]
+		} else if i < len(cp.TimeDeltas) {
+			delta = cp.TimeDeltas[i]
+		}
+		if delta < 0 {
+			delta = 0
+		}
+
+		v, ok := values[id]
+		if !ok {
+			v = &agg{}
+			values[id] = v
+			order = append(order, id)
+		}
+		v.count++
+		v.nanos += delta * 1000
+	}
+
+	for _, id := range order {
+		var stack []*pprofile.Location
+		for cur, depth := id, 0; depth < len(nodes); depth++ {
+			info, ok := nodes[cur]
+			if !ok {
+				break
+			}
+			if info.location != nil {
+				stack = append(stack, info.location)
+			}
+			if info.parent == 0 {
+				break
+			}
+			cur = info.parent
+		}
+
+		if info := nodes[id]; info == nil || info.skip || len(stack) == 0 {
+			continue
+		}
+
+		prof.Sample = append(prof.Sample, &pprofile.Sample{
+			Location: stack,
+			Value:    []int64{values[id].count, values[id].nanos},
+		})
+	}
+
+	return prof, prof.CheckValid()
+}
@@ -0,0 +1,241 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	pprofile "github.com/google/pprof/profile"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/profile/metrics"
+)
+
+const (
+	defaultPySpyPath = "py-spy"
+	defaultPySpyRate = 100
+)
+
+// pySpyFrameRegexp match frame of py-spy raw output, such as `work (app.py:12)`.
+var pySpyFrameRegexp = regexp.MustCompile(`^(.*) \(([^()]*?)(?::(\d+))?\)$`)
+
+// PythonProfiler pull CPU profile of Python process by py-spy.
+type PythonProfiler struct {
+	pullTarget
+
+	PySpyPath   string `toml:"py_spy_path"`
+	PID         int32  `toml:"pid"`
+	ProcessName string `toml:"process_name"`
+	Rate        int    `toml:"rate"` // samples per second
+	Native      bool   `toml:"native"`
+	Nonblocking bool   `toml:"nonblocking"`
+
+	processRe *regexp.Regexp
+}
+
+func (p *PythonProfiler) init(i *Input) error {
+	if err := p.setup(i); err != nil {
+		return err
+	}
+
+	if p.PySpyPath == "" {
+		p.PySpyPath = defaultPySpyPath
+	}
+	if p.Rate <= 0 {
+		p.Rate = defaultPySpyRate
+	}
+
+	re, err := compileProcessName(p.ProcessName)
+	if err != nil {
+		return err
+	}
+	if re == nil && p.PID <= 0 {
+		return fmt.Errorf("pid or process_name required")
+	}
+	p.processRe = re
+
+	return nil
+}
+
+func (p *PythonProfiler) run(i *Input) error {
+	if err := p.init(i); err != nil {
+		return fmt.Errorf("init python profiler error: %w", err)
+	}
+
+	return p.loop("python", p.collect)
+}
+
+func (p *PythonProfiler) collect() error {
+	pid, err := findProcess(p.PID, p.processRe)
+	if err != nil {
+		return err
+	}
+
+	pd, err := p.record(pid)
+	if err != nil {
+		return err
+	}
+
+	return p.push(pd, "pid:"+strconv.Itoa(int(pid)), "/python", &metrics.Metadata{
+		Language: metrics.Python,
+		Format:   metrics.PPROF,
+		Profiler: metrics.PySpy,
+	})
+}
+
+// record run py-spy for duration and convert its output to pprof.
+func (p *PythonProfiler) record(pid int32) (*profileData, error) {
+	f, err := os.CreateTemp("", "datakit-py-spy-*.txt")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create temp file: %w", err)
+	}
+	out := f.Name()
+	_ = f.Close()
+	defer os.Remove(out) //nolint:errcheck
+
+	secs := int(p.duration / time.Second)
+	args := []string{
+		"record",
+		"--pid", strconv.Itoa(int(pid)),
+		"--duration", strconv.Itoa(secs),
+		"--rate", strconv.Itoa(p.Rate),
+		"--format", "raw",
+		"--output", out,
+	}
+	if p.Native {
+		args = append(args, "--native")
+	}
+	if p.Nonblocking {
+		args = append(args, "--nonblocking")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), p.duration+30*time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if res, err := exec.CommandContext(ctx, p.PySpyPath, args...).CombinedOutput(); err != nil { //nolint:gosec
+		return nil, fmt.Errorf("run %s: %w, output: %s", p.PySpyPath, err, bytes.TrimSpace(res))
+	}
+	end := time.Now()
+
+	raw, err := os.Open(filepath.Clean(out))
+	if err != nil {
+		return nil, fmt.Errorf("unable to open py-spy output: %w", err)
+	}
+	defer raw.Close() //nolint:errcheck
+
+	prof, err := collapsedToPProf(raw, time.Second/time.Duration(p.Rate))
+	if err != nil {
+		return nil, err
+	}
+	prof.TimeNanos = start.UnixNano()
+	prof.DurationNanos = end.Sub(start).Nanoseconds()
+
+	buf := &bytes.Buffer{}
+	if err := prof.Write(buf); err != nil {
+		return nil, fmt.Errorf("write pprof failed: %w", err)
+	}
+
+	return &profileData{
+		fileName:  "cpu" + metrics.PprofExt,
+		buf:       buf,
+		startTime: start,
+		endTime:   end,
+	}, nil
+}
+
+// collapsedToPProf convert collapsed stacks(`root;caller;callee count` per line)
+// to pprof, each sample takes period of CPU time. Sample types are named
+// as ddtrace python profiler does, so that profiling metrics can be generated.
+func collapsedToPProf(r io.Reader, period time.Duration) (*pprofile.Profile, error) {
+	prof := &pprofile.Profile{
+		SampleType: []*pprofile.ValueType{
+			{Type: "cpu-samples", Unit: "count"},
+			{Type: "cpu-time", Unit: "nanoseconds"},
+		},
+		PeriodType: &pprofile.ValueType{Type: "cpu-time", Unit: "nanoseconds"},
+		Period:     period.Nanoseconds(),
+	}
+
+	var (
+		functions = map[string]*pprofile.Function{}
+		locations = map[string]*pprofile.Location{}
+	)
+
+	location := func(frame string) *pprofile.Location {
+		if loc, ok := locations[frame]; ok {
+			return loc
+		}
+
+		name, file, line := frame, "", int64(0)
+		if m := pySpyFrameRegexp.FindStringSubmatch(frame); len(m) == 4 {
+			name, file = m[1], m[2]
+			line, _ = strconv.ParseInt(m[3], 10, 64)
+		}
+
+		key := name + "|" + file
+		fn, ok := functions[key]
+		if !ok {
+			fn = &pprofile.Function{ID: uint64(len(prof.Function) + 1), Name: name, Filename: file}
+			functions[key] = fn
+			prof.Function = append(prof.Function, fn)
+		}
+
+		loc := &pprofile.Location{
+			ID:   uint64(len(prof.Location) + 1),
+			Line: []pprofile.Line{{Function: fn, Line: line}},
+		}
+		locations[frame] = loc
+		prof.Location = append(prof.Location, loc)
+		return loc
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 0, 64*1024), 4*MiB)
+
+	for sc.Scan() {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" {
+			continue
+		}
+
+		idx := strings.LastIndexByte(text, ' ')
+		if idx <= 0 {
+			return nil, fmt.Errorf("invalid collapsed stack: %q", text)
+		}
+
+		count, err := strconv.ParseInt(text[idx+1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid collapsed stack: %q", text)
+		}
+
+		frames := strings.Split(text[:idx], ";")
+		stack := make([]*pprofile.Location, 0, len(frames))
+		for i := len(frames) - 1; i >= 0; i-- { // leaf first
+			stack = append(stack, location(frames[i]))
+		}
+
+		prof.Sample = append(prof.Sample, &pprofile.Sample{
+			Location: stack,
+			Value:    []int64{count, count * period.Nanoseconds()},
+		})
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read collapsed stacks: %w", err)
+	}
+
+	return prof, prof.CheckValid()
+}
@@ -0,0 +1,193 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/process"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/profile/metrics"
+)
+
+const (
+	defaultPullInterval = time.Minute
+	minPullInterval     = 10 * time.Second
+	defaultPullDuration = 10 * time.Second
+	minPullDuration     = time.Second
+)
+
+// pullTarget is the common config of pull mode profilers except go.
+type pullTarget struct {
+	Interval string            `toml:"interval"`
+	Duration string            `toml:"duration"`
+	Service  string            `toml:"service"`
+	Env      string            `toml:"env"`
+	Version  string            `toml:"version"`
+	Tags     map[string]string `toml:"tags"`
+
+	interval time.Duration
+	duration time.Duration
+	tags     map[string]string
+	input    *Input
+}
+
+// setup check interval and duration, the duration of each profiling
+// should not be longer than the interval.
+func (t *pullTarget) setup(i *Input) error {
+	if i == nil {
+		return fmt.Errorf("input expected not to be nil")
+	}
+	t.input = i
+
+	t.interval = defaultPullInterval
+	if t.Interval != "" {
+		du, err := time.ParseDuration(t.Interval)
+		if err != nil {
+			return fmt.Errorf("invalid interval %q: %w", t.Interval, err)
+		}
+		t.interval = du
+	}
+	if t.interval < minPullInterval {
+		t.interval = minPullInterval
+	}
+
+	t.duration = defaultPullDuration
+	if t.Duration != "" {
+		du, err := time.ParseDuration(t.Duration)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q: %w", t.Duration, err)
+		}
+		t.duration = du
+	}
+	if t.duration < minPullDuration {
+		t.duration = minPullDuration
+	}
+	if t.duration > t.interval {
+		t.duration = t.interval
+	}
+
+	t.tags = map[string]string{
+		"service": t.Service,
+		"version": t.Version,
+		"env":     t.Env,
+	}
+	for k, v := range t.Tags {
+		t.tags[k] = v
+	}
+
+	return nil
+}
+
+// loop run collect every interval until datakit exit, collect is skipped
+// if not leader under election.
+func (t *pullTarget) loop(name string, collect func() error) error {
+	defer func() {
+		log.Warnf("%s/%s handler stopped", PullInputMode, name)
+	}()
+
+	tick := time.NewTicker(t.interval)
+	defer tick.Stop()
+
+	once := new(sync.Once)
+
+	for {
+		if t.input.pause.Load() {
+			log.Debugf("not leader, skipped")
+		} else {
+			once.Do(func() {
+				log.Infof("profiling pull mode for %s(service %q) start....", name, t.Service)
+			})
+
+			if err := collect(); err != nil {
+				log.Warnf("%s profiling for service %q: %s", name, t.Service, err.Error())
+			}
+		}
+
+		select {
+		case <-datakit.Exit.Wait():
+			return nil
+		case <-t.input.semStop.Wait():
+			log.Infof("%s profiler exit", name)
+			return nil
+		case <-tick.C:
+		}
+	}
+}
+
+// push upload the profile through the same path with other profiles.
+func (t *pullTarget) push(pd *profileData, endPoint, nameSuffix string, event *metrics.Metadata) error {
+	event.Start = metrics.NewRFC3339Time(pd.startTime)
+	event.End = metrics.NewRFC3339Time(pd.endTime)
+	event.Attachments = []string{pd.fileName}
+	event.TagsProfiler = metrics.JoinTags(t.tags)
+	event.SubCustomTags = metrics.JoinTags(t.Tags)
+
+	return pushProfileData(
+		&pushProfileDataOpt{
+			startTime:       pd.startTime,
+			endTime:         pd.endTime,
+			profiledatas:    []*profileData{pd},
+			endPoint:        endPoint,
+			inputTags:       t.tags,
+			inputNameSuffix: nameSuffix,
+			Input:           t.input,
+		},
+		event,
+		t.input.GetBodySizeLimit(),
+	)
+}
+
+// findProcess get the PID of the target process, pid is used if set,
+// otherwise the first process whose command line matches re.
+func findProcess(pid int32, re *regexp.Regexp) (int32, error) {
+	if pid > 0 {
+		return pid, nil
+	}
+
+	if re == nil {
+		return 0, fmt.Errorf("neither pid nor process_name set")
+	}
+
+	procs, err := process.Processes()
+	if err != nil {
+		return 0, fmt.Errorf("unable to list processes: %w", err)
+	}
+
+	self := int32(os.Getpid())
+	for _, p := range procs {
+		if p.Pid == self {
+			continue
+		}
+
+		cmdline, err := p.Cmdline()
+		if err != nil || cmdline == "" {
+			continue
+		}
+
+		if re.MatchString(cmdline) {
+			return p.Pid, nil
+		}
+	}
+
+	return 0, fmt.Errorf("no process matched %q", re.String())
+}
+
+func compileProcessName(s string) (*regexp.Regexp, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	re, err := regexp.Compile(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid process_name %q: %w", s, err)
+	}
+	return re, nil
+}
@@ -0,0 +1,315 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	T "testing"
+	"time"
+
+	bstoml "github.com/BurntSushi/toml"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPullTargetSetup(t *T.T) {
+	t.Run("config", func(t *T.T) {
+		ipt := DefaultInput()
+		_, err := bstoml.Decode(`
+[[jvm]]
+  mode = "jmx"
+  jolokia_url = "http://localhost:8778/jolokia"
+  interval = "30s"
+  duration = "1m"
+  service = "java-demo"
+  [jvm.tags]
+    foo = "bar"
+
+[[nodejs]]
+  inspector_url = "http://127.0.0.1:9229"
+
+[[python]]
+  process_name = "python .*app\\.py"
+  interval = "1s"
+`, ipt)
+		require.NoError(t, err)
+		require.Len(t, ipt.JVM, 1)
+		require.Len(t, ipt.NodeJS, 1)
+		require.Len(t, ipt.Python, 1)
+
+		j := ipt.JVM[0]
+		require.NoError(t, j.init(ipt))
+		assert.Equal(t, 30*time.Second, j.interval)
+		assert.Equal(t, 30*time.Second, j.duration) // no longer than interval
+		assert.Equal(t, map[string]string{"service": "java-demo", "env": "", "version": "", "foo": "bar"}, j.tags)
+		assert.Equal(t, defaultJFRSettings, j.JFRSettings)
+
+		n := ipt.NodeJS[0]
+		require.NoError(t, n.init(ipt))
+		assert.Equal(t, defaultPullInterval, n.interval)
+		assert.Equal(t, defaultPullDuration, n.duration)
+		assert.Equal(t, defaultSamplingInterval, n.samplingInterval)
+
+		p := ipt.Python[0]
+		require.NoError(t, p.init(ipt))
+		assert.Equal(t, minPullInterval, p.interval)
+		assert.True(t, p.processRe.MatchString("python /app/app.py"))
+		assert.Equal(t, defaultPySpyRate, p.Rate)
+	})
+
+	t.Run("invalid", func(t *T.T) {
+		ipt := DefaultInput()
+		assert.Error(t, (&JVMProfiler{}).init(ipt))
+		assert.Error(t, (&JVMProfiler{Mode: "attach", PID: 1}).init(ipt))
+		assert.Error(t, (&JVMProfiler{Mode: jvmModeJMX}).init(ipt))
+		assert.Error(t, (&PythonProfiler{ProcessName: "("}).init(ipt))
+		assert.Error(t, (&NodeJSProfiler{pullTarget: pullTarget{Interval: "abc"}}).init(ipt))
+	})
+}
+
+func TestCPUProfileToPProf(t *T.T) {
+	var cp cpuProfile
+	require.NoError(t, json.Unmarshal([]byte(`{
+  "nodes": [
+    {"id": 1, "callFrame": {"functionName": "(root)", "url": "", "lineNumber": -1}, "children": [2, 5]},
+    {"id": 2, "callFrame": {"functionName": "main", "url": "file:///app/index.js", "lineNumber": 9}, "children": [3, 4]},
+    {"id": 3, "callFrame": {"functionName": "fib", "url": "file:///app/fib.js", "lineNumber": 0}},
+    {"id": 4, "callFrame": {"functionName": "", "url": "file:///app/index.js", "lineNumber": 20}},
+    {"id": 5, "callFrame": {"functionName": "(idle)", "url": "", "lineNumber": -1}}
+  ],
+  "startTime": 1000000,
+  "endTime": 1040000,
+  "samples": [3, 3, 4, 5, 3],
+  "timeDeltas": [0, 10000, 10000, 10000, 10000]
+}`), &cp))
+
+	prof, err := cpuProfileToPProf(&cp, 10*time.Millisecond)
+	require.NoError(t, err)
+
+	assert.Equal(t, int64(1e9), prof.TimeNanos)
+	assert.Equal(t, int64(40e6), prof.DurationNanos)
+	require.Len(t, prof.Sample, 2) // idle dropped
+
+	stacks := map[string][]int64{}
+	for _, s := range prof.Sample {
+		var names []string
+		for _, loc := range s.Location {
+			names = append(names, fmt.Sprintf("%s:%d", loc.Line[0].Function.Name, loc.Line[0].Line))
+		}
+		stacks[strings.Join(names, ";")] = s.Value
+	}
+
+	assert.Equal(t, []int64{3, 30e6}, stacks["fib:1;main:10"])
+	assert.Equal(t, []int64{1, 10e6}, stacks["(anonymous):21;main:10"])
+}
+
+func TestCollapsedToPProf(t *T.T) {
+	raw := `<module> (app.py:30);main (app.py:25);work (lib/work.py:12) 7
+<module> (app.py:30);main (app.py:25) 3
+process 123:"python app.py";<module> (app.py:30) 1
+`
+
+	prof, err := collapsedToPProf(strings.NewReader(raw), 10*time.Millisecond)
+	require.NoError(t, err)
+	require.Len(t, prof.Sample, 3)
+
+	s := prof.Sample[0]
+	assert.Equal(t, []int64{7, 70e6}, s.Value)
+	require.Len(t, s.Location, 3)
+	assert.Equal(t, "work", s.Location[0].Line[0].Function.Name)
+	assert.Equal(t, "lib/work.py", s.Location[0].Line[0].Function.Filename)
+	assert.Equal(t, int64(12), s.Location[0].Line[0].Line)
+	assert.Equal(t, "<module>", s.Location[2].Line[0].Function.Name)
+
+	// locations are shared
+	assert.Same(t, prof.Sample[0].Location[1], prof.Sample[1].Location[0])
+	assert.Equal(t, `process 123:"python app.py"`, prof.Sample[2].Location[1].Line[0].Function.Name)
+
+	_, err = collapsedToPProf(strings.NewReader("main (app.py:1) x\n"), time.Millisecond)
+	assert.Error(t, err)
+}
+
+func TestNodeJSProfiler(t *T.T) {
+	var (
+		upgrader = websocket.Upgrader{}
+		mtx      sync.Mutex
+		methods  []string
+	)
+
+	mux := http.NewServeMux()
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	mux.HandleFunc("/json/list", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `[{"id":"abc","webSocketDebuggerUrl":"ws://%s/abc"}]`, r.Host)
+	})
+
+	mux.HandleFunc("/abc", func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close() //nolint:errcheck
+
+		for {
+			var msg inspectorMessage
+			if err := conn.ReadJSON(&msg); err != nil {
+				return
+			}
+
+			mtx.Lock()
+			methods = append(methods, msg.Method)
+			mtx.Unlock()
+
+			// events should be ignored
+			_ = conn.WriteJSON(map[string]any{"method": "Runtime.consoleAPICalled", "params": map[string]any{}})
+
+			res := `{}`
+			if msg.Method == "Profiler.stop" {
+				res = `{"profile": {
+  "nodes": [
+    {"id": 1, "callFrame": {"functionName": "(root)"}, "children": [2]},
+    {"id": 2, "callFrame": {"functionName": "main", "url": "index.js", "lineNumber": 1}}
+  ],
+  "startTime": 1, "endTime": 2, "samples": [2], "timeDeltas": [1]}}`
+			}
+			_ = conn.WriteMessage(websocket.TextMessage, []byte(fmt.Sprintf(`{"id":%d,"result":%s}`, msg.ID, res)))
+		}
+	})
+
+	ipt := DefaultInput()
+	n := &NodeJSProfiler{InspectorURL: srv.URL, pullTarget: pullTarget{Duration: "1s"}}
+	require.NoError(t, n.init(ipt))
+
+	wsURL, err := n.debuggerURL()
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(wsURL, "ws://"))
+
+	cp, err := n.cpuProfile(wsURL)
+	require.NoError(t, err)
+	assert.Len(t, cp.Nodes, 2)
+	assert.Equal(t, []int64{2}, cp.Samples)
+
+	assert.Eventually(t, func() bool {
+		mtx.Lock()
+		defer mtx.Unlock()
+		return len(methods) == 5
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, []string{
+		"Profiler.enable",
+		"Profiler.setSamplingInterval",
+		"Profiler.start",
+		"Profiler.stop",
+		"Profiler.disable",
+	}, methods)
+}
+
+func TestJVMRecordJFR(t *T.T) {
+	var (
+		ops    []string
+		chunks = []string{`[74, 70, 82, -128]`, `[1, 2]`, `null`}
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, _ := r.BasicAuth()
+		assert.Equal(t, "admin", user)
+		assert.Equal(t, "secret", pass)
+
+		var req jolokiaRequest
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, jfrMBean, req.MBean)
+		ops = append(ops, req.Operation)
+
+		value := "null"
+		switch req.Operation {
+		case "newRecording":
+			value = "3"
+		case "openStream":
+			value = "7"
+		case "readStream":
+			value, chunks = chunks[0], chunks[1:]
+		case "setPredefinedConfiguration(long,java.lang.String)":
+			assert.Equal(t, []any{float64(3), "profile"}, req.Arguments)
+		}
+
+		fmt.Fprintf(w, `{"status":200,"value":%s}`, value)
+	}))
+	defer srv.Close()
+
+	ipt := DefaultInput()
+	j := &JVMProfiler{
+		Mode:       jvmModeJMX,
+		JolokiaURL: srv.URL,
+		Username:   "admin",
+		Password:   "secret",
+		pullTarget: pullTarget{Duration: "1s"},
+	}
+	require.NoError(t, j.init(ipt))
+
+	pd, err := j.recordJFR()
+	require.NoError(t, err)
+	assert.Equal(t, []byte{'J', 'F', 'R', 0x80, 1, 2}, pd.buf.Bytes())
+	assert.Equal(t, "main.jfr", pd.fileName)
+
+	assert.Equal(t, []string{
+		"newRecording",
+		"setPredefinedConfiguration(long,java.lang.String)",
+		"startRecording",
+		"stopRecording",
+		"openStream",
+		"readStream",
+		"readStream",
+		"readStream",
+		"closeStream",
+		"closeRecording",
+	}, ops)
+}
+
+func TestPythonRecord(t *T.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("shell script not available")
+	}
+
+	dir := t.TempDir()
+	argsFile := filepath.Join(dir, "args")
+	script := filepath.Join(dir, "py-spy")
+
+	// fake py-spy write collapsed stacks to --output
+	require.NoError(t, os.WriteFile(script, []byte(`#!/bin/sh
+echo "$@" > `+argsFile+`
+while [ $# -gt 0 ]; do
+  if [ "$1" = "--output" ]; then out=$2; fi
+  shift
+done
+echo "main (app.py:3);work (app.py:9) 5" > $out
+`), 0o700)) //nolint:gosec
+
+	ipt := DefaultInput()
+	p := &PythonProfiler{PySpyPath: script, PID: 42, Native: true, pullTarget: pullTarget{Duration: "2s"}}
+	require.NoError(t, p.init(ipt))
+
+	pd, err := p.record(42)
+	require.NoError(t, err)
+	assert.Equal(t, "cpu.pprof", pd.fileName)
+
+	args, err := os.ReadFile(argsFile) //nolint:gosec
+	require.NoError(t, err)
+	assert.Contains(t, string(args), "record --pid 42 --duration 2 --rate 100 --format raw --output")
+	assert.Contains(t, string(args), "--native")
+
+	p.PySpyPath = filepath.Join(dir, "not-exist")
+	_, err = p.record(42)
+	assert.Error(t, err)
+}
@@ -77,7 +77,7 @@ func (p *pyroscopeOpts) run(input *Input) error {
 		return fmt.Errorf("input expected not to be nil")
 	}
 
-	if input.pause {
+	if input.pause.Load() {
 		log.Debugf("not leader, skipped")
 		return nil
 	}
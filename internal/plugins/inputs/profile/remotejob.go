@@ -0,0 +1,74 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/remotejob"
+)
+
+func init() { //nolint:gochecknoinits
+	remotejob.SetGoProfileCapture(captureGoProfile)
+}
+
+// captureGoProfile pull profiles of the Go service for remote job, unlike the
+// pull mode of the input, heap/mutex/block profiles are not converted to deltas.
+func captureGoProfile(j *remotejob.GoProfile) (map[string][]byte, error) {
+	g := &GoProfiler{
+		URL:                j.URL,
+		TLSOpen:            j.TLSOpen,
+		CacertFile:         j.CacertFile,
+		CertFile:           j.CertFile,
+		KeyFile:            j.KeyFile,
+		InsecureSkipVerify: j.InsecureSkipVerify,
+		input:              &Input{BodySizeLimitMB: defaultProfileMaxSize},
+	}
+
+	if err := g.init(); err != nil {
+		return nil, fmt.Errorf("init go profiler error: %w", err)
+	}
+
+	// cpu profile blocks for the whole duration
+	g.client.Timeout += time.Duration(j.Seconds) * time.Second
+
+	var (
+		files = map[string][]byte{}
+		errs  []string
+	)
+
+	for _, k := range j.Types {
+		item, ok := profileConfigMap[k]
+		if !ok {
+			errs = append(errs, fmt.Sprintf("invalid profile type: %s", k))
+			continue
+		}
+
+		params := item.params
+		if k == "cpu" {
+			params = url.Values{"seconds": []string{strconv.Itoa(j.Seconds)}}
+		}
+
+		buf, err := g.pullProfileData(item.path, params)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", k, err.Error()))
+			continue
+		}
+
+		files[k+".pprof"] = buf.Bytes()
+	}
+
+	if len(errs) > 0 {
+		return files, errors.New(strings.Join(errs, "; "))
+	}
+
+	return files, nil
+}
@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package profile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/http/pprof"
+	"testing"
+
+	pprofile "github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/remotejob"
+)
+
+func TestCaptureGoProfile(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.Handle("/debug/pprof/heap", pprof.Handler("heap"))
+	mux.Handle("/debug/pprof/goroutine", pprof.Handler("goroutine"))
+
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	files, err := captureGoProfile(&remotejob.GoProfile{
+		URL:     ts.URL,
+		Types:   []string{"cpu", "heap", "goroutine", "unknown"},
+		Seconds: 1,
+	})
+
+	assert.ErrorContains(t, err, "invalid profile type: unknown")
+	require.Len(t, files, 3)
+
+	for name, data := range files {
+		_, err := pprofile.ParseData(data)
+		assert.NoError(t, err, name)
+	}
+}
@@ -247,7 +247,8 @@ func (ipt *Input) serveWrite(res http.ResponseWriter, req *http.Request) {
 		if err := ipt.feeder.FeedV2(point.Metric, pts,
 			dkio.WithCollectCost(time.Since(start)),
 			dkio.DisableGlobalTags(true),
-			dkio.WithInputName(inputName)); err != nil {
+			dkio.WithInputName(inputName),
+			dkio.WithRequestHandler(true)); err != nil {
 			ipt.feeder.FeedLastError(err.Error(),
 				metrics.WithLastErrorInput(inputName),
 				metrics.WithLastErrorCategory(point.Metric),
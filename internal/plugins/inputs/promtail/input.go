@@ -143,6 +143,7 @@ func (ipt *Input) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
 		dkio.WithPipelineOption(&plmanager.Option{
 			ScriptMap: map[string]string{source: pipelinePath},
 		}),
+		dkio.WithRequestHandler(true),
 	); err != nil {
 		resp.WriteHeader(http.StatusInternalServerError)
 	} else {
@@ -0,0 +1,296 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package proxy
+
+import (
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	resultAllowed      = "allowed"
+	resultDenied       = "denied"
+	resultUnauthorized = "unauthorized"
+
+	// destAny is the destination label if no allowed destinations configured.
+	destAny = "*"
+)
+
+// User is the basic auth account of proxy clients.
+type User struct {
+	Username string `toml:"username"`
+	Password string `toml:"password"`
+}
+
+// destRule is one of allowed destinations, in format host[:port], host can be
+// domain(`*.` prefix matches any sub-domain), IP or CIDR, and port can be a range
+// such as 8000-9000. IPv6 should be bracketed if port set, such as [fd00::/8]:443.
+type destRule struct {
+	raw     string
+	domain  string
+	ipnet   *net.IPNet
+	minPort int
+	maxPort int
+}
+
+func parseDestRule(s string) (*destRule, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("empty destination")
+	}
+
+	host, port := s, ""
+	switch {
+	case strings.HasPrefix(s, "["):
+		end := strings.Index(s, "]")
+		if end < 0 {
+			return nil, fmt.Errorf("invalid destination %q", s)
+		}
+		host = s[1:end]
+		if rest := s[end+1:]; rest != "" {
+			if !strings.HasPrefix(rest, ":") {
+				return nil, fmt.Errorf("invalid destination %q", s)
+			}
+			port = rest[1:]
+		}
+	case strings.Count(s, ":") == 1:
+		idx := strings.LastIndex(s, ":")
+		host, port = s[:idx], s[idx+1:]
+	}
+
+	r := &destRule{raw: s, minPort: 1, maxPort: 65535}
+
+	if port != "" && port != "*" {
+		lo, hi, found := strings.Cut(port, "-")
+		if !found {
+			hi = lo
+		}
+
+		var err error
+		if r.minPort, err = strconv.Atoi(lo); err != nil {
+			return nil, fmt.Errorf("invalid port of destination %q", s)
+		}
+		if r.maxPort, err = strconv.Atoi(hi); err != nil {
+			return nil, fmt.Errorf("invalid port of destination %q", s)
+		}
+		if r.minPort < 1 || r.maxPort > 65535 || r.minPort > r.maxPort {
+			return nil, fmt.Errorf("invalid port range of destination %q", s)
+		}
+	}
+
+	if _, ipnet, err := net.ParseCIDR(host); err == nil {
+		r.ipnet = ipnet
+		return r, nil
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		r.ipnet = singleIPNet(ip)
+		return r, nil
+	}
+
+	if host == "" {
+		return nil, fmt.Errorf("invalid destination %q", s)
+	}
+
+	r.domain = strings.ToLower(strings.TrimSuffix(host, "."))
+	return r, nil
+}
+
+// match check the destination, CIDR only match IP destination, domains are
+// not resolved.
+func (r *destRule) match(host string, port int) bool {
+	if port < r.minPort || port > r.maxPort {
+		return false
+	}
+
+	if r.ipnet != nil {
+		ip := net.ParseIP(host)
+		return ip != nil && r.ipnet.Contains(ip)
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	switch {
+	case r.domain == "*":
+		return true
+	case strings.HasPrefix(r.domain, "*."):
+		return strings.HasSuffix(host, r.domain[1:])
+	default:
+		return host == r.domain
+	}
+}
+
+func singleIPNet(ip net.IP) *net.IPNet {
+	if ip4 := ip.To4(); ip4 != nil {
+		return &net.IPNet{IP: ip4, Mask: net.CIDRMask(32, 32)}
+	}
+	return &net.IPNet{IP: ip, Mask: net.CIDRMask(128, 128)}
+}
+
+// accessPolicy decides which clients can use the proxy and where they can go,
+// empty lists mean no restriction.
+type accessPolicy struct {
+	clients []*net.IPNet
+	dests   []*destRule
+	users   map[string]string
+}
+
+func newAccessPolicy(clients, dests []string, users []*User) (*accessPolicy, error) {
+	p := &accessPolicy{}
+
+	for _, c := range clients {
+		c = strings.TrimSpace(c)
+		if _, ipnet, err := net.ParseCIDR(c); err == nil {
+			p.clients = append(p.clients, ipnet)
+		} else if ip := net.ParseIP(c); ip != nil {
+			p.clients = append(p.clients, singleIPNet(ip))
+		} else {
+			return nil, fmt.Errorf("invalid client %q, expect IP or CIDR", c)
+		}
+	}
+
+	for _, d := range dests {
+		r, err := parseDestRule(d)
+		if err != nil {
+			return nil, err
+		}
+		p.dests = append(p.dests, r)
+	}
+
+	for _, u := range users {
+		if u == nil || u.Username == "" {
+			return nil, fmt.Errorf("username required")
+		}
+		if p.users == nil {
+			p.users = map[string]string{}
+		}
+		p.users[u.Username] = u.Password
+	}
+
+	return p, nil
+}
+
+func (p *accessPolicy) allowClient(ip string) bool {
+	if len(p.clients) == 0 {
+		return true
+	}
+
+	x := net.ParseIP(ip)
+	if x == nil {
+		return false
+	}
+
+	for _, ipnet := range p.clients {
+		if ipnet.Contains(x) {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticate check the Proxy-Authorization header.
+func (p *accessPolicy) authenticate(h http.Header) bool {
+	if len(p.users) == 0 {
+		return true
+	}
+
+	auth := h.Get("Proxy-Authorization")
+	const prefix = "Basic "
+	if len(auth) < len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return false
+	}
+
+	raw, err := base64.StdEncoding.DecodeString(auth[len(prefix):])
+	if err != nil {
+		return false
+	}
+
+	username, password, ok := strings.Cut(string(raw), ":")
+	if !ok {
+		return false
+	}
+
+	expect, ok := p.users[username]
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(expect), []byte(password)) == 1
+}
+
+// matchDestination check host[:port], defaultPort used if no port in it. The
+// matched entry of allowed destinations returned, or denied if not allowed.
+// It's used as metric label instead of the destination sent by the client,
+// so that the label values are bounded.
+func (p *accessPolicy) matchDestination(hostport string, defaultPort int) (string, bool) {
+	if len(p.dests) == 0 {
+		return destAny, true
+	}
+
+	host, port := splitHostPort(hostport, defaultPort)
+	for _, r := range p.dests {
+		if r.match(host, port) {
+			return r.raw, true
+		}
+	}
+	return resultDenied, false
+}
+
+// allowDestination check host[:port], defaultPort used if no port in it.
+func (p *accessPolicy) allowDestination(hostport string, defaultPort int) bool {
+	_, ok := p.matchDestination(hostport, defaultPort)
+	return ok
+}
+
+// check returns the result, the HTTP status to reject the request, and the
+// destination label of metrics.
+func (p *accessPolicy) check(r *http.Request, clientIP, dest string, defaultPort int) (string, int, string) {
+	label, allowed := p.matchDestination(dest, defaultPort)
+
+	if !p.allowClient(clientIP) {
+		return resultDenied, http.StatusForbidden, label
+	}
+
+	if !p.authenticate(r.Header) {
+		return resultUnauthorized, http.StatusProxyAuthRequired, label
+	}
+
+	if !allowed {
+		return resultDenied, http.StatusForbidden, label
+	}
+
+	return resultAllowed, http.StatusOK, label
+}
+
+func splitHostPort(hostport string, defaultPort int) (string, int) {
+	host, p, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return strings.Trim(hostport, "[]"), defaultPort
+	}
+
+	port, err := strconv.Atoi(p)
+	if err != nil {
+		return host, defaultPort
+	}
+	return host, port
+}
+
+// destination returns host:port of the request.
+func destination(hostport string, defaultPort int) string {
+	host, port := splitHostPort(hostport, defaultPort)
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func clientIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return "not-set"
+	}
+	return host
+}
@@ -0,0 +1,335 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	T "testing"
+
+	"github.com/GuanceCloud/cliutils/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDestRule(t *T.T) {
+	cases := []struct {
+		rule string
+		host string
+		port int
+		pass bool
+	}{
+		{"openway.guance.com", "openway.guance.com", 443, true},
+		{"openway.guance.com", "OPENWAY.guance.com.", 80, true},
+		{"openway.guance.com:443", "openway.guance.com", 80, false},
+		{"*.guance.com", "openway.guance.com", 443, true},
+		{"*.guance.com", "guance.com", 443, false},
+		{"*.guance.com", "evilguance.com", 443, false},
+		{"*", "example.com", 1, true},
+		{"10.0.0.0/8:8000-9000", "10.1.2.3", 8080, true},
+		{"10.0.0.0/8:8000-9000", "10.1.2.3", 9001, false},
+		{"10.0.0.0/8", "11.1.2.3", 80, false},
+		{"10.0.0.0/8", "ten.example.com", 80, false}, // domains not resolved
+		{"192.168.1.1:*", "192.168.1.1", 22, true},
+		{"[fd00::/8]:443", "fd00::1", 443, true},
+		{"[fd00::/8]:443", "fe00::1", 443, false},
+		{"::1", "::1", 9529, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(fmt.Sprintf("%s-%s:%d", tc.rule, tc.host, tc.port), func(t *T.T) {
+			r, err := parseDestRule(tc.rule)
+			require.NoError(t, err)
+			assert.Equal(t, tc.pass, r.match(tc.host, tc.port))
+		})
+	}
+
+	for _, rule := range []string{"", ":80", "a.com:abc", "a.com:9000-8000", "a.com:0", "[::1", "[::1]80"} {
+		_, err := parseDestRule(rule)
+		assert.Errorf(t, err, "rule %q", rule)
+	}
+}
+
+func TestAccessPolicy(t *T.T) {
+	_, err := newAccessPolicy([]string{"not-ip"}, nil, nil)
+	assert.Error(t, err)
+
+	_, err = newAccessPolicy(nil, nil, []*User{{Password: "abc"}})
+	assert.Error(t, err)
+
+	p, err := newAccessPolicy(nil, nil, nil)
+	require.NoError(t, err)
+	assert.True(t, p.allowClient("1.2.3.4"))
+	assert.True(t, p.authenticate(http.Header{}))
+	assert.True(t, p.allowDestination("example.com", 80))
+
+	p, err = newAccessPolicy(
+		[]string{"10.0.0.0/8", "192.168.1.10"},
+		[]string{"*.guance.com:443"},
+		[]*User{{Username: "dk", Password: "secret"}},
+	)
+	require.NoError(t, err)
+
+	assert.True(t, p.allowClient("10.2.3.4"))
+	assert.True(t, p.allowClient("192.168.1.10"))
+	assert.False(t, p.allowClient("192.168.1.11"))
+	assert.False(t, p.allowClient("not-set"))
+
+	req, err := http.NewRequest(http.MethodGet, "http://openway.guance.com", nil)
+	require.NoError(t, err)
+
+	assert.False(t, p.authenticate(req.Header))
+	req.Header.Set("Proxy-Authorization", "Basic not-base64")
+	assert.False(t, p.authenticate(req.Header))
+
+	req.SetBasicAuth("dk", "wrong")
+	req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+	assert.False(t, p.authenticate(req.Header))
+
+	req.SetBasicAuth("dk", "secret")
+	req.Header.Set("Proxy-Authorization", req.Header.Get("Authorization"))
+	assert.True(t, p.authenticate(req.Header))
+
+	assert.True(t, p.allowDestination("openway.guance.com:443", 80))
+	assert.False(t, p.allowDestination("openway.guance.com", 80))
+
+	// label is the matched rule, not the destination from client
+	result, status, label := p.check(req, "10.0.0.1", "openway.guance.com:443", 443)
+	assert.Equal(t, resultAllowed, result)
+	assert.Equal(t, http.StatusOK, status)
+	assert.Equal(t, "*.guance.com:443", label)
+
+	result, status, label = p.check(req, "10.0.0.1", "example.com:443", 443)
+	assert.Equal(t, resultDenied, result)
+	assert.Equal(t, http.StatusForbidden, status)
+	assert.Equal(t, resultDenied, label)
+
+	result, status, _ = p.check(req, "172.16.0.1", "openway.guance.com:443", 443)
+	assert.Equal(t, resultDenied, result)
+	assert.Equal(t, http.StatusForbidden, status)
+
+	req.Header.Del("Proxy-Authorization")
+	result, status, _ = p.check(req, "10.0.0.1", "openway.guance.com:443", 443)
+	assert.Equal(t, resultUnauthorized, result)
+	assert.Equal(t, http.StatusProxyAuthRequired, status)
+
+	p, err = newAccessPolicy(nil, nil, nil)
+	require.NoError(t, err)
+	_, _, label = p.check(req, "10.0.0.1", "example.com:443", 443)
+	assert.Equal(t, destAny, label)
+}
+
+func startProxy(t *T.T, ipt *Input) string {
+	t.Helper()
+
+	require.NoError(t, ipt.doInitProxy())
+
+	go func() {
+		_ = ipt.proxyServer.Serve(ipt.ln)
+	}()
+
+	t.Cleanup(func() {
+		_ = ipt.proxyServer.Close()
+	})
+
+	return fmt.Sprintf("http://127.0.0.1:%d", ipt.ln.Addr().(*net.TCPAddr).Port)
+}
+
+func proxyClient(proxyURL string) *http.Client {
+	return &http.Client{
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true}, //nolint:gosec
+			Proxy: func(req *http.Request) (*url.URL, error) {
+				return url.Parse(proxyURL)
+			},
+		},
+	}
+}
+
+func TestProxyAccessPolicy(t *T.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Empty(t, r.Header.Get("Proxy-Authorization"))
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(bytes.Repeat([]byte("x"), len(body)*2))
+	}))
+	defer ts.Close()
+
+	tsTLS := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	}))
+	defer tsTLS.Close()
+
+	tsHost := strings.TrimPrefix(ts.URL, "http://")
+	tlsHost := strings.TrimPrefix(tsTLS.URL, "https://")
+
+	t.Run("http", func(t *T.T) {
+		resetMetrics()
+
+		proxyURL := startProxy(t, &Input{
+			Bind:                "127.0.0.1",
+			AllowedClients:      []string{"127.0.0.0/8"},
+			AllowedDestinations: []string{tsHost},
+			Users:               []*User{{Username: "dk", Password: "secret"}},
+		})
+
+		// no auth
+		resp, err := proxyClient(proxyURL).Post(ts.URL, "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, http.StatusProxyAuthRequired, resp.StatusCode)
+		assert.Equal(t, `Basic realm="datakit"`, resp.Header.Get("Proxy-Authenticate"))
+
+		authURL := strings.Replace(proxyURL, "http://", "http://dk:secret@", 1)
+
+		resp, err = proxyClient(authURL).Post(ts.URL, "text/plain", strings.NewReader("hello"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Len(t, body, 10)
+
+		// destination not allowed
+		resp, err = proxyClient(authURL).Get(tsTLS.URL)
+		assert.Error(t, err) // CONNECT rejected
+		if resp != nil {
+			resp.Body.Close() //nolint:errcheck,gosec
+		}
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(allMetrics()...)
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+
+		for _, x := range []struct {
+			labels []string
+			value  float64
+		}{
+			{[]string{"127.0.0.1", tsHost, resultUnauthorized}, 1},
+			{[]string{"127.0.0.1", tsHost, resultAllowed}, 1},
+			{[]string{"127.0.0.1", resultDenied, resultDenied}, 1},
+		} {
+			m := metrics.GetMetricOnLabels(mfs, "datakit_input_proxy_client_requests_total", x.labels...)
+			require.NotNilf(t, m, "labels %v, metrics:\n%s", x.labels, metrics.MetricFamily2Text(mfs))
+			assert.Equal(t, x.value, m.GetCounter().GetValue())
+		}
+
+		m := metrics.GetMetricOnLabels(mfs, "datakit_input_proxy_client_bytes_total", "127.0.0.1", tsHost, "sent")
+		require.NotNil(t, m)
+		assert.Equal(t, float64(5), m.GetCounter().GetValue())
+
+		m = metrics.GetMetricOnLabels(mfs, "datakit_input_proxy_client_bytes_total", "127.0.0.1", tsHost, "received")
+		require.NotNil(t, m)
+		assert.Equal(t, float64(10), m.GetCounter().GetValue())
+	})
+
+	t.Run("client-not-allowed", func(t *T.T) {
+		resetMetrics()
+
+		proxyURL := startProxy(t, &Input{Bind: "127.0.0.1", AllowedClients: []string{"10.0.0.0/8"}})
+
+		resp, err := proxyClient(proxyURL).Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+	})
+
+	t.Run("connect-upstream", func(t *T.T) {
+		resetMetrics()
+
+		upstreamURL := startProxy(t, &Input{
+			Bind:  "127.0.0.1",
+			Users: []*User{{Username: "corp", Password: "pass"}},
+		})
+
+		proxyURL := startProxy(t, &Input{
+			Bind:          "127.0.0.1",
+			UpstreamProxy: strings.Replace(upstreamURL, "http://", "http://corp:pass@", 1),
+		})
+
+		resp, err := proxyClient(proxyURL).Get(tsTLS.URL)
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, "hello", string(body))
+
+		resp, err = proxyClient(proxyURL).Get(ts.URL)
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(allMetrics()...)
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+
+		// both proxies count the tunnel and the request, no allowed destinations
+		// configured, so all under destination *
+		m := metrics.GetMetricOnLabels(mfs, "datakit_input_proxy_client_requests_total", "127.0.0.1", destAny, resultAllowed)
+		require.NotNil(t, m)
+		assert.Equal(t, float64(4), m.GetCounter().GetValue())
+
+		m = metrics.GetMetricOnLabels(mfs, "datakit_input_proxy_client_bytes_total", "127.0.0.1", destAny, "received")
+		require.NotNil(t, m)
+		assert.Greater(t, m.GetCounter().GetValue(), float64(0))
+	})
+
+	t.Run("mitm-destination", func(t *T.T) {
+		resetMetrics()
+
+		proxyURL := startProxy(t, &Input{
+			Bind:                "127.0.0.1",
+			MITM:                true,
+			AllowedDestinations: []string{tlsHost},
+		})
+
+		// CONNECT to the allowed destination, then request another host within the tunnel
+		conn, err := net.Dial("tcp", strings.TrimPrefix(proxyURL, "http://"))
+		require.NoError(t, err)
+		defer conn.Close() //nolint:errcheck
+
+		_, err = fmt.Fprintf(conn, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", tlsHost, tlsHost)
+		require.NoError(t, err)
+
+		br := bufio.NewReader(conn)
+		resp, err := http.ReadResponse(br, nil)
+		require.NoError(t, err)
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		tlsConn := tls.Client(conn, &tls.Config{InsecureSkipVerify: true}) //nolint:gosec
+		_, err = fmt.Fprintf(tlsConn, "GET https://%s/ HTTP/1.1\r\nHost: %s\r\n\r\n", tsHost, tsHost)
+		require.NoError(t, err)
+
+		resp, err = http.ReadResponse(bufio.NewReader(tlsConn), nil)
+		require.NoError(t, err)
+		resp.Body.Close() //nolint:errcheck,gosec
+		assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		reg := prometheus.NewRegistry()
+		reg.MustRegister(allMetrics()...)
+		mfs, err := reg.Gather()
+		require.NoError(t, err)
+
+		m := metrics.GetMetricOnLabels(mfs, "datakit_input_proxy_client_requests_total", "127.0.0.1", resultDenied, resultDenied)
+		require.NotNil(t, m)
+		assert.Equal(t, float64(1), m.GetCounter().GetValue())
+	})
+
+	t.Run("invalid-upstream", func(t *T.T) {
+		ipt := &Input{Bind: "127.0.0.1", UpstreamProxy: "socks5://127.0.0.1:1080"}
+		assert.Error(t, ipt.doInitProxy())
+	})
+}
@@ -6,6 +6,9 @@
 package proxy
 
 import (
+	"io"
+	"net"
+
 	"github.com/GuanceCloud/cliutils/metrics"
 	"github.com/prometheus/client_golang/prometheus"
 )
@@ -14,6 +17,9 @@ var (
 	proxyReqVec        *prometheus.CounterVec
 	proxyConnectVec    *prometheus.CounterVec
 	proxyReqLatencyVec *prometheus.SummaryVec
+
+	proxyClientReqVec   *prometheus.CounterVec
+	proxyClientBytesVec *prometheus.CounterVec
 )
 
 func metricsSetup() {
@@ -61,16 +67,104 @@ func metricsSetup() {
 			"status",
 		},
 	)
+
+	proxyClientReqVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "input_proxy",
+			Name:      "client_requests_total",
+			Help:      "Proxied requests(and CONNECT tunnels) of each client and destination(the matched entry of allowed destinations, * if not configured, denied if not allowed), result is one of allowed/denied/unauthorized",
+		},
+		[]string{
+			"client_ip",
+			"destination",
+			"result",
+		},
+	)
+
+	proxyClientBytesVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "input_proxy",
+			Name:      "client_bytes_total",
+			Help:      "Proxied bytes of each client and destination(the matched entry of allowed destinations), direction is sent(client to destination) or received",
+		},
+		[]string{
+			"client_ip",
+			"destination",
+			"direction",
+		},
+	)
 }
 
 func allMetrics() []prometheus.Collector {
-	return []prometheus.Collector{proxyReqVec, proxyReqLatencyVec, proxyConnectVec}
+	return []prometheus.Collector{
+		proxyReqVec,
+		proxyReqLatencyVec,
+		proxyConnectVec,
+		proxyClientReqVec,
+		proxyClientBytesVec,
+	}
 }
 
 func resetMetrics() {
 	proxyReqLatencyVec.Reset()
 	proxyReqVec.Reset()
 	proxyConnectVec.Reset()
+	proxyClientReqVec.Reset()
+	proxyClientBytesVec.Reset()
+}
+
+// countingBody count bytes read from request/response body.
+type countingBody struct {
+	io.ReadCloser
+	counter prometheus.Counter
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if n > 0 {
+		b.counter.Add(float64(n))
+	}
+	return n, err
+}
+
+// countingConn count bytes of CONNECT tunnel, writes to the destination are sent
+// by client, and reads are received.
+type countingConn struct {
+	net.Conn
+	sent, received prometheus.Counter
+}
+
+func (c *countingConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.received.Add(float64(n))
+	}
+	return n, err
+}
+
+func (c *countingConn) Write(p []byte) (int, error) {
+	n, err := c.Conn.Write(p)
+	if n > 0 {
+		c.sent.Add(float64(n))
+	}
+	return n, err
+}
+
+// CloseWrite and CloseRead keep the tunnel half-closable as *net.TCPConn.
+func (c *countingConn) CloseWrite() error {
+	if hc, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return hc.CloseWrite()
+	}
+	return c.Conn.Close()
+}
+
+func (c *countingConn) CloseRead() error {
+	if hc, ok := c.Conn.(interface{ CloseRead() error }); ok {
+		return hc.CloseRead()
+	}
+	return c.Conn.Close()
 }
 
 //nolint:gochecknoinits
@@ -134,7 +134,10 @@ func textProcessor(opts []iprom.PromOption, feeder dkio.Feeder, body io.Reader,
 		return err
 	}
 
-	return feeder.FeedV2(point.Metric, pts, dkio.WithInputName(inputName), dkio.DisableGlobalTags(true))
+	return feeder.FeedV2(point.Metric, pts,
+		dkio.WithInputName(inputName),
+		dkio.DisableGlobalTags(true),
+		dkio.WithRequestHandler(true))
 }
 
 func protobufProcessor(opts []iprom.PromOption, feeder dkio.Feeder, body io.Reader, tags map[string]string) error {
@@ -164,7 +167,10 @@ func protobufProcessor(opts []iprom.PromOption, feeder dkio.Feeder, body io.Read
 		return err
 	}
 
-	return feeder.FeedV2(point.Metric, pts, dkio.WithInputName(inputName), dkio.DisableGlobalTags(true))
+	return feeder.FeedV2(point.Metric, pts,
+		dkio.WithInputName(inputName),
+		dkio.DisableGlobalTags(true),
+		dkio.WithRequestHandler(true))
 }
 
 func init() { //nolint:gochecknoinits
@@ -13,7 +13,7 @@ import sys
 sys.path.append(${PythonCorePath})
 sys.path.extend(${CustomerDefinedScriptRoot})
 
-from datakit_framework import DataKitFramework
+from datakit_framework import DataKitFramework, plugin_enabled
 
 PY2 = sys.version_info[0] == 2
 PY3 = sys.version_info[0] == 3
@@ -30,6 +30,8 @@ def init_log():
 def mylog(msg, *args, **kwargs):
     logger.debug(time.strftime("%Y-%m-%d %H:%M:%S ", time.localtime()) + msg, *args, **kwargs)
 
+stopped = threading.Event()
+
 class RunThread (threading.Thread):
 	__plugin = DataKitFramework()
 	__interval = 10
@@ -42,12 +44,12 @@ class RunThread (threading.Thread):
 
 	def run(self):
 		if self.__plugin:
-			while True:
+			while not stopped.is_set():
 				try:
 					self.__plugin.run()
 				except:
 					mylog("Unexpected error: info = %s, script = '%s'", sys.exc_info(), self.__plugin.name)
-				time.sleep(self.__interval)
+				stopped.wait(self.__interval)
 
 def search_plugin(plugin_path):
 	try:
@@ -77,9 +79,19 @@ def main(*args):
 
     for plg in plugins:
         thd = RunThread(plg)
+        thd.daemon = True
         thd.start()
         threads.append(thd)
 
+    if plugin_enabled():
+        # supervised by Datakit, exit once Datakit ask to
+        import datakit_plugin
+        cli = datakit_plugin.default_client()
+        cli.keep_alive()
+        stopped.set()
+        cli.close()
+        return
+
     for t in threads:
         t.join()
 
@@ -7,6 +7,11 @@ import logging
 from logging.handlers import RotatingFileHandler
 import requests
 
+try:
+    import datakit_plugin
+except ImportError:  # grpcio not installed
+    datakit_plugin = None
+
 logger = logging.getLogger('pythond_framework')
 
 '''
@@ -77,6 +82,10 @@ class DataKitFramework(object):
         if 'version' in data:
             version = data['version']
 
+        # started by Datakit as supervised plugin, feed via the plugin protocol
+        if plugin_enabled():
+            return self.report_plugin(data, precision)
+
         s = Template('http://${s1}:${s2}/v1/write/${s3}?')
         origin_url = s.safe_substitute(s1=self.__dk_host, s2=self.__dk_port, s3=self.__magic)
         if precision:
@@ -115,6 +124,14 @@ class DataKitFramework(object):
 
         return response
 
+    def report_plugin(self, data, precision):
+        cli = datakit_plugin.default_client()
+        n = 0
+        for key, category in (('M', 'metric'), ('L', 'logging'), ('R', 'rum'), ('O', 'object'), ('CO', 'custom_object'), ('E', 'keyevent')):
+            if data.get(key):
+                n += cli.feed(category, data[key], precision)
+        return n
+
 
     def checkArgEmpty(self, name, checkStr):
         if not checkStr:
@@ -302,6 +319,10 @@ class DataKitFramework(object):
 
 
     def set_lasterror(self, input_name, err_msg):
+        if plugin_enabled():
+            datakit_plugin.default_client().set_error(err_msg)
+            return
+
         base_url = self.construct_url("v1/lasterror")
         raw_data = {
             "input":input_name,
@@ -342,6 +363,9 @@ class DataKitFramework(object):
 
         return html.text
 
+def plugin_enabled():
+    return datakit_plugin is not None and datakit_plugin.enabled()
+
 def init_log():
     log_path = os.path.join(os.path.expanduser('~'), "_datakit_pythond_framework_" + DataKitFramework.log_name + "_.log")
     print(log_path)
@@ -0,0 +1,216 @@
+#encoding: utf-8
+
+'''
+Python SDK of Datakit supervised plugins.
+
+The plugin is started by Datakit with environments DATAKIT_PLUGIN_SOCKET,
+DATAKIT_PLUGIN_NAME and DATAKIT_PLUGIN_TOKEN, it connect back to Datakit via
+gRPC over the Unix socket, see plugin.proto within Datakit source for the
+protocol. plugin_pb2.py and plugin_pb2_grpc.py are generated from plugin.proto,
+they require grpcio and protobuf:
+
+    pip install grpcio protobuf
+
+Usage:
+
+    import datakit_plugin
+
+    cli = datakit_plugin.Client()
+    cli.start_keep_alive()
+    while not cli.done.wait(10):
+        cli.feed('metric', [{'measurement': 'demo', 'tags': {'t1': 'a'}, 'fields': {'f1': 1}}])
+'''
+
+import os
+import signal
+import threading
+import time
+
+import grpc
+
+import plugin_pb2
+import plugin_pb2_grpc
+
+PROTOCOL_VERSION = 1
+SDK_NAME = 'python/1'
+
+ENV_SOCKET = 'DATAKIT_PLUGIN_SOCKET'
+ENV_NAME = 'DATAKIT_PLUGIN_NAME'
+ENV_TOKEN = 'DATAKIT_PLUGIN_TOKEN'
+TOKEN_METADATA = 'x-datakit-plugin-token'
+
+_TIMEOUT = 10
+_MAX_HEARTBEAT_FAILURES = 3
+
+
+def enabled():
+    '''True if the process is started by Datakit as a plugin.'''
+    return bool(os.environ.get(ENV_SOCKET))
+
+
+# ---------------- line protocol ----------------
+
+def _escape(s, chars):
+    s = str(s)
+    for c in chars:
+        s = s.replace(c, '\\' + c)
+    return s
+
+
+def _field_value(v):
+    if isinstance(v, bool):
+        return 'true' if v else 'false'
+    if isinstance(v, int):
+        return '%di' % v
+    if isinstance(v, float):
+        return repr(v)
+    return '"' + str(v).replace('\\', '\\\\').replace('"', '\\"') + '"'
+
+
+def to_line_protocol(points):
+    '''points: list of dict with measurement/tags/fields/time.'''
+    lines = []
+    for pt in points:
+        fields = dict((k, v) for k, v in (pt.get('fields') or {}).items() if v is not None)
+        if not fields:
+            continue
+
+        line = _escape(pt['measurement'], ', ')
+        for k, v in sorted((pt.get('tags') or {}).items()):
+            if v is None or v == '':
+                continue
+            line += ',' + _escape(k, ',= ') + '=' + _escape(v, ',= ')
+
+        line += ' ' + ','.join(_escape(k, ',= ') + '=' + _field_value(v) for k, v in sorted(fields.items()))
+
+        if pt.get('time'):
+            line += ' %d' % int(pt['time'])
+
+        lines.append(line)
+    return '\n'.join(lines)
+
+
+# ---------------- client ----------------
+
+class Client(object):
+    '''Client of Datakit plugin host.'''
+
+    def __init__(self, socket=None, token=None, name=None, retry=3, retry_wait=1.0):
+        self.socket = socket or os.environ.get(ENV_SOCKET)
+        if not self.socket:
+            raise RuntimeError(ENV_SOCKET + ' not set, the plugin should be started by Datakit')
+
+        self.name = name or os.environ.get(ENV_NAME, '')
+        self.retry = retry
+        self.retry_wait = retry_wait
+        self.done = threading.Event()
+
+        self._metadata = ((TOKEN_METADATA, token or os.environ.get(ENV_TOKEN, '')),)
+        self._error = None
+        self._lock = threading.Lock()
+        self._channel = grpc.insecure_channel('unix:' + self.socket)
+        self._stub = plugin_pb2_grpc.HostStub(self._channel)
+
+        resp = self._stub.Handshake(plugin_pb2.HandshakeRequest(
+            protocol_version=PROTOCOL_VERSION,
+            name=self.name,
+            pid=os.getpid(),
+            sdk=SDK_NAME,
+        ), metadata=self._metadata, timeout=_TIMEOUT)
+
+        self.config = resp.config
+        self.heartbeat_interval = (resp.heartbeat_interval_ms or 10000) / 1000.0
+
+        self._watch_signal()
+
+    def _watch_signal(self):
+        '''set done on SIGTERM, which sent by Datakit on stop.'''
+        try:
+            signal.signal(signal.SIGTERM, lambda signum, frame: self.done.set())
+        except ValueError:
+            pass  # not within the main thread
+
+    def feed(self, category, points, precision=''):
+        '''
+        category: metric/logging/object/custom_object/keyevent/...
+        points: line-protocol text or list of dict with measurement/tags/fields/time
+        precision: time precision of points, n/u/ms/s, default n
+        '''
+        if not isinstance(points, (str, bytes)):
+            points = to_line_protocol(points)
+        if not points:
+            return 0
+
+        if isinstance(points, str):
+            points = points.encode('utf-8')
+
+        req = plugin_pb2.FeedRequest(
+            category=category,
+            encoding=plugin_pb2.LINE_PROTOCOL,
+            points=points,
+            precision=precision,
+        )
+
+        for i in range(self.retry + 1):
+            if i > 0:
+                time.sleep(self.retry_wait * i)
+            try:
+                return self._stub.Feed(req, metadata=self._metadata, timeout=_TIMEOUT).points
+            except grpc.RpcError as e:
+                if e.code() not in (grpc.StatusCode.UNAVAILABLE, grpc.StatusCode.DEADLINE_EXCEEDED) or i == self.retry:
+                    raise
+
+    def set_error(self, message):
+        '''set error reported to Datakit on next heartbeat, None to clear it.'''
+        with self._lock:
+            self._error = message
+
+    def heartbeat(self):
+        '''returns True if Datakit ask the plugin to exit.'''
+        with self._lock:
+            err = self._error
+
+        if err:
+            req = plugin_pb2.HeartbeatRequest(status='error', message=str(err))
+        else:
+            req = plugin_pb2.HeartbeatRequest(status='ok')
+
+        return self._stub.Heartbeat(req, metadata=self._metadata, timeout=_TIMEOUT).shutdown
+
+    def keep_alive(self):
+        '''send heartbeat until Datakit ask the plugin to exit or Datakit not reachable.'''
+        failures = 0
+        while not self.done.is_set():
+            try:
+                if self.heartbeat():
+                    break
+                failures = 0
+            except grpc.RpcError:
+                failures += 1
+                if failures >= _MAX_HEARTBEAT_FAILURES:
+                    break
+            self.done.wait(self.heartbeat_interval)
+        self.done.set()
+
+    def start_keep_alive(self):
+        thd = threading.Thread(target=self.keep_alive)
+        thd.daemon = True
+        thd.start()
+        return thd
+
+    def close(self):
+        self.done.set()
+        self._channel.close()
+
+
+_default = None
+_default_lock = threading.Lock()
+
+
+def default_client():
+    '''Client shared within the process, created on first call.'''
+    global _default
+    with _default_lock:
+        if _default is None:
+            _default = Client()
+        return _default
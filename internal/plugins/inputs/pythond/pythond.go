@@ -27,6 +27,7 @@ import (
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/path"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/plugin"
 )
 
 const (
@@ -42,7 +43,18 @@ const (
   cmd = "python3" # required. python3 is recommended.
 
   # Python scripts relative path
-  dirs = []`
+  dirs = []
+
+  # Run scripts as supervised plugin: Datakit restart the scripts if they exit
+  # or no heartbeat received within heartbeat_timeout, and points sent via
+  # the plugin protocol(Python packages grpcio and protobuf required).
+  #plugin = false
+  #heartbeat_timeout = "30s"
+
+  # CPU(percent, max is 100) and memory(MB) limit of the scripts, plugin mode only.
+  #[inputs.pythond.resource_limit]
+  #  cpu_max = 10.0
+  #  mem_max_mb = 512`
 )
 
 var (
@@ -59,6 +71,10 @@ type Input struct {
 	Envs []string          `toml:"envs"`
 	Tags map[string]string `toml:"tags"` // TODO
 
+	Plugin           bool                  `toml:"plugin"`
+	HeartbeatTimeout time.Duration         `toml:"heartbeat_timeout"`
+	ResourceLimit    *plugin.ResourceLimit `toml:"resource_limit"`
+
 	cmd    *exec.Cmd
 	feeder io.Feeder // TODO
 
@@ -85,22 +101,30 @@ func getCliPyScript(scriptRoot, scriptName string) string {
 	return os.Expand(pyCli, func(k string) string { return replacePair[k] })
 }
 
-func (ipt *Input) start() error {
+func (ipt *Input) writeCliPyScript() (*os.File, error) {
 	cli := getCliPyScript(ipt.scriptRoot, ipt.scriptName)
 
 	pyTmpFle, err := ioutil.TempFile("", "pythond_")
 	if err != nil {
 		l.Errorf("ioutil.TempFile failed: %s", err.Error())
-		return err
+		return nil, err
 	}
 
 	n, err := pyTmpFle.WriteString(cli)
 	if err != nil {
 		l.Errorf("TempFile.WriteString failed: %s", err.Error())
-		return err
+		return nil, err
 	}
 
 	l.Debugf("python tmp = %s, written: %d", pyTmpFle.Name(), n)
+	return pyTmpFle, nil
+}
+
+func (ipt *Input) start() error {
+	pyTmpFle, err := ipt.writeCliPyScript()
+	if err != nil {
+		return err
+	}
 
 	ipt.cmd = exec.Command(ipt.Cmd, pyTmpFle.Name(), fmt.Sprintf("--logname=%s", ipt.Name)) //nolint:gosec
 	if ipt.Envs != nil {
@@ -332,6 +356,11 @@ func (ipt *Input) Run() {
 
 	l.Debugf("pe.scriptName = %v, pe.scriptRoot = %v", ipt.scriptName, ipt.scriptRoot)
 
+	if ipt.Plugin {
+		ipt.runPlugin()
+		return
+	}
+
 	for {
 		if err := ipt.start(); err != nil { // start failed, retry
 			time.Sleep(time.Second)
@@ -345,6 +374,59 @@ func (ipt *Input) Run() {
 	}
 }
 
+// runPlugin run the scripts under plugin supervisor until Datakit exit or
+// the input terminated.
+func (ipt *Input) runPlugin() {
+	var (
+		pyTmpFle *os.File
+		err      error
+	)
+
+	for {
+		if pyTmpFle, err = ipt.writeCliPyScript(); err == nil {
+			break
+		}
+
+		select {
+		case <-datakit.Exit.Wait():
+			return
+		case <-ipt.semStop.Wait():
+			return
+		case <-time.After(time.Second):
+		}
+	}
+
+	defer func() {
+		_ = pyTmpFle.Close()
+		_ = os.Remove(pyTmpFle.Name())
+	}()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		select {
+		case <-datakit.Exit.Wait():
+		case <-ipt.semStop.Wait():
+		}
+		cancel()
+	}()
+
+	s := plugin.NewSupervisor(ipt.Name, ipt.Cmd,
+		plugin.WithArgs(pyTmpFle.Name(), fmt.Sprintf("--logname=%s", ipt.Name)),
+		plugin.WithEnvs(ipt.Envs...),
+		plugin.WithTags(ipt.Tags),
+		plugin.WithHeartbeatTimeout(ipt.HeartbeatTimeout),
+		plugin.WithResourceLimit(ipt.ResourceLimit),
+		plugin.WithFeeder(ipt.feeder),
+	)
+
+	l.Infof("starting pythond plugin %s", ipt.Name)
+	if err := s.Run(ctx); err != nil {
+		l.Errorf("pythond plugin %s: %s", ipt.Name, err)
+	}
+}
+
 func (ipt *Input) MonitProc() error {
 	tick := time.NewTicker(time.Second)
 	defer tick.Stop()
@@ -40,6 +40,12 @@ func ReleaseFiles() error {
 		return err
 	}
 
+	for _, name := range []string{"datakit_plugin.py", "plugin_pb2.py", "plugin_pb2_grpc.py"} {
+		if err := releaseEmbedFile(datakit.PythonCoreDir, name, &pyDatakitPlugin); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -48,6 +54,9 @@ func ReleaseFiles() error {
 //go:embed pys/datakit_framework.py
 var pyDatakitFramework embed.FS
 
+//go:embed pys/datakit_plugin.py pys/plugin_pb2.py pys/plugin_pb2_grpc.py
+var pyDatakitPlugin embed.FS
+
 //go:embed pys/cli.py
 var pyCli string
 
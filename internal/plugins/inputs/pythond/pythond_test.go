@@ -42,7 +42,7 @@ func TestGetCliPyScript(t *testing.T) {
 
 	cli := getCliPyScript(scriptRoot, scriptName)
 
-	expectMD5 := "3701245679c4e4e4a4b9c7bafdba8947"
+	expectMD5 := "a9bdbca8cf15279d2b3b9944ad22499a"
 
 	fmt.Println(cli)
 	assert.Equal(t, expectMD5, md5sum(cli), "md5 not equal!")
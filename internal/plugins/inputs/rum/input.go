@@ -120,6 +120,25 @@ const (
   #       "{ service = 'xxx' or version IN [ 'v1', 'v2'] }",
   #       "{ app_id = 'yyy' and env = 'production' }"
   #   ]
+
+  ## local_store keep session replay data on local disk, it's useful when the data must stay on-premises.
+  ## path is the directory to store session replay segments.
+  ## retention is how long a session is kept after its last update.
+  ## capacity_mb is the max disk space (in MiB) of the store, oldest sessions are removed when exceeded.
+  ## upload set whether we should still upload session replay data to dataway.
+  ## api_token is the token(within header "Authorization: Bearer <token>") to access the playback API,
+  ## the API is disabled if not set.
+  ## mask_rules replace DOM text and input values matched by pattern before saving and uploading.
+  # [inputs.rum.session_replay.local_store]
+  #   enable = false
+  #   path = "/usr/local/datakit/data/rum/session_replay"
+  #   retention = "168h"
+  #   capacity_mb = 10240
+  #   upload = true
+  #   api_token = ""
+  #   [[inputs.rum.session_replay.local_store.mask_rules]]
+  #     pattern = '1[3-9]\d{9}'
+  #     replace = "***"
 `
 )
 
@@ -138,6 +157,7 @@ var (
 	wkpool             *workerpool.WorkerPool
 	localCache         *storage.Storage
 	replayWorkersGroup *goroutine.Group
+	replayStoreGroup   *goroutine.Group
 )
 
 var kunlunCDNGlob = glob.MustCompile(`*.kunlun*.com`)
@@ -161,6 +181,7 @@ type Input struct {
 	replayUploadAPI        string
 	replayHTTPClient       *http.Client
 	replayDiskQueue        *diskcache.DiskCache
+	replayStore            *replayStore
 	semStop                *cliutils.Sem // start stop signal
 }
 
@@ -254,6 +275,11 @@ func (ipt *Input) RegHTTPHandler() {
 			httpapi.RegHTTPHandler(http.MethodPost, endpoint, handler)
 			log.Infof("register RUM replay upload endpoint: %s", endpoint)
 		}
+
+		if ipt.replayStore != nil {
+			httpapi.RegHTTPRoute(http.MethodGet, replaySessionsAPI, ipt.handleReplaySessions)
+			httpapi.RegHTTPRoute(http.MethodGet, replaySegmentsAPI, ipt.handleReplaySegments)
+		}
 	}
 
 	// add handler for sourcemap related api
@@ -338,6 +364,8 @@ func (ipt *Input) Run() {
 		replayReadBodyDelaySeconds,
 		replayFilteredTotalCount,
 		replayFilteredTotalBytes,
+		replayStoreTotalCount,
+		replayStoreBytes,
 	} {
 		if err := metrics.Register(m); err != nil {
 			log.Warnf("regist metrics failed: %s, ignored", err)
@@ -452,6 +480,11 @@ func (ipt *Input) Terminate() {
 			log.Errorf("goroutine [%s] exit abnormal: %s", replayWorkersGroup.Name(), err)
 		}
 	}
+	if replayStoreGroup != nil {
+		if err := replayStoreGroup.Wait(); err != nil {
+			log.Errorf("goroutine [%s] exit abnormal: %s", replayStoreGroup.Name(), err)
+		}
+	}
 
 	// remove http route
 	for _, endpoint := range ipt.Endpoints {
@@ -463,6 +496,8 @@ func (ipt *Input) Terminate() {
 	httpapi.RemoveHTTPRoute(http.MethodGet, "/v1/sourcemap/check")
 	httpapi.RemoveHTTPRoute(http.MethodPut, "/v1/sourcemap")
 	httpapi.RemoveHTTPRoute(http.MethodDelete, "/v1/sourcemap")
+	httpapi.RemoveHTTPRoute(http.MethodGet, replaySessionsAPI)
+	httpapi.RemoveHTTPRoute(http.MethodGet, replaySegmentsAPI)
 }
 
 func defaultInput() *Input {
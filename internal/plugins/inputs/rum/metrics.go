@@ -114,6 +114,20 @@ var replayFilteredTotalBytes = prometheus.NewCounterVec(prometheus.CounterOpts{
 	Help:      "statistics the total bytes of session replay points which have been filtered by rules",
 }, []string{"app_id", "env", "version", "service"})
 
+var replayStoreTotalCount = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: subSystem,
+	Name:      "session_replay_local_store_total",
+	Help:      "statistics the total count of session replay segments saved to local store",
+}, []string{"app_id", "status"})
+
+var replayStoreBytes = prometheus.NewGauge(prometheus.GaugeOpts{
+	Namespace: namespace,
+	Subsystem: subSystem,
+	Name:      "session_replay_local_store_bytes",
+	Help:      "the disk space used by session replay local store",
+})
+
 type sourceMapStatus struct {
 	sdkName string
 	appid   string
@@ -0,0 +1,885 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package rum
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	uhttp "github.com/GuanceCloud/cliutils/network/http"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+)
+
+const (
+	defaultReplayStoreRetention   = time.Hour * 24 * 7
+	defaultReplayStoreCapacityMib = 10240 // 10 Gib
+	defaultReplayMaskReplace      = "***"
+	replayStoreCleanInterval      = time.Minute * 10
+
+	replaySessionsAPI = "/v1/rum/replay/sessions"
+	replaySegmentsAPI = "/v1/rum/replay/segments"
+
+	replayMetaFile   = "meta.json"
+	replaySegmentExt = ".json.gz"
+)
+
+var (
+	// replayIDRegexp restrict app/session/view ID, they are used as file names.
+	replayIDRegexp = regexp.MustCompile(`^[\w.\-]{1,128}$`)
+
+	// replayMaskFields are the fields of rrweb records that carry DOM text or user input.
+	replayMaskFields = map[string]struct{}{
+		"textContent": {},
+		"text":        {},
+		"value":       {},
+		"placeholder": {},
+		"title":       {},
+		"alt":         {},
+	}
+
+	errReplayInvalidID      = errors.New("invalid app_id, session_id or view_id")
+	errReplayInvalidSegment = errors.New("invalid segment")
+)
+
+// ReplayMaskRule replace text matched by Pattern with Replace before storage.
+type ReplayMaskRule struct {
+	Pattern string `toml:"pattern"`
+	Replace string `toml:"replace"`
+
+	re *regexp.Regexp
+}
+
+// ReplayStoreCfg configure the local session replay store.
+type ReplayStoreCfg struct {
+	Enable     bool              `toml:"enable"`
+	Path       string            `toml:"path"`
+	Retention  time.Duration     `toml:"retention"`
+	CapacityMB int64             `toml:"capacity_mb"`
+	Upload     bool              `toml:"upload"`
+	APIToken   string            `toml:"api_token"`
+	MaskRules  []*ReplayMaskRule `toml:"mask_rules"`
+}
+
+func defaultReplayStoreCfg() *ReplayStoreCfg {
+	return &ReplayStoreCfg{
+		Path:       filepath.Join(datakit.DataRUMDir, "session_replay"),
+		Retention:  defaultReplayStoreRetention,
+		CapacityMB: defaultReplayStoreCapacityMib,
+		Upload:     true,
+	}
+}
+
+// replaySegment is the index of a stored segment.
+type replaySegment struct {
+	Seq             int    `json:"seq"`
+	ViewID          string `json:"view_id"`
+	IndexInView     int    `json:"index_in_view"`
+	Start           int64  `json:"start"`
+	End             int64  `json:"end"`
+	RecordsCount    int    `json:"records_count"`
+	HasFullSnapshot bool   `json:"has_full_snapshot"`
+	Size            int64  `json:"size"`
+}
+
+// replaySession is the index of a stored session, it's saved as meta.json within session dir.
+type replaySession struct {
+	AppID     string           `json:"app_id"`
+	SessionID string           `json:"session_id"`
+	Env       string           `json:"env"`
+	Version   string           `json:"version"`
+	Service   string           `json:"service"`
+	Source    string           `json:"source"`
+	Start     int64            `json:"start"`
+	End       int64            `json:"end"`
+	Views     []string         `json:"views"`
+	Size      int64            `json:"size"`
+	UpdatedAt int64            `json:"updated_at"`
+	Segments  []*replaySegment `json:"segments,omitempty"`
+}
+
+type replayStore struct {
+	cfg *ReplayStoreCfg
+	mtx sync.Mutex
+
+	// sessions index all sessions within the store by app and session ID.
+	sessions map[string]*replaySession
+
+	// broken are session dirs with unreadable meta, they are removed on clean.
+	broken map[string]int64
+
+	size int64
+	now  func() time.Time
+}
+
+func newReplayStore(cfg *ReplayStoreCfg) (*replayStore, error) {
+	if cfg.Path == "" {
+		cfg.Path = defaultReplayStoreCfg().Path
+	}
+	if cfg.Retention <= 0 {
+		cfg.Retention = defaultReplayStoreRetention
+	}
+	if cfg.CapacityMB <= 0 {
+		cfg.CapacityMB = defaultReplayStoreCapacityMib
+	}
+
+	for _, r := range cfg.MaskRules {
+		re, err := regexp.Compile(r.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid session replay mask rule %q: %w", r.Pattern, err)
+		}
+		r.re = re
+		if r.Replace == "" {
+			r.Replace = defaultReplayMaskReplace
+		}
+	}
+
+	if err := os.MkdirAll(cfg.Path, datakit.ConfPerm); err != nil {
+		return nil, fmt.Errorf("unable to create session replay store dir: %w", err)
+	}
+
+	s := &replayStore{
+		cfg:      cfg,
+		sessions: map[string]*replaySession{},
+		broken:   map[string]int64{},
+		now:      time.Now,
+	}
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+func (s *replayStore) sessionDir(appID, sessionID string) string {
+	return filepath.Join(s.cfg.Path, appID, sessionID)
+}
+
+func replaySessionKey(appID, sessionID string) string {
+	return appID + "/" + sessionID
+}
+
+func (s *replayStore) masking() bool {
+	return len(s.cfg.MaskRules) > 0
+}
+
+func validReplayID(id string) bool {
+	return id != "." && id != ".." && replayIDRegexp.MatchString(id)
+}
+
+// decodeSegment decompress the segment uploaded by RUM SDK, which is
+// zlib-compressed JSON, plain JSON is also accepted.
+func decodeSegment(data []byte) ([]byte, error) {
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		return trimmed, nil
+	}
+
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress segment: %w", err)
+	}
+	defer r.Close() //nolint:errcheck
+
+	// truncated stream rejected, or partial records saved and uploaded
+	raw, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decompress segment: %w", err)
+	}
+
+	return raw, nil
+}
+
+// prepare decompress the segment and apply mask rules on it.
+func (s *replayStore) prepare(data []byte) ([]byte, error) {
+	raw, err := decodeSegment(data)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", errReplayInvalidSegment, err)
+	}
+
+	if raw, err = s.mask(raw); err != nil {
+		return nil, fmt.Errorf("%w: %s", errReplayInvalidSegment, err)
+	}
+
+	return raw, nil
+}
+
+// mask apply mask rules to DOM text and input values within segment records.
+func (s *replayStore) mask(segment []byte) ([]byte, error) {
+	if len(s.cfg.MaskRules) == 0 {
+		return segment, nil
+	}
+
+	var v any
+	dec := json.NewDecoder(bytes.NewReader(segment))
+	dec.UseNumber()
+	if err := dec.Decode(&v); err != nil {
+		return nil, fmt.Errorf("unable to unmarshal segment: %w", err)
+	}
+
+	return json.Marshal(s.maskValue(v, false))
+}
+
+func (s *replayStore) maskValue(v any, isText bool) any {
+	switch x := v.(type) {
+	case map[string]any:
+		for k, val := range x {
+			_, ok := replayMaskFields[k]
+			x[k] = s.maskValue(val, ok)
+		}
+		return x
+	case []any:
+		for i, val := range x {
+			x[i] = s.maskValue(val, false)
+		}
+		return x
+	case string:
+		if !isText {
+			return x
+		}
+		for _, r := range s.cfg.MaskRules {
+			x = r.re.ReplaceAllString(x, r.Replace)
+		}
+		return x
+	default:
+		return v
+	}
+}
+
+func formValue(form *multipart.Form, key string) string {
+	if v := form.Value[key]; len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func formSegment(form *multipart.Form) ([]byte, error) {
+	files := form.File["segment"]
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w: segment not found", errReplayInvalidSegment)
+	}
+
+	f, err := files[0].Open()
+	if err != nil {
+		return nil, fmt.Errorf("unable to open segment: %w", err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read segment: %w", err)
+	}
+
+	return data, nil
+}
+
+// replaceSegment rebuild the multipart body with the segment replaced by raw,
+// other fields and files are kept as is.
+func replaceSegment(form *multipart.Form, contentType string, raw []byte) ([]byte, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, fmt.Errorf("invalid content type %q: %w", contentType, err)
+	}
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	if err := mw.SetBoundary(params["boundary"]); err != nil {
+		return nil, fmt.Errorf("invalid multipart boundary: %w", err)
+	}
+
+	keys := make([]string, 0, len(form.Value))
+	for k := range form.Value {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		for _, v := range form.Value[k] {
+			if err := mw.WriteField(k, v); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for k, files := range form.File {
+		for i, fh := range files {
+			w, err := mw.CreatePart(fh.Header)
+			if err != nil {
+				return nil, err
+			}
+
+			if k == "segment" && i == 0 {
+				// compressed the same as RUM SDK
+				zw := zlib.NewWriter(w)
+				if _, err := zw.Write(raw); err != nil {
+					return nil, err
+				}
+				if err := zw.Close(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+
+			f, err := fh.Open()
+			if err != nil {
+				return nil, err
+			}
+			_, err = io.Copy(w, f)
+			_ = f.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// putRequest save the segment within the session replay multipart form, and
+// return the body to upload. With mask rules set, the returned body carries the
+// masked segment, and it's nil if the segment can't be masked, so that
+// unmasked data never leaves Datakit.
+func (s *replayStore) putRequest(form *multipart.Form, contentType string, body []byte) ([]byte, error) {
+	if s.masking() {
+		body = nil
+	}
+
+	data, err := formSegment(form)
+	if err != nil {
+		return body, err
+	}
+
+	raw, err := s.prepare(data)
+	if err != nil {
+		return body, err
+	}
+
+	if s.masking() {
+		if body, err = replaceSegment(form, contentType, raw); err != nil {
+			return nil, fmt.Errorf("unable to rebuild masked session replay: %w", err)
+		}
+	}
+
+	sess := &replaySession{
+		AppID:     formValue(form, "app_id"),
+		SessionID: formValue(form, "session_id"),
+		Env:       formValue(form, "env"),
+		Version:   formValue(form, "version"),
+		Service:   formValue(form, "service"),
+		Source:    formValue(form, "source"),
+	}
+
+	seg := &replaySegment{ViewID: formValue(form, "view_id")}
+	seg.IndexInView, _ = strconv.Atoi(formValue(form, "index_in_view"))
+	seg.Start, _ = strconv.ParseInt(formValue(form, "start"), 10, 64)
+	seg.End, _ = strconv.ParseInt(formValue(form, "end"), 10, 64)
+	seg.RecordsCount, _ = strconv.Atoi(formValue(form, "records_count"))
+	seg.HasFullSnapshot, _ = strconv.ParseBool(formValue(form, "has_full_snapshot"))
+
+	return body, s.save(sess, seg, raw)
+}
+
+// put decompress and mask the segment, then save it.
+func (s *replayStore) put(sess *replaySession, seg *replaySegment, data []byte) error {
+	raw, err := s.prepare(data)
+	if err != nil {
+		return err
+	}
+
+	return s.save(sess, seg, raw)
+}
+
+// save the decompressed and masked segment into the session.
+func (s *replayStore) save(sess *replaySession, seg *replaySegment, raw []byte) error {
+	if !validReplayID(sess.AppID) || !validReplayID(sess.SessionID) ||
+		(seg.ViewID != "" && !validReplayID(seg.ViewID)) {
+		return errReplayInvalidID
+	}
+
+	buf := &bytes.Buffer{}
+	zw := gzip.NewWriter(buf)
+	if _, err := zw.Write(raw); err != nil {
+		return fmt.Errorf("unable to compress segment: %w", err)
+	}
+	if err := zw.Close(); err != nil {
+		return fmt.Errorf("unable to compress segment: %w", err)
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	dir := s.sessionDir(sess.AppID, sess.SessionID)
+	if err := os.MkdirAll(dir, datakit.ConfPerm); err != nil {
+		return fmt.Errorf("unable to create session dir: %w", err)
+	}
+
+	key := replaySessionKey(sess.AppID, sess.SessionID)
+	if old, ok := s.sessions[key]; ok {
+		old.Env, old.Version, old.Service, old.Source = sess.Env, sess.Version, sess.Service, sess.Source
+		sess = old
+	}
+
+	seg.Seq = 0
+	if n := len(sess.Segments); n > 0 {
+		seg.Seq = sess.Segments[n-1].Seq + 1
+	} else if size, ok := s.broken[dir]; ok {
+		// meta of the session is unreadable, keep existing segments, they are
+		// still within the size and removed along with the session.
+		log.Warnf("session replay meta in [%s] is unreadable, rebuild it", dir)
+		seg.Seq = nextSegmentSeq(dir)
+		sess.Size = size
+		delete(s.broken, dir)
+	}
+	seg.Size = int64(buf.Len())
+
+	if err := os.WriteFile(filepath.Join(dir, segmentFileName(seg.Seq)), buf.Bytes(), datakit.ConfPerm); err != nil {
+		return fmt.Errorf("unable to write segment: %w", err)
+	}
+
+	sess.Segments = append(sess.Segments, seg)
+	sess.Size += seg.Size
+	sess.UpdatedAt = s.now().UnixMilli()
+	if sess.Start == 0 || (seg.Start > 0 && seg.Start < sess.Start) {
+		sess.Start = seg.Start
+	}
+	if seg.End > sess.End {
+		sess.End = seg.End
+	}
+	if seg.ViewID != "" && !containsString(sess.Views, seg.ViewID) {
+		sess.Views = append(sess.Views, seg.ViewID)
+	}
+
+	s.sessions[key] = sess
+	s.size += seg.Size
+
+	err := writeReplaySession(dir, sess)
+
+	if s.size > s.cfg.CapacityMB*MiB {
+		s.evict(sess.AppID, sess.SessionID)
+	}
+	replayStoreBytes.Set(float64(s.size))
+
+	return err
+}
+
+// nextSegmentSeq find the next segment sequence after existing segment files within dir.
+func nextSegmentSeq(dir string) int {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	next := 0
+	for _, e := range entries {
+		if seq, err := strconv.Atoi(strings.TrimSuffix(e.Name(), replaySegmentExt)); err == nil && seq >= next {
+			next = seq + 1
+		}
+	}
+
+	return next
+}
+
+// dirSize get total size of regular files within dir.
+func dirSize(dir string) int64 {
+	var size int64
+	_ = filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil //nolint:nilerr
+		}
+
+		if fi, err := d.Info(); err == nil {
+			size += fi.Size()
+		}
+		return nil
+	})
+
+	return size
+}
+
+func segmentFileName(seq int) string {
+	return fmt.Sprintf("%06d%s", seq, replaySegmentExt)
+}
+
+func containsString(arr []string, s string) bool {
+	for _, x := range arr {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func readReplaySession(dir string) (*replaySession, error) {
+	data, err := os.ReadFile(filepath.Join(dir, replayMetaFile))
+	if err != nil {
+		return nil, err
+	}
+
+	sess := &replaySession{}
+	if err := json.Unmarshal(data, sess); err != nil {
+		return nil, err
+	}
+	return sess, nil
+}
+
+func writeReplaySession(dir string, sess *replaySession) error {
+	data, err := json.Marshal(sess)
+	if err != nil {
+		return fmt.Errorf("unable to marshal session meta: %w", err)
+	}
+
+	// write to temp file then rename, so readers never see partial meta
+	tmp := filepath.Join(dir, replayMetaFile+".tmp")
+	if err := os.WriteFile(tmp, data, datakit.ConfPerm); err != nil {
+		return fmt.Errorf("unable to write session meta: %w", err)
+	}
+	return os.Rename(tmp, filepath.Join(dir, replayMetaFile))
+}
+
+// load build the session index on all session meta within the store. Sessions
+// with unreadable meta are kept and removed on next clean.
+func (s *replayStore) load() error {
+	apps, err := os.ReadDir(s.cfg.Path)
+	if err != nil {
+		return fmt.Errorf("unable to read session replay store dir: %w", err)
+	}
+
+	for _, app := range apps {
+		if !app.IsDir() {
+			continue
+		}
+
+		sessions, err := os.ReadDir(filepath.Join(s.cfg.Path, app.Name()))
+		if err != nil {
+			log.Warnf("unable to read session replay app dir [%s]: %s", app.Name(), err)
+			continue
+		}
+
+		for _, x := range sessions {
+			if !x.IsDir() {
+				continue
+			}
+
+			dir := filepath.Join(s.cfg.Path, app.Name(), x.Name())
+			sess, err := readReplaySession(dir)
+			if err != nil {
+				log.Warnf("unable to read session replay meta in [%s], it will be removed on clean: %s", dir, err)
+				s.broken[dir] = dirSize(dir)
+				s.size += s.broken[dir]
+				continue
+			}
+
+			s.sessions[replaySessionKey(sess.AppID, sess.SessionID)] = sess
+			s.size += sess.Size
+		}
+	}
+
+	replayStoreBytes.Set(float64(s.size))
+	return nil
+}
+
+func (s *replayStore) removeDir(dir string, size int64) bool {
+	if err := os.RemoveAll(dir); err != nil {
+		log.Warnf("unable to remove session replay dir [%s]: %s", dir, err)
+		return false
+	}
+	s.size -= size
+
+	// remove the app dir if it's empty
+	_ = os.Remove(filepath.Dir(dir))
+	return true
+}
+
+func (s *replayStore) removeSession(sess *replaySession) {
+	if s.removeDir(s.sessionDir(sess.AppID, sess.SessionID), sess.Size) {
+		delete(s.sessions, replaySessionKey(sess.AppID, sess.SessionID))
+	}
+}
+
+// removeBroken remove sessions with unreadable meta.
+func (s *replayStore) removeBroken() {
+	for dir, size := range s.broken {
+		log.Infof("remove session replay dir [%s] with unreadable meta", dir)
+		if s.removeDir(dir, size) {
+			delete(s.broken, dir)
+		}
+	}
+}
+
+// evict remove oldest sessions until the store is within capacity, the session
+// being written is kept. Caller should hold the lock.
+func (s *replayStore) evict(appID, sessionID string) {
+	s.removeBroken()
+
+	sessions := make([]*replaySession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		sessions = append(sessions, sess)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].UpdatedAt < sessions[j].UpdatedAt
+	})
+
+	for _, sess := range sessions {
+		if s.size <= s.cfg.CapacityMB*MiB {
+			return
+		}
+		if sess.AppID == appID && sess.SessionID == sessionID {
+			continue
+		}
+
+		log.Infof("session replay store exceed capacity, remove session %s/%s", sess.AppID, sess.SessionID)
+		s.removeSession(sess)
+	}
+}
+
+// clean remove sessions that not updated within retention.
+func (s *replayStore) clean() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.removeBroken()
+
+	expire := s.now().Add(-s.cfg.Retention).UnixMilli()
+	for _, sess := range s.sessions {
+		if sess.UpdatedAt < expire {
+			log.Debugf("session replay %s/%s expired, removed", sess.AppID, sess.SessionID)
+			s.removeSession(sess)
+		}
+	}
+
+	replayStoreBytes.Set(float64(s.size))
+}
+
+type replaySessionQuery struct {
+	appID, env, service, version string
+	start, end                   int64
+	limit                        int
+}
+
+func (q *replaySessionQuery) match(sess *replaySession) bool {
+	switch {
+	case q.appID != "" && q.appID != sess.AppID,
+		q.env != "" && q.env != sess.Env,
+		q.service != "" && q.service != sess.Service,
+		q.version != "" && q.version != sess.Version,
+		q.start > 0 && sess.End < q.start,
+		q.end > 0 && sess.Start > q.end:
+		return false
+	default:
+		return true
+	}
+}
+
+// listSessions list sessions matched the query, latest first.
+func (s *replayStore) listSessions(q *replaySessionQuery) ([]*replaySession, error) {
+	s.mtx.Lock()
+	res := make([]*replaySession, 0, len(s.sessions))
+	for _, sess := range s.sessions {
+		if q.match(sess) {
+			x := *sess
+			x.Views = append([]string(nil), sess.Views...)
+			x.Segments = nil
+			res = append(res, &x)
+		}
+	}
+	s.mtx.Unlock()
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Start > res[j].Start
+	})
+
+	if q.limit > 0 && len(res) > q.limit {
+		res = res[:q.limit]
+	}
+
+	return res, nil
+}
+
+// replaySegmentData is a line of the segments stream.
+type replaySegmentData struct {
+	replaySegment
+	Segment json.RawMessage `json:"segment"`
+}
+
+// writeSegments write segments of the session(and view if specified) as
+// NDJSON to w, ordered by start time.
+func (s *replayStore) writeSegments(w io.Writer, appID, sessionID, viewID string) error {
+	if !validReplayID(appID) || !validReplayID(sessionID) {
+		return errReplayInvalidID
+	}
+
+	dir := s.sessionDir(appID, sessionID)
+
+	var segments []*replaySegment
+
+	s.mtx.Lock()
+	sess, ok := s.sessions[replaySessionKey(appID, sessionID)]
+	if ok {
+		// segments are never modified once saved
+		for _, seg := range sess.Segments {
+			if viewID == "" || seg.ViewID == viewID {
+				segments = append(segments, seg)
+			}
+		}
+	}
+	s.mtx.Unlock()
+
+	if !ok {
+		return fmt.Errorf("session %s/%s: %w", appID, sessionID, os.ErrNotExist)
+	}
+
+	sort.SliceStable(segments, func(i, j int) bool {
+		if segments[i].Start != segments[j].Start {
+			return segments[i].Start < segments[j].Start
+		}
+		return segments[i].Seq < segments[j].Seq
+	})
+
+	enc := json.NewEncoder(w)
+	for _, seg := range segments {
+		raw, err := readSegmentFile(filepath.Join(dir, segmentFileName(seg.Seq)))
+		if err != nil {
+			// the session may be evicted during streaming
+			return fmt.Errorf("unable to read segment #%d: %w", seg.Seq, err)
+		}
+
+		if err := enc.Encode(&replaySegmentData{replaySegment: *seg, Segment: raw}); err != nil {
+			return err
+		}
+
+		if f, ok := w.(interface{ Flush() }); ok {
+			f.Flush()
+		}
+	}
+
+	return nil
+}
+
+func readSegmentFile(path string) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck
+
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close() //nolint:errcheck
+
+	return io.ReadAll(zr)
+}
+
+func (s *replayStore) hasSession(appID, sessionID string) bool {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	_, ok := s.sessions[replaySessionKey(appID, sessionID)]
+	return ok
+}
+
+// checkToken check the playback token within header "Authorization: Bearer <token>".
+func (s *replayStore) checkToken(r *http.Request) error {
+	if s.cfg.APIToken == "" {
+		return fmt.Errorf("api_token of session replay local store not set")
+	}
+
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if subtle.ConstantTimeCompare([]byte(token), []byte(s.cfg.APIToken)) != 1 {
+		return fmt.Errorf("token is missing or not correct")
+	}
+
+	return nil
+}
+
+// handleReplaySessions list sessions within local session replay store.
+func (ipt *Input) handleReplaySessions(w http.ResponseWriter, r *http.Request, _ ...interface{}) (interface{}, error) {
+	query := r.URL.Query()
+
+	if err := ipt.replayStore.checkToken(r); err != nil {
+		return nil, uhttp.NewErr(err, http.StatusUnauthorized)
+	}
+
+	q := &replaySessionQuery{
+		appID:   query.Get("app_id"),
+		env:     query.Get("env"),
+		service: query.Get("service"),
+		version: query.Get("version"),
+	}
+
+	var err error
+	for k, v := range map[string]*int64{"start": &q.start, "end": &q.end} {
+		if x := query.Get(k); x != "" {
+			if *v, err = strconv.ParseInt(x, 10, 64); err != nil {
+				return nil, uhttp.NewErr(fmt.Errorf("invalid %s: %w", k, err), http.StatusBadRequest)
+			}
+		}
+	}
+
+	if x := query.Get("limit"); x != "" {
+		if q.limit, err = strconv.Atoi(x); err != nil {
+			return nil, uhttp.NewErr(fmt.Errorf("invalid limit: %w", err), http.StatusBadRequest)
+		}
+	}
+
+	sessions, err := ipt.replayStore.listSessions(q)
+	if err != nil {
+		return nil, uhttp.NewErr(err, http.StatusInternalServerError)
+	}
+
+	return sessions, nil
+}
+
+// handleReplaySegments stream segments of the session for playback.
+func (ipt *Input) handleReplaySegments(w http.ResponseWriter, r *http.Request, _ ...interface{}) (interface{}, error) {
+	query := r.URL.Query()
+
+	if err := ipt.replayStore.checkToken(r); err != nil {
+		return nil, uhttp.NewErr(err, http.StatusUnauthorized)
+	}
+
+	appID, sessionID := query.Get("app_id"), query.Get("session_id")
+	if !validReplayID(appID) || !validReplayID(sessionID) {
+		return nil, uhttp.NewErr(errReplayInvalidID, http.StatusBadRequest)
+	}
+
+	if !ipt.replayStore.hasSession(appID, sessionID) {
+		return nil, uhttp.NewErr(fmt.Errorf("session %s/%s not found", appID, sessionID), http.StatusNotFound)
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+
+	if err := ipt.replayStore.writeSegments(w, appID, sessionID, query.Get("view_id")); err != nil {
+		// header already sent, only log it
+		log.Warnf("unable to stream session replay %s/%s: %s", appID, sessionID, err)
+	}
+
+	return nil, nil
+}
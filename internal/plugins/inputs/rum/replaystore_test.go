@@ -0,0 +1,351 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package rum
+
+import (
+	"bufio"
+	"bytes"
+	"compress/zlib"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func zlibSegment(t *T.T, text string) []byte {
+	t.Helper()
+
+	seg := map[string]any{
+		"records": []any{
+			map[string]any{
+				"type":      2,
+				"timestamp": 1700000000000,
+				"data": map[string]any{
+					"node": map[string]any{
+						"type": 2,
+						"childNodes": []any{
+							map[string]any{"type": 3, "textContent": text},
+							map[string]any{"type": 2, "tagName": "input", "attributes": map[string]any{"value": text, "id": text}},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	j, err := json.Marshal(seg)
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	w := zlib.NewWriter(buf)
+	_, err = w.Write(j)
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+	return buf.Bytes()
+}
+
+func readSegments(t *T.T, s *replayStore, appID, sessionID, viewID string) []*replaySegmentData {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, s.writeSegments(buf, appID, sessionID, viewID))
+
+	var res []*replaySegmentData
+	sc := bufio.NewScanner(buf)
+	sc.Buffer(nil, MiB)
+	for sc.Scan() {
+		x := &replaySegmentData{}
+		require.NoError(t, json.Unmarshal(sc.Bytes(), x))
+		res = append(res, x)
+	}
+	return res
+}
+
+func TestReplayStore(t *T.T) {
+	newStore := func(t *T.T, rules ...*ReplayMaskRule) *replayStore {
+		t.Helper()
+		s, err := newReplayStore(&ReplayStoreCfg{Enable: true, Path: t.TempDir(), MaskRules: rules})
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("put-and-mask", func(t *T.T) {
+		s := newStore(t, &ReplayMaskRule{Pattern: `1[3-9]\d{9}`})
+
+		sess := &replaySession{AppID: "app1", SessionID: "s1", Env: "prod"}
+		require.NoError(t, s.put(sess, &replaySegment{ViewID: "v2", Start: 2000, End: 3000},
+			zlibSegment(t, "call 13800000000 now")))
+		require.NoError(t, s.put(sess, &replaySegment{ViewID: "v1", Start: 1000, End: 1500},
+			zlibSegment(t, "hello")))
+
+		segs := readSegments(t, s, "app1", "s1", "")
+		require.Len(t, segs, 2)
+
+		// ordered by start time
+		assert.Equal(t, "v1", segs[0].ViewID)
+		assert.Equal(t, "v2", segs[1].ViewID)
+
+		raw := string(segs[1].Segment)
+		assert.NotContains(t, raw, `"textContent":"call 13800000000 now"`)
+		assert.Contains(t, raw, `"textContent":"call *** now"`)
+		assert.Contains(t, raw, `"value":"call *** now"`)
+		assert.Contains(t, raw, `"id":"call 13800000000 now"`) // not a text field
+		assert.Contains(t, raw, `"timestamp":1700000000000`)
+
+		segs = readSegments(t, s, "app1", "s1", "v2")
+		require.Len(t, segs, 1)
+
+		sessions, err := s.listSessions(&replaySessionQuery{appID: "app1"})
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, int64(1000), sessions[0].Start)
+		assert.Equal(t, int64(3000), sessions[0].End)
+		assert.Equal(t, []string{"v2", "v1"}, sessions[0].Views)
+		assert.Nil(t, sessions[0].Segments)
+
+		sessions, err = s.listSessions(&replaySessionQuery{env: "test"})
+		require.NoError(t, err)
+		assert.Len(t, sessions, 0)
+
+		// size is reloaded on reopen
+		s2, err := newReplayStore(s.cfg)
+		require.NoError(t, err)
+		assert.Equal(t, s.size, s2.size)
+	})
+
+	t.Run("invalid-id", func(t *T.T) {
+		s := newStore(t)
+		for _, sess := range []*replaySession{
+			{AppID: "..", SessionID: "s1"},
+			{AppID: "app1", SessionID: "../../etc"},
+			{AppID: "", SessionID: "s1"},
+		} {
+			assert.ErrorIs(t, s.put(sess, &replaySegment{}, zlibSegment(t, "x")), errReplayInvalidID)
+		}
+	})
+
+	t.Run("bad-segment", func(t *T.T) {
+		s := newStore(t)
+		assert.ErrorIs(t, s.put(&replaySession{AppID: "app1", SessionID: "s1"}, &replaySegment{}, []byte("not-zlib")),
+			errReplayInvalidSegment)
+
+		// truncated stream rejected
+		data := zlibSegment(t, "hello")
+		assert.ErrorIs(t, s.put(&replaySession{AppID: "app1", SessionID: "s1"}, &replaySegment{}, data[:len(data)-6]),
+			errReplayInvalidSegment)
+	})
+
+	t.Run("broken-meta", func(t *T.T) {
+		s := newStore(t)
+		sess := &replaySession{AppID: "app1", SessionID: "s1"}
+		require.NoError(t, s.put(sess, &replaySegment{}, zlibSegment(t, "x")))
+		require.NoError(t, s.put(sess, &replaySegment{}, zlibSegment(t, "y")))
+
+		dir := s.sessionDir("app1", "s1")
+		require.NoError(t, os.WriteFile(filepath.Join(dir, replayMetaFile), []byte("{broken"), os.ModePerm))
+
+		s2, err := newReplayStore(s.cfg)
+		require.NoError(t, err)
+		size := s2.size
+		assert.Equal(t, dirSize(dir), size)
+
+		// listing never remove the session
+		sessions, err := s2.listSessions(&replaySessionQuery{})
+		require.NoError(t, err)
+		assert.Len(t, sessions, 0)
+		assert.DirExists(t, dir)
+
+		// existing segments not overwritten, and still counted
+		require.NoError(t, s2.put(&replaySession{AppID: "app1", SessionID: "s1"}, &replaySegment{}, zlibSegment(t, "z")))
+		assert.FileExists(t, filepath.Join(dir, segmentFileName(0)))
+		assert.FileExists(t, filepath.Join(dir, segmentFileName(2)))
+		segs := readSegments(t, s2, "app1", "s1", "")
+		require.Len(t, segs, 1)
+		assert.Equal(t, 2, segs[0].Seq)
+		assert.Equal(t, s2.size, s2.sessions[replaySessionKey("app1", "s1")].Size)
+		assert.Greater(t, s2.size, size)
+
+		// removed on clean
+		require.NoError(t, os.WriteFile(filepath.Join(dir, replayMetaFile), []byte("{broken"), os.ModePerm))
+		s3, err := newReplayStore(s.cfg)
+		require.NoError(t, err)
+		s3.clean()
+		assert.NoDirExists(t, dir)
+		assert.Equal(t, int64(0), s3.size)
+	})
+
+	t.Run("mask-upload-body", func(t *T.T) {
+		s := newStore(t, &ReplayMaskRule{Pattern: `1[3-9]\d{9}`})
+
+		body, contentType := replayRequestBody(t, "call 13800000000 now")
+		req := httptest.NewRequest(http.MethodPost, "/v1/write/rum/replay", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		require.NoError(t, req.ParseMultipartForm(MiB))
+
+		uploadBody, err := s.putRequest(req.MultipartForm, contentType, body)
+		require.NoError(t, err)
+
+		// uploaded body is masked too
+		req = httptest.NewRequest(http.MethodPost, "/v1/write/rum/replay", bytes.NewReader(uploadBody))
+		req.Header.Set("Content-Type", contentType)
+		require.NoError(t, req.ParseMultipartForm(MiB))
+		assert.Equal(t, "app1", formValue(req.MultipartForm, "app_id"))
+
+		data, err := formSegment(req.MultipartForm)
+		require.NoError(t, err)
+		raw, err := decodeSegment(data)
+		require.NoError(t, err)
+		assert.Contains(t, string(raw), `"textContent":"call *** now"`)
+		assert.NotContains(t, string(raw), `"textContent":"call 13800000000 now"`)
+
+		// not uploaded if unable to mask
+		req = httptest.NewRequest(http.MethodPost, "/v1/write/rum/replay", bytes.NewReader(body))
+		req.Header.Set("Content-Type", contentType)
+		require.NoError(t, req.ParseMultipartForm(MiB))
+		req.MultipartForm.File["segment"] = nil
+		uploadBody, err = s.putRequest(req.MultipartForm, contentType, body)
+		assert.ErrorIs(t, err, errReplayInvalidSegment)
+		assert.Nil(t, uploadBody)
+	})
+
+	t.Run("capacity", func(t *T.T) {
+		s := newStore(t)
+		for i := 0; i < 3; i++ {
+			require.NoError(t, s.put(&replaySession{AppID: "app1", SessionID: "s" + strconv.Itoa(i)},
+				&replaySegment{}, zlibSegment(t, "x")))
+		}
+
+		s.cfg.CapacityMB = 0
+		require.NoError(t, s.put(&replaySession{AppID: "app2", SessionID: "s9"}, &replaySegment{}, zlibSegment(t, "x")))
+
+		sessions, err := s.listSessions(&replaySessionQuery{})
+		require.NoError(t, err)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "s9", sessions[0].SessionID)
+		assert.Equal(t, sessions[0].Size, s.size)
+	})
+
+	t.Run("retention", func(t *T.T) {
+		s := newStore(t)
+		require.NoError(t, s.put(&replaySession{AppID: "app1", SessionID: "s1"}, &replaySegment{}, zlibSegment(t, "x")))
+
+		s.clean()
+		sessions, err := s.listSessions(&replaySessionQuery{})
+		require.NoError(t, err)
+		assert.Len(t, sessions, 1)
+
+		s.now = func() time.Time { return time.Now().Add(s.cfg.Retention + time.Minute) }
+		s.clean()
+		sessions, err = s.listSessions(&replaySessionQuery{})
+		require.NoError(t, err)
+		assert.Len(t, sessions, 0)
+		assert.Equal(t, int64(0), s.size)
+	})
+}
+
+func replayRequestBody(t *T.T, text string) ([]byte, string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	mw := multipart.NewWriter(buf)
+	for k, v := range map[string]string{
+		"app_id":        "app1",
+		"session_id":    "s1",
+		"view_id":       "v1",
+		"env":           "prod",
+		"start":         "1000",
+		"end":           "2000",
+		"records_count": "1",
+	} {
+		require.NoError(t, mw.WriteField(k, v))
+	}
+	fw, err := mw.CreateFormFile("segment", "segment")
+	require.NoError(t, err)
+	_, err = fw.Write(zlibSegment(t, text))
+	require.NoError(t, err)
+	require.NoError(t, mw.Close())
+
+	return buf.Bytes(), mw.FormDataContentType()
+}
+
+func playbackRequest(url, token string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, url, nil)
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return req
+}
+
+func TestReplayStoreHandler(t *T.T) {
+	ipt := defaultInput()
+	ipt.SessionReplayCfg.LocalStore = &ReplayStoreCfg{
+		Enable:   true,
+		Path:     t.TempDir(),
+		Upload:   false,
+		APIToken: "tkn_123",
+	}
+	defer ipt.semStop.Close()
+
+	handler, err := ipt.sessionReplayHandler()
+	require.NoError(t, err)
+	assert.Nil(t, ipt.replayDiskQueue)
+
+	body, contentType := replayRequestBody(t, "hello")
+	req := httptest.NewRequest(http.MethodPost, "/v1/write/rum/replay", bytes.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	t.Run("sessions", func(t *T.T) {
+		_, err := ipt.handleReplaySessions(httptest.NewRecorder(),
+			playbackRequest(replaySessionsAPI+"?app_id=app1", ""))
+		assert.Error(t, err)
+
+		// token within query not accepted
+		_, err = ipt.handleReplaySessions(httptest.NewRecorder(),
+			playbackRequest(replaySessionsAPI+"?app_id=app1&token=tkn_123", ""))
+		assert.Error(t, err)
+
+		res, err := ipt.handleReplaySessions(httptest.NewRecorder(),
+			playbackRequest(replaySessionsAPI+"?app_id=app1", "tkn_123"))
+		require.NoError(t, err)
+		sessions, ok := res.([]*replaySession)
+		require.True(t, ok)
+		require.Len(t, sessions, 1)
+		assert.Equal(t, "prod", sessions[0].Env)
+
+		_, err = ipt.handleReplaySessions(httptest.NewRecorder(),
+			playbackRequest(replaySessionsAPI+"?start=abc", "tkn_123"))
+		assert.Error(t, err)
+	})
+
+	t.Run("segments", func(t *T.T) {
+		rec := httptest.NewRecorder()
+		_, err := ipt.handleReplaySegments(rec,
+			playbackRequest(replaySegmentsAPI+"?app_id=app1&session_id=s1", "tkn_123"))
+		require.NoError(t, err)
+		assert.Equal(t, "application/x-ndjson", rec.Header().Get("Content-Type"))
+
+		lines := strings.Split(strings.TrimSpace(rec.Body.String()), "\n")
+		require.Len(t, lines, 1)
+		assert.Contains(t, lines[0], `"view_id":"v1"`)
+		assert.Contains(t, lines[0], `"textContent":"hello"`)
+
+		_, err = ipt.handleReplaySegments(httptest.NewRecorder(),
+			playbackRequest(replaySegmentsAPI+"?app_id=app1&session_id=nope", "tkn_123"))
+		assert.Error(t, err)
+	})
+}
@@ -40,6 +40,7 @@ type SessionReplayCfg struct {
 	SendTimeout       time.Duration             `toml:"send_timeout"`
 	SendRetryCount    int                       `toml:"send_retry_count"`
 	FilterRules       []string                  `toml:"filter_rules"`
+	LocalStore        *ReplayStoreCfg           `toml:"local_store"`
 	whereConditions   []filter2.WhereConditions `toml:"-"`
 }
 
@@ -64,16 +65,31 @@ func defaultSessionReplayCfg() *SessionReplayCfg {
 }
 
 func (ipt *Input) sessionReplayHandler() (f http.HandlerFunc, err error) {
-	if err := ipt.initReplayHTTPClient(); err != nil {
-		return nil, fmt.Errorf("unable to init session replay http client: %w", err)
-	}
+	upload := true
+	if cfg := ipt.SessionReplayCfg.LocalStore; cfg != nil && cfg.Enable {
+		if ipt.replayStore, err = newReplayStore(cfg); err != nil {
+			return nil, fmt.Errorf("unable to open session replay local store: %w", err)
+		}
+		ipt.startReplayStoreCleaner()
+		upload = cfg.Upload
 
-	if err := ipt.initReplayDiskQueue(); err != nil {
-		return nil, fmt.Errorf("unable to init diskqueue: %w", err)
+		if cfg.APIToken == "" {
+			log.Warnf("api_token of session replay local store not set, playback APIs are disabled")
+		}
 	}
 
-	if err := ipt.initSessionReplayWorkers(); err != nil {
-		return nil, fmt.Errorf("unable to start session replay uploading workers: %w", err)
+	if upload {
+		if err := ipt.initReplayHTTPClient(); err != nil {
+			return nil, fmt.Errorf("unable to init session replay http client: %w", err)
+		}
+
+		if err := ipt.initReplayDiskQueue(); err != nil {
+			return nil, fmt.Errorf("unable to init diskqueue: %w", err)
+		}
+
+		if err := ipt.initSessionReplayWorkers(); err != nil {
+			return nil, fmt.Errorf("unable to start session replay uploading workers: %w", err)
+		}
 	}
 
 	return func(w http.ResponseWriter, req *http.Request) {
@@ -168,6 +184,32 @@ func (ipt *Input) sessionReplayHandler() (f http.HandlerFunc, err error) {
 			}
 		}
 
+		if ipt.replayStore != nil {
+			// body with segment masked is uploaded, or nil if unable to mask
+			uploadBody, err := ipt.replayStore.putRequest(req.MultipartForm, req.Header.Get("Content-Type"), body)
+			if err != nil {
+				log.Warnf("unable to save session replay to local store: %s", err)
+				replayStoreTotalCount.WithLabelValues(appID, "failed").Inc()
+
+				if ipt.replayDiskQueue == nil || uploadBody == nil {
+					if errors.Is(err, errReplayInvalidID) || errors.Is(err, errReplayInvalidSegment) {
+						w.WriteHeader(http.StatusBadRequest)
+					} else {
+						w.WriteHeader(http.StatusInternalServerError)
+					}
+					return
+				}
+			} else {
+				replayStoreTotalCount.WithLabelValues(appID, "success").Inc()
+			}
+
+			body = uploadBody
+		}
+
+		if ipt.replayDiskQueue == nil { // upload disabled
+			return
+		}
+
 		reqPB := &RequestPB{
 			Header:     headers,
 			Body:       body,
@@ -188,6 +230,29 @@ func (ipt *Input) sessionReplayHandler() (f http.HandlerFunc, err error) {
 	}, nil
 }
 
+func (ipt *Input) startReplayStoreCleaner() {
+	replayStoreGroup = goroutine.NewGroup(goroutine.Option{Name: "session_replay_store"})
+
+	replayStoreGroup.Go(func(ctx context.Context) error {
+		tick := time.NewTicker(replayStoreCleanInterval)
+		defer tick.Stop()
+
+		for {
+			ipt.replayStore.clean()
+
+			select {
+			case <-datakit.Exit.Wait():
+				return nil
+			case <-ctx.Done():
+				return nil
+			case <-ipt.semStop.Wait():
+				return nil
+			case <-tick.C:
+			}
+		}
+	})
+}
+
 func (ipt *Input) initReplayHTTPClient() error {
 	endpoints := config.Cfg.Dataway.GetEndpoints()
 	if len(endpoints) == 0 {
@@ -38,6 +38,8 @@ const (
 	SourceMapDirMini        = "miniapp"
 	SourceMapDirAndroid     = "android"
 	SourceMapDirIOS         = "ios"
+	SourceMapDirReactNative = "react-native"
+	SourceMapDirFlutter     = "flutter"
 	ZipExt                  = ".zip"
 
 	maxSourcemapUploadSize = 100 * 1024 * 1024 // 100Mib
@@ -143,7 +145,7 @@ func (ipt *Input) extractArchives(loose bool) error {
 
 	sourceMapDirs := make(map[string]struct{}, 3)
 
-	for _, sdkName := range []string{SdkAndroid, SdkIOS} {
+	for _, sdkName := range []string{SdkAndroid, SdkIOS, SdkFlutter} {
 		sourceMapDirs[ipt.getRumSourcemapDir(sdkName)] = struct{}{}
 	}
 
@@ -262,6 +264,10 @@ func (ipt *Input) parseSourcemap(p *point.Point, sdkName string, status *sourceM
 		return ipt.resolveAndroidSourceMap(p, sdkName, status)
 	case SdkIOS:
 		return ipt.resolveIOSSourceMap(p, sdkName, status)
+	case SdkReactNative:
+		return ipt.resolveReactNativeSourceMap(p, sdkName, status)
+	case SdkFlutter:
+		return ipt.resolveFlutterSymbols(p, sdkName, status)
 	}
 	return p, nil
 }
@@ -634,8 +640,7 @@ type sourcemapCheckRes struct {
 	OriginalErrorStack string `json:"original_error_stack"`
 }
 
-// handleSourcemapCheck check whether sourcemap is valid. Only support web sourcemap now.
-// TODO: support android and ios sourcemap.
+// handleSourcemapCheck check whether sourcemap is valid.
 func (ipt *Input) handleSourcemapCheck(w http.ResponseWriter, r *http.Request, _ ...interface{}) (interface{}, error) {
 	var (
 		sdkName string
@@ -662,6 +667,10 @@ func (ipt *Input) handleSourcemapCheck(w http.ResponseWriter, r *http.Request, _
 		sdkName = SdkWeb
 	case "miniapp":
 		sdkName = SdkWebMiniApp
+	case SourceMapDirReactNative:
+		sdkName = SdkReactNative
+	case SourceMapDirFlutter:
+		sdkName = SdkFlutter
 	default:
 		sdkName = SdkWeb
 	}
@@ -742,9 +751,9 @@ func (ipt *Input) handleSourcemapUpload(w http.ResponseWriter, r *http.Request,
 		platform = SourceMapDirWeb
 	}
 
-	if platform != SourceMapDirWeb && platform != SourceMapDirAndroid && platform != SourceMapDirIOS {
+	if !isUploadPlatform(platform) {
 		sendResponse(&sourcemapResponse{
-			ErrorMsg: fmt.Sprintf("platform [%s] not supported, please use web, miniapp, android or ios", platform),
+			ErrorMsg: fmt.Sprintf("platform [%s] not supported, please use web, miniapp, android, ios, react-native or flutter", platform),
 			Success:  false,
 		}, w)
 
@@ -816,8 +825,19 @@ func (ipt *Input) handleSourcemapUpload(w http.ResponseWriter, r *http.Request,
 		return nil, nil
 	}
 
-	if err := updateSourcemapCache(dst); err != nil {
-		log.Warnf("update sourcemap cache failed: %s", err.Error())
+	switch platform {
+	case SourceMapDirReactNative:
+		deleteRNSourcemapCache(dst)
+	case SourceMapDirFlutter:
+		// symbol files are read from the extracted dir, extract it now to make
+		// it available before next extracting interval.
+		if err := extractZipFile(dst); err != nil {
+			log.Warnf("extract flutter symbols failed: %s", err.Error())
+		}
+	default:
+		if err := updateSourcemapCache(dst); err != nil {
+			log.Warnf("update sourcemap cache failed: %s", err.Error())
+		}
 	}
 
 	sendResponse(&sourcemapResponse{
@@ -828,6 +848,15 @@ func (ipt *Input) handleSourcemapUpload(w http.ResponseWriter, r *http.Request,
 	return nil, nil
 }
 
+func isUploadPlatform(platform string) bool {
+	switch platform {
+	case SourceMapDirWeb, SourceMapDirAndroid, SourceMapDirIOS, SourceMapDirReactNative, SourceMapDirFlutter:
+		return true
+	default:
+		return false
+	}
+}
+
 func sendResponse(res *sourcemapResponse, w http.ResponseWriter) {
 	jsonBuf, _ := json.Marshal(res)
 	if _, err := w.Write(jsonBuf); err != nil {
@@ -884,9 +913,9 @@ func (ipt *Input) handleSourcemapDelete(w http.ResponseWriter, r *http.Request,
 		platform = SourceMapDirWeb
 	}
 
-	if platform != SourceMapDirWeb && platform != SourceMapDirAndroid && platform != SourceMapDirIOS {
+	if !isUploadPlatform(platform) {
 		sendResponse(&sourcemapResponse{
-			ErrorMsg: fmt.Sprintf("platform [%s] not supported, please use web, miniapp, android or ios", platform),
+			ErrorMsg: fmt.Sprintf("platform [%s] not supported, please use web, miniapp, android, ios, react-native or flutter", platform),
 			Success:  false,
 		}, w)
 
@@ -916,6 +945,7 @@ func (ipt *Input) handleSourcemapDelete(w http.ResponseWriter, r *http.Request,
 	}
 
 	deleteSourcemapCache(zipFilePath)
+	deleteRNSourcemapCache(zipFilePath)
 
 	sendResponse(&sourcemapResponse{
 		Success: true,
@@ -0,0 +1,341 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package rum
+
+import (
+	"bytes"
+	"debug/dwarf"
+	"debug/elf"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+const flutterSymbolsExt = ".symbols"
+
+var (
+	// flutterFrameRegexp match frames of obfuscated(--split-debug-info) Flutter stack, such as
+	//
+	//	#00 abs 000000723d6346d7 virt 00000000001ed6d7 _kDartIsolateSnapshotInstructions+0x1e26d7
+	//
+	// $1 frame index
+	// $2 absolute address
+	// $3 virtual address(optional).
+	flutterFrameRegexp = regexp.MustCompile(`(?m)^[ \t]*#(\d+)[ \t]+abs[ \t]+([0-9a-fA-F]+)(?:[ \t]+virt[ \t]+([0-9a-fA-F]+))?.*$`)
+
+	flutterBuildIDRegexp = regexp.MustCompile(`build_id:\s*'([0-9a-fA-F]+)'`)
+	flutterArchRegexp    = regexp.MustCompile(`\barch:\s*(\S+)`)
+	flutterDSOBaseRegexp = regexp.MustCompile(`isolate_dso_base:\s*([0-9a-fA-F]+)`)
+)
+
+// flutterStackHeader is the header of obfuscated Flutter stack.
+type flutterStackHeader struct {
+	buildID string
+	arch    string
+	dsoBase uint64
+}
+
+func parseFlutterStackHeader(stack string) *flutterStackHeader {
+	h := &flutterStackHeader{}
+
+	if m := flutterBuildIDRegexp.FindStringSubmatch(stack); len(m) == 2 {
+		h.buildID = strings.ToLower(m[1])
+	}
+
+	if m := flutterArchRegexp.FindStringSubmatch(stack); len(m) == 2 {
+		h.arch = m[1]
+	}
+
+	if m := flutterDSOBaseRegexp.FindStringSubmatch(stack); len(m) == 2 {
+		h.dsoBase, _ = strconv.ParseUint(m[1], 16, 64)
+	}
+
+	return h
+}
+
+// elfBuildID get the GNU build ID of the ELF file.
+func elfBuildID(f *elf.File) string {
+	for _, s := range f.Sections {
+		if s.Type != elf.SHT_NOTE {
+			continue
+		}
+
+		data, err := s.Data()
+		if err != nil {
+			continue
+		}
+
+		// note: namesz(4) descsz(4) type(4) name(aligned to 4) desc(aligned to 4)
+		for len(data) >= 12 {
+			if 12+uint64(f.ByteOrder.Uint32(data[0:4]))+uint64(f.ByteOrder.Uint32(data[4:8])) > uint64(len(data)) {
+				break
+			}
+
+			var (
+				namesz = f.ByteOrder.Uint32(data[0:4])
+				descsz = f.ByteOrder.Uint32(data[4:8])
+				typ    = f.ByteOrder.Uint32(data[8:12])
+				nameAt = uint32(12)
+				descAt = nameAt + (namesz+3)&^3
+				next   = descAt + (descsz+3)&^3
+			)
+
+			if uint64(next) > uint64(len(data)) {
+				break
+			}
+
+			name := string(bytes.TrimRight(data[nameAt:nameAt+namesz], "\x00"))
+			if typ == 3 && name == "GNU" { // NT_GNU_BUILD_ID
+				return hex.EncodeToString(data[descAt : descAt+descsz])
+			}
+
+			data = data[next:]
+		}
+	}
+
+	return ""
+}
+
+// findFlutterSymbols find the symbol file(app.<platform>-<arch>.symbols) for the stack
+// within dir. Symbol files are matched by build ID first, then by arch.
+func findFlutterSymbols(dir string, h *flutterStackHeader) (string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && strings.HasSuffix(d.Name(), flutterSymbolsExt) {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if len(files) == 0 {
+		return "", fmt.Errorf("no flutter symbol file(*%s) found in [%s]", flutterSymbolsExt, dir)
+	}
+
+	if h.buildID != "" {
+		for _, file := range files {
+			f, err := elf.Open(file)
+			if err != nil {
+				log.Warnf("open flutter symbol file [%s] failed: %s", file, err)
+				continue
+			}
+
+			id := elfBuildID(f)
+			_ = f.Close()
+
+			if id == h.buildID {
+				return file, nil
+			}
+		}
+	}
+
+	if h.arch != "" {
+		for _, file := range files {
+			if strings.HasSuffix(file, "-"+h.arch+flutterSymbolsExt) {
+				return file, nil
+			}
+		}
+	}
+
+	if len(files) == 1 {
+		return files[0], nil
+	}
+
+	return "", fmt.Errorf("flutter symbol file for build_id [%s], arch [%s] not found", h.buildID, h.arch)
+}
+
+type dwarfSymbolizer struct {
+	data *dwarf.Data
+}
+
+// lookup find the function and source line of pc.
+func (s *dwarfSymbolizer) lookup(pc uint64) (fn, file string, line, col int, err error) {
+	r := s.data.Reader()
+
+	cu, err := r.SeekPC(pc)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	lr, err := s.data.LineReader(cu)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+
+	if lr != nil {
+		var le dwarf.LineEntry
+		if err := lr.SeekPC(pc, &le); err == nil && le.File != nil {
+			file, line, col = le.File.Name, le.Line, le.Column
+		}
+	}
+
+	// find the subprogram within the compile unit
+	for {
+		e, err := r.Next()
+		if err != nil || e == nil || e.Tag == dwarf.TagCompileUnit {
+			break
+		}
+
+		if e.Tag != dwarf.TagSubprogram {
+			continue
+		}
+
+		ranges, err := s.data.Ranges(e)
+		if err != nil {
+			continue
+		}
+
+		for _, rg := range ranges {
+			if pc >= rg[0] && pc < rg[1] {
+				fn, _ = e.Val(dwarf.AttrName).(string)
+				break
+			}
+		}
+
+		if fn != "" {
+			break
+		}
+	}
+
+	if file == "" && fn == "" {
+		return "", "", 0, 0, dwarf.ErrUnknownPC
+	}
+
+	return fn, file, line, col, nil
+}
+
+// symbolicateFlutterStack replace obfuscated frames within stack with function and source line.
+func symbolicateFlutterStack(stack, symbolFile string, h *flutterStackHeader) (string, error) {
+	f, err := elf.Open(symbolFile)
+	if err != nil {
+		return "", fmt.Errorf("open flutter symbol file [%s] failed: %w", symbolFile, err)
+	}
+	defer f.Close() //nolint:errcheck
+
+	data, err := f.DWARF()
+	if err != nil {
+		return "", fmt.Errorf("load DWARF from [%s] failed: %w", symbolFile, err)
+	}
+
+	s := &dwarfSymbolizer{data: data}
+
+	return flutterFrameRegexp.ReplaceAllStringFunc(stack, func(str string) string {
+		m := flutterFrameRegexp.FindStringSubmatch(str)
+		if len(m) != 4 {
+			return str
+		}
+
+		pc, ok := flutterFramePC(m[2], m[3], h.dsoBase)
+		if !ok {
+			return str
+		}
+
+		// return addresses point to the instruction after the call
+		if m[1] != "00" && m[1] != "0" && pc > 0 {
+			pc--
+		}
+
+		fn, file, line, col, err := s.lookup(pc)
+		if err != nil {
+			log.Debugf("lookup pc %x in [%s]: %s", pc, symbolFile, err)
+			return str
+		}
+
+		loc := fmt.Sprintf("%s:%d", file, line)
+		if col > 0 {
+			loc += ":" + strconv.Itoa(col)
+		}
+
+		return fmt.Sprintf("#%s      %s (%s)", m[1], fn, loc)
+	}), nil
+}
+
+// flutterFramePC get the virtual address of the frame, it's calculated from
+// the absolute address if virt not available.
+func flutterFramePC(abs, virt string, dsoBase uint64) (uint64, bool) {
+	if virt != "" {
+		pc, err := strconv.ParseUint(virt, 16, 64)
+		return pc, err == nil
+	}
+
+	if dsoBase == 0 {
+		return 0, false
+	}
+
+	pc, err := strconv.ParseUint(abs, 16, 64)
+	if err != nil || pc < dsoBase {
+		return 0, false
+	}
+
+	return pc - dsoBase, true
+}
+
+func (ipt *Input) resolveFlutterSymbols(p *point.Point, sdkName string, status *sourceMapStatus) (*point.Point, error) {
+	errStack, ok := p.Get("error_stack").(string)
+	if !ok {
+		status.status = StatusLackField
+		return p, nil
+	}
+
+	var (
+		appID, _   = p.Get("app_id").(string)
+		env, _     = p.Get("env").(string)
+		version, _ = p.Get("version").(string)
+	)
+
+	if appID == "" {
+		status.status = StatusLackField
+		return p, nil
+	}
+
+	if !flutterFrameRegexp.MatchString(errStack) {
+		// not obfuscated
+		return p, nil
+	}
+
+	zipFile := GetSourcemapZipFileName(appID, env, version)
+	symbolDir := filepath.Join(ipt.getRumSourcemapDir(sdkName), strings.TrimSuffix(zipFile, ZipExt))
+	if !isDir(symbolDir) {
+		status.status = StatusZipNotFound
+		status.reason = fmt.Sprintf("flutter symbols dir [%s] not exists", symbolDir)
+		return p, errors.New(status.reason)
+	}
+
+	h := parseFlutterStackHeader(errStack)
+
+	symbolFile, err := findFlutterSymbols(symbolDir, h)
+	if err != nil {
+		status.status = StatusZipNotFound
+		status.reason = err.Error()
+		return p, err
+	}
+
+	start := time.Now()
+	originStack, err := symbolicateFlutterStack(errStack, symbolFile, h)
+	sourceMapDurationSummary.WithLabelValues(sdkName, appID, env, version).Observe(float64(time.Since(start)) / promDurationUnit)
+	if err != nil {
+		status.reason = err.Error()
+		return p, err
+	}
+
+	status.status = StatusOK
+	p.MustAdd("error_stack_source_base64", base64.StdEncoding.EncodeToString([]byte(originStack)))
+	return p, nil
+}
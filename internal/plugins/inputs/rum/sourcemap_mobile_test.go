@@ -0,0 +1,258 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package rum
+
+import (
+	"archive/zip"
+	"bytes"
+	"debug/elf"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gen(1,9) -> App.js:2:2(0-based column).
+const rnTestSourcemap = `{"version":3,"sources":["App.js"],"names":["crash"],"mappings":"AAAAA,SACEA"}`
+
+// rnTestSourcemap composed with Hermes sourcemap, virtual offset 9 of segment 0
+// -> App.js:2:2, within function onPress starts at App.js:2:0.
+const rnTestHermesSourcemap = `{"version":3,"sources":["App.js"],"names":["crash"],"mappings":"AAAAA,SACEA",` +
+	`"x_facebook_sources":[[{"names":["<global>","onPress"],"mappings":"AAA;ACC"}]],` +
+	`"x_hermes_function_offsets":{"0":[0,9]}}`
+
+func writeRNSourcemapZip(t *testing.T, zipFile string, maps map[string]string) {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	w := zip.NewWriter(buf)
+	for name, content := range maps {
+		f, err := w.Create(name)
+		require.NoError(t, err)
+		_, err = f.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, w.Close())
+
+	require.NoError(t, os.WriteFile(zipFile, buf.Bytes(), os.ModePerm))
+}
+
+func errorPoint(appID, stack string) *point.Point {
+	var kvs point.KVs
+	kvs = kvs.MustAddTag("app_id", appID)
+	kvs = kvs.MustAddTag("env", "prod")
+	kvs = kvs.MustAddTag("version", "1.0.0")
+	kvs = kvs.Add("error_stack", stack, false, true)
+
+	return point.NewPointV2("error", kvs, point.CommonLoggingOptions()...)
+}
+
+func errorStackSource(t *testing.T, p *point.Point) string {
+	t.Helper()
+
+	b64, ok := p.Get("error_stack_source_base64").(string)
+	require.True(t, ok)
+
+	stack, err := base64.StdEncoding.DecodeString(b64)
+	require.NoError(t, err)
+	return string(stack)
+}
+
+func TestResolveReactNativeSourceMap(t *testing.T) {
+	ipt := defaultInput()
+	ipt.rumDataDir = t.TempDir()
+
+	dir := ipt.getRumSourcemapDir(SdkReactNative)
+	require.NoError(t, os.MkdirAll(dir, os.ModePerm))
+
+	writeRNSourcemapZip(t, filepath.Join(dir, GetSourcemapZipFileName("app_rn", "prod", "1.0.0")), map[string]string{
+		"android/index.android.bundle.map": rnTestHermesSourcemap,
+		"ios/main.jsbundle.map":            rnTestSourcemap,
+	})
+
+	writeRNSourcemapZip(t, filepath.Join(dir, GetSourcemapZipFileName("app_plain", "prod", "1.0.0")), map[string]string{
+		"android/index.android.bundle.map": rnTestSourcemap,
+	})
+
+	t.Run("hermes", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		p, err := ipt.parseSourcemap(errorPoint("app_rn",
+			"Error: oops\n    at a (address at index.android.bundle:1:9)\n    at anonymous (native)"), SdkReactNative, status)
+		require.NoError(t, err)
+
+		// bytecode offset mapped as is, function name from x_facebook_sources
+		assert.Equal(t, StatusOK, status.status)
+		assert.Equal(t, "Error: oops\n    at onPress (App.js:2:3)\n    at anonymous (native)", errorStackSource(t, p))
+	})
+
+	t.Run("hermes-without-composed-sourcemap", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		p, err := ipt.parseSourcemap(errorPoint("app_plain",
+			"at a (address at index.android.bundle:1:9)"), SdkReactNative, status)
+		require.NoError(t, err)
+
+		assert.Equal(t, StatusError, status.status)
+		assert.Contains(t, status.reason, "composed with Hermes sourcemap")
+		assert.Equal(t, "at a (address at index.android.bundle:1:9)", errorStackSource(t, p))
+	})
+
+	t.Run("hermes-unknown-segment", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		_, err := ipt.parseSourcemap(errorPoint("app_rn",
+			"at a (address at index.android.bundle:2:9)"), SdkReactNative, status)
+		require.NoError(t, err)
+		assert.Equal(t, StatusError, status.status)
+	})
+
+	t.Run("jsc", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		p, err := ipt.parseSourcemap(errorPoint("app_rn",
+			"crash@/var/containers/Bundle/App.app/main.jsbundle:1:10"), SdkReactNative, status)
+		require.NoError(t, err)
+
+		assert.Equal(t, StatusOK, status.status)
+		assert.Equal(t, "crash@App.js:2:3", errorStackSource(t, p))
+	})
+
+	t.Run("zip-not-found", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		_, err := ipt.parseSourcemap(errorPoint("app_other", "at crash (index.android.bundle:1:10)"), SdkReactNative, status)
+		assert.Error(t, err)
+		assert.Equal(t, StatusZipNotFound, status.status)
+	})
+}
+
+func TestDecodeFunctionMap(t *testing.T) {
+	mappings, err := decodeFunctionMap([]string{"<global>", "foo", "bar"}, "AAA,IC;CCC,ED")
+	require.NoError(t, err)
+	assert.Equal(t, []rnFunctionMapping{
+		{line: 1, column: 0, name: "<global>"},
+		{line: 1, column: 4, name: "foo"},
+		{line: 2, column: 1, name: "bar"},
+		{line: 2, column: 3, name: "foo"},
+	}, mappings)
+
+	_, err = decodeFunctionMap([]string{"<global>"}, "AC")
+	assert.Error(t, err)
+
+	_, err = decodeFunctionMap([]string{"<global>"}, "A!")
+	assert.Error(t, err)
+}
+
+// buildSymbolFile build a Go program as the symbol file, and return the
+// address of function main.crash, Go binaries come with DWARF too.
+func buildSymbolFile(t *testing.T) (string, uint64) {
+	t.Helper()
+
+	goBin, err := exec.LookPath("go")
+	if err != nil {
+		t.Skip("go toolchain not found")
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module crash\n\ngo 1.19\n"), os.ModePerm))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.go"), []byte(`package main
+
+//go:noinline
+func crash() int { return 42 }
+
+func main() { _ = crash() }
+`), os.ModePerm))
+
+	bin := filepath.Join(dir, "app.symbols")
+	cmd := exec.Command(goBin, "build", "-o", bin, ".") //nolint:gosec
+	cmd.Dir = dir
+	cmd.Env = append(os.Environ(), "GOFLAGS=", "GOOS=linux", "CGO_ENABLED=0")
+	out, err := cmd.CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	f, err := elf.Open(bin)
+	require.NoError(t, err)
+	defer f.Close() //nolint:errcheck
+
+	syms, err := f.Symbols()
+	require.NoError(t, err)
+
+	for _, sym := range syms {
+		if sym.Name == "main.crash" {
+			return bin, sym.Value
+		}
+	}
+
+	t.Fatal("main.crash not found")
+	return "", 0
+}
+
+func TestResolveFlutterSymbols(t *testing.T) {
+	symbolFile, pc := buildSymbolFile(t)
+
+	ipt := defaultInput()
+	ipt.rumDataDir = t.TempDir()
+
+	symbolDir := filepath.Join(ipt.getRumSourcemapDir(SdkFlutter),
+		strings.TrimSuffix(GetSourcemapZipFileName("app_flutter", "prod", "1.0.0"), ZipExt), "symbols")
+	require.NoError(t, os.MkdirAll(symbolDir, os.ModePerm))
+
+	data, err := os.ReadFile(symbolFile) //nolint:gosec
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(filepath.Join(symbolDir, "app.android-arm64.symbols"), data, os.ModePerm))
+
+	stack := fmt.Sprintf(`*** *** *** *** *** *** *** *** *** *** *** *** *** *** *** ***
+pid: 1234, tid: 5678, name 1.ui
+os: android arch: arm64 comp: yes sim: no
+isolate_dso_base: 7a3b1c2000, vm_dso_base: 7a3b1c2000
+    #00 abs %016x virt %016x _kDartIsolateSnapshotInstructions+0x1e26d7
+    #01 abs 0000000000000001 _kDartIsolateSnapshotInstructions+0x1e5527`, 0x7a3b1c2000+pc, pc)
+
+	status := &sourceMapStatus{}
+	p, err := ipt.parseSourcemap(errorPoint("app_flutter", stack), SdkFlutter, status)
+	require.NoError(t, err)
+	assert.Equal(t, StatusOK, status.status)
+
+	lines := strings.Split(errorStackSource(t, p), "\n")
+	require.Len(t, lines, 6)
+	assert.Contains(t, lines[4], "main.crash")
+	assert.Contains(t, lines[4], "main.go:4")
+	assert.Contains(t, lines[5], "abs 0000000000000001") // unresolvable, keep as is
+
+	t.Run("symbols-not-found", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		_, err := ipt.parseSourcemap(errorPoint("app_other", stack), SdkFlutter, status)
+		assert.Error(t, err)
+		assert.Equal(t, StatusZipNotFound, status.status)
+	})
+
+	t.Run("not-obfuscated", func(t *testing.T) {
+		status := &sourceMapStatus{}
+		p, err := ipt.parseSourcemap(errorPoint("app_flutter", "#0 main (package:app/main.dart:10:3)"), SdkFlutter, status)
+		require.NoError(t, err)
+		assert.Nil(t, p.Get("error_stack_source_base64"))
+	})
+}
+
+func TestFindFlutterSymbols(t *testing.T) {
+	dir := t.TempDir()
+	for _, f := range []string{"app.android-arm64.symbols", "app.android-arm.symbols", "app.android-x64.symbols"} {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, f), []byte("not-elf"), os.ModePerm))
+	}
+
+	f, err := findFlutterSymbols(dir, &flutterStackHeader{buildID: "abc", arch: "arm"})
+	require.NoError(t, err)
+	assert.Equal(t, "app.android-arm.symbols", filepath.Base(f))
+
+	_, err = findFlutterSymbols(dir, &flutterStackHeader{arch: "ia32"})
+	assert.Error(t, err)
+
+	h := parseFlutterStackHeader("os: android arch: arm64 comp: yes sim: no\nbuild_id: 'ABCDEF'\nisolate_dso_base: 7a3b1c2000, vm_dso_base: 7a3b1c2000")
+	assert.Equal(t, &flutterStackHeader{buildID: "abcdef", arch: "arm64", dsoBase: 0x7a3b1c2000}, h)
+}
@@ -0,0 +1,431 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package rum
+
+import (
+	"archive/zip"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/go-sourcemap/sourcemap"
+)
+
+// rnFrameRegexp match location of React Native stack frames, such as
+//
+//	at onPress (address at index.android.bundle:1:123456)  // Hermes, column is bytecode offset
+//	at onPress (index.android.bundle:1:123456)
+//	onPress@main.jsbundle:1:4567                           // JSC
+//
+// $1 "address at index.android.bundle:1:123456"
+// $2 "index.android.bundle"(the base name)
+// $3 line
+// $4 column.
+var rnFrameRegexp = regexp.MustCompile(`((?:address at )?(?:[^\s()@]*/)?([^\s()@/]+\.(?:bundle|jsbundle|js|hbc)):(\d+):(\d+))`)
+
+// rnFrameNameRegexp match function name of Hermes/V8 and JSC stack frames.
+var rnFrameNameRegexp = regexp.MustCompile(`^(\s*at )([^\s()]+)( \()|^([^\s@]+)(@)`)
+
+// rnFunctionMapping is a decoded segment of the Metro function map: function
+// name starts at the line(1-based) and column(0-based) of the source.
+type rnFunctionMapping struct {
+	line, column int
+	name         string
+}
+
+// rnSourcemapItem is a sourcemap within the React Native zip.
+//
+// Sourcemaps composed from Metro and Hermes sourcemaps(compose-source-maps.js)
+// come with Facebook extensions: x_hermes_function_offsets for bytecode segments,
+// and x_facebook_sources for original function names of each source.
+type rnSourcemapItem struct {
+	*sourcemap.Consumer
+
+	sources         []string
+	functionOffsets map[string][]int
+	functionMaps    [][]rnFunctionMapping
+}
+
+type rnSourcemapExtensions struct {
+	Sources         []string         `json:"sources"`
+	FunctionOffsets map[string][]int `json:"x_hermes_function_offsets"`
+	FacebookSources [][]*struct {
+		Names    []string `json:"names"`
+		Mappings string   `json:"mappings"`
+	} `json:"x_facebook_sources"`
+}
+
+func parseRNSourcemap(fname string, content []byte) (*rnSourcemapItem, error) {
+	smap, err := sourcemap.Parse(fname, content)
+	if err != nil {
+		return nil, err
+	}
+
+	var ext rnSourcemapExtensions
+	if err := json.Unmarshal(content, &ext); err != nil {
+		return nil, err
+	}
+
+	item := &rnSourcemapItem{
+		Consumer:        smap,
+		sources:         ext.Sources,
+		functionOffsets: ext.FunctionOffsets,
+	}
+
+	for i, metadata := range ext.FacebookSources {
+		// the first metadata of each source is the function map
+		if len(metadata) == 0 || metadata[0] == nil {
+			item.functionMaps = append(item.functionMaps, nil)
+			continue
+		}
+
+		mappings, err := decodeFunctionMap(metadata[0].Names, metadata[0].Mappings)
+		if err != nil {
+			return nil, fmt.Errorf("invalid function map of source %d: %w", i, err)
+		}
+		item.functionMaps = append(item.functionMaps, mappings)
+	}
+
+	return item, nil
+}
+
+// isHermes check if the sourcemap composed with Hermes sourcemap, only those
+// sourcemaps map bytecode offsets.
+func (item *rnSourcemapItem) isHermes() bool {
+	return item.functionOffsets != nil
+}
+
+// functionName find the original function name at source position.
+func (item *rnSourcemapItem) functionName(source string, line, column int) string {
+	for i, src := range item.sources {
+		if i >= len(item.functionMaps) {
+			break
+		}
+
+		// source may be prefixed by sourceRoot within the consumer
+		if src == "" || !strings.HasSuffix(source, src) {
+			continue
+		}
+
+		mappings := item.functionMaps[i]
+		n := sort.Search(len(mappings), func(j int) bool {
+			x := mappings[j]
+			return x.line > line || (x.line == line && x.column > column)
+		})
+
+		if n == 0 {
+			return ""
+		}
+
+		return mappings[n-1].name
+	}
+
+	return ""
+}
+
+const vlqBase64 = "ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz0123456789+/"
+
+// decodeVLQ decode base64 VLQ values of a sourcemap segment.
+func decodeVLQ(segment string) ([]int, error) {
+	var (
+		res   []int
+		value int
+		shift uint
+	)
+
+	for i := 0; i < len(segment); i++ {
+		digit := strings.IndexByte(vlqBase64, segment[i])
+		if digit < 0 {
+			return nil, fmt.Errorf("invalid base64 VLQ character %q", segment[i])
+		}
+
+		value += (digit & 31) << shift
+		if digit&32 != 0 {
+			shift += 5
+			continue
+		}
+
+		if value&1 != 0 {
+			res = append(res, -(value >> 1))
+		} else {
+			res = append(res, value>>1)
+		}
+
+		value, shift = 0, 0
+	}
+
+	if shift != 0 {
+		return nil, fmt.Errorf("truncated base64 VLQ segment %q", segment)
+	}
+
+	return res, nil
+}
+
+// decodeFunctionMap decode Metro function map. Each segment is relative
+// (column, name index[, line]), and ';' starts a new line with column reset.
+func decodeFunctionMap(names []string, mappings string) ([]rnFunctionMapping, error) {
+	var (
+		res                   []rnFunctionMapping
+		line, column, nameIdx = 1, 0, 0
+	)
+
+	for i, group := range strings.Split(mappings, ";") {
+		if i > 0 {
+			column = 0
+		}
+
+		for _, seg := range strings.Split(group, ",") {
+			if seg == "" {
+				continue
+			}
+
+			vals, err := decodeVLQ(seg)
+			if err != nil {
+				return nil, err
+			}
+
+			if len(vals) < 2 {
+				return nil, fmt.Errorf("invalid function map segment %q", seg)
+			}
+
+			column += vals[0]
+			nameIdx += vals[1]
+			if len(vals) > 2 {
+				line += vals[2]
+			}
+
+			if nameIdx < 0 || nameIdx >= len(names) {
+				return nil, fmt.Errorf("name index %d out of range", nameIdx)
+			}
+
+			res = append(res, rnFunctionMapping{line: line, column: column, name: names[nameIdx]})
+		}
+	}
+
+	return res, nil
+}
+
+type rnSourcemap struct {
+	items   map[string]*rnSourcemapItem
+	modTime time.Time
+}
+
+var (
+	rnSourcemapCache = map[string]*rnSourcemap{}
+	rnSourcemapLock  sync.Mutex
+)
+
+func loadRNZipFile(zipFile string) (map[string]*rnSourcemapItem, error) {
+	zipReader, err := zip.OpenReader(zipFile)
+	if err != nil {
+		return nil, fmt.Errorf("zip.OpenReader(%q): %w", zipFile, err)
+	}
+	defer zipReader.Close() //nolint:errcheck
+
+	items := map[string]*rnSourcemapItem{}
+	for _, f := range zipReader.File {
+		fname, content, err := readZipSourcemapContent(f, MaxSourceMapFileSize, ".map")
+		if err != nil {
+			log.Errorf("read sourcemap %q failed: %s", f.Name, err.Error())
+			continue
+		}
+
+		if fname == "" {
+			continue
+		}
+
+		item, err := parseRNSourcemap(fname, content)
+		if err != nil {
+			log.Errorf("parse sourcemap %q failed: %s", f.Name, err.Error())
+			continue
+		}
+
+		log.Infof("load sourcemap file %q, hermes: %v", fname, item.isHermes())
+		items[fname] = item
+	}
+
+	return items, nil
+}
+
+// loadRNSourcemap load *.map within the zip, reload it if the zip updated.
+func loadRNSourcemap(zipFile string) (map[string]*rnSourcemapItem, error) {
+	rnSourcemapLock.Lock()
+	defer rnSourcemapLock.Unlock()
+
+	stat, err := os.Stat(zipFile)
+	if err != nil {
+		delete(rnSourcemapCache, zipFile)
+		return nil, err
+	}
+
+	if c, ok := rnSourcemapCache[zipFile]; ok && c.modTime.Equal(stat.ModTime()) {
+		return c.items, nil
+	}
+
+	items, err := loadRNZipFile(zipFile)
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("load react native sourcemap %s", zipFile)
+	rnSourcemapCache[zipFile] = &rnSourcemap{items: items, modTime: stat.ModTime()}
+	return items, nil
+}
+
+func deleteRNSourcemapCache(zipFile string) {
+	rnSourcemapLock.Lock()
+	defer rnSourcemapLock.Unlock()
+
+	delete(rnSourcemapCache, zipFile)
+}
+
+// findRNSourcemap find sourcemap of the bundle, the metro bundle usually
+// comes with only one sourcemap, so use it if no sourcemap matched by name.
+func findRNSourcemap(bundle string, items map[string]*rnSourcemapItem) *rnSourcemapItem {
+	mapFile := bundle + ".map"
+	for name, smap := range items {
+		if filepath.Base(name) == mapFile {
+			return smap
+		}
+	}
+
+	if len(items) == 1 {
+		for _, smap := range items {
+			return smap
+		}
+	}
+
+	return nil
+}
+
+// symbolicateRNFrame resolve the bundle location str, the original function
+// name returned too if the sourcemap comes with function map.
+//
+// Columns within JS stack are 1-based, while sourcemap's are 0-based. For
+// Hermes bytecode("address at ..."), the line is the 1-based segment ID and the
+// column is the bytecode virtual offset, which mapped as is by the sourcemap
+// composed with Hermes sourcemap.
+func symbolicateRNFrame(str string, items map[string]*rnSourcemapItem, status *sourceMapStatus) (string, string) {
+	m := rnFrameRegexp.FindStringSubmatch(str)
+	if len(m) != 5 {
+		return str, ""
+	}
+
+	line, err := strconv.Atoi(m[3])
+	if err != nil {
+		return str, ""
+	}
+	col, err := strconv.Atoi(m[4])
+	if err != nil {
+		return str, ""
+	}
+
+	smap := findRNSourcemap(m[2], items)
+	if smap == nil {
+		status.status = StatusError
+		status.reason = fmt.Sprintf("sourcemap file [%s.map] is required", m[2])
+		return str, ""
+	}
+
+	genCol := col - 1
+	if strings.HasPrefix(m[1], "address at ") {
+		if !smap.isHermes() {
+			status.status = StatusError
+			status.reason = fmt.Sprintf("[%s] is Hermes bytecode offset, sourcemap file [%s.map] should be composed with Hermes sourcemap", str, m[2])
+			return str, ""
+		}
+
+		if _, ok := smap.functionOffsets[strconv.Itoa(line-1)]; !ok {
+			status.status = StatusError
+			status.reason = fmt.Sprintf("bytecode segment of [%s] not found in sourcemap file [%s.map]", str, m[2])
+			return str, ""
+		}
+
+		genCol = col
+	}
+
+	file, _, srcLine, srcCol, ok := smap.Source(line, genCol)
+	if !ok {
+		status.status = StatusError
+		status.reason = fmt.Sprintf("fetch original source of [%s] failed, make sure sourcemap file [%s.map] is valid", str, m[2])
+		return str, ""
+	}
+
+	return fmt.Sprintf("%s:%d:%d", file, srcLine, srcCol+1), smap.functionName(file, srcLine, srcCol)
+}
+
+// symbolicateRNStack replace bundle locations within the stack with original
+// sources, and function names of the frames with original names if known.
+func symbolicateRNStack(errStack string, items map[string]*rnSourcemapItem, status *sourceMapStatus) string {
+	lines := strings.Split(errStack, "\n")
+	for i, line := range lines {
+		var name string
+		line = rnFrameRegexp.ReplaceAllStringFunc(line, func(str string) string {
+			var loc string
+			loc, name = symbolicateRNFrame(str, items, status)
+			return loc
+		})
+
+		if name != "" {
+			line = rnFrameNameRegexp.ReplaceAllString(line, "${1}${4}"+strings.ReplaceAll(name, "$", "$$")+"${3}${5}")
+		}
+
+		lines[i] = line
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+func (ipt *Input) resolveReactNativeSourceMap(p *point.Point, sdkName string, status *sourceMapStatus) (*point.Point, error) {
+	errStack, ok := p.Get("error_stack").(string)
+	if !ok {
+		status.status = StatusLackField
+		return p, nil
+	}
+
+	var (
+		appID, _   = p.Get("app_id").(string)
+		env, _     = p.Get("env").(string)
+		version, _ = p.Get("version").(string)
+	)
+
+	if appID == "" {
+		status.status = StatusLackField
+		return p, nil
+	}
+
+	zipFile := filepath.Join(ipt.getRumSourcemapDir(sdkName), GetSourcemapZipFileName(appID, env, version))
+	items, err := loadRNSourcemap(zipFile)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			status.status = StatusZipNotFound
+			status.reason = fmt.Sprintf("source map file [%s] not exists", zipFile)
+			return p, errors.New(status.reason)
+		}
+		return p, fmt.Errorf("load react native sourcemap failed: %w", err)
+	}
+
+	start := time.Now()
+	originStack := symbolicateRNStack(errStack, items, status)
+	sourceMapDurationSummary.WithLabelValues(sdkName, appID, env, version).Observe(float64(time.Since(start)) / promDurationUnit)
+
+	if status.status != StatusError {
+		status.status = StatusOK
+	}
+
+	p.MustAdd("error_stack_source_base64", base64.StdEncoding.EncodeToString([]byte(originStack)))
+	return p, nil
+}
@@ -34,11 +34,13 @@ const CacheInitCap = 16
 const TmpExpiredDirExt = ".expired-tmp"
 
 const (
-	SdkWeb        = "df_web_rum_sdk"
-	SdkWebMiniApp = "df_miniapp_rum_sdk"
-	SdkWebUniApp  = "df_uniapp_rum_sdk"
-	SdkAndroid    = "df_android_rum_sdk"
-	SdkIOS        = "df_ios_rum_sdk"
+	SdkWeb         = "df_web_rum_sdk"
+	SdkWebMiniApp  = "df_miniapp_rum_sdk"
+	SdkWebUniApp   = "df_uniapp_rum_sdk"
+	SdkAndroid     = "df_android_rum_sdk"
+	SdkIOS         = "df_ios_rum_sdk"
+	SdkReactNative = "df_react_native_rum_sdk"
+	SdkFlutter     = "df_flutter_rum_sdk"
 )
 
 const (
@@ -59,11 +61,13 @@ var (
 		"x86_64":      {},
 	}
 	srcMapDirs = map[string]string{
-		SdkWeb:        SourceMapDirWeb,
-		SdkWebMiniApp: SourceMapDirMini,
-		SdkWebUniApp:  SourceMapDirMini,
-		SdkAndroid:    SourceMapDirAndroid,
-		SdkIOS:        SourceMapDirIOS,
+		SdkWeb:         SourceMapDirWeb,
+		SdkWebMiniApp:  SourceMapDirMini,
+		SdkWebUniApp:   SourceMapDirMini,
+		SdkAndroid:     SourceMapDirAndroid,
+		SdkIOS:         SourceMapDirIOS,
+		SdkReactNative: SourceMapDirReactNative,
+		SdkFlutter:     SourceMapDirFlutter,
 	}
 
 	rumMetricAppID         = "app_id"
@@ -562,7 +566,9 @@ func checkZipEntry(f *zip.File) string {
 	return fname
 }
 
-func readZipSourcemap(f *zip.File, maxSize uint64, suffix string) (string, *sourcemap.Consumer, error) {
+// readZipSourcemapContent read raw content of the sourcemap file in zip,
+// empty name returned if the file ignored.
+func readZipSourcemapContent(f *zip.File, maxSize uint64, suffix string) (string, []byte, error) {
 	fname := checkSourcemapZipEntry(f, maxSize, suffix)
 	if fname == "" {
 		return "", nil, nil
@@ -579,6 +585,15 @@ func readZipSourcemap(f *zip.File, maxSize uint64, suffix string) (string, *sour
 		return "", nil, err
 	}
 
+	return fname, content, nil
+}
+
+func readZipSourcemap(f *zip.File, maxSize uint64, suffix string) (string, *sourcemap.Consumer, error) {
+	fname, content, err := readZipSourcemapContent(f, maxSize, suffix)
+	if err != nil || fname == "" {
+		return "", nil, err
+	}
+
 	smap, err := sourcemap.Parse(fname, content)
 	if err != nil {
 		return "", nil, err
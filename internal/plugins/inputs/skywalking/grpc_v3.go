@@ -82,6 +82,7 @@ func (m *MeterReportServiceServerImpl) Collect(collect agentv3.MeterReportServic
 
 	if err := m.feeder.FeedV2(point.Metric, []*point.Point{pt},
 		dkio.WithInputName("skywalking_meter"),
+		dkio.WithRequestHandler(true),
 	); err != nil {
 		log.Warnf("feeder error=%v", err)
 	}
@@ -103,6 +104,7 @@ func (m *MeterReportServiceServerImpl) CollectBatch(collects agentv3.MeterReport
 	}
 	if err := m.feeder.FeedV2(point.Metric, pts,
 		dkio.WithInputName("skywalking_meter"),
+		dkio.WithRequestHandler(true),
 	); err != nil {
 		log.Warnf("feeder error=%v", err)
 	}
@@ -208,6 +210,7 @@ func (r *JVMMetricReportServerV3Old) Collect(ctx context.Context, jvm *agentv3.J
 		if err := r.ipt.feeder.FeedV2(point.Metric, pts,
 			dkio.WithCollectCost(time.Since(start)),
 			dkio.WithInputName(jvmMetricName),
+			dkio.WithRequestHandler(true),
 		); err != nil {
 			r.ipt.feeder.FeedLastError(err.Error(),
 				metrics.WithLastErrorInput(inputName),
@@ -371,6 +374,7 @@ func (r *JVMMetricReportServerV3) Collect(ctx context.Context, jvm *agentv3.JVMM
 		if err := r.ipt.feeder.FeedV2(point.Metric, pts,
 			dkio.WithCollectCost(time.Since(start)),
 			dkio.WithInputName(jvmMetricName),
+			dkio.WithRequestHandler(true),
 		); err != nil {
 			r.ipt.feeder.FeedLastError(err.Error(),
 				metrics.WithLastErrorInput(inputName),
@@ -405,6 +409,7 @@ func (ls *LoggingServerV3) Collect(server loggingv3.LogReportService_CollectServ
 		} else {
 			if err := ls.Ipt.feeder.FeedV2(point.Logging, []*point.Point{pt},
 				dkio.WithInputName(logData.Service),
+				dkio.WithRequestHandler(true),
 			); err != nil {
 				log.Error(err.Error())
 			}
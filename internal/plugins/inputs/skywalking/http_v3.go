@@ -98,6 +98,7 @@ func (ipt *Input) handleSkyMetricV3(resp http.ResponseWriter, req *http.Request)
 		if err := ipt.feeder.FeedV2(point.Metric, pts,
 			dkio.WithCollectCost(time.Since(start)),
 			dkio.WithInputName(jvmMetricName),
+			dkio.WithRequestHandler(true),
 		); err != nil {
 			ipt.feeder.FeedLastError(err.Error(),
 				metrics.WithLastErrorInput(inputName),
@@ -146,6 +147,7 @@ func handleSkyLoggingV3(resp http.ResponseWriter, req *http.Request) {
 	} else {
 		if err := iptGlobal.feeder.FeedV2(point.Logging, []*point.Point{pt},
 			dkio.WithInputName(logdata.Service),
+			dkio.WithRequestHandler(true),
 		); err != nil {
 			log.Error(err.Error())
 		}
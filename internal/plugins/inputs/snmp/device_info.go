@@ -215,6 +215,7 @@ func (di *deviceInfo) ReportNetworkDeviceMetadata(store *snmputil.ResultValueSto
 	device := di.buildNetworkDeviceMetadata(deviceID, deviceIDTags, metadataStore, tags, deviceStatus)
 
 	interfaces := snmputil.BuildNetworkInterfacesMetadata(deviceID, metadataStore)
+	snmputil.DefaultInterfaceStore.Update(di.IP, interfaces) // shared with netflow enrichment
 
 	metadataPayloads := snmputil.BatchPayloads(di.Namespace, di.Subnet, collectTime, snmputil.PayloadMetadataBatchSize, device, interfaces)
 
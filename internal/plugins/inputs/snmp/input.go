@@ -102,6 +102,7 @@ type Input struct {
 	PickingExtra        []string          `toml:"extra"`
 	ObjectInterval      time.Duration     `toml:"object_interval,omitempty"`
 	MetricInterval      time.Duration     `toml:"metric_interval,omitempty"`
+	EnableTopology      bool              `toml:"enable_topology"`
 
 	Profiles       snmputil.ProfileDefinitionMap
 	CustomProfiles snmputil.ProfileConfigMap `toml:"custom_profiles,omitempty"`
@@ -136,6 +137,7 @@ type Input struct {
 	mFieldNameSpecified  map[string]struct{}
 	jobs                 chan Job
 	autodetectProfile    bool
+	topology             *topologyRegistry
 	feeder               dkio.Feeder
 	Tagger               datakit.GlobalTagger
 }
@@ -154,7 +156,7 @@ func (*Input) SampleConfig() string { return sampleCfg }
 func (*Input) AvailableArchs() []string { return datakit.AllOS }
 
 func (*Input) SampleMeasurement() []inputs.Measurement {
-	return []inputs.Measurement{&snmpmeasurement.SNMPObject{}, &snmpmeasurement.SNMPMetric{}}
+	return []inputs.Measurement{&snmpmeasurement.SNMPObject{}, &snmpmeasurement.SNMPMetric{}, &snmpmeasurement.SNMPLink{}}
 }
 
 func (ipt *Input) setup() {
@@ -518,6 +520,10 @@ func (ipt *Input) doCollectObject(deviceIP string, device *deviceInfo) {
 			metrics.WithLastErrorSource(snmpmeasurement.SNMPObjectName),
 		)
 	}
+
+	if ipt.EnableTopology {
+		ipt.doCollectTopology(deviceIP, device)
+	}
 }
 
 func (ipt *Input) doCollectMetrics(deviceIP string, device *deviceInfo) {
@@ -1249,6 +1255,8 @@ func defaultInput() *Input {
 		feeder:  dkio.DefaultFeeder(),
 		Tagger:  datakit.DefaultGlobalTagger(),
 		MaxOIDs: 1000,
+
+		topology: newTopologyRegistry(),
 	}
 }
 
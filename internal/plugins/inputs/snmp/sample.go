@@ -82,6 +82,10 @@ const sampleCfg = `
   ## Collect object interval, default is 5m. (optional)
   # object_interval = "5m"
 
+  ## Discover LLDP/CDP neighbors of devices and report links between them as
+  ## snmp_link objects, which build the L2 topology of devices.
+  # enable_topology = false
+
   ## Filling in excluded device IP address, example ["10.200.10.220", "10.200.10.221"].
   ## Only worked in auto discovery feature.
   ## This is optional.
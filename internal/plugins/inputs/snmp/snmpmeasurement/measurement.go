@@ -19,6 +19,7 @@ const (
 	InputName      = "snmp"
 	SNMPObjectName = "snmp_object"
 	SNMPMetricName = "snmp_metric"
+	SNMPLinkName   = "snmp_link"
 )
 
 //------------------------------------------------------------------------------
@@ -224,6 +225,54 @@ func (m *SNMPMetric) Info() *inputs.MeasurementInfo {
 	}
 }
 
+//------------------------------------------------------------------------------
+
+// SNMPLink is a L2 link between devices discovered by LLDP/CDP.
+type SNMPLink struct {
+	Name   string
+	Tags   map[string]string
+	Fields map[string]interface{}
+	TS     time.Time
+}
+
+// Point implement MeasurementV2.
+func (m *SNMPLink) Point() *point.Point {
+	opts := point.DefaultObjectOptions()
+	opts = append(opts, point.WithTime(m.TS))
+
+	return point.NewPointV2(m.Name,
+		append(point.NewTags(m.Tags), point.NewKVs(m.Fields)...),
+		opts...)
+}
+
+//nolint:lll
+func (m *SNMPLink) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: SNMPLinkName,
+		Desc: "Link between local device port and remote device port discovered by LLDP or CDP.",
+		Type: "object",
+		Fields: map[string]interface{}{
+			"local_port_index": newOtherFieldInfo(inputs.Int, inputs.Gauge, inputs.UnknownUnit, "Local port number(LLDP) or ifIndex(CDP)."),
+			"remote_port_desc": newOtherFieldInfo(inputs.String, inputs.String, inputs.UnknownUnit, "Remote port description, LLDP only."),
+			"remote_platform":  newOtherFieldInfo(inputs.String, inputs.String, inputs.UnknownUnit, "Remote device platform, CDP only."),
+			"remote_resolved":  newOtherFieldInfo(inputs.Bool, inputs.Gauge, inputs.UnknownUnit, "Whether the remote device is a device collected by the input."),
+		},
+		Tags: map[string]interface{}{
+			"name":               inputs.NewTagInfo("Link name, `<local_device_ip>:<local_port>-><remote_chassis_id>:<remote_port>`."),
+			"protocol":           inputs.NewTagInfo("Discovery protocol, `lldp` or `cdp`."),
+			"link_status":        inputs.NewTagInfo("`up`, or `down` if the neighbor disappeared since last collection."),
+			"device_namespace":   inputs.NewTagInfo("Device namespace."),
+			"local_device_ip":    inputs.NewTagInfo("Local device IP."),
+			"local_device_name":  inputs.NewTagInfo("Local device system name."),
+			"local_port":         inputs.NewTagInfo("Local port name."),
+			"remote_device_ip":   inputs.NewTagInfo("Remote device IP, resolved from collected devices or the advertised management address."),
+			"remote_device_name": inputs.NewTagInfo("Remote device system name(LLDP) or device ID(CDP)."),
+			"remote_chassis_id":  inputs.NewTagInfo("Remote chassis ID."),
+			"remote_port":        inputs.NewTagInfo("Remote port ID."),
+		},
+	}
+}
+
 func newOtherFieldInfo(datatype, ftype, unit, desc string) *inputs.FieldInfo {
 	return &inputs.FieldInfo{
 		DataType: datatype,
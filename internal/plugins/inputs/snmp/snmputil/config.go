@@ -180,6 +180,12 @@ var LegacyMetadataConfig = MetadataConfig{
 					Format: "mac_address",
 				},
 			},
+			"speed": {
+				Symbol: SymbolConfig{
+					OID:  "1.3.6.1.2.1.31.1.1.1.15",
+					Name: "ifHighSpeed",
+				},
+			},
 		},
 		IDTags: MetricTagConfigList{
 			{
@@ -567,6 +573,7 @@ var validMetadataResources = map[string]map[string]bool{
 		"mac_address":  true,
 		"admin_status": true,
 		"oper_status":  true,
+		"speed":        true,
 	},
 }
 
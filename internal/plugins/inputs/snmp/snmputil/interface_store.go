@@ -0,0 +1,68 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package snmputil
+
+import (
+	"sync"
+)
+
+type interfaceInfo struct {
+	name  string
+	speed uint64 // in bits per second
+}
+
+// InterfaceStore keeps interfaces of SNMP devices indexed by device IP and
+// ifIndex, so other inputs(such as netflow) can resolve interface names of
+// the same device.
+type InterfaceStore struct {
+	mtx     sync.RWMutex
+	devices map[string]map[uint32]*interfaceInfo
+}
+
+// DefaultInterfaceStore is updated by the snmp input on reporting device metadata.
+var DefaultInterfaceStore = NewInterfaceStore()
+
+// NewInterfaceStore returns an empty InterfaceStore.
+func NewInterfaceStore() *InterfaceStore {
+	return &InterfaceStore{
+		devices: map[string]map[uint32]*interfaceInfo{},
+	}
+}
+
+// Update replace all interfaces of device.
+func (s *InterfaceStore) Update(deviceIP string, interfaces []InterfaceMetadata) {
+	if len(interfaces) == 0 {
+		return
+	}
+
+	ifs := make(map[uint32]*interfaceInfo, len(interfaces))
+	for _, x := range interfaces {
+		if x.Index <= 0 {
+			continue
+		}
+
+		ifs[uint32(x.Index)] = &interfaceInfo{
+			name:  x.Name,
+			speed: x.Speed * 1000 * 1000, // ifHighSpeed in Mbps
+		}
+	}
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.devices[deviceIP] = ifs
+}
+
+// GetInterface returns name and speed(bits per second) of interface index on device.
+func (s *InterfaceStore) GetInterface(deviceIP string, index uint32) (string, uint64, bool) {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if x, ok := s.devices[deviceIP][index]; ok {
+		return x.name, x.speed, true
+	}
+
+	return "", 0, false
+}
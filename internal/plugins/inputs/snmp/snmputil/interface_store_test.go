@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package snmputil
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterfaceStore(t *testing.T) {
+	s := NewInterfaceStore()
+
+	s.Update("1.2.3.4", []InterfaceMetadata{
+		{Index: 1, Name: "eth0", Speed: 1000},
+		{Index: 2, Name: "eth1"},
+	})
+
+	name, speed, ok := s.GetInterface("1.2.3.4", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "eth0", name)
+	assert.Equal(t, uint64(1000*1000*1000), speed)
+
+	_, _, ok = s.GetInterface("1.2.3.4", 3)
+	assert.False(t, ok)
+
+	_, _, ok = s.GetInterface("5.6.7.8", 1)
+	assert.False(t, ok)
+
+	// replaced, empty update ignored
+	s.Update("1.2.3.4", []InterfaceMetadata{{Index: 1, Name: "ge-0/0/1"}})
+	s.Update("1.2.3.4", nil)
+
+	name, _, ok = s.GetInterface("1.2.3.4", 1)
+	assert.True(t, ok)
+	assert.Equal(t, "ge-0/0/1", name)
+
+	_, _, ok = s.GetInterface("1.2.3.4", 2)
+	assert.False(t, ok)
+}
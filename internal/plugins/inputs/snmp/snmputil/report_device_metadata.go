@@ -103,6 +103,7 @@ func BuildNetworkInterfacesMetadata(deviceID string, store *Store) []InterfaceMe
 			MacAddress:  store.GetColumnAsString("interface.mac_address", strIndex),
 			AdminStatus: int32(store.GetColumnAsFloat("interface.admin_status", strIndex)),
 			OperStatus:  int32(store.GetColumnAsFloat("interface.oper_status", strIndex)),
+			Speed:       uint64(store.GetColumnAsFloat("interface.speed", strIndex)),
 			IDTags:      ifIDTags,
 		}
 		interfaces = append(interfaces, networkInterface)
@@ -7,6 +7,7 @@
 package snmputil
 
 import (
+	"strings"
 	"time"
 
 	"github.com/gosnmp/gosnmp"
@@ -101,3 +102,42 @@ func CreateMockSession() *MockSession {
 func NewMockSession() (Session, error) {
 	return CreateMockSession(), nil
 }
+
+// MockTopology mocks walks of LLDP/CDP tables with snmprec-style pdus, tables
+// without pdus are empty, it's used for testing.
+func (s *MockSession) MockTopology(pdus []gosnmp.SnmpPDU) {
+	for _, oid := range []string{
+		lldpRemChassisIDSubtypeOid,
+		lldpRemChassisIDOid,
+		lldpRemPortIDSubtypeOid,
+		lldpRemPortIDOid,
+		lldpRemPortDescOid,
+		lldpRemSysNameOid,
+		lldpLocPortIDOid,
+		lldpLocPortDescOid,
+		lldpRemManAddrIfSubtypeOid,
+		cdpCacheAddressOid,
+		cdpCacheDeviceIDOid,
+		cdpCacheDevicePortOid,
+		cdpCachePlatformOid,
+	} {
+		column := []gosnmp.SnmpPDU{}
+		for _, pdu := range pdus {
+			if strings.HasPrefix(strings.TrimLeft(pdu.Name, "."), oid+".") {
+				column = append(column, pdu)
+			}
+		}
+		s.On("GetWalkAll", oid).Return(column, nil)
+	}
+}
+
+// MockLocalChassisID mocks LLDP local chassis ID of MAC address subtype, it's
+// used for testing.
+func (s *MockSession) MockLocalChassisID(mac []byte) {
+	s.On("Get", []string{lldpLocChassisIDSubtypeOid, lldpLocChassisIDOid}).Return(&gosnmp.SnmpPacket{
+		Variables: []gosnmp.SnmpPDU{
+			{Name: lldpLocChassisIDSubtypeOid, Type: gosnmp.Integer, Value: lldpChassisIDMacAddress},
+			{Name: lldpLocChassisIDOid, Type: gosnmp.OctetString, Value: mac},
+		},
+	}, nil)
+}
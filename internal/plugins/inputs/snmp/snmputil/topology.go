@@ -0,0 +1,311 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package snmputil
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gosnmp/gosnmp"
+)
+
+// Neighbor discovery protocols.
+const (
+	TopologyLLDP = "lldp"
+	TopologyCDP  = "cdp"
+)
+
+// LLDP-MIB and CISCO-CDP-MIB OIDs.
+const (
+	lldpLocChassisIDSubtypeOid = "1.0.8802.1.1.2.1.3.1.0"
+	lldpLocChassisIDOid        = "1.0.8802.1.1.2.1.3.2.0"
+
+	lldpLocPortIDOid   = "1.0.8802.1.1.2.1.3.7.1.3" // index: lldpLocPortNum
+	lldpLocPortDescOid = "1.0.8802.1.1.2.1.3.7.1.4"
+
+	// index: lldpRemTimeMark.lldpRemLocalPortNum.lldpRemIndex
+	lldpRemChassisIDSubtypeOid = "1.0.8802.1.1.2.1.4.1.1.4"
+	lldpRemChassisIDOid        = "1.0.8802.1.1.2.1.4.1.1.5"
+	lldpRemPortIDSubtypeOid    = "1.0.8802.1.1.2.1.4.1.1.6"
+	lldpRemPortIDOid           = "1.0.8802.1.1.2.1.4.1.1.7"
+	lldpRemPortDescOid         = "1.0.8802.1.1.2.1.4.1.1.8"
+	lldpRemSysNameOid          = "1.0.8802.1.1.2.1.4.1.1.9"
+
+	// index: lldpRemTimeMark.lldpRemLocalPortNum.lldpRemIndex.addrSubtype.addrLen.addr...
+	lldpRemManAddrIfSubtypeOid = "1.0.8802.1.1.2.1.4.2.1.3"
+
+	// index: cdpCacheIfIndex.cdpCacheDeviceIndex
+	cdpCacheAddressOid    = "1.3.6.1.4.1.9.9.23.1.2.1.1.4"
+	cdpCacheDeviceIDOid   = "1.3.6.1.4.1.9.9.23.1.2.1.1.6"
+	cdpCacheDevicePortOid = "1.3.6.1.4.1.9.9.23.1.2.1.1.7"
+	cdpCachePlatformOid   = "1.3.6.1.4.1.9.9.23.1.2.1.1.8"
+)
+
+// LLDP chassis/port ID subtypes(LldpChassisIdSubtype and LldpPortIdSubtype).
+const (
+	lldpChassisIDNetworkAddress = 5
+	lldpChassisIDMacAddress     = 4
+	lldpPortIDMacAddress        = 3
+	lldpPortIDNetworkAddress    = 4
+)
+
+// Neighbor is a remote device seen on local port by LLDP or CDP.
+type Neighbor struct {
+	Protocol       string
+	LocalPortIndex int // lldpLocPortNum for LLDP, ifIndex for CDP
+	LocalPort      string
+
+	RemoteChassisID string
+	RemoteSysName   string
+	RemotePort      string
+	RemotePortDesc  string
+	RemoteAddress   string
+	RemotePlatform  string
+}
+
+// Key identify the link of neighbor on local device.
+func (n *Neighbor) Key() string {
+	return fmt.Sprintf("%s:%d:%s:%s", n.Protocol, n.LocalPortIndex, n.RemoteChassisID, n.RemotePort)
+}
+
+// walkColumn walk column oid, returns values indexed by the OID suffix.
+func walkColumn(sess Session, oid string) (map[string]gosnmp.SnmpPDU, error) {
+	pdus, err := sess.GetWalkAll(oid)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]gosnmp.SnmpPDU, len(pdus))
+	for _, pdu := range pdus {
+		idx := strings.TrimPrefix(strings.TrimLeft(pdu.Name, "."), oid+".")
+		res[idx] = pdu
+	}
+	return res, nil
+}
+
+func pduBytes(pdu gosnmp.SnmpPDU) []byte {
+	switch v := pdu.Value.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	default:
+		return nil
+	}
+}
+
+func pduString(pdu gosnmp.SnmpPDU) string {
+	v, err := GetValueFromPDU(pdu)
+	if err != nil {
+		return ""
+	}
+
+	s, err := StandardTypeToString(v)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+func pduInt(pdu gosnmp.SnmpPDU) int {
+	if pdu.Value == nil {
+		return 0
+	}
+	return int(gosnmp.ToBigInt(pdu.Value).Int64())
+}
+
+// FormatMAC format 6 bytes as MAC address, such as 00:1a:2b:3c:4d:5e.
+func FormatMAC(b []byte) string {
+	if len(b) != 6 {
+		return ""
+	}
+	return net.HardwareAddr(b).String()
+}
+
+// formatID format LLDP chassis/port ID by its subtype.
+func formatID(b []byte, isMAC, isNetworkAddress bool) string {
+	switch {
+	case isMAC && len(b) == 6:
+		return FormatMAC(b)
+	case isNetworkAddress && len(b) == 5 && b[0] == 1: // IANA address family 1: IPv4
+		return net.IP(b[1:]).String()
+	case IsStringPrintable(b):
+		return strings.TrimSpace(string(b))
+	case len(b) == 6:
+		return FormatMAC(b)
+	default:
+		return fmt.Sprintf("%#x", b)
+	}
+}
+
+// FetchLocalChassisID returns LLDP chassis ID of the device, empty if LLDP not
+// supported.
+func FetchLocalChassisID(sess Session) string {
+	pkt, err := sess.Get([]string{lldpLocChassisIDSubtypeOid, lldpLocChassisIDOid})
+	if err != nil || pkt == nil || len(pkt.Variables) != 2 {
+		return ""
+	}
+
+	subtype, pdu := pkt.Variables[0], pkt.Variables[1]
+	if pdu.Type != gosnmp.OctetString {
+		return ""
+	}
+
+	return formatID(pduBytes(pdu),
+		pduInt(subtype) == lldpChassisIDMacAddress,
+		pduInt(subtype) == lldpChassisIDNetworkAddress)
+}
+
+// FetchLLDPNeighbors walk LLDP-MIB remote systems table of the device.
+func FetchLLDPNeighbors(sess Session) ([]*Neighbor, error) {
+	chassisIDs, err := walkColumn(sess, lldpRemChassisIDOid)
+	if err != nil {
+		return nil, fmt.Errorf("walk lldpRemChassisId: %w", err)
+	}
+
+	if len(chassisIDs) == 0 {
+		return nil, nil
+	}
+
+	// Other columns are optional.
+	columns := map[string]map[string]gosnmp.SnmpPDU{}
+	for _, oid := range []string{
+		lldpRemChassisIDSubtypeOid,
+		lldpRemPortIDSubtypeOid,
+		lldpRemPortIDOid,
+		lldpRemPortDescOid,
+		lldpRemSysNameOid,
+		lldpLocPortIDOid,
+		lldpLocPortDescOid,
+		lldpRemManAddrIfSubtypeOid,
+	} {
+		if columns[oid], err = walkColumn(sess, oid); err != nil {
+			l.Debugf("walk %s: %s, ignored", oid, err)
+		}
+	}
+
+	addrs := lldpManAddrs(columns[lldpRemManAddrIfSubtypeOid])
+
+	var res []*Neighbor
+	for idx, pdu := range chassisIDs {
+		parts := strings.Split(idx, ".")
+		if len(parts) != 3 {
+			continue
+		}
+
+		localPortNum, err := strconv.Atoi(parts[1])
+		if err != nil {
+			continue
+		}
+
+		n := &Neighbor{
+			Protocol:       TopologyLLDP,
+			LocalPortIndex: localPortNum,
+			RemoteChassisID: formatID(pduBytes(pdu),
+				pduInt(columns[lldpRemChassisIDSubtypeOid][idx]) == lldpChassisIDMacAddress,
+				pduInt(columns[lldpRemChassisIDSubtypeOid][idx]) == lldpChassisIDNetworkAddress),
+			RemotePort: formatID(pduBytes(columns[lldpRemPortIDOid][idx]),
+				pduInt(columns[lldpRemPortIDSubtypeOid][idx]) == lldpPortIDMacAddress,
+				pduInt(columns[lldpRemPortIDSubtypeOid][idx]) == lldpPortIDNetworkAddress),
+			RemotePortDesc: pduString(columns[lldpRemPortDescOid][idx]),
+			RemoteSysName:  pduString(columns[lldpRemSysNameOid][idx]),
+			RemoteAddress:  addrs[idx],
+		}
+
+		// Prefer description of local port, it's ifDescr/ifName on most devices.
+		if n.LocalPort = pduString(columns[lldpLocPortDescOid][parts[1]]); n.LocalPort == "" {
+			n.LocalPort = pduString(columns[lldpLocPortIDOid][parts[1]])
+		}
+
+		res = append(res, n)
+	}
+
+	sortNeighbors(res)
+	return res, nil
+}
+
+// lldpManAddrs returns the lowest IPv4 management address of remote systems,
+// the address is encoded in the index of lldpRemManAddrTable.
+func lldpManAddrs(pdus map[string]gosnmp.SnmpPDU) map[string]string {
+	res := map[string]string{}
+	for idx := range pdus {
+		parts := strings.Split(idx, ".")
+		// timeMark.localPortNum.remIndex.subtype(1: IPv4).len(4).a.b.c.d
+		if len(parts) != 9 || parts[3] != "1" || parts[4] != "4" {
+			continue
+		}
+
+		remIdx, addr := strings.Join(parts[:3], "."), strings.Join(parts[5:], ".")
+		if cur, ok := res[remIdx]; !ok || addr < cur {
+			res[remIdx] = addr
+		}
+	}
+	return res
+}
+
+// FetchCDPNeighbors walk CISCO-CDP-MIB cache table of the device.
+func FetchCDPNeighbors(sess Session) ([]*Neighbor, error) {
+	deviceIDs, err := walkColumn(sess, cdpCacheDeviceIDOid)
+	if err != nil {
+		return nil, fmt.Errorf("walk cdpCacheDeviceId: %w", err)
+	}
+
+	if len(deviceIDs) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]map[string]gosnmp.SnmpPDU{}
+	for _, oid := range []string{cdpCacheAddressOid, cdpCacheDevicePortOid, cdpCachePlatformOid} {
+		if columns[oid], err = walkColumn(sess, oid); err != nil {
+			l.Debugf("walk %s: %s, ignored", oid, err)
+		}
+	}
+
+	var res []*Neighbor
+	for idx, pdu := range deviceIDs {
+		parts := strings.Split(idx, ".")
+		if len(parts) != 2 {
+			continue
+		}
+
+		ifIndex, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+
+		// CDP device ID is used as both chassis ID and system name.
+		deviceID := pduString(pdu)
+		n := &Neighbor{
+			Protocol:        TopologyCDP,
+			LocalPortIndex:  ifIndex,
+			RemoteChassisID: deviceID,
+			RemoteSysName:   deviceID,
+			RemotePort:      pduString(columns[cdpCacheDevicePortOid][idx]),
+			RemotePlatform:  pduString(columns[cdpCachePlatformOid][idx]),
+		}
+
+		if addr := pduBytes(columns[cdpCacheAddressOid][idx]); len(addr) == 4 {
+			n.RemoteAddress = net.IP(addr).String()
+		}
+
+		res = append(res, n)
+	}
+
+	sortNeighbors(res)
+	return res, nil
+}
+
+func sortNeighbors(arr []*Neighbor) {
+	sort.Slice(arr, func(i, j int) bool {
+		if arr[i].LocalPortIndex != arr[j].LocalPortIndex {
+			return arr[i].LocalPortIndex < arr[j].LocalPortIndex
+		}
+		return arr[i].Key() < arr[j].Key()
+	})
+}
@@ -0,0 +1,107 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package snmputil
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchLLDPNeighbors(t *testing.T) {
+	sess := CreateMockSession()
+	sess.MockTopology([]gosnmp.SnmpPDU{
+		// neighbor 0.3.1: chassis ID is MAC, port ID is interface name
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.4.0.3.1", Type: gosnmp.Integer, Value: 4},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.5.0.3.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e}},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.6.0.3.1", Type: gosnmp.Integer, Value: 5},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.7.0.3.1", Type: gosnmp.OctetString, Value: []byte("Gi0/1")},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.8.0.3.1", Type: gosnmp.OctetString, Value: []byte("GigabitEthernet0/1")},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.9.0.3.1", Type: gosnmp.OctetString, Value: []byte("core-sw")},
+		{Name: ".1.0.8802.1.1.2.1.4.2.1.3.0.3.1.1.4.10.0.0.9", Type: gosnmp.Integer, Value: 2},
+		{Name: ".1.0.8802.1.1.2.1.4.2.1.3.0.3.1.1.4.10.0.0.2", Type: gosnmp.Integer, Value: 2},
+
+		// neighbor 0.1.2: chassis ID is local name, port ID is MAC
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.4.0.1.2", Type: gosnmp.Integer, Value: 7},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.5.0.1.2", Type: gosnmp.OctetString, Value: []byte("server-1")},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.6.0.1.2", Type: gosnmp.Integer, Value: 3},
+		{Name: ".1.0.8802.1.1.2.1.4.1.1.7.0.1.2", Type: gosnmp.OctetString, Value: []byte{0xaa, 0xbb, 0xcc, 0x00, 0x11, 0x22}},
+
+		{Name: ".1.0.8802.1.1.2.1.3.7.1.3.1", Type: gosnmp.OctetString, Value: []byte("port-1")},
+		{Name: ".1.0.8802.1.1.2.1.3.7.1.3.3", Type: gosnmp.OctetString, Value: []byte("port-3")},
+		{Name: ".1.0.8802.1.1.2.1.3.7.1.4.3", Type: gosnmp.OctetString, Value: []byte("Gi1/0/3")},
+	})
+
+	neighbors, err := FetchLLDPNeighbors(sess)
+	require.NoError(t, err)
+	require.Len(t, neighbors, 2)
+
+	assert.Equal(t, &Neighbor{
+		Protocol:        TopologyLLDP,
+		LocalPortIndex:  1,
+		LocalPort:       "port-1",
+		RemoteChassisID: "server-1",
+		RemotePort:      "aa:bb:cc:00:11:22",
+	}, neighbors[0])
+
+	assert.Equal(t, &Neighbor{
+		Protocol:        TopologyLLDP,
+		LocalPortIndex:  3,
+		LocalPort:       "Gi1/0/3",
+		RemoteChassisID: "00:1a:2b:3c:4d:5e",
+		RemoteSysName:   "core-sw",
+		RemotePort:      "Gi0/1",
+		RemotePortDesc:  "GigabitEthernet0/1",
+		RemoteAddress:   "10.0.0.2",
+	}, neighbors[1])
+}
+
+func TestFetchCDPNeighbors(t *testing.T) {
+	sess := CreateMockSession()
+	sess.MockTopology([]gosnmp.SnmpPDU{
+		{Name: ".1.3.6.1.4.1.9.9.23.1.2.1.1.4.10101.5", Type: gosnmp.OctetString, Value: []byte{10, 0, 0, 3}},
+		{Name: ".1.3.6.1.4.1.9.9.23.1.2.1.1.6.10101.5", Type: gosnmp.OctetString, Value: []byte("access-sw.example.com")},
+		{Name: ".1.3.6.1.4.1.9.9.23.1.2.1.1.7.10101.5", Type: gosnmp.OctetString, Value: []byte("GigabitEthernet1/0/48")},
+		{Name: ".1.3.6.1.4.1.9.9.23.1.2.1.1.8.10101.5", Type: gosnmp.OctetString, Value: []byte("cisco WS-C2960X-48TS-L")},
+	})
+
+	neighbors, err := FetchCDPNeighbors(sess)
+	require.NoError(t, err)
+	require.Len(t, neighbors, 1)
+
+	assert.Equal(t, &Neighbor{
+		Protocol:        TopologyCDP,
+		LocalPortIndex:  10101,
+		RemoteChassisID: "access-sw.example.com",
+		RemoteSysName:   "access-sw.example.com",
+		RemotePort:      "GigabitEthernet1/0/48",
+		RemoteAddress:   "10.0.0.3",
+		RemotePlatform:  "cisco WS-C2960X-48TS-L",
+	}, neighbors[0])
+}
+
+func TestFetchNeighborsNotSupported(t *testing.T) {
+	sess := CreateMockSession()
+	sess.MockTopology(nil)
+
+	neighbors, err := FetchLLDPNeighbors(sess)
+	assert.NoError(t, err)
+	assert.Empty(t, neighbors)
+
+	sess = CreateMockSession()
+	sess.On("GetWalkAll", cdpCacheDeviceIDOid).Return([]gosnmp.SnmpPDU{}, errors.New("timeout"))
+	_, err = FetchCDPNeighbors(sess)
+	assert.Error(t, err)
+}
+
+func TestFetchLocalChassisID(t *testing.T) {
+	sess := CreateMockSession()
+	sess.MockLocalChassisID([]byte{0x00, 0x1a, 0x2b, 0x3c, 0x4d, 0x5e})
+	assert.Equal(t, "00:1a:2b:3c:4d:5e", FetchLocalChassisID(sess))
+}
@@ -563,3 +563,37 @@ var zabbixYaml = `zabbix_export:
                 - tag: Application
                   value: 'Network Interfaces'
 `
+
+func getMockTopologySession(sysName string, chassisID []byte, pdus []gosnmp.SnmpPDU) snmputil.Session {
+	sess := snmputil.CreateMockSession()
+	sess.On("Get", []string{sysNameOID}).Return(&gosnmp.SnmpPacket{
+		Variables: []gosnmp.SnmpPDU{{
+			Name: sysNameOID, Type: gosnmp.OctetString, Value: []byte(sysName),
+		}},
+	}, nil)
+	sess.MockLocalChassisID(chassisID)
+	sess.MockTopology(pdus)
+	return sess
+}
+
+// sw-a(10.0.0.1) port 1 <-> sw-b(10.0.0.2) Gi0/2, sw-a advertises LLDP and sw-b advertises CDP.
+func getMockTopologyDevices(ipt *Input) (*deviceInfo, *deviceInfo) {
+	a := &deviceInfo{Ipt: ipt, IP: "10.0.0.1", Namespace: "default", Session: getMockTopologySession("sw-a",
+		[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x0a},
+		[]gosnmp.SnmpPDU{
+			{Name: ".1.0.8802.1.1.2.1.4.1.1.4.0.1.1", Type: gosnmp.Integer, Value: 4},
+			{Name: ".1.0.8802.1.1.2.1.4.1.1.5.0.1.1", Type: gosnmp.OctetString, Value: []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x0b}},
+			{Name: ".1.0.8802.1.1.2.1.4.1.1.6.0.1.1", Type: gosnmp.Integer, Value: 5},
+			{Name: ".1.0.8802.1.1.2.1.4.1.1.7.0.1.1", Type: gosnmp.OctetString, Value: []byte("Gi0/2")},
+			{Name: ".1.0.8802.1.1.2.1.4.1.1.9.0.1.1", Type: gosnmp.OctetString, Value: []byte("SW-B")},
+		})}
+
+	b := &deviceInfo{Ipt: ipt, IP: "10.0.0.2", Namespace: "default", Session: getMockTopologySession("sw-b",
+		[]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x0b},
+		[]gosnmp.SnmpPDU{
+			{Name: ".1.3.6.1.4.1.9.9.23.1.2.1.1.6.2.1", Type: gosnmp.OctetString, Value: []byte("sw-a.example.com")},
+			{Name: ".1.3.6.1.4.1.9.9.23.1.2.1.1.7.2.1", Type: gosnmp.OctetString, Value: []byte("port-1")},
+		})}
+
+	return a, b
+}
@@ -0,0 +1,304 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package snmp
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/snmp/snmpmeasurement"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/snmp/snmputil"
+)
+
+const (
+	linkStatusUp   = "up"
+	linkStatusDown = "down"
+)
+
+// topologyRegistry keeps identities of collected devices to resolve remote
+// devices of LLDP/CDP neighbors, and the last reported neighbors of each
+// device to detect disappeared links.
+type topologyRegistry struct {
+	mtx       sync.RWMutex
+	byChassis map[string]string // chassis ID -> device IP
+	byName    map[string]string // sysName -> device IP
+	names     map[string]string // device IP -> sysName, all registered devices
+
+	neighbors map[string]map[string]*snmputil.Neighbor // device IP -> neighbor key -> neighbor
+}
+
+func newTopologyRegistry() *topologyRegistry {
+	return &topologyRegistry{
+		byChassis: map[string]string{},
+		byName:    map[string]string{},
+		names:     map[string]string{},
+		neighbors: map[string]map[string]*snmputil.Neighbor{},
+	}
+}
+
+// nameKeys returns lookup keys of system name: itself and its short name. CDP
+// device ID may be FQDN or with serial number, such as sw1.example.com or
+// sw1(FOC1234X0AB).
+func nameKeys(name string) []string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" {
+		return nil
+	}
+
+	keys := []string{name}
+	if i := strings.IndexAny(name, ".("); i > 0 {
+		keys = append(keys, name[:i])
+	}
+	return keys
+}
+
+func (r *topologyRegistry) register(deviceIP, sysName, chassisID string) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if chassisID != "" {
+		r.byChassis[strings.ToLower(chassisID)] = deviceIP
+	}
+
+	for _, k := range nameKeys(sysName) {
+		r.byName[k] = deviceIP
+	}
+
+	r.names[deviceIP] = sysName
+}
+
+// resolve returns IP and name of the remote device of neighbor if it's
+// collected by the input.
+func (r *topologyRegistry) resolve(n *snmputil.Neighbor) (string, string, bool) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	ip, ok := r.byChassis[strings.ToLower(n.RemoteChassisID)]
+	if !ok {
+		for _, k := range nameKeys(n.RemoteSysName) {
+			if ip, ok = r.byName[k]; ok {
+				break
+			}
+		}
+	}
+
+	if !ok && n.RemoteAddress != "" {
+		_, ok = r.names[n.RemoteAddress]
+		ip = n.RemoteAddress
+	}
+
+	if !ok {
+		return "", "", false
+	}
+
+	return ip, r.names[ip], true
+}
+
+// update replace neighbors of device, returns neighbors disappeared. Last
+// neighbors of failed protocols are kept, they are unknown instead of gone.
+func (r *topologyRegistry) update(deviceIP string, neighbors []*snmputil.Neighbor, failed map[string]bool) []*snmputil.Neighbor {
+	cur := make(map[string]*snmputil.Neighbor, len(neighbors))
+	for _, n := range neighbors {
+		cur[n.Key()] = n
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var gone []*snmputil.Neighbor
+	for k, n := range r.neighbors[deviceIP] {
+		if _, ok := cur[k]; ok {
+			continue
+		}
+
+		if failed[n.Protocol] {
+			cur[k] = n
+		} else {
+			gone = append(gone, n)
+		}
+	}
+
+	r.neighbors[deviceIP] = cur
+	return gone
+}
+
+// fetchNeighbors walk LLDP and CDP neighbors of device, and register the device
+// for resolving. Protocols failed to walk are returned in failed.
+func (ipt *Input) fetchNeighbors(deviceIP string, device *deviceInfo) (sysName string,
+	neighbors []*snmputil.Neighbor, failed map[string]bool, err error,
+) {
+	sess := device.Session
+	if err := sess.Connect(); err != nil {
+		return "", nil, nil, err
+	}
+	defer func() {
+		if err := sess.Close(); err != nil {
+			l.Warnf("failed to close session: err = (%v), ip = (%s)", err, deviceIP)
+		}
+	}()
+
+	sysName = device.getStringValue(sysNameOID)
+	if sysName == "unknown" {
+		sysName = ""
+	}
+	ipt.topology.register(deviceIP, sysName, snmputil.FetchLocalChassisID(sess))
+
+	failed = map[string]bool{}
+	var errs []string
+	for protocol, fetch := range map[string]func(snmputil.Session) ([]*snmputil.Neighbor, error){
+		snmputil.TopologyLLDP: snmputil.FetchLLDPNeighbors,
+		snmputil.TopologyCDP:  snmputil.FetchCDPNeighbors,
+	} {
+		arr, err := fetch(sess)
+		if err != nil {
+			failed[protocol] = true
+			errs = append(errs, err.Error())
+			continue
+		}
+		neighbors = append(neighbors, arr...)
+	}
+
+	// Both failed, keep the last neighbors.
+	if len(failed) == 2 {
+		return sysName, nil, nil, fmt.Errorf("%s", strings.Join(errs, "; "))
+	}
+
+	if len(errs) > 0 {
+		l.Warnf("fetch neighbors of %s: %s, last links of the protocol kept", deviceIP, errs[0])
+	}
+
+	for _, n := range neighbors {
+		if n.LocalPort != "" {
+			continue
+		}
+
+		// lldpLocPortNum is ifIndex on most devices.
+		if name, _, ok := snmputil.DefaultInterfaceStore.GetInterface(deviceIP, uint32(n.LocalPortIndex)); ok && name != "" {
+			n.LocalPort = name
+		} else {
+			n.LocalPort = fmt.Sprintf("%d", n.LocalPortIndex)
+		}
+	}
+
+	return sysName, neighbors, failed, nil
+}
+
+// collectTopology returns link points of device's neighbors, including links
+// disappeared since last collection.
+func (ipt *Input) collectTopology(deviceIP string, device *deviceInfo, tn time.Time) ([]*point.Point, error) {
+	sysName, neighbors, failed, err := ipt.fetchNeighbors(deviceIP, device)
+	if err != nil {
+		return nil, err
+	}
+
+	gone := ipt.topology.update(deviceIP, neighbors, failed)
+
+	var pts []*point.Point
+	for status, arr := range map[string][]*snmputil.Neighbor{
+		linkStatusUp:   neighbors,
+		linkStatusDown: gone,
+	} {
+		for _, n := range arr {
+			pts = append(pts, ipt.linkPoint(deviceIP, sysName, device.Namespace, n, status, tn))
+		}
+	}
+
+	return pts, nil
+}
+
+func (ipt *Input) linkPoint(deviceIP, sysName, namespace string, n *snmputil.Neighbor, status string, tn time.Time) *point.Point {
+	remoteIP, remoteName, resolved := ipt.topology.resolve(n)
+	if !resolved {
+		remoteIP, remoteName = n.RemoteAddress, n.RemoteSysName
+	}
+
+	if remoteName == "" {
+		remoteName = n.RemoteSysName
+	}
+
+	// Name by chassis ID, so it's unchanged once the remote device resolved.
+	remote := n.RemoteChassisID
+	if remote == "" {
+		remote = n.RemoteSysName
+	}
+
+	tags := map[string]string{
+		"name":               fmt.Sprintf("%s:%s->%s:%s", deviceIP, n.LocalPort, remote, n.RemotePort),
+		"protocol":           n.Protocol,
+		"link_status":        status,
+		"device_namespace":   namespace,
+		"local_device_ip":    deviceIP,
+		"local_device_name":  sysName,
+		"local_port":         n.LocalPort,
+		"remote_device_ip":   remoteIP,
+		"remote_device_name": remoteName,
+		"remote_chassis_id":  n.RemoteChassisID,
+		"remote_port":        n.RemotePort,
+	}
+
+	for k, v := range ipt.Tags {
+		tags[k] = v
+	}
+
+	if ipt.Election {
+		tags = inputs.MergeTags(ipt.Tagger.ElectionTags(), tags, "")
+	} else {
+		tags = inputs.MergeTags(ipt.Tagger.HostTags(), tags, "")
+	}
+
+	fields := map[string]interface{}{
+		"local_port_index": n.LocalPortIndex,
+		"remote_resolved":  resolved,
+	}
+
+	if n.RemotePortDesc != "" {
+		fields["remote_port_desc"] = n.RemotePortDesc
+	}
+
+	if n.RemotePlatform != "" {
+		fields["remote_platform"] = n.RemotePlatform
+	}
+
+	link := &snmpmeasurement.SNMPLink{
+		Name:   snmpmeasurement.SNMPLinkName,
+		Tags:   tags,
+		Fields: fields,
+		TS:     tn,
+	}
+
+	return link.Point()
+}
+
+func (ipt *Input) doCollectTopology(deviceIP string, device *deviceInfo) {
+	tn := time.Now().UTC()
+	pts, err := ipt.collectTopology(deviceIP, device, tn)
+	if err != nil {
+		l.Warnf("collect topology of %s failed: %v", deviceIP, err)
+		return
+	}
+
+	if len(pts) == 0 {
+		return
+	}
+
+	if err := ipt.feeder.FeedV2(point.Object, pts,
+		dkio.WithCollectCost(time.Since(tn)),
+		dkio.WithElection(ipt.Election),
+		dkio.WithInputName(snmpmeasurement.SNMPLinkName),
+	); err != nil {
+		l.Errorf("FeedMeasurement link err: %v", err)
+		ipt.feeder.FeedLastError(err.Error(),
+			metrics.WithLastErrorInput(snmpmeasurement.InputName),
+			metrics.WithLastErrorSource(snmpmeasurement.SNMPLinkName),
+		)
+	}
+}
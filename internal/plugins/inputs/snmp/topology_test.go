@@ -0,0 +1,111 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package snmp
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/gosnmp/gosnmp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/snmp/snmputil"
+)
+
+func TestCollectTopology(t *testing.T) {
+	ipt := defaultInput()
+	ipt.Tags = map[string]string{"tag1": "val1"}
+	a, b := getMockTopologyDevices(ipt)
+
+	link := func(pts []*point.Point) *point.Point {
+		t.Helper()
+		require.Len(t, pts, 1)
+		return pts[0]
+	}
+
+	t.Run("unresolved", func(t *testing.T) {
+		pts, err := ipt.collectTopology(a.IP, a, time.Now())
+		require.NoError(t, err)
+
+		pt := link(pts)
+		assert.Equal(t, "10.0.0.1:1->00:00:00:00:00:0b:Gi0/2", pt.Get("name"))
+		assert.Equal(t, "lldp", pt.Get("protocol"))
+		assert.Equal(t, "up", pt.Get("link_status"))
+		assert.Equal(t, "sw-a", pt.Get("local_device_name"))
+		assert.Equal(t, "00:00:00:00:00:0b", pt.Get("remote_chassis_id"))
+		assert.Equal(t, "", pt.Get("remote_device_ip"))
+		assert.Equal(t, "val1", pt.Get("tag1"))
+		assert.Equal(t, false, pt.Get("remote_resolved"))
+	})
+
+	t.Run("resolved-by-name", func(t *testing.T) {
+		pts, err := ipt.collectTopology(b.IP, b, time.Now())
+		require.NoError(t, err)
+
+		pt := link(pts)
+		assert.Equal(t, "10.0.0.2:2->sw-a.example.com:port-1", pt.Get("name"))
+		assert.Equal(t, "cdp", pt.Get("protocol"))
+		assert.Equal(t, "10.0.0.1", pt.Get("remote_device_ip"))
+		assert.Equal(t, "sw-a", pt.Get("remote_device_name"))
+		assert.Equal(t, true, pt.Get("remote_resolved"))
+	})
+
+	t.Run("resolved-by-chassis", func(t *testing.T) {
+		pts, err := ipt.collectTopology(a.IP, a, time.Now())
+		require.NoError(t, err)
+
+		pt := link(pts)
+		assert.Equal(t, "10.0.0.1:1->00:00:00:00:00:0b:Gi0/2", pt.Get("name"))
+		assert.Equal(t, "sw-b", pt.Get("remote_device_name"))
+		assert.Equal(t, true, pt.Get("remote_resolved"))
+	})
+
+	t.Run("protocol-failed", func(t *testing.T) {
+		sess := snmputil.CreateMockSession()
+		sess.On("Get", []string{sysNameOID}).Return(&gosnmp.SnmpPacket{}, nil)
+		sess.MockLocalChassisID([]byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x0a})
+		sess.On("GetWalkAll", "1.0.8802.1.1.2.1.4.1.1.5").Return([]gosnmp.SnmpPDU{}, assert.AnError)
+		sess.On("GetWalkAll", "1.3.6.1.4.1.9.9.23.1.2.1.1.6").Return([]gosnmp.SnmpPDU{}, nil)
+		origin := a.Session
+		a.Session = sess
+
+		pts, err := ipt.collectTopology(a.IP, a, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, pts, "LLDP links not reported down on LLDP walk failure")
+
+		a.Session = origin
+		pts, err = ipt.collectTopology(a.IP, a, time.Now())
+		require.NoError(t, err)
+		assert.Equal(t, "up", link(pts).Get("link_status"))
+	})
+
+	t.Run("neighbor-gone", func(t *testing.T) {
+		a.Session = getMockTopologySession("sw-a", []byte{0x00, 0x00, 0x00, 0x00, 0x00, 0x0a}, nil)
+
+		pts, err := ipt.collectTopology(a.IP, a, time.Now())
+		require.NoError(t, err)
+
+		pt := link(pts)
+		assert.Equal(t, "10.0.0.1:1->00:00:00:00:00:0b:Gi0/2", pt.Get("name"))
+		assert.Equal(t, "down", pt.Get("link_status"))
+
+		pts, err = ipt.collectTopology(a.IP, a, time.Now())
+		require.NoError(t, err)
+		assert.Empty(t, pts, "down link reported only once")
+	})
+
+	t.Run("walk-failed", func(t *testing.T) {
+		sess := snmputil.CreateMockSession()
+		sess.On("Get", []string{sysNameOID}).Return(&gosnmp.SnmpPacket{}, nil)
+		sess.MockLocalChassisID(nil)
+		sess.On("GetWalkAll", "1.0.8802.1.1.2.1.4.1.1.5").Return([]gosnmp.SnmpPDU{}, assert.AnError)
+		sess.On("GetWalkAll", "1.3.6.1.4.1.9.9.23.1.2.1.1.6").Return([]gosnmp.SnmpPDU{}, assert.AnError)
+
+		_, err := ipt.collectTopology("10.0.0.3", &deviceInfo{Ipt: ipt, IP: "10.0.0.3", Session: sess}, time.Now())
+		assert.Error(t, err)
+	})
+}
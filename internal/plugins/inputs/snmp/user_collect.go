@@ -371,6 +371,10 @@ func (ipt *Input) doCollectUserObject(deviceIP string, device *deviceInfo) {
 			metrics.WithLastErrorCategory(point.CustomObject),
 		)
 	}
+
+	if ipt.EnableTopology {
+		ipt.doCollectTopology(deviceIP, device)
+	}
 }
 
 func (ipt *Input) collectUserMetrics() {
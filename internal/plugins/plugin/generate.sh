@@ -0,0 +1,7 @@
+#!/usr/bin/env bash
+
+protoc --go_out=. --go_opt=paths=source_relative \
+	--go-grpc_out=. --go-grpc_opt=paths=source_relative \
+	--python_out=../inputs/pythond/pys ./plugin.proto
+
+python -m grpc_tools.protoc -I. --grpc_python_out=../inputs/pythond/pys ./plugin.proto
@@ -0,0 +1,76 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package plugin
+
+import (
+	"github.com/GuanceCloud/cliutils/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	restartVec          *prometheus.CounterVec
+	heartbeatTimeoutVec *prometheus.CounterVec
+	feedPointsVec       *prometheus.CounterVec
+)
+
+func metricsSetup() {
+	restartVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "plugin",
+			Name:      "restart_total",
+			Help:      "Plugin restart count",
+		},
+		[]string{
+			"name",
+		},
+	)
+
+	heartbeatTimeoutVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "plugin",
+			Name:      "heartbeat_timeout_total",
+			Help:      "Plugin killed count on heartbeat timeout",
+		},
+		[]string{
+			"name",
+		},
+	)
+
+	feedPointsVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "plugin",
+			Name:      "feed_points_total",
+			Help:      "Points fed by plugin",
+		},
+		[]string{
+			"name",
+			"category",
+		},
+	)
+}
+
+func allMetrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		restartVec,
+		heartbeatTimeoutVec,
+		feedPointsVec,
+	}
+}
+
+func resetMetrics() {
+	restartVec.Reset()
+	heartbeatTimeoutVec.Reset()
+	feedPointsVec.Reset()
+}
+
+//nolint:gochecknoinits
+func init() {
+	metricsSetup()
+	metrics.MustRegister(allMetrics()...)
+}
@@ -0,0 +1,58 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build !windows
+// +build !windows
+
+package plugin
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// gateScript hold the process until a line read from fd 3, then exec the
+// plugin within the same process(pid).
+const gateScript = `read -r _ <&3 || exit 1; exec 3<&-; exec "$0" "$@"`
+
+// startProcess start cmd, and call before with its pid ahead of any code of
+// the plugin running if before not nil.
+func startProcess(cmd *exec.Cmd, before func(pid int)) error {
+	if before == nil {
+		return cmd.Start()
+	}
+
+	path, err := exec.LookPath(cmd.Path)
+	if err != nil {
+		return err
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer w.Close() //nolint:errcheck
+
+	cmd.Args = append([]string{"/bin/sh", "-c", gateScript, path}, cmd.Args[1:]...)
+	cmd.Path = "/bin/sh"
+	cmd.ExtraFiles = []*os.File{r}
+
+	err = cmd.Start()
+	_ = r.Close()
+	if err != nil {
+		return err
+	}
+
+	before(cmd.Process.Pid)
+
+	_, err = w.Write([]byte("\n"))
+	return err
+}
+
+// terminate ask the process to exit.
+func terminate(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
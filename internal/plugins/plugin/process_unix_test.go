@@ -0,0 +1,73 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build !windows
+// +build !windows
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	T "testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+func TestStartProcess(t *T.T) {
+	t.Run("before-exec", func(t *T.T) {
+		marker := filepath.Join(t.TempDir(), "limited")
+
+		var out bytes.Buffer
+		cmd := exec.Command("sh", "-c", `test -f "$1" && echo $$`, "sh", marker)
+		cmd.Stdout = &out
+
+		pid := 0
+		require.NoError(t, startProcess(cmd, func(p int) {
+			pid = p
+			// the plugin must not run before the limit applied
+			time.Sleep(100 * time.Millisecond)
+			require.NoError(t, os.WriteFile(marker, nil, 0o600))
+		}))
+
+		require.NoError(t, cmd.Wait())
+		assert.Equal(t, strconv.Itoa(pid), string(bytes.TrimSpace(out.Bytes())))
+	})
+
+	t.Run("not-found", func(t *T.T) {
+		cmd := exec.Command("no-such-plugin-command")
+		assert.Error(t, startProcess(cmd, func(int) {}))
+	})
+}
+
+func TestStopPlugin(t *T.T) {
+	s := NewSupervisor("sleep", "sleep",
+		WithArgs("60"),
+		WithSocketDir(t.TempDir()),
+		WithFeeder(dkio.NewMockedFeeder()),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Run(ctx) }()
+
+	time.Sleep(500 * time.Millisecond)
+	cancel()
+
+	// terminated by SIGTERM rather than killed on stop timeout
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(s.stopTimeout / 2):
+		t.Fatal("plugin not terminated")
+	}
+}
@@ -0,0 +1,66 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+//go:build windows
+// +build windows
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"syscall"
+
+	"golang.org/x/sys/windows"
+)
+
+var procNtResumeProcess = windows.NewLazySystemDLL("ntdll.dll").NewProc("NtResumeProcess")
+
+// startProcess start cmd, and call before with its pid ahead of any code of
+// the plugin running if before not nil.
+func startProcess(cmd *exec.Cmd, before func(pid int)) error {
+	if before == nil {
+		return cmd.Start()
+	}
+
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.CreationFlags |= windows.CREATE_SUSPENDED
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	before(cmd.Process.Pid)
+
+	if err := resumeProcess(cmd.Process.Pid); err != nil {
+		_ = cmd.Process.Kill()
+		return fmt.Errorf("resume process: %w", err)
+	}
+
+	return nil
+}
+
+func resumeProcess(pid int) error {
+	h, err := windows.OpenProcess(windows.PROCESS_SUSPEND_RESUME, false, uint32(pid))
+	if err != nil {
+		return err
+	}
+	defer windows.CloseHandle(h) //nolint:errcheck
+
+	if status, _, _ := procNtResumeProcess.Call(uintptr(h)); status != 0 {
+		return fmt.Errorf("NtResumeProcess: 0x%x", status)
+	}
+
+	return nil
+}
+
+// terminate not supported on Windows, the plugin exit on next heartbeat.
+func terminate(*os.Process) error {
+	return errors.New("not supported")
+}
@@ -0,0 +1,19 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package plugin implements the supervised plugin protocol(see plugin.proto)
+// between Datakit and external programs.
+package plugin
+
+const (
+	ProtocolVersion = 1
+
+	EnvSocket   = "DATAKIT_PLUGIN_SOCKET"
+	EnvName     = "DATAKIT_PLUGIN_NAME"
+	EnvToken    = "DATAKIT_PLUGIN_TOKEN"
+	EnvProtocol = "DATAKIT_PLUGIN_PROTOCOL"
+
+	TokenMetadata = "x-datakit-plugin-token"
+)
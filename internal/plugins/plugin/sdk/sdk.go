@@ -0,0 +1,279 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package sdk is the Go SDK for Datakit supervised plugins.
+//
+// A plugin started by Datakit connect back with Dial, read its config via
+// Config, send points via Feed, and exit once Done closed:
+//
+//	cli, err := sdk.Dial(context.Background())
+//	if err != nil { ... }
+//	defer cli.Close()
+//
+//	go cli.KeepAlive(ctx)
+//	for {
+//		select {
+//		case <-cli.Done():
+//			return
+//		case <-tick.C:
+//			cli.Feed(ctx, point.Metric, pts)
+//		}
+//	}
+package sdk
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	SDKName = "go/1"
+
+	defaultRetry     = 3
+	defaultRetryWait = time.Second
+	defaultTimeout   = 10 * time.Second
+
+	// exit if Datakit not reachable for heartbeats.
+	maxHeartbeatFailures = 3
+)
+
+type Client struct {
+	name  string
+	token string
+
+	conn *grpc.ClientConn
+	host plugin.HostClient
+
+	config            string
+	heartbeatInterval time.Duration
+	retry             int
+	retryWait         time.Duration
+
+	mtx       sync.Mutex
+	lastErr   error
+	done      chan struct{}
+	closeOnce sync.Once
+}
+
+type Option func(c *Client)
+
+// WithRetry set retry count and the wait between retries of Feed.
+func WithRetry(n int, wait time.Duration) Option {
+	return func(c *Client) {
+		c.retry, c.retryWait = n, wait
+	}
+}
+
+// Dial connect to Datakit with environments set by Datakit and handshake.
+func Dial(ctx context.Context, opts ...Option) (*Client, error) {
+	socket := os.Getenv(plugin.EnvSocket)
+	if socket == "" {
+		return nil, fmt.Errorf("%s not set, the plugin should be started by Datakit", plugin.EnvSocket)
+	}
+
+	c := &Client{
+		name:      os.Getenv(plugin.EnvName),
+		token:     os.Getenv(plugin.EnvToken),
+		retry:     defaultRetry,
+		retryWait: defaultRetryWait,
+		done:      make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(c)
+		}
+	}
+
+	conn, err := grpc.DialContext(ctx, socket,
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	c.conn = conn
+	c.host = plugin.NewHostClient(conn)
+
+	resp, err := c.host.Handshake(c.withToken(ctx), &plugin.HandshakeRequest{
+		ProtocolVersion: plugin.ProtocolVersion,
+		Name:            c.name,
+		Pid:             int64(os.Getpid()),
+		Sdk:             SDKName,
+	})
+	if err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("handshake: %w", err)
+	}
+
+	c.config = resp.Config
+	c.heartbeatInterval = time.Duration(resp.HeartbeatIntervalMs) * time.Millisecond
+	if c.heartbeatInterval <= 0 {
+		c.heartbeatInterval = 10 * time.Second
+	}
+
+	go c.watchSignal()
+
+	return c, nil
+}
+
+// watchSignal close Done on SIGTERM, which sent by Datakit on stop.
+func (c *Client) watchSignal() {
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, syscall.SIGTERM)
+	defer signal.Stop(sig)
+
+	select {
+	case <-sig:
+		c.closeOnce.Do(func() { close(c.done) })
+	case <-c.done:
+	}
+}
+
+func (c *Client) withToken(ctx context.Context) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, plugin.TokenMetadata, c.token)
+}
+
+// Name returns the plugin name within Datakit.
+func (c *Client) Name() string { return c.name }
+
+// Config returns the plugin config set within the input conf.
+func (c *Client) Config() string { return c.config }
+
+// Done closed once Datakit ask the plugin to exit(SIGTERM or heartbeat response).
+func (c *Client) Done() <-chan struct{} { return c.done }
+
+// SetError set the error reported to Datakit on next heartbeat, nil to clear it.
+func (c *Client) SetError(err error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.lastErr = err
+}
+
+// Feed send points to Datakit, retry if Datakit temporary unavailable.
+func (c *Client) Feed(ctx context.Context, cat point.Category, pts []*point.Point) error {
+	enc := point.GetEncoder(point.WithEncEncoding(point.Protobuf))
+	defer point.PutEncoder(enc)
+
+	bufs, err := enc.Encode(pts)
+	if err != nil {
+		return err
+	}
+
+	for _, buf := range bufs {
+		req := &plugin.FeedRequest{
+			Category: cat.String(),
+			Encoding: plugin.Encoding_PROTOBUF,
+			Points:   buf,
+		}
+
+		if err := c.feed(ctx, req); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Client) feed(ctx context.Context, req *plugin.FeedRequest) error {
+	var err error
+	for i := 0; i <= c.retry; i++ {
+		if i > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(c.retryWait * time.Duration(i)):
+			}
+		}
+
+		tctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+		_, err = c.host.Feed(c.withToken(tctx), req)
+		cancel()
+
+		if err == nil {
+			return nil
+		}
+
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded:
+			continue
+		default:
+			return err
+		}
+	}
+
+	return err
+}
+
+// KeepAlive send heartbeat to Datakit periodically until ctx done, Datakit
+// ask the plugin to exit or Datakit not reachable.
+func (c *Client) KeepAlive(ctx context.Context) {
+	tick := time.NewTicker(c.heartbeatInterval)
+	defer tick.Stop()
+
+	failures := 0
+	for {
+		shutdown, err := c.heartbeat(ctx)
+		if err != nil {
+			failures++
+		} else {
+			failures = 0
+		}
+
+		if shutdown || failures >= maxHeartbeatFailures {
+			c.closeOnce.Do(func() { close(c.done) })
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-tick.C:
+		}
+	}
+}
+
+// heartbeat returns true if Datakit ask to shutdown.
+func (c *Client) heartbeat(ctx context.Context) (bool, error) {
+	req := &plugin.HeartbeatRequest{Status: "ok"}
+
+	c.mtx.Lock()
+	if c.lastErr != nil {
+		req.Status, req.Message = "error", c.lastErr.Error()
+	}
+	c.mtx.Unlock()
+
+	tctx, cancel := context.WithTimeout(ctx, defaultTimeout)
+	defer cancel()
+
+	resp, err := c.host.Heartbeat(c.withToken(tctx), req)
+	if err != nil {
+		return false, err
+	}
+
+	return resp.Shutdown, nil
+}
+
+func (c *Client) Close() error {
+	c.closeOnce.Do(func() { close(c.done) })
+	return c.conn.Close()
+}
@@ -0,0 +1,190 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package sdk
+
+import (
+	"context"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/plugin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const envHelperMode = "SDK_TEST_HELPER_MODE"
+
+// TestHelperPlugin is not a real test, it's the plugin process started by
+// the supervisor within other tests.
+func TestHelperPlugin(t *T.T) {
+	mode := os.Getenv(envHelperMode)
+	if mode == "" {
+		t.Skip("helper process only")
+	}
+
+	ctx := context.Background()
+	cli, err := Dial(ctx)
+	if err != nil {
+		os.Exit(2)
+	}
+	defer cli.Close() //nolint:errcheck
+
+	feed := func(stage string) {
+		pt := point.NewPointV2("plugin_test",
+			point.NewKVs(map[string]any{"pid": int64(os.Getpid())}).
+				AddTag("stage", stage).
+				AddTag("config", cli.Config()),
+			point.DefaultMetricOptions()...)
+		if err := cli.Feed(ctx, point.Metric, []*point.Point{pt}); err != nil {
+			os.Exit(3)
+		}
+	}
+
+	feed("start")
+
+	switch mode {
+	case "crash":
+		os.Exit(1)
+	case "hang": // no heartbeat
+		time.Sleep(time.Minute)
+	default:
+		go cli.KeepAlive(ctx)
+		<-cli.Done()
+		feed("stop")
+		os.Exit(0)
+	}
+}
+
+func newSupervisor(t *T.T, mode string, opts ...plugin.Option) (*plugin.Supervisor, *dkio.MockedFeeder, string) {
+	t.Helper()
+
+	dir, err := os.MkdirTemp("", "plugin") // t.TempDir() too long for Unix socket on some OS
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	feeder := dkio.NewMockedFeeder()
+	opts = append([]plugin.Option{
+		plugin.WithArgs("-test.run=^TestHelperPlugin$"),
+		plugin.WithEnvs(envHelperMode + "=" + mode),
+		plugin.WithSocketDir(dir),
+		plugin.WithConfig("foo = 'bar'"),
+		plugin.WithTags(map[string]string{"from": "plugin", "stage": "ignored"}),
+		plugin.WithFeeder(feeder),
+		plugin.WithRestartBackoff(10*time.Millisecond, 100*time.Millisecond),
+	}, opts...)
+
+	return plugin.NewSupervisor("test-"+mode, os.Args[0], opts...), feeder, filepath.Join(dir, "test-"+mode+".sock")
+}
+
+func TestSupervisor(t *T.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix socket not available")
+	}
+
+	t.Run("feed-and-shutdown", func(t *T.T) {
+		s, feeder, _ := newSupervisor(t, "normal")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		exited := make(chan error)
+		go func() { exited <- s.Run(ctx) }()
+
+		pts, err := feeder.NPoints(1, 10*time.Second)
+		require.NoError(t, err)
+
+		pt := pts[0]
+		assert.Equal(t, "plugin_test", pt.Name())
+		assert.Equal(t, "foo = 'bar'", pt.GetTag("config"))
+		assert.Equal(t, "plugin", pt.GetTag("from"))
+		assert.Equal(t, "start", pt.GetTag("stage")) // not overwritten by supervisor tags
+		feeder.Clear()
+
+		cancel()
+		select {
+		case err := <-exited:
+			assert.NoError(t, err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("supervisor not exited")
+		}
+
+		// plugin got shutdown from heartbeat and feed before exit
+		pts, err = feeder.NPoints(1, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "stop", pts[0].GetTag("stage"))
+	})
+
+	t.Run("restart-on-crash", func(t *T.T) {
+		s, feeder, _ := newSupervisor(t, "crash")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = s.Run(ctx) }()
+
+		pts, err := feeder.NPoints(3, 20*time.Second)
+		require.NoError(t, err)
+
+		pids := map[int64]bool{}
+		for _, pt := range pts {
+			pids[pt.Get("pid").(int64)] = true
+		}
+		assert.Len(t, pids, 3)
+	})
+
+	t.Run("restart-on-heartbeat-timeout", func(t *T.T) {
+		// the helper process takes about 1s to start
+		s, feeder, _ := newSupervisor(t, "hang", plugin.WithHeartbeatTimeout(3*time.Second))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = s.Run(ctx) }()
+
+		pts, err := feeder.NPoints(2, 30*time.Second)
+		require.NoError(t, err)
+		assert.NotEqual(t, pts[0].Get("pid"), pts[1].Get("pid"))
+	})
+
+	t.Run("invalid-token", func(t *T.T) {
+		s, _, socket := newSupervisor(t, "normal")
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		go func() { _ = s.Run(ctx) }()
+
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(socket)
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		conn, err := grpc.Dial(socket,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", addr)
+			}),
+		)
+		require.NoError(t, err)
+		defer conn.Close() //nolint:errcheck
+
+		_, err = plugin.NewHostClient(conn).Handshake(context.Background(), &plugin.HandshakeRequest{
+			ProtocolVersion: plugin.ProtocolVersion,
+		})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+}
+
+func TestDialWithoutDatakit(t *T.T) {
+	t.Setenv(plugin.EnvSocket, "")
+	_, err := Dial(context.Background())
+	assert.Error(t, err)
+}
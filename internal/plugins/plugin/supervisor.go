@@ -0,0 +1,453 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package plugin
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/logger"
+	"github.com/GuanceCloud/cliutils/point"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultHeartbeatTimeout = 30 * time.Second
+	defaultRestartMin       = time.Second
+	defaultRestartMax       = time.Minute
+	defaultStopTimeout      = 5 * time.Second
+)
+
+var l = logger.DefaultSLogger("plugin")
+
+// ResourceLimit limit CPU and memory of the plugin process.
+type ResourceLimit struct {
+	CPUMax float64 `toml:"cpu_max"`    // CPU usage percent(max is 100)
+	MemMax int64   `toml:"mem_max_mb"` // memory in MB
+}
+
+// Supervisor start the plugin process, serve its requests and restart it on
+// exit or heartbeat timeout.
+type Supervisor struct {
+	name   string
+	cmd    string
+	args   []string
+	envs   []string
+	config string
+	tags   map[string]string
+
+	socketDir        string
+	heartbeatTimeout time.Duration
+	restartMin       time.Duration
+	restartMax       time.Duration
+	stopTimeout      time.Duration
+	limit            *ResourceLimit
+	feeder           dkio.Feeder
+	election         bool
+
+	token    string
+	socket   string
+	shutdown chan struct{}
+
+	mtx           sync.Mutex
+	lastHeartbeat time.Time
+}
+
+type Option func(s *Supervisor)
+
+// WithArgs set the command arguments of the plugin.
+func WithArgs(args ...string) Option { return func(s *Supervisor) { s.args = args } }
+
+// WithEnvs set extra environments(in format key=value) of the plugin.
+func WithEnvs(envs ...string) Option { return func(s *Supervisor) { s.envs = envs } }
+
+// WithConfig set config passed to plugin on handshake.
+func WithConfig(cfg string) Option { return func(s *Supervisor) { s.config = cfg } }
+
+// WithTags set extra tags added to all points of the plugin.
+func WithTags(tags map[string]string) Option { return func(s *Supervisor) { s.tags = tags } }
+
+// WithSocketDir set the directory of the Unix socket, default is Datakit's data dir.
+func WithSocketDir(dir string) Option { return func(s *Supervisor) { s.socketDir = dir } }
+
+// WithHeartbeatTimeout set the timeout to restart the plugin if no heartbeat received.
+func WithHeartbeatTimeout(du time.Duration) Option {
+	return func(s *Supervisor) {
+		if du > 0 {
+			s.heartbeatTimeout = du
+		}
+	}
+}
+
+// WithRestartBackoff set the min and max delay between restarts.
+func WithRestartBackoff(min, max time.Duration) Option {
+	return func(s *Supervisor) {
+		if min > 0 && max >= min {
+			s.restartMin, s.restartMax = min, max
+		}
+	}
+}
+
+// WithResourceLimit set CPU and memory limit of the plugin process.
+func WithResourceLimit(limit *ResourceLimit) Option { return func(s *Supervisor) { s.limit = limit } }
+
+// WithFeeder set the feeder of plugin points.
+func WithFeeder(f dkio.Feeder) Option { return func(s *Supervisor) { s.feeder = f } }
+
+// WithElection set whether the points are election points.
+func WithElection(on bool) Option { return func(s *Supervisor) { s.election = on } }
+
+func NewSupervisor(name, cmd string, opts ...Option) *Supervisor {
+	s := &Supervisor{
+		name:             name,
+		cmd:              cmd,
+		socketDir:        filepath.Join(datakit.DataDir, "plugins"),
+		heartbeatTimeout: defaultHeartbeatTimeout,
+		restartMin:       defaultRestartMin,
+		restartMax:       defaultRestartMax,
+		stopTimeout:      defaultStopTimeout,
+		feeder:           dkio.DefaultFeeder(),
+	}
+
+	for _, opt := range opts {
+		if opt != nil {
+			opt(s)
+		}
+	}
+
+	return s
+}
+
+func newToken() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func (s *Supervisor) listen() (net.Listener, error) {
+	if err := os.MkdirAll(s.socketDir, 0o700); err != nil {
+		return nil, err
+	}
+
+	s.socket = filepath.Join(s.socketDir, s.name+".sock")
+	if err := os.Remove(s.socket); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	ln, err := net.Listen("unix", s.socket)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := os.Chmod(s.socket, 0o600); err != nil {
+		l.Warnf("chmod %s: %s, ignored", s.socket, err)
+	}
+
+	return ln, nil
+}
+
+// Run start and supervise the plugin until ctx done.
+func (s *Supervisor) Run(ctx context.Context) error {
+	l = logger.SLogger("plugin")
+
+	token, err := newToken()
+	if err != nil {
+		return err
+	}
+	s.token = token
+	s.shutdown = make(chan struct{})
+
+	ln, err := s.listen()
+	if err != nil {
+		return fmt.Errorf("listen plugin socket: %w", err)
+	}
+
+	srv := grpc.NewServer(grpc.UnaryInterceptor(s.authenticate))
+	RegisterHostServer(srv, &hostServer{s: s})
+
+	go func() {
+		if err := srv.Serve(ln); err != nil {
+			l.Warnf("plugin %s: serve: %s", s.name, err)
+		}
+	}()
+
+	defer func() {
+		srv.Stop()
+		_ = os.Remove(s.socket)
+	}()
+
+	delay := s.restartMin
+	for {
+		started := time.Now()
+
+		if err := s.runOnce(ctx); err != nil {
+			l.Warnf("plugin %s: %s", s.name, err)
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// reset the backoff if the plugin has been running for a while
+		if time.Since(started) >= s.restartMax {
+			delay = s.restartMin
+		}
+
+		restartVec.WithLabelValues(s.name).Inc()
+		l.Infof("plugin %s: restart after %s", s.name, delay)
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+
+		if delay *= 2; delay > s.restartMax {
+			delay = s.restartMax
+		}
+	}
+}
+
+func (s *Supervisor) command() *exec.Cmd {
+	cmd := exec.Command(s.cmd, s.args...) //nolint:gosec
+	cmd.Env = append(os.Environ(), s.envs...)
+	cmd.Env = append(cmd.Env,
+		EnvSocket+"="+s.socket,
+		EnvName+"="+s.name,
+		EnvToken+"="+s.token,
+		EnvProtocol+"="+strconv.Itoa(ProtocolVersion),
+	)
+	cmd.Stdout = &logWriter{name: s.name}
+	cmd.Stderr = cmd.Stdout
+	return cmd
+}
+
+// runOnce start the plugin process and wait until it exit, heartbeat timeout
+// or ctx done.
+func (s *Supervisor) runOnce(ctx context.Context) error {
+	cmd := s.command()
+
+	l.Infof("plugin %s: starting %s", s.name, cmd.String())
+
+	limited := false
+	var before func(pid int)
+	if s.limit != nil && (s.limit.CPUMax > 0 || s.limit.MemMax > 0) {
+		before = func(pid int) {
+			if err := resourcelimit.LimitProcess(s.limitName(), pid, s.limit.CPUMax, s.limit.MemMax); err != nil {
+				l.Warnf("plugin %s: limit resource: %s, ignored", s.name, err)
+			} else {
+				limited = true
+			}
+		}
+	}
+
+	if err := startProcess(cmd, before); err != nil {
+		if cmd.Process != nil {
+			_ = cmd.Wait()
+		}
+		return fmt.Errorf("start %s: %w", s.cmd, err)
+	}
+
+	if limited {
+		defer func() {
+			if err := resourcelimit.RemoveProcessLimit(s.limitName()); err != nil {
+				l.Warnf("plugin %s: remove resource limit: %s, ignored", s.name, err)
+			}
+		}()
+	}
+
+	s.beat() // plugin should handshake within heartbeat timeout
+
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+
+	tick := time.NewTicker(s.heartbeatTimeout / 3)
+	defer tick.Stop()
+
+	for {
+		select {
+		case err := <-exited:
+			return fmt.Errorf("exited: %v", err)
+
+		case <-tick.C:
+			s.mtx.Lock()
+			last := s.lastHeartbeat
+			s.mtx.Unlock()
+
+			if time.Since(last) > s.heartbeatTimeout {
+				heartbeatTimeoutVec.WithLabelValues(s.name).Inc()
+				_ = cmd.Process.Kill()
+				<-exited
+				return fmt.Errorf("no heartbeat since %s, killed", last.Format(time.RFC3339))
+			}
+
+		case <-ctx.Done():
+			s.stop(cmd, exited)
+			return nil
+		}
+	}
+}
+
+func (s *Supervisor) limitName() string {
+	return "plugin-" + s.name
+}
+
+// stop ask the plugin to exit via SIGTERM and heartbeat response, and kill it
+// on timeout.
+func (s *Supervisor) stop(cmd *exec.Cmd, exited chan error) {
+	close(s.shutdown)
+
+	timeout := s.stopTimeout
+	if err := terminate(cmd.Process); err != nil {
+		// the plugin get shutdown on next heartbeat
+		timeout += s.heartbeatTimeout / 3
+	}
+
+	select {
+	case <-exited:
+		l.Infof("plugin %s: exited", s.name)
+	case <-time.After(timeout):
+		l.Warnf("plugin %s: not exited within %s, killed", s.name, timeout)
+		_ = cmd.Process.Kill()
+		<-exited
+	}
+}
+
+func (s *Supervisor) authenticate(ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	tokens := md.Get(TokenMetadata)
+	if len(tokens) == 0 || subtle.ConstantTimeCompare([]byte(tokens[0]), []byte(s.token)) != 1 {
+		return nil, status.Error(codes.Unauthenticated, "invalid plugin token")
+	}
+
+	return handler(ctx, req)
+}
+
+type hostServer struct {
+	UnimplementedHostServer
+	s *Supervisor
+}
+
+func (h *hostServer) Handshake(_ context.Context, req *HandshakeRequest) (*HandshakeResponse, error) {
+	if req.ProtocolVersion != ProtocolVersion {
+		return nil, status.Errorf(codes.FailedPrecondition,
+			"protocol version %d not supported, expect %d", req.ProtocolVersion, ProtocolVersion)
+	}
+
+	l.Infof("plugin %s: handshake from pid %d, sdk %s", h.s.name, req.Pid, req.Sdk)
+	h.s.beat()
+
+	return &HandshakeResponse{
+		ProtocolVersion:     ProtocolVersion,
+		Config:              h.s.config,
+		HeartbeatIntervalMs: (h.s.heartbeatTimeout / 3).Milliseconds(),
+	}, nil
+}
+
+func (h *hostServer) Feed(_ context.Context, req *FeedRequest) (*FeedResponse, error) {
+	cat := point.CatString(req.Category)
+	if cat == point.UnknownCategory {
+		return nil, status.Errorf(codes.InvalidArgument, "invalid category %q", req.Category)
+	}
+
+	enc := point.LineProtocol
+	if req.Encoding == Encoding_PROTOBUF {
+		enc = point.Protobuf
+	}
+
+	var opts []point.Option
+	if req.Precision != "" {
+		opts = append(opts, point.WithPrecision(point.PrecStr(req.Precision)))
+	}
+
+	dec := point.GetDecoder(point.WithDecEncoding(enc))
+	defer point.PutDecoder(dec)
+
+	pts, err := dec.Decode(req.Points, opts...)
+	if err != nil {
+		return nil, status.Errorf(codes.InvalidArgument, "decode points: %s", err)
+	}
+
+	for _, pt := range pts {
+		for k, v := range h.s.tags {
+			if pt.GetTag(k) == "" {
+				pt.AddTag(k, v)
+			}
+		}
+	}
+
+	if len(pts) > 0 {
+		if err := h.s.feeder.FeedV2(cat, pts,
+			dkio.WithInputName(h.s.name),
+			dkio.WithElection(h.s.election),
+		); err != nil {
+			return nil, status.Errorf(codes.Unavailable, "feed: %s", err)
+		}
+	}
+
+	feedPointsVec.WithLabelValues(h.s.name, cat.String()).Add(float64(len(pts)))
+	h.s.beat()
+
+	return &FeedResponse{Points: int64(len(pts))}, nil
+}
+
+func (h *hostServer) Heartbeat(_ context.Context, req *HeartbeatRequest) (*HeartbeatResponse, error) {
+	h.s.beat()
+
+	if req.Status == "error" {
+		h.s.feeder.FeedLastError(req.Message,
+			metrics.WithLastErrorInput(h.s.name),
+			metrics.WithLastErrorSource(h.s.name),
+		)
+	}
+
+	select {
+	case <-h.s.shutdown:
+		return &HeartbeatResponse{Shutdown: true}, nil
+	default:
+		return &HeartbeatResponse{}, nil
+	}
+}
+
+func (s *Supervisor) beat() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.lastHeartbeat = time.Now()
+}
+
+// logWriter log plugin's stdout/stderr.
+type logWriter struct {
+	name string
+}
+
+func (w *logWriter) Write(p []byte) (int, error) {
+	l.Infof("plugin %s: %s", w.name, p)
+	return len(p), nil
+}
@@ -0,0 +1,96 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package plugin
+
+import (
+	"context"
+	T "testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMessageEncoding(t *T.T) {
+	req := &FeedRequest{Category: "logging", Encoding: Encoding_PROTOBUF, Points: []byte("abc"), Precision: "ms"}
+
+	buf, err := proto.Marshal(req)
+	require.NoError(t, err)
+
+	// field numbers must match plugin.proto
+	assert.Equal(t, []byte{
+		0x0a, 7, 'l', 'o', 'g', 'g', 'i', 'n', 'g',
+		0x10, 1,
+		0x1a, 3, 'a', 'b', 'c',
+		0x22, 2, 'm', 's',
+	}, buf)
+
+	var got FeedRequest
+	require.NoError(t, proto.Unmarshal(buf, &got))
+	assert.Equal(t, req.Points, got.Points)
+	assert.Equal(t, req.Precision, got.Precision)
+}
+
+func TestHostServer(t *T.T) {
+	feeder := dkio.NewMockedFeeder()
+	s := NewSupervisor("demo", "demo",
+		WithConfig("interval = '10s'"),
+		WithTags(map[string]string{"tag1": "v1"}),
+		WithFeeder(feeder),
+		WithHeartbeatTimeout(9*time.Second),
+	)
+	s.shutdown = make(chan struct{})
+	h := &hostServer{s: s}
+
+	t.Run("handshake", func(t *T.T) {
+		_, err := h.Handshake(context.Background(), &HandshakeRequest{ProtocolVersion: 2})
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+
+		resp, err := h.Handshake(context.Background(), &HandshakeRequest{ProtocolVersion: ProtocolVersion})
+		require.NoError(t, err)
+		assert.Equal(t, "interval = '10s'", resp.Config)
+		assert.Equal(t, int64(3000), resp.HeartbeatIntervalMs)
+	})
+
+	t.Run("feed-line-protocol", func(t *T.T) {
+		resp, err := h.Feed(context.Background(), &FeedRequest{
+			Category:  "logging",
+			Points:    []byte("nginx,host=a message=\"hello\" 1700000000000\nnginx message=\"world\" 1700000000001"),
+			Precision: "ms",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), resp.Points)
+
+		pts, err := feeder.NPoints(2, time.Second)
+		require.NoError(t, err)
+		assert.Equal(t, "v1", pts[0].GetTag("tag1"))
+		assert.Equal(t, "a", pts[0].GetTag("host"))
+		assert.Equal(t, int64(1700000000000*1e6), pts[0].Time().UnixNano())
+	})
+
+	t.Run("feed-invalid", func(t *T.T) {
+		_, err := h.Feed(context.Background(), &FeedRequest{Category: "no-such-category"})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+		_, err = h.Feed(context.Background(), &FeedRequest{Category: "metric", Points: []byte("invalid line-protocol")})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("heartbeat", func(t *T.T) {
+		resp, err := h.Heartbeat(context.Background(), &HeartbeatRequest{Status: "error", Message: "connect db failed"})
+		require.NoError(t, err)
+		assert.False(t, resp.Shutdown)
+
+		close(s.shutdown)
+		resp, err = h.Heartbeat(context.Background(), &HeartbeatRequest{Status: "ok"})
+		require.NoError(t, err)
+		assert.True(t, resp.Shutdown)
+	})
+}
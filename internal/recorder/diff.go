@@ -0,0 +1,362 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package recorder
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/GuanceCloud/cliutils/point"
+)
+
+// defaultIgnoreKeys are volatile keys ignored on comparing points, time of
+// points is always ignored.
+var defaultIgnoreKeys = []string{"host"}
+
+type diffOption struct {
+	ignoreKeys map[string]bool
+	values     bool
+}
+
+// DiffOption configures DiffPoints.
+type DiffOption func(*diffOption)
+
+// WithIgnoreKeys ignores tags and fields of the keys, besides the default
+// volatile key host.
+func WithIgnoreKeys(keys ...string) DiffOption {
+	return func(o *diffOption) {
+		for _, k := range keys {
+			o.ignoreKeys[k] = true
+		}
+	}
+}
+
+// WithCompareValues compares values of tags and fields, and number of points
+// of each measurement. By default only keys and types of fields are compared.
+func WithCompareValues(on bool) DiffOption {
+	return func(o *diffOption) {
+		o.values = on
+	}
+}
+
+// MeasurementDiff is the difference of one measurement between points a and b,
+// each line is prefixed with '-'(only in a), '+'(only in b) or '~'(changed).
+type MeasurementDiff struct {
+	Measurement string
+	Diffs       []string
+}
+
+// PointsDiff is the difference between points a and b.
+type PointsDiff []*MeasurementDiff
+
+func (d PointsDiff) String() string {
+	var sb strings.Builder
+	for _, m := range d {
+		sb.WriteString(fmt.Sprintf("measurement %s:\n", m.Measurement))
+		for _, x := range m.Diffs {
+			sb.WriteString("  " + x + "\n")
+		}
+	}
+	return sb.String()
+}
+
+// measurement is the schema of points with the same name.
+type measurement struct {
+	pts    []*point.Point
+	tags   map[string]bool
+	fields map[string]map[string]bool // key -> types
+}
+
+func groupPoints(pts []*point.Point, opt *diffOption) map[string]*measurement {
+	res := map[string]*measurement{}
+
+	for _, pt := range pts {
+		m, ok := res[pt.Name()]
+		if !ok {
+			m = &measurement{tags: map[string]bool{}, fields: map[string]map[string]bool{}}
+			res[pt.Name()] = m
+		}
+
+		m.pts = append(m.pts, pt)
+		for _, kv := range pt.KVs() {
+			if opt.ignoreKeys[kv.Key] {
+				continue
+			}
+
+			if kv.IsTag {
+				m.tags[kv.Key] = true
+				continue
+			}
+
+			if m.fields[kv.Key] == nil {
+				m.fields[kv.Key] = map[string]bool{}
+			}
+			m.fields[kv.Key][typeName(kv.Raw())] = true
+		}
+	}
+
+	return res
+}
+
+// DiffPoints compares points a and b by measurement. Time of points and
+// volatile keys are ignored, see WithIgnoreKeys.
+func DiffPoints(a, b []*point.Point, opts ...DiffOption) PointsDiff {
+	opt := &diffOption{ignoreKeys: map[string]bool{}}
+	WithIgnoreKeys(defaultIgnoreKeys...)(opt)
+	for _, f := range opts {
+		f(opt)
+	}
+
+	ma, mb := groupPoints(a, opt), groupPoints(b, opt)
+
+	names := map[string]bool{}
+	for k := range ma {
+		names[k] = true
+	}
+	for k := range mb {
+		names[k] = true
+	}
+
+	var res PointsDiff
+	for _, name := range sortedKeys(names) {
+		x, y := ma[name], mb[name]
+
+		var diffs []string
+		switch {
+		case y == nil:
+			diffs = []string{fmt.Sprintf("- only in a(%d points)", len(x.pts))}
+		case x == nil:
+			diffs = []string{fmt.Sprintf("+ only in b(%d points)", len(y.pts))}
+		default:
+			diffs = diffMeasurement(x, y, opt)
+		}
+
+		if len(diffs) > 0 {
+			res = append(res, &MeasurementDiff{Measurement: name, Diffs: diffs})
+		}
+	}
+
+	return res
+}
+
+func diffMeasurement(a, b *measurement, opt *diffOption) []string {
+	var diffs []string
+
+	tags := map[string]bool{}
+	for k := range a.tags {
+		tags[k] = true
+	}
+	for k := range b.tags {
+		tags[k] = true
+	}
+
+	for _, k := range sortedKeys(tags) {
+		switch {
+		case !b.tags[k]:
+			diffs = append(diffs, fmt.Sprintf("- tag %s", k))
+		case !a.tags[k]:
+			diffs = append(diffs, fmt.Sprintf("+ tag %s", k))
+		}
+	}
+
+	fields := map[string]bool{}
+	for k := range a.fields {
+		fields[k] = true
+	}
+	for k := range b.fields {
+		fields[k] = true
+	}
+
+	for _, k := range sortedKeys(fields) {
+		ta, tb := strings.Join(sortedKeys(a.fields[k]), "|"), strings.Join(sortedKeys(b.fields[k]), "|")
+		switch {
+		case tb == "":
+			diffs = append(diffs, fmt.Sprintf("- field %s(%s)", k, ta))
+		case ta == "":
+			diffs = append(diffs, fmt.Sprintf("+ field %s(%s)", k, tb))
+		case ta != tb:
+			diffs = append(diffs, fmt.Sprintf("~ field %s: %s -> %s", k, ta, tb))
+		}
+	}
+
+	if opt.values {
+		diffs = append(diffs, diffValues(a.pts, b.pts, opt)...)
+	}
+
+	return diffs
+}
+
+// diffValues compares points one by one, points are sorted by tags first.
+func diffValues(a, b []*point.Point, opt *diffOption) []string {
+	var diffs []string
+
+	if len(a) != len(b) {
+		diffs = append(diffs, fmt.Sprintf("~ points: %d -> %d", len(a), len(b)))
+	}
+
+	a, b = sortPoints(a, opt), sortPoints(b, opt)
+	for i := 0; i < len(a) && i < len(b); i++ {
+		va, vb := pointValues(a[i], opt), pointValues(b[i], opt)
+		for _, k := range sortedKeys(va) {
+			if y, ok := vb[k]; ok && !reflect.DeepEqual(va[k], y) {
+				diffs = append(diffs, fmt.Sprintf("~ point[%d] %s: %v -> %v", i, k, va[k], y))
+			}
+		}
+	}
+
+	return diffs
+}
+
+func pointValues(pt *point.Point, opt *diffOption) map[string]any {
+	res := map[string]any{}
+	for _, kv := range pt.KVs() {
+		if opt.ignoreKeys[kv.Key] {
+			continue
+		}
+
+		if kv.IsTag {
+			res["tag "+kv.Key] = kv.GetS()
+		} else {
+			res["field "+kv.Key] = kv.Raw()
+		}
+	}
+	return res
+}
+
+func sortPoints(pts []*point.Point, opt *diffOption) []*point.Point {
+	keys := make([]string, len(pts))
+	for i, pt := range pts {
+		var arr []string
+		for _, kv := range pt.Tags() {
+			if !opt.ignoreKeys[kv.Key] {
+				arr = append(arr, kv.Key+"="+kv.GetS())
+			}
+		}
+		sort.Strings(arr)
+		keys[i] = strings.Join(arr, ",")
+	}
+
+	idx := make([]int, len(pts))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(i, j int) bool { return keys[idx[i]] < keys[idx[j]] })
+
+	res := make([]*point.Point, len(pts))
+	for i, x := range idx {
+		res[i] = pts[x]
+	}
+	return res
+}
+
+func typeName(v any) string {
+	switch v.(type) {
+	case int64:
+		return "int"
+	case uint64:
+		return "uint"
+	case float64:
+		return "float"
+	case bool:
+		return "bool"
+	case string:
+		return "string"
+	case []byte:
+		return "bytes"
+	default:
+		return fmt.Sprintf("%T", v)
+	}
+}
+
+func sortedKeys[T any](m map[string]T) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// LoadPoints loads recorded points from file or directory path. Recorded
+// files(.pbjson and .lp) within the directory are loaded recursively.
+func LoadPoints(path string) ([]*point.Point, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.IsDir() {
+		return loadPointsFile(path)
+	}
+
+	var pts []*point.Point
+	err = filepath.Walk(path, func(fp string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		switch filepath.Ext(fp) {
+		case ExtPBJson, ExtLineProtocol:
+			arr, err := loadPointsFile(fp)
+			if err != nil {
+				return err
+			}
+			pts = append(pts, arr...)
+		}
+		return nil
+	})
+
+	return pts, err
+}
+
+func loadPointsFile(path string) ([]*point.Point, error) {
+	data, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+
+	var pts []*point.Point
+	switch filepath.Ext(path) {
+	case ExtPBJson:
+		pts, err = PBJson2pts(data)
+
+	case ExtLineProtocol:
+		dec := point.GetDecoder(point.WithDecEncoding(point.LineProtocol))
+		defer point.PutDecoder(dec)
+		pts, err = dec.Decode(bytes.TrimSpace(data))
+
+	default:
+		return nil, fmt.Errorf("unknown recorded file %q", path)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+	return pts, nil
+}
+
+// WritePoints writes points to file path in pb-json, the same as recorded.
+func WritePoints(path string, pts []*point.Point) error {
+	data, err := pts2pbjson(pts)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, data, 0o600)
+}
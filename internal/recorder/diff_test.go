@@ -0,0 +1,122 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package recorder
+
+import (
+	"os"
+	"path/filepath"
+	T "testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newPoint(name string, tags map[string]string, fields map[string]any) *point.Point {
+	return point.NewPointV2(name, append(point.NewTags(tags), point.NewKVs(fields)...),
+		point.WithTime(time.Now()))
+}
+
+func TestDiffPoints(t *T.T) {
+	a := []*point.Point{
+		newPoint("cpu", map[string]string{"host": "h1", "cpu": "cpu0"}, map[string]any{"usage": 1.0, "cores": 4}),
+		newPoint("cpu", map[string]string{"host": "h1", "cpu": "cpu1"}, map[string]any{"usage": 2.0, "cores": 4}),
+		newPoint("mem", map[string]string{"host": "h1"}, map[string]any{"used": 100}),
+	}
+
+	t.Run("same", func(t *T.T) {
+		// time and host ignored, and order of points not matter
+		b := []*point.Point{
+			newPoint("mem", map[string]string{"host": "h2"}, map[string]any{"used": 200}),
+			newPoint("cpu", map[string]string{"host": "h2", "cpu": "cpu1"}, map[string]any{"usage": 2.0, "cores": 4}),
+			newPoint("cpu", map[string]string{"host": "h2", "cpu": "cpu0"}, map[string]any{"usage": 1.0, "cores": 4}),
+		}
+
+		assert.Empty(t, DiffPoints(a, b))
+
+		diff := DiffPoints(a, b, WithCompareValues(true))
+		require.Len(t, diff, 1)
+		assert.Equal(t, "mem", diff[0].Measurement)
+		assert.Equal(t, []string{"~ point[0] field used: 100 -> 200"}, diff[0].Diffs)
+
+		assert.Empty(t, DiffPoints(a, b, WithCompareValues(true), WithIgnoreKeys("used")))
+	})
+
+	t.Run("schema", func(t *T.T) {
+		b := []*point.Point{
+			newPoint("cpu", map[string]string{"host": "h1", "core": "cpu0"}, map[string]any{"usage": 1, "idle": 1.0}),
+			newPoint("disk", map[string]string{"host": "h1"}, map[string]any{"free": 100}),
+		}
+
+		diff := DiffPoints(a, b)
+		require.Len(t, diff, 3)
+
+		assert.Equal(t, "cpu", diff[0].Measurement)
+		assert.Equal(t, []string{
+			"+ tag core",
+			"- tag cpu",
+			"- field cores(int)",
+			"+ field idle(float)",
+			"~ field usage: float -> int",
+		}, diff[0].Diffs)
+
+		assert.Equal(t, []string{"+ only in b(1 points)"}, diff[1].Diffs)
+		assert.Equal(t, []string{"- only in a(1 points)"}, diff[2].Diffs)
+
+		assert.Equal(t, `measurement cpu:
+  + tag core
+  - tag cpu
+  - field cores(int)
+  + field idle(float)
+  ~ field usage: float -> int
+measurement disk:
+  + only in b(1 points)
+measurement mem:
+  - only in a(1 points)
+`, diff.String())
+	})
+
+	t.Run("count", func(t *T.T) {
+		diff := DiffPoints(a, a[:2], WithCompareValues(true), WithIgnoreKeys("used"))
+		require.Len(t, diff, 1)
+		assert.Equal(t, "mem", diff[0].Measurement)
+
+		diff = DiffPoints(a, a[1:], WithCompareValues(true))
+		require.Len(t, diff, 1)
+		assert.Equal(t, []string{
+			"~ points: 2 -> 1",
+			"~ point[0] field usage: 1 -> 2",
+			"~ point[0] tag cpu: cpu0 -> cpu1",
+		}, diff[0].Diffs)
+	})
+}
+
+func TestLoadPoints(t *T.T) {
+	dir := t.TempDir()
+
+	pts := point.NewRander().Rand(10)
+	require.NoError(t, WritePoints(filepath.Join(dir, "metric", "cpu.123.pbjson"), pts))
+
+	lp := "mem,host=h1 used=100i 1700000000000000000\n"
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "mem.lp"), []byte(lp), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "README.md"), []byte("ignored"), 0o600))
+
+	loaded, err := LoadPoints(filepath.Join(dir, "metric", "cpu.123.pbjson"))
+	require.NoError(t, err)
+	require.Len(t, loaded, 10)
+	assert.Empty(t, DiffPoints(pts, loaded, WithCompareValues(true)))
+
+	loaded, err = LoadPoints(dir)
+	require.NoError(t, err)
+	assert.Len(t, loaded, 11)
+
+	_, err = LoadPoints(filepath.Join(dir, "README.md"))
+	assert.Error(t, err)
+
+	_, err = LoadPoints(filepath.Join(dir, "none"))
+	assert.Error(t, err)
+}
@@ -12,6 +12,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io/dataway"
@@ -34,17 +35,20 @@ var log = logger.DefaultSLogger("io_remote_job")
 
 // RemoteJob  UnMarshal from dw.
 type RemoteJob struct {
-	JvmDumpJob *JVM `json:"jvm_dump_job"`
-	// other jobs ...
-
-	PullInterval int64 `json:"pull_interval"`
+	JvmDumpJob        *JVM            `json:"jvm_dump_job"`
+	GoProfileJob      *GoProfile      `json:"go_profile_job"`
+	DatakitProfileJob *DatakitProfile `json:"datakit_profile_job"`
+	LogSnapshotJob    *LogSnapshot    `json:"log_snapshot_job"`
+	PyStackJob        *PyStack        `json:"py_stack_job"`
+	PullInterval      int64           `json:"pull_interval"`
 }
 
 type jobReport struct {
-	UUID    string `json:"uuid"`    // UUID
-	IsOK    bool   `json:"isOK"`    // 是否成功
-	Reason  string `json:"reason"`  // 任务执行失败原因
-	Details string `json:"details"` // 命令行输出
+	UUID        string        `json:"uuid"`                  // UUID
+	IsOK        bool          `json:"isOK"`                  // 是否成功
+	Reason      string        `json:"reason"`                // 任务执行失败原因
+	Details     string        `json:"details"`               // 命令行输出
+	Attachments []*attachment `json:"attachments,omitempty"` // 采集到的文件，如 pprof
 }
 
 type Manager struct {
@@ -54,6 +58,13 @@ type Manager struct {
 	Internal time.Duration
 	PullFunc func(args string) ([]byte, error)
 	JavaHome string
+
+	// MaxReportSize limit the size of job report(details and attachments).
+	MaxReportSize int64
+	// LogSnapshotPaths is glob patterns of log files allowed to snapshot.
+	LogSnapshotPaths []string
+	// PySpyPath is path of py-spy binary, searched in $PATH if not set.
+	PySpyPath string
 }
 
 func (m *Manager) writeToFile() {
@@ -79,7 +90,8 @@ func (m *Manager) Start() {
 		select {
 		case <-ticker.C:
 			log.Debugf("-----------job-----start")
-			args := fmt.Sprintf("%s=true&host=%s", jvmMemorySnapshot, datakit.DatakitHostName)
+			args := fmt.Sprintf("%s=true&host=%s&%s=%s",
+				jvmMemorySnapshot, datakit.DatakitHostName, jobTypesArg, strings.Join(jobTypes, ","))
 			body, err := m.PullFunc(args)
 			if err != nil {
 				log.Warnf("request remote err=%v", err)
@@ -95,19 +107,47 @@ func (m *Manager) Start() {
 				log.Errorf("json unmarshal err=%v", err)
 				continue
 			}
-			if dumpJob.JvmDumpJob != nil {
-				dumpJob.JvmDumpJob.javaHome = m.JavaHome
-				report := dumpJob.JvmDumpJob.doCmd(m.Envs)
-				m.returnToDW(report)
-			}
+			m.runJobs(dumpJob)
 		case <-datakit.Exit.Wait():
 			return
 		}
 	}
 }
 
+func (m *Manager) runJobs(job *RemoteJob) {
+	if job.JvmDumpJob != nil {
+		job.JvmDumpJob.javaHome = m.JavaHome
+		m.returnToDW(observe(jobJVMDump, func() *jobReport {
+			return job.JvmDumpJob.doCmd(m.Envs)
+		}))
+	}
+
+	if job.GoProfileJob != nil {
+		m.returnToDW(observe(jobGoProfile, job.GoProfileJob.run))
+	}
+
+	if job.DatakitProfileJob != nil {
+		m.returnToDW(observe(jobDatakitProfile, job.DatakitProfileJob.run))
+	}
+
+	if job.LogSnapshotJob != nil {
+		m.returnToDW(observe(jobLogSnapshot, func() *jobReport {
+			return job.LogSnapshotJob.run(m.LogSnapshotPaths, m.maxReportSize())
+		}))
+	}
+
+	if job.PyStackJob != nil {
+		m.returnToDW(observe(jobPyStack, func() *jobReport {
+			return job.PyStackJob.run(m.PySpyPath)
+		}))
+	}
+}
+
 func (m *Manager) returnToDW(jr *jobReport) {
-	log.Infof("return job report is :%+v", jr)
+	limitReport(jr, m.maxReportSize())
+
+	log.Infof("return job report: uuid=%s, ok=%v, reason=%q, details=%d bytes, attachments=%d",
+		jr.UUID, jr.IsOK, jr.Reason, len(jr.Details), len(jr.Attachments))
 	bts, _ := json.Marshal(jr)
 	resp, err := m.DWURL.RemoteJob(bts)
 	if err != nil {
@@ -0,0 +1,142 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2024-present Guance, Inc.
+
+package remotejob
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const (
+	defaultSnapshotLines = 1000
+	maxSnapshotLines     = 100000
+	tailChunkSize        = 32 * 1024
+)
+
+// LogSnapshot snapshot the tail of a log file.
+type LogSnapshot struct {
+	UUID  string `json:"uuid"`
+	Path  string `json:"path"`
+	Lines int    `json:"lines"`
+}
+
+// allowedPath check if the path(symlinks resolved) match any of the glob patterns.
+func allowedPath(path string, patterns []string) (string, error) {
+	if !filepath.IsAbs(path) {
+		return "", fmt.Errorf("path %q should be absolute", path)
+	}
+
+	resolved, err := filepath.EvalSymlinks(filepath.Clean(path))
+	if err != nil {
+		return "", err
+	}
+
+	for _, p := range patterns {
+		if ok, err := filepath.Match(p, resolved); err == nil && ok {
+			return resolved, nil
+		}
+	}
+
+	return "", fmt.Errorf("path %q not allowed by log_snapshot_paths", path)
+}
+
+func (j *LogSnapshot) run(allowed []string, maxSize int64) *jobReport {
+	if j.UUID == "" || j.Path == "" {
+		return failedReport(j.UUID, "log snapshot check err: uuid or path is empty")
+	}
+
+	if len(allowed) == 0 {
+		return failedReport(j.UUID, "log snapshot disabled: log_snapshot_paths not configured")
+	}
+
+	path, err := allowedPath(j.Path, allowed)
+	if err != nil {
+		return failedReport(j.UUID, "log snapshot: %s", err.Error())
+	}
+
+	lines := j.Lines
+	switch {
+	case lines <= 0:
+		lines = defaultSnapshotLines
+	case lines > maxSnapshotLines:
+		lines = maxSnapshotLines
+	}
+
+	data, err := tailFile(path, lines, maxSize)
+	if err != nil {
+		return failedReport(j.UUID, "log snapshot: %s", err.Error())
+	}
+
+	log.Infof("snapshot %d bytes from %s", len(data), path)
+
+	return &jobReport{UUID: j.UUID, IsOK: true, Details: string(data)}
+}
+
+// tailFile read the last n lines of file, at most limit bytes.
+func tailFile(path string, n int, limit int64) ([]byte, error) {
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close() //nolint:errcheck,gosec
+
+	fi, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	if !fi.Mode().IsRegular() {
+		return nil, fmt.Errorf("%q is not a regular file", path)
+	}
+
+	var (
+		off = fi.Size()
+		buf []byte
+	)
+
+	for off > 0 && int64(len(buf)) < limit {
+		size := int64(tailChunkSize)
+		if size > off {
+			size = off
+		}
+		off -= size
+
+		chunk := make([]byte, size)
+		if _, err := f.ReadAt(chunk, off); err != nil {
+			return nil, err
+		}
+
+		buf = append(chunk, buf...)
+
+		// one more newline for the line before the first wanted one
+		if bytes.Count(bytes.TrimSuffix(buf, []byte("\n")), []byte("\n")) >= n {
+			break
+		}
+	}
+
+	// drop lines before the last n lines
+	end := len(bytes.TrimSuffix(buf, []byte("\n")))
+	for i := 0; i < n; i++ {
+		idx := bytes.LastIndexByte(buf[:end], '\n')
+		if idx < 0 {
+			end = -1
+			break
+		}
+		end = idx
+	}
+
+	if end >= 0 {
+		buf = buf[end+1:]
+	}
+
+	if int64(len(buf)) > limit {
+		buf = buf[int64(len(buf))-limit:]
+	}
+
+	return buf, nil
+}
@@ -0,0 +1,114 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2024-present Guance, Inc.
+
+package remotejob
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTailFile(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "app.log")
+
+	var lines []string
+	for i := 0; i < 10000; i++ {
+		lines = append(lines, fmt.Sprintf("line-%d", i))
+	}
+	require.NoError(t, os.WriteFile(f, []byte(strings.Join(lines, "\n")+"\n"), 0o600))
+
+	t.Run("last-lines", func(t *testing.T) {
+		data, err := tailFile(f, 3, 1<<20)
+		require.NoError(t, err)
+		assert.Equal(t, "line-9997\nline-9998\nline-9999\n", string(data))
+	})
+
+	t.Run("more-than-file", func(t *testing.T) {
+		data, err := tailFile(f, 20000, 1<<20)
+		require.NoError(t, err)
+		assert.Equal(t, 10000, strings.Count(string(data), "\n"))
+		assert.True(t, strings.HasPrefix(string(data), "line-0\n"))
+	})
+
+	t.Run("size-limit", func(t *testing.T) {
+		data, err := tailFile(f, 1000, 20)
+		require.NoError(t, err)
+		assert.Len(t, data, 20)
+		assert.True(t, strings.HasSuffix(string(data), "line-9999\n"))
+	})
+
+	t.Run("no-trailing-newline", func(t *testing.T) {
+		x := filepath.Join(dir, "x.log")
+		require.NoError(t, os.WriteFile(x, []byte("a\nb\nc"), 0o600))
+		data, err := tailFile(x, 2, 1<<20)
+		require.NoError(t, err)
+		assert.Equal(t, "b\nc", string(data))
+	})
+}
+
+func TestLogSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	f := filepath.Join(dir, "app.log")
+	require.NoError(t, os.WriteFile(f, []byte("a\nb\n"), 0o600))
+
+	secret := filepath.Join(t.TempDir(), "secret")
+	require.NoError(t, os.WriteFile(secret, []byte("password"), 0o600))
+	link := filepath.Join(dir, "link.log")
+	require.NoError(t, os.Symlink(secret, link))
+
+	allowed := []string{filepath.Join(dir, "*.log")}
+
+	t.Run("ok", func(t *testing.T) {
+		jr := (&LogSnapshot{UUID: "1", Path: f}).run(allowed, 1<<20)
+		assert.True(t, jr.IsOK, jr.Reason)
+		assert.Equal(t, "a\nb\n", jr.Details)
+	})
+
+	t.Run("disabled", func(t *testing.T) {
+		jr := (&LogSnapshot{UUID: "1", Path: f}).run(nil, 1<<20)
+		assert.False(t, jr.IsOK)
+		assert.Contains(t, jr.Reason, "disabled")
+	})
+
+	t.Run("not-allowed", func(t *testing.T) {
+		jr := (&LogSnapshot{UUID: "1", Path: secret}).run(allowed, 1<<20)
+		assert.False(t, jr.IsOK)
+		assert.Contains(t, jr.Reason, "not allowed")
+	})
+
+	t.Run("symlink-escape", func(t *testing.T) {
+		jr := (&LogSnapshot{UUID: "1", Path: link}).run(allowed, 1<<20)
+		assert.False(t, jr.IsOK)
+		assert.Contains(t, jr.Reason, "not allowed")
+	})
+
+	t.Run("dot-dot", func(t *testing.T) {
+		jr := (&LogSnapshot{UUID: "1", Path: filepath.Join(dir, "..", filepath.Base(dir), "..", "x.log")}).run(allowed, 1<<20)
+		assert.False(t, jr.IsOK)
+	})
+
+	t.Run("relative", func(t *testing.T) {
+		jr := (&LogSnapshot{UUID: "1", Path: "app.log"}).run(allowed, 1<<20)
+		assert.False(t, jr.IsOK)
+		assert.Contains(t, jr.Reason, "absolute")
+	})
+}
+
+func TestPyStack(t *testing.T) {
+	jr := (&PyStack{UUID: "1", ProcessID: 1}).run("/no/such/py-spy")
+	assert.False(t, jr.IsOK)
+	assert.Contains(t, jr.Reason, "py-spy not available")
+
+	jr = (&PyStack{UUID: "1"}).run("")
+	assert.False(t, jr.IsOK)
+	assert.Contains(t, jr.Reason, "invalid process id")
+}
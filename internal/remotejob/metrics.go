@@ -10,7 +10,10 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 )
 
-var jobRunVec *prometheus.SummaryVec
+var (
+	jobRunVec     *prometheus.SummaryVec
+	jobRunCostVec *prometheus.SummaryVec
+)
 
 func setupMetrics() {
 	jobRunVec = prometheus.NewSummaryVec(
@@ -31,9 +34,28 @@ func setupMetrics() {
 			"status",
 		},
 	)
+
+	jobRunCostVec = prometheus.NewSummaryVec(
+		prometheus.SummaryOpts{
+			Namespace: "datakit",
+			Subsystem: "remote_job",
+			Name:      "run_cost",
+			Help:      "Remote job execution time(seconds) statistics",
+
+			Objectives: map[float64]float64{
+				0.5:  0.05,
+				0.9:  0.01,
+				0.99: 0.001,
+			},
+		},
+		[]string{
+			"job",
+			"status",
+		},
+	)
 }
 
 func init() { //nolint:gochecknoinits
 	setupMetrics()
-	metrics.MustRegister(jobRunVec)
+	metrics.MustRegister(jobRunVec, jobRunCostVec)
 }
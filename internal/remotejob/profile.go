@@ -0,0 +1,178 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2024-present Guance, Inc.
+
+package remotejob
+
+import (
+	"bytes"
+	"fmt"
+	"runtime/pprof"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultProfileSeconds = 10
+	maxProfileSeconds     = 60
+)
+
+var defaultProfileTypes = []string{"cpu", "heap", "goroutine"}
+
+// GoProfile capture pprof of a Go service that exposed net/http/pprof.
+type GoProfile struct {
+	UUID    string   `json:"uuid"`
+	URL     string   `json:"url"`
+	Types   []string `json:"types"`   // cpu,heap,goroutine,mutex,block
+	Seconds int      `json:"seconds"` // duration of cpu profile
+
+	TLSOpen            bool   `json:"tls_open"`
+	CacertFile         string `json:"tls_ca"`
+	CertFile           string `json:"tls_cert"`
+	KeyFile            string `json:"tls_key"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+}
+
+// GoProfileCaptureFunc pull profiles of the Go service, return pprof data keyed by file name.
+type GoProfileCaptureFunc func(j *GoProfile) (map[string][]byte, error)
+
+var (
+	goProfileCapture    GoProfileCaptureFunc
+	goProfileCaptureMtx sync.RWMutex
+)
+
+// SetGoProfileCapture set the function to capture Go profiles, it's
+// implemented by profile input to reuse its pull logic.
+func SetGoProfileCapture(f GoProfileCaptureFunc) {
+	goProfileCaptureMtx.Lock()
+	defer goProfileCaptureMtx.Unlock()
+	goProfileCapture = f
+}
+
+func getGoProfileCapture() GoProfileCaptureFunc {
+	goProfileCaptureMtx.RLock()
+	defer goProfileCaptureMtx.RUnlock()
+	return goProfileCapture
+}
+
+func profileSeconds(n int) int {
+	switch {
+	case n <= 0:
+		return defaultProfileSeconds
+	case n > maxProfileSeconds:
+		return maxProfileSeconds
+	default:
+		return n
+	}
+}
+
+func profileReport(uuid string, files map[string][]byte) *jobReport {
+	jr := &jobReport{UUID: uuid, IsOK: true}
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	details := make([]string, 0, len(names))
+	for _, name := range names {
+		jr.Attachments = append(jr.Attachments, newAttachment(name, files[name]))
+		details = append(details, fmt.Sprintf("%s: %d bytes", name, len(files[name])))
+	}
+
+	jr.Details = strings.Join(details, "\n")
+	return jr
+}
+
+func (j *GoProfile) run() *jobReport {
+	if j.UUID == "" || j.URL == "" {
+		return failedReport(j.UUID, "go profile check err: uuid or url is empty")
+	}
+
+	capture := getGoProfileCapture()
+	if capture == nil {
+		return failedReport(j.UUID, "go profile not supported: profile input not available")
+	}
+
+	if len(j.Types) == 0 {
+		j.Types = defaultProfileTypes
+	}
+	j.Seconds = profileSeconds(j.Seconds)
+
+	log.Infof("capture go profile %v from %s", j.Types, j.URL)
+
+	files, err := capture(j)
+	if err != nil {
+		log.Warnf("capture go profile from %s: %s", j.URL, err.Error())
+		if len(files) == 0 {
+			return failedReport(j.UUID, "capture go profile: %s", err.Error())
+		}
+	}
+
+	jr := profileReport(j.UUID, files)
+	if err != nil {
+		jr.Reason = err.Error()
+	}
+	return jr
+}
+
+// DatakitProfile capture pprof of Datakit itself.
+type DatakitProfile struct {
+	UUID    string   `json:"uuid"`
+	Types   []string `json:"types"`   // cpu,heap,goroutine,allocs,mutex,block,threadcreate
+	Seconds int      `json:"seconds"` // duration of cpu profile
+}
+
+func (j *DatakitProfile) run() *jobReport {
+	if j.UUID == "" {
+		return failedReport(j.UUID, "datakit profile check err: uuid is empty")
+	}
+
+	if len(j.Types) == 0 {
+		j.Types = defaultProfileTypes
+	}
+
+	var (
+		files = map[string][]byte{}
+		errs  []string
+	)
+
+	for _, t := range j.Types {
+		buf := &bytes.Buffer{}
+
+		if t == "cpu" {
+			if err := pprof.StartCPUProfile(buf); err != nil {
+				errs = append(errs, fmt.Sprintf("cpu: %s", err.Error()))
+				continue
+			}
+
+			time.Sleep(time.Duration(profileSeconds(j.Seconds)) * time.Second)
+			pprof.StopCPUProfile()
+		} else {
+			p := pprof.Lookup(t)
+			if p == nil {
+				errs = append(errs, fmt.Sprintf("%s: unknown profile", t))
+				continue
+			}
+
+			if err := p.WriteTo(buf, 0); err != nil {
+				errs = append(errs, fmt.Sprintf("%s: %s", t, err.Error()))
+				continue
+			}
+		}
+
+		files[t+".pprof"] = buf.Bytes()
+	}
+
+	if len(files) == 0 {
+		return failedReport(j.UUID, "capture datakit profile: %s", strings.Join(errs, "; "))
+	}
+
+	jr := profileReport(j.UUID, files)
+	jr.Reason = strings.Join(errs, "; ")
+	return jr
+}
@@ -0,0 +1,75 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2024-present Guance, Inc.
+
+package remotejob
+
+import (
+	"bytes"
+	"context"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+const (
+	pySpyBin              = "py-spy"
+	defaultPyStackTimeout = 30
+)
+
+// PyStack dump stacks of a running python process via py-spy.
+type PyStack struct {
+	UUID      string `json:"uuid"`
+	ProcessID int    `json:"process_id"`
+	Native    bool   `json:"native"`  // include native(C/C++) stack frames
+	Timeout   int    `json:"timeout"` // seconds
+}
+
+func (j *PyStack) run(pySpy string) *jobReport {
+	if j.UUID == "" || j.ProcessID <= 0 {
+		return failedReport(j.UUID, "py stack check err: uuid is empty or invalid process id %d", j.ProcessID)
+	}
+
+	if pySpy == "" {
+		pySpy = pySpyBin
+	}
+
+	bin, err := exec.LookPath(pySpy)
+	if err != nil {
+		return failedReport(j.UUID, "py-spy not available: %s", err.Error())
+	}
+
+	timeout := j.Timeout
+	if timeout <= 0 {
+		timeout = defaultPyStackTimeout
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(timeout)*time.Second)
+	defer cancel()
+
+	args := []string{"dump", "--pid", strconv.Itoa(j.ProcessID)}
+	if j.Native {
+		args = append(args, "--native")
+	}
+
+	cmd := exec.CommandContext(ctx, bin, args...) //nolint:gosec
+	log.Infof("cmd to string:%s", cmd.String())
+
+	var out, stderr bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = &stderr
+
+	jr := &jobReport{UUID: j.UUID, IsOK: true}
+	if err := cmd.Run(); err != nil {
+		log.Warnf("do %s err=%v bts=%s", cmd.String(), err, stderr.String())
+		jr.IsOK = false
+		jr.Reason = err.Error()
+		if stderr.Len() > 0 {
+			jr.Reason += ": " + stderr.String()
+		}
+	}
+
+	jr.Details = out.String()
+	return jr
+}
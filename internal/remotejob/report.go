@@ -0,0 +1,121 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2024-present Guance, Inc.
+
+package remotejob
+
+import (
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	jobJVMDump        = "jvm_dump"
+	jobGoProfile      = "go_profile"
+	jobDatakitProfile = "datakit_profile"
+	jobLogSnapshot    = "log_snapshot"
+	jobPyStack        = "py_stack"
+
+	// jobTypesArg tell the server which kinds of job this Datakit can run.
+	jobTypesArg = "job_types"
+
+	defaultMaxReportSize = 4 * 1024 * 1024
+	truncatedMark        = "...(truncated)\n"
+)
+
+var jobTypes = []string{
+	jobJVMDump,
+	jobGoProfile,
+	jobDatakitProfile,
+	jobLogSnapshot,
+	jobPyStack,
+}
+
+// attachment is a binary file(such as pprof) collected by the job.
+type attachment struct {
+	Name     string `json:"name"`
+	Encoding string `json:"encoding"`
+	Content  string `json:"content"`
+}
+
+func newAttachment(name string, data []byte) *attachment {
+	return &attachment{
+		Name:     name,
+		Encoding: "base64",
+		Content:  base64.StdEncoding.EncodeToString(data),
+	}
+}
+
+func (m *Manager) maxReportSize() int64 {
+	if m.MaxReportSize <= 0 {
+		return defaultMaxReportSize
+	}
+	return m.MaxReportSize
+}
+
+// limitReport keep size of details and attachments within max bytes.
+// Details is truncated from the head, it's the tail that matters for command
+// output and log, attachments that do not fit are dropped.
+func limitReport(jr *jobReport, max int64) {
+	if int64(len(jr.Details)) > max {
+		keep := max - int64(len(truncatedMark))
+		if keep < 0 {
+			keep = 0
+		}
+		jr.Details = truncatedMark + jr.Details[int64(len(jr.Details))-keep:]
+	}
+
+	left := max - int64(len(jr.Details))
+
+	var (
+		kept    []*attachment
+		dropped []string
+	)
+
+	for _, a := range jr.Attachments {
+		if n := int64(len(a.Content)); n <= left {
+			kept = append(kept, a)
+			left -= n
+		} else {
+			dropped = append(dropped, a.Name)
+		}
+	}
+
+	if len(dropped) == 0 {
+		return
+	}
+
+	jr.Attachments = kept
+	reason := fmt.Sprintf("attachment %s dropped: exceed report size limit(%d bytes)",
+		strings.Join(dropped, ","), max)
+	if jr.Reason != "" {
+		jr.Reason += "; " + reason
+	} else {
+		jr.Reason = reason
+	}
+
+	if len(kept) == 0 {
+		jr.IsOK = false
+	}
+}
+
+// observe run the job and record its cost.
+func observe(job string, f func() *jobReport) *jobReport {
+	start := time.Now()
+	jr := f()
+
+	status := "success"
+	if !jr.IsOK {
+		status = "failed"
+	}
+
+	jobRunCostVec.WithLabelValues(job, status).Observe(float64(time.Since(start)) / float64(time.Second))
+	return jr
+}
+
+func failedReport(uuid, format string, args ...any) *jobReport {
+	return &jobReport{UUID: uuid, Reason: fmt.Sprintf(format, args...)}
+}
@@ -0,0 +1,101 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2024-present Guance, Inc.
+
+package remotejob
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitReport(t *testing.T) {
+	t.Run("within-limit", func(t *testing.T) {
+		jr := &jobReport{IsOK: true, Details: "abc", Attachments: []*attachment{newAttachment("a", []byte("123"))}}
+		limitReport(jr, 100)
+		assert.True(t, jr.IsOK)
+		assert.Equal(t, "abc", jr.Details)
+		assert.Len(t, jr.Attachments, 1)
+		assert.Empty(t, jr.Reason)
+	})
+
+	t.Run("truncate-details", func(t *testing.T) {
+		jr := &jobReport{IsOK: true, Details: strings.Repeat("x", 100) + "tail"}
+		limitReport(jr, 50)
+		assert.True(t, jr.IsOK)
+		assert.Len(t, jr.Details, 50)
+		assert.True(t, strings.HasPrefix(jr.Details, truncatedMark))
+		assert.True(t, strings.HasSuffix(jr.Details, "tail"))
+	})
+
+	t.Run("drop-attachments", func(t *testing.T) {
+		jr := &jobReport{
+			IsOK: true,
+			Attachments: []*attachment{
+				newAttachment("small", []byte("1")),
+				newAttachment("large", []byte(strings.Repeat("x", 100))),
+			},
+		}
+
+		limitReport(jr, 50)
+		assert.True(t, jr.IsOK)
+		assert.Len(t, jr.Attachments, 1)
+		assert.Equal(t, "small", jr.Attachments[0].Name)
+		assert.Contains(t, jr.Reason, "attachment large dropped")
+	})
+
+	t.Run("all-dropped", func(t *testing.T) {
+		jr := &jobReport{IsOK: true, Attachments: []*attachment{newAttachment("large", []byte(strings.Repeat("x", 100)))}}
+		limitReport(jr, 50)
+		assert.False(t, jr.IsOK)
+		assert.Empty(t, jr.Attachments)
+	})
+}
+
+func TestDatakitProfile(t *testing.T) {
+	t.Run("ok", func(t *testing.T) {
+		jr := (&DatakitProfile{UUID: "1", Types: []string{"heap", "goroutine"}}).run()
+		assert.True(t, jr.IsOK)
+		assert.Len(t, jr.Attachments, 2)
+		assert.Equal(t, "goroutine.pprof", jr.Attachments[0].Name)
+		assert.Equal(t, "heap.pprof", jr.Attachments[1].Name)
+	})
+
+	t.Run("cpu", func(t *testing.T) {
+		jr := (&DatakitProfile{UUID: "1", Types: []string{"cpu"}, Seconds: 1}).run()
+		assert.True(t, jr.IsOK, jr.Reason)
+		assert.Len(t, jr.Attachments, 1)
+	})
+
+	t.Run("unknown-type", func(t *testing.T) {
+		jr := (&DatakitProfile{UUID: "1", Types: []string{"heap", "no-such"}}).run()
+		assert.True(t, jr.IsOK)
+		assert.Len(t, jr.Attachments, 1)
+		assert.Contains(t, jr.Reason, "no-such: unknown profile")
+
+		jr = (&DatakitProfile{UUID: "1", Types: []string{"no-such"}}).run()
+		assert.False(t, jr.IsOK)
+	})
+}
+
+func TestGoProfile(t *testing.T) {
+	defer SetGoProfileCapture(getGoProfileCapture())
+
+	SetGoProfileCapture(nil)
+	jr := (&GoProfile{UUID: "1", URL: "http://localhost:6060"}).run()
+	assert.False(t, jr.IsOK)
+	assert.Contains(t, jr.Reason, "not supported")
+
+	SetGoProfileCapture(func(j *GoProfile) (map[string][]byte, error) {
+		assert.Equal(t, defaultProfileTypes, j.Types)
+		assert.Equal(t, maxProfileSeconds, j.Seconds)
+		return map[string][]byte{"cpu.pprof": []byte("cpu")}, nil
+	})
+
+	jr = (&GoProfile{UUID: "1", URL: "http://localhost:6060", Seconds: 3600}).run()
+	assert.True(t, jr.IsOK)
+	assert.Len(t, jr.Attachments, 1)
+}
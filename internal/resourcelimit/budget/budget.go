@@ -0,0 +1,306 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+// Package budget account CPU and memory usage of each input, and throttle or
+// pause inputs exceeding their budgets.
+//
+// Go runtime got no per-goroutine CPU or memory counters. CPU time of the
+// process(see runtime/metrics) is split among inputs by their share of busy
+// goroutines sampled from goroutine profiles, which attributed by the pprof
+// label set on input goroutines(see goroutine.LabelInput). Heap usage is
+// sampled from the heap profile. Unlabeled samples are attributed by input
+// packages within the stack.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/logger"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/metrics"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit"
+)
+
+const (
+	defaultInterval        = 10 * time.Second
+	defaultGoroutineSample = time.Second
+
+	minThrottle = 100 * time.Millisecond
+	maxThrottle = 10 * time.Second
+)
+
+var l = logger.DefaultSLogger("budget")
+
+type state struct {
+	delay  time.Duration
+	paused bool
+}
+
+type accountant struct {
+	budgets map[string]*resourcelimit.InputBudget
+	states  map[string]*state
+
+	ncpu      int
+	lastAlloc map[string]int64
+	seen      map[string]bool
+
+	// busy goroutines sampled since last update
+	busy      map[string]int64
+	busyTotal int64
+	lastCPU   float64
+	lastTime  time.Time
+	cpuTime   func() (float64, error)
+
+	throttle func(name string, delay time.Duration)
+	pause    func(name string, on bool)
+	feeder   dkio.Feeder
+}
+
+func newAccountant(budgets map[string]*resourcelimit.InputBudget) *accountant {
+	return &accountant{
+		budgets:   budgets,
+		states:    map[string]*state{},
+		ncpu:      runtime.NumCPU(),
+		lastAlloc: map[string]int64{},
+		seen:      map[string]bool{},
+		busy:      map[string]int64{},
+		cpuTime:   processCPUTime,
+		throttle:  dkio.ThrottleInput,
+		pause:     dkio.PauseInput,
+		feeder:    dkio.DefaultFeeder(),
+	}
+}
+
+// Check validate and set default action of budgets.
+func Check(budgets map[string]*resourcelimit.InputBudget) error {
+	for name, b := range budgets {
+		if b == nil {
+			continue
+		}
+
+		if b.CPUMax < 0 || b.CPUMax > 100 {
+			return fmt.Errorf("input %s: cpu_max should be in range of [0.0, 100.0]", name)
+		}
+
+		switch b.Action {
+		case "":
+			b.Action = resourcelimit.BudgetActionThrottle
+		case resourcelimit.BudgetActionThrottle, resourcelimit.BudgetActionPause:
+		default:
+			return fmt.Errorf("input %s: invalid action %q, should be %s or %s",
+				name, b.Action, resourcelimit.BudgetActionThrottle, resourcelimit.BudgetActionPause)
+		}
+	}
+
+	return nil
+}
+
+// Run start accounting resource usage of inputs and enforcing budgets.
+func Run(opt *resourcelimit.ResourceLimitOptions) {
+	l = logger.SLogger("budget")
+
+	if opt == nil || (len(opt.Inputs) == 0 && !opt.AccountInputs) {
+		return
+	}
+
+	if err := Check(opt.Inputs); err != nil {
+		l.Errorf("invalid input budgets: %s", err)
+		return
+	}
+
+	a := newAccountant(opt.Inputs)
+
+	g := datakit.G("internal_resourcelimit")
+	g.Go(func(ctx context.Context) error {
+		sampleTick := time.NewTicker(defaultGoroutineSample)
+		defer sampleTick.Stop()
+
+		tick := time.NewTicker(defaultInterval)
+		defer tick.Stop()
+
+		a.cpuUsage() // set the start CPU time
+
+		for {
+			select {
+			case <-datakit.Exit.Wait():
+				return nil
+			case <-sampleTick.C:
+				a.sampleGoroutines()
+			case <-tick.C:
+				a.sample()
+			}
+		}
+	})
+}
+
+func (a *accountant) sampleGoroutines() {
+	p, err := profileGoroutines()
+	if err != nil {
+		l.Warnf("profile goroutines: %s", err)
+		return
+	}
+
+	busy, total, err := busyFromProfile(p)
+	if err != nil {
+		l.Warnf("busyFromProfile: %s", err)
+		return
+	}
+
+	for name, n := range busy {
+		a.busy[name] += n
+	}
+	a.busyTotal += total
+}
+
+// cpuUsage split CPU usage(percent of all cores) of the process since last
+// call among inputs by their share of busy goroutines sampled.
+func (a *accountant) cpuUsage() map[string]float64 {
+	res := map[string]float64{}
+
+	now := time.Now()
+	total, err := a.cpuTime()
+	if err != nil {
+		l.Warnf("get CPU time: %s", err)
+		return res
+	}
+
+	defer func() {
+		a.busy = map[string]int64{}
+		a.busyTotal = 0
+		a.lastCPU, a.lastTime = total, now
+	}()
+
+	if a.lastTime.IsZero() || a.busyTotal == 0 || !now.After(a.lastTime) {
+		return res
+	}
+
+	percent := (total - a.lastCPU) / now.Sub(a.lastTime).Seconds() / float64(a.ncpu) * 100
+	for name, n := range a.busy {
+		res[name] = percent * float64(n) / float64(a.busyTotal)
+	}
+
+	return res
+}
+
+func (a *accountant) sample() {
+	cpu := a.cpuUsage()
+
+	var mem map[string]*memUsage
+	if p, err := profileHeap(); err != nil {
+		l.Warnf("profile heap: %s", err)
+	} else if mem, err = memFromProfile(p); err != nil {
+		l.Warnf("memFromProfile: %s", err)
+	}
+
+	a.update(cpu, mem)
+}
+
+// update refresh metrics and enforce budgets with new sampled usage.
+func (a *accountant) update(cpu map[string]float64, mem map[string]*memUsage) {
+	names := map[string]bool{}
+	for name := range cpu {
+		names[name] = true
+	}
+	for name := range mem {
+		names[name] = true
+	}
+	for name := range a.seen {
+		names[name] = true // usage of these inputs gone, reset them
+	}
+	for name := range a.budgets {
+		names[name] = true
+	}
+
+	for name := range names {
+		var heap int64
+		if u := mem[name]; u != nil {
+			heap = u.inuse
+			if delta := u.alloc - a.lastAlloc[name]; delta > 0 {
+				allocBytesVec.WithLabelValues(name).Add(float64(delta))
+			}
+			a.lastAlloc[name] = u.alloc
+		}
+
+		if a.seen[name] || cpu[name] > 0 || heap > 0 {
+			a.seen[name] = true
+			cpuUsageVec.WithLabelValues(name).Set(cpu[name])
+			heapBytesVec.WithLabelValues(name).Set(float64(heap))
+		}
+
+		if b := a.budgets[name]; b != nil {
+			a.enforce(name, b, cpu[name], heap)
+		}
+	}
+}
+
+func (a *accountant) enforce(name string, b *resourcelimit.InputBudget, cpu float64, heap int64) {
+	st, ok := a.states[name]
+	if !ok {
+		st = &state{}
+		a.states[name] = st
+	}
+
+	var reason string
+	switch {
+	case b.CPUMax > 0 && cpu > b.CPUMax:
+		reason = fmt.Sprintf("CPU usage %.2f%% exceeds budget %.2f%%", cpu, b.CPUMax)
+	case b.MemMax > 0 && heap > b.MemMax*resourcelimit.MB:
+		reason = fmt.Sprintf("heap in use %dMB exceeds budget %dMB", heap/resourcelimit.MB, b.MemMax)
+	}
+
+	over := reason != ""
+	if over {
+		overBudgetVec.WithLabelValues(name, b.Action).Inc()
+	}
+
+	switch b.Action {
+	case resourcelimit.BudgetActionPause:
+		if over == st.paused {
+			return
+		}
+
+		st.paused = over
+		a.pause(name, over)
+
+		if over {
+			l.Warnf("input %s paused: %s", name, reason)
+			a.feeder.FeedLastError(fmt.Sprintf("input paused: %s", reason), metrics.WithLastErrorInput(name))
+		} else {
+			l.Infof("input %s resumed", name)
+		}
+
+	default:
+		delay := st.delay
+		if over {
+			// double the delay until usage back within budget
+			if delay *= 2; delay < minThrottle {
+				delay = minThrottle
+			} else if delay > maxThrottle {
+				delay = maxThrottle
+			}
+		} else if delay /= 2; delay < minThrottle {
+			delay = 0
+		}
+
+		if delay == st.delay {
+			return
+		}
+
+		st.delay = delay
+		a.throttle(name, delay)
+
+		if over {
+			l.Warnf("input %s throttled(%s per feed): %s", name, delay, reason)
+			a.feeder.FeedLastError(fmt.Sprintf("input throttled(%s per feed): %s", delay, reason),
+				metrics.WithLastErrorInput(name))
+		} else {
+			l.Infof("input %s throttle set to %s", name, delay)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package budget
+
+import (
+	"context"
+	"runtime/pprof"
+	T "testing"
+	"time"
+
+	"github.com/google/pprof/profile"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/goroutine"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit"
+)
+
+const pkgPrefix = "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs/"
+
+func TestFuncInput(t *T.T) {
+	cases := map[string]string{
+		pkgPrefix + "prom.(*Input).Collect":                                             "prom",
+		pkgPrefix + "logging.(*Input).Run.func1":                                        "logging",
+		pkgPrefix + "ddtrace/trace.handleTraces":                                        "ddtrace",
+		"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/plugins/inputs.RunInput": "",
+		"runtime.mallocgc": "",
+	}
+
+	for fn, expect := range cases {
+		assert.Equal(t, expect, funcInput(fn), fn)
+	}
+}
+
+func stack(fns ...string) []*profile.Location {
+	var locs []*profile.Location
+	for _, fn := range fns {
+		locs = append(locs, &profile.Location{Line: []profile.Line{{Function: &profile.Function{Name: fn}}}})
+	}
+	return locs
+}
+
+func TestFromProfile(t *T.T) {
+	t.Run("busy", func(t *T.T) {
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{{Type: "goroutine", Unit: "count"}},
+			Sample: []*profile.Sample{
+				// labeled
+				{Value: []int64{2}, Label: map[string][]string{goroutine.LabelInput: {"prom"}}, Location: stack("runtime.mallocgc")},
+				// label preferred to stack
+				{Value: []int64{1}, Label: map[string][]string{goroutine.LabelInput: {"prom"}}, Location: stack(pkgPrefix + "cpu.collect")},
+				// parked
+				{Value: []int64{5}, Label: map[string][]string{goroutine.LabelInput: {"prom"}}, Location: stack("runtime.gopark", "runtime.chanrecv")},
+				{Value: []int64{3}, Location: stack("syscall.Syscall6", pkgPrefix+"logging.(*Input).Run")},
+				// by stack
+				{Value: []int64{1}, Location: stack("runtime.mallocgc", pkgPrefix+"logging.(*Input).Run")},
+				// not any input
+				{Value: []int64{4}, Location: stack("runtime.gcBgMarkWorker")},
+			},
+		}
+
+		res, total, err := busyFromProfile(p)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]int64{"prom": 3, "logging": 1}, res)
+		assert.Equal(t, int64(8), total)
+	})
+
+	t.Run("mem", func(t *T.T) {
+		p := &profile.Profile{
+			SampleType: []*profile.ValueType{
+				{Type: "alloc_objects"}, {Type: "alloc_space"}, {Type: "inuse_objects"}, {Type: "inuse_space"},
+			},
+			Sample: []*profile.Sample{
+				{Value: []int64{1, 100, 1, 10}, Location: stack("runtime.makeslice", pkgPrefix+"prom.(*Input).Collect")},
+				{Value: []int64{1, 200, 1, 20}, Location: stack(pkgPrefix + "prom.parse")},
+				{Value: []int64{1, 300, 1, 30}, Location: stack("main.main")},
+			},
+		}
+
+		res, err := memFromProfile(p)
+		require.NoError(t, err)
+		require.Len(t, res, 1)
+		assert.Equal(t, &memUsage{inuse: 30, alloc: 300}, res["prom"])
+	})
+
+	t.Run("invalid", func(t *T.T) {
+		_, _, err := busyFromProfile(&profile.Profile{})
+		assert.Error(t, err)
+	})
+}
+
+func TestCPUUsage(t *T.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go pprof.Do(ctx, pprof.Labels(goroutine.LabelInput, "busy"), func(ctx context.Context) {
+		for ctx.Err() == nil { // burn CPU
+		}
+	})
+
+	time.Sleep(10 * time.Millisecond) // wait the goroutine labeled
+
+	a := newAccountant(nil)
+	a.ncpu = 1
+
+	cpuTime := 0.0
+	a.cpuTime = func() (float64, error) { return cpuTime, nil }
+	a.cpuUsage()
+
+	for i := 0; i < 5; i++ {
+		a.sampleGoroutines()
+	}
+	assert.Equal(t, int64(5), a.busy["busy"])
+
+	time.Sleep(100 * time.Millisecond)
+	cpuTime = 0.1 // 100% of 1 core
+
+	res := a.cpuUsage()
+	assert.Greater(t, res["busy"], 10.0)
+	assert.LessOrEqual(t, res["busy"], 100.0)
+	assert.Empty(t, a.busy)
+
+	t.Run("process", func(t *T.T) {
+		v, err := processCPUTime()
+		require.NoError(t, err)
+		assert.Greater(t, v, 0.0)
+	})
+}
+
+type action struct {
+	delay  time.Duration
+	paused bool
+}
+
+func newTestAccountant(budgets map[string]*resourcelimit.InputBudget) (*accountant, map[string][]action, *dkio.MockedFeeder) {
+	actions := map[string][]action{}
+	feeder := dkio.NewMockedFeeder()
+
+	a := newAccountant(budgets)
+	a.feeder = feeder
+	a.throttle = func(name string, delay time.Duration) {
+		actions[name] = append(actions[name], action{delay: delay})
+	}
+	a.pause = func(name string, on bool) {
+		actions[name] = append(actions[name], action{paused: on})
+	}
+
+	return a, actions, feeder
+}
+
+func TestEnforce(t *T.T) {
+	resetMetrics()
+
+	t.Run("throttle", func(t *T.T) {
+		budgets := map[string]*resourcelimit.InputBudget{"prom": {CPUMax: 5}}
+		require.NoError(t, Check(budgets))
+		assert.Equal(t, resourcelimit.BudgetActionThrottle, budgets["prom"].Action)
+
+		a, actions, feeder := newTestAccountant(budgets)
+
+		for i := 0; i < 3; i++ {
+			a.update(map[string]float64{"prom": 10}, nil)
+		}
+		a.update(map[string]float64{"prom": 1}, nil)
+		a.update(map[string]float64{"prom": 1}, nil)
+		a.update(map[string]float64{"prom": 1}, nil)
+
+		assert.Equal(t, []action{
+			{delay: 100 * time.Millisecond},
+			{delay: 200 * time.Millisecond},
+			{delay: 400 * time.Millisecond},
+			{delay: 200 * time.Millisecond},
+			{delay: 100 * time.Millisecond},
+			{delay: 0},
+		}, actions["prom"])
+
+		require.Len(t, feeder.LastErrors(), 3)
+		assert.Equal(t, "prom", feeder.LastErrors()[0][0])
+	})
+
+	t.Run("max-throttle", func(t *T.T) {
+		a, actions, _ := newTestAccountant(map[string]*resourcelimit.InputBudget{
+			"prom": {CPUMax: 5, Action: resourcelimit.BudgetActionThrottle},
+		})
+
+		for i := 0; i < 20; i++ {
+			a.update(map[string]float64{"prom": 10}, nil)
+		}
+
+		assert.Equal(t, maxThrottle, actions["prom"][len(actions["prom"])-1].delay)
+	})
+
+	t.Run("pause", func(t *T.T) {
+		a, actions, _ := newTestAccountant(map[string]*resourcelimit.InputBudget{
+			"logging": {MemMax: 1, Action: resourcelimit.BudgetActionPause},
+		})
+
+		over := map[string]*memUsage{"logging": {inuse: 2 * resourcelimit.MB, alloc: 4 * resourcelimit.MB}}
+		within := map[string]*memUsage{"logging": {inuse: resourcelimit.MB / 2, alloc: 8 * resourcelimit.MB}}
+
+		a.update(nil, over)
+		a.update(nil, over)
+		a.update(nil, within)
+		a.update(nil, within)
+
+		assert.Equal(t, []action{{paused: true}, {paused: false}}, actions["logging"])
+	})
+
+	t.Run("invalid", func(t *T.T) {
+		assert.Error(t, Check(map[string]*resourcelimit.InputBudget{"prom": {CPUMax: 101}}))
+		assert.Error(t, Check(map[string]*resourcelimit.InputBudget{"prom": {Action: "drop"}}))
+	})
+}
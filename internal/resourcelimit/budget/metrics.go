@@ -0,0 +1,92 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package budget
+
+import (
+	"github.com/GuanceCloud/cliutils/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	cpuUsageVec,
+	heapBytesVec *prometheus.GaugeVec
+
+	allocBytesVec,
+	overBudgetVec *prometheus.CounterVec
+)
+
+func metricsSetup() {
+	cpuUsageVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "datakit",
+			Subsystem: "input",
+			Name:      "resource_cpu_usage",
+			Help:      "Sampled CPU usage(percent, max is 100) of the input",
+		},
+		[]string{
+			"name",
+		},
+	)
+
+	heapBytesVec = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: "datakit",
+			Subsystem: "input",
+			Name:      "resource_heap_bytes",
+			Help:      "Heap in use(bytes) allocated by the input",
+		},
+		[]string{
+			"name",
+		},
+	)
+
+	allocBytesVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "input",
+			Name:      "resource_alloc_bytes_total",
+			Help:      "Total bytes allocated by the input",
+		},
+		[]string{
+			"name",
+		},
+	)
+
+	overBudgetVec = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: "datakit",
+			Subsystem: "input",
+			Name:      "resource_over_budget_total",
+			Help:      "Count of the input exceeding its CPU or memory budget",
+		},
+		[]string{
+			"name",
+			"action",
+		},
+	)
+}
+
+func allMetrics() []prometheus.Collector {
+	return []prometheus.Collector{
+		cpuUsageVec,
+		heapBytesVec,
+		allocBytesVec,
+		overBudgetVec,
+	}
+}
+
+func resetMetrics() {
+	cpuUsageVec.Reset()
+	heapBytesVec.Reset()
+	allocBytesVec.Reset()
+	overBudgetVec.Reset()
+}
+
+//nolint:gochecknoinits
+func init() {
+	metricsSetup()
+	metrics.MustRegister(allMetrics()...)
+}
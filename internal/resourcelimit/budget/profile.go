@@ -0,0 +1,198 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package budget
+
+import (
+	"bytes"
+	"fmt"
+	rtmetrics "runtime/metrics"
+	"runtime/pprof"
+	"strings"
+
+	"github.com/google/pprof/profile"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/goroutine"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit"
+)
+
+const inputPkgPath = "/internal/plugins/inputs/"
+
+type memUsage struct {
+	inuse int64 // heap in use bytes
+	alloc int64 // total allocated bytes
+}
+
+// funcInput returns the input package name of function fn, such as
+// prom for .../internal/plugins/inputs/prom.(*Input).Collect.
+func funcInput(fn string) string {
+	idx := strings.Index(fn, inputPkgPath)
+	if idx < 0 {
+		return ""
+	}
+
+	rest := fn[idx+len(inputPkgPath):]
+	if end := strings.IndexAny(rest, "./"); end > 0 {
+		return rest[:end]
+	}
+
+	return ""
+}
+
+// sampleInput returns the input the sample belongs to. Goroutines of inputs
+// are labeled, for samples without label(such as HTTP handlers of inputs and
+// all heap samples), the nearest input package within the stack used.
+func sampleInput(s *profile.Sample) string {
+	if v := s.Label[goroutine.LabelInput]; len(v) > 0 {
+		return v[0]
+	}
+
+	for _, loc := range s.Location { // leaf first
+		for _, line := range loc.Line {
+			if line.Function == nil {
+				continue
+			}
+
+			if name := funcInput(line.Function.Name); name != "" {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+func sampleIndex(p *profile.Profile, typ string) (int, error) {
+	for i, st := range p.SampleType {
+		if st.Type == typ {
+			return i, nil
+		}
+	}
+
+	return -1, fmt.Errorf("sample type %q not found", typ)
+}
+
+// parkedFuncs are leaf functions of goroutines not running on CPU: parked
+// or blocked within syscalls.
+var parkedFuncs = []string{
+	"runtime.gopark",
+	"runtime.goparkunlock",
+	"runtime.notetsleepg",
+	"syscall.",
+	"runtime/internal/syscall.",
+	"internal/runtime/syscall.",
+	"golang.org/x/sys/unix.",
+}
+
+func sampleParked(s *profile.Sample) bool {
+	if len(s.Location) == 0 || len(s.Location[0].Line) == 0 || s.Location[0].Line[0].Function == nil {
+		return false
+	}
+
+	leaf := s.Location[0].Line[0].Function.Name
+	for _, fn := range parkedFuncs {
+		if strings.HasPrefix(leaf, fn) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// busyFromProfile returns count of busy(running or runnable) goroutines of
+// each input and of the whole process within goroutine profile p.
+func busyFromProfile(p *profile.Profile) (map[string]int64, int64, error) {
+	idx, err := sampleIndex(p, "goroutine")
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var total int64
+	res := map[string]int64{}
+	for _, s := range p.Sample {
+		if sampleParked(s) {
+			continue
+		}
+
+		total += s.Value[idx]
+		if input := sampleInput(s); input != "" {
+			res[input] += s.Value[idx]
+		}
+	}
+
+	return res, total, nil
+}
+
+// memFromProfile returns heap usage of each input within heap profile p.
+func memFromProfile(p *profile.Profile) (map[string]*memUsage, error) {
+	inuseIdx, err := sampleIndex(p, "inuse_space")
+	if err != nil {
+		return nil, err
+	}
+
+	allocIdx, err := sampleIndex(p, "alloc_space")
+	if err != nil {
+		return nil, err
+	}
+
+	res := map[string]*memUsage{}
+	for _, s := range p.Sample {
+		input := sampleInput(s)
+		if input == "" {
+			continue
+		}
+
+		u, ok := res[input]
+		if !ok {
+			u = &memUsage{}
+			res[input] = u
+		}
+
+		u.inuse += s.Value[inuseIdx]
+		u.alloc += s.Value[allocIdx]
+	}
+
+	return res, nil
+}
+
+// profileGoroutines returns the goroutine profile, with labels of each goroutine.
+func profileGoroutines() (*profile.Profile, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("goroutine").WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+
+	return profile.Parse(&buf)
+}
+
+func profileHeap() (*profile.Profile, error) {
+	var buf bytes.Buffer
+	if err := pprof.Lookup("allocs").WriteTo(&buf, 0); err != nil {
+		return nil, err
+	}
+
+	return profile.Parse(&buf)
+}
+
+const cpuUserMetric = "/cpu/classes/user:cpu-seconds"
+
+// processCPUTime returns CPU time(seconds) of Datakit from rusage of the
+// process. CPU classes of runtime/metrics are estimated on GOMAXPROCS and
+// overestimate the usage, they are used only if the process CPU time not
+// available.
+func processCPUTime() (float64, error) {
+	v, err := resourcelimit.MyCPUTime()
+	if err == nil {
+		return v, nil
+	}
+
+	sample := []rtmetrics.Sample{{Name: cpuUserMetric}}
+	rtmetrics.Read(sample)
+
+	if sample[0].Value.Kind() == rtmetrics.KindFloat64 {
+		return sample[0].Value.Float64(), nil
+	}
+
+	return 0, err
+}
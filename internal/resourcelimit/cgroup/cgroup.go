@@ -132,8 +132,11 @@ func (c *Cgroup) delControl() {
 }
 
 func (c *Cgroup) start() error {
+	return c.add(os.Getpid())
+}
+
+func (c *Cgroup) add(pid int) error {
 	resource := c.makeLinuxResource()
-	pid := os.Getpid()
 	if cgroups.Mode() == cgroups.Unified {
 		l.Infof("use cgroup V2")
 		c.err = c.setupV2(resource, pid)
@@ -145,7 +148,7 @@ func (c *Cgroup) start() error {
 	if c.err != nil {
 		return fmt.Errorf("cgroup setup err=%w", c.err)
 	} else {
-		l.Infof("add Datakit pid:%d to cgroup", pid)
+		l.Infof("add pid:%d to cgroup %s", pid, c.opt.Path)
 	}
 
 	return nil
@@ -157,6 +160,30 @@ func Run(opt *CgroupOptions) error {
 	return cg.start()
 }
 
+// Limit add process pid to cgroup opt.Path, used to limit child processes such as
+// plugins. The opt.Path should not be Datakit's own cgroup.
+func Limit(opt *CgroupOptions, pid int) error {
+	o := *opt // MemMax changed during setup
+	return (&Cgroup{opt: &o}).add(pid)
+}
+
+// Remove delete cgroup path created by Limit, processes within it should have exited.
+func Remove(path string) error {
+	if cgroups.Mode() == cgroups.Unified {
+		manager, err := cgroup2.Load(path)
+		if err != nil {
+			return err
+		}
+		return manager.Delete()
+	}
+
+	control, err := cgroup1.Load(cgroup1.StaticPath(path))
+	if err != nil {
+		return err
+	}
+	return control.Delete()
+}
+
 func (c *Cgroup) String() string {
 	return fmt.Sprintf("path: %s, mem: %dMB, cpu: %.2f",
 		c.opt.Path, c.opt.MemMax/MB, c.opt.CPUMax)
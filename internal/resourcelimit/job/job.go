@@ -55,6 +55,11 @@ func Run(opt *JobOptions) error {
 
 	jobOpt = opt
 
+	return Limit(opt, os.Getpid())
+}
+
+// Limit assign process pid to a new job object with limits within opt.
+func Limit(opt *JobOptions, pid int) error {
 	var (
 		cpuInfo *JobObjectCPURateControlInformation
 		memInfo *JobObjectExtendedLimitInformation
@@ -64,7 +69,6 @@ func Run(opt *JobOptions) error {
 		return fmt.Errorf("both CPUMax and MemMax are 0, ignore set cpu/mem limit")
 	}
 
-	pid := os.Getpid()
 	name := "datakit"
 	if u, err := uuid.NewRandom(); err == nil {
 		name = u.String()
@@ -75,6 +79,13 @@ func Run(opt *JobOptions) error {
 		return fmt.Errorf("create job object failed: %w", err)
 	}
 
+	if pid != os.Getpid() {
+		// the job object is kept by the process within it, so the handle of
+		// child process's job can be closed, and the job released after the
+		// process exited.
+		defer CloseHandle(handle)
+	}
+
 	if opt.CPUMax > 0 {
 		cpuInfo = &JobObjectCPURateControlInformation{
 			CPURate: uint32(opt.CPUMax * 100),
@@ -83,7 +94,7 @@ func Run(opt *JobOptions) error {
 		cpuInfo.ControlFlags |= JOB_OBJECT_CPU_RATE_CONTROL_HARD_CAP
 
 		if err := SetInformationJobObject_CPURateControlInformation(handle, cpuInfo); err != nil {
-			jobOpt.cpuErr = fmt.Errorf("set cpu limit error: %w", err)
+			opt.cpuErr = fmt.Errorf("set cpu limit error: %w", err)
 		}
 	}
 
@@ -93,12 +104,12 @@ func Run(opt *JobOptions) error {
 		memInfo.BasicLimitInformation.LimitFlags |= JOB_OBJECT_LIMIT_PROCESS_MEMORY
 
 		if err := SetInformationJobObject_ExtendedLimitInformation(handle, memInfo); err != nil {
-			jobOpt.memErr = fmt.Errorf("set mem limit error: %w", err)
+			opt.memErr = fmt.Errorf("set mem limit error: %w", err)
 		}
 	}
 
-	if jobOpt.cpuErr != nil && jobOpt.memErr != nil {
-		return fmt.Errorf("set cpu/mem limit error: %w, %w", jobOpt.cpuErr, jobOpt.memErr)
+	if opt.cpuErr != nil && opt.memErr != nil {
+		return fmt.Errorf("set cpu/mem limit error: %w, %w", opt.cpuErr, opt.memErr)
 	}
 
 	processHandle, err := OpenProcess(
@@ -38,6 +38,26 @@ type ResourceLimitOptions struct {
 
 	DisableOOM bool `toml:"disable_oom,omitempty"`
 	Enable     bool `toml:"enable"`
+
+	// Per-input budgets, key is the input name. Enforced within Datakit,
+	// not affected by Enable.
+	Inputs map[string]*InputBudget `toml:"inputs,omitempty"`
+
+	// Account per-input resource usage even no budget configured.
+	AccountInputs bool `toml:"account_inputs,omitempty"`
+}
+
+// Actions on input exceeding its budget.
+const (
+	BudgetActionThrottle = "throttle" // delay feeds of the input
+	BudgetActionPause    = "pause"    // block feeds of the input until usage back within budget
+)
+
+// InputBudget limit CPU and memory usage of a single input.
+type InputBudget struct {
+	CPUMax float64 `toml:"cpu_max"`    // CPU usage percent(max is 100)
+	MemMax int64   `toml:"mem_max_mb"` // heap in use(MB)
+	Action string  `toml:"action"`
 }
 
 //nolint:gochecknoinits,lll
@@ -76,6 +96,26 @@ func Run(c *ResourceLimitOptions, username string) {
 	})
 }
 
+// LimitProcess limit CPU(percent, max is 100) and memory(MB) of child process pid,
+// name used to identify the cgroup(Linux) or job object(Windows) of the process.
+func LimitProcess(name string, pid int, cpuMax float64, memMax int64) error {
+	if cpuMax <= 0 && memMax <= 0 {
+		return nil
+	}
+
+	if cpuMax < 0 || cpuMax > 100 {
+		return fmt.Errorf("cpu_max should be in range of (0.0, 100.0]")
+	}
+
+	return limitProcess(name, pid, cpuMax, memMax)
+}
+
+// RemoveProcessLimit release the cgroup(Linux) created by LimitProcess for name,
+// it should be called after the limited process exited.
+func RemoveProcessLimit(name string) error {
+	return removeProcessLimit(name)
+}
+
 func MyMemPercent() (float32, error) {
 	if self == nil {
 		return 0, errProcessInitFailed
@@ -100,6 +140,20 @@ func MyCPUPercent(du time.Duration) (float64, error) {
 	return self.Percent(du)
 }
 
+// MyCPUTime returns CPU time(user and system, in seconds) of Datakit.
+func MyCPUTime() (float64, error) {
+	if self == nil {
+		return 0, errProcessInitFailed
+	}
+
+	t, err := self.Times()
+	if err != nil {
+		return 0, err
+	}
+
+	return t.User + t.System, nil
+}
+
 func MyCtxSwitch() *process.NumCtxSwitchesStat {
 	if self == nil {
 		return nil
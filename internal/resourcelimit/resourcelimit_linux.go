@@ -8,7 +8,11 @@
 
 package resourcelimit
 
-import "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit/cgroup"
+import (
+	"path"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/resourcelimit/cgroup"
+)
 
 func run(opt *ResourceLimitOptions) error {
 	return cgroup.Run(&cgroup.CgroupOptions{
@@ -23,3 +27,20 @@ func run(opt *ResourceLimitOptions) error {
 func info() string {
 	return cgroup.Info()
 }
+
+func limitProcess(name string, pid int, cpuMax float64, memMax int64) error {
+	return cgroup.Limit(&cgroup.CgroupOptions{
+		Path:   processCgroup(name),
+		CPUMax: cpuMax,
+		MemMax: memMax,
+		Enable: true,
+	}, pid)
+}
+
+func removeProcessLimit(name string) error {
+	return cgroup.Remove(processCgroup(name))
+}
+
+func processCgroup(name string) string {
+	return path.Join("/datakit-plugins", name)
+}
@@ -20,3 +20,11 @@ func run(opt *ResourceLimitOptions) error {
 func info() string {
 	return "-"
 }
+
+func limitProcess(string, int, float64, int64) error {
+	return fmt.Errorf("not implemented at os: %s", runtime.GOOS)
+}
+
+func removeProcessLimit(string) error {
+	return nil
+}
@@ -21,3 +21,15 @@ func run(opt *ResourceLimitOptions) error {
 func info() string {
 	return job.Info()
 }
+
+func limitProcess(_ string, pid int, cpuMax float64, memMax int64) error {
+	return job.Limit(&job.JobOptions{
+		CPUMax: cpuMax,
+		MemMax: memMax,
+	}, pid)
+}
+
+// the job object released by Windows once the process exited.
+func removeProcessLimit(string) error {
+	return nil
+}
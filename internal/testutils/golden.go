@@ -0,0 +1,43 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package testutils
+
+import (
+	"os"
+	"testing"
+
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/recorder"
+)
+
+// EnvUpdateGolden set to update golden files instead of comparing with them.
+const EnvUpdateGolden = "UPDATE_GOLDEN"
+
+// AssertGolden compares points collected in test with the golden file, which
+// is recorded in pb-json by recorder, see recorder.DiffPoints for options.
+//
+// Run tests with env UPDATE_GOLDEN=on to create or update golden files.
+func AssertGolden(t testing.TB, golden string, pts []*point.Point, opts ...recorder.DiffOption) {
+	t.Helper()
+
+	if os.Getenv(EnvUpdateGolden) != "" {
+		if err := recorder.WritePoints(golden, pts); err != nil {
+			t.Fatalf("update golden file %s: %s", golden, err)
+		}
+		t.Logf("golden file %s updated with %d points", golden, len(pts))
+		return
+	}
+
+	expect, err := recorder.LoadPoints(golden)
+	if err != nil {
+		t.Fatalf("load golden file %s: %s, run with env %s=on to create it", golden, err, EnvUpdateGolden)
+	}
+
+	if diff := recorder.DiffPoints(expect, pts, opts...); len(diff) > 0 {
+		t.Errorf("points differ from golden file %s(-golden +actual):\n%s", golden, diff)
+	}
+}
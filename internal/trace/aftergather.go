@@ -61,6 +61,7 @@ type AfterGather struct {
 	retry        time.Duration
 	pointOptions []point.Option
 	feeder       dkio.Feeder
+	topology     *Topology
 }
 
 // AppendFilter will append new filters into AfterGather structure
@@ -73,23 +74,59 @@ func (aga *AfterGather) AppendFilter(filter ...FilterFunc) {
 	aga.filters = append(aga.filters, filter...)
 }
 
-func (aga *AfterGather) doFeed(iname string, dktrace DatakitTrace) {
+// SetTopology enable service topology computing on traces before filters applied.
+func (aga *AfterGather) SetTopology(topo *Topology) {
+	aga.Lock()
+	defer aga.Unlock()
+
+	aga.topology = topo
+}
+
+func (aga *AfterGather) doFeed(iname string, dktrace DatakitTrace, opts ...dkio.FeedOption) (rejected int, err error) {
 	var pts []*point.Point
 	for _, span := range dktrace {
 		span.Point.AddTag(TagDKFingerprintKey, datakit.DatakitHostName)
 		pts = append(pts, span.Point)
 	}
 
-	if err := aga.feeder.FeedV2(point.Tracing, pts, dkio.WithInputName(iname)); err != nil {
+	var filtered int
+	// traces are received within request handlers, they should not be blocked by input throttle
+	opts = append([]dkio.FeedOption{
+		dkio.WithInputName(iname),
+		dkio.WithFilteredCount(&filtered),
+		dkio.WithRequestHandler(true),
+	}, opts...)
+
+	if err := aga.feeder.FeedV2(point.Tracing, pts, opts...); err != nil {
 		aga.log.Warnf("feed %d points failed: %s, ignored", len(pts), err.Error())
+		return len(pts), err
 	}
+
+	return filtered, nil
 }
 
 func (aga *AfterGather) Run(inputName string, dktraces DatakitTraces) {
+	aga.RunWithResult(inputName, dktraces) //nolint:errcheck
+}
+
+// RunWithResult is the same as Run, and return the number of spans rejected by IO(feed
+// failed or dropped by IO filters), err is the last feed error. Spans dropped by samplers
+// and trace filters are not counted as rejected.
+func (aga *AfterGather) RunWithResult(inputName string,
+	dktraces DatakitTraces,
+	opts ...dkio.FeedOption,
+) (rejected int, err error) {
 	if len(dktraces) == 0 {
 		aga.log.Debug("empty dktraces")
 
-		return
+		return 0, nil
+	}
+
+	// topology edges are computed before filters, samplers should not affect the call count.
+	if aga.topology != nil {
+		for k := range dktraces {
+			aga.topology.Observe(dktraces[k])
+		}
 	}
 
 	var afterFilters DatakitTraces
@@ -121,8 +158,14 @@ func (aga *AfterGather) Run(inputName string, dktraces DatakitTraces) {
 	}
 
 	for _, trace := range afterFilters {
-		aga.doFeed(inputName, trace)
+		n, feedErr := aga.doFeed(inputName, trace, opts...)
+		rejected += n
+		if feedErr != nil {
+			err = feedErr
+		}
 	}
+
+	return rejected, err
 }
 
 func NewAfterGather(options ...Option) *AfterGather {
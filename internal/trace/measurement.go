@@ -62,3 +62,33 @@ func (m *TraceMeasurement) Info() *inputs.MeasurementInfo {
 		},
 	}
 }
+
+type TopologyMeasurement struct{}
+
+// Point implement MeasurementV2.
+func (*TopologyMeasurement) Point() *point.Point {
+	return nil
+}
+
+func (*TopologyMeasurement) Info() *inputs.MeasurementInfo {
+	return &inputs.MeasurementInfo{
+		Name: MeasurementTopology,
+		Type: "metric",
+		Desc: "Service-to-service call edges computed from spans, the same data also reported as object.",
+		Tags: map[string]interface{}{
+			TagCallerService: &inputs.TagInfo{Desc: "Caller service name"},
+			TagCalleeService: &inputs.TagInfo{Desc: "Callee service name, or peer name(from `peer_tags`) if callee not instrumented"},
+			TagCalleeType:    &inputs.TagInfo{Desc: "Callee type, `service` or `peer`"},
+			TagProtocol:      &inputs.TagInfo{Desc: "Call protocol, such as `http`/`grpc`/`mysql`. Optional."},
+			TagSource:        &inputs.TagInfo{Desc: "Input name the spans come from"},
+		},
+		Fields: map[string]interface{}{
+			"call_count":   &inputs.FieldInfo{DataType: inputs.Int, Type: inputs.Count, Unit: inputs.NCount, Desc: "Call count within the interval"},
+			"error_count":  &inputs.FieldInfo{DataType: inputs.Int, Type: inputs.Count, Unit: inputs.NCount, Desc: "Error call count within the interval"},
+			"duration_sum": &inputs.FieldInfo{DataType: inputs.Int, Type: inputs.Count, Unit: inputs.DurationUS, Desc: "Total duration of calls"},
+			"duration_max": &inputs.FieldInfo{DataType: inputs.Int, Type: inputs.Gauge, Unit: inputs.DurationUS, Desc: "Max duration of calls"},
+			"duration_min": &inputs.FieldInfo{DataType: inputs.Int, Type: inputs.Gauge, Unit: inputs.DurationUS, Desc: "Min duration of calls"},
+			"duration_avg": &inputs.FieldInfo{DataType: inputs.Int, Type: inputs.Gauge, Unit: inputs.DurationUS, Desc: "Average duration of calls"},
+		},
+	}
+}
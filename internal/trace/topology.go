@@ -0,0 +1,433 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/logger"
+	"github.com/GuanceCloud/cliutils/point"
+
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/datakit"
+	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/goroutine"
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+const (
+	MeasurementTopology = "service_topology"
+
+	TagCallerService = "caller_service"
+	TagCalleeService = "callee_service"
+	TagCalleeType    = "callee_type"
+	TagProtocol      = "protocol"
+
+	CalleeTypeService = "service"
+	CalleeTypePeer    = "peer"
+
+	defaultTopologyInterval = time.Minute
+	defaultTopologyMaxEdges = 10000
+	defaultTopologySpanTTL  = 30 * time.Second
+	defaultTopologyMaxSpans = 100000
+)
+
+// DefaultPeerTags are span tags used to detect the callee on client spans whose callee is not instrumented.
+// The tags are checked in order and the first non-empty value wins.
+var DefaultPeerTags = []string{"peer_service", "db_system", "http_host", "net_peer_name"}
+
+type topoEdgeKey struct {
+	caller,
+	callee,
+	protocol,
+	calleeType string
+}
+
+type topoEdge struct {
+	calls,
+	errors,
+	durCount,
+	durSum,
+	durMax,
+	durMin int64
+}
+
+// topoSpanKey identify a span among all traces.
+type topoSpanKey struct {
+	traceID,
+	spanID string
+}
+
+// topoSpanRef keeps what's needed to build edges of a span after its payload
+// observed, parent and child spans may be sent in different payloads by
+// different processes.
+type topoSpanRef struct {
+	service,
+	protocol,
+	peer string
+	isErr  bool
+	dur    int64
+	hasDur bool
+	expire time.Time
+}
+
+// Topology computes service-to-service edges from parent/child spans and
+// feeds them as metric and object points periodically.
+type Topology struct {
+	sync.Mutex
+	Interval time.Duration `toml:"interval" json:"interval"`
+	PeerTags []string      `toml:"peer_tags" json:"peer_tags"`
+	MaxEdges int           `toml:"max_edges" json:"max_edges"`
+	SpanTTL  time.Duration `toml:"span_ttl" json:"span_ttl"`
+	MaxSpans int           `toml:"max_spans" json:"max_spans"`
+
+	inputName string
+	feeder    dkio.Feeder
+	log       *logger.Logger
+	edges     map[topoEdgeKey]*topoEdge
+	stop      chan struct{}
+
+	// spans seen within SpanTTL, used as parent of spans in later payloads
+	spans map[topoSpanKey]*topoSpanRef
+	// spans waiting for their parent, by parent
+	orphans map[topoSpanKey][]*topoSpanRef
+	// client spans reported as peer edges if no child span found within SpanTTL
+	peers map[topoSpanKey]*topoSpanRef
+	// number of spans cached in orphans
+	orphanCount int
+}
+
+// Init set default values and bind topology to input.
+func (topo *Topology) Init(inputName string, feeder dkio.Feeder, log *logger.Logger) *Topology {
+	if topo.Interval <= 0 {
+		topo.Interval = defaultTopologyInterval
+	}
+	if len(topo.PeerTags) == 0 {
+		topo.PeerTags = DefaultPeerTags
+	}
+	if topo.MaxEdges <= 0 {
+		topo.MaxEdges = defaultTopologyMaxEdges
+	}
+	if topo.SpanTTL <= 0 {
+		topo.SpanTTL = defaultTopologySpanTTL
+	}
+	if topo.MaxSpans <= 0 {
+		topo.MaxSpans = defaultTopologyMaxSpans
+	}
+	if log == nil {
+		log = logger.DefaultSLogger("topology")
+	}
+
+	topo.inputName = inputName
+	topo.feeder = feeder
+	topo.log = log
+	topo.edges = make(map[topoEdgeKey]*topoEdge)
+	topo.spans = make(map[topoSpanKey]*topoSpanRef)
+	topo.orphans = make(map[topoSpanKey][]*topoSpanRef)
+	topo.peers = make(map[topoSpanKey]*topoSpanRef)
+	topo.stop = make(chan struct{})
+
+	return topo
+}
+
+// Observe collect edges from spans of a payload. Parent of a span is looked
+// up in spans observed within SpanTTL, and spans whose parent not observed yet
+// are kept until the parent arrives or expired.
+func (topo *Topology) Observe(dktrace DatakitTrace) {
+	if len(dktrace) == 0 {
+		return
+	}
+
+	now := time.Now()
+	expire := now.Add(topo.SpanTTL)
+
+	type observed struct {
+		key, parent topoSpanKey
+		ref         *topoSpanRef
+		isClient    bool
+	}
+
+	arr := make([]*observed, 0, len(dktrace))
+	for _, span := range dktrace {
+		service := span.GetTag(TagService)
+		if service == "" {
+			continue
+		}
+
+		traceID := span.GetFiledToString(FieldTraceID)
+		x := &observed{
+			key:      topoSpanKey{traceID, span.GetFiledToString(FieldSpanid)},
+			parent:   topoSpanKey{traceID, span.GetFiledToString(FieldParentID)},
+			isClient: isClientSpan(span),
+			ref: &topoSpanRef{
+				service:  service,
+				protocol: spanProtocol(span),
+				isErr:    span.GetTag(TagSpanStatus) == StatusErr,
+				expire:   expire,
+			},
+		}
+
+		if du, ok := span.Get(FieldDuration).(int64); ok {
+			x.ref.dur, x.ref.hasDur = du, true
+		}
+
+		if x.isClient {
+			x.ref.peer = topo.peerService(span)
+		}
+
+		arr = append(arr, x)
+	}
+
+	topo.Lock()
+	defer topo.Unlock()
+
+	// register spans first, so parents in the same payload are found
+	// regardless of the order.
+	for _, x := range arr {
+		if len(topo.spans) < topo.MaxSpans {
+			topo.spans[x.key] = x.ref
+		}
+	}
+
+	for _, x := range arr {
+		// client span whose callee not instrumented, try to find the peer.
+		if x.isClient && x.ref.peer != "" && x.ref.peer != x.ref.service && len(topo.peers) < topo.MaxSpans {
+			topo.peers[x.key] = x.ref
+		}
+	}
+
+	for _, x := range arr {
+		if x.parent.spanID == "" || x.parent.spanID == "0" {
+			continue
+		}
+
+		if parent, ok := topo.spans[x.parent]; ok {
+			topo.link(x.parent, parent, x.ref)
+		} else if topo.orphanCount < topo.MaxSpans {
+			topo.orphans[x.parent] = append(topo.orphans[x.parent], x.ref)
+			topo.orphanCount++
+		}
+	}
+
+	for _, x := range arr {
+		if children, ok := topo.orphans[x.key]; ok {
+			delete(topo.orphans, x.key)
+			topo.orphanCount -= len(children)
+
+			for _, child := range children {
+				topo.link(x.key, x.ref, child)
+			}
+		}
+	}
+}
+
+// link add service edge from parent to child, parent is not a peer edge
+// since its callee is instrumented.
+func (topo *Topology) link(parentKey topoSpanKey, parent, child *topoSpanRef) {
+	delete(topo.peers, parentKey)
+
+	if parent.service == child.service {
+		return
+	}
+
+	protocol := parent.protocol
+	if protocol == "" {
+		protocol = child.protocol
+	}
+
+	topo.addEdge(topoEdgeKey{parent.service, child.service, protocol, CalleeTypeService}, child)
+}
+
+// expire drop spans expired, and add peer edges of client spans without
+// child span found. All pending peers are added if all set.
+func (topo *Topology) expire(now time.Time, all bool) {
+	topo.Lock()
+	defer topo.Unlock()
+
+	for k, ref := range topo.spans {
+		if all || now.After(ref.expire) {
+			delete(topo.spans, k)
+		}
+	}
+
+	for k, children := range topo.orphans {
+		if all || now.After(children[0].expire) {
+			delete(topo.orphans, k)
+			topo.orphanCount -= len(children)
+		}
+	}
+
+	for k, ref := range topo.peers {
+		if all || now.After(ref.expire) {
+			delete(topo.peers, k)
+			topo.addEdge(topoEdgeKey{ref.service, ref.peer, ref.protocol, CalleeTypePeer}, ref)
+		}
+	}
+}
+
+func (topo *Topology) addEdge(key topoEdgeKey, span *topoSpanRef) {
+	edge, ok := topo.edges[key]
+	if !ok {
+		if len(topo.edges) >= topo.MaxEdges {
+			topo.log.Debugf("topology edges exceed max edges %d, edge %s -> %s dropped", topo.MaxEdges, key.caller, key.callee)
+
+			return
+		}
+
+		edge = &topoEdge{durMin: -1}
+		topo.edges[key] = edge
+	}
+
+	edge.calls++
+	if span.isErr {
+		edge.errors++
+	}
+
+	if span.hasDur {
+		du := span.dur
+		edge.durCount++
+		edge.durSum += du
+		if du > edge.durMax {
+			edge.durMax = du
+		}
+		if edge.durMin < 0 || du < edge.durMin {
+			edge.durMin = du
+		}
+	}
+}
+
+func (topo *Topology) peerService(span *DkSpan) string {
+	for _, key := range topo.PeerTags {
+		if v := span.GetTag(key); v != "" {
+			return v
+		}
+		if v := span.GetFiledToString(key); v != "" {
+			return v
+		}
+	}
+
+	return ""
+}
+
+// isClientSpan check span is an outgoing call. span_kind is preferred and
+// span_type is used as a fallback for tracers that do not report span kind.
+func isClientSpan(span *DkSpan) bool {
+	switch span.GetTag("span_kind") {
+	case "client", "producer":
+		return true
+	case "server", "consumer", "internal":
+		return false
+	default:
+		return span.GetTag(TagSpanType) == SpanTypeExit
+	}
+}
+
+func spanProtocol(span *DkSpan) string {
+	for _, key := range []string{"rpc_system", "db_system", "messaging_system"} {
+		if v := span.GetTag(key); v != "" {
+			return v
+		}
+	}
+
+	if span.GetTag(TagHttpMethod) != "" || span.GetTag(TagHttpUrl) != "" {
+		return "http"
+	}
+
+	return span.GetTag(TagSourceType)
+}
+
+// Start run flush worker in background.
+func (topo *Topology) Start() {
+	stop := topo.stop
+	g := goroutine.NewGroup(goroutine.Option{Name: "trace_topology"})
+	g.Go(func(ctx context.Context) error {
+		tick := time.NewTicker(topo.Interval)
+		defer tick.Stop()
+
+		for {
+			select {
+			case <-datakit.Exit.Wait():
+				return nil
+			case <-stop:
+				return nil
+			case now := <-tick.C:
+				topo.flush(now, false)
+			}
+		}
+	})
+}
+
+// Stop flush worker, and flush edges not reported yet.
+func (topo *Topology) Stop() {
+	if topo.stop != nil {
+		close(topo.stop)
+		topo.stop = nil
+		topo.flush(time.Now(), true)
+	}
+}
+
+// flush report edges observed since last flush. Pending peer edges are added
+// if expired, or all of them if final.
+func (topo *Topology) flush(now time.Time, final bool) {
+	topo.expire(now, final)
+
+	topo.Lock()
+	edges := topo.edges
+	topo.edges = make(map[topoEdgeKey]*topoEdge)
+	topo.Unlock()
+
+	if len(edges) == 0 {
+		return
+	}
+
+	metrics, objects := topo.buildPoints(edges, now)
+
+	if err := topo.feeder.FeedV2(point.Metric, metrics, dkio.WithInputName(topo.inputName+"/topology")); err != nil {
+		topo.log.Warnf("feed %d topology metrics failed: %s, ignored", len(metrics), err.Error())
+	}
+
+	if err := topo.feeder.FeedV2(point.Object, objects, dkio.WithInputName(topo.inputName+"/topology")); err != nil {
+		topo.log.Warnf("feed %d topology objects failed: %s, ignored", len(objects), err.Error())
+	}
+}
+
+func (topo *Topology) buildPoints(edges map[topoEdgeKey]*topoEdge, now time.Time) (metrics, objects []*point.Point) {
+	for key, edge := range edges {
+		var tags point.KVs
+		tags = tags.AddTag(TagCallerService, key.caller).
+			AddTag(TagCalleeService, key.callee).
+			AddTag(TagCalleeType, key.calleeType).
+			AddTag(TagSource, topo.inputName)
+		if key.protocol != "" {
+			tags = tags.AddTag(TagProtocol, key.protocol)
+		}
+
+		var kvs point.KVs
+		kvs = kvs.Add("call_count", edge.calls, false, false).
+			Add("error_count", edge.errors, false, false)
+		if edge.durMin >= 0 {
+			kvs = kvs.Add("duration_sum", edge.durSum, false, false).
+				Add("duration_max", edge.durMax, false, false).
+				Add("duration_min", edge.durMin, false, false).
+				Add("duration_avg", edge.durSum/edge.durCount, false, false)
+		}
+
+		metrics = append(metrics, point.NewPointV2(MeasurementTopology,
+			append(append(point.KVs{}, tags...), kvs...),
+			append(point.DefaultMetricOptions(), point.WithTime(now))...))
+
+		objKVs := append(point.KVs{}, tags...)
+		objKVs = objKVs.AddTag("name", key.caller+"->"+key.callee).
+			Add("call_count", edge.calls, false, false).
+			Add("error_count", edge.errors, false, false).
+			Add("last_active", now.UnixMilli(), false, false)
+
+		objects = append(objects, point.NewPointV2(MeasurementTopology, objKVs,
+			append(point.DefaultObjectOptions(), point.WithTime(now))...))
+	}
+
+	return metrics, objects
+}
@@ -0,0 +1,188 @@
+// Unless explicitly stated otherwise all files in this repository are licensed
+// under the MIT License.
+// This product includes software developed at Guance Cloud (https://www.guance.com/).
+// Copyright 2021-present Guance, Inc.
+
+package trace
+
+import (
+	"testing"
+	"time"
+
+	"github.com/GuanceCloud/cliutils/point"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	dkio "gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/io"
+)
+
+func topoSpan(service, spanID, parentID, status string, duration int64, tags map[string]string) *DkSpan {
+	var kvs point.KVs
+	kvs = kvs.AddTag(TagService, service).
+		AddTag(TagSpanStatus, status).
+		Add(FieldSpanid, spanID, false, false).
+		Add(FieldParentID, parentID, false, false).
+		Add(FieldDuration, duration, false, false)
+	for k, v := range tags {
+		kvs = kvs.AddTag(k, v)
+	}
+
+	return NewAPMPoint("ddtrace", kvs)
+}
+
+func withTraceID(traceID string, spans ...*DkSpan) DatakitTrace {
+	for _, span := range spans {
+		span.Add(FieldTraceID, traceID)
+	}
+	return spans
+}
+
+func TestTopologyObserve(t *testing.T) {
+	topo := (&Topology{}).Init("ddtrace", dkio.NewMockedFeeder(), nil)
+
+	trace := func(traceID string) DatakitTrace {
+		return withTraceID(traceID,
+			topoSpan("frontend", "1", "0", StatusOk, 300, map[string]string{TagSpanType: SpanTypeEntry}),
+			topoSpan("frontend", "2", "1", StatusOk, 200, map[string]string{"span_kind": "client", TagHttpMethod: "GET"}),
+			topoSpan("backend", "3", "2", StatusErr, 150, map[string]string{"span_kind": "server"}),
+			topoSpan("backend", "4", "3", StatusOk, 50, map[string]string{"span_kind": "client", "db_system": "mysql"}),
+			topoSpan("backend", "5", "3", StatusOk, 10, map[string]string{TagSpanType: SpanTypeExit, "peer_service": "redis-cache"}),
+			topoSpan("backend", "6", "3", StatusOk, 10, map[string]string{TagSpanType: SpanTypeLocal}),
+		)
+	}
+
+	topo.Observe(trace("t1"))
+	topo.Observe(trace("t2"))
+	require.Len(t, topo.edges, 1, "peer edges pending")
+
+	topo.expire(time.Now().Add(time.Hour), false)
+
+	require.Len(t, topo.edges, 3)
+
+	edge := topo.edges[topoEdgeKey{"frontend", "backend", "http", CalleeTypeService}]
+	require.NotNil(t, edge)
+	assert.Equal(t, int64(2), edge.calls)
+	assert.Equal(t, int64(2), edge.errors)
+	assert.Equal(t, int64(150), edge.durMax)
+
+	edge = topo.edges[topoEdgeKey{"backend", "mysql", "mysql", CalleeTypePeer}]
+	require.NotNil(t, edge)
+	assert.Equal(t, int64(2), edge.calls)
+	assert.Equal(t, int64(0), edge.errors)
+
+	assert.NotNil(t, topo.edges[topoEdgeKey{"backend", "redis-cache", "", CalleeTypePeer}])
+}
+
+func TestTopologyAcrossPayloads(t *testing.T) {
+	frontend := func(traceID string) DatakitTrace {
+		return withTraceID(traceID,
+			topoSpan("frontend", "1", "0", StatusOk, 300, nil),
+			topoSpan("frontend", "2", "1", StatusOk, 200, map[string]string{"span_kind": "client", "http_host": "backend:8080"}),
+		)
+	}
+
+	backend := func(traceID string) DatakitTrace {
+		return withTraceID(traceID,
+			topoSpan("backend", "3", "2", StatusOk, 150, map[string]string{"span_kind": "server"}),
+		)
+	}
+
+	t.Run("parent-first", func(t *testing.T) {
+		topo := (&Topology{}).Init("ddtrace", dkio.NewMockedFeeder(), nil)
+		topo.Observe(frontend("t1"))
+		topo.Observe(backend("t1"))
+		topo.expire(time.Now().Add(time.Hour), false)
+
+		require.Len(t, topo.edges, 1, "instrumented callee not reported as peer")
+		assert.NotNil(t, topo.edges[topoEdgeKey{"frontend", "backend", "", CalleeTypeService}])
+	})
+
+	t.Run("child-first", func(t *testing.T) {
+		topo := (&Topology{}).Init("ddtrace", dkio.NewMockedFeeder(), nil)
+		topo.Observe(backend("t1"))
+		topo.Observe(frontend("t1"))
+		topo.expire(time.Now().Add(time.Hour), false)
+
+		require.Len(t, topo.edges, 1)
+		assert.NotNil(t, topo.edges[topoEdgeKey{"frontend", "backend", "", CalleeTypeService}])
+		assert.Empty(t, topo.orphans)
+	})
+
+	t.Run("other-trace", func(t *testing.T) {
+		topo := (&Topology{}).Init("ddtrace", dkio.NewMockedFeeder(), nil)
+		topo.Observe(frontend("t1"))
+		topo.Observe(backend("t2"))
+		topo.expire(time.Now().Add(time.Hour), false)
+
+		require.Len(t, topo.edges, 1)
+		assert.NotNil(t, topo.edges[topoEdgeKey{"frontend", "backend:8080", "", CalleeTypePeer}])
+		assert.Empty(t, topo.spans)
+		assert.Empty(t, topo.orphans)
+	})
+}
+
+func TestTopologyStop(t *testing.T) {
+	feeder := dkio.NewMockedFeeder()
+	topo := (&Topology{}).Init("ddtrace", feeder, nil)
+	topo.Start()
+
+	topo.Observe(withTraceID("t1",
+		topoSpan("a", "1", "0", StatusOk, 10, map[string]string{"span_kind": "client", "db_system": "mysql"}),
+	))
+	topo.Stop()
+
+	pts, err := feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	require.Len(t, pts, 1)
+	assert.Equal(t, "mysql", pts[0].GetTag(TagCalleeService))
+}
+
+func TestTopologyMaxEdges(t *testing.T) {
+	topo := (&Topology{MaxEdges: 1}).Init("ddtrace", dkio.NewMockedFeeder(), nil)
+
+	topo.Observe(DatakitTrace{
+		topoSpan("a", "1", "0", StatusOk, 10, nil),
+		topoSpan("b", "2", "1", StatusOk, 10, nil),
+		topoSpan("c", "3", "2", StatusOk, 10, nil),
+	})
+
+	assert.Len(t, topo.edges, 1)
+}
+
+func TestTopologyFlush(t *testing.T) {
+	feeder := dkio.NewMockedFeeder()
+	topo := (&Topology{}).Init("ddtrace", feeder, nil)
+
+	topo.Observe(DatakitTrace{
+		topoSpan("a", "1", "0", StatusOk, 10, nil),
+		topoSpan("b", "2", "1", StatusOk, 30, nil),
+		topoSpan("b", "3", "1", StatusErr, 50, nil),
+	})
+
+	// span without duration not counted in duration
+	span := topoSpan("b", "4", "1", StatusOk, 0, nil)
+	span.Del(FieldDuration)
+	topo.Observe(DatakitTrace{topoSpan("a", "1", "0", StatusOk, 10, nil), span})
+
+	now := time.Unix(1700000000, 0)
+	topo.flush(now, false)
+	assert.Len(t, topo.edges, 0)
+
+	pts, err := feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	require.Len(t, pts, 1)
+
+	pt := pts[0]
+	assert.Equal(t, MeasurementTopology, pt.Name())
+	assert.Equal(t, "a", pt.GetTag(TagCallerService))
+	assert.Equal(t, "b", pt.GetTag(TagCalleeService))
+	assert.Equal(t, int64(3), pt.Get("call_count"))
+	assert.Equal(t, int64(1), pt.Get("error_count"))
+	assert.Equal(t, int64(40), pt.Get("duration_avg"))
+	assert.Equal(t, now.UnixNano(), pt.Time().UnixNano())
+
+	objs, err := feeder.AnyPoints(time.Second)
+	require.NoError(t, err)
+	require.Len(t, objs, 1)
+	assert.Equal(t, "a->b", objs[0].GetTag("name"))
+}
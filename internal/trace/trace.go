@@ -17,6 +17,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/klauspost/compress/zstd"
 	"gitlab.jiagouyun.com/cloudcare-tools/datakit/internal/httpapi"
 	"google.golang.org/grpc"
 )
@@ -342,12 +343,21 @@ func ParseTracerRequest(req *http.Request) (contentType, encode string, buf []by
 	}
 
 	var body io.ReadCloser
-	if httpapi.GetHeader(req, "Content-Encoding") == "gzip" {
+	switch httpapi.GetHeader(req, "Content-Encoding") {
+	case "gzip":
 		encode = "gzip"
 		if body, err = gzip.NewReader(req.Body); err == nil {
 			defer body.Close() // nolint:errcheck
 		}
-	} else {
+	case "zstd":
+		encode = "zstd"
+		var dec *zstd.Decoder
+		if dec, err = zstd.NewReader(req.Body); err != nil {
+			return
+		}
+		body = dec.IOReadCloser()
+		defer body.Close() // nolint:errcheck
+	default:
 		body = req.Body
 	}
 